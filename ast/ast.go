@@ -35,9 +35,9 @@ type Expression interface {
 	expressionNode()
 }
 
-// LetStatement holds the name and value for a let statement
+// LetStatement holds the name and value for a let statement, or a const statement when Const is set
 type LetStatement struct {
-	Token token.Token // the token.LET token
+	Token token.Token // the token.LET or token.CONST token
 	Name  *Identifier // Name holds the identifer of the binding
 	// Value is the expression that produces the value eg: 5 in `let x = 5`.
 	// Coincidentally, it can also be an identifier for an expression in a different statement
@@ -46,6 +46,10 @@ type LetStatement struct {
 	// Therefore in this statement, `let x = valueProducingIdentifier`, valueProducingIdentifer
 	// is an identifier that serves as an expression - it produces a value
 	Value Expression
+	// Doc is the text of the ### doc comment preceding the let statement
+	Doc string
+	// Const is set for `const x = 5`, the binding cannot be reassigned
+	Const bool
 }
 
 // statementNode is implemented to allow LetStatement to be served as a Statement
@@ -71,11 +75,69 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// MultiLetStatement binds several names at once, `let a, b = 1, 2;`, Names[i] is bound to Values[i].
+// Every value is evaluated before any name is bound, so `let a, b = b, a;` swaps two bindings.
+// Const is set for `const a, b = 1, 2;`, like for a LetStatement.
+type MultiLetStatement struct {
+	Token  token.Token // the token.LET or token.CONST token
+	Names  []*Identifier
+	Values []Expression
+	Const  bool
+}
+
+// statementNode is implemented to allow MultiLetStatement to be served as a Statement
+func (ms *MultiLetStatement) statementNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for a token of type Token.LET
+func (ms *MultiLetStatement) TokenLiteral() string { return ms.Token.Literal }
+
+// String constructs the entire MultiLetStatement node as a string
+func (ms *MultiLetStatement) String() string {
+	names := make([]string, len(ms.Names))
+	for i, name := range ms.Names {
+		names[i] = name.String()
+	}
+	values := make([]string, len(ms.Values))
+	for i, value := range ms.Values {
+		values[i] = value.String()
+	}
+	return ms.TokenLiteral() + " " + strings.Join(names, ", ") + " = " + strings.Join(values, ", ") + ";"
+}
+
+// MultiAssignStatement rebinds several names bound before at once, `a, b = b, a;`, Names[i] is assigned Values[i].
+// Every value is evaluated before any name is assigned, so it swaps two bindings like a MultiLetStatement.
+type MultiAssignStatement struct {
+	Token  token.Token // the '=' token
+	Names  []*Identifier
+	Values []Expression
+}
+
+// statementNode is implemented to allow MultiAssignStatement to be served as a Statement
+func (ms *MultiAssignStatement) statementNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the = token
+func (ms *MultiAssignStatement) TokenLiteral() string { return ms.Token.Literal }
+
+// String constructs the entire MultiAssignStatement node as a string
+func (ms *MultiAssignStatement) String() string {
+	names := make([]string, len(ms.Names))
+	for i, name := range ms.Names {
+		names[i] = name.String()
+	}
+	values := make([]string, len(ms.Values))
+	for i, value := range ms.Values {
+		values[i] = value.String()
+	}
+	return strings.Join(names, ", ") + " = " + strings.Join(values, ", ") + ";"
+}
+
 // Identifier holds the identifier of a binding eg: x in `let x = 5` and implements the Expression interface
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	// Value is used to represent the name in a variable binding x in `let x = 5`,
 	Value string
+	// Type is the optional annotation of the name of a let statement or a function parameter, int in `let x: int = 5`
+	Type *TypeAnnotation
 }
 
 // expressionNode is implemented to allow Identifier to be served as an Expression
@@ -84,8 +146,26 @@ func (i *Identifier) expressionNode() {}
 // TokenLiteral returns the literal value (Token.Literal) for a token of type Token.IDENT
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 
-// String() returns the identifier's name value (x in let x = 5)
-func (i *Identifier) String() string { return i.Value }
+// String() returns the identifier's name value (x in let x = 5), followed by its type annotation if it has one
+func (i *Identifier) String() string {
+	if i.Type != nil {
+		return i.Value + ": " + i.Type.String()
+	}
+	return i.Value
+}
+
+// TypeAnnotation holds the name of a static type eg: int in `let x: int = 5` or `fn(a: int) -> int`.
+// Annotations are optional and do not change how a program runs, the typecheck package checks them.
+type TypeAnnotation struct {
+	Token token.Token // the token of the type name
+	Name  string
+}
+
+// TokenLiteral returns the literal value (Token.Literal) for the type name
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+
+// String returns the name of the type
+func (ta *TypeAnnotation) String() string { return ta.Name }
 
 // ReturnStatement holds a Token field for the return token
 // and a ReturnValue field for the expression that's to be returned
@@ -115,6 +195,34 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// BreakStatement leaves the innermost loop it is in, `while (true) { break; }`
+type BreakStatement struct {
+	Token token.Token // the token.BREAK token
+}
+
+// statementNode is implmented to allow BreakStatement to be served as a Statement
+func (bs *BreakStatement) statementNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for a token of type token.BREAK
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+
+// String constructs the BreakStatement node as a string
+func (bs *BreakStatement) String() string { return bs.TokenLiteral() + ";" }
+
+// ContinueStatement skips the rest of the body of the innermost loop it is in and starts its next iteration
+type ContinueStatement struct {
+	Token token.Token // the token.CONTINUE token
+}
+
+// statementNode is implmented to allow ContinueStatement to be served as a Statement
+func (cs *ContinueStatement) statementNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for a token of type token.CONTINUE
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+
+// String constructs the ContinueStatement node as a string
+func (cs *ContinueStatement) String() string { return cs.TokenLiteral() + ";" }
+
 // ExpressionStatement holds a Token field and
 // an Expression field for the expression.
 // It implements the Node and Statement interfaces.
@@ -153,6 +261,21 @@ func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 // String constructs the integer value as a string
 func (il *IntegerLiteral) String() string { return il.Token.Literal }
 
+// FloatLiteral holds the FLOAT token of a floating-point literal and its value
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+// expressionNode is implemented to allow FloatLiteral to be served as an Expression
+func (fl *FloatLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) of the float
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+
+// String returns the float as it was written
+func (fl *FloatLiteral) String() string { return fl.Token.Literal }
+
 // PrefixExpression holds a Token field for the input,
 // Operator is a string that contains either "-" or "!" and
 // Right contains the expression to the right of the operator.
@@ -260,6 +383,147 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// ConditionalExpression is the expression form of an if expression, `cond ? a : b`.
+// Only one of Consequence and Alternative is evaluated, depending on whether Condition is truthy.
+type ConditionalExpression struct {
+	Token       token.Token // The '?' token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+// expressionNode is implemented to allow ConditionalExpression to be served as an Expression
+func (ce *ConditionalExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the '?' token
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+
+// String will contruct the entire ConditionalExpression as a string
+func (ce *ConditionalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ce.Condition.String())
+	out.WriteString(" ? ")
+	out.WriteString(ce.Consequence.String())
+	out.WriteString(" : ")
+	out.WriteString(ce.Alternative.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// SwitchExpression runs the body of the first case with a value equal to Subject, or the Default body when
+// no case matches, `switch (x) { case 1, 2 { ... } case "a" { ... } default { ... } }`. Its value is the
+// value of the body that ran, null when no case matches and there is no default.
+type SwitchExpression struct {
+	Token   token.Token // The 'switch' token
+	Subject Expression
+	Cases   []*SwitchCase
+	Default *BlockStatement
+}
+
+// SwitchCase is a case of a switch expression, its Body runs when one of its Values is equal to the subject
+type SwitchCase struct {
+	Token  token.Token // The 'case' token
+	Values []Expression
+	Body   *BlockStatement
+}
+
+// expressionNode is implemented to allow SwitchExpression to be served as an Expression
+func (se *SwitchExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the switch token
+func (se *SwitchExpression) TokenLiteral() string { return se.Token.Literal }
+
+// String will contruct the entire SwitchExpression as a string
+func (se *SwitchExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("switch (")
+	out.WriteString(se.Subject.String())
+	out.WriteString(") {")
+	for _, c := range se.Cases {
+		values := []string{}
+		for _, v := range c.Values {
+			values = append(values, v.String())
+		}
+		out.WriteString(" case ")
+		out.WriteString(strings.Join(values, ", "))
+		out.WriteString(" ")
+		out.WriteString(c.Body.String())
+	}
+	if se.Default != nil {
+		out.WriteString(" default ")
+		out.WriteString(se.Default.String())
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// WhileExpression repeats its Body as long as its Condition is truthy, `while (x < 10) { ... }`.
+// Like every expression it produces a value, which is always null.
+type WhileExpression struct {
+	Token     token.Token // The 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+// expressionNode is implemented to allow WhileExpression to be served as an Expression
+func (we *WhileExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the while token
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+
+// String constructs the entire WhileExpression as a string
+func (we *WhileExpression) String() string {
+	return "while" + we.Condition.String() + " " + we.Body.String()
+}
+
+// AssignExpression rebinds a name bound before with a let statement, `x = x + 1`.
+// Its value is the value assigned.
+type AssignExpression struct {
+	Token token.Token // The '=' token
+	Name  *Identifier
+	Value Expression
+}
+
+// expressionNode is implemented to allow AssignExpression to be served as an Expression
+func (ae *AssignExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the = token
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+
+// String constructs the entire AssignExpression as a string
+func (ae *AssignExpression) String() string {
+	return ae.Name.String() + " = " + ae.Value.String()
+}
+
+// ForExpression runs its Body once for every element of a collection, `for (x in arr) { ... }`.
+// Key is only set when the loop binds two names, `for (k, v in hash) { ... }`.
+type ForExpression struct {
+	Token    token.Token // The 'for' token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+// expressionNode is implemented to allow ForExpression to be served as an Expression
+func (fe *ForExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the for token
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+
+// String constructs the entire ForExpression as a string
+func (fe *ForExpression) String() string {
+	names := fe.Value.String()
+	if fe.Key != nil {
+		names = fe.Key.String() + ", " + names
+	}
+	return "for (" + names + " in " + fe.Iterable.String() + ") " + fe.Body.String()
+}
+
 // BlockStatement holds the necessary information
 // to construct a statement(s) that exist within an IfExpression or Function Literal
 type BlockStatement struct {
@@ -290,8 +554,12 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token     // The 'fn' token
 	Parameters []*Identifier   // The parameters of the function
+	Defaults   []Expression    // The default values of the last len(Defaults) parameters before the variadic one, 10 in `fn(x, y = 10)`
+	Variadic   bool            // Whether the last parameter collects the remaining arguments in an array, rest in `fn(x, rest...)`
 	Body       *BlockStatement // The collection of statements in the body of the function
 	Name       string          // The name the function is bound to
+	Doc        string          // The doc comment of the let statement binding the function
+	ReturnType *TypeAnnotation // The optional annotation of the returned value, int in `fn() -> int { 1 }`
 }
 
 // expressionNode is implemented to allow FunctionLiteral to be served as an Expression
@@ -308,8 +576,15 @@ func (fl *FunctionLiteral) String() string {
 
 	params := []string{}
 
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		switch value := fl.Default(i); {
+		case value != nil:
+			params = append(params, p.String()+" = "+value.String())
+		case i == fl.Fixed():
+			params = append(params, VariadicString(p))
+		default:
+			params = append(params, p.String())
+		}
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -319,17 +594,58 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// Required returns the number of parameters without a default value, the minimum number of arguments of a call
+func (fl *FunctionLiteral) Required() int {
+	return fl.Fixed() - len(fl.Defaults)
+}
+
+// Fixed returns the number of parameters bound to a single argument, every parameter but the variadic one.
+// A call of a function that is not variadic accepts at most that many arguments.
+func (fl *FunctionLiteral) Fixed() int {
+	if fl.Variadic {
+		return len(fl.Parameters) - 1
+	}
+	return len(fl.Parameters)
+}
+
+// Default returns the default value of the parameter at index i, nil when the parameter has none
+func (fl *FunctionLiteral) Default(i int) Expression {
+	if i < fl.Required() || i >= fl.Fixed() {
+		return nil
+	}
+	return fl.Defaults[i-fl.Required()]
+}
+
+// VariadicString returns the variadic parameter as it is written, with its annotation typing every argument it collects
+func VariadicString(param *Identifier) string {
+	if param.Type != nil {
+		return param.Value + "...: " + param.Type.String()
+	}
+	return param.Value + "..."
+}
+
 // CallExpression consist of an expression that results in a function when evaluated
 // and a list of expressions that are the arguments of this function call
 type CallExpression struct {
 	Token     token.Token  // The "(" token
 	Function  Expression   // Identifier of Function Literal
 	Arguments []Expression // The list of expressions that are arguments to the function call
+	// Names holds the names of the named arguments, `f(1, y: 2)`. They come after the positional arguments,
+	// so the name of the i-th named argument is Names[i] and its value is Arguments[Positional()+i].
+	Names []*Identifier
+}
+
+// Positional returns the number of arguments passed by position, they come before the named arguments
+func (ce *CallExpression) Positional() int {
+	return len(ce.Arguments) - len(ce.Names)
 }
 
 // expressionNode is implemented to allow CallExpression to be served as an Expression
@@ -346,7 +662,11 @@ func (ce *CallExpression) String() string {
 
 	args := []string{}
 	// stringify all arguments
-	for _, a := range ce.Arguments {
+	for i, a := range ce.Arguments {
+		if named := i - ce.Positional(); named >= 0 {
+			args = append(args, ce.Names[named].Value+": "+a.String())
+			continue
+		}
 		args = append(args, a.String())
 	}
 
@@ -375,6 +695,21 @@ func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 // String returns the literal value (Token.Literal) for the the StringLiteral
 func (sl *StringLiteral) String() string { return sl.Token.Literal }
 
+// SymbolLiteral holds the COLON token of a symbol literal, :name, and the name of the symbol
+type SymbolLiteral struct {
+	Token token.Token
+	Value string
+}
+
+// expressionNode is implemented to allow SymbolLiteral to be served as an Expression
+func (sl *SymbolLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) of the COLON token
+func (sl *SymbolLiteral) TokenLiteral() string { return sl.Token.Literal }
+
+// String returns the symbol literal, :name
+func (sl *SymbolLiteral) String() string { return ":" + sl.Value }
+
 // Program serves as the root node of every AST a parser produces.
 type Program struct {
 	Statements []Statement // Statements are just a slice of AST nodes
@@ -432,13 +767,44 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// MemberExpression is used to construct an ast.Node for member access expressions (strings.upper)
+// Parsing the tokens of a member access should return a MemberExpression struct.
+// MemberExpression is a valid expression node within the abstract-syntax tree.
+type MemberExpression struct {
+	Token    token.Token // The . Token
+	Object   Expression
+	Property *Identifier
+}
+
+// expressionNode is implemented to allow MemberExpression to be served as an Expression
+func (me *MemberExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the dot of the member access
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+
+// String builds the entire MemberExpression as a string, the object and property joined by a dot
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	out.WriteString(".")
+	out.WriteString(me.Property.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // IndexExpression is used to construct an ast.Node for index operator expressions ([1, 2, 3][1])
 // Parsing the tokens of an index operator expression should return an IndexExpression struct.
 // IndexExpression is a valid expression node within the abstract-syntax tree.
 type IndexExpression struct {
-	Token token.Token // The [ Token
+	Token token.Token // The [ Token, or the ?[ Token of a safe navigation
 	Left  Expression
 	Index Expression
+	// Optional marks a safe navigation (list?[0]), which is null instead of an error when Left is null.
+	// The index is not evaluated in that case.
+	Optional bool
 }
 
 // expressionNode is implemented to allow IndexExpression to be served as an Expression
@@ -458,6 +824,9 @@ func (ie *IndexExpression) String() string {
 	// could take the form of an identifier, array literal a function call,
 	// or any expression.
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	// stringify the "index" which can take the form of any valid expression.
 	out.WriteString(ie.Index.String())
@@ -466,6 +835,47 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is used to construct an ast.Node for slice expressions ([1, 2, 3][1:3])
+// Low and High are the bounds of the slice, either of them is nil when it is omitted (xs[:2], xs[1:]).
+// SliceExpression is a valid expression node within the abstract-syntax tree.
+type SliceExpression struct {
+	Token token.Token // The [ Token, or the ?[ Token of a safe navigation
+	Left  Expression
+	Low   Expression
+	High  Expression
+	// Optional marks a safe navigation (list?[1:]), which is null instead of an error when Left is null.
+	// The bounds are not evaluated in that case.
+	Optional bool
+}
+
+// expressionNode is implemented to allow SliceExpression to be served as an Expression
+func (se *SliceExpression) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the opening bracket of the slice operation
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+
+// String builds the entire SliceExpression as a string, the omitted bounds are left out
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 // HashLiteral is used to construct an ast.Node for hash literals ({ "a": 1 })
 // Parsing the tokens of a hash literal should return an HashLiteral struct.
 // HashLiteral is a valid expression node within the abstract-syntax tree.
@@ -496,3 +906,32 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// RecordLiteral is used to construct an ast.Node for record literals (record{x: 1, y: 2}).
+// Fields holds the names of the fields in the order they are written and Values the matching expressions.
+type RecordLiteral struct {
+	Token  token.Token // the 'record' token
+	Fields []*Identifier
+	Values []Expression
+}
+
+// expressionNode is implemented to allow RecordLiteral to be served as an Expression
+func (rl *RecordLiteral) expressionNode() {}
+
+// TokenLiteral returns the literal value (Token.Literal) for the record keyword
+func (rl *RecordLiteral) TokenLiteral() string { return rl.Token.Literal }
+
+// String builds the entire RecordLiteral as a string, its fields in the order they are written
+func (rl *RecordLiteral) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for i, field := range rl.Fields {
+		fields = append(fields, field.String()+": "+rl.Values[i].String())
+	}
+	out.WriteString("record{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
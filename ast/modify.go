@@ -0,0 +1,182 @@
+package ast
+
+import "fmt"
+
+// ModifierFunc rewrites a single node. It returns the node that replaces it,
+// which may be the node itself, or an error that stops the modification.
+type ModifierFunc func(Node) (Node, error)
+
+// Modify walks the tree rooted at node depth-first and replaces every node with the result of the modifier.
+// The children of a node are modified before the node itself, so the modifier always sees rewritten children.
+// A replacement must fit the place of the node it replaces: expressions are replaced by expressions,
+// statements by statements and block statements by block statements.
+func Modify(node Node, modifier ModifierFunc) (Node, error) {
+	var err error
+
+	switch node := node.(type) {
+	case *Program:
+		err = modifyStatements(node.Statements, modifier)
+	case *BlockStatement:
+		err = modifyStatements(node.Statements, modifier)
+	case *ExpressionStatement:
+		node.Expression, err = modifyExpression(node.Expression, modifier)
+	case *LetStatement:
+		node.Value, err = modifyExpression(node.Value, modifier)
+	case *MultiLetStatement:
+		err = modifyExpressions(node.Values, modifier)
+	case *MultiAssignStatement:
+		err = modifyExpressions(node.Values, modifier)
+	case *ReturnStatement:
+		node.ReturnValue, err = modifyExpression(node.ReturnValue, modifier)
+	case *PrefixExpression:
+		node.Right, err = modifyExpression(node.Right, modifier)
+	case *InfixExpression:
+		if node.Left, err = modifyExpression(node.Left, modifier); err == nil {
+			node.Right, err = modifyExpression(node.Right, modifier)
+		}
+	case *IfExpression:
+		if node.Condition, err = modifyExpression(node.Condition, modifier); err != nil {
+			break
+		}
+		if node.Consequence, err = modifyBlock(node.Consequence, modifier); err != nil {
+			break
+		}
+		if node.Alternative != nil {
+			node.Alternative, err = modifyBlock(node.Alternative, modifier)
+		}
+	case *ConditionalExpression:
+		if node.Condition, err = modifyExpression(node.Condition, modifier); err != nil {
+			break
+		}
+		if node.Consequence, err = modifyExpression(node.Consequence, modifier); err == nil {
+			node.Alternative, err = modifyExpression(node.Alternative, modifier)
+		}
+	case *SwitchExpression:
+		if node.Subject, err = modifyExpression(node.Subject, modifier); err != nil {
+			break
+		}
+		for _, c := range node.Cases {
+			if err = modifyExpressions(c.Values, modifier); err != nil {
+				break
+			}
+			if c.Body, err = modifyBlock(c.Body, modifier); err != nil {
+				break
+			}
+		}
+		if err == nil && node.Default != nil {
+			node.Default, err = modifyBlock(node.Default, modifier)
+		}
+	case *WhileExpression:
+		if node.Condition, err = modifyExpression(node.Condition, modifier); err == nil {
+			node.Body, err = modifyBlock(node.Body, modifier)
+		}
+	case *AssignExpression:
+		node.Value, err = modifyExpression(node.Value, modifier)
+	case *ForExpression:
+		if node.Iterable, err = modifyExpression(node.Iterable, modifier); err == nil {
+			node.Body, err = modifyBlock(node.Body, modifier)
+		}
+	case *FunctionLiteral:
+		if err = modifyExpressions(node.Defaults, modifier); err == nil {
+			node.Body, err = modifyBlock(node.Body, modifier)
+		}
+	case *CallExpression:
+		if node.Function, err = modifyExpression(node.Function, modifier); err == nil {
+			err = modifyExpressions(node.Arguments, modifier)
+		}
+	case *ArrayLiteral:
+		err = modifyExpressions(node.Elements, modifier)
+	case *RecordLiteral:
+		err = modifyExpressions(node.Values, modifier)
+	case *MemberExpression:
+		node.Object, err = modifyExpression(node.Object, modifier)
+	case *IndexExpression:
+		if node.Left, err = modifyExpression(node.Left, modifier); err == nil {
+			node.Index, err = modifyExpression(node.Index, modifier)
+		}
+	case *SliceExpression:
+		node.Left, err = modifyExpression(node.Left, modifier)
+		if err == nil && node.Low != nil {
+			node.Low, err = modifyExpression(node.Low, modifier)
+		}
+		if err == nil && node.High != nil {
+			node.High, err = modifyExpression(node.High, modifier)
+		}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for key, value := range node.Pairs {
+			newKey, err := modifyExpression(key, modifier)
+			if err != nil {
+				return nil, err
+			}
+			newValue, err := modifyExpression(value, modifier)
+			if err != nil {
+				return nil, err
+			}
+			pairs[newKey] = newValue
+		}
+		node.Pairs = pairs
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return modifier(node)
+}
+
+// modifyStatements modifies every statement of the list in place
+func modifyStatements(statements []Statement, modifier ModifierFunc) error {
+	for i, statement := range statements {
+		modified, err := Modify(statement, modifier)
+		if err != nil {
+			return err
+		}
+		replacement, ok := modified.(Statement)
+		if !ok {
+			return fmt.Errorf("cannot replace statement %q with %T", statement.String(), modified)
+		}
+		statements[i] = replacement
+	}
+	return nil
+}
+
+// modifyExpressions modifies every expression of the list in place
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) error {
+	for i, expression := range expressions {
+		modified, err := modifyExpression(expression, modifier)
+		if err != nil {
+			return err
+		}
+		expressions[i] = modified
+	}
+	return nil
+}
+
+// modifyExpression modifies an expression, which may be missing in incomplete nodes
+func modifyExpression(expression Expression, modifier ModifierFunc) (Expression, error) {
+	if expression == nil {
+		return nil, nil
+	}
+	modified, err := Modify(expression, modifier)
+	if err != nil {
+		return nil, err
+	}
+	replacement, ok := modified.(Expression)
+	if !ok {
+		return nil, fmt.Errorf("cannot replace expression %q with %T", expression.String(), modified)
+	}
+	return replacement, nil
+}
+
+// modifyBlock modifies a block statement, the replacement must be a block statement as well
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) (*BlockStatement, error) {
+	modified, err := Modify(block, modifier)
+	if err != nil {
+		return nil, err
+	}
+	replacement, ok := modified.(*BlockStatement)
+	if !ok {
+		return nil, fmt.Errorf("cannot replace block statement with %T", modified)
+	}
+	return replacement, nil
+}
@@ -0,0 +1,75 @@
+package ast
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/token"
+)
+
+// integer creates an integer literal with the token the parser would give it
+func integer(value int64) *IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal}, Value: value}
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return integer(1) }
+	two := func() Expression { return integer(2) }
+
+	turnOneIntoTwo := func(node Node) (Node, error) {
+		literal, ok := node.(*IntegerLiteral)
+		if !ok || literal.Value != 1 {
+			return node, nil
+		}
+		return integer(2), nil
+	}
+
+	tests := []struct {
+		input    Node
+		expected string
+	}{
+		{one(), "2"},
+		{&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}}, "2"},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, "(2 + 2)"},
+		{&PrefixExpression{Operator: "-", Right: one()}, "(-2)"},
+		{&IndexExpression{Left: one(), Index: one()}, "(2[2])"},
+		{&IfExpression{
+			Condition:   one(),
+			Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+		}, "if2 2else 2"},
+		{&ConditionalExpression{Condition: one(), Consequence: one(), Alternative: two()}, "(2 ? 2 : 2)"},
+		{&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}, ReturnValue: one()}, "return 2;"},
+		{&LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: &Identifier{Value: "x"}, Value: one()}, "let x = 2;"},
+		{&FunctionLiteral{Token: token.Token{Type: token.FUNCTION, Literal: "fn"}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}}, "fn() 2"},
+		{&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), two()}}, "f(2, 2)"},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, "[2, 2]"},
+		{&HashLiteral{Pairs: map[Expression]Expression{one(): one()}}, "{2:2}"},
+	}
+
+	for _, tt := range tests {
+		modified, err := Modify(tt.input, turnOneIntoTwo)
+		if err != nil {
+			t.Fatalf("modify failed: %s", err)
+		}
+		if modified.String() != tt.expected {
+			t.Errorf("wrong modification. want=%q, got=%q", tt.expected, modified.String())
+		}
+	}
+}
+
+func TestModifyInvalidReplacement(t *testing.T) {
+	toStatement := func(node Node) (Node, error) {
+		if _, ok := node.(*IntegerLiteral); ok {
+			return &ReturnStatement{ReturnValue: &Identifier{Value: "x"}}, nil
+		}
+		return node, nil
+	}
+
+	_, err := Modify(&ArrayLiteral{Elements: []Expression{integer(1)}}, toStatement)
+	expected := `cannot replace expression "1" with *ast.ReturnStatement`
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%v", expected, err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 )
 
 var engine = flag.String("engine", "vm", "use 'vm' or 'eval'")
+var arena = flag.Bool("arena", false, "allocate integer and string results from an arena in the vm")
 
 var input = `
 let fibonacci = fn(x) {
@@ -49,6 +50,7 @@ func main() {
 		}
 
 		machine := vm.New(comp.Bytecode())
+		machine.SetArena(*arena)
 
 		start := time.Now()
 
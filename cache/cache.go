@@ -0,0 +1,194 @@
+// Package cache keeps compiled programs on disk, so running a large script again skips parsing, checking and
+// compiling it. An entry is keyed by the hash of the source and of everything else the compiled program depends
+// on: the settings of the compilation, the instruction set and the interpreter binary itself, so a cached program
+// is never run by an interpreter it was not compiled for. The run and watch commands share the cache.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// maxAge is how long an entry stays in the cache without being loaded, Store removes the older entries
+const maxAge = 30 * 24 * time.Hour
+
+// entryExtension is the extension of the files holding the entries, other files in the directory are left alone
+const entryExtension = ".json"
+
+// Cache is a directory of compiled programs. Loading a missing, outdated or damaged entry is a miss,
+// so the cache can be emptied at any time by removing the directory.
+type Cache struct {
+	// Dir is the directory holding the entries, it is created by the first Store
+	Dir string
+	// Salt is part of every key, entries stored with another salt are never loaded
+	Salt string
+}
+
+// Program is a compiled program and the warnings found while compiling it, they are reported again
+// when the program is loaded from the cache
+type Program struct {
+	Bytecode    *compiler.Bytecode
+	Diagnostics diagnostic.Diagnostics
+}
+
+// entry is the JSON document an entry is saved as, its constants use the object encoding.
+// The encoding does not keep the source maps of compiled functions, they are saved by the index of the constant.
+type entry struct {
+	Version        int                     `json:"version"`
+	InstructionSet string                  `json:"instructionSet"`
+	Instructions   []byte                  `json:"instructions"`
+	SourceMap      code.SourceMap          `json:"sourceMap"`
+	Constants      []*object.EncodedObject `json:"constants"`
+	SourceMaps     map[int]code.SourceMap  `json:"sourceMaps,omitempty"`
+	Diagnostics    diagnostic.Diagnostics  `json:"diagnostics"`
+}
+
+// Default returns the cache in the monkey directory of the user's cache directory, ~/.cache/monkey on Linux.
+// Its salt identifies the running executable by its path, size and modification time, so installing another
+// build of the interpreter starts from an empty cache. It fails when the system has no cache directory.
+func Default() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	salt := ""
+	if executable, err := os.Executable(); err == nil {
+		if info, err := os.Stat(executable); err == nil {
+			salt = fmt.Sprintf("%s %d %d", executable, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return &Cache{Dir: filepath.Join(dir, "monkey"), Salt: salt}, nil
+}
+
+// Key returns the key of the program compiled from the source with the settings, which describe every option
+// changing the compiled program, like "strict=true"
+func (c *Cache) Key(source string, settings ...string) string {
+	hash := sha256.New()
+	// every part is preceded by its length, so moving text from one part to the next changes the key
+	parts := append([]string{fmt.Sprint(object.EncodingVersion), code.InstructionSet, c.Salt, source}, settings...)
+	for _, part := range parts {
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Load returns the program stored with the key. base holds the constants the compilation started from,
+// the same as when the program was stored, the constants of the program are appended to a copy of them.
+// It reports false when there is no usable entry for the key.
+func (c *Cache) Load(key string, base []object.Object) (*Program, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var saved entry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, false
+	}
+	if object.CheckCompatible(saved.Version, saved.InstructionSet) != nil {
+		return nil, false
+	}
+
+	constants := append([]object.Object{}, base...)
+	// constants are decoded in order, so closures can only refer to the functions before them
+	decoder := &object.Decoder{Constants: constants}
+	for i, encoded := range saved.Constants {
+		decoded, err := decoder.Decode(encoded)
+		if err != nil {
+			return nil, false
+		}
+		if fn, ok := decoded.(*object.CompiledFunction); ok {
+			fn.SourceMap = saved.SourceMaps[i]
+		}
+		// like the constants of a compiler, the decoded ones must not change
+		constants = append(constants, object.Freeze(decoded))
+		decoder.Constants = constants
+	}
+
+	// an entry that is loaded is kept, Store only removes the entries that have not been loaded for a while
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	bytecode := &compiler.Bytecode{Instructions: saved.Instructions, Constants: constants, SourceMap: saved.SourceMap}
+	return &Program{Bytecode: bytecode, Diagnostics: saved.Diagnostics}, true
+}
+
+// Store saves the program with the key, base holds the constants the compilation started from, they are not saved.
+// The entry is written to a temporary file first, so a program being loaded at the same time is never half written.
+func (c *Cache) Store(key string, program *Program, base []object.Object) error {
+	saved := entry{
+		Version:        object.EncodingVersion,
+		InstructionSet: code.InstructionSet,
+		Instructions:   program.Bytecode.Instructions,
+		SourceMap:      program.Bytecode.SourceMap,
+		Constants:      []*object.EncodedObject{},
+		SourceMaps:     map[int]code.SourceMap{},
+		Diagnostics:    program.Diagnostics,
+	}
+	encoder := &object.Encoder{Constants: program.Bytecode.Constants}
+	for i, constant := range program.Bytecode.Constants[len(base):] {
+		encoded, err := encoder.Encode(constant)
+		if err != nil {
+			return err
+		}
+		saved.Constants = append(saved.Constants, encoded)
+		if fn, ok := constant.(*object.CompiledFunction); ok && len(fn.SourceMap) != 0 {
+			saved.SourceMaps[i] = fn.SourceMap
+		}
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	c.prune()
+	tmp, err := os.CreateTemp(c.Dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// prune removes the entries that were not stored or loaded during the last maxAge
+func (c *Cache) prune() {
+	files, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), entryExtension) {
+			continue
+		}
+		if info, err := file.Info(); err == nil && time.Since(info.ModTime()) > maxAge {
+			os.Remove(filepath.Join(c.Dir, file.Name()))
+		}
+	}
+}
+
+// path returns the path of the file holding the entry with the key
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+entryExtension)
+}
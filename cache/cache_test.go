@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// compile compiles the source starting from the base constants
+func compile(t *testing.T, source string, base []object.Object) *compiler.Bytecode {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	comp := compiler.NewWithState(compiler.NewSymbolTable(), base)
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compilation failed: %s", err)
+	}
+	return comp.Bytecode()
+}
+
+func TestStoreLoad(t *testing.T) {
+	c := &Cache{Dir: filepath.Join(t.TempDir(), "monkey")}
+	base := []object.Object{&object.Integer{Value: 1}}
+	source := "let add = fn(a) { fn(b) { a + b } };\nadd(2)(3) + \"a\";"
+	bytecode := compile(t, source, base)
+	warning := diagnostic.Diagnostic{Severity: diagnostic.Warning, Line: 1, Column: 5, Code: "W101", Message: "a warning"}
+	key := c.Key(source)
+
+	if _, ok := c.Load(key, base); ok {
+		t.Fatalf("expected a miss before the program is stored")
+	}
+	if err := c.Store(key, &Program{Bytecode: bytecode, Diagnostics: diagnostic.Diagnostics{warning}}, base); err != nil {
+		t.Fatalf("store failed: %s", err)
+	}
+	loaded, ok := c.Load(key, base)
+	if !ok {
+		t.Fatalf("expected a hit after the program is stored")
+	}
+
+	if len(loaded.Diagnostics) != 1 || loaded.Diagnostics[0] != warning {
+		t.Errorf("wrong diagnostics. got=%v", loaded.Diagnostics)
+	}
+	if len(loaded.Bytecode.Constants) != len(bytecode.Constants) || loaded.Bytecode.Constants[0] != base[0] {
+		t.Fatalf("wrong constants. want=%v, got=%v", bytecode.Constants, loaded.Bytecode.Constants)
+	}
+	if loaded.Bytecode.Instructions.String() != bytecode.Instructions.String() {
+		t.Errorf("wrong instructions. want=%s, got=%s", bytecode.Instructions, loaded.Bytecode.Instructions)
+	}
+
+	// the loaded program reports errors at the same positions, in the inner function too
+	machine := vm.New(loaded.Bytecode)
+	err := machine.Run()
+	expected := vm.New(bytecode).Run()
+	if err == nil || expected == nil || err.Error() != expected.Error() {
+		t.Errorf("wrong error of the loaded program. want=%v, got=%v", expected, err)
+	}
+	for i, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			loadedFn := loaded.Bytecode.Constants[i].(*object.CompiledFunction)
+			if len(loadedFn.SourceMap) != len(fn.SourceMap) {
+				t.Errorf("wrong source map of constant %d. want=%v, got=%v", i, fn.SourceMap, loadedFn.SourceMap)
+			}
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	keys := []string{
+		c.Key("1"),
+		c.Key("2"),
+		c.Key("1", "strict=true"),
+		c.Key("1", "strict=false"),
+		c.Key("1strict=true"),
+		(&Cache{Dir: c.Dir, Salt: "another build"}).Key("1"),
+	}
+	seen := map[string]bool{}
+	for i, key := range keys {
+		if seen[key] {
+			t.Errorf("key %d is not unique: %s", i, key)
+		}
+		seen[key] = true
+	}
+	if c.Key("1", "strict=true") != keys[2] {
+		t.Errorf("the key of the same source and settings changed")
+	}
+}
+
+func TestLoadDamaged(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	key := c.Key("1")
+	if err := os.WriteFile(c.path(key), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("writing the entry failed: %s", err)
+	}
+	if _, ok := c.Load(key, nil); ok {
+		t.Errorf("expected a miss for a damaged entry")
+	}
+
+	outdated := `{"version": 0, "instructionSet": "", "instructions": "", "constants": []}`
+	if err := os.WriteFile(c.path(key), []byte(outdated), 0644); err != nil {
+		t.Fatalf("writing the entry failed: %s", err)
+	}
+	if _, ok := c.Load(key, nil); ok {
+		t.Errorf("expected a miss for an entry of another version")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	old, recent := c.path(c.Key("old")), c.path(c.Key("recent"))
+	other := filepath.Join(c.Dir, "notes.txt")
+	for _, path := range []string{old, recent, other} {
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatalf("writing %s failed: %s", path, err)
+		}
+	}
+	long := time.Now().Add(-2 * maxAge)
+	for _, path := range []string{old, other} {
+		if err := os.Chtimes(path, long, long); err != nil {
+			t.Fatalf("setting the time of %s failed: %s", path, err)
+		}
+	}
+
+	if err := c.Store(c.Key("new"), &Program{Bytecode: compile(t, "1", nil)}, nil); err != nil {
+		t.Fatalf("store failed: %s", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expected the old entry to be removed")
+	}
+	for _, path := range []string{recent, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept: %s", path, err)
+		}
+	}
+}
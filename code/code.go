@@ -25,6 +25,8 @@ func (ins Instructions) String() string {
 		def, err := Lookup(ins[i])
 		if err != nil {
 			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			// skip the unknown byte, its operands cannot be decoded
+			i++
 			continue
 		}
 
@@ -106,8 +108,33 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+	OpGetModule
+	OpIndexKey
+	OpFloorDiv
+	OpRecord
+	OpGetField
+	OpJumpIfNull
+	OpIterate
+	OpIterNext
+	OpGreaterOrEqual
+	OpJumpIfFalsy
+	OpJumpIfTruthy
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpBitNot
+	OpSlice
+	OpDup
+	OpJumpIfArgument
+	OpCallNamed
 )
 
+// OpCustomStart is the first opcode available to embedders. The opcodes below it are reserved for the core
+// instruction set, which can keep growing without colliding with the opcodes registered with Register.
+const OpCustomStart Opcode = 128
+
 // Definition helps us understand Opcode defintions. A Definition
 // gives more insight on an Opcode's human-readable name (Name) and its operands.
 // OperandWidths records the unique bytewidth that each operand may have
@@ -157,6 +184,55 @@ var definitions = map[Opcode]*Definition{
 	be transferred to the about-to-be-created closure **/
 	OpGetFree:        {"OpGetFree", []int{1}},       //OpGetFree has one one-byte operand. The operand refers to the unique index of a free variable.
 	OpCurrentClosure: {"OpCurrentClosure", []int{}}, //OpCurrentClosure does not have any operands
+	OpGetModule:      {"OpGetModule", []int{1}},     //OpGetModule has one one-byte operand. The operand refers to the unique index of the module in object.Modules.
+	OpIndexKey:       {"OpIndexKey", []int{2}},      //OpIndexKey has one two-byte operand. The operand refers to the index of the precomputed string key in the constants pool.
+	OpFloorDiv:       {"OpFloorDiv", []int{}},       //OpFloorDiv does not have any operands
+	OpRecord:         {"OpRecord", []int{2, 2}},     //OpRecord has two two-byte operands. The first refers to the index of the record shape in the constants pool, the second is the number of fields.
+	OpGetField:       {"OpGetField", []int{2, 2}},   //OpGetField has two two-byte operands. The first refers to the index of the record shape in the constants pool, the second is the offset of the field in records of that shape.
+	OpJumpIfNull:     {"OpJumpIfNull", []int{2}},    //OpJumpIfNull has one two-byte operand. The operand refers to where in the instructions to jump to when the top of the stack is null, which stays on the stack.
+	OpIterate:        {"OpIterate", []int{}},        //OpIterate does not have any operands
+	OpIterNext:       {"OpIterNext", []int{2, 1}},   /**OpIterNext has two operands. The first operand is two-bytes wide and refers to where in the instructions to jump to
+	once the cursor on top of the stack is exhausted. The second operand is one-byte wide and is the number of values pushed for the next element,
+	1 for the value alone and 2 for the key followed by the value **/
+	OpGreaterOrEqual: {"OpGreaterOrEqual", []int{}},     //OpGreaterOrEqual does not have any operands
+	OpJumpIfFalsy:    {"OpJumpIfFalsy", []int{2}},       //OpJumpIfFalsy has one two-byte operand. The operand refers to where in the instructions to jump to when the top of the stack is falsy, which stays on the stack.
+	OpJumpIfTruthy:   {"OpJumpIfTruthy", []int{2}},      //OpJumpIfTruthy has one two-byte operand. The operand refers to where in the instructions to jump to when the top of the stack is truthy, which stays on the stack.
+	OpBitAnd:         {"OpBitAnd", []int{}},             //OpBitAnd does not have any operands
+	OpBitOr:          {"OpBitOr", []int{}},              //OpBitOr does not have any operands
+	OpBitXor:         {"OpBitXor", []int{}},             //OpBitXor does not have any operands
+	OpShiftLeft:      {"OpShiftLeft", []int{}},          //OpShiftLeft does not have any operands
+	OpShiftRight:     {"OpShiftRight", []int{}},         //OpShiftRight does not have any operands
+	OpBitNot:         {"OpBitNot", []int{}},             //OpBitNot does not have any operands
+	OpSlice:          {"OpSlice", []int{}},              //OpSlice does not have any operands, the bounds of the slice are on the stack
+	OpDup:            {"OpDup", []int{}},                //OpDup does not have any operands, it pushes the element on top of the stack again
+	OpJumpIfArgument: {"OpJumpIfArgument", []int{2, 1}}, /**OpJumpIfArgument has two operands. The first operand is two-bytes wide and refers to where in the instructions to jump to
+	when the call passed an argument for the parameter, skipping the evaluation of its default value. The second operand is one-byte wide and is the index of the parameter **/
+	OpCallNamed: {"OpCallNamed", []int{1, 2}}, /**OpCallNamed has two operands. The first operand is one-byte wide and refers to the number of arguments of the calling function,
+	the named arguments being the last ones. The second operand is two-bytes wide and refers to the index of the array holding their names in the constants pool **/
+}
+
+// customStackEffects records the stack effect of the opcodes added with Register
+var customStackEffects = map[Opcode]int{}
+
+// Register adds a custom opcode with its definition to the instruction set, claiming a byte from OpCustomStart upwards.
+// stackEffect is the net number of elements the instruction leaves on the stack, as reported by StackEffect.
+// Opcodes are meant to be registered during initialization, before any instructions are made or executed.
+func Register(op Opcode, def *Definition, stackEffect int) error {
+	if op < OpCustomStart {
+		return fmt.Errorf("opcode %d is reserved, custom opcodes start at %d", op, OpCustomStart)
+	}
+	if existing, ok := definitions[op]; ok {
+		return fmt.Errorf("opcode %d is already defined as %s", op, existing.Name)
+	}
+	for _, width := range def.OperandWidths {
+		if width != 1 && width != 2 {
+			return fmt.Errorf("unsupported operand width %d for %s", width, def.Name)
+		}
+	}
+
+	definitions[op] = def
+	customStackEffects[op] = stackEffect
+	return nil
 }
 
 // Lookup simply finds the definition of the provided op (Opcode)
@@ -243,3 +319,109 @@ func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
 func ReadUint16(ins Instructions) uint16 {
 	return binary.BigEndian.Uint16(ins)
 }
+
+// StackEffect reports the net number of elements the instruction with the given
+// op and operands leaves on the stack once the VM has executed it.
+// A negative value means the instruction consumes more elements than it pushes.
+func StackEffect(op Opcode, operands []int) int {
+	switch op {
+	case OpConstant, OpTrue, OpFalse, OpNull, OpGetGlobal, OpGetLocal,
+		OpGetBuiltin, OpGetFree, OpCurrentClosure, OpGetModule, OpDup:
+		return 1
+	case OpAdd, OpSub, OpMul, OpDiv, OpFloorDiv, OpEqual, OpNotEqual, OpGreaterThan, OpGreaterOrEqual,
+		OpBitAnd, OpBitOr, OpBitXor, OpShiftLeft, OpShiftRight,
+		OpPop, OpJumpNotTruthy, OpSetGlobal, OpSetLocal, OpIndex, OpReturnValue:
+		return -1
+	case OpSlice:
+		// the sequence and both bounds are replaced by the slice
+		return -2
+	case OpArray, OpHash:
+		// all elements are replaced by the single collection
+		return 1 - operands[0]
+	case OpRecord:
+		// the values of the fields are replaced by the record
+		return 1 - operands[1]
+	case OpCall, OpCallNamed:
+		// the function and its arguments are replaced by the return value
+		return -operands[0]
+	case OpClosure:
+		// the free variables are replaced by the closure
+		return 1 - operands[1]
+	case OpIterNext:
+		// the values of the next element are pushed on top of the cursor, the cursor stays when it is exhausted
+		return operands[1]
+	default:
+		return customStackEffects[op]
+	}
+}
+
+// MaxStackDepth computes the maximum number of elements the given instructions
+// can hold on the stack at the same time. It follows every path through the
+// instructions, including both destinations of conditional jumps, starting from an empty stack.
+func MaxStackDepth(ins Instructions) int {
+	_, max := stackDepths(ins)
+	return max
+}
+
+// StackDepth returns the number of elements on the stack right before the instruction at pos executes,
+// pos can be the end of the instructions. It reports false when no path through the instructions reaches pos.
+func StackDepth(ins Instructions, pos int) (int, bool) {
+	depths, _ := stackDepths(ins)
+	depth, ok := depths[pos]
+	return depth, ok
+}
+
+// stackDepths records the stack depth before executing the instruction at each position reached
+// from the start of the instructions, and the maximum depth, see MaxStackDepth
+func stackDepths(ins Instructions) (map[int]int, int) {
+	depths := make(map[int]int)
+	worklist := []int{0}
+	depths[0] = 0
+	max := 0
+
+	for len(worklist) > 0 {
+		pos := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+
+		for pos < len(ins) {
+			def, err := Lookup(ins[pos])
+			if err != nil {
+				return depths, max
+			}
+
+			op := Opcode(ins[pos])
+			operands, read := ReadOperands(def, ins[pos+1:])
+			depth := depths[pos] + StackEffect(op, operands)
+			if depth > max {
+				max = depth
+			}
+
+			// record the depth at the jump destination so that path is followed as well
+			if op == OpJump || op == OpJumpNotTruthy || op == OpJumpIfNull || op == OpJumpIfFalsy ||
+				op == OpJumpIfTruthy || op == OpIterNext || op == OpJumpIfArgument {
+				jumpDepth := depth
+				// an exhausted cursor jumps without pushing any values
+				if op == OpIterNext {
+					jumpDepth = depths[pos]
+				}
+				if _, ok := depths[operands[0]]; !ok {
+					depths[operands[0]] = jumpDepth
+					worklist = append(worklist, operands[0])
+				}
+			}
+
+			// the instructions after an unconditional jump or a return are reached through other paths
+			if op == OpJump || op == OpReturnValue || op == OpReturn {
+				break
+			}
+
+			pos += 1 + read
+			if _, ok := depths[pos]; ok {
+				break
+			}
+			depths[pos] = depth
+		}
+	}
+
+	return depths, max
+}
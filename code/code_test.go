@@ -112,3 +112,228 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestMaxStackDepth(t *testing.T) {
+	tests := []struct {
+		instructions []Instructions
+		expected     int
+	}{
+		{
+			[]Instructions{
+				Make(OpConstant, 0),
+				Make(OpConstant, 1),
+				Make(OpAdd),
+				Make(OpPop),
+			},
+			2,
+		},
+		{
+			// if (true) { 10 } else { 20 }; both branches leave one element on the stack
+			[]Instructions{
+				Make(OpTrue),
+				Make(OpJumpNotTruthy, 10),
+				Make(OpConstant, 0),
+				Make(OpJump, 13),
+				Make(OpConstant, 1),
+				Make(OpPop),
+			},
+			1,
+		},
+		{
+			// switch (1) { case 2 { 10 } }; the subject stays below the copy compared with the case
+			[]Instructions{
+				Make(OpConstant, 0),
+				Make(OpDup),
+				Make(OpConstant, 1),
+				Make(OpEqual),
+				Make(OpJumpNotTruthy, 18),
+				Make(OpPop),
+				Make(OpConstant, 2),
+				Make(OpJump, 20),
+				Make(OpPop),
+				Make(OpNull),
+				Make(OpPop),
+			},
+			3,
+		},
+		{
+			[]Instructions{
+				Make(OpGetGlobal, 0),
+				Make(OpConstant, 0),
+				Make(OpConstant, 1),
+				Make(OpConstant, 2),
+				Make(OpCall, 3),
+				Make(OpConstant, 3),
+				Make(OpArray, 2),
+				Make(OpReturnValue),
+			},
+			4,
+		},
+	}
+
+	for _, tt := range tests {
+		concatted := Instructions{}
+		for _, ins := range tt.instructions {
+			concatted = append(concatted, ins...)
+		}
+
+		depth := MaxStackDepth(concatted)
+		if depth != tt.expected {
+			t.Errorf("wrong max stack depth. want=%d, got=%d", tt.expected, depth)
+		}
+	}
+}
+
+func TestStackDepth(t *testing.T) {
+	// 1 + if (true) { return 2; } else { 3 }; no path reaches the jump following the return
+	ins := Instructions{}
+	for _, i := range []Instructions{
+		Make(OpConstant, 0),
+		Make(OpTrue),
+		Make(OpJumpNotTruthy, 14),
+		Make(OpConstant, 1),
+		Make(OpReturnValue),
+		Make(OpJump, 17),
+		Make(OpConstant, 2),
+		Make(OpAdd),
+	} {
+		ins = append(ins, i...)
+	}
+
+	tests := []struct {
+		pos      int
+		expected int
+		ok       bool
+	}{
+		{0, 0, true},
+		{4, 2, true},
+		{10, 2, true},
+		{11, 0, false},
+		{14, 1, true},
+		{17, 2, true},
+		{18, 1, true},
+	}
+	for _, tt := range tests {
+		depth, ok := StackDepth(ins, tt.pos)
+		if depth != tt.expected || ok != tt.ok {
+			t.Errorf("wrong stack depth at %d. want=%d %t, got=%d %t", tt.pos, tt.expected, tt.ok, depth, ok)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	op := OpCustomStart + 10
+	err := Register(op, &Definition{"OpVecScale", []int{1}}, -1)
+	if err != nil {
+		t.Fatalf("register failed: %s", err)
+	}
+
+	if string(Make(op, 3)) != string([]byte{byte(op), 3}) {
+		t.Errorf("wrong instruction for custom opcode: %v", Make(op, 3))
+	}
+	if ins := Instructions(Make(op, 3)).String(); ins != "0000 OpVecScale 3\n" {
+		t.Errorf("wrong instructions string: %q", ins)
+	}
+	if effect := StackEffect(op, []int{3}); effect != -1 {
+		t.Errorf("wrong stack effect. want=-1, got=%d", effect)
+	}
+	// the instruction set only covers the core opcodes, bytecode of the core stays compatible
+	if set := instructionSetHash(); set != InstructionSet {
+		t.Errorf("custom opcode changed the instruction set. want=%s, got=%s", InstructionSet, set)
+	}
+	// adding a core opcode makes the bytecode compiled before incompatible
+	definitions[OpCustomStart-1] = &Definition{"OpNext", []int{}}
+	set := instructionSetHash()
+	delete(definitions, OpCustomStart-1)
+	if set == InstructionSet {
+		t.Errorf("new core opcode did not change the instruction set %s", set)
+	}
+
+	tests := []struct {
+		op       Opcode
+		def      *Definition
+		expected string
+	}{
+		{OpAdd, &Definition{"OpMine", []int{}}, "opcode 1 is reserved, custom opcodes start at 128"},
+		{op, &Definition{"OpMine", []int{}}, "opcode 138 is already defined as OpVecScale"},
+		{op + 1, &Definition{"OpWide", []int{4}}, "unsupported operand width 4 for OpWide"},
+	}
+
+	for _, tt := range tests {
+		err := Register(tt.op, tt.def, 0)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestInstructionSetBuiltins(t *testing.T) {
+	defer SetBuiltins(builtinNames)
+
+	SetBuiltins([]string{"len", "puts"})
+	set := InstructionSet
+	// bytecode addresses built-in functions by index, moving one makes it incompatible
+	SetBuiltins([]string{"puts", "len"})
+	if InstructionSet == set {
+		t.Errorf("reordered built-in functions did not change the instruction set %s", set)
+	}
+	SetBuiltins([]string{"len", "puts"})
+	if InstructionSet != set {
+		t.Errorf("same built-in functions changed the instruction set. want=%s, got=%s", set, InstructionSet)
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	var sm SourceMap
+	sm = sm.Add(0, 1, 1)
+	// an instruction at the same position adds nothing
+	sm = sm.Add(3, 1, 1)
+	sm = sm.Add(4, 1, 5)
+	sm = sm.Add(7, 2, 1)
+
+	lookups := []struct {
+		offset int
+		line   int
+		column int
+	}{
+		{0, 1, 1},
+		{3, 1, 1},
+		// an operand belongs to the position of its instruction
+		{5, 1, 5},
+		{9, 2, 1},
+	}
+	for _, tt := range lookups {
+		line, column, ok := sm.Lookup(tt.offset)
+		if !ok || line != tt.line || column != tt.column {
+			t.Errorf("wrong position at %d. want=%d:%d, got=%d:%d %t", tt.offset, tt.line, tt.column, line, column, ok)
+		}
+	}
+	if _, _, ok := (SourceMap{{Offset: 2, Line: 1, Column: 1}}).Lookup(1); ok {
+		t.Errorf("expected no position before the first entry")
+	}
+
+	if truncated := sm.Truncate(7); len(truncated) != 2 || truncated[1].Offset != 4 {
+		t.Errorf("wrong truncated map. got=%v", truncated)
+	}
+
+	// removing the instruction at 4 moves the position of the next one back, the removed position is dropped
+	removed := sm.Remove(4, 3)
+	expected := SourceMap{{0, 1, 1}, {4, 2, 1}}
+	if len(removed) != len(expected) {
+		t.Fatalf("wrong map after removing. want=%v, got=%v", expected, removed)
+	}
+	for i := range expected {
+		if removed[i] != expected[i] {
+			t.Errorf("wrong position %d after removing. want=%v, got=%v", i, expected[i], removed[i])
+		}
+	}
+
+	// the position of a removed instruction carries over to the next instruction when it has none
+	removed = sm.Remove(4, 1)
+	if line, column, _ := removed.Lookup(4); line != 1 || column != 5 {
+		t.Errorf("wrong carried position. got=%d:%d", line, column)
+	}
+	if line, _, _ := removed.Lookup(6); line != 2 {
+		t.Errorf("wrong shifted position. got=%v", removed)
+	}
+}
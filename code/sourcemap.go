@@ -0,0 +1,74 @@
+package code
+
+import "sort"
+
+// SourcePosition records that the instructions from Offset on were compiled from the source at Line and Column,
+// until the offset of the next position of a SourceMap
+type SourcePosition struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// SourceMap maps the offsets of instructions back to the source they were compiled from, so errors and the
+// debugger can point at the code being executed. Its positions are in ascending order of their offsets and
+// an instruction belongs to the last position at or before its offset, so only the instructions starting
+// a new position need an entry. Passes rewriting instructions keep the map in sync with Remove and Truncate.
+type SourceMap []SourcePosition
+
+// Add records that the instruction at offset was compiled from the source at line and column.
+// Offsets must be added in ascending order, an instruction with the same position as the one before it adds nothing.
+func (sm SourceMap) Add(offset, line, column int) SourceMap {
+	if n := len(sm); n > 0 {
+		last := sm[n-1]
+		if last.Line == line && last.Column == column {
+			return sm
+		}
+		if last.Offset == offset {
+			sm[n-1] = SourcePosition{Offset: offset, Line: line, Column: column}
+			return sm
+		}
+	}
+	return append(sm, SourcePosition{Offset: offset, Line: line, Column: column})
+}
+
+// Lookup returns the line and the column of the source the instruction containing offset was compiled from,
+// offset can point at an operand of the instruction. It reports false when the instruction has no position.
+func (sm SourceMap) Lookup(offset int) (int, int, bool) {
+	i := sort.Search(len(sm), func(i int) bool { return sm[i].Offset > offset })
+	if i == 0 {
+		return 0, 0, false
+	}
+	return sm[i-1].Line, sm[i-1].Column, true
+}
+
+// Truncate drops the positions of the instructions at or after offset, when the instructions are shortened to offset
+func (sm SourceMap) Truncate(offset int) SourceMap {
+	i := sort.Search(len(sm), func(i int) bool { return sm[i].Offset >= offset })
+	return sm[:i]
+}
+
+// Remove updates the map for instructions where the width bytes at offset were removed.
+// The positions of the instructions after them move back by width, the instruction now at offset
+// keeps its own position, or takes the position of the removed instructions when it had none.
+func (sm SourceMap) Remove(offset, width int) SourceMap {
+	removed := make(SourceMap, 0, len(sm))
+	for _, position := range sm {
+		switch {
+		case position.Offset < offset:
+			removed = append(removed, position)
+		case position.Offset < offset+width:
+			// the position of a removed instruction carries over to the next instruction, unless it has its own
+			position.Offset = offset
+			removed = removed.Add(position.Offset, position.Line, position.Column)
+		default:
+			position.Offset -= width
+			if n := len(removed); n > 0 && removed[n-1].Offset == position.Offset {
+				removed[n-1] = position
+				continue
+			}
+			removed = append(removed, position)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,39 @@
+package code
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// InstructionSet identifies the core opcodes: it is a hash of the byte, the name and the operand widths of every
+// opcode below OpCustomStart, and of the names of the built-in functions OpGetBuiltin refers to, in the order of
+// their indices. Adding, removing or changing an opcode or moving a built-in function changes it, so serialized
+// bytecode records the instruction set it was compiled for and is refused by a version running other instructions,
+// instead of being executed with the wrong meaning. The opcodes registered by embedders are not part of it.
+var InstructionSet = instructionSetHash()
+
+// builtinNames are the names of the built-in functions of the core, set by SetBuiltins
+var builtinNames []string
+
+// SetBuiltins records the names of the built-in functions of the core, ordered by their OpGetBuiltin operand,
+// in InstructionSet. The object package calls it with its list, the code package cannot import it.
+func SetBuiltins(names []string) {
+	builtinNames = names
+	InstructionSet = instructionSetHash()
+}
+
+// instructionSetHash computes InstructionSet from the definitions of the core opcodes and the built-in functions
+func instructionSetHash() string {
+	h := fnv.New32a()
+	for op := Opcode(0); op < OpCustomStart; op++ {
+		def, ok := definitions[op]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(h, "%d %s %v\n", op, def.Name, def.OperandWidths)
+	}
+	for i, name := range builtinNames {
+		fmt.Fprintf(h, "builtin %d %s\n", i, name)
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
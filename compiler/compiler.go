@@ -3,12 +3,24 @@ package compiler
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/yourfavoritedev/golang-interpreter/ast"
 	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
 	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+	"github.com/yourfavoritedev/golang-interpreter/token"
 )
 
+// MaxConstants is the upper limit on the number of constants in the constant pool,
+// since the OpConstant and OpClosure operands referencing them are two bytes wide.
+const MaxConstants = 65536
+
+// MaxCollectionElements is the upper limit on the number of elements in an array literal and
+// the combined number of keys and values in a hash literal, since OpArray and OpHash operands are two bytes wide.
+const MaxCollectionElements = 65535
+
 // Compiler will create Bytecode for the VM to execute.
 // The Compiler will leverage the evaluated abstract-syntax-tree to
 // compile the necessary attributes for Bytecode. This includes the
@@ -21,8 +33,43 @@ type Compiler struct {
 	symbolTable *SymbolTable
 	scopes      []CompilationScope
 	scopeIndex  int
+	// stringConstants maps the value of every string constant in the constants pool to its index,
+	// letting identical string literals share a single constant instead of growing the pool.
+	stringConstants map[string]int
+	// keyConstants maps the value of every precomputed string key in the constants pool to its index
+	keyConstants map[string]int
+	// shapeConstants maps the field names of every record shape in the constants pool, joined by commas, to its index
+	shapeConstants map[string]int
+	// symbolConstants maps the name of every symbol in the constants pool to its index
+	symbolConstants map[string]int
+	// internedStrings counts how many string literals reused an existing constant during compilation.
+	internedStrings int
+	// debugInfo retains the source code of function literals in their compiled functions.
+	debugInfo bool
+	// diagnostics holds the warnings reported during compilation, they do not stop the compilation.
+	diagnostics diagnostic.Diagnostics
+	// extensions get the chance to compile every node before the compiler does, in the order they were added.
+	extensions []Extension
+	// position is the token of the innermost node being compiled that has a position in the source,
+	// every emitted instruction is mapped back to it in the source map of its scope.
+	position token.Token
+	// use tells how the value of the next expression statement is used, the nodes holding statements
+	// set it before compiling every statement, see compileStatements.
+	use valueUse
 }
 
+// valueUse tells how the value of an expression statement is used, which decides whether it is popped
+type valueUse int
+
+const (
+	// discardValue pops the value of the statement, a loop does not even push the null it evaluates to
+	discardValue valueUse = iota
+	// resultValue pops the value of the last statement of a program, the VM reports it as the last popped element
+	resultValue
+	// keepValue leaves the value on the stack, it is the value of a block or the return value of a function
+	keepValue
+)
+
 // EmittedInstruction is the struct that describes an instruction that was
 // emitted by the compiler
 type EmittedInstruction struct {
@@ -40,6 +87,10 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	// sourceMap maps the instructions back to the source they were compiled from
+	sourceMap code.SourceMap
+	// loops holds the loops of the scope being compiled, the innermost one last
+	loops []*loop
 }
 
 // New simply initializes a new Compiler
@@ -55,12 +106,19 @@ func New() *Compiler {
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
+	for i, m := range object.Modules {
+		symbolTable.DefineModule(i, m.Name)
+	}
 
 	return &Compiler{
-		constants:   []object.Object{},
-		symbolTable: symbolTable,
-		scopes:      []CompilationScope{mainScope},
-		scopeIndex:  0,
+		constants:       []object.Object{},
+		symbolTable:     symbolTable,
+		scopes:          []CompilationScope{mainScope},
+		scopeIndex:      0,
+		stringConstants: make(map[string]int),
+		keyConstants:    make(map[string]int),
+		shapeConstants:  make(map[string]int),
+		symbolConstants: make(map[string]int),
 	}
 }
 
@@ -75,30 +133,59 @@ func (c *Compiler) currentInstructions() code.Instructions {
 // to be added to the constants pool, and builds the necessary instructions
 // for the VM to execute.
 func (c *Compiler) Compile(node ast.Node) error {
+	// the instructions of the node are mapped to its position, the instructions of its children to theirs
+	if tok, ok := sourceToken(node); ok {
+		outer := c.position
+		c.position = tok
+		defer func() { c.position = outer }()
+	}
+
+	for _, ext := range c.extensions {
+		handled, err := ext.Compile(c, node)
+		if handled || err != nil {
+			return err
+		}
+	}
+
 	switch node := node.(type) {
 	// our starting point
 	case *ast.Program:
-		for _, s := range node.Statements {
-			err := c.Compile(s)
-			if err != nil {
-				return err
-			}
+		err := c.compileStatements(node.Statements, resultValue)
+		if err != nil {
+			return err
 		}
 
-	// compile expression statement - work our way down to the expression
+	// compile expression statement - work our way down to the expression.
+	// The value is popped unless the block or function it ends uses it, so no OpPop has to be removed again.
 	case *ast.ExpressionStatement:
+		use := c.use
+		c.use = discardValue
 		err := c.Compile(node.Expression)
 		if err != nil {
 			return err
 		}
-		c.emit(code.OpPop)
+
+		switch {
+		case use == keepValue:
+		// a loop pushes null right before the OpPop would pop it, neither is needed. The jumps leaving
+		// the loop target the OpNull, they now target the instruction following the loop instead.
+		case use == discardValue && isLoop(node.Expression) && c.lastInstructionIs(code.OpNull):
+			c.removeLastInstruction()
+		default:
+			c.emit(code.OpPop)
+		}
 
 	// compile infix expression - work our way down to the literals
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogical(node)
+		}
+
 		// when a "<" operator is encountered, we want to simply apply the
 		// comparison in reverse to keep logic succinct. To the VM, its as if the
 		// "<" operator does not exist, all it should worry about is the OpGreaterThan instructions.
-		if node.Operator == "<" {
+		// "<=" is reversed the same way into an OpGreaterOrEqual instruction.
+		if node.Operator == "<" || node.Operator == "<=" {
 			err := c.Compile(node.Right)
 			if err != nil {
 				return err
@@ -109,7 +196,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return err
 			}
 
-			c.emit(code.OpGreaterThan)
+			if node.Operator == "<=" {
+				c.emit(code.OpGreaterOrEqual)
+			} else {
+				c.emit(code.OpGreaterThan)
+			}
 			return nil
 		}
 
@@ -132,12 +223,26 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMul)
 		case "/":
 			c.emit(code.OpDiv)
+		case "//":
+			c.emit(code.OpFloorDiv)
 		case ">":
 			c.emit(code.OpGreaterThan)
+		case ">=":
+			c.emit(code.OpGreaterOrEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
 			c.emit(code.OpNotEqual)
+		case "&":
+			c.emit(code.OpBitAnd)
+		case "|":
+			c.emit(code.OpBitOr)
+		case "^":
+			c.emit(code.OpBitXor)
+		case "<<":
+			c.emit(code.OpShiftLeft)
+		case ">>":
+			c.emit(code.OpShiftRight)
 		default:
 			return fmt.Errorf("unknown operator %s", node.Operator)
 		}
@@ -153,6 +258,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpMinus)
 		case "!":
 			c.emit(code.OpBang)
+		case "~":
+			c.emit(code.OpBitNot)
 		default:
 			return fmt.Errorf("unknown operator: %s", node.Operator)
 		}
@@ -176,20 +283,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// Recall that code.OpJumpNotTruthy has a single operand that indicates where in the instructions to jump to if the condition is not truthy
 		jumpNotTruhyPos := c.emit(code.OpJumpNotTruthy, 9999)
 
-		// compile the consequence
-		err = c.Compile(node.Consequence)
+		// compile the consequence, its value stays on the stack to have a value for statements that use
+		// the if expression as an expression (let x = 5)
+		err = c.compileBlockValue(node.Consequence)
 		if err != nil {
 			return err
 		}
 
-		// The consequence is an *ast.ExpressionStatement which emits an OpPop instruction.
-		// We want to keep the constant on the stack to have a value for statements that use
-		// it as an expression (let x = 5), so we must remove the last pop instruction.
-		// OpPop instruction
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
-
 		// the code.OpJump instruction is emitted directly after emitting the consequence (almost like its part of the consequence
 		// when the consequence is executed by the VM, it knows to jump over the alternative instruction or over a OpNull instruction.
 		// when an alternative block exists - it tells us the VM it can skip over the alternative).
@@ -209,17 +309,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.Alternative == nil {
 			c.emit(code.OpNull)
 		} else {
-			// compile the alternative
-			err := c.Compile(node.Alternative)
+			// compile the alternative, same reasoning as above, its value stays on the stack
+			err := c.compileBlockValue(node.Alternative)
 			if err != nil {
 				return err
 			}
-
-			// same reasoning as above, we want to prevent the constant generated from the alternative
-			// from popping so it can be used in the future
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
 		}
 
 		// as soon as the alternative or OpNull instruction is emitted, we know exactly what to backpatch the code.OpJump operand to
@@ -229,29 +323,216 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// replace code.OpJump's operand with the new position, the position after the alternative or OpNull instruction (afterAlternativePos)
 		c.changeOperand(jumpPos, afterAlternativePos)
 
-	// compile a block statement
-	case *ast.BlockStatement:
-		for _, s := range node.Statements {
-			err := c.Compile(s)
-			if err != nil {
+	// compile a conditional expression with the jumps of an if expression, the branches are expressions
+	// that leave their value on the stack, and the alternative always exists
+	case *ast.ConditionalExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.Compile(node.Consequence); err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		if err := c.Compile(node.Alternative); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	// compile a switch expression to a chain of comparisons and jumps. The subject stays on the stack while the
+	// cases are compared, OpDup copies it for each OpEqual. A case with several values compares them like an ||,
+	// the first equal value jumps to the OpJumpNotTruthy with true on the stack. The body of the matching case,
+	// or the default body, starts by popping the subject and leaves its value on the stack.
+	case *ast.SwitchExpression:
+		if err := c.Compile(node.Subject); err != nil {
+			return err
+		}
+
+		endJumps := []int{}
+		for _, sc := range node.Cases {
+			matchJumps := []int{}
+			for i, value := range sc.Values {
+				if i > 0 {
+					c.emit(code.OpPop)
+				}
+				c.emit(code.OpDup)
+				if err := c.Compile(value); err != nil {
+					return err
+				}
+				c.emit(code.OpEqual)
+				if i < len(sc.Values)-1 {
+					matchJumps = append(matchJumps, c.emit(code.OpJumpIfTruthy, 9999))
+				}
+			}
+			for _, pos := range matchJumps {
+				c.changeOperand(pos, len(c.currentInstructions()))
+			}
+			nextCasePos := c.emit(code.OpJumpNotTruthy, 9999)
+
+			c.emit(code.OpPop)
+			if err := c.compileBlockValue(sc.Body); err != nil {
 				return err
 			}
+			endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+			c.changeOperand(nextCasePos, len(c.currentInstructions()))
+		}
+
+		c.emit(code.OpPop)
+		if node.Default == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compileBlockValue(node.Default); err != nil {
+			return err
+		}
+		for _, pos := range endJumps {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+
+	// compile a while loop. The condition is followed by an OpJumpNotTruthy leaving the loop, the body
+	// pops the values of its statements and ends with an OpJump back to the condition.
+	// After the loop, OpNull pushes the value of the while expression.
+	// A continue jumps back to the condition and a break jumps to the OpNull.
+	case *ast.WhileExpression:
+		conditionPos := len(c.currentInstructions())
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		c.enterLoop(conditionPos)
+		if err := c.Compile(node.Body); err != nil {
+			return err
+		}
+		c.emit(code.OpJump, conditionPos)
+
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.leaveLoop(len(c.currentInstructions()))
+		c.emit(code.OpNull)
+
+	// compile a for-in loop. OpIterate replaces the collection with a cursor, every iteration starts with
+	// an OpIterNext pushing the key and the value of the next element, which are bound to the names of the loop
+	// before the body runs, and the body ends with an OpJump back to the OpIterNext.
+	// Once the cursor is exhausted OpIterNext jumps past the loop, where the cursor is popped and OpNull
+	// pushes the value of the for expression. A continue jumps back to the OpIterNext and a break jumps
+	// to the OpPop popping the cursor.
+	case *ast.ForExpression:
+		if err := c.Compile(node.Iterable); err != nil {
+			return err
+		}
+		c.emit(code.OpIterate)
+
+		names := []*ast.Identifier{node.Value}
+		if node.Key != nil {
+			names = []*ast.Identifier{node.Key, node.Value}
+		}
+		c.enterLoop(len(c.currentInstructions()))
+		nextPos := c.emit(code.OpIterNext, 9999, len(names))
+		// the names of the loop are only visible in its body
+		c.symbolTable.EnterBlock()
+		symbols := make([]Symbol, len(names))
+		for i, name := range names {
+			symbols[i] = c.symbolTable.Define(name.Value)
+		}
+		// the value is on top of the key, it is bound first
+		for i := len(names) - 1; i >= 0; i-- {
+			c.bindValue(names[i], symbols[i], nil)
+		}
+
+		err := c.Compile(node.Body)
+		c.symbolTable.LeaveBlock()
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpJump, nextPos)
+
+		// OpIterNext has a second operand, so it is replaced as a whole instead of with changeOperand
+		c.replaceInstruction(nextPos, code.Make(code.OpIterNext, len(c.currentInstructions()), len(names)))
+		c.leaveLoop(len(c.currentInstructions()))
+		c.emit(code.OpPop)
+		c.emit(code.OpNull)
+
+	// compile a block statement whose value is not used, like the body of a loop.
+	// The names the block binds are only visible inside of it.
+	case *ast.BlockStatement:
+		c.symbolTable.EnterBlock()
+		err := c.compileStatements(node.Statements, discardValue)
+		c.symbolTable.LeaveBlock()
+		if err != nil {
+			return err
 		}
 
 	// compile a let statement and update the symbolTable
 	case *ast.LetStatement:
-		// define the identifier in the symbol table
-		symbol := c.symbolTable.Define(node.Name.Value)
+		c.warnShadowing(node.Name)
+
+		// define the identifier in the symbol table. The functions of the value can refer to the name it is bound to,
+		// like a recursive function passed to memoize, so the name is defined first. A value reading the name itself,
+		// outside of its functions, is compiled first so it reads the binding the name had before, like in the
+		// evaluator: `let x = x + 1` and `let x = [x, fn() { 1 }]` shadow x.
+		recursive := !readsName(node.Value, node.Name.Value)
+		var symbol Symbol
+		if recursive {
+			symbol = c.define(node.Name.Value, node.Const)
+		}
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
 		}
-		// the symbol for that identifier now has an index, which we use as an operand
-		// to construct the instruction
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
-		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
+		if !recursive {
+			symbol = c.define(node.Name.Value, node.Const)
+		}
+		c.bindValue(node.Name, symbol, node.Value)
+
+	// compile a let statement binding several names. The values are pushed on the stack first, where they stay
+	// as temporaries while the names are defined, then they are popped into the bindings from the last to the first.
+	// Defining the names after compiling every value lets the values read the previous bindings of the names.
+	case *ast.MultiLetStatement:
+		for _, value := range node.Values {
+			if err := c.Compile(value); err != nil {
+				return err
+			}
+		}
+		symbols := make([]Symbol, len(node.Names))
+		for i, name := range node.Names {
+			c.warnShadowing(name)
+			symbols[i] = c.define(name.Value, node.Const)
+		}
+		for i := len(node.Names) - 1; i >= 0; i-- {
+			c.bindValue(node.Names[i], symbols[i], node.Values[i])
+		}
+
+	// compile an assignment. The name must already be bound, the value replaces its binding with the
+	// same OpSetGlobal or OpSetLocal instruction a let statement emits and is loaded again as the value
+	// of the assignment expression.
+	case *ast.AssignExpression:
+		symbol, err := c.assignTarget(node.Name.Value)
+		if err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.assignValue(node.Name, symbol, node.Value)
+		c.loadSymbol(symbol)
+
+	// compile an assignment to several names. Like a let statement binding several names, the values are pushed
+	// on the stack first and popped into the bindings from the last to the first, so they read the previous bindings.
+	case *ast.MultiAssignStatement:
+		symbols := make([]Symbol, len(node.Names))
+		for i, name := range node.Names {
+			symbol, err := c.assignTarget(name.Value)
+			if err != nil {
+				return err
+			}
+			symbols[i] = symbol
+		}
+		for _, value := range node.Values {
+			if err := c.Compile(value); err != nil {
+				return err
+			}
+		}
+		for i := len(node.Names) - 1; i >= 0; i-- {
+			c.assignValue(node.Names[i], symbols[i], node.Values[i])
 		}
 
 	// compile an identifier, it should look into the symbolTable to validate that the identifier has
@@ -260,7 +541,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// grab the identiier from the symbol table
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable: %s", node.Value)
+			return c.undefined(node.Value, node.Token)
 		}
 
 		// construct an instruction with the symbol's index as the operand
@@ -269,6 +550,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 	// compile an array literal, it should cosntruct an OpArray instruction with the operand
 	// being the number of elements in the array.
 	case *ast.ArrayLiteral:
+		// the number of elements is encoded in a two-byte wide operand
+		if len(node.Elements) > MaxCollectionElements {
+			return fmt.Errorf("array literal at line %d, column %d has %d elements, exceeding the limit of %d",
+				node.Token.Line, node.Token.Column, len(node.Elements), MaxCollectionElements)
+		}
+
 		// compile all elements in the array. The elements themselves are expressions.
 		for _, e := range node.Elements {
 			err := c.Compile(e)
@@ -282,6 +569,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 	// compile a hash literal, it should construct an OpHash instruction with the operand
 	// being the combined number of keys and values in the hash
 	case *ast.HashLiteral:
+		// the combined number of keys and values is encoded in a two-byte wide operand
+		if len(node.Pairs)*2 > MaxCollectionElements {
+			return fmt.Errorf("hash literal at line %d, column %d has %d pairs, exceeding the limit of %d",
+				node.Token.Line, node.Token.Column, len(node.Pairs), MaxCollectionElements/2)
+		}
+
 		keys := []ast.Expression{}
 		// get keys from hash
 		for k := range node.Pairs {
@@ -297,7 +590,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// build Opcode instructions for keys and their values which should lead to a series
 		// of OpConstants if the hash is not empty
 		for _, k := range keys {
-			err := c.Compile(k)
+			// string literal keys are hashed at compile time
+			var err error
+			if str, ok := k.(*ast.StringLiteral); ok {
+				err = c.compileStringKey(code.OpConstant, str.Value)
+			} else {
+				err = c.Compile(k)
+			}
 			if err != nil {
 				return err
 			}
@@ -309,20 +608,113 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpHash, len(node.Pairs)*2)
 
+	// compile a record literal, the values of its fields followed by an OpRecord instruction
+	// referring to the shape of the record in the constants pool
+	case *ast.RecordLiteral:
+		if len(node.Fields) > MaxCollectionElements {
+			return fmt.Errorf("record literal at line %d, column %d has %d fields, exceeding the limit of %d",
+				node.Token.Line, node.Token.Column, len(node.Fields), MaxCollectionElements)
+		}
+
+		for _, value := range node.Values {
+			err := c.Compile(value)
+			if err != nil {
+				return err
+			}
+		}
+
+		idx, err := c.recordShape(recordFields(node))
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpRecord, idx, len(node.Fields))
+
 	// compile an index expression. it should simply compile the object being indexed and then the index itself,
 	// then finally emit an OpIndex instruction.
+	// A safe navigation emits an OpJumpIfNull after the object, which jumps over the index operation
+	// and leaves the null on the stack as the value of the whole expression.
 	case *ast.IndexExpression:
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
 		}
 
-		err = c.Compile(node.Index)
+		if !node.Optional {
+			return c.compileIndex(node.Index)
+		}
+
+		// Emit an 'OpJumpIfNull' with a bogus operand value which we will resolve through backpatching
+		jumpIfNullPos := c.emit(code.OpJumpIfNull, 9999)
+		err = c.compileIndex(node.Index)
+		if err != nil {
+			return err
+		}
+		c.changeOperand(jumpIfNullPos, len(c.currentInstructions()))
+
+	// compile a slice expression. The sequence is compiled first, then both bounds with an omitted bound compiled
+	// into an OpNull, and finally an OpSlice instruction. A safe navigation jumps over the bounds and the slice like
+	// it does for an index operation.
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
 		if err != nil {
 			return err
 		}
 
-		c.emit(code.OpIndex)
+		jumpIfNullPos := -1
+		if node.Optional {
+			// Emit an 'OpJumpIfNull' with a bogus operand value which we will resolve through backpatching
+			jumpIfNullPos = c.emit(code.OpJumpIfNull, 9999)
+		}
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+			if err := c.Compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+		if node.Optional {
+			c.changeOperand(jumpIfNullPos, len(c.currentInstructions()))
+		}
+
+	// compile a member access. Members of built-in modules are resolved at compile time and loaded
+	// like any other built-in function, any other member access is compiled into an index operation.
+	case *ast.MemberExpression:
+		if ident, ok := node.Object.(*ast.Identifier); ok {
+			symbol, ok := c.symbolTable.Resolve(ident.Value)
+			if ok && symbol.Scope == ModuleScope {
+				module := object.Modules[symbol.Index]
+				index, ok := module.Members[node.Property.Value]
+				if !ok {
+					return fmt.Errorf("undefined member %s in module %s", node.Property.Value, module.Name)
+				}
+				c.emit(code.OpGetBuiltin, index)
+				return nil
+			}
+
+			// the shape of the record is known, so the offset of the field is too
+			if ok && symbol.Shape != nil {
+				offset, ok := symbol.Shape.Offset(node.Property.Value)
+				if !ok {
+					return fmt.Errorf("record %s has no field %s", ident.Value, node.Property.Value)
+				}
+				c.loadSymbol(symbol)
+				c.emit(code.OpGetField, c.shapeConstants[strings.Join(symbol.Shape.Fields, ",")], offset)
+				return nil
+			}
+		}
+
+		err := c.Compile(node.Object)
+		if err != nil {
+			return err
+		}
+
+		err = c.compileStringKey(code.OpIndexKey, node.Property.Value)
+		if err != nil {
+			return fmt.Errorf("%s in member %s", err, node.Property.Value)
+		}
 
 	// compile a function literal. It should create a unique scope for the function and compile its body into
 	// instructions, use those instructions to build a object.CompiledFunction, push that object to the
@@ -340,16 +732,28 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.symbolTable.Define(p.Value)
 		}
 
-		err := c.Compile(node.Body)
+		// the default values are evaluated first, from left to right. Each one is preceded by an OpJumpIfArgument
+		// skipping it when the call passed an argument for the parameter, otherwise the value is compiled and stored
+		// in the parameter's local binding, which the VM left null. The variadic parameter never has a default,
+		// the VM binds it to an array of the remaining arguments.
+		for i := node.Required(); i < node.Fixed(); i++ {
+			jumpPos := c.emit(code.OpJumpIfArgument, 9999, i)
+			if err := c.Compile(node.Default(i)); err != nil {
+				return err
+			}
+			c.emit(code.OpSetLocal, i)
+			// OpJumpIfArgument has a second operand, so it is replaced as a whole instead of with changeOperand
+			c.replaceInstruction(jumpPos, code.Make(code.OpJumpIfArgument, len(c.currentInstructions()), i))
+		}
+
+		// the value of the last expression statement of the body is not popped, when the VM executes the body,
+		// it is returned with an OpReturnValue instruction instead.
+		err := c.compileStatements(node.Body.Statements, keepValue)
 		if err != nil {
 			return err
 		}
-
-		// remove OpPop instruction (if there is one) from the function body's instructions, when the VM executes the body,
-		// we don't want to pop the returnable value from the stack. Instead we want to replace OpPop
-		// with the desired OpReturnValue instruction so the VM can actually return the value.
-		if c.lastInstructionIs(code.OpPop) {
-			c.replaceLastPopWithReturn()
+		if endsWithExpression(node.Body) {
+			c.emit(code.OpReturnValue)
 		}
 
 		// when the function does not have a returnable value and therefore not an OpReturnValue instruction,
@@ -360,6 +764,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		locals := c.symbolTable.Locals()
+		sourceMap := c.scopes[c.scopeIndex].sourceMap
 		instructions := c.leaveScope()
 
 		// Before leaving the inner-function's scope, we stored its free-variables in freeSymbols.
@@ -376,10 +782,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			NumDefaults:   len(node.Defaults),
+			Variadic:      node.Variadic,
+			MaxStack:      code.MaxStackDepth(instructions),
+			Name:          node.Name,
+			Parameters:    parameterNames(node),
+			Doc:           node.Doc,
+			SourceMap:     sourceMap,
+		}
+		if c.debugInfo {
+			// the name the literal is bound to is not part of its source
+			literal := *node
+			literal.Name = ""
+			compiledFn.Source = literal.String()
+			compiledFn.Locals = locals
 		}
 
 		// add the compiledFn into the constants pool and use its index as the first operand
-		fnIndex := c.addConstant(compiledFn)
+		fnIndex, err := c.addConstant(compiledFn)
+		if err != nil {
+			return fmt.Errorf("%s in function literal %s", err, functionLabel(node))
+		}
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
 
 	// compile a return statement, it should emit an OpReturnValue instruction
@@ -391,8 +814,19 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpReturnValue)
 
-	// compile a call expression
+	// compile a break or continue statement to a jump, see compileLoopControl
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		return c.compileLoopControl(node.(ast.Statement))
+
+	// compile a call expression, a call of a pure built-in function with constant arguments is folded to its result
 	case *ast.CallExpression:
+		if value, ok := c.foldCall(node); ok {
+			if err := c.emitConstant(value); err != nil {
+				return fmt.Errorf("%s in call %s", err, node.String())
+			}
+			return nil
+		}
+
 		err := c.Compile(node.Function)
 		if err != nil {
 			return err
@@ -406,17 +840,53 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
-		c.emit(code.OpCall, len(node.Arguments))
+		if len(node.Names) == 0 {
+			c.emit(code.OpCall, len(node.Arguments))
+			return nil
+		}
+		// the names of the named arguments are kept in the constants pool, the VM matches them with the parameters
+		names := make([]object.Object, len(node.Names))
+		for i, name := range node.Names {
+			names[i] = &object.String{Value: name.Value}
+		}
+		constIndex, err := c.addConstant(&object.Array{Elements: names})
+		if err != nil {
+			return fmt.Errorf("%s in call %s", err, node.String())
+		}
+		c.emit(code.OpCallNamed, len(node.Arguments), constIndex)
 
 	// compile an integer literal
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
-		c.emit(code.OpConstant, c.addConstant(integer))
+		constIndex, err := c.addConstant(integer)
+		if err != nil {
+			return fmt.Errorf("%s in integer literal %s", err, node.String())
+		}
+		c.emit(code.OpConstant, constIndex)
+
+	// compile a float literal
+	case *ast.FloatLiteral:
+		constIndex, err := c.addConstant(&object.Float{Value: node.Value})
+		if err != nil {
+			return fmt.Errorf("%s in float literal %s", err, node.String())
+		}
+		c.emit(code.OpConstant, constIndex)
 
 	// compile a string literal
 	case *ast.StringLiteral:
-		s := &object.String{Value: node.Value}
-		c.emit(code.OpConstant, c.addConstant(s))
+		constIndex, err := c.addStringConstant(node.Value)
+		if err != nil {
+			return fmt.Errorf("%s in string literal %q", err, node.Value)
+		}
+		c.emit(code.OpConstant, constIndex)
+
+	// compile a symbol literal, the interned symbol is stored in the constant pool once per compilation
+	case *ast.SymbolLiteral:
+		constIndex, err := c.addSymbolConstant(node.Value)
+		if err != nil {
+			return fmt.Errorf("%s in symbol literal %s", err, node.String())
+		}
+		c.emit(code.OpConstant, constIndex)
 
 	// compile a boolean literal
 	case *ast.Boolean:
@@ -430,12 +900,249 @@ func (c *Compiler) Compile(node ast.Node) error {
 	return nil
 }
 
+// warnShadowing warns when the binding of a let statement hides a built-in function or module, it is still allowed
+func (c *Compiler) warnShadowing(name *ast.Identifier) {
+	if symbol, ok := c.symbolTable.Resolve(name.Value); ok {
+		switch symbol.Scope {
+		case BuiltinScope:
+			c.warn(name.Token, "W101", "let %s shadows the built-in function %s", name.Value, name.Value)
+		case ModuleScope:
+			c.warn(name.Token, "W102", "let %s shadows the built-in module %s", name.Value, name.Value)
+		}
+	}
+}
+
+// undefined returns the error for a use of a name that is not defined, located at the token.
+// It suggests the name in scope the undefined one is probably a misspelling of.
+func (c *Compiler) undefined(name string, tok token.Token) error {
+	return &report.Error{
+		Kind:       report.Compile,
+		Message:    fmt.Sprintf("undefined variable: %s", name),
+		Line:       tok.Line,
+		Column:     tok.Column,
+		Suggestion: report.Suggest(name, c.symbolTable.Names()),
+	}
+}
+
+// assignTarget resolves the binding an assignment to the name replaces. Only the bindings of the current
+// function and the global bindings can be assigned, a closure holds a copy of the bindings of the functions
+// enclosing it, so assigning to them would not be seen outside of the closure. Constants cannot be assigned at all.
+func (c *Compiler) assignTarget(name string) (Symbol, error) {
+	symbol, ok := c.symbolTable.Resolve(name)
+	// inside a function bound by a let statement, its name refers to the function itself,
+	// assigning to it replaces the binding made by the let statement
+	if ok && symbol.Scope == FunctionScope {
+		symbol, ok = c.symbolTable.Outer.Resolve(name)
+		if ok && symbol.Scope == LocalScope {
+			symbol.Scope = FreeScope
+		}
+	}
+	if !ok {
+		return symbol, c.undefined(name, c.position)
+	}
+	if symbol.Constant {
+		return symbol, fmt.Errorf("cannot assign to constant %s", name)
+	}
+
+	switch symbol.Scope {
+	case GlobalScope, LocalScope:
+		return symbol, nil
+	case BuiltinScope:
+		return symbol, fmt.Errorf("cannot assign to built-in function %s", name)
+	case ModuleScope:
+		return symbol, fmt.Errorf("cannot assign to built-in module %s", name)
+	default:
+		return symbol, fmt.Errorf("cannot assign to %s, it is bound by an enclosing function", name)
+	}
+}
+
+// assignValue emits the instruction popping the value on top of the stack into the symbol an assignment replaces
+func (c *Compiler) assignValue(name *ast.Identifier, symbol Symbol, value ast.Expression) {
+	// field accesses compiled from now on only rely on the shape of the record when the new value has it too
+	var shape *object.RecordShape
+	if record, ok := value.(*ast.RecordLiteral); ok {
+		shape = c.constants[c.shapeConstants[strings.Join(recordFields(record), ",")]].(*object.RecordShape)
+	}
+	if symbol.Shape != shape {
+		c.symbolTable.SetShape(name.Value, shape)
+	}
+
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else {
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+}
+
+// define defines the name bound by a let statement, or by a const statement when constant is set
+func (c *Compiler) define(name string, constant bool) Symbol {
+	if constant {
+		return c.symbolTable.DefineConstant(name)
+	}
+	return c.symbolTable.Define(name)
+}
+
+// bindValue emits the instruction popping the value on top of the stack into the symbol defined for the name
+func (c *Compiler) bindValue(name *ast.Identifier, symbol Symbol, value ast.Expression) {
+	// the fields of a record bound by name are accessed by their offset
+	if record, ok := value.(*ast.RecordLiteral); ok {
+		shape := c.constants[c.shapeConstants[strings.Join(recordFields(record), ",")]].(*object.RecordShape)
+		c.symbolTable.SetShape(name.Value, shape)
+	}
+	// the symbol for that identifier now has an index, which we use as an operand
+	// to construct the instruction
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else {
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+}
+
 // addConstant will add the given obj to the end of the constant pool and
 // will return the index of that obj, that index can be used as an identifier
-// to find obj in the pool.
-func (c *Compiler) addConstant(obj object.Object) int {
-	c.constants = append(c.constants, obj)
-	return len(c.constants) - 1
+// to find obj in the pool. Constants are referenced by two-byte wide operands,
+// so an error is returned once the pool cannot address any more constants.
+// The constant is frozen, the pool is shared by every VM running the bytecode (see object.Freeze).
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) >= MaxConstants {
+		return 0, fmt.Errorf("too many constants: the constant pool is limited to %d entries", MaxConstants)
+	}
+
+	c.constants = append(c.constants, object.Freeze(obj))
+	return len(c.constants) - 1, nil
+}
+
+// addStringConstant interns the given string value in the constant pool. If an identical
+// string constant already exists, its index is reused, otherwise a new object.String is added.
+func (c *Compiler) addStringConstant(value string) (int, error) {
+	if idx, ok := c.stringConstants[value]; ok {
+		c.internedStrings++
+		return idx, nil
+	}
+
+	idx, err := c.addConstant(&object.String{Value: value})
+	if err != nil {
+		return 0, err
+	}
+	c.stringConstants[value] = idx
+	return idx, nil
+}
+
+// compileStringKey emits op with the index of the string key in the constant pool as its operand.
+// The key's HashKey is computed once here, identical keys share a single constant.
+func (c *Compiler) compileStringKey(op code.Opcode, value string) error {
+	idx, ok := c.keyConstants[value]
+	if !ok {
+		var err error
+		idx, err = c.addConstant(object.NewStringKey(value))
+		if err != nil {
+			return err
+		}
+		c.keyConstants[value] = idx
+	}
+	c.emit(op, idx)
+	return nil
+}
+
+// compileLogical compiles a short-circuiting && or || operator. The left operand is followed by
+// an OpJumpIfFalsy (for &&) or an OpJumpIfTruthy (for ||) which jumps over the right operand and leaves
+// the left value on the stack as the value of the whole expression. Otherwise the left value is popped
+// and the value of the right operand is the value of the expression.
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	op := code.OpJumpIfFalsy
+	if node.Operator == "||" {
+		op = code.OpJumpIfTruthy
+	}
+	// Emit the jump with a bogus operand value which we will resolve through backpatching
+	jumpPos := c.emit(op, 9999)
+	c.emit(code.OpPop)
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileIndex compiles the index of an index expression whose object is already on the stack and emits the index operation.
+// Indexing with a string literal uses the key hashed at compile time.
+func (c *Compiler) compileIndex(index ast.Expression) error {
+	if str, ok := index.(*ast.StringLiteral); ok {
+		return c.compileStringKey(code.OpIndexKey, str.Value)
+	}
+
+	err := c.Compile(index)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpIndex)
+	return nil
+}
+
+// addSymbolConstant returns the index of the interned symbol with the given name in the constants pool,
+// adding it the first time, so every literal of the same symbol shares a constant
+func (c *Compiler) addSymbolConstant(name string) (int, error) {
+	if idx, ok := c.symbolConstants[name]; ok {
+		return idx, nil
+	}
+	idx, err := c.addConstant(object.Intern(name))
+	if err != nil {
+		return 0, err
+	}
+	c.symbolConstants[name] = idx
+	return idx, nil
+}
+
+// recordShape returns the index of the record shape with the given fields in the constants pool,
+// adding it the first time, so every record literal with the same fields shares a shape
+func (c *Compiler) recordShape(fields []string) (int, error) {
+	key := strings.Join(fields, ",")
+	if idx, ok := c.shapeConstants[key]; ok {
+		return idx, nil
+	}
+	idx, err := c.addConstant(&object.RecordShape{Fields: fields})
+	if err != nil {
+		return 0, err
+	}
+	c.shapeConstants[key] = idx
+	return idx, nil
+}
+
+// recordFields returns the names of the fields of a record literal
+func recordFields(rl *ast.RecordLiteral) []string {
+	fields := make([]string, len(rl.Fields))
+	for i, field := range rl.Fields {
+		fields[i] = field.Value
+	}
+	return fields
+}
+
+// InternedStrings returns the number of string literals that reused an
+// existing constant instead of adding a new one to the constant pool.
+func (c *Compiler) InternedStrings() int {
+	return c.internedStrings
+}
+
+// Diagnostics returns the warnings reported while compiling, errors are returned by Compile instead
+func (c *Compiler) Diagnostics() diagnostic.Diagnostics {
+	return c.diagnostics
+}
+
+// warn records a warning about the given token
+func (c *Compiler) warn(tok token.Token, code, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, diagnostic.New(diagnostic.Warning, tok, code, format, a...))
+}
+
+// SetDebugInfo enables or disables retaining debug information in compiled functions.
+// With debug information, the source code of every function literal is kept for the `source` built-in function.
+func (c *Compiler) SetDebugInfo(enabled bool) {
+	c.debugInfo = enabled
 }
 
 // emit generates an instruction for the compiler using the given params
@@ -444,6 +1151,10 @@ func (c *Compiler) addConstant(obj object.Object) int {
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	ins := code.Make(op, operands...)
 	pos := c.addInstruction(ins)
+	if c.position.Line > 0 {
+		scope := &c.scopes[c.scopeIndex]
+		scope.sourceMap = scope.sourceMap.Add(pos, c.position.Line, c.position.Column)
+	}
 
 	c.setLastInstruction(op, pos)
 
@@ -480,9 +1191,9 @@ func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
 	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
 }
 
-// removeLastPop is used to remove an OpPop instruction from the compiler,
-// shortening the instructions to everything up until the OpPop instruction.
-func (c *Compiler) removeLastPop() {
+// removeLastInstruction is used to remove the last emitted instruction from the compiler,
+// shortening the instructions to everything up until that instruction.
+func (c *Compiler) removeLastInstruction() {
 	last := c.scopes[c.scopeIndex].lastInstruction
 	previous := c.scopes[c.scopeIndex].previousInstruction
 
@@ -491,6 +1202,7 @@ func (c *Compiler) removeLastPop() {
 
 	c.scopes[c.scopeIndex].instructions = new
 	c.scopes[c.scopeIndex].lastInstruction = previous
+	c.scopes[c.scopeIndex].sourceMap = c.scopes[c.scopeIndex].sourceMap.Truncate(last.Position)
 }
 
 // replaceInstruction will replace an instruction starting at the absolute offset (pos)
@@ -503,12 +1215,84 @@ func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
 	}
 }
 
-// replaceLastPopWithReturn will replace an OpPop instruction with an OpReturnValue instruction
-func (c *Compiler) replaceLastPopWithReturn() {
-	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
-	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+// compileStatements compiles the statements of a program or a block. The value of every expression statement
+// is discarded, except the value of the last statement, which is used as given.
+func (c *Compiler) compileStatements(statements []ast.Statement, last valueUse) error {
+	defer func() { c.use = discardValue }()
+	for i, s := range statements {
+		c.use = discardValue
+		if i == len(statements)-1 {
+			c.use = last
+		}
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+// compileBlockValue compiles a block whose value is used, the value of its last expression statement.
+// A block ending with another statement, or an empty block, has the value null.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	// like the block statements of loops, the names the block binds are only visible inside of it
+	c.symbolTable.EnterBlock()
+	err := c.compileStatements(block.Statements, keepValue)
+	c.symbolTable.LeaveBlock()
+	if err != nil {
+		return err
+	}
+	// a block ending with a return statement never gets to push a value
+	if !endsWithExpression(block) && !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// endsWithExpression reports whether the last statement of the block is an expression statement
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+// readsName reports whether evaluating the expression reads the name, outside of the bodies and the default values
+// of the function literals it contains, which only read it once the functions are called
+func readsName(expression ast.Expression, name string) bool {
+	// the modifiers return every node unchanged, so they only inspect the tree. The children of a node are
+	// visited before the node, so the identifiers of the functions are collected first.
+	deferred := make(map[*ast.Identifier]bool)
+	ast.Modify(expression, func(node ast.Node) (ast.Node, error) {
+		if fn, ok := node.(*ast.FunctionLiteral); ok {
+			ast.Modify(fn, func(node ast.Node) (ast.Node, error) {
+				if ident, ok := node.(*ast.Identifier); ok {
+					deferred[ident] = true
+				}
+				return node, nil
+			})
+		}
+		return node, nil
+	})
+
+	found := false
+	ast.Modify(expression, func(node ast.Node) (ast.Node, error) {
+		if ident, ok := node.(*ast.Identifier); ok && ident.Value == name && !deferred[ident] {
+			found = true
+		}
+		return node, nil
+	})
+	return found
+}
+
+// isLoop reports whether the expression is a loop, which always evaluates to null
+func isLoop(expression ast.Expression) bool {
+	switch expression.(type) {
+	case *ast.WhileExpression, *ast.ForExpression:
+		return true
+	default:
+		return false
+	}
 }
 
 // NewWithState keeps global state in the REPL so the compiler can continue
@@ -517,6 +1301,21 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 	compiler := New()
 	compiler.symbolTable = s
 	compiler.constants = constants
+
+	// rebuild the interning tables so string constants and keys from previous compilations are shared
+	for i, constant := range constants {
+		switch constant := constant.(type) {
+		case *object.String:
+			compiler.stringConstants[constant.Value] = i
+		case *object.StringKey:
+			compiler.keyConstants[constant.String.Value] = i
+		case *object.RecordShape:
+			compiler.shapeConstants[strings.Join(constant.Fields, ",")] = i
+		case *object.Symbol:
+			compiler.symbolConstants[constant.Name] = i
+		}
+	}
+
 	return compiler
 }
 
@@ -567,6 +1366,8 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpGetLocal, s.Index)
 	case BuiltinScope:
 		c.emit(code.OpGetBuiltin, s.Index)
+	case ModuleScope:
+		c.emit(code.OpGetModule, s.Index)
 	case FreeScope:
 		c.emit(code.OpGetFree, s.Index)
 	case FunctionScope:
@@ -580,13 +1381,69 @@ func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
 	}
 }
 
 // Bytecode is the struct for the representation of bytecode that
 // will be passed to the VM. The Compiler will generate the Instructions
 // and the Constants that were evaluated.
+// SourceMap maps the Instructions back to the source, compiled functions carry their own.
+// The Constants are frozen, the same Bytecode can be run by several VMs at the same time.
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	SourceMap    code.SourceMap
+}
+
+// sourceToken returns the token locating the node in the source, for the nodes whose instructions
+// can fail or stop in the debugger: statements, operators, calls, indexing and assignments
+func sourceToken(node ast.Node) (token.Token, bool) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token, true
+	case *ast.MultiLetStatement:
+		return node.Token, true
+	case *ast.ReturnStatement:
+		return node.Token, true
+	case *ast.ExpressionStatement:
+		return node.Token, true
+	case *ast.PrefixExpression:
+		return node.Token, true
+	case *ast.InfixExpression:
+		return node.Token, true
+	case *ast.CallExpression:
+		return node.Token, true
+	case *ast.SliceExpression:
+		return node.Token, true
+	case *ast.IndexExpression:
+		return node.Token, true
+	case *ast.MemberExpression:
+		return node.Token, true
+	case *ast.AssignExpression:
+		return node.Token, true
+	case *ast.MultiAssignStatement:
+		return node.Token, true
+	case *ast.ForExpression:
+		return node.Token, true
+	}
+	return token.Token{}, false
+}
+
+// functionLabel describes a function literal in compiler errors, preferring
+// the name it is bound to over its (potentially long) source representation.
+func functionLabel(fl *ast.FunctionLiteral) string {
+	if fl.Name != "" {
+		return fl.Name
+	}
+	return fl.String()
+}
+
+// parameterNames returns the names of the parameters of a function literal
+func parameterNames(fl *ast.FunctionLiteral) []string {
+	names := make([]string, len(fl.Parameters))
+	for i, p := range fl.Parameters {
+		names[i] = p.Value
+	}
+	return names
 }
@@ -1,7 +1,11 @@
 package compiler
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/yourfavoritedev/golang-interpreter/ast"
@@ -9,6 +13,7 @@ import (
 	"github.com/yourfavoritedev/golang-interpreter/lexer"
 	"github.com/yourfavoritedev/golang-interpreter/object"
 	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/report"
 )
 
 type compilerTestCase struct {
@@ -73,6 +78,16 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 // 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpFloorDiv),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "-1",
 			expectedConstants: []interface{}{1},
@@ -82,6 +97,33 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 & 2 | 3 ^ 4 << 1 >> 2",
+			expectedConstants: []interface{}{1, 2, 3, 4, 1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitAnd),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpShiftLeft),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpShiftRight),
+				code.Make(code.OpBitXor),
+				code.Make(code.OpBitOr),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "~1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpBitNot),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -125,15 +167,16 @@ func testInstructions(
 ) error {
 	concatted := concatInstructions(expected)
 
+	// the disassembled instructions are compared so a mismatch reads as a diff of the instructions
 	if len(actual) != len(concatted) {
-		return fmt.Errorf("wrong instructions length.\nwant=%q\ngot=%q",
-			concatted, actual)
+		return fmt.Errorf("wrong instructions length.\n%s",
+			diffLines(concatted.String(), actual.String()))
 	}
 
 	for i, ins := range concatted {
 		if actual[i] != ins {
-			return fmt.Errorf("wrong instruction at %d.\nwant=%q\ngot=%q",
-				i, concatted, actual)
+			return fmt.Errorf("wrong instruction at %d.\n%s",
+				i, diffLines(concatted.String(), actual.String()))
 		}
 	}
 
@@ -153,6 +196,12 @@ func concatInstructions(s []code.Instructions) code.Instructions {
 	return out
 }
 
+// stringKey is the expected value of a precomputed string key in the constants pool
+type stringKey string
+
+// recordShape is the expected field names of a record shape in the constants pool
+type recordShape []string
+
 func testConstants(
 	t *testing.T,
 	expected []interface{},
@@ -177,6 +226,22 @@ func testConstants(
 				return fmt.Errorf("constant %d - testStringObject failed: %s",
 					i, err)
 			}
+		case stringKey:
+			key, ok := actual[i].(*object.StringKey)
+			if !ok {
+				return fmt.Errorf("constant %d - not a string key: %T", i, actual[i])
+			}
+			if key.String.Value != string(constant) || key.Key != key.String.HashKey() {
+				return fmt.Errorf("constant %d - wrong string key: %q", i, key.String.Value)
+			}
+		case recordShape:
+			shape, ok := actual[i].(*object.RecordShape)
+			if !ok {
+				return fmt.Errorf("constant %d - not a record shape: %T", i, actual[i])
+			}
+			if strings.Join(shape.Fields, ",") != strings.Join(constant, ",") {
+				return fmt.Errorf("constant %d - wrong record shape: %v", i, shape.Fields)
+			}
 		case []code.Instructions:
 			fn, ok := actual[i].(*object.CompiledFunction)
 			if !ok {
@@ -261,6 +326,26 @@ func TestBooleanExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 >= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 <= 2",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1 == 2",
 			expectedConstants: []interface{}{1, 2},
@@ -315,6 +400,377 @@ func TestBooleanExpressions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestWhileLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			while (true) { 10 }; 3333;
+			`,
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpPop),
+				// 0008
+				code.Make(code.OpJump, 0),
+				// 0011, the null of the loop is never pushed only to be popped
+				code.Make(code.OpConstant, 1),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// the value of the last statement is the result of the program, the loop pushes it
+			input:             `while (false) { }`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpFalse),
+				code.Make(code.OpJumpNotTruthy, 7),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// continue jumps back to the condition and break jumps past the loop
+			input:             `while (true) { if (false) { continue; } break; }; 1;`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 23),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 15),
+				// 0008
+				code.Make(code.OpJump, 0),
+				// 0011
+				code.Make(code.OpNull),
+				// 0012
+				code.Make(code.OpJump, 16),
+				// 0015
+				code.Make(code.OpNull),
+				// 0016
+				code.Make(code.OpPop),
+				// 0017
+				code.Make(code.OpJump, 23),
+				// 0020
+				code.Make(code.OpJump, 0),
+				// 0023
+				code.Make(code.OpConstant, 0),
+				// 0026
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestStatementValues(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// a block ending with another statement than an expression has the value null
+			input:             `if (true) { let a = 1; }`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 15),
+				// 0014
+				code.Make(code.OpNull),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `if (true) { }`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 8),
+				// 0004
+				code.Make(code.OpNull),
+				// 0005
+				code.Make(code.OpJump, 9),
+				// 0008
+				code.Make(code.OpNull),
+				// 0009
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// the loop is not the last statement of the body, its value is thrown away without being pushed
+			input: `fn(xs) { for (x in xs) { x }; 1 }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 0),
+					// 0002
+					code.Make(code.OpIterate),
+					// 0003
+					code.Make(code.OpIterNext, 15, 1),
+					// 0007
+					code.Make(code.OpSetLocal, 1),
+					// 0009
+					code.Make(code.OpGetLocal, 1),
+					// 0011
+					code.Make(code.OpPop),
+					// 0012
+					code.Make(code.OpJump, 3),
+					// 0015, the cursor is still popped
+					code.Make(code.OpPop),
+					// 0016
+					code.Make(code.OpConstant, 0),
+					// 0019
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && 1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpIfFalsy, 8),
+				// 0004
+				code.Make(code.OpPop),
+				// 0005
+				code.Make(code.OpConstant, 0),
+				// 0008
+				code.Make(code.OpPop),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "false || 1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpFalse),
+				// 0001
+				code.Make(code.OpJumpIfTruthy, 8),
+				// 0004
+				code.Make(code.OpPop),
+				// 0005
+				code.Make(code.OpConstant, 0),
+				// 0008
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `let x = 1; x = 2;`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				// the value of the assignment is the assigned value
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(n) { n = n + 1 }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	// a let statement binding the name of a constant again makes a new binding, which can be assigned
+	if err := New().Compile(parse("const x = 1; let x = 2; x = 3")); err != nil {
+		t.Errorf("compiler error: %s", err)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "undefined variable: x"},
+		{"len = 1", "cannot assign to built-in function len"},
+		{"let x = 1; let f = fn() { let y = 2; fn() { y = 3 } }", "cannot assign to y, it is bound by an enclosing function"},
+		{"const x = 1; x = 2", "cannot assign to constant x"},
+		{"const a, b = 1, 2; b++", "cannot assign to constant b"},
+		{"let f = fn() { const y = 1; y = 2 }", "cannot assign to constant y"},
+		{"const f = fn() { f = 1 }", "cannot assign to constant f"},
+		{"let a = 1; a, b = 2, 3", "undefined variable: b"},
+	}
+	for _, tt := range errors {
+		if err := New().Compile(parse(tt.input)); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// the let statement in the block defines a new global, its value reads the outer x
+			input:             `let x = 1; if (x) { let x = x; x }; x`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpJumpNotTruthy, 24),
+				// 0012
+				code.Make(code.OpGetGlobal, 0),
+				// 0015
+				code.Make(code.OpSetGlobal, 1),
+				// 0018
+				code.Make(code.OpGetGlobal, 1),
+				// 0021
+				code.Make(code.OpJump, 25),
+				// 0024
+				code.Make(code.OpNull),
+				// 0025
+				code.Make(code.OpPop),
+				// 0026, after the block x is the outer x again
+				code.Make(code.OpGetGlobal, 0),
+				// 0029
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; if (x) { let y = 2; }; y", "undefined variable: y"},
+		{"let x = 1; while (x) { let y = 2; }; y", "undefined variable: y"},
+		{"for (x in [1]) { x }; x", "undefined variable: x"},
+		{"let f = fn(x) { switch (x) { case 1 { let y = 2; } }; y }", "undefined variable: y"},
+	}
+	for _, tt := range errors {
+		if err := New().Compile(parse(tt.input)); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `for (x in [1]) { x }`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIterate),
+				// 0007
+				code.Make(code.OpIterNext, 21, 1),
+				// 0011
+				code.Make(code.OpSetGlobal, 0),
+				// 0014
+				code.Make(code.OpGetGlobal, 0),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpJump, 7),
+				// 0021
+				code.Make(code.OpPop),
+				// 0022
+				code.Make(code.OpNull),
+				// 0023
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(h) { for (k, v in h) { k } }`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpIterate),
+					code.Make(code.OpIterNext, 17, 2),
+					// the value is on top of the key
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpJump, 3),
+					code.Make(code.OpPop),
+					code.Make(code.OpNull),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestConditionals(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -365,6 +821,74 @@ func TestConditionals(t *testing.T) {
 				code.Make(code.OpPop), // 1 byte wide
 			},
 		},
+		{
+			input: `
+			true ? 10 : 20; 3333;
+			`,
+			expectedConstants: []interface{}{10, 20, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 13),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpConstant, 2),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			switch (1) { case 2, 3 { 10 } default { 20 } }; 3333;
+			`,
+			expectedConstants: []interface{}{1, 2, 3, 10, 20, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpDup),
+				// 0004
+				code.Make(code.OpConstant, 1),
+				// 0007
+				code.Make(code.OpEqual),
+				// 0008
+				code.Make(code.OpJumpIfTruthy, 17),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpDup),
+				// 0013
+				code.Make(code.OpConstant, 2),
+				// 0016
+				code.Make(code.OpEqual),
+				// 0017
+				code.Make(code.OpJumpNotTruthy, 27),
+				// 0020
+				code.Make(code.OpPop),
+				// 0021
+				code.Make(code.OpConstant, 3),
+				// 0024
+				code.Make(code.OpJump, 31),
+				// 0027
+				code.Make(code.OpPop),
+				// 0028
+				code.Make(code.OpConstant, 4),
+				// 0031
+				code.Make(code.OpPop),
+				// 0032
+				code.Make(code.OpConstant, 5),
+				// 0035
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input: `
 			if (false) { 10 } else { 20 };
@@ -454,6 +978,23 @@ func TestGlobalLetStatements(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input: `
+			let one, two = 1, 2;
+			let one, two = two, one;
+			`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 3),
+				code.Make(code.OpSetGlobal, 2),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -479,11 +1020,94 @@ func TestStringExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             `"mon" + "key" + "mon"`,
+			expectedConstants: []interface{}{"mon", "key"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
 }
 
+func TestStringInterningWithState(t *testing.T) {
+	constants := []object.Object{}
+	symbolTable := NewSymbolTable()
+
+	for _, input := range []string{`"monkey"`, `"monkey" + "business"`, `"business"`} {
+		compiler := NewWithState(symbolTable, constants)
+		err := compiler.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		constants = compiler.Bytecode().Constants
+	}
+
+	err := testConstants(t, []interface{}{"monkey", "business"}, constants)
+	if err != nil {
+		t.Fatalf("testConstants failed: %s", err)
+	}
+}
+
+func TestConstantPoolOverflow(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i <= MaxConstants; i++ {
+		fmt.Fprintf(&input, "%d;", i)
+	}
+
+	compiler := New()
+	err := compiler.Compile(parse(input.String()))
+	if err == nil {
+		t.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	expected := "too many constants: the constant pool is limited to 65536 entries in integer literal 65536"
+	if err.Error() != expected {
+		t.Fatalf("wrong compiler error. want=%q, got=%q", expected, err)
+	}
+}
+
+func TestCollectionLiteralOverflow(t *testing.T) {
+	elements := strings.Repeat("true, ", MaxCollectionElements)
+	pairs := make([]string, MaxCollectionElements/2+1)
+	for i := range pairs {
+		pairs[i] = fmt.Sprintf("%d: true", i)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x = 1;\nlet arr = [" + elements + "true];",
+			"array literal at line 2, column 11 has 65536 elements, exceeding the limit of 65535",
+		},
+		{
+			"{" + strings.Join(pairs, ", ") + "}",
+			"hash literal at line 1, column 1 has 32768 pairs, exceeding the limit of 32767",
+		},
+	}
+
+	for _, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compiler error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -506,64 +1130,179 @@ func TestArrayLiterals(t *testing.T) {
 			},
 		},
 		{
-			input:             "[1 + 2, 3 - 4, 5 * 6]",
-			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			input:             "[1 + 2, 3 - 4, 5 * 6]",
+			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSub),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpMul),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "{}",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{1: 2, 3: 4, 5: 6}",
+			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpHash, 6),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{1: 2 + 3, 4: 5 * 6}",
+			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpMul),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestRecordLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "record{x: 1, y: 2}; record{x: 3, y: 4}",
+			expectedConstants: []interface{}{1, 2, recordShape{"x", "y"}, 3, 4},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpRecord, 2, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpRecord, 2, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// the offset of a field is computed from the shape of the record bound to p
+			input: "let p = record{x: 1, y: 2}; p.y; fn() { p.x }",
+			expectedConstants: []interface{}{1, 2, recordShape{"x", "y"}, []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpGetField, 2, 0),
+				code.Make(code.OpReturnValue),
+			}},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpRecord, 2, 2),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpGetField, 2, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// without a known shape the field is looked up by name
+			input: "let f = fn(r) { r.x }",
+			expectedConstants: []interface{}{stringKey("x"), []code.Instructions{
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpIndexKey, 0),
+				code.Make(code.OpReturnValue),
+			}},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpAdd),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpMul),
-				code.Make(code.OpArray, 3),
-				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
 			},
 		},
 	}
 
 	runCompilerTests(t, tests)
+
+	compiler := New()
+	err := compiler.Compile(parse("let p = record{x: 1}; p.z"))
+	if err == nil || err.Error() != "record p has no field z" {
+		t.Errorf("wrong compiler error for a missing field: %v", err)
+	}
 }
 
-func TestHashLiterals(t *testing.T) {
+func TestSliceExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
-			input:             "{}",
-			expectedConstants: []interface{}{},
+			input:             "[1, 2][1:2]",
+			expectedConstants: []interface{}{1, 2, 1, 2},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpHash, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSlice),
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input:             "{1: 2, 3: 4, 5: 6}",
-			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			input:             "[1, 2][:1]",
+			expectedConstants: []interface{}{1, 2, 1},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpNull),
 				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpHash, 6),
+				code.Make(code.OpSlice),
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input:             "{1: 2 + 3, 4: 5 * 6}",
-			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			input:             "[1, 2]?[1:]",
+			expectedConstants: []interface{}{1, 2, 1},
 			expectedInstructions: []code.Instructions{
+				// 0000
 				code.Make(code.OpConstant, 0),
+				// 0003
 				code.Make(code.OpConstant, 1),
+				// 0006
+				code.Make(code.OpArray, 2),
+				// 0009
+				code.Make(code.OpJumpIfNull, 17),
+				// 0012
 				code.Make(code.OpConstant, 2),
-				code.Make(code.OpAdd),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpMul),
-				code.Make(code.OpHash, 4),
+				// 0015
+				code.Make(code.OpNull),
+				// 0016
+				code.Make(code.OpSlice),
+				// 0017
 				code.Make(code.OpPop),
 			},
 		},
@@ -603,6 +1342,24 @@ func TestIndexExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "[1]?[0]",
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpJumpIfNull, 13),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpIndex),
+				// 0013
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -665,6 +1422,26 @@ func TestFunctions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			// the default value is skipped when the call passed an argument for b
+			input: `fn(a, b = 10) { a + b }`,
+			expectedConstants: []interface{}{
+				10,
+				[]code.Instructions{
+					code.Make(code.OpJumpIfArgument, 9, 1),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -967,25 +1744,24 @@ func TestBuiltins(t *testing.T) {
 			len([]);
 			push([], 1);
 			`,
-			expectedConstants: []interface{}{1},
+			// len is folded to its result, push returns a new array every time it is called
+			expectedConstants: []interface{}{0, 1},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpGetBuiltin, 0),
-				code.Make(code.OpArray, 0),
-				code.Make(code.OpCall, 1),
+				code.Make(code.OpConstant, 0),
 				code.Make(code.OpPop),
 				code.Make(code.OpGetBuiltin, 5),
 				code.Make(code.OpArray, 0),
-				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
 				code.Make(code.OpCall, 2),
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input: `fn() { len([]) }`,
+			input: `fn(xs) { len(xs) }`,
 			expectedConstants: []interface{}{
 				[]code.Instructions{
 					code.Make(code.OpGetBuiltin, 0),
-					code.Make(code.OpArray, 0),
+					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpCall, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -999,6 +1775,189 @@ func TestBuiltins(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestFoldPureBuiltins(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `len("abc") + len([1, "a", [true]]);`,
+			expectedConstants: []interface{}{3, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// nested calls are folded with the arrays they return, the folded string shares the interned constant
+			input:             `"-2.0"; to_fixed(int(-2.5), len(rest(push([1], 2))));`,
+			expectedConstants: []interface{}{"-2.0"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// a call failing at compile time is left to fail with its position at run time
+			input:             `divmod(1, 0);`,
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 40),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// a binding hides the built-in function
+			input:             `let len = fn(x) { 1 }; len("a");`,
+			expectedConstants: []interface{}{1, []code.Instructions{code.Make(code.OpConstant, 0), code.Make(code.OpReturnValue)}, "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestModuleMembers(t *testing.T) {
+	upper := object.GetModuleByName("strings").Members["upper"]
+
+	tests := []compilerTestCase{
+		{
+			input:             `strings.upper("a")`,
+			expectedConstants: []interface{}{"a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, upper),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let s = strings; s.upper`,
+			expectedConstants: []interface{}{stringKey("upper")},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetModule, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpIndexKey, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+
+	program := parse(`strings.shout("a")`)
+	compiler := New()
+	err := compiler.Compile(program)
+	if err == nil {
+		t.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	expected := "undefined member shout in module strings"
+	if err.Error() != expected {
+		t.Errorf("wrong compiler error: want=%q, got=%q", expected, err)
+	}
+}
+
+func TestDebugInfo(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };`
+
+	for _, debugInfo := range []bool{false, true} {
+		compiler := New()
+		compiler.SetDebugInfo(debugInfo)
+		err := compiler.Compile(parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		fn, ok := compiler.Bytecode().Constants[0].(*object.CompiledFunction)
+		if !ok {
+			t.Fatalf("constant is not CompiledFunction. got=%T", compiler.Bytecode().Constants[0])
+		}
+
+		if len(fn.Parameters) != 2 || fn.Parameters[0] != "a" || fn.Parameters[1] != "b" {
+			t.Errorf("wrong parameters. got=%v", fn.Parameters)
+		}
+
+		expectedSource := ""
+		if debugInfo {
+			expectedSource = "fn(a, b) (a + b)"
+		}
+		if fn.Source != expectedSource {
+			t.Errorf("wrong source with debug info %t. want=%q, got=%q", debugInfo, expectedSource, fn.Source)
+		}
+	}
+}
+
+func TestUndefinedVariables(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedMessage    string
+		expectedLine       int
+		expectedColumn     int
+		expectedSuggestion string
+	}{
+		{"let counter = 1;\ncountr + 1", "undefined variable: countr", 2, 1, "counter"},
+		{"let f = fn(total) { totl };", "undefined variable: totl", 1, 21, "total"},
+		{"let counter = 1;\ncountr = 2", "undefined variable: countr", 2, 8, "counter"},
+		{"lenn([1])", "undefined variable: lenn", 1, 1, "len"},
+		{"zebra", "undefined variable: zebra", 1, 1, ""},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		located, ok := err.(*report.Error)
+		if !ok {
+			t.Fatalf("error is not *report.Error. got=%T (%v)", err, err)
+		}
+		if located.Kind != report.Compile || located.Message != tt.expectedMessage ||
+			located.Line != tt.expectedLine || located.Column != tt.expectedColumn || located.Suggestion != tt.expectedSuggestion {
+			t.Errorf("wrong error for %q. want=%s at %d:%d suggesting %q, got=%+v",
+				tt.input, tt.expectedMessage, tt.expectedLine, tt.expectedColumn, tt.expectedSuggestion, located)
+		}
+	}
+}
+
+func TestShadowingWarnings(t *testing.T) {
+	input := `
+	let len = fn(x) { 1 };
+	let strings = 2;
+	let f = fn() { let first = 3; first };
+	let fine = 4;
+	`
+
+	compiler := New()
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := []string{
+		"2:6: warning[W101]: let len shadows the built-in function len",
+		"3:6: warning[W102]: let strings shadows the built-in module strings",
+		"4:21: warning[W101]: let first shadows the built-in function first",
+	}
+
+	diagnostics := compiler.Diagnostics()
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. want=%d, got=%d (%v)", len(expected), len(diagnostics), diagnostics)
+	}
+	for i, want := range expected {
+		if diagnostics[i].String() != want {
+			t.Errorf("diagnostics[%d] wrong. want=%q, got=%q", i, want, diagnostics[i])
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -1277,3 +2236,188 @@ func TestRecursiveFunctions(t *testing.T) {
 	}
 	runCompilerTests(t, tests)
 }
+
+func TestStringKeys(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `{"a": 1, "b": "a"}`,
+			expectedConstants: []interface{}{stringKey("a"), 1, stringKey("b"), "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let h = {"a": 1}; h["a"]; h.a`,
+			expectedConstants: []interface{}{stringKey("a"), 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpIndexKey, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpIndexKey, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	// keys from a previous compilation are shared with the next one
+	first := New()
+	if err := first.Compile(parse(`let h = {"a": 1};`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	second := NewWithState(first.symbolTable, first.Bytecode().Constants)
+	if err := second.Compile(parse(`h.a`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	if len(second.Bytecode().Constants) != 2 {
+		t.Errorf("string key was not shared. constants=%d", len(second.Bytecode().Constants))
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse("1;\nlet x = 2 + 3;\nlet f = fn() { if (true) { x } };")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	// the operands of the addition have no position of their own, they belong to the operator
+	expected := code.SourceMap{{Offset: 0, Line: 1, Column: 1}, {Offset: 4, Line: 2, Column: 11}, {Offset: 11, Line: 2, Column: 1}, {Offset: 14, Line: 3, Column: 1}}
+	if len(bytecode.SourceMap) != len(expected) {
+		t.Fatalf("wrong source map. want=%v, got=%v", expected, bytecode.SourceMap)
+	}
+	for i := range expected {
+		if bytecode.SourceMap[i] != expected[i] {
+			t.Errorf("wrong position %d. want=%v, got=%v", i, expected[i], bytecode.SourceMap[i])
+		}
+	}
+
+	// the OpPop removed from the consequence does not leave a position behind
+	fn := bytecode.Constants[len(bytecode.Constants)-1].(*object.CompiledFunction)
+	for _, position := range fn.SourceMap {
+		if position.Offset >= len(fn.Instructions) {
+			t.Errorf("position past the instructions: %v", position)
+		}
+	}
+	if line, column, ok := fn.SourceMap.Lookup(0); !ok || line != 3 || column != 16 {
+		t.Errorf("wrong position of the function body. got=%d:%d %t", line, column, ok)
+	}
+}
+
+// update rewrites the golden files with the current output instead of comparing it, run
+// `go test ./compiler -update` after a deliberate change to the compiler and review the diff of testdata
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+func TestGoldenBytecode(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.monkey"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no golden inputs found: %v", err)
+	}
+
+	for _, input := range inputs {
+		source, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatalf("reading %s failed: %s", input, err)
+		}
+		compiler := New()
+		if err := compiler.Compile(parse(string(source))); err != nil {
+			t.Fatalf("%s: compiler error: %s", input, err)
+		}
+		testGolden(t, strings.TrimSuffix(input, ".monkey")+".golden", compiler.Bytecode().Dump())
+	}
+}
+
+func TestDump(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse(`let s = "a b"; fn(x) { x }`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	expected := `constants:
+0000 STRING "a b"
+0001 FUNCTION <anonymous>(x) locals=1
+
+main:
+0000 OpConstant 0
+0003 OpSetGlobal 0
+0006 OpClosure 1 0
+0010 OpPop
+
+function 0001 <anonymous>:
+0000 OpGetLocal 0
+0002 OpReturnValue
+`
+	if dump := compiler.Bytecode().Dump(); dump != expected {
+		t.Errorf("wrong dump:\n%s", diffLines(expected, dump))
+	}
+}
+
+// testGolden compares the output with the golden file at path, or rewrites the file with -update
+func testGolden(t *testing.T, path, got string) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("writing %s failed: %s", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s failed: %s, run the test with -update to create it", path, err)
+	}
+	if string(want) != got {
+		t.Errorf("%s does not match, run the test with -update if the change is deliberate:\n%s", path, diffLines(string(want), got))
+	}
+}
+
+// diffLines returns the lines of want missing from got prefixed with "-" and the lines of got missing
+// from want prefixed with "+", between the lines they have in common, which are indented with a space
+func diffLines(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&out, " %s\n", a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			fmt.Fprintf(&out, "-%s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+%s\n", b[j])
+			j++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,72 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// Dump returns a stable, human-readable text of the bytecode: the constants pool, the disassembled main
+// instructions and the disassembled instructions of every compiled function in the pool, which includes the
+// functions nested in other functions. The text depends only on the compiled program, not on memory addresses,
+// so two dumps can be compared line by line and a change in the compiler shows up as a readable diff.
+//
+//	constants:
+//	0000 INTEGER 1
+//	0001 FUNCTION add(a, b) locals=2
+//
+//	main:
+//	0000 OpClosure 1 0
+//	...
+//
+//	function 0001 add:
+//	0000 OpGetLocal 0
+//	...
+func (b *Bytecode) Dump() string {
+	var out strings.Builder
+
+	out.WriteString("constants:\n")
+	for i, constant := range b.Constants {
+		fmt.Fprintf(&out, "%04d %s\n", i, dumpConstant(constant))
+	}
+
+	out.WriteString("\nmain:\n")
+	out.WriteString(b.Instructions.String())
+
+	for i, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&out, "\nfunction %04d %s:\n", i, functionName(fn))
+		out.WriteString(fn.Instructions.String())
+	}
+
+	return out.String()
+}
+
+// dumpConstant formats a constant of the pool with its type, strings are quoted so whitespace stays visible
+func dumpConstant(constant object.Object) string {
+	switch constant := constant.(type) {
+	case *object.String:
+		return fmt.Sprintf("STRING %q", constant.Value)
+	case *object.StringKey:
+		return fmt.Sprintf("KEY %q", constant.String.Value)
+	case *object.RecordShape:
+		return "SHAPE " + constant.Inspect()
+	case *object.CompiledFunction:
+		return fmt.Sprintf("FUNCTION %s(%s) locals=%d", functionName(constant),
+			strings.Join(constant.Parameters, ", "), constant.NumLocals)
+	default:
+		return fmt.Sprintf("%s %s", constant.Type(), constant.Inspect())
+	}
+}
+
+// functionName returns the name a function is bound to, or object.AnonymousFunctionName
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return object.AnonymousFunctionName
+	}
+	return fn.Name
+}
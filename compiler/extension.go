@@ -0,0 +1,34 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/code"
+)
+
+// Extension lets embedders compile nodes themselves, typically into custom opcodes registered with code.Register.
+// Compile is offered every node before the compiler handles it. It returns true when it compiled the node,
+// false leaves the node to the next extension or the compiler. Extensions compile the child nodes they need
+// with the compiler's Compile method and emit their instructions with Emit.
+type Extension interface {
+	Compile(c *Compiler, node ast.Node) (bool, error)
+}
+
+// Use adds an extension to the compiler, extensions are consulted in the order they were added
+func (c *Compiler) Use(ext Extension) {
+	c.extensions = append(c.extensions, ext)
+}
+
+// Emit generates an instruction in the current scope and returns its position.
+// Unlike the compiler itself, extensions may only emit opcodes that have a definition.
+func (c *Compiler) Emit(op code.Opcode, operands ...int) (int, error) {
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		return 0, err
+	}
+	if len(operands) != len(def.OperandWidths) {
+		return 0, fmt.Errorf("%s expects %d operands, got %d", def.Name, len(def.OperandWidths), len(operands))
+	}
+	return c.emit(op, operands...), nil
+}
@@ -0,0 +1,131 @@
+package compiler
+
+import (
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// foldCall calls a pure built-in function at compile time when all the arguments of the call are constants,
+// `len("abc")` compiles to the constant 3 instead of a call. It reports false when the call must happen at
+// run time: the function is not a pure built-in function, an argument is not a constant, the call fails,
+// so the error is reported with its position when the program runs, or the result is an array, because
+// every call of a built-in function returns a new array and a constant would be the same one every time.
+func (c *Compiler) foldCall(node *ast.CallExpression) (object.Object, bool) {
+	result, ok := c.callPure(node)
+	if !ok {
+		return nil, false
+	}
+	switch result.(type) {
+	case *object.Integer, *object.Float, *object.String, *object.Boolean, *object.Null:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// callPure returns the result of calling a pure built-in function with constant arguments
+func (c *Compiler) callPure(node *ast.CallExpression) (object.Object, bool) {
+	// built-in functions do not accept named arguments, the call fails when the program runs
+	name, ok := node.Function.(*ast.Identifier)
+	if !ok || len(node.Names) > 0 {
+		return nil, false
+	}
+	// a binding of the same name hides the built-in function
+	symbol, ok := c.symbolTable.Resolve(name.Value)
+	if !ok || symbol.Scope != BuiltinScope {
+		return nil, false
+	}
+	builtin := object.Builtins[symbol.Index].Builtin
+	if !builtin.Pure || builtin.Fn == nil {
+		return nil, false
+	}
+
+	args := make([]object.Object, len(node.Arguments))
+	for i, arg := range node.Arguments {
+		value, ok := c.constantValue(arg)
+		if !ok {
+			return nil, false
+		}
+		args[i] = value
+	}
+
+	result := builtin.Fn(args...)
+	if result == nil {
+		return nil, false
+	}
+	if _, isError := result.(*object.Error); isError {
+		return nil, false
+	}
+	return result, true
+}
+
+// constantValue returns the value of an expression that is the same every time it is evaluated:
+// a literal, a negated number, an array of constants or a call of a pure built-in function with constants
+func (c *Compiler) constantValue(node ast.Expression) (object.Object, bool) {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}, true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}, true
+	case *ast.Boolean:
+		if node.Value {
+			return object.TRUE, true
+		}
+		return object.FALSE, true
+	case *ast.PrefixExpression:
+		if node.Operator != "-" {
+			return nil, false
+		}
+		switch right := node.Right.(type) {
+		case *ast.IntegerLiteral:
+			return &object.Integer{Value: -right.Value}, true
+		case *ast.FloatLiteral:
+			return &object.Float{Value: -right.Value}, true
+		}
+		return nil, false
+	case *ast.ArrayLiteral:
+		elements := make([]object.Object, len(node.Elements))
+		for i, element := range node.Elements {
+			value, ok := c.constantValue(element)
+			if !ok {
+				return nil, false
+			}
+			elements[i] = value
+		}
+		return &object.Array{Elements: elements}, true
+	case *ast.CallExpression:
+		return c.callPure(node)
+	default:
+		return nil, false
+	}
+}
+
+// emitConstant emits the instruction loading a folded value, booleans and null have their own opcodes
+func (c *Compiler) emitConstant(value object.Object) error {
+	var index int
+	var err error
+	switch value := value.(type) {
+	case *object.Boolean:
+		if value.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+		return nil
+	case *object.Null:
+		c.emit(code.OpNull)
+		return nil
+	case *object.String:
+		index, err = c.addStringConstant(value.Value)
+	default:
+		index, err = c.addConstant(value)
+	}
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, index)
+	return nil
+}
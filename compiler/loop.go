@@ -0,0 +1,61 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/code"
+)
+
+// loop is a loop being compiled, the target of the break and continue statements of its body
+type loop struct {
+	// depth is the number of elements on the stack when an iteration starts, a for loop keeps its cursor there
+	depth int
+	// continuePos is the position of the instructions starting the next iteration
+	continuePos int
+	// breakJumps holds the positions of the OpJump instructions of the break statements,
+	// they are backpatched with the position following the loop once it is compiled
+	breakJumps []int
+}
+
+// enterLoop starts compiling a loop whose iterations start at continuePos, the current position
+func (c *Compiler) enterLoop(continuePos int) {
+	depth, _ := code.StackDepth(c.currentInstructions(), continuePos)
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{depth: depth, continuePos: continuePos})
+}
+
+// leaveLoop ends the innermost loop, its break statements jump to breakPos
+func (c *Compiler) leaveLoop(breakPos int) {
+	scope := &c.scopes[c.scopeIndex]
+	l := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+	for _, pos := range l.breakJumps {
+		c.changeOperand(pos, breakPos)
+	}
+}
+
+// compileLoopControl compiles a break or continue statement to a jump out of the innermost loop or back to its
+// next iteration. The statement can be nested in an expression whose operands are already on the stack,
+// `1 + if (done) { break; }`, they are popped first so the stack is left like the loop expects it.
+func (c *Compiler) compileLoopControl(node ast.Statement) error {
+	scope := &c.scopes[c.scopeIndex]
+	if len(scope.loops) == 0 {
+		return fmt.Errorf("%s outside of a loop", node.TokenLiteral())
+	}
+	l := scope.loops[len(scope.loops)-1]
+
+	// no path reaches a statement right after another break, continue or return, nothing needs popping
+	if depth, ok := code.StackDepth(c.currentInstructions(), len(c.currentInstructions())); ok {
+		for i := l.depth; i < depth; i++ {
+			c.emit(code.OpPop)
+		}
+	}
+
+	if _, ok := node.(*ast.BreakStatement); ok {
+		l.breakJumps = append(l.breakJumps, c.emit(code.OpJump, 9999))
+	} else {
+		c.emit(code.OpJump, l.continuePos)
+	}
+	return nil
+}
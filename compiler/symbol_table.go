@@ -1,5 +1,11 @@
 package compiler
 
+import (
+	"sort"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
 // SymbolScope is the unique scope a symbol belongs to
 type SymbolScope string
 
@@ -9,17 +15,21 @@ const (
 	BuiltinScope  SymbolScope = "BUILTIN"
 	FreeScope     SymbolScope = "FREE"
 	FunctionScope SymbolScope = "FUNCTIOn"
+	ModuleScope   SymbolScope = "MODULE"
 )
 
 // Symbol is the struct that holds all the necessary information about a symbol
 // thats associated with an identifier.
 // It contains information such as its name (the identifier, x in let x), the scope it belongs to
 // and its unique number (index) in a SymbolTable. The index enables the VM to store
-// and retrieve values.
+// and retrieve values. Shape is the shape of the record the symbol is bound to, when the compiler knows it.
+// Constant is set for the symbols defined by const statements, which cannot be assigned to.
 type Symbol struct {
-	Name  string
-	Scope SymbolScope
-	Index int
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Shape    *object.RecordShape
+	Constant bool
 }
 
 // SymbolTable helps associate identifiers with a scope and unique number.
@@ -32,6 +42,14 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 	FreeSymbols    []Symbol
+	// blocks holds, for every block entered and not left yet, the symbols its definitions replaced in the store
+	blocks []map[string]shadowed
+}
+
+// shadowed is the symbol a definition in a block replaced, ok is false when the name had no symbol in the store
+type shadowed struct {
+	symbol Symbol
+	ok     bool
 }
 
 // NewSymbolTable creates a new SymbolTable with an empty store
@@ -54,11 +72,73 @@ func (st *SymbolTable) Define(name string) Symbol {
 		symbol.Scope = LocalScope
 	}
 
+	st.shadow(name)
 	st.store[name] = symbol
 	st.numDefinitions++
 	return symbol
 }
 
+// EnterBlock starts a block, the names defined until the matching LeaveBlock are only resolved inside of it.
+// The symbols of a block get new indices like any other definition, so a block never reuses the slots
+// of the bindings it shadows and the functions defined in it keep their own bindings.
+func (st *SymbolTable) EnterBlock() {
+	st.blocks = append(st.blocks, map[string]shadowed{})
+}
+
+// LeaveBlock ends the innermost block, the names defined in it resolve to the symbols they had before the block again
+func (st *SymbolTable) LeaveBlock() {
+	block := st.blocks[len(st.blocks)-1]
+	st.blocks = st.blocks[:len(st.blocks)-1]
+	for name, previous := range block {
+		if previous.ok {
+			st.store[name] = previous.symbol
+		} else {
+			delete(st.store, name)
+		}
+	}
+}
+
+// shadow remembers the symbol of the name before the innermost block defines it for the first time
+func (st *SymbolTable) shadow(name string) {
+	if len(st.blocks) == 0 {
+		return
+	}
+	block := st.blocks[len(st.blocks)-1]
+	if _, ok := block[name]; ok {
+		return
+	}
+	symbol, ok := st.store[name]
+	block[name] = shadowed{symbol: symbol, ok: ok}
+}
+
+// DefineConstant defines the identifier like Define does, the symbol is marked Constant so assignments
+// to it are rejected. Defining the name again with Define replaces it with a symbol that is not constant.
+func (st *SymbolTable) DefineConstant(name string) Symbol {
+	symbol := st.Define(name)
+	symbol.Constant = true
+	st.store[name] = symbol
+	return symbol
+}
+
+// NumDefinitions returns the number of symbols defined in the SymbolTable so far. Defining a name again
+// adds a new symbol, so the number only grows and tells whether anything was defined since it was read.
+func (st *SymbolTable) NumDefinitions() int {
+	return st.numDefinitions
+}
+
+// SetShape records the shape of the record the symbol defined with the name is bound to, in this SymbolTable
+// or the closest enclosing table defining the name. A nil shape forgets the shape the symbol had.
+func (st *SymbolTable) SetShape(name string, shape *object.RecordShape) {
+	if symbol, ok := st.store[name]; ok {
+		symbol.Shape = shape
+		st.store[name] = symbol
+		return
+	}
+	if st.Outer != nil {
+		st.Outer.SetShape(name, shape)
+	}
+}
+
 // DefineBuiltin sets an identifier/symbol association for a builtin function in the SymbolTable's store.
 // It uses the index of the builtin function in Builtins and its name to create a new symbol with the BuiltinScope
 func (st *SymbolTable) DefineBuiltin(index int, name string) Symbol {
@@ -67,6 +147,14 @@ func (st *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	return symbol
 }
 
+// DefineModule sets an identifier/symbol association for a built-in module in the SymbolTable's store.
+// It uses the index of the module in object.Modules and its name to create a new symbol with the ModuleScope
+func (st *SymbolTable) DefineModule(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: ModuleScope}
+	st.store[name] = symbol
+	return symbol
+}
+
 // SymbolTable sets an identifier/symbol association for a function in the SymbolTable's store.
 // There can only ever be one symbol in the FunctionScope for a SymbolTable.
 func (st *SymbolTable) DefineFunctionName(name string) Symbol {
@@ -86,7 +174,7 @@ func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 			return symbol, ok
 		}
 
-		if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope || symbol.Scope == ModuleScope {
 			return symbol, ok
 		}
 
@@ -106,13 +194,60 @@ func (st *SymbolTable) Resolve(name string) (Symbol, bool) {
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 
-	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Shape: original.Shape, Constant: original.Constant}
 	symbol.Scope = FreeScope
 
 	s.store[original.Name] = symbol
 	return symbol
 }
 
+// Globals returns the global symbols in the SymbolTable's store ordered by their index.
+// Together with RestoreGlobals it allows the global bindings of a session to be saved and restored.
+func (st *SymbolTable) Globals() []Symbol {
+	globals := []Symbol{}
+	for _, symbol := range st.store {
+		if symbol.Scope == GlobalScope {
+			globals = append(globals, symbol)
+		}
+	}
+	sort.Slice(globals, func(i, j int) bool { return globals[i].Index < globals[j].Index })
+	return globals
+}
+
+// Names returns the names the SymbolTable resolves, the names in its store and in the stores of the tables enclosing it
+func (st *SymbolTable) Names() []string {
+	names := []string{}
+	for table := st; table != nil; table = table.Outer {
+		for name := range table.store {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Locals returns the names of the local bindings in the SymbolTable's store, indexed by their slot
+func (st *SymbolTable) Locals() []string {
+	locals := make([]string, st.numDefinitions)
+	for _, symbol := range st.store {
+		if symbol.Scope == LocalScope {
+			locals[symbol.Index] = symbol.Name
+		}
+	}
+	return locals
+}
+
+// RestoreGlobals defines the given global symbols with their original indices, so they keep
+// referring to the same slots of the globals store. Later definitions continue after the highest index.
+func (st *SymbolTable) RestoreGlobals(symbols []Symbol) {
+	for _, symbol := range symbols {
+		symbol.Scope = GlobalScope
+		st.store[symbol.Name] = symbol
+		if symbol.Index >= st.numDefinitions {
+			st.numDefinitions = symbol.Index + 1
+		}
+	}
+}
+
 // NewEnclosedSymbolTable creates a new SymbolTable enclosed by an outer SymbolTable.
 func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	s := NewSymbolTable()
@@ -322,11 +322,11 @@ func TestResolveUnresolvableFree(t *testing.T) {
 		if !ok {
 			t.Errorf("name %s not resolvable", sym.Name)
 			continue
+		}
 
-			if result != sym {
-				t.Errorf("expected to %s to resolve to %+v, got=%+v",
-					sym.Name, sym, result)
-			}
+		if result != sym {
+			t.Errorf("expected to %s to resolve to %+v, got=%+v",
+				sym.Name, sym, result)
 		}
 	}
 
@@ -377,3 +377,88 @@ func TestShadowingFunctionName(t *testing.T) {
 			expected.Name, expected, result)
 	}
 }
+
+func TestGlobalsRestoreGlobals(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	global.Define("a")
+	global.Define("b")
+	global.Define("a")
+
+	expected := []Symbol{
+		{Name: "b", Scope: GlobalScope, Index: 1},
+		{Name: "a", Scope: GlobalScope, Index: 2},
+	}
+	globals := global.Globals()
+	if len(globals) != len(expected) {
+		t.Fatalf("wrong number of globals. want=%d, got=%d", len(expected), len(globals))
+	}
+	for i, sym := range expected {
+		if globals[i] != sym {
+			t.Errorf("globals[%d] wrong. want=%+v, got=%+v", i, sym, globals[i])
+		}
+	}
+
+	restored := NewSymbolTable()
+	restored.RestoreGlobals(globals)
+	for _, sym := range expected {
+		result, ok := restored.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	next := restored.Define("c")
+	if next.Index != 3 {
+		t.Errorf("wrong index for definition after restore. want=3, got=%d", next.Index)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	global.EnterBlock()
+	global.Define("a")
+	global.Define("b")
+	global.EnterBlock()
+	global.Define("b")
+	if result, _ := global.Resolve("b"); result.Index != 3 {
+		t.Errorf("wrong symbol of b in the inner block. got=%+v", result)
+	}
+	global.LeaveBlock()
+	if result, _ := global.Resolve("b"); result.Index != 2 {
+		t.Errorf("wrong symbol of b in the outer block. got=%+v", result)
+	}
+	global.LeaveBlock()
+
+	expected := Symbol{Name: "a", Scope: GlobalScope, Index: 0}
+	if result, ok := global.Resolve("a"); !ok || result != expected {
+		t.Errorf("expected a to resolve to %+v after the blocks, got=%+v", expected, result)
+	}
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("b resolvable after the blocks")
+	}
+	// the symbols of the blocks keep their slots
+	if next := global.Define("c"); next.Index != 4 {
+		t.Errorf("wrong index for definition after the blocks. want=4, got=%d", next.Index)
+	}
+}
+
+func TestLocals(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+	local.Define("c")
+
+	locals := local.Locals()
+	if len(locals) != 2 || locals[0] != "b" || locals[1] != "c" {
+		t.Errorf("wrong locals. got=%v", locals)
+	}
+}
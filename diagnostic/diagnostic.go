@@ -0,0 +1,116 @@
+// Package diagnostic defines the errors and warnings reported about a program by the parser and the compiler.
+// Warnings never abort parsing or compiling, they are rendered alongside the result.
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yourfavoritedev/golang-interpreter/token"
+)
+
+// Severity tells whether a diagnostic is an error, which prevents the program from running, or a warning
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+// String returns the name of the severity as it appears in rendered diagnostics
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// ANSI escape codes used to highlight diagnostics in a terminal
+const (
+	red    = "\x1b[31m"
+	yellow = "\x1b[33m"
+	reset  = "\x1b[0m"
+)
+
+// Diagnostic is a single error or warning. Line and Column locate the token it is about,
+// both count from 1 and are 0 when the position is unknown. Code identifies the kind
+// of diagnostic (E001, W001) so tools can filter them without matching messages.
+type Diagnostic struct {
+	Severity Severity
+	Line     int
+	Column   int
+	Code     string
+	Message  string
+}
+
+// New creates a diagnostic located at the given token
+func New(severity Severity, tok token.Token, code, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: severity,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// String formats the diagnostic as "line:column: severity[code]: message"
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s[%s]: %s", d.Line, d.Column, d.Severity, d.Code, d.Message)
+}
+
+// Diagnostics is the list of diagnostics reported while processing a program, in the order they were found
+type Diagnostics []Diagnostic
+
+// Errors returns only the diagnostics with the Error severity
+func (ds Diagnostics) Errors() Diagnostics {
+	return ds.filter(Error)
+}
+
+// Warnings returns only the diagnostics with the Warning severity
+func (ds Diagnostics) Warnings() Diagnostics {
+	return ds.filter(Warning)
+}
+
+// filter returns the diagnostics with the given severity
+func (ds Diagnostics) filter(severity Severity) Diagnostics {
+	var filtered Diagnostics
+	for _, d := range ds {
+		if d.Severity == severity {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
+
+// Render writes every diagnostic on its own line. When out is a terminal errors are red and warnings yellow,
+// the diagnostics written to a pipe or a file are plain text.
+func Render(out io.Writer, ds Diagnostics) {
+	RenderColor(out, ds, IsTerminal(out))
+}
+
+// RenderColor writes the diagnostics like Render, in color when color is set
+func RenderColor(out io.Writer, ds Diagnostics, color bool) {
+	for _, d := range ds {
+		if !color {
+			fmt.Fprintln(out, d)
+			continue
+		}
+		highlight := red
+		if d.Severity == Warning {
+			highlight = yellow
+		}
+		fmt.Fprintf(out, "%s%s%s\n", highlight, d, reset)
+	}
+}
+
+// IsTerminal reports whether w is a file connected to a terminal, which shows the ANSI escape codes as colors
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,44 @@
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/token"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tok := token.Token{Type: token.IDENT, Literal: "len", Line: 2, Column: 5}
+	ds := Diagnostics{
+		New(Warning, tok, "W101", "let %s shadows the built-in function %s", "len", "len"),
+		New(Error, tok, "E001", "no prefix parse function for %s found", "="),
+	}
+
+	if got := ds[0].String(); got != "2:5: warning[W101]: let len shadows the built-in function len" {
+		t.Errorf("wrong diagnostic string. got=%q", got)
+	}
+
+	if len(ds.Warnings()) != 1 || ds.Warnings()[0].Code != "W101" {
+		t.Errorf("wrong warnings. got=%v", ds.Warnings())
+	}
+	if len(ds.Errors()) != 1 || ds.Errors()[0].Code != "E001" {
+		t.Errorf("wrong errors. got=%v", ds.Errors())
+	}
+
+	var out bytes.Buffer
+	RenderColor(&out, ds, true)
+	expected := "\x1b[33m2:5: warning[W101]: let len shadows the built-in function len\x1b[0m\n" +
+		"\x1b[31m2:5: error[E001]: no prefix parse function for = found\x1b[0m\n"
+	if out.String() != expected {
+		t.Errorf("wrong rendering.\nwant=%q\ngot=%q", expected, out.String())
+	}
+
+	// a writer that is not a terminal gets plain text
+	out.Reset()
+	Render(&out, ds)
+	expected = "2:5: warning[W101]: let len shadows the built-in function len\n" +
+		"2:5: error[E001]: no prefix parse function for = found\n"
+	if out.String() != expected {
+		t.Errorf("wrong rendering without a terminal.\nwant=%q\ngot=%q", expected, out.String())
+	}
+}
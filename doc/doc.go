@@ -0,0 +1,63 @@
+// Package doc generates Markdown documentation from the ### doc comments in Monkey programs.
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+)
+
+// Generate builds the Markdown documentation of a program. Every top-level let statement
+// that is documented or binds a function gets a section with its name, the signature
+// of the function (if any) and the text of its doc comment.
+func Generate(program *ast.Program) string {
+	var out bytes.Buffer
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+
+		fl, isFunction := let.Value.(*ast.FunctionLiteral)
+		if let.Doc == "" && !isFunction {
+			continue
+		}
+
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "## %s\n", let.Name.Value)
+
+		if isFunction {
+			fmt.Fprintf(&out, "\n`%s`\n", signature(let.Name.Value, fl))
+		}
+
+		if let.Doc != "" {
+			fmt.Fprintf(&out, "\n%s\n", let.Doc)
+		}
+	}
+
+	return out.String()
+}
+
+// signature formats how a function is called, its name followed by its parameters with their default values
+// and the type annotations of the parameters and the result, when it has them
+func signature(name string, fl *ast.FunctionLiteral) string {
+	params := make([]string, len(fl.Parameters))
+	for i, p := range fl.Parameters {
+		params[i] = p.String()
+		if value := fl.Default(i); value != nil {
+			params[i] += " = " + value.String()
+		}
+		if i == fl.Fixed() {
+			params[i] = ast.VariadicString(p)
+		}
+	}
+	if fl.ReturnType != nil {
+		return fmt.Sprintf("%s(%s) -> %s", name, strings.Join(params, ", "), fl.ReturnType)
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(params, ", "))
+}
@@ -0,0 +1,41 @@
+package doc
+
+import (
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+)
+
+func TestGenerate(t *testing.T) {
+	input := `
+### Adds two numbers.
+### Both must be integers.
+let add = fn(a, b) { a + b };
+
+let double = fn(x) { x * 2 };
+
+let triple = fn(x: int) -> int { x * 3 };
+
+### The answer to everything.
+let answer = 42;
+
+let undocumented = 1;
+add(1, 2);
+`
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	expected := "## add\n\n`add(a, b)`\n\nAdds two numbers.\nBoth must be integers.\n" +
+		"\n## double\n\n`double(x)`\n" +
+		"\n## triple\n\n`triple(x: int) -> int`\n" +
+		"\n## answer\n\nThe answer to everything.\n"
+
+	if got := Generate(program); got != expected {
+		t.Errorf("wrong documentation.\nwant=%q\ngot=%q", expected, got)
+	}
+}
@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// Options configures an evaluation started by EvalWith
+type Options struct {
+	// Strict makes indexing an array out of range and reading a key missing from a hash errors instead of NULL
+	Strict bool
+	// MaxCallDepth is the maximum number of nested function calls, 0 for the default of MaxCallDepth
+	MaxCallDepth int
+	// Signals keeps the handlers the program registers with `on_signal` once the evaluation returns, a REPL
+	// shares them between its inputs. Without them an evaluation started with a context has signals of its own.
+	Signals *object.Signals
+	// Logger is the Logger of the logging built-in functions, `log_level` changes its level. Without it the
+	// evaluation writes to a copy of object.Log, so the level it sets is forgotten once it returns.
+	Logger *object.Logger
+}
+
+// evaluation is the state of a single evaluation, threaded through the evaluation of every node.
+// Evaluations running at the same time in different goroutines each have their own.
+type evaluation struct {
+	// ctx is the context the evaluation was started with, it is nil for evaluations started by Eval
+	ctx context.Context
+	// strict is Options.Strict
+	strict bool
+	// maxCallDepth is Options.MaxCallDepth and callStack holds the names of the functions
+	// that are currently being called, the innermost call last
+	maxCallDepth int
+	callStack    []string
+	// counts is the number of nodes evaluated and functions called since they were last reported
+	counts object.Counts
+	// signals holds the handlers the program registered with `on_signal`, it is nil for evaluations started by Eval.
+	// handlingSignal is true while the handler of a signal runs, the signals received meanwhile wait for it to return.
+	signals        *object.Signals
+	handlingSignal bool
+	// logger is Options.Logger, or a copy of object.Log. The workers of pmap share the logger of their evaluation.
+	logger *object.Logger
+}
+
+// newEvaluation returns the state of an evaluation stopped by the cancellation of ctx, which may be nil
+func newEvaluation(ctx context.Context, options Options) *evaluation {
+	ev := &evaluation{ctx: ctx, strict: options.Strict, maxCallDepth: options.MaxCallDepth, signals: options.Signals, logger: options.Logger}
+	if ev.maxCallDepth <= 0 {
+		ev.maxCallDepth = MaxCallDepth
+	}
+	if ev.logger == nil {
+		ev.logger = object.Log.Copy()
+	}
+	return ev
+}
+
+// EvalContext evaluates the node like Eval, but stops with an error once the context is cancelled.
+// Cancellation is checked whenever a function is called and a block is evaluated,
+// which lets an interrupted program stop even in the middle of an endless recursion.
+// The handlers the program registers with `on_signal` are called at the same points, and removed when it returns.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return EvalWith(ctx, node, env, Options{})
+}
+
+// EvalWith evaluates the node like EvalContext, with the given options
+func EvalWith(ctx context.Context, node ast.Node, env *object.Environment, options Options) object.Object {
+	ev := newEvaluation(ctx, options)
+	if ctx != nil && ev.signals == nil {
+		ev.signals = object.NewSignals()
+		defer ev.signals.Stop()
+	}
+	defer ev.reportCounts()
+	return ev.eval(node, env)
+}
+
+// reportCounts adds the work done since the last report to the totals of object.AddCounts
+func (ev *evaluation) reportCounts() {
+	object.AddCounts(ev.counts)
+	ev.counts = object.Counts{}
+}
+
+// checkpoint returns the error stopping the evaluation when its context has been cancelled, and nil otherwise.
+// It also calls the handlers the program registered with `on_signal` for the signals the process received,
+// a failed handler stops the evaluation with its error.
+func (ev *evaluation) checkpoint() *object.Error {
+	if ev.ctx == nil {
+		return nil
+	}
+	if err := ev.ctx.Err(); err != nil {
+		return newError("%s", err)
+	}
+	// the workers of pmap leave the signals to the evaluation they serve
+	if ev.handlingSignal || ev.signals == nil {
+		return nil
+	}
+
+	ev.handlingSignal = true
+	defer func() { ev.handlingSignal = false }()
+	for {
+		handler, ok := ev.signals.Next()
+		if !ok {
+			return nil
+		}
+		if errObj, ok := ev.applyFunction(handler, nil).(*object.Error); ok {
+			return errObj
+		}
+	}
+}
@@ -2,20 +2,33 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/yourfavoritedev/golang-interpreter/ast"
 	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+	"github.com/yourfavoritedev/golang-interpreter/token"
 )
 
 var (
 	// null can be referenced instead of allocating a new object each time we evaluate a node.
-	NULL = &object.Null{}
+	NULL = object.NULL
 	// there will only ever be two variations of object.Booleans,
 	// it is more beneficial to reference them instead of allocating new ones.
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
+var (
+	// breakSignal and continueSignal are the values of break and continue statements, handled by the innermost loop
+	breakSignal    = &object.LoopControl{Break: true}
+	continueSignal = &object.LoopControl{Break: false}
+)
+
+// MaxCallDepth is the default maximum number of nested function calls, exceeding it
+// results in an error instead of a Go stack overflow. Options.MaxCallDepth changes it.
+const MaxCallDepth = 1024
+
 // Eval accepts an AST Node and determines the best way to evaluate it.
 // We store the evaluated value in an Object, which can be later referenced.
 // Eval is expected to run recursively, following the "tree-walking pattern".
@@ -23,40 +36,89 @@ var (
 // going into all its statements and evaluating each one. It traverses each Statement,
 // evaluating its own nodes. This will lead to evaluating the actual Expression Nodes,
 // where the Value of the node can be consumed and stored in an Object.
+// An error is located at the innermost node it was raised by, see locate.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	ev := newEvaluation(nil, Options{})
+	defer ev.reportCounts()
+	return ev.eval(node, env)
+}
+
+// eval evaluates the node within the evaluation, every node is evaluated through it
+func (ev *evaluation) eval(node ast.Node, env *object.Environment) object.Object {
+	ev.counts.Instructions++
+	result := ev.evalNode(node, env)
+	if errObj, ok := result.(*object.Error); ok && errObj.Line == 0 && !errObj.Exit {
+		ev.locate(errObj, node)
+	}
+	return result
+}
+
+// evalNode evaluates the node with the method matching its type
+func (ev *evaluation) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
 		// evaluates all statements in the program
-		return evalProgram(node, env)
+		return ev.evalProgram(node, env)
 	case *ast.ExpressionStatement:
 		// recursively calls itself to evaluate the entire expression statement
-		return Eval(node.Expression, env)
+		return ev.eval(node.Expression, env)
 	case *ast.BlockStatement:
-		// evaluate all statements in the BlockStatement
-		return evalBlockStatement(node, env)
+		// evaluate all statements in the BlockStatement, the names they bind are only visible inside the block
+		return ev.evalBlockStatement(node, object.NewBlockEnvironment(env))
 	case *ast.ReturnStatement:
 		// evaluate the expression associated with the return statement and then wrap the value
-		val := Eval(node.ReturnValue, env)
+		val := ev.eval(node.ReturnValue, env)
 		// if there is an error, prevent it from being passed around
 		// and bubbling up far away from their origin
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.BreakStatement:
+		return breakSignal
+	case *ast.ContinueStatement:
+		return continueSignal
 	case *ast.LetStatement:
 		// first we need to evaluate the expression of the LetStatement
-		val := Eval(node.Value, env)
+		val := ev.eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		// set the identifier name and the evaluated value to the environment
-		env.Set(node.Name.Value, val)
+		bind(env, node.Name.Value, val, node.Const)
+	case *ast.MultiLetStatement:
+		// every value is evaluated before any name is bound, so the values see the previous bindings
+		values := ev.evalExpressions(node.Values, env)
+		if len(values) == 1 && isError(values[0]) {
+			return values[0]
+		}
+		for i, name := range node.Names {
+			bind(env, name.Value, values[i], node.Const)
+		}
+	case *ast.MultiAssignStatement:
+		// every name must be assignable before any value is evaluated, and every value
+		// is evaluated before any name is assigned, so the values see the previous bindings
+		owners := make([]*object.Environment, len(node.Names))
+		for i, name := range node.Names {
+			owner, errObj := assignOwner(name.Value, env)
+			if errObj != nil {
+				return errObj
+			}
+			owners[i] = owner
+		}
+		values := ev.evalExpressions(node.Values, env)
+		if len(values) == 1 && isError(values[0]) {
+			return values[0]
+		}
+		for i, name := range node.Names {
+			owners[i].Set(name.Value, values[i])
+		}
 
 	// Expressions
 	case *ast.PrefixExpression:
 		// Evaluate its operand and then use the result with the operator
-		right := Eval(node.Right, env)
+		right := ev.eval(node.Right, env)
 		// if there is an error, prevent it from being passed around
 		// and bubbling up far away from their origin
 		if isError(right) {
@@ -65,33 +127,55 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
 		// evaluate the left and right operands and then use the results with the operator
-		left := Eval(node.Left, env)
+		left := ev.eval(node.Left, env)
 		// return error if encountered when evaluating left node
 		if isError(left) {
 			return left
 		}
+		// && and || only evaluate the right operand when the left one does not decide the result
+		if (node.Operator == "&&" && !isTruthy(left)) || (node.Operator == "||" && isTruthy(left)) {
+			return left
+		}
 
-		right := Eval(node.Right, env)
+		right := ev.eval(node.Right, env)
 		// return error if encountered when evaluating right node
 		if isError(right) {
 			return right
 		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return right
+		}
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.IfExpression:
 		// evaluate if expression
-		return evalIfExpression(node, env)
+		return ev.evalIfExpression(node, env)
+	case *ast.ConditionalExpression:
+		return ev.evalConditionalExpression(node, env)
+	case *ast.SwitchExpression:
+		return ev.evalSwitchExpression(node, env)
+	case *ast.WhileExpression:
+		return ev.evalWhileExpression(node, env)
+	case *ast.ForExpression:
+		return ev.evalForExpression(node, env)
+	case *ast.AssignExpression:
+		return ev.evalAssignExpression(node, env)
 	case *ast.IntegerLiteral:
 		// Simply evaluates an integer literal
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		// Simply evaluates a Boolean
 		return nativeBoolToBooleanObject(node.Value)
 		// Simply evaluates a string literal
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.SymbolLiteral:
+		// symbols are interned, every literal of the same name evaluates to the same symbol
+		return object.Intern(node.Value)
 	case *ast.ArrayLiteral:
 		// Evaluate the array literal with its elements
-		elements := evalExpressions(node.Elements, env)
+		elements := ev.evalExpressions(node.Elements, env)
 		// Should stop evaluating as soon as we encounter an error while evaluating the elements
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
@@ -100,18 +184,60 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IndexExpression:
 		// Evaluate the index operator expression. First evaluate the object being operated on, it
 		// can take the form of any expression. Then evaluate the index which is also an expression.
-		left := Eval(node.Left, env)
+		left := ev.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		// a safe navigation on null is null, without evaluating the index
+		if node.Optional && left == NULL {
+			return NULL
+		}
+		index := ev.eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return ev.evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		// Evaluate the slice expression like an index operation, an omitted bound is NULL
+		left := ev.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if node.Optional && left == NULL {
+			return NULL
+		}
+		bounds := []object.Object{NULL, NULL}
+		for i, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				continue
+			}
+			bounds[i] = ev.eval(bound, env)
+			if isError(bounds[i]) {
+				return bounds[i]
+			}
+		}
+		return ev.evalSliceExpression(left, bounds[0], bounds[1])
+	case *ast.MemberExpression:
+		// Evaluate the member access like an index operation with the name of the property as the index,
+		// which looks up members of modules and string keys of hashes.
+		obj := ev.eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return ev.evalMemberExpression(obj, node.Property.Value)
+	case *ast.RecordLiteral:
+		values := ev.evalExpressions(node.Values, env)
+		if len(values) == 1 && isError(values[0]) {
+			return values[0]
+		}
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		return &object.Record{Shape: &object.RecordShape{Fields: fields}, Values: values}
 	case *ast.HashLiteral:
 		// Simply evaluates a hash literal
-		return evalHashLiteral(node, env)
+		return ev.evalHashLiteral(node, env)
 
 	// Identifiers
 	case *ast.Identifier:
@@ -125,25 +251,34 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		// they will be evaluated during function calls
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Variadic: node.Variadic, Body: body, Env: env, Name: node.Name, Doc: node.Doc}
 	case *ast.CallExpression:
 		// Evaluate the call expression, simply getting back the function we want to call,
 		// it can be the form of an ast.Identifier or an ast.FunctionLiteral, it still
 		// returns an object.Function
-		function := Eval(node.Function, env)
+		function := ev.eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
 
 		// Evaluate the arguments of the function and keep track of the produced Object values.
 		// Should stop evaluating as soon as we encounter an error
-		args := evalExpressions(node.Arguments, env)
+		args := ev.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
+		// the named arguments are placed at the position of their parameters before the call
+		if len(node.Names) > 0 {
+			names := make([]string, len(node.Names))
+			for i, name := range node.Names {
+				names[i] = name.Value
+			}
+			return ev.applyNamed(function, args, names)
+		}
+
 		// call the function!
-		return applyFunction(function, args)
+		return ev.applyFunction(function, args)
 	}
 
 	return nil
@@ -152,18 +287,44 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 // applyFunction accepts an already evaluated function and evaluated arguments.
 // If fn is of type object.Function, it will bind the function and arguments to a new inner environment then evaluate it.
 // If fn is type object.Builtin, it will call the built-in function with the given arguments.
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (ev *evaluation) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		// every call of a function nests the evaluation deeper in the Go stack,
+		// stop before exceeding the maximum depth rather than overflowing it
+		if err := ev.checkpoint(); err != nil {
+			return err
+		}
+		if len(ev.callStack) >= ev.maxCallDepth {
+			return newError("%s", object.RecursionDepthMessage(ev.maxCallDepth, append(ev.callStack, fn.Name)))
+		}
+		ev.callStack = append(ev.callStack, fn.Name)
+		defer func() { ev.callStack = ev.callStack[:len(ev.callStack)-1] }()
+		ev.counts.Frames++
+
 		// bind function and arguments to a new inner environment
-		extendedEnv := extendFunctionEnv(fn, args)
-		// evaluate the function body within this extended environemnt
-		evaluated := Eval(fn.Body, extendedEnv)
+		extendedEnv, errObj := ev.extendFunctionEnv(fn, args)
+		if errObj != nil {
+			return errObj
+		}
+		// evaluate the function body within this extended environemnt, which is already fresh for the call
+		// so the body does not get a block environment of its own
+		evaluated := ev.evalBlockStatement(fn.Body, extendedEnv)
 		// unwrap object if its a return value object
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		// call the built-in function with the evaluated arguments
-		if result := fn.Fn(args...); result != nil {
+		// call the built-in function with the evaluated arguments, higher-order
+		// built-in functions call back into the evaluator through callFunction,
+		// parallel ones on the workers of fork.
+		// `runtime_stats` reports the work done up to its call.
+		ev.reportCounts()
+		var result object.Object
+		if fn.ParallelFn != nil {
+			result = fn.CallParallel(ev.callFunction, ev.fork, args...)
+		} else {
+			result = fn.CallProgram(ev.callFunction, ev.signals, ev.logger, args...)
+		}
+		if result != nil {
 			return result
 		}
 		return NULL
@@ -172,23 +333,92 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
+// applyNamed calls fn with arguments of which the last len(names) are named. They are matched with the parameters
+// of the function by their names, the parameters left out take their default values. Built-in functions have no
+// parameter names, they only accept positional arguments.
+func (ev *evaluation) applyNamed(fn object.Object, args []object.Object, names []string) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		params := make([]string, len(fn.Parameters))
+		for i, param := range fn.Parameters {
+			params[i] = param.Value
+		}
+		required := len(fn.Parameters) - len(fn.Defaults)
+		if fn.Variadic {
+			required--
+		}
+		arranged, err := object.ArrangeArguments(params, required, fn.Variadic, args, names)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return ev.applyFunction(fn, arranged)
+	case *object.Builtin:
+		return newError("built-in functions do not accept named arguments, got %s", names[0])
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// callFunction lets higher-order built-in functions apply a function with the given arguments
+func (ev *evaluation) callFunction(fn object.Object, args ...object.Object) object.Object {
+	return ev.applyFunction(fn, args)
+}
+
 // extendFunctionEnv creates a new inner environment for an object.Function
 // It binds the function's parameters and already evaluated arguments to
 // the new inner environment. The environment is enclosed by the initial environment (outer)
-// of which the function was defined in (Function.Env)
-func extendFunctionEnv(
+// of which the function was defined in (Function.Env). It returns an error when the number of arguments
+// does not match the parameters or when evaluating a default value fails.
+func (ev *evaluation) extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+) (*object.Environment, *object.Error) {
+	// a variadic function accepts any number of arguments after its fixed parameters
+	fixed, total := len(fn.Parameters), len(fn.Parameters)
+	if fn.Variadic {
+		fixed, total = fixed-1, -1
+	}
+	required := fixed - len(fn.Defaults)
+	if len(args) < required || (!fn.Variadic && len(args) > fixed) {
+		return nil, newError("wrong number of arguments: %s", object.ArgumentsMessage(required, total, len(args)))
+	}
+
 	// Create inner environment, enclosed by the outer environment that defined the function
 	env := object.NewEnclosedEnvironment(fn.Env)
 
-	// set inner environment store with the function's parameters and evaluated arguments
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+	// set inner environment store with the function's parameters and evaluated arguments,
+	// the parameters left out by the call are null until their default values are evaluated.
+	// A call with named arguments leaves out a parameter with a nil argument.
+	for paramIdx, param := range fn.Parameters[:fixed] {
+		if paramIdx < len(args) && args[paramIdx] != nil {
+			env.Set(param.Value, args[paramIdx])
+		} else {
+			env.Set(param.Value, NULL)
+		}
+	}
+	// the variadic parameter collects the remaining arguments in a new array, which is empty when there are none
+	if fn.Variadic {
+		rest := []object.Object{}
+		if len(args) > fixed {
+			rest = append(rest, args[fixed:]...)
+		}
+		env.Set(fn.Parameters[fixed].Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	// evaluate the default values of the missing arguments from left to right in the inner environment,
+	// so a default can refer to the parameters before it
+	for paramIdx := required; paramIdx < fixed; paramIdx++ {
+		if paramIdx < len(args) && args[paramIdx] != nil {
+			continue
+		}
+		value := ev.eval(fn.Defaults[paramIdx-required], env)
+		if err, ok := value.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(fn.Parameters[paramIdx].Value, value)
+	}
+
+	return env, nil
 }
 
 // unwrapReturnValue asserts if the evaluated object is an object.ReturnValue.
@@ -205,11 +435,11 @@ func unwrapReturnValue(obj object.Object) object.Object {
 // evalProgram accepts an ast.Program and evaluates its
 // statements, constructing an object.Object for every
 // evaluated ast.Node it encounters
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (ev *evaluation) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = ev.eval(statement, env)
 
 		switch result := result.(type) {
 		// if we encounter a ReturnValue after successfully evaluating a statement,
@@ -228,18 +458,22 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 // evalBlockStatement evaluates a block statement and identifies
 // if we should immediately return the evaluated value if
 // it is of type object.RETURN_VALUE_OBJ
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (ev *evaluation) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	if err := ev.checkpoint(); err != nil {
+		return err
+	}
+
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = ev.eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
 			// should return the Object and early-exit if the statement has evalated to an object of type
-			// RETURN_VALUE_OBJ or ERROR_OBJ, these are objects that should stop the evaluation.
-			// This happens after we evaluate a return statement or encounter an error
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			// RETURN_VALUE_OBJ, ERROR_OBJ or LOOP_CONTROL_OBJ, these are objects that should stop the evaluation.
+			// This happens after we evaluate a return, break or continue statement or encounter an error
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.LOOP_CONTROL_OBJ {
 				return result
 			}
 		}
@@ -257,6 +491,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalComplementPrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -273,6 +509,17 @@ func evalInfixExpression(
 	// evaluate the infix expression where both left and right nodes are operating on integers
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	// a float operand promotes an integer operand to a float
+	case left.Type() == object.FLOAT_OBJ && object.IsNumber(right), right.Type() == object.FLOAT_OBJ && object.IsNumber(left):
+		return evalFloatInfixExpression(operator, left, right)
+	// records are equal when their fields are
+	case left.Type() == object.RECORD_OBJ && right.Type() == object.RECORD_OBJ && (operator == "==" || operator == "!="):
+		equal := left.(*object.Record).Equal(right.(*object.Record))
+		return nativeBoolToBooleanObject(equal == (operator == "=="))
+	// evaluate the infix expression where both left and right nodes are operating on strings,
+	// strings are equal when their values are, not only when they are the same object
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	// When the nodes are not integers then they are object.Booleans.
 	// We can do a pointer comparison here to check for equality between booleans.
 	// This is possible because the nodes here have already been evaluated
@@ -287,9 +534,6 @@ func evalInfixExpression(
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
-	// evaluate the infix expression where both left and right nodes are operating on strings
-	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -316,11 +560,38 @@ func evalIntegerInfixExpression(
 	case "*":
 		return &object.Integer{Value: leftValue * rightValue}
 	case "/":
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftValue / rightValue}
+	case "//":
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
+		quotient, _ := object.FloorDivide(leftValue, rightValue)
+		return &object.Integer{Value: quotient}
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<", ">>":
+		if rightValue < 0 {
+			return newError("negative shift count: %d", rightValue)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftValue << rightValue}
+		}
+		return &object.Integer{Value: leftValue >> rightValue}
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
 	case ">":
 		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
 	case "==":
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
@@ -331,21 +602,68 @@ func evalIntegerInfixExpression(
 	}
 }
 
-// evalStringInfixExpression validates that a concatentation (+) is
-// attempted on two Object.Strings (left) and (right).
-// It concatenates the left and right Values to form a new Object.String
-func evalStringInfixExpression(
+// evalFloatInfixExpression performs an operation between two numbers of which at least one is a float,
+// the integer operand is promoted to a float. Arithmetic follows IEEE 754 except for floor division by zero.
+func evalFloatInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	if operator != "+" {
+	leftValue, _ := object.ToFloat(left)
+	rightValue, _ := object.ToFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftValue + rightValue}
+	case "-":
+		return &object.Float{Value: leftValue - rightValue}
+	case "*":
+		return &object.Float{Value: leftValue * rightValue}
+	case "/":
+		return &object.Float{Value: leftValue / rightValue}
+	case "//":
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Floor(leftValue / rightValue)}
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+}
 
+// evalStringInfixExpression validates that a concatentation (+) or a comparison (== or !=) is
+// attempted on two Object.Strings (left) and (right).
+// It concatenates the left and right Values to form a new Object.String, or compares them.
+func evalStringInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
 }
 
 // evalBangOperatorExpression will return the inverse object.Boolean
@@ -368,6 +686,9 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 // a Value that is oppositely charged to the provided object.Integer, right.
 // 5 -> -5 and -5 -> 5
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if float, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -float.Value}
+	}
 	// validate that an integer is provided
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
@@ -377,6 +698,15 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	return &object.Integer{Value: -value}
 }
 
+// evalComplementPrefixOperatorExpression flips every bit of an integer, ~x is -x - 1
+func evalComplementPrefixOperatorExpression(right object.Object) object.Object {
+	integer, ok := right.(*object.Integer)
+	if !ok {
+		return newError("unknown operator: ~%s", right.Type())
+	}
+	return &object.Integer{Value: ^integer.Value}
+}
+
 // nativeBoolToBooleanObject determines which object.Boolean struct
 // to return depending on the provided input
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -388,22 +718,152 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 
 // evalIfExpression constructs a new Object by evaluating either
 // the if expression's Consequence or Alternative.
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+func (ev *evaluation) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	// evaluate the condition and determine whether it is truthy or falsey
-	condition := Eval(ie.Condition, env)
+	condition := ev.eval(ie.Condition, env)
 	// if there is an error, prevent it from being passed around
 	// and bubbling up far away from its origin
 	if isError(condition) {
 		return condition
 	}
 
+	var result object.Object
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		result = ev.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
-	} else {
+		result = ev.eval(ie.Alternative, env)
+	}
+	// a block ending with a let statement, or an empty block, has the value null like a missing alternative
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// evalConditionalExpression evaluates the consequence when the condition is truthy and the alternative otherwise
+func (ev *evaluation) evalConditionalExpression(ce *ast.ConditionalExpression, env *object.Environment) object.Object {
+	condition := ev.eval(ce.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return ev.eval(ce.Consequence, env)
+	}
+	return ev.eval(ce.Alternative, env)
+}
+
+// evalSwitchExpression evaluates the subject once and compares it with == to the values of the cases in order,
+// the body of the first matching case is evaluated, or the default body when no case matches
+func (ev *evaluation) evalSwitchExpression(se *ast.SwitchExpression, env *object.Environment) object.Object {
+	subject := ev.eval(se.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	body := se.Default
+cases:
+	for _, c := range se.Cases {
+		for _, v := range c.Values {
+			value := ev.eval(v, env)
+			if isError(value) {
+				return value
+			}
+			equal := evalInfixExpression("==", subject, value)
+			if isError(equal) {
+				return equal
+			}
+			if isTruthy(equal) {
+				body = c.Body
+				break cases
+			}
+		}
+	}
+
+	var result object.Object
+	if body != nil {
+		result = ev.eval(body, env)
+	}
+	// like an if expression, a body ending with a let statement, or no body, has the value null
+	if result == nil {
 		return NULL
 	}
+	return result
+}
+
+// evalWhileExpression evaluates the body of the loop as long as its condition is truthy and returns NULL.
+// A break statement stops the loop and a continue statement only stops the body, they end the evaluation
+// of the body with their signal. A return statement or an error in the body stops the loop and is passed on. Cancellation is checked
+// before every iteration, so an endless loop without calls can still be interrupted.
+func (ev *evaluation) evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	for {
+		if err := ev.checkpoint(); err != nil {
+			return err
+		}
+
+		condition := ev.eval(we.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := ev.eval(we.Body, env)
+		if result == breakSignal {
+			return NULL
+		}
+		if result != nil {
+			if rt := result.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+}
+
+// evalForExpression evaluates the body of the loop once for every element of the collection and returns NULL.
+// The names of the loop are bound to the key and the value of the element before the body runs,
+// in an environment of the loop enclosing the body, so they are not visible after the loop.
+// Like in a while loop, break and continue statements, a return statement or an error stop the loop or the body,
+// and cancellation is checked before every iteration.
+func (ev *evaluation) evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := ev.eval(fe.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	cursor, errObj := object.Iterate(iterable)
+	if errObj != nil {
+		return errObj
+	}
+
+	loopEnv := object.NewBlockEnvironment(env)
+	for {
+		if err := ev.checkpoint(); err != nil {
+			return err
+		}
+
+		key, value, ok := cursor.Next(ev.callFunction)
+		if !ok {
+			return NULL
+		}
+		if isError(value) {
+			return value
+		}
+		if fe.Key != nil {
+			loopEnv.Set(fe.Key.Value, key)
+		}
+		loopEnv.Set(fe.Value.Value, value)
+
+		result := ev.eval(fe.Body, loopEnv)
+		if result == breakSignal {
+			return NULL
+		}
+		if result != nil {
+			if rt := result.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
 }
 
 func isTruthy(obj object.Object) bool {
@@ -419,6 +879,64 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+// locate sets the position of the error to the position of the node that raised it, for the nodes that can raise
+// errors themselves: identifiers, operators, calls, indexing and assignments. The error keeps the names of the
+// functions being called, the evaluator does not record where the calls were made.
+func (ev *evaluation) locate(err *object.Error, node ast.Node) {
+	var tok token.Token
+	switch node := node.(type) {
+	case *ast.Identifier:
+		tok = node.Token
+	case *ast.PrefixExpression:
+		tok = node.Token
+	case *ast.InfixExpression:
+		tok = node.Token
+	case *ast.CallExpression:
+		tok = node.Token
+	case *ast.IndexExpression:
+		tok = node.Token
+	case *ast.SliceExpression:
+		tok = node.Token
+	case *ast.MemberExpression:
+		tok = node.Token
+	case *ast.AssignExpression:
+		tok = node.Token
+	case *ast.ForExpression:
+		tok = node.Token
+	case *ast.LetStatement:
+		tok = node.Token
+	case *ast.MultiLetStatement:
+		tok = node.Token
+	case *ast.MultiAssignStatement:
+		tok = node.Token
+	default:
+		return
+	}
+	err.Line, err.Column = tok.Line, tok.Column
+	err.Trace = append([]string{}, ev.callStack...)
+}
+
+// Report describes an error the evaluation stopped with as a report.Error, with the position and the calls
+// recorded when the error was located
+func Report(err *object.Error) *report.Error {
+	frames := []report.Frame{{}}
+	for _, name := range err.Trace {
+		if name == "" {
+			name = object.AnonymousFunctionName
+		}
+		frames = append(frames, report.Frame{Function: name})
+	}
+	frames[len(frames)-1].Line, frames[len(frames)-1].Column = err.Line, err.Column
+	return &report.Error{
+		Kind:       report.Runtime,
+		Message:    err.Message,
+		Line:       err.Line,
+		Column:     err.Column,
+		Trace:      frames,
+		Suggestion: err.Suggestion,
+	}
+}
+
 // newError constructs a object.Error with the given format and
 // a, which is a variadic slice of error message(s) which can be for any type
 func newError(format string, a ...interface{}) *object.Error {
@@ -434,6 +952,52 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// evalAssignExpression rebinds a name to a new value in the environment that bound it and returns the value.
+// A function can assign to its own bindings, including those of the blocks it is in, and to the bindings at
+// the top of the program, but not to the bindings of an enclosing function, which the VM copies into its closures.
+func (ev *evaluation) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	owner, errObj := assignOwner(node.Name.Value, env)
+	if errObj != nil {
+		return errObj
+	}
+
+	val := ev.eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+	return owner.Set(node.Name.Value, val)
+}
+
+// assignOwner returns the environment holding the binding an assignment to the name replaces, or the error
+// when the name cannot be assigned to from env
+func assignOwner(name string, env *object.Environment) (*object.Environment, *object.Error) {
+	owner := env.Owner(name)
+	switch {
+	case owner == nil && object.GetBuiltInByName(name) != nil:
+		return nil, newError("cannot assign to built-in function %s", name)
+	case owner == nil && object.GetModuleByName(name) != nil:
+		return nil, newError("cannot assign to built-in module %s", name)
+	case owner == nil:
+		err := newError("undefined variable: %s", name)
+		err.Suggestion = report.Suggest(name, env.Names())
+		return nil, err
+	case owner.IsConstant(name):
+		return nil, newError("cannot assign to constant %s", name)
+	case owner.Function() != env.Function() && !owner.Global():
+		return nil, newError("cannot assign to %s, it is bound by an enclosing function", name)
+	}
+	return owner, nil
+}
+
+// bind binds the name of a let statement in the environment, or of a const statement when constant is set
+func bind(env *object.Environment, name string, val object.Object, constant bool) {
+	if constant {
+		env.SetConstant(name, val)
+	} else {
+		env.Set(name, val)
+	}
+}
+
 // evalIdentifier verifies if an identifier has been previously associated
 // in the environment. Ff an identifier was found, return its mapped object.
 // If not found, then check if there is a built-in function with that identifier.
@@ -444,24 +1008,43 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin := object.GetBuiltInByName(node.Value); builtin != nil {
 		return builtin
 	}
 
-	return newError("identifier not found: %s", node.Value)
+	if module := object.GetModuleByName(node.Value); module != nil {
+		return module
+	}
+
+	err := newError("identifier not found: %s", node.Value)
+	err.Suggestion = report.Suggest(node.Value, visibleNames(env))
+	return err
+}
+
+// visibleNames returns the names an identifier evaluated in the environment can refer to:
+// the bindings, the built-in functions and the modules
+func visibleNames(env *object.Environment) []string {
+	names := env.Names()
+	for _, builtin := range object.Builtins {
+		names = append(names, builtin.Name)
+	}
+	for _, module := range object.Modules {
+		names = append(names, module.Name)
+	}
+	return names
 }
 
 // evalExpressions evaluates the given list of expressions and if no error is encountered
 // then it will return the evaluated Objects in their respective argument order.
 // However, if an error was encountered, it will only return the Object.Error.
-func evalExpressions(
+func (ev *evaluation) evalExpressions(
 	exps []ast.Expression,
 	env *object.Environment,
 ) []object.Object {
 	var result []object.Object
 
 	for _, e := range exps {
-		evaluated := Eval(e, env)
+		evaluated := ev.eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -471,20 +1054,41 @@ func evalExpressions(
 	return result
 }
 
+// evalMemberExpression looks up the named field of a record, the member of a module
+// or the string key of a hash. Fields of records are only accessed this way.
+func (ev *evaluation) evalMemberExpression(obj object.Object, name string) object.Object {
+	if record, ok := obj.(*object.Record); ok {
+		value, ok := record.Field(name)
+		if !ok {
+			return newError("record has no field %s", name)
+		}
+		return value
+	}
+	return ev.evalIndexExpression(obj, &object.String{Value: name})
+}
+
 // evalIndexExpression evaluates an index operation. It is compatible with
 // evaluating index operations for arrays and hashes. It will determine the appropriate
 // evaluation method depending on the type of the object. If no type is compatible,
 // it will return an error.
-func evalIndexExpression(left, index object.Object) object.Object {
+func (ev *evaluation) evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	// If left.Type() is an ARRAY_OBJ and index.Type() is an INTEGER_OBJ, then
 	// evaluate the array to return the value at that index.
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+		return ev.evalArrayIndexExpression(left, index)
 	// If left.Type() is an HASH_OBJ, then evaluate the hash
 	// to return the value at that index (key).
 	case left.Type() == object.HASH_OBJ:
-		return evalHashIndexExpression(left, index)
+		return ev.evalHashIndexExpression(left, index)
+	// If left.Type() is a MODULE_OBJ, then return its member named by the index.
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		module := left.(*object.Module)
+		member, ok := module.Member(index.(*object.String).Value)
+		if !ok {
+			return newError("undefined member %s in module %s", index.Inspect(), module.Name)
+		}
+		return member
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -493,7 +1097,7 @@ func evalIndexExpression(left, index object.Object) object.Object {
 // evalHashIndexExpression will return the evaluated value in the Hash (left)
 // at the given key (index). If the key (index) does not exist in the Hash,
 // it will return NULL.
-func evalHashIndexExpression(hash, index object.Object) object.Object {
+func (ev *evaluation) evalHashIndexExpression(hash, index object.Object) object.Object {
 	// assert that the index object is hashable
 	key, ok := index.(object.Hashable)
 	if !ok {
@@ -512,38 +1116,69 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	// This is a valid comparison operation which leads to finding the matching key-value pair.
 	pair, ok := pairs[hashKey]
 	if !ok {
+		if ev.strict {
+			return newError("%s", object.MissingKeyMessage(index))
+		}
 		return NULL
 	}
 
 	return pair.Value
 }
 
+// evalSliceExpression returns a new array with the elements of the array (left) from the low bound
+// up until the high bound (not inclusive), a NULL bound is the start or the end of the array.
+// The slice of a string is the substring between the bounds, which count bytes like len does.
+func (ev *evaluation) evalSliceExpression(left, low, high object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		lo, hi, err := object.SliceBounds("array", low, high, len(left.Elements), ev.strict)
+		if err != nil {
+			return newError("%s", err)
+		}
+		elements := make([]object.Object, hi-lo)
+		copy(elements, left.Elements[lo:hi])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		lo, hi, err := object.SliceBounds("string", low, high, len(left.Value), ev.strict)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return &object.String{Value: left.Value[lo:hi]}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
 // evalArrayIndexExpression will return the evaluated element in the array (left)
-// at the given index.Value. If the index is outside the bounds of the array,
-// it will return NULL.
-func evalArrayIndexExpression(left, index object.Object) object.Object {
+// at the given index.Value, a negative index counts from the end of the array.
+// If the index is outside the bounds of the array, it will return NULL.
+func (ev *evaluation) evalArrayIndexExpression(left, index object.Object) object.Object {
 	// assert that left is an object.Array so that we can access its Elements
 	array := left.(*object.Array)
 	// assert that index is an object.Integer so that we can access its Value
 	idx := index.(*object.Integer).Value
-	maxIdx := int64(len(array.Elements) - 1)
-	if idx > maxIdx || idx < 0 {
+	// a negative index counts from the end of the array
+	element, ok := object.ArrayIndex(idx, len(array.Elements))
+	if !ok {
+		if ev.strict {
+			return newError("%s", object.OutOfRangeMessage(idx, len(array.Elements)))
+		}
 		return NULL
 	}
-	return array.Elements[idx]
+	return array.Elements[element]
 }
 
 // evalHashLiteral evaluates a ast.HashLiteral node to construct an object.Hash.
 // It iterates through all the Pairs in the HashLiteral, evaluating all key and value
 // nodes to construct the new object.Hash.
-func evalHashLiteral(
+func (ev *evaluation) evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+		key := ev.eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -553,7 +1188,7 @@ func evalHashLiteral(
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := ev.eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
@@ -1,6 +1,9 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"os"
 	"testing"
 
 	"github.com/yourfavoritedev/golang-interpreter/lexer"
@@ -28,6 +31,18 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"-7 / 2", -3},
+		{"7 // 2", 3},
+		{"-7 // 2", -4},
+		{"7 // -2", -4},
+		{"-8 // 2", -4},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"~5", -6},
+		{"1 | 2 ^ 7 & 12 << 1 >> 2", 5},
 	}
 
 	for _, tt := range tests {
@@ -36,6 +51,41 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1", 2.5},
+		{"2 * 0.25", 0.5},
+		{"1 / 4.0", 0.25},
+		{"7.5 // 2", 3},
+		{"-7.5 // 2", -4},
+		{"float(2) / 4", 0.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("object has wrong value. got=%g, want=%g", result.Value, tt.expected)
+		}
+	}
+
+	if got := testEval("2.0").Inspect(); got != "2.0" {
+		t.Errorf("wrong Inspect output: %q", got)
+	}
+	if errObj, ok := testEval("1.5 // 0").(*object.Error); !ok || errObj.Message != "division by zero" {
+		t.Errorf("wrong floor division by zero result")
+	}
+}
+
 func TestEvalBooleanExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -47,10 +97,18 @@ func TestEvalBooleanExpressions(t *testing.T) {
 		{"1 > 2", false},
 		{"1 < 1", false},
 		{"1 > 1", false},
+		{"1 <= 1", true},
+		{"1 >= 1", true},
+		{"1 <= 0", false},
+		{"0 >= 1", false},
+		{"1.5 >= 1", true},
+		{"2 <= 1.5", false},
 		{"1 == 1", true},
 		{"1 != 1", false},
 		{"1 == 2", false},
 		{"1 != 2", true},
+		{"1 == 1.0", true},
+		{"0.5 < 1", true},
 		{"true == true", true},
 		{"false == false", true},
 		{"true == false", false},
@@ -60,6 +118,8 @@ func TestEvalBooleanExpressions(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"ab" == "a" + "b"`, true},
+		{`"a" != "b"`, true},
 	}
 
 	for _, tt := range tests {
@@ -155,6 +215,18 @@ func TestIfElseExpression(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) { }", nil},
+		{"let x = if (true) { let a = 1; }; x", nil},
+		{"1 < 2 ? 10 : 20", 10},
+		{"1 > 2 ? 10 : 20", 20},
+		{"let n = 0; n > 0 ? 1 : n < 0 ? -1 : 0", 0},
+		{"let x = 5; let f = fn() { x = true ? 7 : fail(); }; f(); x", 7},
+		{`switch (2) { case 1, 2 { 10 } case "a" { 20 } default { 30 } }`, 10},
+		{`switch ("a") { case 1, 2 { 10 } case "a" { 20 } default { 30 } }`, 20},
+		{`switch ([1]) { case 1, 2 { 10 } case "a" { 20 } default { 30 } }`, 30},
+		{"switch (5) { case 1 { 1 } }", nil},
+		{"let n = 0; switch (n) { case 0 { n = 5; } }; n", 5},
+		{"switch (1) { case 1 { 1 } case fail() { 2 } }", 1},
 	}
 
 	for _, tt := range tests {
@@ -206,6 +278,26 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true;",
 			"type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			"7 / 0",
+			"division by zero",
+		},
+		{
+			"1 // 0",
+			"division by zero",
+		},
+		{
+			"1 << -1",
+			"negative shift count: -1",
+		},
+		{
+			"1.5 & 1",
+			"unknown operator: FLOAT & INTEGER",
+		},
+		{
+			"~true",
+			"unknown operator: ~BOOLEAN",
+		},
 		{
 			"5 + true; 5;",
 			"type mismatch: INTEGER + BOOLEAN",
@@ -267,6 +359,48 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorReports(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedLine       int
+		expectedColumn     int
+		expectedTrace      []string
+		expectedSuggestion string
+	}{
+		{"let counter = 1;\ncountr + 1", 2, 1, []string{""}, "counter"},
+		{"lenn([1])", 1, 1, []string{""}, "len"},
+		{"let counter = 1;\ncountr = 2", 2, 8, []string{""}, "counter"},
+		{"let f = fn(a) {\n  a + true\n};\nf(1)", 2, 5, []string{"", "f"}, ""},
+		{"let g = fn() { fn(x) { x() }(1) };\ng()", 1, 25, []string{"", "g", "<anonymous>"}, ""},
+		// a call with the wrong number of arguments fails in the caller
+		{"let f = fn(a) { a };\nf()", 2, 2, []string{""}, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		located := Report(errObj)
+		if located.Line != tt.expectedLine || located.Column != tt.expectedColumn || located.Suggestion != tt.expectedSuggestion {
+			t.Errorf("wrong error for %q. want=%d:%d suggesting %q, got=%+v",
+				tt.input, tt.expectedLine, tt.expectedColumn, tt.expectedSuggestion, located)
+		}
+		if len(located.Trace) != len(tt.expectedTrace) {
+			t.Errorf("wrong trace for %q. want=%v, got=%v", tt.input, tt.expectedTrace, located.Trace)
+			continue
+		}
+		for i, name := range tt.expectedTrace {
+			if located.Trace[i].Function != name {
+				t.Errorf("wrong frame %d for %q. want=%q, got=%q", i, tt.input, name, located.Trace[i].Function)
+			}
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -276,6 +410,8 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a", 25},
 		{"let a = 5; let b = a; b", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c", 15},
+		{"let a, b = 1, 2; let a, b = b, a; a * 10 + b", 21},
+		{"let f = fn(a, b) { let a, b = b, a; a - b }; f(1, 5)", 4},
 	}
 
 	for _, tt := range tests {
@@ -325,6 +461,110 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestDefaultParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let add = fn(x, y = 10) { x + y }; add(5);", 15},
+		{"let add = fn(x, y = 10) { x + y }; add(5, 1);", 6},
+		{"let f = fn(x, y = x * 2, z = y + 1) { z }; f(1);", 3},
+		{"let f = fn(x, y = x * 2, z = y + 1) { z }; f(1, 5);", 6},
+		{"let n = 1; let f = fn(x = n) { x }; n = 2; f();", 2},
+		{"let f = fn(x = y, y = 1) { x }; f();", nil},
+		{"let f = fn(x, y = 1) { x }; f();", "wrong number of arguments: want=1 to 2, got=0"},
+		{"let f = fn(x, y = 1) { x }; f(1, 2, 3);", "wrong number of arguments: want=1 to 2, got=3"},
+		{"let f = fn(x) { x }; f(1, 2);", "wrong number of arguments: want=1, got=2"},
+		{"let f = fn(x = 1 + true) { x }; f();", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. want=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(x, rest...) { len(rest) }; f(1, 2, 3);", 2},
+		{"let f = fn(x, rest...) { len(rest) }; f(1);", 0},
+		{"let f = fn(rest...) { rest[1] }; f(4, 5, 6);", 5},
+		{"let sum = fn(xs...) { let total = 0; for (x in xs) { total = total + x }; total }; sum(1, 2, 3);", 6},
+		{"let f = fn(x, y = 2, rest...) { x + y + len(rest) }; f(1);", 3},
+		{"let f = fn(x, y = 2, rest...) { x + y + len(rest) }; f(1, 5, 7, 7);", 8},
+		{"let f = fn(x, rest...) { x }; f();", "wrong number of arguments: want=1 or more, got=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. want=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(x, y) { x - y }; f(y: 1, x: 10);", 9},
+		{"let f = fn(x, y) { x - y }; f(10, y: 1);", 9},
+		{"let f = fn(x, y = 2, z = x + y) { x * 100 + y * 10 + z }; f(1, z: 5);", 125},
+		{"let f = fn(x, y = 2, z = x + y) { x * 100 + y * 10 + z }; f(z: 5, x: 3);", 325},
+		{"let f = fn(x, rest...) { x + len(rest) }; f(x: 1);", 1},
+		{"let f = fn(x, y) { x }; f(1, z: 2);", "unknown argument name z, the parameters are (x, y)"},
+		{"let f = fn(x, y) { x }; f(1, x: 2);", "argument x is given twice"},
+		{"let f = fn(x, y = 1) { x }; f(y: 2);", "missing argument for parameter x"},
+		{"let f = fn(x, rest...) { x }; f(1, rest: 2);", "variadic parameter rest cannot be passed by name"},
+		{"len(x: 1);", "built-in functions do not accept named arguments, got x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. want=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 		let newAdder = fn(x) {
@@ -338,6 +578,203 @@ func TestClosures(t *testing.T) {
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestMaxCallDepth(t *testing.T) {
+	program := parser.New(lexer.New(`
+	let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } };
+	countdown(2);
+	fn(n) { countdown(n) }(3);
+	`)).ParseProgram()
+
+	ev := newEvaluation(nil, Options{MaxCallDepth: 3})
+	evaluated := ev.eval(program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := `maximum recursion depth (3) exceeded in function countdown
+call trace (most recent call last):
+  <anonymous>
+  countdown
+  countdown
+  countdown`
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+
+	if len(ev.callStack) != 0 {
+		t.Errorf("call stack not unwound. got=%v", ev.callStack)
+	}
+
+	// the default depth is deep enough for the recursion to finish
+	testIntegerObject(t, testEval("let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } }; countdown(1000)"), 0)
+}
+
+func TestEvalContext(t *testing.T) {
+	program := parser.New(lexer.New(`
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	fib(30);
+	`)).ParseProgram()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluated := EvalContext(ctx, program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "context canceled" {
+		t.Fatalf("expected cancellation error. got=%v", evaluated)
+	}
+
+	// evaluations without a context are not affected by the cancelled one
+	testIntegerObject(t, testEval("let f = fn(x) { x }; f(1)"), 1)
+}
+
+func TestEvalWith(t *testing.T) {
+	program := parser.New(lexer.New(`[1, 2][5]`)).ParseProgram()
+
+	evaluated := EvalWith(context.Background(), program, object.NewEnvironment(), Options{Strict: true})
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "index 5 out of range for array of length 2" {
+		t.Fatalf("expected index error. got=%v", evaluated)
+	}
+
+	// the options only apply to the evaluation they were given to
+	if evaluated := Eval(program, object.NewEnvironment()); evaluated != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestSignalHandlers(t *testing.T) {
+	_, err := object.RegisterBuiltin("eval_test_interrupt", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		object.RaiseSignal(os.Interrupt)
+		return object.NULL
+	}})
+	if err != nil {
+		t.Fatalf("built-in function not registered: %s", err)
+	}
+
+	program := parser.New(lexer.New(`
+	let interrupts = 0;
+	on_signal("SIGINT", fn() { interrupts = interrupts + 1 });
+	eval_test_interrupt();
+	eval_test_interrupt();
+	let f = fn() { interrupts };
+	f();
+	`)).ParseProgram()
+	testIntegerObject(t, EvalContext(context.Background(), program, object.NewEnvironment()), 2)
+	// the handlers of an evaluation are removed when it returns
+	if object.HandlesSignal(os.Interrupt) {
+		t.Errorf("the handler outlived its evaluation")
+	}
+
+	program = parser.New(lexer.New(`on_signal("SIGINT", fn() { exit(3) }); eval_test_interrupt(); let f = fn() { 1 }; f()`)).ParseProgram()
+	errObj, ok := EvalContext(context.Background(), program, object.NewEnvironment()).(*object.Error)
+	if !ok || !errObj.Exit || errObj.Code != 3 {
+		t.Fatalf("expected the handler to exit with status 3. got=%v", errObj)
+	}
+
+	// shared signals keep the handlers for the next evaluation
+	signals := object.NewSignals()
+	defer signals.Stop()
+	env := object.NewEnvironment()
+	program = parser.New(lexer.New(`let interrupts = 0; on_signal("SIGINT", fn() { interrupts = interrupts + 1 });`)).ParseProgram()
+	EvalWith(context.Background(), program, env, Options{Signals: signals})
+	program = parser.New(lexer.New(`eval_test_interrupt(); let f = fn() { interrupts }; f()`)).ParseProgram()
+	testIntegerObject(t, EvalWith(context.Background(), program, env, Options{Signals: signals}), 1)
+}
+
+func TestLoggerOption(t *testing.T) {
+	l := lexer.New(`log_level("warn"); log_info("hidden"); pmap(fn(x) { log_warn("worker") }, [1])`)
+	program := parser.New(l).ParseProgram()
+	var out bytes.Buffer
+	logger := &object.Logger{Writer: &out, Level: object.LogInfo}
+	result := EvalWith(nil, program, object.NewEnvironment(), Options{Logger: logger})
+	if errObj, ok := result.(*object.Error); ok {
+		t.Fatalf("evaluation failed: %s", errObj.Message)
+	}
+
+	if out.String() != "level=warn msg=\"worker\"\n" {
+		t.Errorf("wrong log output: %q", out.String())
+	}
+	if logger.Level != object.LogWarn || object.Log.Level != object.LogInfo {
+		t.Errorf("wrong levels. got=%s for the evaluation and %s for the default logger", logger.Level, object.Log.Level)
+	}
+}
+
+func TestParallelMap(t *testing.T) {
+	// the functions run on workers follow the rules of the VM, so a program behaves the same in both engines
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`pmap(fn(x) { x * 2 }, [1, 2, 3, 4, 5], 2)`, "[2, 4, 6, 8, 10]"},
+		{`pmap(fn(x) { x * x }, [1, 2, 3], 8)`, "[1, 4, 9]"},
+		{`pmap(fn(x) { x + 1 }, [])`, "[]"},
+		{`pmap(len, ["a", "bc", "def"], 3)`, "[1, 2, 3]"},
+		{`let offset = 10; let f = fn(n) { pmap(fn(x) { x + n }, [1, 2, 3], 3) }; f(offset)`, "[11, 12, 13]"},
+		{`pmap(fn(x) { let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(x) }, [10, 15, 20], 3)`,
+			"[55, 610, 6765]"},
+		{`let limit = 2; pmap(fn(x) { x > limit }, [1, 2, 3])`,
+			"cannot run <anonymous> on several workers: it uses global variables"},
+		{`let limit = 2; let above = fn(x) { x > limit }; pmap(fn(x) { above(x) }, [1, 2, 3])`,
+			"cannot run <anonymous> on several workers: it uses global variables"},
+		{`let count = 0; let f = fn(x) { count = count + x }; pmap(f, [1, 2])`,
+			"cannot run f on several workers: it uses global variables"},
+		{`pmap(memoize(fn(x) { x }), [1, 2, 3])`,
+			"cannot run a built-in function on several workers: it keeps state between calls"},
+		{`pmap(fn(x) { x + "a" }, [1, 2])`, "type mismatch: INTEGER + STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != tt.expected {
+				t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+			}
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestWhileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { i = i + 1; } i", 5},
+		{"let f = fn() { while (true) { return 42; } }; f()", 42},
+		{"while (1 + true) { 1 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"while (false) { 1 }", nil},
+		{"let i = 0; while (true) { i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"let i = 0; let n = 0; while (i < 5) { i = i + 1; if (i == 2) { continue; } n = n + i; }; n", 13},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	// an endless loop without calls is stopped by the cancellation checks
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	program := parser.New(lexer.New(`while (true) { }`)).ParseProgram()
+	if errObj, ok := EvalContext(ctx, program, object.NewEnvironment()).(*object.Error); !ok || errObj.Message != "context canceled" {
+		t.Fatalf("expected cancellation error")
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 	evaluated := testEval(input)
@@ -372,8 +809,13 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len({"a": 1, "b": 2})`, 2},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len(1)`, "argument to `len` not supported, got=INTEGER"},
+		{`len(record{x: 1, y: 2})`, 2},
+		{`len(range(5))`, 5},
+		{`len(range(10, 0, -3))`, 4},
+		{`len(filter(fn(x) { x > 1 }, range(3)))`, "length of a lazy iterator is unknown, collect it first"},
 		{`let arr = [1,2,3]; first(arr);`, 1},
 		{`first()`, "wrong number of arguments. got=0, want=1"},
 		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
@@ -404,6 +846,69 @@ func TestBuiltinFunctions(t *testing.T) {
 		},
 		{`push()`, "wrong number of arguments. got=0, want=2"},
 		{`push(1, 2)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`parse_toml("name = 'monkey'
+		[server]
+		port = 8080")["server"]["port"]`, 8080},
+		{`parse_toml("ratio = 0.5")["ratio"] == 0.5`, true},
+		{`parse_toml("[a]
+		[a]")`, "toml: line 2: table \"a\" is already defined"},
+		{`parse_toml("port = ")`, "toml: line 1: expected a value"},
+		{`parse_toml(1)`, "argument to `parse_toml` must be STRING, got INTEGER"},
+		{`len(render("{{a}}-{{b}}", {"a": "x", "b": 10}))`, 4},
+		{`render("{{missing}}", {})`, "undefined template key: missing"},
+		{`get({"a": 1}, "b", 5)`, 5},
+		{`dig({"a": {"b": [1, {"c": 3}]}}, ["a", "b", 1, "c"])`, 3},
+		{`dig({"a": 1}, ["a", "b"], 7)`, 7},
+		{`get({})`, "wrong number of arguments. got=1, want=2 or 3"},
+		{`len(group_by([1, 2, 3, 4], fn(x) { x > 2 })[true])`, 2},
+		{`sort_by([3, 1, 2], fn(x) { -x })[2]`, 1},
+		{`len(unique([1, 2, 1, 3, 2]))`, 3},
+		{`min_by([3, 1, 2], fn(x) { x })`, 1},
+		{`max_by([3, 1, 2], fn(x) { x })`, 3},
+		{`sort_by([1, 2], fn(x) { x + "a" })`, "type mismatch: INTEGER + STRING"},
+		{`unique(1)`, "first argument to `unique` must be ARRAY, got INTEGER"},
+		{`len(collect(take(5, map(fn(x) { x * x }, range(1000000)))))`, 5},
+		{`to_array(drop(2, take(4, filter(fn(x) { x > 10 }, range(1, 9223372036854775807)))))[1]`, 14},
+		{`collect(map(fn(x) { x + "a" }, range(2)))`, "type mismatch: INTEGER + STRING"},
+		{`range(1, 2, 0)`, "step of `range` must not be 0"},
+		{`map(fn(x) { x }, 1)`, "second argument to `map` must be ARRAY or ITERATOR, got INTEGER"},
+		{`let fib = memoize(fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }); fib(80)`, 23416728348467685},
+		{`memoize(fn(x) { x })({})`, "unusable as memoize key: HASH"},
+		{`apply(fn(a, b) { a - b }, [5, 3])`, 2},
+		{`let add = fn(a, b, c) { a + b + c }; partial(add, 1, 2)(3)`, 6},
+		{`apply(fn(a) { a }, 1)`, "second argument to `apply` must be ARRAY, got INTEGER"},
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(inc, double)(5)`, 11},
+		{`compose(identity, const_fn(3))()`, 3},
+		{`compose()`, "wrong number of arguments. got=0, want at least 1"},
+		{`try(fn() { 1 + 2 })["ok"]`, 3},
+		{`len(try(fn() { 1 + "a" })["error"])`, len("type mismatch: INTEGER + STRING")},
+		{`try(fn() { 1 / 0 })["error"] == "division by zero"`, true},
+		{`try(1)`, "argument to `try` must be a function, got INTEGER"},
+		{`len(strings.upper("monkey"))`, 6},
+		{`let m = math; m.max(3, 4)`, 4},
+		{`{"a": 2}.a`, 2},
+		{`math.sqrt`, "undefined member sqrt in module math"},
+		{`strings.repeat`, "undefined member repeat in module strings"},
+		{`arity(fn(a, b, c) { a })`, 3},
+		{`len(params(fn(first, second) { first })[1])`, 6},
+		{`len(source(fn(x) { x }))`, len("fn(x) {\nx\n}")},
+		{`params("fn")`, "argument to `params` must be a function, got STRING"},
+		{"### Doubles x.\nlet double = fn(x) { x * 2 }; len(doc(double))", len("Doubles x.")},
+		{`doc(1)`, "argument to `doc` must be a function, got INTEGER"},
+		{`len(to_fixed(5, 2))`, 4},
+		{`len(format_number(1234567))`, 9},
+		{`divmod(-7, 2)[0]`, -4},
+		{`divmod(-7, 2)[1]`, 1},
+		{`divmod(1, 0)`, "division by zero"},
+		{`divmod("7", 2)`, "first argument to `divmod` must be INTEGER, got STRING"},
+		{`on_signal("SIGKILL", fn() { 1 })`, "unsupported signal \"SIGKILL\", want SIGHUP, SIGINT or SIGTERM"},
+		{`on_signal("SIGTERM", 1)`, "second argument to `on_signal` must be a function, got INTEGER"},
+		{`on_signal("SIGINT", fn() { 1 })`, "`on_signal` must be called by a program run with a context"},
+		{`to_fixed("5", 2)`, "first argument to `to_fixed` must be a number, got STRING"},
+		{`unwrap(ok(5))`, 5},
+		{`unwrap_or(try(fn() { 1 + "a" }), 7)`, 7},
+		{`unwrap(err("boom"))`, "unwrap of an error result: boom"},
+		{`is_ok(1)`, "argument to `is_ok` must be a result, {\"ok\": value} or {\"error\": message}, got 1"},
 	}
 
 	for _, tt := range tests {
@@ -412,6 +917,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
 		case string:
 			errObj, ok := evaluated.(*object.Error)
 			if !ok {
@@ -425,6 +932,30 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestRuntimeStats(t *testing.T) {
+	stats := `let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; let before = runtime_stats(); f(9); let after = runtime_stats();`
+	testIntegerObject(t, testEval(stats+`after["frames"] - before["frames"]`), 10)
+	testBooleanObject(t, testEval(stats+`after["instructions"] - before["instructions"] > 10 * 8`), true)
+	testBooleanObject(t, testEval(stats+`after["heap_objects"] > 0 && after["memory"]["num_gc"] >= 0`), true)
+
+	// the work of an evaluation calling no built-in function is reported when it ends
+	before := testEval(`runtime_stats()["frames"]`).(*object.Integer).Value
+	testEval(`let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(9)`)
+	testIntegerObject(t, testEval(`runtime_stats()["frames"]`), before+10)
+}
+
+func TestRegisteredBuiltin(t *testing.T) {
+	_, err := object.RegisterBuiltin("eval_test_square", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		value := args[0].(*object.Integer).Value
+		return &object.Integer{Value: value * value}
+	}})
+	if err != nil {
+		t.Fatalf("built-in function not registered: %s", err)
+	}
+
+	testIntegerObject(t, testEval("eval_test_square(7)"), 49)
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -443,6 +974,186 @@ func TestArrayLiterals(t *testing.T) {
 	testIntegerObject(t, result.Elements[2], 6)
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && false", false},
+		{"true && 2", 2},
+		{"1 && 2", 2},
+		{"false || 2", 2},
+		{"1 || 2", 1},
+		{"let n = if (false) { 1 }; n || 3", 3},
+		{"let n = if (false) { 1 }; n && 3", nil},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		// the right operand is not evaluated when the left one decides the result
+		{"false && len(1)", false},
+		{"true || len(1)", true},
+		{"let x = 0; false && (x = 1); true || (x = 2); true && (x = 3); x", 3},
+		{"true && len(1)", "argument to `len` not supported, got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = x + 1; x", 2},
+		{"let x = 1; x = 5", 5},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let i = 0; let total = 0; while (i < 4) { total = total + i; i = i + 1; } total", 6},
+		{"let x = 1; let f = fn() { x = x + 1; }; f(); f(); x", 3},
+		{"let f = fn(n) { let m = n; m = m * 2; m }; f(4)", 8},
+		{"let i = 0; while (i < 3) { i++; } i", 3},
+		{"let i = 5; let j = i--; i + j", 8},
+		{"x++", "undefined variable: x"},
+		{"x = 1", "undefined variable: x"},
+		{"len = 1", "cannot assign to built-in function len"},
+		{"const x = 1; x = 2", "cannot assign to constant x"},
+		{"const a, b = 1, 2; let f = fn() { b = 3 }; f()", "cannot assign to constant b"},
+		{"const x = 1; let x = 2; x = 3; x", 3},
+		// the error happens when the assignment runs
+		{"const x = 1; if (false) { x = 2 }; x", 1},
+		{"let f = fn() { let y = 2; fn() { y = 3 } }; f()()", "cannot assign to y, it is bound by an enclosing function"},
+		// the target is checked before the value is evaluated
+		{"x = len(1)", "undefined variable: x"},
+		{"let a, b = 1, 2; a, b = b, a; a * 10 + b", 21},
+		{"let f = fn(n) { let a, b = 0, 1; while (n > 0) { a, b = b, a + b; n--; } a }; f(10)", 55},
+		{"let a = 1; a, b = 2, len(1)", "undefined variable: b"},
+		{"const a = 1; let b = 2; b, a = a, b", "cannot assign to constant a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; if (true) { let x = x + 1; x }", 2},
+		{"let x = 1; if (true) { let x = x + 1; }; x", 1},
+		{"if (true) { let y = 1; }; y", "identifier not found: y"},
+		{"let n = 0; while (n < 2) { let m = n; n = m + 1; }; m", "identifier not found: m"},
+		{"for (x in [1, 2]) { x }; x", "identifier not found: x"},
+		{"switch (1) { case 1 { let y = 2; } }; y", "identifier not found: y"},
+		// blocks belong to the function they are in, it can assign to their bindings
+		{"let f = fn() { let n = 1; if (true) { let m = 2; if (true) { n = n + m; m = 5; } } n }; f()", 3},
+		{"if (true) { let n = 1; let f = fn() { n = 2 }; f(); n }", 2},
+		{"let f = fn() { let n = 1; fn() { if (true) { n = 2 } } }; f()()", "cannot assign to n, it is bound by an enclosing function"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let total = 0; for (x in [1, 2, 3]) { total = total + x; } total", 6},
+		{"let total = 0; for (i, x in [10, 20]) { total = total + i; } total", 1},
+		{`let keys = ""; for (k, v in {"b": 1, "a": 2, "c": 3}) { keys = keys + k; } {"abc": true}[keys]`, true},
+		{"let total = 0; for (x in range(4)) { total = total + x; } total", 6},
+		{"let f = fn() { for (x in [1, 2]) { return x; } }; f()", 1},
+		{"for (x in []) { 1 }", nil},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break; } n = n + x; }; n", 3},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { continue; } n = n + x; }; n", 7},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2]) { if (y > x) { break; } n = n + 1; } }; n", 3},
+		{"for (x in 1) { x }", "cannot iterate over INTEGER, want ARRAY, HASH or ITERATOR"},
+		{"for (x in map(fn(x) { x + true }, range(3))) { x }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSafeNavigation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let n = if (false) { 1 }; n?[0]", nil},
+		{"[1, 2]?[1]", 2},
+		{`{"a": 1}?["a"]`, 1},
+		{`let h = {"a": {"b": 2}}; h?["c"]?["b"]`, nil},
+		// the index is not evaluated when the object is null
+		{"let n = if (false) { 1 }; n?[len(1)]", nil},
+		{"1?[0]", "index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestArrayIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -486,6 +1197,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -501,6 +1220,96 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3][:]", "[1, 2, 3]"},
+		{"[1, 2, 3][1:99]", "[2, 3]"},
+		{"[1, 2, 3][2:1]", "[]"},
+		{"[1, 2, 3][-2:]", "[2, 3]"},
+		{"[1, 2, 3][:-1]", "[1, 2]"},
+		{"[1, 2, 3][-5:-2]", "[1]"},
+		{"let n = if (false) { 1 }; n?[len(1):]", "null"},
+		{`"monkey"[1:4]`, "onk"},
+		{`"monkey"[:2] + "monkey"[2:]`, "monkey"},
+		{`"monkey"[5:2]`, ""},
+		{`"monkey"[-3:]`, "key"},
+		{`1[1:]`, "ERROR: slice operator not supported: INTEGER"},
+		{`[1]["a":]`, "ERROR: slice bounds must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{":a == :a", true},
+		{":a != :b", true},
+		{`let colors = {:red: 1, :green: 2}; colors[:green]`, 2},
+		{`let tag = fn() { :done }; tag() == :done`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	if got := testEval(":red").Inspect(); got != ":red" {
+		t.Errorf("wrong Inspect output: %q", got)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let p = record{x: 1, y: 2}; p.x + p.y", 3},
+		{"let getY = fn(r) { r.y }; getY(record{y: 5, x: 1})", 5},
+		{"record{x: 1, y: \"a\"} == record{x: 1, y: \"a\"}", true},
+		{"record{x: 1, y: 2} == record{y: 2, x: 1}", false},
+		{"record{x: 1} != record{x: 2}", true},
+		{"record{x: 1}.z", "record has no field z"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+
+	if got := testEval("record{x: 1, y: [2]}").Inspect(); got != "record{x: 1, y: [2]}" {
+		t.Errorf("wrong Inspect output: %q", got)
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -586,3 +1395,48 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestPrepare(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello, " + "World"`, "Hello, World"},
+		{"60 * 60 * 24", "86400"},
+		{"-5 + 1.5", "-3.5"},
+		{"!(1 < 2) || x", "(false || x)"},
+		{"1 < 2 && 3", "3"},
+		{"x + 2 * 3", "(x + 6)"},
+		{`fn(a) { a + (1 + 2) }`, "fn(a) (a + 3)"},
+		{"1 + 2 > 2 ? x : y", "x"},
+		{"[1 + 1, x ? 1 : 2 - 3]", "[2, (x ? 1 : -1)]"},
+		// failing operations are kept, evaluating them reports the error
+		{"if (false) { 1 / 0 }", "iffalse (1 / 0)"},
+		{"1 // 0", "(1 // 0)"},
+		{`1 + "a"`, "(1 + a)"},
+	}
+
+	for _, tt := range tests {
+		program := Prepare(parser.New(lexer.New(tt.input)).ParseProgram())
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	// a prepared program evaluates like the program it was prepared from, however many times it runs
+	inputs := []string{
+		`let greet = fn(name) { "Hello, " + "dear " + name }; greet("you")`,
+		"let f = fn(n) { n * (2 + 3) }; f(2) + f(3)",
+		"1 + 2 > 2 ? 10 - 1 : 0",
+		`1 + "a"`,
+	}
+	for _, input := range inputs {
+		expected := testEval(input).Inspect()
+		program := Prepare(parser.New(lexer.New(input)).ParseProgram())
+		for i := 0; i < 2; i++ {
+			if got := Eval(Prepare(program), object.NewEnvironment()).Inspect(); got != expected {
+				t.Errorf("wrong result for prepared %q. want=%q, got=%q", input, expected, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// fork creates a worker calling fn for a parallel built-in function like pmap, it fails when fn cannot run
+// concurrently with other workers. Every worker is an evaluation of its own, which shares the environments
+// of the functions with the evaluation waiting for the built-in function to return. The functions may not
+// use globals, the only bindings an assignment of another worker could change, so the rule is the one of the VM.
+func (ev *evaluation) fork(fn object.Object) (object.CallFunction, *object.Error) {
+	if err := checkParallel(fn, map[*object.Function]bool{}); err != nil {
+		return nil, newError("cannot run %s on several workers: %s", callableName(fn), err)
+	}
+
+	worker := newEvaluation(ev.ctx, Options{Strict: ev.strict, MaxCallDepth: ev.maxCallDepth - len(ev.callStack), Logger: ev.logger})
+	return func(fn object.Object, args ...object.Object) object.Object {
+		defer worker.reportCounts()
+		return worker.callFunction(fn, args...)
+	}, nil
+}
+
+// checkParallel returns an error when fn cannot run on a worker: its body, the bodies of the functions it defines
+// or the functions it captured read or write globals, which another worker could be writing, or fn is a built-in
+// function keeping state between calls, like a memoized function.
+func checkParallel(fn object.Object, checked map[*object.Function]bool) error {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if checked[fn] {
+			return nil
+		}
+		checked[fn] = true
+
+		for _, name := range freeNames(fn) {
+			owner := fn.Env.Owner(name)
+			if owner == nil {
+				continue
+			}
+			value, _ := owner.Get(name)
+			// a function bound by a let statement calls itself by its name, like the closures of the VM
+			if value == fn && name == fn.Name {
+				continue
+			}
+			if owner.Global() {
+				return fmt.Errorf("it uses global variables")
+			}
+			if err := checkParallel(value, checked); err != nil {
+				return err
+			}
+		}
+	case *object.Builtin:
+		if fn.KeepsState() {
+			return fmt.Errorf("it keeps state between calls")
+		}
+	}
+	return nil
+}
+
+// freeNames returns the names the body and the default values of the function read or assign without binding
+// them itself, with a parameter or a let statement of its own or of a function it defines
+func freeNames(fn *object.Function) []string {
+	bound := make(map[string]bool)
+	for _, param := range fn.Parameters {
+		bound[param.Value] = true
+	}
+	var used []string
+	visit := func(node ast.Node) (ast.Node, error) {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			bound[node.Name.Value] = true
+		case *ast.MultiLetStatement:
+			for _, name := range node.Names {
+				bound[name.Value] = true
+			}
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				bound[param.Value] = true
+			}
+		case *ast.ForExpression:
+			if node.Key != nil {
+				bound[node.Key.Value] = true
+			}
+			bound[node.Value.Value] = true
+		case *ast.Identifier:
+			used = append(used, node.Value)
+		case *ast.AssignExpression:
+			used = append(used, node.Name.Value)
+		case *ast.MultiAssignStatement:
+			for _, name := range node.Names {
+				used = append(used, name.Value)
+			}
+		}
+		return node, nil
+	}
+	// the modifier returns every node unchanged, it only inspects the tree
+	for _, value := range fn.Defaults {
+		ast.Modify(value, visit)
+	}
+	ast.Modify(fn.Body, visit)
+
+	var free []string
+	for _, name := range used {
+		if !bound[name] {
+			free = append(free, name)
+		}
+	}
+	return free
+}
+
+// callableName returns the name of a function for error messages
+func callableName(fn object.Object) string {
+	if fn, ok := fn.(*object.Function); ok && fn.Name != "" {
+		return fn.Name
+	}
+	if _, ok := fn.(*object.Builtin); ok {
+		return "a built-in function"
+	}
+	return object.AnonymousFunctionName
+}
@@ -0,0 +1,108 @@
+package evaluator
+
+import (
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/token"
+)
+
+// Prepare simplifies the subtrees of the program that only involve literals, once, so evaluating the program
+// again and again does not repeat the work. Operators applied to literals are replaced with their result,
+// `"Hello, " + "World"` becomes a single string literal and `60 * 60 * 24` a single integer, and a conditional
+// expression with a literal condition is replaced with the branch it chooses. An operation that fails, like
+// `1 / 0` or `1 + "a"`, is left for the evaluation to report. The program is modified in place and returned,
+// preparing it again changes nothing. Embedders evaluating the same program many times call Prepare after
+// parsing it, Eval gives the same results with or without it.
+func Prepare(program *ast.Program) *ast.Program {
+	// the simplification never fails, every node is replaced with a node of the same kind
+	ast.Modify(program, simplify)
+	return program
+}
+
+// simplify replaces an operation on literals with the literal of its result, its operands are already simplified
+func simplify(node ast.Node) (ast.Node, error) {
+	switch node := node.(type) {
+	case *ast.PrefixExpression:
+		right, ok := literalValue(node.Right)
+		if !ok {
+			return node, nil
+		}
+		return literalNode(node, evalPrefixExpression(node.Operator, right), node.Token), nil
+	case *ast.InfixExpression:
+		left, ok := literalValue(node.Left)
+		if !ok {
+			return node, nil
+		}
+		right, ok := literalValue(node.Right)
+		if !ok {
+			return node, nil
+		}
+		// an integer division by zero is left to run time like the other failing operations,
+		// evaluating it here would stop the program even when the division is never reached
+		if zero, ok := right.(*object.Integer); ok && zero.Value == 0 && node.Operator == "/" {
+			return node, nil
+		}
+		// like in Eval, && and || have the value of the operand that decides the result
+		switch node.Operator {
+		case "&&":
+			if !isTruthy(left) {
+				return node.Left, nil
+			}
+			return node.Right, nil
+		case "||":
+			if isTruthy(left) {
+				return node.Left, nil
+			}
+			return node.Right, nil
+		}
+		return literalNode(node, evalInfixExpression(node.Operator, left, right), node.Token), nil
+	case *ast.ConditionalExpression:
+		condition, ok := literalValue(node.Condition)
+		if !ok {
+			return node, nil
+		}
+		if isTruthy(condition) {
+			return node.Consequence, nil
+		}
+		return node.Alternative, nil
+	}
+	return node, nil
+}
+
+// literalValue returns the value of a literal expression, booleans are the shared TRUE and FALSE
+func literalValue(node ast.Expression) (object.Object, bool) {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}, true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}, true
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value), true
+	}
+	return nil, false
+}
+
+// literalNode returns the literal expression evaluating to the value, located at the token of the operation
+// it replaces. The operation itself is kept when the value has no literal, an error in particular.
+func literalNode(operation ast.Expression, value object.Object, at token.Token) ast.Expression {
+	position := func(t token.TokenType, literal string) token.Token {
+		return token.Token{Type: t, Literal: literal, Line: at.Line, Column: at.Column}
+	}
+
+	switch value := value.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: position(token.INT, value.Inspect()), Value: value.Value}
+	case *object.Float:
+		return &ast.FloatLiteral{Token: position(token.FLOAT, value.Inspect()), Value: value.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: position(token.STRING, value.Value), Value: value.Value}
+	case *object.Boolean:
+		if value.Value {
+			return &ast.Boolean{Token: position(token.TRUE, "true"), Value: true}
+		}
+		return &ast.Boolean{Token: position(token.FALSE, "false"), Value: false}
+	}
+	return operation
+}
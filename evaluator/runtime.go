@@ -0,0 +1,86 @@
+package evaluator
+
+import "github.com/yourfavoritedev/golang-interpreter/object"
+
+// The functions in this file expose the semantics of the evaluator operation by operation,
+// for Go programs generated by the transpile package. They give the transpiled program
+// exactly the behavior and the error messages of evaluating it. Every operation is
+// evaluated on its own, without a context and in the default mode.
+
+// Apply calls fn with the given arguments, as a call expression does
+func Apply(fn object.Object, args []object.Object) object.Object {
+	return newEvaluation(nil, Options{}).applyFunction(fn, args)
+}
+
+// Infix applies the infix operator to the already evaluated operands
+func Infix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Prefix applies the prefix operator to the already evaluated operand
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Index looks up the index in left, as an index expression does
+func Index(left, index object.Object) object.Object {
+	return newEvaluation(nil, Options{}).evalIndexExpression(left, index)
+}
+
+// Slice returns the slice of left between the bounds, as a slice expression does. An omitted bound is object.NULL.
+func Slice(left, low, high object.Object) object.Object {
+	return newEvaluation(nil, Options{}).evalSliceExpression(left, low, high)
+}
+
+// Iterate returns a cursor over the collection as a for expression walks it, or the error when it is not a collection
+func Iterate(obj object.Object) (*object.Cursor, object.Object) {
+	cursor, errObj := object.Iterate(obj)
+	if errObj != nil {
+		return nil, errObj
+	}
+	return cursor, nil
+}
+
+// Next returns the key and the value of the next element of the cursor, it reports false once the cursor is exhausted
+func Next(cursor *object.Cursor) (object.Object, object.Object, bool) {
+	return cursor.Next(newEvaluation(nil, Options{}).callFunction)
+}
+
+// Member looks up the named field of a record, the member of a module or the string key of a hash, as a member expression does
+func Member(obj object.Object, name string) object.Object {
+	return newEvaluation(nil, Options{}).evalMemberExpression(obj, name)
+}
+
+// Hash builds a hash from already evaluated keys and the values at the same positions
+func Hash(keys, values []object.Object) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+	for i, key := range keys {
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: values[i]}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+// IsTruthy reports whether the object counts as true in a condition
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// IsError reports whether the object is an error, which stops the program
+func IsError(obj object.Object) bool {
+	return isError(obj)
+}
+
+// Global returns the built-in function or the module with the given name, or nil when there is none
+func Global(name string) object.Object {
+	if builtin := object.GetBuiltInByName(name); builtin != nil {
+		return builtin
+	}
+	if module := object.GetModuleByName(name); module != nil {
+		return module
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Package feature keeps track of the language features that can be switched on and off,
+// letting large syntax additions ship behind a flag (--feature=match) before they are stable.
+package feature
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Status describes how far along a feature is
+type Status int
+
+const (
+	// Experimental features are only available when they are enabled explicitly
+	Experimental Status = iota
+	// Stable features are always enabled, enabling them explicitly has no effect
+	Stable
+	// Deprecated features are still available but using them results in a warning
+	Deprecated
+)
+
+// Feature describes a language feature that can be gated
+type Feature struct {
+	Name        string
+	Description string
+	Status      Status
+}
+
+// known contains every registered feature by name
+var known = map[string]*Feature{}
+
+// Register adds a feature to the known features, it panics when the name is already registered
+// since features are registered once by the code implementing them.
+func Register(name, description string, status Status) *Feature {
+	if _, ok := known[name]; ok {
+		panic(fmt.Sprintf("feature %s is already registered", name))
+	}
+	f := &Feature{Name: name, Description: description, Status: status}
+	known[name] = f
+	return f
+}
+
+// Lookup returns the registered feature with the given name
+func Lookup(name string) (*Feature, bool) {
+	f, ok := known[name]
+	return f, ok
+}
+
+// Names returns the names of all registered features in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set is the collection of explicitly enabled features, a nil Set enables none of them
+type Set map[string]bool
+
+// Parse builds a Set from a comma separated list of feature names (match,records).
+// It returns an error for names that are not registered.
+func Parse(list string) (Set, error) {
+	set := Set{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := known[name]; !ok {
+			if len(known) == 0 {
+				return nil, fmt.Errorf("unknown feature %q, no features are registered", name)
+			}
+			return nil, fmt.Errorf("unknown feature %q, known features: %s", name, strings.Join(Names(), ", "))
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// Enabled reports whether the feature can be used: it is stable or deprecated, or it was enabled explicitly
+func (s Set) Enabled(name string) bool {
+	f, ok := known[name]
+	if !ok {
+		return false
+	}
+	return f.Status != Experimental || s[name]
+}
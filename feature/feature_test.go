@@ -0,0 +1,38 @@
+package feature
+
+import "testing"
+
+func TestSet(t *testing.T) {
+	Register("test_experimental", "an experimental feature", Experimental)
+	Register("test_stable", "a stable feature", Stable)
+	Register("test_deprecated", "a deprecated feature", Deprecated)
+
+	set, err := Parse("test_experimental, ")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		set      Set
+		name     string
+		expected bool
+	}{
+		{set, "test_experimental", true},
+		{nil, "test_experimental", false},
+		{nil, "test_stable", true},
+		{nil, "test_deprecated", true},
+		{set, "unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.set.Enabled(tt.name); got != tt.expected {
+			t.Errorf("Enabled(%q) with %v wrong. want=%t, got=%t", tt.name, tt.set, tt.expected, got)
+		}
+	}
+
+	_, err = Parse("test_stable,missing")
+	expected := `unknown feature "missing", known features: test_deprecated, test_experimental, test_stable`
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%v", expected, err)
+	}
+}
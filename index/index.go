@@ -0,0 +1,317 @@
+// Package index maps the names of a Monkey project to the places they are defined, it backs `monkey defs`.
+// Every file added to an Index is walked once: the bindings of its let statements, function parameters and
+// for loops are recorded with their position, and every reference is resolved the way the engines resolve
+// it, to the closest binding of the enclosing blocks and functions. A name a file does not bind is looked up in the
+// bindings at the top of the other files of the project, so a definition can be found across files.
+package index
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+)
+
+// Location is a position in a file, lines and columns start at 1
+type Location struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String returns the location as file:line:column
+func (l Location) String() string {
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// Kind tells how a name is bound
+type Kind string
+
+const (
+	Function  Kind = "function"
+	Value     Kind = "value"
+	Parameter Kind = "parameter"
+	Loop      Kind = "loop variable"
+)
+
+// Definition is a binding of a name
+type Definition struct {
+	Name string
+	Kind Kind
+	Location
+	// Global reports whether the name is bound at the top of its file
+	Global bool
+}
+
+// reference is the use of a name. The definition of a name the file does not bind itself
+// depends on the other files of the project, it is only resolved when it is looked up.
+type reference struct {
+	name     string
+	location Location
+	// definition is nil when the name is not bound in the file
+	definition *Definition
+}
+
+// Index holds the definitions and the references of every file added to it
+type Index struct {
+	// globals holds the bindings at the top of the files by name, in the order of the files
+	globals    map[string][]*Definition
+	references []reference
+}
+
+// New creates an empty Index
+func New() *Index {
+	return &Index{globals: make(map[string][]*Definition)}
+}
+
+// scope holds the bindings of a function or a block, the bindings at the top of a file are the outermost scope.
+// Like in the engines, the blocks of if expressions, switch expressions and loops have their own scope.
+type scope struct {
+	outer    *scope
+	bindings map[string]*Definition
+}
+
+// resolve returns the closest binding of the name, nil when no enclosing scope binds it
+func (s *scope) resolve(name string) *Definition {
+	for current := s; current != nil; current = current.outer {
+		if definition, ok := current.bindings[name]; ok {
+			return definition
+		}
+	}
+	return nil
+}
+
+// walker records the definitions and the references of a single file
+type walker struct {
+	index *Index
+	file  string
+	scope *scope
+	// late holds the references resolved once the whole file is walked: a function can call
+	// a function bound after it at the top of the file, which exists by the time it is called
+	late []int
+}
+
+// Add walks the program parsed from the file and records its definitions and references
+func (ix *Index) Add(file string, program *ast.Program) {
+	w := &walker{index: ix, file: file, scope: &scope{bindings: make(map[string]*Definition)}}
+	w.statements(program.Statements)
+
+	globals := w.scope
+	for _, i := range w.late {
+		if definition, ok := globals.bindings[ix.references[i].name]; ok {
+			ix.references[i].definition = definition
+		}
+	}
+	// the last binding of a name at the top of a file is the one other files see
+	for name, definition := range globals.bindings {
+		ix.globals[name] = append(ix.globals[name], definition)
+	}
+}
+
+// Globals returns the bindings at the top of every file, ordered by name and then by file
+func (ix *Index) Globals() []Definition {
+	names := make([]string, 0, len(ix.globals))
+	for name := range ix.globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	definitions := []Definition{}
+	for _, name := range names {
+		definitions = append(definitions, ix.Lookup(name)...)
+	}
+	return definitions
+}
+
+// Lookup returns the bindings of the name at the top of every file, in the order the files were added
+func (ix *Index) Lookup(name string) []Definition {
+	definitions := []Definition{}
+	for _, definition := range ix.globals[name] {
+		definitions = append(definitions, *definition)
+	}
+	return definitions
+}
+
+// DefinitionAt returns the definition of the name at the location, which can be a reference or a definition.
+// It reports false when there is no name at the location or the name is not bound by any file, like a built-in.
+func (ix *Index) DefinitionAt(location Location) (Definition, bool) {
+	for _, ref := range ix.references {
+		if ref.location.File != location.File || ref.location.Line != location.Line ||
+			location.Column < ref.location.Column || location.Column >= ref.location.Column+len(ref.name) {
+			continue
+		}
+		if ref.definition != nil {
+			return *ref.definition, true
+		}
+		if definitions := ix.globals[ref.name]; len(definitions) != 0 {
+			return *definitions[0], true
+		}
+		return Definition{}, false
+	}
+	return Definition{}, false
+}
+
+// define binds the identifier in the current scope, a definition is a reference to itself
+func (w *walker) define(name *ast.Identifier, kind Kind) {
+	definition := &Definition{
+		Name:     name.Value,
+		Kind:     kind,
+		Location: Location{File: w.file, Line: name.Token.Line, Column: name.Token.Column},
+		Global:   w.scope.outer == nil,
+	}
+	w.scope.bindings[name.Value] = definition
+	w.index.references = append(w.index.references, reference{name: name.Value, location: definition.Location, definition: definition})
+}
+
+// refer records the use of the identifier
+func (w *walker) refer(name *ast.Identifier) {
+	ref := reference{
+		name:       name.Value,
+		location:   Location{File: w.file, Line: name.Token.Line, Column: name.Token.Column},
+		definition: w.scope.resolve(name.Value),
+	}
+	w.index.references = append(w.index.references, ref)
+	if ref.definition == nil {
+		w.late = append(w.late, len(w.index.references)-1)
+	}
+}
+
+// statements walks the statements in order, a binding is visible to the statements after it
+func (w *walker) statements(statements []ast.Statement) {
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			kind := Value
+			if fl, ok := statement.Value.(*ast.FunctionLiteral); ok {
+				kind = Function
+				// a function refers to itself by the name it is bound to
+				w.define(statement.Name, kind)
+				w.expression(fl)
+				continue
+			}
+			w.expression(statement.Value)
+			w.define(statement.Name, kind)
+		case *ast.MultiLetStatement:
+			// every value is evaluated before any name is bound
+			for _, value := range statement.Values {
+				w.expression(value)
+			}
+			for _, name := range statement.Names {
+				w.define(name, Value)
+			}
+		case *ast.MultiAssignStatement:
+			for _, value := range statement.Values {
+				w.expression(value)
+			}
+			for _, name := range statement.Names {
+				w.refer(name)
+			}
+		case *ast.ReturnStatement:
+			w.expression(statement.ReturnValue)
+		case *ast.ExpressionStatement:
+			w.expression(statement.Expression)
+		case *ast.BlockStatement:
+			w.block(statement)
+		}
+	}
+}
+
+// block walks the statements of a block in a scope of their own
+func (w *walker) block(block *ast.BlockStatement) {
+	w.scope = &scope{outer: w.scope, bindings: make(map[string]*Definition)}
+	w.statements(block.Statements)
+	w.scope = w.scope.outer
+}
+
+// expression walks the expression and the expressions it is made of
+func (w *walker) expression(expression ast.Expression) {
+	switch node := expression.(type) {
+	case *ast.Identifier:
+		w.refer(node)
+	case *ast.PrefixExpression:
+		w.expression(node.Right)
+	case *ast.InfixExpression:
+		w.expression(node.Left)
+		w.expression(node.Right)
+	case *ast.AssignExpression:
+		w.expression(node.Value)
+		w.refer(node.Name)
+	case *ast.IfExpression:
+		w.expression(node.Condition)
+		w.block(node.Consequence)
+		if node.Alternative != nil {
+			w.block(node.Alternative)
+		}
+	case *ast.ConditionalExpression:
+		w.expression(node.Condition)
+		w.expression(node.Consequence)
+		w.expression(node.Alternative)
+	case *ast.SwitchExpression:
+		w.expression(node.Subject)
+		for _, c := range node.Cases {
+			for _, v := range c.Values {
+				w.expression(v)
+			}
+			w.block(c.Body)
+		}
+		if node.Default != nil {
+			w.block(node.Default)
+		}
+	case *ast.WhileExpression:
+		w.expression(node.Condition)
+		w.block(node.Body)
+	case *ast.ForExpression:
+		w.expression(node.Iterable)
+		// the names of the loop are only visible in its body
+		w.scope = &scope{outer: w.scope, bindings: make(map[string]*Definition)}
+		if node.Key != nil {
+			w.define(node.Key, Loop)
+		}
+		w.define(node.Value, Loop)
+		w.block(node.Body)
+		w.scope = w.scope.outer
+	case *ast.FunctionLiteral:
+		w.scope = &scope{outer: w.scope, bindings: make(map[string]*Definition)}
+		for _, param := range node.Parameters {
+			w.define(param, Parameter)
+		}
+		// the default values are evaluated in the function, they can refer to any parameter
+		for _, value := range node.Defaults {
+			w.expression(value)
+		}
+		w.statements(node.Body.Statements)
+		w.scope = w.scope.outer
+	case *ast.CallExpression:
+		w.expression(node.Function)
+		for _, argument := range node.Arguments {
+			w.expression(argument)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range node.Elements {
+			w.expression(element)
+		}
+	case *ast.RecordLiteral:
+		for _, value := range node.Values {
+			w.expression(value)
+		}
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			w.expression(key)
+			w.expression(value)
+		}
+	case *ast.MemberExpression:
+		// the property is the name of a field, not a reference
+		w.expression(node.Object)
+	case *ast.IndexExpression:
+		w.expression(node.Left)
+		w.expression(node.Index)
+	case *ast.SliceExpression:
+		w.expression(node.Left)
+		if node.Low != nil {
+			w.expression(node.Low)
+		}
+		if node.High != nil {
+			w.expression(node.High)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+)
+
+func build(t *testing.T, files ...string) *Index {
+	ix := New()
+	for i := 0; i < len(files); i += 2 {
+		p := parser.New(lexer.New(files[i+1]))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors in %s: %v", files[i], p.Errors())
+		}
+		ix.Add(files[i], program)
+	}
+	return ix
+}
+
+func TestDefinitionAt(t *testing.T) {
+	ix := build(t,
+		"a.mky", "let helper = fn(x) { x * later };\nlet main = fn() { helper(shared) + len(\"\") };\nlet later = 1;",
+		"b.mky", "let shared = 1;\nlet f = fn(n) { let shared = n; for (i in [n]) { shared + i } };",
+		"d.mky", "let y = 1;\nif (true) { let y = 2; y };\ny;",
+	)
+
+	tests := []struct {
+		at       Location
+		expected string
+		kind     Kind
+	}{
+		// a reference to a binding of the same file
+		{Location{"a.mky", 2, 19}, "a.mky:1:5", Function},
+		// a parameter
+		{Location{"a.mky", 1, 22}, "a.mky:1:17", Parameter},
+		// a global bound after the function using it
+		{Location{"a.mky", 1, 27}, "a.mky:3:5", Value},
+		// a name bound by another file
+		{Location{"a.mky", 2, 28}, "b.mky:1:5", Value},
+		// a local binding hides the global one
+		{Location{"b.mky", 2, 52}, "b.mky:2:21", Value},
+		{Location{"b.mky", 2, 59}, "b.mky:2:38", Loop},
+		// a binding of a block is only visible inside of it
+		{Location{"d.mky", 2, 24}, "d.mky:2:17", Value},
+		{Location{"d.mky", 3, 1}, "d.mky:1:5", Value},
+		// a definition is its own definition
+		{Location{"b.mky", 1, 6}, "b.mky:1:5", Value},
+	}
+	for _, tt := range tests {
+		definition, ok := ix.DefinitionAt(tt.at)
+		if !ok || definition.Location.String() != tt.expected || definition.Kind != tt.kind {
+			t.Errorf("wrong definition at %s. want=%s %s, got=%v %v", tt.at, tt.expected, tt.kind, definition, ok)
+		}
+	}
+
+	// built-ins and positions without a name have no definition
+	for _, at := range []Location{{"a.mky", 2, 37}, {"a.mky", 1, 1}, {"c.mky", 1, 1}} {
+		if definition, ok := ix.DefinitionAt(at); ok {
+			t.Errorf("unexpected definition at %s: %v", at, definition)
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	ix := build(t,
+		"a.mky", "let b = 1; let a = fn() { let local = 1; };",
+		"b.mky", "let a = 2;",
+	)
+
+	expected := []string{"a a.mky:1:16 function", "a b.mky:1:5 value", "b a.mky:1:5 value"}
+	globals := ix.Globals()
+	if len(globals) != len(expected) {
+		t.Fatalf("wrong number of globals. want=%d, got=%v", len(expected), globals)
+	}
+	for i, definition := range globals {
+		if got := definition.Name + " " + definition.Location.String() + " " + string(definition.Kind); got != expected[i] {
+			t.Errorf("wrong global %d. want=%q, got=%q", i, expected[i], got)
+		}
+	}
+	if len(ix.Lookup("local")) != 0 || len(ix.Lookup("a")) != 2 {
+		t.Errorf("wrong lookups. got=%v, %v", ix.Lookup("local"), ix.Lookup("a"))
+	}
+}
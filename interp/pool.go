@@ -0,0 +1,169 @@
+// Package interp runs a Monkey program many times from a pool of warm VMs, for embedders like servers
+// evaluating a script on every request. The program is compiled once and every VM of the pool runs the same
+// bytecode, which can be shared since its constants are frozen. A VM released back to the pool is recycled:
+// its stack, frames and global store are cleared and reused by the next run instead of being allocated again.
+package interp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/feature"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/runner"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// Options configures a Pool
+type Options struct {
+	// Size is the number of VMs of the pool, which is the number of runs that can happen at the same time
+	Size     int
+	Features feature.Set
+	// Inputs are the names of the globals bound to the values given to every run, in order
+	Inputs []string
+	// Strict works like the option of the runner: indexing an array out of range and reading
+	// a missing hash key are errors, and so are expression statements whose result is unused
+	Strict bool
+	// NoStdlib compiles the program without the standard library
+	NoStdlib bool
+	// Logger configures the logging built-in functions, every run starts with a copy of it, or of object.Log
+	// when it is nil, so the level a run sets with `log_level` is not seen by the others
+	Logger *object.Logger
+}
+
+// Pool holds the compiled program and the VMs running it. It is safe to use from several goroutines.
+type Pool struct {
+	bytecode *compiler.Bytecode
+	// inputs holds the indices of the input globals in the global store
+	inputs []int
+	// globals holds the values every run starts from: the functions of the standard library, and nil
+	// for the globals the program defines. A recycled VM gets them back.
+	globals []object.Object
+	strict  bool
+	logger  *object.Logger
+	idle    chan *Instance
+}
+
+// Instance is a VM of the pool, acquired to run the program once
+type Instance struct {
+	pool    *Pool
+	machine *vm.VM
+	globals []object.Object
+	// broken marks a VM whose run panicked, its state cannot be trusted and it is not recycled
+	broken bool
+}
+
+// NewPool compiles the program and creates the VMs of the pool.
+// It fails when the program does not compile or the size of the pool is not positive.
+func NewPool(source string, options Options) (*Pool, error) {
+	if options.Size < 1 {
+		return nil, fmt.Errorf("size of the pool must be positive, got %d", options.Size)
+	}
+
+	runnerOptions := runner.Options{Features: options.Features, Strict: options.Strict, NoStdlib: options.NoStdlib}
+	program, _, err := runner.Parse(source, runnerOptions)
+	if err != nil {
+		return nil, err
+	}
+	library := runner.Stdlib(runnerOptions)
+	symbolTable, store := runner.NewSymbolTable(library, nil)
+	inputs := make([]int, len(options.Inputs))
+	for i, name := range options.Inputs {
+		inputs[i] = symbolTable.Define(name).Index
+	}
+	comp := compiler.NewWithState(symbolTable, library.Constants())
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("compilation failed: %s", err)
+	}
+
+	pool := &Pool{
+		bytecode: comp.Bytecode(),
+		inputs:   inputs,
+		globals:  store[:symbolTable.NumDefinitions()],
+		strict:   options.Strict,
+		logger:   options.Logger,
+		idle:     make(chan *Instance, options.Size),
+	}
+	if pool.logger == nil {
+		pool.logger = object.Log
+	}
+	for i := 0; i < options.Size; i++ {
+		pool.idle <- pool.newInstance()
+	}
+	return pool, nil
+}
+
+// newInstance creates a VM running the program from the globals of the pool
+func (p *Pool) newInstance() *Instance {
+	globals := make([]object.Object, vm.GlobalsSize)
+	copy(globals, p.globals)
+	machine := vm.NewWithGlobalStore(p.bytecode, globals)
+	machine.SetStrict(p.strict)
+	machine.SetLogger(p.logger.Copy())
+	return &Instance{pool: p, machine: machine, globals: globals}
+}
+
+// Acquire returns an idle VM of the pool, it waits for one to be released when all of them are running.
+// It fails with the error of the context when the context is done first.
+func (p *Pool) Acquire(ctx context.Context) (*Instance, error) {
+	select {
+	case inst := <-p.idle:
+		return inst, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Release recycles the VM and returns it to the pool, the instance must not be used afterwards.
+// The globals of the last run are cleared, so nothing a run leaves behind is seen by the next one.
+// A VM whose run panicked is dropped instead, and a new one takes its place in the pool.
+func (p *Pool) Release(inst *Instance) {
+	if inst.broken {
+		p.idle <- p.newInstance()
+		return
+	}
+	inst.machine.Reset()
+	inst.machine.SetLogger(p.logger.Copy())
+	copy(inst.globals, p.globals)
+	p.idle <- inst
+}
+
+// Run runs the program once on the instance with the inputs bound to the input globals of the pool,
+// a missing input is null. It returns the value of the last expression statement, or the error that
+// stopped the program, a *vm.ExitError when the program called exit. The context stops the program
+// when it is cancelled. An instance runs the program once, it must be released before the next run.
+// A panic of the run, like one of a built-in function given as an input, is returned as an error
+// instead of stopping the embedding process.
+func (inst *Instance) Run(ctx context.Context, inputs ...object.Object) (result object.Object, err error) {
+	if len(inputs) > len(inst.pool.inputs) {
+		return nil, fmt.Errorf("wrong number of inputs. got=%d, want at most %d", len(inputs), len(inst.pool.inputs))
+	}
+	for i, index := range inst.pool.inputs {
+		inst.globals[index] = vm.Null
+		if i < len(inputs) {
+			inst.globals[index] = inputs[i]
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			inst.broken = true
+			result, err = nil, fmt.Errorf("runtime panic: %v", r)
+		}
+	}()
+	if err := inst.machine.RunContext(ctx); err != nil {
+		return nil, err
+	}
+	return inst.machine.LastPoppedStackElem(), nil
+}
+
+// Run acquires a VM, runs the program on it with the inputs and releases the VM, see Instance.Run
+func (p *Pool) Run(ctx context.Context, inputs ...object.Object) (object.Object, error) {
+	inst, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Release(inst)
+	return inst.Run(ctx, inputs...)
+}
@@ -0,0 +1,168 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+func TestPoolRuns(t *testing.T) {
+	pool, err := NewPool(`
+	let double = fn(x) { x * 2 };
+	let total = if (n > 100) { n + name } else { double(n) + len(name) };
+	total`, Options{Size: 3, Inputs: []string{"n", "name"}})
+	if err != nil {
+		t.Fatalf("pool not created: %s", err)
+	}
+
+	// more runs than VMs, the runs wait for a VM to be released
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			result, err := pool.Run(context.Background(), &object.Integer{Value: int64(n)}, &object.String{Value: "ab"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			if integer, ok := result.(*object.Integer); !ok || integer.Value != int64(2*n+2) {
+				errs <- fmt.Errorf("wrong result for %d: %s", n, result.Inspect())
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// a failed run leaves the VM in the middle of the program, the pool recycles it all the same
+	for i := 0; i < 3; i++ {
+		if _, err := pool.Run(context.Background(), &object.Integer{Value: 101}, &object.String{Value: ""}); err == nil {
+			t.Fatalf("expected the run to fail")
+		}
+	}
+	result, err := pool.Run(context.Background(), &object.Integer{Value: 1}, &object.String{Value: "abc"})
+	if err != nil {
+		t.Fatalf("run failed: %s", err)
+	}
+	if result.Inspect() != "5" {
+		t.Errorf("wrong result after failed runs: %s", result.Inspect())
+	}
+
+	if _, err := pool.Run(context.Background(), &object.Integer{Value: 1}, object.NULL, object.NULL); err == nil ||
+		err.Error() != "wrong number of inputs. got=3, want at most 2" {
+		t.Errorf("wrong error for too many inputs: %v", err)
+	}
+}
+
+func TestPoolRecyclesGlobals(t *testing.T) {
+	pool, err := NewPool(`let last = seen; last`, Options{Size: 1, Inputs: []string{"seen"}})
+	if err != nil {
+		t.Fatalf("pool not created: %s", err)
+	}
+
+	result, err := pool.Run(context.Background(), &object.Integer{Value: 7})
+	if err != nil {
+		t.Fatalf("run failed: %s", err)
+	}
+	if result.Inspect() != "7" {
+		t.Fatalf("wrong result: %s", result.Inspect())
+	}
+	// the same VM runs again, without the input of the previous run
+	result, err = pool.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run failed: %s", err)
+	}
+	if result != object.NULL {
+		t.Fatalf("run saw the globals of the previous run: %s", result.Inspect())
+	}
+}
+
+func TestPoolAcquire(t *testing.T) {
+	if _, err := NewPool(`1`, Options{Size: 0}); err == nil || err.Error() != "size of the pool must be positive, got 0" {
+		t.Errorf("wrong error for an empty pool: %v", err)
+	}
+	if _, err := NewPool(`let = 1`, Options{Size: 1}); err == nil {
+		t.Errorf("expected a parser error")
+	}
+
+	pool, err := NewPool(`1`, Options{Size: 1})
+	if err != nil {
+		t.Fatalf("pool not created: %s", err)
+	}
+	inst, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %s", err)
+	}
+
+	// every VM is running, acquiring another one waits until the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.Acquire(ctx); err != context.Canceled {
+		t.Errorf("wrong error acquiring from an exhausted pool: %v", err)
+	}
+
+	pool.Release(inst)
+	if _, err := pool.Acquire(context.Background()); err != nil {
+		t.Errorf("acquire after release failed: %s", err)
+	}
+}
+
+func TestPoolRecoversPanics(t *testing.T) {
+	pool, err := NewPool(`f(1 / n)`, Options{Size: 1, Inputs: []string{"f", "n"}})
+	if err != nil {
+		t.Fatalf("pool not created: %s", err)
+	}
+	boom := &object.Builtin{Fn: func(args ...object.Object) object.Object { panic("boom") }}
+	identity := &object.Builtin{Fn: func(args ...object.Object) object.Object { return args[0] }}
+
+	if _, err := pool.Run(context.Background(), boom, &object.Integer{Value: 1}); err == nil || err.Error() != "runtime panic: boom" {
+		t.Errorf("wrong error for a panicking run: %v", err)
+	}
+	if _, err := pool.Run(context.Background(), identity, &object.Integer{Value: 0}); err == nil || err.Error() != "division by zero" {
+		t.Errorf("wrong error for a division by zero: %v", err)
+	}
+
+	// the VM that panicked was replaced, the pool keeps running the program
+	inst, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire failed: %s", err)
+	}
+	defer pool.Release(inst)
+	result, err := inst.Run(context.Background(), identity, &object.Integer{Value: 1})
+	if err != nil {
+		t.Fatalf("run failed: %s", err)
+	}
+	if result.Inspect() != "1" {
+		t.Errorf("wrong result after a panic: %s", result.Inspect())
+	}
+}
+
+func TestPoolLogger(t *testing.T) {
+	var out bytes.Buffer
+	pool, err := NewPool(`if (debug) { log_level("debug") }; log_debug("run")`, Options{
+		Size:   1,
+		Inputs: []string{"debug"},
+		Logger: &object.Logger{Writer: &out, Level: object.LogInfo},
+	})
+	if err != nil {
+		t.Fatalf("pool not created: %s", err)
+	}
+
+	// the level set by a run is forgotten when its VM is recycled
+	for _, debug := range []*object.Boolean{object.TRUE, object.FALSE} {
+		if _, err := pool.Run(context.Background(), debug); err != nil {
+			t.Fatalf("run failed: %s", err)
+		}
+	}
+	if out.String() != "level=debug msg=\"run\"\n" {
+		t.Errorf("wrong log output: %q", out.String())
+	}
+}
@@ -0,0 +1,254 @@
+// Package isolate runs Monkey programs in separate VMs that only communicate by exchanging messages.
+// Every message is deep-copied through the binary object encoding before it reaches the other VM,
+// so isolates never share mutable state and can run concurrently, like actors.
+package isolate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// ReceiveFunction is the name of the function every isolate program must define.
+// It is called with each message sent to the isolate and its return value is the reply.
+const ReceiveFunction = "receive"
+
+// ErrClosed is returned when sending to or receiving from an isolate that has been closed
+var ErrClosed = errors.New("isolate is closed")
+
+// reply is the encoded return value of the receive function, or the runtime error it failed with
+type reply struct {
+	data []byte
+	err  error
+}
+
+// Options configures an isolate started by SpawnWith. The limits apply to running the program and to handling
+// every message on their own, so one runaway isolate cannot starve the others or the program embedding them.
+// A failing message is replied with the error, the isolate keeps handling the next ones.
+type Options struct {
+	// Context stops the isolate when it is cancelled, like Close. It is context.Background() when nil.
+	Context context.Context
+	// MaxSteps is the maximum number of instructions executed, 0 for no limit
+	MaxSteps int
+	// MaxMemory is the maximum estimated size in bytes of the values the isolate holds, 0 for no limit
+	MaxMemory int
+	// Timeout is the maximum duration, 0 for no limit
+	Timeout time.Duration
+	// Logger is the Logger of the logging built-in functions of the isolate, a copy of object.Log when nil
+	Logger *object.Logger
+}
+
+// An Isolate owns a VM running in its own goroutine. Messages sent to the isolate are handled
+// one at a time by its receive function, in the order they were sent, and every message produces one reply.
+type Isolate struct {
+	machine *vm.VM
+	receive object.Object
+	inbox   chan []byte
+	outbox  chan reply
+	// ctx is cancelled by Close, which stops the message being handled
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// Spawn compiles and runs the program, then starts handling messages with the receive function it defines
+func Spawn(source string) (*Isolate, error) {
+	return SpawnWith(source, Options{})
+}
+
+// SpawnWith spawns the isolate like Spawn, with the given options
+func SpawnWith(source string, options Options) (*Isolate, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser errors: %s", strings.Join(p.Errors(), ", "))
+	}
+
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	for i, m := range object.Modules {
+		symbolTable.DefineModule(i, m.Name)
+	}
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("compilation failed: %s", err)
+	}
+
+	parent := options.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	globals := make([]object.Object, vm.GlobalsSize)
+	machine := vm.NewWithGlobalStore(comp.Bytecode(), globals)
+	machine.SetMaxSteps(options.MaxSteps)
+	machine.SetMaxMemory(options.MaxMemory)
+	if options.Logger != nil {
+		machine.SetLogger(options.Logger)
+	}
+	iso := &Isolate{
+		machine: machine,
+		inbox:   make(chan []byte),
+		outbox:  make(chan reply),
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: options.Timeout,
+	}
+
+	runCtx, stop := iso.limit()
+	err := machine.RunContext(runCtx)
+	if err != nil {
+		err = iso.limitError(runCtx, err)
+	}
+	stop()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("executing bytecode failed: %s", err)
+	}
+
+	symbol, ok := symbolTable.Resolve(ReceiveFunction)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		cancel()
+		return nil, fmt.Errorf("program does not define a %s function", ReceiveFunction)
+	}
+
+	iso.receive = globals[symbol.Index]
+	go iso.loop()
+
+	return iso, nil
+}
+
+// limit returns the context of a single run of the isolate, which times out after the timeout of its options
+func (iso *Isolate) limit() (context.Context, context.CancelFunc) {
+	if iso.timeout > 0 {
+		return context.WithTimeout(iso.ctx, iso.timeout)
+	}
+	return context.WithCancel(iso.ctx)
+}
+
+// limitError returns the error of a run stopped by its context: the timeout or ErrClosed, err otherwise
+func (iso *Isolate) limitError(ctx context.Context, err error) error {
+	switch {
+	case iso.ctx.Err() != nil:
+		return ErrClosed
+	case ctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("timeout (%s) exceeded", iso.timeout)
+	default:
+		return err
+	}
+}
+
+// loop handles the messages of the inbox until the isolate is closed
+func (iso *Isolate) loop() {
+	for {
+		select {
+		case data := <-iso.inbox:
+			r := iso.handle(data)
+			select {
+			case iso.outbox <- r:
+			case <-iso.ctx.Done():
+				return
+			}
+		case <-iso.ctx.Done():
+			return
+		}
+	}
+}
+
+// handle decodes a message, calls the receive function with it and encodes the reply
+func (iso *Isolate) handle(data []byte) reply {
+	msg, err := object.DecodeBinary(data, nil)
+	if err != nil {
+		return reply{err: err}
+	}
+
+	ctx, stop := iso.limit()
+	defer stop()
+	result, err := iso.machine.CallContext(ctx, iso.receive, msg)
+	if err != nil {
+		return reply{err: iso.limitError(ctx, err)}
+	}
+
+	data, err = Copy(result)
+	return reply{data: data, err: err}
+}
+
+// Send delivers a deep copy of the message to the isolate, it blocks until the isolate takes the message.
+// Only plain values can be sent, functions refer to the constants of their own VM.
+func (iso *Isolate) Send(msg object.Object) error {
+	data, err := Copy(msg)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case iso.inbox <- data:
+		return nil
+	case <-iso.ctx.Done():
+		return ErrClosed
+	}
+}
+
+// Receive blocks until the next reply of the isolate is available.
+// A runtime error of the receive function is returned as an error, the isolate keeps handling messages after it.
+func (iso *Isolate) Receive() (object.Object, error) {
+	var r reply
+	select {
+	case r = <-iso.outbox:
+	case <-iso.ctx.Done():
+		return nil, ErrClosed
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return object.DecodeBinary(r.data, nil)
+}
+
+// Close stops the isolate, it also stops the message being handled. Replies that have not been received are discarded.
+func (iso *Isolate) Close() {
+	iso.cancel()
+}
+
+// Copy encodes a message so it can cross to another isolate.
+// Messages containing functions are refused, since their instructions only make sense in the VM that compiled them.
+func Copy(msg object.Object) ([]byte, error) {
+	if err := checkMessage(msg); err != nil {
+		return nil, err
+	}
+	return object.EncodeBinary(msg, nil)
+}
+
+// checkMessage returns an error when the message is or contains a function
+func checkMessage(msg object.Object) error {
+	switch msg := msg.(type) {
+	case *object.Closure, *object.CompiledFunction:
+		return fmt.Errorf("values of type %s cannot be sent between isolates", msg.Type())
+	case *object.ReturnValue:
+		return checkMessage(msg.Value)
+	case *object.Array:
+		for _, el := range msg.Elements {
+			if err := checkMessage(el); err != nil {
+				return err
+			}
+		}
+	case *object.Hash:
+		for _, pair := range msg.Pairs {
+			if err := checkMessage(pair.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package isolate
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+func TestIsolateMessages(t *testing.T) {
+	doubler, err := Spawn(`let receive = fn(msg) { map(fn(x) { x * 2 }, msg) };`)
+	if err != nil {
+		t.Fatalf("spawn failed: %s", err)
+	}
+	defer doubler.Close()
+
+	summer, err := Spawn(`
+	let sum = fn(arr) { if (len(arr) == 0) { 0 } else { first(arr) + sum(rest(arr)) } };
+	let receive = fn(msg) { {"sum": sum(msg)} };
+	`)
+	if err != nil {
+		t.Fatalf("spawn failed: %s", err)
+	}
+	defer summer.Close()
+
+	message := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}
+	if err := doubler.Send(message); err != nil {
+		t.Fatalf("send failed: %s", err)
+	}
+	doubled, err := doubler.Receive()
+	if err != nil {
+		t.Fatalf("receive failed: %s", err)
+	}
+	if doubled.Inspect() != "[2, 4]" {
+		t.Errorf("wrong reply. want=[2, 4], got=%s", doubled.Inspect())
+	}
+	// the message the isolate received was a copy of ours
+	if message.Inspect() != "[1, 2]" {
+		t.Errorf("message was modified: %s", message.Inspect())
+	}
+
+	if err := summer.Send(doubled); err != nil {
+		t.Fatalf("send failed: %s", err)
+	}
+	sum, err := summer.Receive()
+	if err != nil {
+		t.Fatalf("receive failed: %s", err)
+	}
+	if sum.Inspect() != "{sum: 6}" {
+		t.Errorf("wrong reply. want={sum: 6}, got=%s", sum.Inspect())
+	}
+}
+
+func TestIsolateErrors(t *testing.T) {
+	if _, err := Spawn(`let handle = fn(msg) { msg };`); err == nil || err.Error() != "program does not define a receive function" {
+		t.Errorf("wrong spawn error: %v", err)
+	}
+
+	iso, err := Spawn(`let receive = fn(msg) { msg + 1 };`)
+	if err != nil {
+		t.Fatalf("spawn failed: %s", err)
+	}
+
+	iso.Send(&object.String{Value: "a"})
+	if _, err := iso.Receive(); err == nil || err.Error() != "unsupported types for binary operation: STRING, INTEGER" {
+		t.Errorf("wrong runtime error: %v", err)
+	}
+
+	// the isolate keeps handling messages after a runtime error
+	iso.Send(&object.Integer{Value: 1})
+	if reply, err := iso.Receive(); err != nil || reply.Inspect() != "2" {
+		t.Errorf("wrong reply after error. got=%v, err=%v", reply, err)
+	}
+
+	fn := &object.Closure{Fn: &object.CompiledFunction{}}
+	if err := iso.Send(fn); err == nil || err.Error() != "values of type CLOSURE cannot be sent between isolates" {
+		t.Errorf("wrong error for function message: %v", err)
+	}
+
+	iso.Close()
+	iso.Close()
+	if err := iso.Send(&object.Integer{Value: 1}); err != ErrClosed {
+		t.Errorf("wrong error after close: %v", err)
+	}
+	if _, err := iso.Receive(); err != ErrClosed {
+		t.Errorf("wrong error after close: %v", err)
+	}
+}
+
+func TestIsolateLimits(t *testing.T) {
+	tests := []struct {
+		options  Options
+		message  object.Object
+		expected string
+	}{
+		{Options{MaxSteps: 1000}, &object.Hash{}, "maximum number of steps (1000) exceeded"},
+		{Options{Timeout: 10 * time.Millisecond}, &object.Hash{}, "timeout (10ms) exceeded"},
+		{Options{MaxMemory: 1000}, text(strings.Repeat("a", 2000)), "maximum memory (1000 bytes) exceeded"},
+	}
+
+	for _, tt := range tests {
+		iso, err := SpawnWith(`
+		let kept = "";
+		let receive = fn(msg) {
+			if (msg["text"]) { kept = kept + msg["text"]; return len(kept); }
+			let i = 0;
+			while (true) { i = i + 1; }
+		};
+		`, tt.options)
+		if err != nil {
+			t.Fatalf("spawn failed: %s", err)
+		}
+
+		iso.Send(tt.message)
+		if _, err := iso.Receive(); err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+		// the limits apply to every message on its own
+		iso.Send(text("b"))
+		if _, err := iso.Receive(); (err != nil) != (tt.options.MaxMemory > 0) {
+			t.Errorf("wrong reply after the limit was exceeded: %v", err)
+		}
+		iso.Close()
+	}
+
+	if _, err := SpawnWith(`while (true) { 1 }; let receive = fn(msg) { msg };`, Options{MaxSteps: 100}); err == nil ||
+		err.Error() != "executing bytecode failed: maximum number of steps (100) exceeded" {
+		t.Errorf("wrong spawn error: %v", err)
+	}
+}
+
+func TestIsolateClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	iso, err := SpawnWith(`let receive = fn(msg) { while (true) { msg = msg + 1; } };`, Options{Context: ctx})
+	if err != nil {
+		t.Fatalf("spawn failed: %s", err)
+	}
+
+	// cancelling the context stops the isolate in the middle of an endless message
+	iso.Send(&object.Integer{Value: 1})
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := iso.Receive(); err != ErrClosed {
+		t.Errorf("wrong error after cancel: %v", err)
+	}
+	if err := iso.Send(&object.Integer{Value: 1}); err != ErrClosed {
+		t.Errorf("wrong error after cancel: %v", err)
+	}
+}
+
+// text returns the message adding s to the text kept by the isolate of TestIsolateLimits
+func text(s string) object.Object {
+	return object.FromNative(map[string]interface{}{"text": s})
+}
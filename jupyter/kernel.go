@@ -0,0 +1,485 @@
+// Package jupyter runs Monkey as a Jupyter kernel, so notebooks can run Monkey cells. A cell is run like
+// an input of the REPL: its bindings stay visible to the following cells, its value is the result of the cell,
+// the output of puts and print is streamed to the notebook and errors come with their call trace.
+// REPL commands work in cells too, :stats and :heap show the constants and the globals of the VM.
+// The kernel speaks version 5.3 of the Jupyter messaging protocol over a minimal implementation of ZeroMQ,
+// see socket, so it needs no library outside of the module.
+package jupyter
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/repl"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+)
+
+// ProtocolVersion is the version of the Jupyter messaging protocol spoken by the kernel
+const ProtocolVersion = "5.3"
+
+// LanguageName is the name of the language in notebooks and the name of the installed kernel
+const LanguageName = "monkey"
+
+// delimiter separates the routing identities of a message from its signature and its parts
+const delimiter = "<IDS|MSG>"
+
+// Connection is the connection file Jupyter starts a kernel with: the ports of the five channels
+// and the key signing every message. A port of 0 lets the system choose a free port.
+type Connection struct {
+	Transport       string `json:"transport"`
+	IP              string `json:"ip"`
+	ShellPort       int    `json:"shell_port"`
+	ControlPort     int    `json:"control_port"`
+	StdinPort       int    `json:"stdin_port"`
+	IOPubPort       int    `json:"iopub_port"`
+	HBPort          int    `json:"hb_port"`
+	Key             string `json:"key"`
+	SignatureScheme string `json:"signature_scheme"`
+}
+
+// ReadConnection reads the connection file at the path
+func ReadConnection(path string) (*Connection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var connection Connection
+	if err := json.Unmarshal(data, &connection); err != nil {
+		return nil, fmt.Errorf("invalid connection file %s: %w", path, err)
+	}
+	if connection.Transport != "tcp" {
+		return nil, fmt.Errorf("unsupported transport %q, want tcp", connection.Transport)
+	}
+	if connection.Key != "" && connection.SignatureScheme != "hmac-sha256" {
+		return nil, fmt.Errorf("unsupported signature scheme %q, want hmac-sha256", connection.SignatureScheme)
+	}
+	return &connection, nil
+}
+
+// header identifies a message and its type, the header of a request is the parent header of its replies
+type header struct {
+	MsgID    string `json:"msg_id"`
+	Session  string `json:"session"`
+	Username string `json:"username"`
+	Date     string `json:"date"`
+	MsgType  string `json:"msg_type"`
+	Version  string `json:"version"`
+}
+
+// message is a message of the protocol as it is received, identities route the replies to the client that sent it
+type message struct {
+	identities [][]byte
+	header     header
+	content    json.RawMessage
+}
+
+// Kernel serves the channels of a connection, running the cells with a REPL session
+type Kernel struct {
+	connection Connection
+	key        []byte
+	session    string
+	repl       *repl.REPL
+	// count is the number of cells run so far, the notebook shows it next to each cell
+	count int
+
+	shell, control, stdin, iopub, hb *socket
+	requests                         chan received
+	controls                         chan received
+	// done is closed by a shutdown request
+	done chan struct{}
+}
+
+// New binds the channels of the connection and creates the REPL session running the cells.
+// The writer and the prompt of the options are not used, the results are sent to the notebook.
+// The interrupts of the options are ignored too, since an interrupt must never end the kernel, see Interrupt.
+func New(connection *Connection, options repl.Options) (*Kernel, error) {
+	options.Writer = io.Discard
+	options.Interrupts = nil
+	session, err := repl.New(options)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Kernel{
+		connection: *connection,
+		key:        []byte(connection.Key),
+		session:    newID(),
+		repl:       session,
+		requests:   make(chan received),
+		controls:   make(chan received),
+		done:       make(chan struct{}),
+	}
+	heartbeats, input := make(chan received), make(chan received)
+	sockets := []struct {
+		target   **socket
+		kind     string
+		port     *int
+		messages chan received
+	}{
+		{&k.shell, router, &k.connection.ShellPort, k.requests},
+		{&k.control, router, &k.connection.ControlPort, k.controls},
+		// the kernel never asks for input, what clients send on stdin is ignored
+		{&k.stdin, router, &k.connection.StdinPort, input},
+		{&k.iopub, publisher, &k.connection.IOPubPort, nil},
+		{&k.hb, replier, &k.connection.HBPort, heartbeats},
+	}
+	for _, s := range sockets {
+		bound, err := listen(s.kind, fmt.Sprintf("%s:%d", connection.IP, *s.port), s.messages)
+		if err != nil {
+			k.Close()
+			return nil, err
+		}
+		*s.target, *s.port = bound, bound.port()
+	}
+	go discard(input)
+	go echo(heartbeats)
+	return k, nil
+}
+
+// Connection returns the connection the kernel is bound to, with the ports chosen by the system filled in
+func (k *Kernel) Connection() Connection {
+	return k.connection
+}
+
+// Serve answers the requests until a client asks the kernel to shut down. The cells run one at a time,
+// the requests of the control channel are answered while a cell runs, so a cell can be interrupted.
+func (k *Kernel) Serve() {
+	go k.serveControl()
+	k.publish(nil, "status", map[string]string{"execution_state": "starting"})
+	for {
+		select {
+		case request := <-k.requests:
+			k.handle(request)
+		case <-k.done:
+			return
+		}
+	}
+}
+
+// Interrupt stops the cell that is running, like an interrupt request on the control channel does
+func (k *Kernel) Interrupt() {
+	k.repl.Interrupt()
+}
+
+// Close unbinds the channels
+func (k *Kernel) Close() {
+	for _, s := range []*socket{k.shell, k.control, k.stdin, k.iopub, k.hb} {
+		if s != nil {
+			s.close()
+		}
+	}
+}
+
+// serveControl answers the requests of the control channel until the kernel shuts down
+func (k *Kernel) serveControl() {
+	for {
+		select {
+		case request := <-k.controls:
+			k.handle(request)
+		case <-k.done:
+			return
+		}
+	}
+}
+
+// handle answers a request, publishing the busy status while it is handled
+func (k *Kernel) handle(request received) {
+	msg, err := k.parse(request.frames)
+	if err != nil {
+		return
+	}
+	k.publish(msg, "status", map[string]string{"execution_state": "busy"})
+	defer k.publish(msg, "status", map[string]string{"execution_state": "idle"})
+
+	switch msg.header.MsgType {
+	case "kernel_info_request":
+		k.reply(request.conn, msg, "kernel_info_reply", map[string]interface{}{
+			"status":                 "ok",
+			"protocol_version":       ProtocolVersion,
+			"implementation":         LanguageName,
+			"implementation_version": "1.0",
+			"language_info": map[string]string{
+				"name":           LanguageName,
+				"version":        "1.0",
+				"mimetype":       "text/x-monkey",
+				"file_extension": ".monkey",
+			},
+			"banner": "Monkey",
+		})
+	case "execute_request":
+		k.execute(request.conn, msg)
+	case "is_complete_request":
+		k.reply(request.conn, msg, "is_complete_reply", map[string]string{"status": "unknown"})
+	case "comm_info_request":
+		k.reply(request.conn, msg, "comm_info_reply", map[string]interface{}{"status": "ok", "comms": map[string]interface{}{}})
+	case "history_request":
+		k.reply(request.conn, msg, "history_reply", map[string]interface{}{"status": "ok", "history": []interface{}{}})
+	case "interrupt_request":
+		k.Interrupt()
+		k.reply(request.conn, msg, "interrupt_reply", map[string]string{"status": "ok"})
+	case "shutdown_request":
+		var content struct {
+			Restart bool `json:"restart"`
+		}
+		json.Unmarshal(msg.content, &content)
+		k.reply(request.conn, msg, "shutdown_reply", map[string]interface{}{"status": "ok", "restart": content.Restart})
+		select {
+		case <-k.done:
+		default:
+			close(k.done)
+		}
+	}
+}
+
+// execute runs the code of a cell and publishes its output, its value and its errors before replying.
+// A silent cell publishes nothing and is not counted.
+func (k *Kernel) execute(conn *conn, msg *message) {
+	var cell struct {
+		Code   string `json:"code"`
+		Silent bool   `json:"silent"`
+	}
+	if err := json.Unmarshal(msg.content, &cell); err != nil {
+		return
+	}
+	publish := func(msgType string, content interface{}) {
+		if !cell.Silent {
+			k.publish(msg, msgType, content)
+		}
+	}
+	if !cell.Silent {
+		k.count++
+	}
+	publish("execute_input", map[string]interface{}{"code": cell.Code, "execution_count": k.count})
+
+	response, err := k.repl.EvalJSON(cell.Code)
+	if response.Stdout != "" {
+		publish("stream", map[string]string{"name": "stdout", "text": response.Stdout})
+	}
+	if len(response.Warnings) != 0 {
+		lines := make([]string, len(response.Warnings))
+		for i, warning := range response.Warnings {
+			lines[i] = describe(warning)
+		}
+		publish("stream", map[string]string{"name": "stderr", "text": strings.Join(lines, "\n") + "\n"})
+	}
+	if response.Exit != nil {
+		// exit ends a REPL session, the kernel keeps running with a new one
+		k.repl.Reset()
+		publish("stream", map[string]string{"name": "stderr", "text": fmt.Sprintf("exit(%d) ended the session, its bindings were reset\n", *response.Exit)})
+	}
+
+	if len(response.Errors) != 0 {
+		failure := map[string]interface{}{
+			"ename":     response.Errors[0].Kind,
+			"evalue":    response.Errors[0].Message,
+			"traceback": traceback(response, err),
+		}
+		publish("error", failure)
+		failure["status"] = "error"
+		failure["execution_count"] = k.count
+		k.reply(conn, msg, "execute_reply", failure)
+		return
+	}
+	if response.Type != "" {
+		publish("execute_result", map[string]interface{}{
+			"execution_count": k.count,
+			"data":            map[string]string{"text/plain": response.Value},
+			"metadata":        map[string]string{"type": response.Type},
+		})
+	}
+	k.reply(conn, msg, "execute_reply", map[string]interface{}{
+		"status":           "ok",
+		"execution_count":  k.count,
+		"user_expressions": map[string]interface{}{},
+		"payload":          []interface{}{},
+	})
+}
+
+// traceback returns the lines describing the errors of a cell, the error located by the compiler or an engine
+// is formatted like the REPL does, with its call trace
+func traceback(response *repl.Response, err error) []string {
+	var located *report.Error
+	if errors.As(err, &located) {
+		return strings.Split(report.Format("", err), "\n")
+	}
+	lines := make([]string, len(response.Errors))
+	for i, e := range response.Errors {
+		lines[i] = describe(e)
+	}
+	return lines
+}
+
+// describe formats an error or a warning of a cell as "line:column: kind[code]: message",
+// the position and the code are left out when they are unknown
+func describe(e repl.ResponseError) string {
+	text := e.Kind
+	if e.Code != "" {
+		text += "[" + e.Code + "]"
+	}
+	text += ": " + e.Message
+	if e.Line > 0 {
+		text = fmt.Sprintf("%d:%d: %s", e.Line, e.Column, text)
+	}
+	return text
+}
+
+// parse checks the signature of the frames of a message and decodes its header.
+// It fails for a message that is not signed with the key of the connection.
+func (k *Kernel) parse(frames [][]byte) (*message, error) {
+	position := -1
+	for i, frame := range frames {
+		if string(frame) == delimiter {
+			position = i
+			break
+		}
+	}
+	// the delimiter is followed by the signature, the header, the parent header, the metadata and the content
+	if position == -1 || len(frames) < position+6 {
+		return nil, errors.New("jupyter: malformed message")
+	}
+	parts := frames[position+2 : position+6]
+	if !hmac.Equal([]byte(k.sign(parts)), frames[position+1]) {
+		return nil, errors.New("jupyter: invalid signature")
+	}
+
+	msg := &message{identities: frames[:position], content: parts[3]}
+	if err := json.Unmarshal(parts[0], &msg.header); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// reply sends the message answering the request on the connection the request arrived on
+func (k *Kernel) reply(conn *conn, parent *message, msgType string, content interface{}) {
+	conn.send(k.frames(parent.identities, parent, msgType, content))
+}
+
+// publish broadcasts the message on the iopub channel, with its type as the topic.
+// parent is the request that caused it, nil when there is none.
+func (k *Kernel) publish(parent *message, msgType string, content interface{}) {
+	k.iopub.publish(k.frames([][]byte{[]byte(msgType)}, parent, msgType, content))
+}
+
+// frames returns the frames of a new message following the identities
+func (k *Kernel) frames(identities [][]byte, parent *message, msgType string, content interface{}) [][]byte {
+	h := header{
+		MsgID:    newID(),
+		Session:  k.session,
+		Username: LanguageName,
+		Date:     time.Now().UTC().Format(time.RFC3339Nano),
+		MsgType:  msgType,
+		Version:  ProtocolVersion,
+	}
+	headerData, _ := json.Marshal(h)
+	parentData := []byte("{}")
+	if parent != nil {
+		parentData, _ = json.Marshal(parent.header)
+	}
+	contentData, err := json.Marshal(content)
+	if err != nil {
+		contentData = []byte("{}")
+	}
+	parts := [][]byte{headerData, parentData, []byte("{}"), contentData}
+
+	frames := append([][]byte{}, identities...)
+	frames = append(frames, []byte(delimiter), []byte(k.sign(parts)))
+	return append(frames, parts...)
+}
+
+// sign returns the HMAC-SHA256 of the parts of a message in hex, messages are not signed when the key is empty
+func (k *Kernel) sign(parts [][]byte) string {
+	if len(k.key) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// discard drops the messages of a channel the kernel does not answer
+func discard(messages <-chan received) {
+	for range messages {
+	}
+}
+
+// echo sends every heartbeat back unchanged, with the envelope the reply socket of ZeroMQ expects
+func echo(heartbeats <-chan received) {
+	for heartbeat := range heartbeats {
+		heartbeat.conn.send(heartbeat.frames)
+	}
+}
+
+// newID returns a random identifier for the session and the messages of the kernel
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// KernelSpec returns the kernel.json describing the kernel to Jupyter, which starts the executable with the kernel
+// command and the path of the connection file. The flags are passed before the command, like --engine=eval.
+func KernelSpec(executable string, flags []string) ([]byte, error) {
+	argv := append([]string{executable}, flags...)
+	argv = append(argv, "kernel", "{connection_file}")
+	return json.MarshalIndent(map[string]interface{}{
+		"argv":         argv,
+		"display_name": "Monkey",
+		"language":     LanguageName,
+		// interrupts are requests on the control channel, signals do not reach a kernel running on Windows
+		"interrupt_mode": "message",
+	}, "", "  ")
+}
+
+// Install writes the kernel spec in the kernels directory of the Jupyter data directory of the user,
+// so notebooks offer the Monkey kernel. It returns the directory of the kernel spec.
+func Install(executable string, flags []string) (string, error) {
+	data, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	spec, err := KernelSpec(executable, flags)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(data, "kernels", LanguageName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, os.WriteFile(filepath.Join(dir, "kernel.json"), append(spec, '\n'), 0644)
+}
+
+// DataDir returns the Jupyter data directory of the user: JUPYTER_DATA_DIR when it is set,
+// otherwise the default of the system, ~/.local/share/jupyter on Linux
+func DataDir() (string, error) {
+	if dir := os.Getenv("JUPYTER_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "jupyter"), nil
+		}
+		return "", errors.New("APPDATA is not set")
+	case "darwin":
+		home, err := os.UserHomeDir()
+		return filepath.Join(home, "Library", "Jupyter"), err
+	}
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, "jupyter"), nil
+	}
+	home, err := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "jupyter"), err
+}
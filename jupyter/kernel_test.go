@@ -0,0 +1,190 @@
+package jupyter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/repl"
+)
+
+// startKernel starts a kernel on free ports of the loopback interface, Serve returns when done is closed
+func startKernel(t *testing.T) (*Kernel, chan struct{}) {
+	t.Helper()
+	connection := &Connection{Transport: "tcp", IP: "127.0.0.1", Key: "secret", SignatureScheme: "hmac-sha256"}
+	k, err := New(connection, repl.Options{})
+	if err != nil {
+		t.Fatalf("starting the kernel failed: %s", err)
+	}
+	t.Cleanup(k.Close)
+	served := make(chan struct{})
+	go func() {
+		k.Serve()
+		close(served)
+	}()
+	return k, served
+}
+
+// connect dials the port of the kernel with a client socket of the kind
+func connect(t *testing.T, kind string, port int) *conn {
+	t.Helper()
+	c, err := dial(kind, fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("connecting to port %d failed: %s", port, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	return c
+}
+
+// request sends a request signed with the key of the kernel and returns the reply
+func request(t *testing.T, k *Kernel, c *conn, msgType string, content interface{}) (*message, map[string]interface{}) {
+	t.Helper()
+	if err := c.send(k.frames(nil, nil, msgType, content)); err != nil {
+		t.Fatalf("sending %s failed: %s", msgType, err)
+	}
+	return receive(t, k, c)
+}
+
+// receive reads the next message of the connection and decodes its content
+func receive(t *testing.T, k *Kernel, c *conn) (*message, map[string]interface{}) {
+	t.Helper()
+	frames, err := c.receive()
+	if err != nil {
+		t.Fatalf("receiving a message failed: %s", err)
+	}
+	msg, err := k.parse(frames)
+	if err != nil {
+		t.Fatalf("invalid message: %s", err)
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(msg.content, &content); err != nil {
+		t.Fatalf("invalid content: %s", err)
+	}
+	return msg, content
+}
+
+// outputs returns the messages published on iopub while the kernel handled the last request, up to its idle status
+func outputs(t *testing.T, k *Kernel, iopub *conn) map[string]map[string]interface{} {
+	t.Helper()
+	published := map[string]map[string]interface{}{}
+	for {
+		msg, content := receive(t, k, iopub)
+		if msg.header.MsgType == "status" && content["execution_state"] == "idle" {
+			return published
+		}
+		published[msg.header.MsgType] = content
+	}
+}
+
+func TestKernel(t *testing.T) {
+	k, served := startKernel(t)
+	shell := connect(t, dealer, k.Connection().ShellPort)
+	iopub := connect(t, subscriber, k.Connection().IOPubPort)
+	// messages are only published to the subscribers connected at the time
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		k.iopub.mu.Lock()
+		subscribed := len(k.iopub.conns) == 1
+		k.iopub.mu.Unlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the subscriber was not accepted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	msg, info := request(t, k, shell, "kernel_info_request", map[string]string{})
+	if msg.header.MsgType != "kernel_info_reply" || info["protocol_version"] != ProtocolVersion {
+		t.Errorf("wrong kernel info reply %s: %v", msg.header.MsgType, info)
+	}
+	outputs(t, k, iopub)
+
+	_, reply := request(t, k, shell, "execute_request", map[string]interface{}{"code": "let x = 2;\nputs(x);\nx * 21"})
+	if reply["status"] != "ok" || reply["execution_count"] != 1.0 {
+		t.Errorf("wrong execute reply: %v", reply)
+	}
+	published := outputs(t, k, iopub)
+	if published["stream"]["text"] != "2\n" || published["stream"]["name"] != "stdout" {
+		t.Errorf("wrong stream: %v", published["stream"])
+	}
+	result, _ := published["execute_result"]["data"].(map[string]interface{})
+	if result["text/plain"] != "42" {
+		t.Errorf("wrong execute result: %v", published["execute_result"])
+	}
+
+	// the bindings of a cell are visible to the following cells
+	_, reply = request(t, k, shell, "execute_request", map[string]interface{}{"code": "let f = fn() { x + true }; f()"})
+	if reply["status"] != "error" || reply["ename"] != "runtime error" || reply["execution_count"] != 2.0 {
+		t.Errorf("wrong execute reply: %v", reply)
+	}
+	published = outputs(t, k, iopub)
+	traceback := fmt.Sprint(published["error"]["traceback"])
+	if !strings.Contains(traceback, "unsupported types for binary operation: INTEGER, BOOLEAN") ||
+		!strings.Contains(traceback, "call trace") {
+		t.Errorf("wrong traceback: %s", traceback)
+	}
+
+	_, reply = request(t, k, shell, "execute_request", map[string]interface{}{"code": "let", "silent": true})
+	if reply["status"] != "error" || reply["ename"] != "parse error" || reply["execution_count"] != 2.0 {
+		t.Errorf("wrong execute reply of a silent cell: %v", reply)
+	}
+	if published = outputs(t, k, iopub); len(published) != 1 {
+		t.Errorf("a silent cell published more than its status: %v", published)
+	}
+
+	heartbeat := connect(t, dealer, k.Connection().HBPort)
+	heartbeat.send([][]byte{{}, []byte("ping")})
+	if frames, err := heartbeat.receive(); err != nil || len(frames) != 2 || string(frames[1]) != "ping" {
+		t.Errorf("wrong heartbeat: %q %v", frames, err)
+	}
+
+	control := connect(t, dealer, k.Connection().ControlPort)
+	if _, reply := request(t, k, control, "shutdown_request", map[string]bool{"restart": false}); reply["status"] != "ok" {
+		t.Errorf("wrong shutdown reply: %v", reply)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Errorf("the kernel did not stop after the shutdown request")
+	}
+}
+
+func TestSignature(t *testing.T) {
+	k, _ := startKernel(t)
+	frames := k.frames(nil, nil, "kernel_info_request", map[string]string{})
+	if _, err := k.parse(frames); err != nil {
+		t.Fatalf("a signed message was refused: %s", err)
+	}
+
+	// the signature covers every part of the message
+	frames[len(frames)-1] = []byte(`{"code": "1"}`)
+	if _, err := k.parse(frames); err == nil {
+		t.Errorf("expected a message with a changed content to be refused")
+	}
+	if _, err := k.parse(frames[:3]); err == nil {
+		t.Errorf("expected a message without its parts to be refused")
+	}
+}
+
+func TestKernelSpec(t *testing.T) {
+	spec, err := KernelSpec("/usr/local/bin/monkey", []string{"--engine=eval"})
+	if err != nil {
+		t.Fatalf("KernelSpec failed: %s", err)
+	}
+	var decoded struct {
+		Argv          []string `json:"argv"`
+		Language      string   `json:"language"`
+		InterruptMode string   `json:"interrupt_mode"`
+	}
+	if err := json.Unmarshal(spec, &decoded); err != nil {
+		t.Fatalf("invalid kernel spec: %s", err)
+	}
+	expected := "/usr/local/bin/monkey --engine=eval kernel {connection_file}"
+	if strings.Join(decoded.Argv, " ") != expected || decoded.Language != LanguageName || decoded.InterruptMode != "message" {
+		t.Errorf("wrong kernel spec: %s", spec)
+	}
+}
@@ -0,0 +1,286 @@
+package jupyter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// The socket types of ZeroMQ the kernel uses, they are exchanged in the handshake of every connection
+const (
+	// router receives the requests of every client on the shell, control and stdin channels
+	router = "ROUTER"
+	// publisher broadcasts the outputs and the status of the kernel on the iopub channel
+	publisher = "PUB"
+	// replier echoes the heartbeats
+	replier = "REP"
+	// dealer and subscriber are the sockets of the clients, the tests connect with them
+	dealer     = "DEALER"
+	subscriber = "SUB"
+)
+
+// The flags starting every frame of ZMTP 3.0, the wire protocol of ZeroMQ
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// greetingSize is the size of the greeting both peers send when a connection opens
+const greetingSize = 64
+
+// maxFrameSize bounds the size of a received frame, so a damaged length cannot exhaust the memory
+const maxFrameSize = 1 << 30
+
+// socket is a minimal ZeroMQ socket speaking ZMTP 3.0 with the NULL security mechanism, the one Jupyter
+// uses, over TCP. It only binds: every connection accepted by its listener is served on its own, a reply
+// is sent back on the connection the request arrived on, and a published message goes to every connection.
+type socket struct {
+	kind     string
+	listener net.Listener
+	// messages receives the messages of every connection, it is nil for a publisher, which ignores them
+	messages chan<- received
+
+	mu    sync.Mutex
+	conns []*conn
+}
+
+// received is a message read from a connection of a socket, with the connection to reply on
+type received struct {
+	conn   *conn
+	frames [][]byte
+}
+
+// conn is a connection of a socket after the handshake, writes are serialized so replies never interleave
+type conn struct {
+	net.Conn
+	r  *bufio.Reader
+	mu sync.Mutex
+}
+
+// listen binds a socket of the kind to the TCP address and starts accepting connections
+func listen(kind, address string, messages chan<- received) (*socket, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := &socket{kind: kind, listener: listener, messages: messages}
+	go s.accept()
+	return s, nil
+}
+
+// port returns the TCP port the socket is bound to, which is chosen by the system when the address asked for port 0
+func (s *socket) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// accept serves every connection until the socket is closed
+func (s *socket) accept() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(nc)
+	}
+}
+
+// serve completes the handshake of the connection and reads its messages until it is closed
+func (s *socket) serve(nc net.Conn) {
+	c, err := handshake(nc, s.kind)
+	if err != nil {
+		nc.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, c)
+	s.mu.Unlock()
+	defer s.remove(c)
+
+	for {
+		frames, err := c.receive()
+		if err != nil {
+			return
+		}
+		// the subscriptions of the clients are not filtered, every client receives every published message
+		if s.messages != nil {
+			s.messages <- received{conn: c, frames: frames}
+		}
+	}
+}
+
+// remove closes the connection and forgets it
+func (s *socket) remove(c *conn) {
+	c.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, other := range s.conns {
+		if other == c {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			break
+		}
+	}
+}
+
+// publish sends the message to every connection, a connection failing to receive it is closed
+func (s *socket) publish(frames [][]byte) {
+	s.mu.Lock()
+	conns := append([]*conn{}, s.conns...)
+	s.mu.Unlock()
+	for _, c := range conns {
+		if err := c.send(frames); err != nil {
+			c.Close()
+		}
+	}
+}
+
+// close stops accepting connections and closes the open ones
+func (s *socket) close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+}
+
+// dial connects a socket of the kind to the TCP address, the tests use it to act as a client
+func dial(kind, address string) (*conn, error) {
+	nc, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c, err := handshake(nc, kind)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake exchanges the greetings and the READY commands with the peer. The greeting announces version 3.0,
+// so a peer speaking a later version falls back to it, and the NULL mechanism, the only one supported.
+func handshake(nc net.Conn, kind string) (*conn, error) {
+	greeting := make([]byte, greetingSize)
+	greeting[0], greeting[9] = 0xff, 0x7f
+	greeting[10], greeting[11] = 3, 0
+	copy(greeting[12:32], "NULL")
+	if _, err := nc.Write(greeting); err != nil {
+		return nil, err
+	}
+
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	peer := make([]byte, greetingSize)
+	if _, err := io.ReadFull(c.r, peer); err != nil {
+		return nil, err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f || peer[10] < 3 {
+		return nil, errors.New("zmtp: the peer does not speak ZMTP 3")
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != "NULL" {
+		return nil, fmt.Errorf("zmtp: unsupported security mechanism %q", mechanism)
+	}
+
+	// the READY command holds the properties of the socket: its name is preceded by its length,
+	// each property is a name preceded by its length and a value preceded by its 4 byte length
+	var ready bytes.Buffer
+	ready.WriteByte(5)
+	ready.WriteString("READY")
+	ready.WriteByte(byte(len("Socket-Type")))
+	ready.WriteString("Socket-Type")
+	binary.Write(&ready, binary.BigEndian, uint32(len(kind)))
+	ready.WriteString(kind)
+	if err := c.writeFrame(ready.Bytes(), flagCommand); err != nil {
+		return nil, err
+	}
+
+	body, flags, err := c.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		return nil, errors.New("zmtp: expected the READY command of the peer")
+	}
+	return c, nil
+}
+
+// receive reads the frames of the next message, skipping the commands sent between messages
+func (c *conn) receive() ([][]byte, error) {
+	var frames [][]byte
+	for {
+		body, flags, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			continue
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// send writes the frames as a single message
+func (c *conn) send(frames [][]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		if err := c.writeFrame(frame, flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFrame reads a frame, its size takes 1 byte, or 8 bytes for a long frame
+func (c *conn) readFrame() ([]byte, byte, error) {
+	flags, err := c.r.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		if err := binary.Read(c.r, binary.BigEndian, &size); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		short, err := c.r.ReadByte()
+		if err != nil {
+			return nil, 0, err
+		}
+		size = uint64(short)
+	}
+	if size > maxFrameSize {
+		return nil, 0, fmt.Errorf("zmtp: frame of %d bytes is too large", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, 0, err
+	}
+	return body, flags, nil
+}
+
+// writeFrame writes a frame with the flags, marking it long when its size does not fit in a byte
+func (c *conn) writeFrame(body []byte, flags byte) error {
+	var header []byte
+	if len(body) > 255 {
+		header = make([]byte, 9)
+		header[0] = flags | flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+	} else {
+		header = []byte{flags, byte(len(body))}
+	}
+	_, err := c.Write(append(header, body...))
+	return err
+}
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"strings"
+
 	"github.com/yourfavoritedev/golang-interpreter/token"
 )
 
@@ -13,10 +15,20 @@ type Lexer struct {
 	position     int  // current position in input (points to the current char)
 	readPosition int  // current reading position in input (points to the char that will be read next)
 	ch           byte // current char under examination
+	line         int  // line of the current char, starting at 1
+	column       int  // column of the current char in its line, starting at 1
 }
 
 // readChar finds the next character in the input and then advances our position in the input
 func (l *Lexer) readChar() {
+	// keep track of the position of the char we're about to examine
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0 // 0 is the ASCII code for the "NUL" character
 	} else {
@@ -27,13 +39,22 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
-// readNumber reads a number and advances the lexer position until it encounters a non-digit character
-func (l *Lexer) readNumber() string {
+// readNumber reads a number and advances the lexer position until it encounters a non-digit character.
+// A dot followed by a digit continues the number as a float, the returned type tells which one was read.
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return token.INT, l.input[position:l.position]
+	}
+
+	l.readChar()
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+	return token.FLOAT, l.input[position:l.position]
 }
 
 // readIdentifier reads an identifer and advances the lexer position until it encounters a non-letter character
@@ -46,6 +67,27 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// isDocComment checks whether the current character starts a "###" doc comment
+func (l *Lexer) isDocComment() bool {
+	return l.ch == '#' && l.readPosition+1 < len(l.input) &&
+		l.input[l.readPosition] == '#' && l.input[l.readPosition+1] == '#'
+}
+
+// isEllipsis checks whether the current character starts a "..." ellipsis
+func (l *Lexer) isEllipsis() bool {
+	return l.readPosition+1 < len(l.input) && l.input[l.readPosition] == '.' && l.input[l.readPosition+1] == '.'
+}
+
+// readDocComment reads the text of a doc comment, starting after the "###" marker
+// and advancing the lexer's position until the end of the line.
+func (l *Lexer) readDocComment() string {
+	position := l.position + 3
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return strings.TrimSpace(l.input[position:l.position])
+}
+
 // peekChar finds the next character in the input. It does not increment the position and readPosition of the lexer.
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
@@ -55,20 +97,33 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
-// skipWhitespace will skip the current character and advance the lexer's position if it is a whitespace
+// skipWhitespace will skip the current character and advance the lexer's position if it is a whitespace.
+// Line comments are skipped like whitespace, a "#" starts a comment running until the end of the line,
+// unless it starts a "###" doc comment, which is a token of its own.
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '#' && !l.isDocComment():
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+		default:
+			return
+		}
 	}
 }
 
-// readString constructs a string literal using the input between the current character '"' and the
-// closing '"' character. It advances the lexer's position until it encounters the closing '"' character or EOF.
-func (l *Lexer) readString() string {
+// readString constructs a string literal using the input between the current character, the quote '"' or
+// the backtick '`' of a raw string, and the closing quote character. It advances the lexer's position until
+// it encounters the closing quote character or EOF. No escapes are processed and newlines are kept, a raw
+// string differs only in that it can hold '"' characters.
+func (l *Lexer) readString(quote byte) string {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == quote || l.ch == 0 {
 			break
 		}
 	}
@@ -81,6 +136,9 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	// record where the token starts before the lexer advances past it
+	line, column := l.line, l.column
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -92,9 +150,28 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '+' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.INCREMENT, Literal: literal}
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
+		} else if l.peekChar() == '-' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.DECREMENT, Literal: literal}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -107,13 +184,52 @@ func (l *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '/' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.FLOOR_SLASH, Literal: literal}
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.LT_EQ, Literal: literal}
+		} else if l.peekChar() == '<' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: literal}
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.GT_EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.SHIFT_RIGHT, Literal: literal}
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		if l.isEllipsis() {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case '(':
@@ -124,15 +240,52 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
-	case '"':
+	case '"', '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readString(l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '&':
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.AND, Literal: literal}
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.OR, Literal: literal}
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
+	case '?':
+		if l.peekChar() == '[' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.OPTIONAL_LBRACKET, Literal: literal}
+		} else {
+			tok = newToken(token.QUESTION, l.ch)
+		}
+	// any other "#" starts a line comment, which was skipped with the whitespace
+	case '#':
+		tok.Type = token.DOC
+		tok.Literal = l.readDocComment()
+		tok.Line, tok.Column = line, column
+		return tok
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -140,10 +293,11 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -153,6 +307,7 @@ func (l *Lexer) NextToken() token.Token {
 	// advance position of input after reading character
 	l.readChar()
 
+	tok.Line, tok.Column = line, column
 	return tok
 }
 
@@ -178,7 +333,7 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 // It calls readChar a single time to initialize the first char to be examined,
 // then sets the position and the next readPosition for the lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -26,10 +26,24 @@ func TestNextToken(t *testing.T) {
 	
 	10 == 10;
 	10 != 9;
+	1 <= 2 >= 3;
+	a && b || c;
+	a & b | c ^ ~d << 1 >> 2;
 	"foobar"
 	"foo bar"
 	[1, 2];
 	{"foo": "bar"}
+	strings.upper
+	7 // 2
+	fn(a: int) -> int
+	1.25 p.x
+	fn(rest...) a..b
+	a?[0]
+	for (x in y) # a comment, with "quotes" and ### inside
+	i++ j-- - -k
+	## a comment too
+	### documented
+	#
 	`
 
 	tests := []struct {
@@ -109,6 +123,31 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.LT_EQ, "<="},
+		{token.INT, "2"},
+		{token.GT_EQ, ">="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "b"},
+		{token.PIPE, "|"},
+		{token.IDENT, "c"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENT, "d"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "1"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
 		{token.LBRACKET, "["},
@@ -122,6 +161,51 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "strings"},
+		{token.DOT, "."},
+		{token.IDENT, "upper"},
+		{token.INT, "7"},
+		{token.FLOOR_SLASH, "//"},
+		{token.INT, "2"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.FLOAT, "1.25"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "rest"},
+		{token.ELLIPSIS, "..."},
+		{token.RPAREN, ")"},
+		{token.IDENT, "a"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.IDENT, "b"},
+		{token.IDENT, "a"},
+		{token.OPTIONAL_LBRACKET, "?["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.IDENT, "i"},
+		{token.INCREMENT, "++"},
+		{token.IDENT, "j"},
+		{token.DECREMENT, "--"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.IDENT, "k"},
+		{token.DOC, "documented"},
 		{token.EOF, ""},
 	}
 
@@ -140,3 +224,90 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "ten";`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"x", 2, 3},
+		{"+", 2, 5},
+		{"ten", 2, 7},
+		{";", 2, 12},
+		{"", 2, 13},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	input := "`say \"hi\"\n\\d+` \"a`b\""
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "say \"hi\"\n\\d+"},
+		{token.STRING, "a`b"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nlet x = 1; # one\n\"#not a comment\" #\n# last"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.LET, "let", 2},
+		{token.IDENT, "x", 2},
+		{token.ASSIGN, "=", 2},
+		{token.INT, "1", 2},
+		{token.SEMICOLON, ";", 2},
+		{token.STRING, "#not a comment", 3},
+		{token.EOF, "", 4},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral || tok.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q at line %d, got=%q %q at line %d",
+				i, tt.expectedType, tt.expectedLiteral, tt.expectedLine, tok.Type, tok.Literal, tok.Line)
+		}
+	}
+}
@@ -1,22 +1,398 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
-	"os/user"
+	"strings"
+	"time"
 
+	"github.com/yourfavoritedev/golang-interpreter/cache"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/doc"
+	"github.com/yourfavoritedev/golang-interpreter/feature"
+	"github.com/yourfavoritedev/golang-interpreter/index"
+	"github.com/yourfavoritedev/golang-interpreter/jupyter"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
 	"github.com/yourfavoritedev/golang-interpreter/repl"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+	"github.com/yourfavoritedev/golang-interpreter/runner"
+	"github.com/yourfavoritedev/golang-interpreter/transpile"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+	"github.com/yourfavoritedev/golang-interpreter/watch"
 )
 
+var featureList = flag.String("feature", "", "comma separated list of experimental language features to enable")
+var maxDepth = flag.Int("max-depth", 0, "maximum nesting of arrays and hashes printed by the REPL, 0 for unlimited")
+var maxWidth = flag.Int("max-width", 0, "maximum number of elements of an array or hash printed by the REPL, 0 for unlimited")
+var quoteStrings = flag.Bool("quote-strings", false, "print strings as quoted literals in REPL results and the output of puts and print")
+var separator = flag.String("separator", object.DefaultSeparator, "written between the arguments of puts and print")
+var transcriptPath = flag.String("transcript", "", "append every REPL input and output with timestamps to this file")
+var strict = flag.Bool("strict", false, "make out-of-range indexes, missing hash keys and unused results at file scope errors")
+var maxCallDepth = flag.Int("max-call-depth", 0, "maximum number of nested function calls, 0 for the default of 1024")
+var engine = flag.String("engine", string(runner.VM), "engine running the REPL and files with the run command, vm or eval")
+var historyPath = flag.String("history", "", "append every REPL input to this file")
+var definitionAt = flag.String("at", "", "file:line:column of a name, the defs command prints where it is defined")
+var noStdlib = flag.Bool("no-stdlib", false, "run without the standard library, its names are free for the program")
+var quiet = flag.Bool("quiet", false, "start the REPL without the greeting and the prompts, for input piped from another program")
+var jsonMode = flag.Bool("json", false, "answer every REPL input with a line of JSON holding its value, output, errors and durations")
+var noCache = flag.Bool("no-cache", false, "compile files every time instead of keeping the compiled programs in ~/.cache/monkey")
+var watchInterval = flag.Duration("interval", 500*time.Millisecond, "how often the watch command looks for changed files")
+
 func main() {
-	user, err := user.Current()
+	flag.Parse()
+	features, err := feature.Parse(*featureList)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	object.Output.Separator = *separator
+
+	// `monkey doc file.monkey...` prints the documentation of the given files instead of starting the REPL
+	if flag.Arg(0) == "doc" {
+		os.Exit(generateDoc(flag.Args()[1:], features))
 	}
-	fmt.Printf("Hello %s!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
+	// `monkey transpile file.monkey` prints a standalone Go program that runs the given file
+	if flag.Arg(0) == "transpile" {
+		os.Exit(transpileFile(flag.Args()[1:], features))
+	}
+	// `monkey run file.monkey args...` runs the given file with the arguments bound to ARGV
+	if flag.Arg(0) == "run" {
+		os.Exit(runFile(flag.Args()[1:], features))
+	}
+
+	// `monkey defs file.monkey...` prints where the names of the given files are defined
+	if flag.Arg(0) == "defs" {
+		os.Exit(printDefinitions(flag.Args()[1:], features))
+	}
+	// `monkey watch file.monkey...` reports the diagnostics of the given files every time they change
+	if flag.Arg(0) == "watch" {
+		os.Exit(watchFiles(flag.Args()[1:], features))
+	}
+
+	if flag.Arg(0) == "kernel" {
+		os.Exit(runKernel(flag.Args()[1:], features))
+	}
+
 	// the os package has access to the current context that is running this program
 	// if running in a terminal, os.Stdin and os.Stdout will be the terminal's
 	// open data-streams for standard input and output
-	repl.Start(os.Stdin, os.Stdout)
+	// Ctrl-C stops the running input instead of killing the REPL
+	interrupts := notifyInterrupts()
+	options := repl.Options{
+		Features:     features,
+		Inspect:      object.InspectOptions{MaxDepth: *maxDepth, MaxWidth: *maxWidth, QuoteStrings: *quoteStrings},
+		Interrupts:   interrupts,
+		Strict:       *strict,
+		MaxCallDepth: *maxCallDepth,
+		NoStdlib:     *noStdlib,
+		Engine:       runner.Engine(*engine),
+		Quiet:        *quiet,
+		HistoryPath:  *historyPath,
+		JSON:         *jsonMode,
+	}
+	// a quiet REPL does not greet, so the user is not even looked up
+	if !*quiet && !*jsonMode {
+		options.Banner = banner()
+	}
+	if *transcriptPath != "" {
+		transcript, err := os.OpenFile(*transcriptPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer transcript.Close()
+		options.Transcript = transcript
+	}
+	repl.StartWithOptions(os.Stdin, os.Stdout, options)
+}
+
+// banner greets the user by name when the system knows it
+func banner() string {
+	if name := username(); name != "" {
+		return fmt.Sprintf("Hello %s!\nFeel free to type in commands\n", name)
+	}
+	return "Hello!\nFeel free to type in commands\n"
+}
+
+// generateDoc parses every file and writes its Markdown documentation to standard output.
+// It returns the exit status of the command.
+func generateDoc(files []string, features feature.Set) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey doc <file>...")
+		return 2
+	}
+
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		p := parser.New(lexer.New(string(input)))
+		p.SetFeatures(features)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", file, msg)
+			}
+			return 1
+		}
+		diagnostic.Render(os.Stderr, p.Diagnostics().Warnings())
+
+		fmt.Printf("# %s\n\n%s", file, doc.Generate(program))
+	}
+
+	return 0
+}
+
+// transpileFile parses a single file and writes the Go program translated from it to standard output.
+// It returns the exit status of the command.
+func transpileFile(files []string, features feature.Set) int {
+	if len(files) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey transpile <file>")
+		return 2
+	}
+
+	input, err := os.ReadFile(files[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(input)))
+	p.SetFeatures(features)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", files[0], msg)
+		}
+		return 1
+	}
+	diagnostic.Render(os.Stderr, p.Diagnostics().Warnings())
+
+	source, err := transpile.Transpile(program)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", files[0], err)
+		return 1
+	}
+	os.Stdout.Write(source)
+	return 0
+}
+
+// runFile runs a single file, the remaining arguments are bound to ARGV and the environment to ENV.
+// It returns the exit status of the command.
+func runFile(args []string, features feature.Set) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey run <file> [arguments...]")
+		return 2
+	}
+
+	// the first Ctrl-C stops the script, a second one kills it when it does not stop
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupts := notifyInterrupts()
+	go func() {
+		waitInterrupt(interrupts)
+		cancel()
+		waitInterrupt(interrupts)
+		os.Exit(repl.InterruptExitCode)
+	}()
+
+	object.Output.Inspect.QuoteStrings = *quoteStrings
+	options := runner.Options{
+		Engine:       runner.Engine(*engine),
+		Features:     features,
+		Args:         args[1:],
+		Env:          os.Environ(),
+		Diagnostics:  os.Stderr,
+		Context:      ctx,
+		Strict:       *strict,
+		MaxCallDepth: *maxCallDepth,
+		NoStdlib:     *noStdlib,
+		Cache:        diskCache(),
+	}
+	_, err := runner.RunFile(args[0], options)
+	if exit, ok := err.(*vm.ExitError); ok {
+		return exit.Code
+	}
+	if err != nil && ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "%s: interrupted\n", args[0])
+		return repl.InterruptExitCode
+	}
+	// an uncaught error is reported on standard error, keeping standard output for the output of the script
+	if err != nil {
+		fmt.Fprintln(os.Stderr, report.Format(args[0], err))
+		return 1
+	}
+	return 0
+}
+
+// diskCache returns the cache of compiled programs used by the run and watch commands,
+// nil when it is disabled with --no-cache or the system has no cache directory
+func diskCache() *cache.Cache {
+	if *noCache {
+		return nil
+	}
+	c, err := cache.Default()
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// waitInterrupt waits for an interrupt the script does not handle itself, with `on_signal("SIGINT", f)`
+func waitInterrupt(interrupts <-chan os.Signal) {
+	for range interrupts {
+		if !object.HandlesSignal(os.Interrupt) {
+			return
+		}
+	}
+}
+
+// watchFiles checks the files every time they change until it is interrupted.
+// It returns the exit status of the command.
+func watchFiles(files []string, features feature.Set) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey watch <file>...")
+		return 2
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	interrupts := notifyInterrupts()
+	go func() {
+		<-interrupts
+		stop()
+	}()
+
+	checker := watch.NewChecker(features)
+	checker.Strict = *strict
+	checker.NoStdlib = *noStdlib
+	checker.Cache = diskCache()
+	if err := checker.Watch(ctx, files, *watchInterval, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// runKernel runs the Jupyter kernel with the connection file Jupyter starts it with, or with "install" installs the
+// kernel spec, which starts the kernel with the flags given to this command. It returns the exit status of the command.
+func runKernel(args []string, features feature.Set) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: monkey kernel <connection file>\n       monkey [flags] kernel install")
+		return 2
+	}
+
+	if args[0] == "install" {
+		executable, err := os.Executable()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		flags := []string{}
+		flag.Visit(func(f *flag.Flag) {
+			flags = append(flags, fmt.Sprintf("--%s=%s", f.Name, f.Value))
+		})
+		dir, err := jupyter.Install(executable, flags)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("installed the monkey kernel in %s\n", dir)
+		return 0
+	}
+
+	connection, err := jupyter.ReadConnection(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	kernel, err := jupyter.New(connection, repl.Options{
+		Features:     features,
+		Inspect:      object.InspectOptions{MaxDepth: *maxDepth, MaxWidth: *maxWidth, QuoteStrings: *quoteStrings},
+		Strict:       *strict,
+		MaxCallDepth: *maxCallDepth,
+		NoStdlib:     *noStdlib,
+		Engine:       runner.Engine(*engine),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer kernel.Close()
+
+	// Ctrl-C in the terminal running Jupyter reaches the kernel too, it only stops the running cell
+	interrupts := notifyInterrupts()
+	go func() {
+		for range interrupts {
+			kernel.Interrupt()
+		}
+	}()
+	kernel.Serve()
+	return 0
+}
+
+// printDefinitions indexes the files and prints the bindings at the top of every file,
+// or only the definition of the name at the location given with --at.
+// It returns the exit status of the command.
+func printDefinitions(files []string, features feature.Set) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey [--at=file:line:column] defs <file>...")
+		return 2
+	}
+
+	ix := index.New()
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		p := parser.New(lexer.New(string(input)))
+		p.SetFeatures(features)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", file, msg)
+			}
+			return 1
+		}
+		ix.Add(file, program)
+	}
+
+	if *definitionAt == "" {
+		for _, definition := range ix.Globals() {
+			fmt.Printf("%s\t%s\t%s\n", definition.Name, definition.Location, definition.Kind)
+		}
+		return 0
+	}
+
+	var at index.Location
+	// the file name can contain colons, the line and the column are the last two fields
+	colon := strings.LastIndexByte(*definitionAt, ':')
+	if colon > 0 {
+		colon = strings.LastIndexByte((*definitionAt)[:colon], ':')
+	}
+	if colon <= 0 {
+		fmt.Fprintf(os.Stderr, "invalid location %q, want file:line:column\n", *definitionAt)
+		return 2
+	}
+	at.File = (*definitionAt)[:colon]
+	if _, err := fmt.Sscanf((*definitionAt)[colon+1:], "%d:%d", &at.Line, &at.Column); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid location %q, want file:line:column\n", *definitionAt)
+		return 2
+	}
+	definition, ok := ix.DefinitionAt(at)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: no definition found\n", at)
+		return 1
+	}
+	fmt.Printf("%s\t%s\t%s\n", definition.Name, definition.Location, definition.Kind)
+	return 0
 }
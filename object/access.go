@@ -0,0 +1,157 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+)
+
+// getBuiltin returns the value for a key in a hash (or an index in an array).
+// When the key does not exist, the optional third argument is returned instead of NULL.
+var getBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+		}
+
+		fallback := defaultArgument(args, 2)
+		if value, ok := lookup(args[0], args[1]); ok {
+			return value
+		}
+		return fallback
+	},
+}
+
+// digBuiltin follows an array of keys (or indices) through nested hashes and arrays.
+// When any of the keys does not exist, the optional third argument is returned instead of NULL.
+var digBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+		}
+
+		keys, ok := args[1].(*Array)
+		if !ok {
+			return newError("second argument to `dig` must be ARRAY, got %s", args[1].Type())
+		}
+
+		fallback := defaultArgument(args, 2)
+		value := args[0]
+		for _, key := range keys.Elements {
+			value, ok = lookup(value, key)
+			if !ok {
+				return fallback
+			}
+		}
+		return value
+	},
+}
+
+// defaultArgument returns the argument at the given position or NULL when it was not provided
+func defaultArgument(args []Object, position int) Object {
+	if len(args) > position {
+		return args[position]
+	}
+	return NULL
+}
+
+// lookup finds the value at the key in a hash or at the index in an array,
+// reporting whether it exists. Any other container never contains the key.
+func lookup(container, key Object) (Object, bool) {
+	switch container := container.(type) {
+	case *Hash:
+		hashable, ok := key.(Hashable)
+		if !ok {
+			return nil, false
+		}
+		pair, ok := container.Pairs[hashable.HashKey()]
+		return pair.Value, ok
+	case *Array:
+		index, ok := key.(*Integer)
+		if !ok {
+			return nil, false
+		}
+		i, ok := ArrayIndex(index.Value, len(container.Elements))
+		if !ok {
+			return nil, false
+		}
+		return container.Elements[i], true
+	default:
+		return nil, false
+	}
+}
+
+// MissingKeyMessage is the error message of strict mode for reading a key missing from a hash,
+// the key is printed as a literal so a missing string key reads `missing hash key "name"`
+func MissingKeyMessage(key Object) string {
+	return "missing hash key " + InspectWith(key, InspectOptions{QuoteStrings: true})
+}
+
+// ArrayIndex resolves the index of an element of an array of the given length. A negative index counts
+// from the end of the array, -1 is the last element. It reports false when the index is out of range.
+func ArrayIndex(index int64, length int) (int, bool) {
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// OutOfRangeMessage is the error message of strict mode for indexing an array of the given length out of range
+func OutOfRangeMessage(index int64, length int) string {
+	return fmt.Sprintf("index %d out of range for array of length %d", index, length)
+}
+
+// SliceBounds resolves the bounds of a slice of a sequence of the given kind and length, xs[low:high].
+// A null bound is omitted, the low bound defaults to the start and the high bound to the end. A negative
+// bound counts from the end like a negative index, `xs[-2:]` holds the last two elements.
+// Bounds past either end are clamped and a low bound past the high bound is an empty slice,
+// like indexing out of range is null. In strict mode bounds out of range are an error instead.
+func SliceBounds(kind string, low, high Object, length int, strict bool) (int, int, error) {
+	bounds := [2]int64{0, int64(length)}
+	written := bounds
+	for i, bound := range []Object{low, high} {
+		if bound == NULL {
+			continue
+		}
+		integer, ok := bound.(*Integer)
+		if !ok {
+			return 0, 0, fmt.Errorf("slice bounds must be INTEGER, got %s", bound.Type())
+		}
+		written[i] = integer.Value
+		bounds[i] = integer.Value
+		if bounds[i] < 0 {
+			bounds[i] += int64(length)
+		}
+	}
+
+	lo, hi := bounds[0], bounds[1]
+	if lo < 0 || hi > int64(length) || lo > hi {
+		if strict {
+			return 0, 0, errors.New(SliceOutOfRangeMessage(kind, written[0], written[1], length))
+		}
+		lo, hi = clamp(lo, 0, int64(length)), clamp(hi, 0, int64(length))
+		if lo > hi {
+			lo = hi
+		}
+	}
+	return int(lo), int(hi), nil
+}
+
+// SliceOutOfRangeMessage is the error message of strict mode for slicing a sequence of the given kind,
+// "array" or "string", and length out of range
+func SliceOutOfRangeMessage(kind string, low, high int64, length int) string {
+	return fmt.Sprintf("slice bounds [%d:%d] out of range for %s of length %d", low, high, kind, length)
+}
+
+// clamp limits n to the range from min to max
+func clamp(n, min, max int64) int64 {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -1,12 +1,21 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
 
-// Builtins contains a mapping of the supported built-in functions.
-var Builtins = []struct {
+	"github.com/yourfavoritedev/golang-interpreter/code"
+)
+
+// BuiltinDefinition binds a built-in function to the name it is called by
+type BuiltinDefinition struct {
 	Name    string
 	Builtin *Builtin
-}{
+}
+
+// Builtins is the single registry of built-in functions shared by both engines. The evaluator
+// looks built-in functions up by name, the compiler and the VM address them by their index.
+// Indices are stable: built-in functions are only ever appended, to this list or with RegisterBuiltin.
+var Builtins = []BuiltinDefinition{
 	{
 		"len",
 		&Builtin{
@@ -15,24 +24,23 @@ var Builtins = []struct {
 					return newError("wrong number of arguments. got=%d, want=1", len(args))
 				}
 
-				switch arg := args[0].(type) {
-				case *Array:
-					return &Integer{Value: int64(len(arg.Elements))}
-				case *String:
-					return &Integer{Value: int64(len(arg.Value))}
-				default:
+				if it, ok := args[0].(*Iterator); ok && it.Length == nil {
+					return newError("length of a lazy iterator is unknown, collect it first")
+				}
+				sized, ok := args[0].(Sized)
+				if !ok {
 					return newError("argument to `len` not supported, got=%s", args[0].Type())
 				}
+				return &Integer{Value: int64(sized.Len())}
 			},
+			Pure: true,
 		},
 	},
 	{
 		"puts",
 		&Builtin{
 			Fn: func(args ...Object) Object {
-				for _, arg := range args {
-					fmt.Println(arg.Inspect())
-				}
+				Output.Print(args, "\n")
 				return nil
 			},
 		},
@@ -56,6 +64,7 @@ var Builtins = []struct {
 
 				return nil
 			},
+			Pure: true,
 		},
 	},
 	{
@@ -78,6 +87,7 @@ var Builtins = []struct {
 
 				return nil
 			},
+			Pure: true,
 		},
 	},
 	{
@@ -102,6 +112,7 @@ var Builtins = []struct {
 
 				return nil
 			},
+			Pure: true,
 		},
 	},
 	{
@@ -125,8 +136,69 @@ var Builtins = []struct {
 
 				return &Array{Elements: newElements}
 			},
+			Pure: true,
 		},
 	},
+	{"log_debug", logBuiltin(LogDebug)},
+	{"log_info", logBuiltin(LogInfo)},
+	{"log_warn", logBuiltin(LogWarn)},
+	{"log_error", logBuiltin(LogError)},
+	{"log_level", logLevelBuiltin},
+	{"parse_toml", parseTomlBuiltin},
+	{"render", renderBuiltin},
+	{"get", getBuiltin},
+	{"dig", digBuiltin},
+	{"group_by", groupByBuiltin},
+	{"sort_by", sortByBuiltin},
+	{"unique", uniqueBuiltin},
+	{"min_by", minByBuiltin},
+	{"max_by", maxByBuiltin},
+	{"range", rangeBuiltin},
+	{"map", mapBuiltin},
+	{"filter", filterBuiltin},
+	{"take", takeBuiltin},
+	{"drop", dropBuiltin},
+	{"collect", collectBuiltin},
+	{"to_array", collectBuiltin},
+	{"memoize", memoizeBuiltin},
+	{"apply", applyBuiltin},
+	{"partial", partialBuiltin},
+	{"compose", composeBuiltin},
+	{"identity", identityBuiltin},
+	{"const_fn", constFnBuiltin},
+	{"try", tryBuiltin},
+	{"arity", arityBuiltin},
+	{"params", paramsBuiltin},
+	{"source", sourceBuiltin},
+	{"doc", docBuiltin},
+	{"to_fixed", toFixedBuiltin},
+	{"format_number", formatNumberBuiltin},
+	{"divmod", divmodBuiltin},
+	{"exit", exitBuiltin},
+	{"print", printBuiltin},
+	{"ok", okBuiltin},
+	{"err", errBuiltin},
+	{"is_ok", isOkBuiltin},
+	{"unwrap", unwrapBuiltin},
+	{"unwrap_or", unwrapOrBuiltin},
+	{"float", floatBuiltin},
+	{"int", intBuiltin},
+	{"pmap", pmapBuiltin},
+	{"on_signal", onSignalBuiltin},
+	{"runtime_stats", runtimeStatsBuiltin},
+	// the members of the modules of the standard library, see RegisterModule. Built-in functions added
+	// later come after them, so adding one never changes the index of a member.
+	{"strings.contains", stringsModule["contains"]},
+	{"strings.join", stringsModule["join"]},
+	{"strings.lower", stringsModule["lower"]},
+	{"strings.replace", stringsModule["replace"]},
+	{"strings.split", stringsModule["split"]},
+	{"strings.trim", stringsModule["trim"]},
+	{"strings.upper", stringsModule["upper"]},
+	{"math.abs", mathModule["abs"]},
+	{"math.max", mathModule["max"]},
+	{"math.min", mathModule["min"]},
+	{"math.pow", mathModule["pow"]},
 }
 
 // newError constructs a object.Error with the given format and
@@ -135,12 +207,48 @@ func newError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// builtinIndices maps the name of every built-in function to its index in Builtins
+var builtinIndices = indexBuiltins()
+
+// indexBuiltins builds the index of the built-in functions defined in Builtins. Their order is part of
+// code.InstructionSet, the ones registered later by embedders are not.
+func indexBuiltins() map[string]int {
+	indices := make(map[string]int, len(Builtins))
+	names := make([]string, len(Builtins))
+	for i, def := range Builtins {
+		indices[def.Name] = i
+		names[i] = def.Name
+	}
+	code.SetBuiltins(names)
+	return indices
+}
+
+// RegisterBuiltin adds a built-in function to the registry under the given name and returns its index.
+// Built-in functions must be registered before compiling programs that use them.
+func RegisterBuiltin(name string, builtin *Builtin) (int, error) {
+	if _, ok := builtinIndices[name]; ok {
+		return 0, fmt.Errorf("built-in function %s is already registered", name)
+	}
+	if len(Builtins) >= MaxBuiltins {
+		return 0, fmt.Errorf("too many built-in functions: %s exceeds the limit of %d", name, MaxBuiltins)
+	}
+
+	index := len(Builtins)
+	Builtins = append(Builtins, BuiltinDefinition{Name: name, Builtin: builtin})
+	builtinIndices[name] = index
+	return index, nil
+}
+
+// LookupBuiltin returns the index of the built-in function with the given name in Builtins
+func LookupBuiltin(name string) (int, bool) {
+	index, ok := builtinIndices[name]
+	return index, ok
+}
+
 // GetBuiltInByName simply finds a BuiltIn with a matching name
 func GetBuiltInByName(name string) *Builtin {
-	for _, def := range Builtins {
-		if def.Name == name {
-			return def.Builtin
-		}
+	if index, ok := builtinIndices[name]; ok {
+		return Builtins[index].Builtin
 	}
 	return nil
 }
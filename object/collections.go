@@ -0,0 +1,209 @@
+package object
+
+import "sort"
+
+// groupByBuiltin groups the elements of an array into a hash. The function is called with every element
+// and the key it returns decides the array of elements the element is appended to.
+var groupByBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		arr, errObj := arrayAndFunction("group_by", args)
+		if errObj != nil {
+			return errObj
+		}
+
+		groups := &Hash{Pairs: make(map[HashKey]HashPair)}
+		for _, el := range arr.Elements {
+			key := call(args[1], el)
+			if isError(key) {
+				return key
+			}
+
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", key.Type())
+			}
+
+			hashKey := hashable.HashKey()
+			pair, ok := groups.Pairs[hashKey]
+			if !ok {
+				pair = HashPair{Key: key, Value: &Array{Elements: []Object{}}}
+			}
+			group := pair.Value.(*Array)
+			group.Elements = append(group.Elements, el)
+			groups.Pairs[hashKey] = pair
+		}
+
+		return groups
+	},
+}
+
+// sortByBuiltin returns a new array with the elements sorted by the keys the function returns for them.
+// The keys must all be integers or all be strings, elements with equal keys keep their original order.
+var sortByBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		arr, errObj := arrayAndFunction("sort_by", args)
+		if errObj != nil {
+			return errObj
+		}
+
+		keys, errObj := callForEach(call, args[1], arr.Elements)
+		if errObj != nil {
+			return errObj
+		}
+
+		indices := make([]int, len(arr.Elements))
+		for i := range indices {
+			indices[i] = i
+		}
+
+		var compareErr Object
+		sort.SliceStable(indices, func(i, j int) bool {
+			less, errObj := lessKey("sort_by", keys[indices[i]], keys[indices[j]])
+			if errObj != nil && compareErr == nil {
+				compareErr = errObj
+			}
+			return less
+		})
+		if compareErr != nil {
+			return compareErr
+		}
+
+		sorted := make([]Object, len(indices))
+		for i, index := range indices {
+			sorted[i] = arr.Elements[index]
+		}
+		return &Array{Elements: sorted}
+	},
+}
+
+// uniqueBuiltin returns a new array without duplicate elements, keeping the first occurrence.
+// When a function is given, elements are compared by the keys the function returns for them.
+var uniqueBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+		}
+
+		arr, ok := args[0].(*Array)
+		if !ok {
+			return newError("first argument to `unique` must be ARRAY, got %s", args[0].Type())
+		}
+
+		seen := make(map[HashKey]bool)
+		result := []Object{}
+		for _, el := range arr.Elements {
+			key := el
+			if len(args) == 2 {
+				key = call(args[1], el)
+				if isError(key) {
+					return key
+				}
+			}
+
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", key.Type())
+			}
+
+			if seen[hashable.HashKey()] {
+				continue
+			}
+			seen[hashable.HashKey()] = true
+			result = append(result, el)
+		}
+
+		return &Array{Elements: result}
+	},
+}
+
+// minByBuiltin returns the element for which the function returns the smallest key, or NULL for an empty array
+var minByBuiltin = extremeByBuiltin("min_by", func(candidate, best Object) (bool, Object) {
+	return lessKey("min_by", candidate, best)
+})
+
+// maxByBuiltin returns the element for which the function returns the largest key, or NULL for an empty array
+var maxByBuiltin = extremeByBuiltin("max_by", func(candidate, best Object) (bool, Object) {
+	return lessKey("max_by", best, candidate)
+})
+
+// extremeByBuiltin creates a built-in function that returns the first element whose key
+// is preferred over the keys of all other elements by the better function.
+func extremeByBuiltin(name string, better func(candidate, best Object) (bool, Object)) *Builtin {
+	return &Builtin{
+		HigherOrderFn: func(call CallFunction, args ...Object) Object {
+			arr, errObj := arrayAndFunction(name, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+
+			keys, errObj := callForEach(call, args[1], arr.Elements)
+			if errObj != nil {
+				return errObj
+			}
+
+			best := 0
+			for i := 1; i < len(keys); i++ {
+				ok, errObj := better(keys[i], keys[best])
+				if errObj != nil {
+					return errObj
+				}
+				if ok {
+					best = i
+				}
+			}
+			return arr.Elements[best]
+		},
+	}
+}
+
+// arrayAndFunction validates the arguments of a built-in function that expects an array and a function
+func arrayAndFunction(name string, args []Object) (*Array, Object) {
+	if len(args) != 2 {
+		return nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, newError("first argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	return arr, nil
+}
+
+// callForEach calls the function with every element and returns the results,
+// stopping at the first call that fails.
+func callForEach(call CallFunction, fn Object, elements []Object) ([]Object, Object) {
+	results := make([]Object, len(elements))
+	for i, el := range elements {
+		result := call(fn, el)
+		if isError(result) {
+			return nil, result
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// lessKey reports whether the key a is ordered before the key b. Keys must both be integers or both be strings.
+func lessKey(name string, a, b Object) (bool, Object) {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value, nil
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return a.Value < b.Value, nil
+		}
+	}
+	return false, newError("keys returned to `%s` must both be INTEGER or STRING, got %s and %s", name, a.Type(), b.Type())
+}
+
+// isError reports whether the object is an Error
+func isError(obj Object) bool {
+	return obj != nil && obj.Type() == ERROR_OBJ
+}
@@ -0,0 +1,65 @@
+package object
+
+import (
+	"github.com/yourfavoritedev/golang-interpreter/toml"
+)
+
+// parseTomlBuiltin decodes a TOML document into nested hashes, letting Monkey programs
+// be configured with the same files as the Go applications embedding them.
+var parseTomlBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		input, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `parse_toml` must be STRING, got %s", args[0].Type())
+		}
+
+		document, err := toml.Parse(input.Value)
+		if err != nil {
+			return newError("%s", err)
+		}
+
+		return FromNative(document)
+	},
+}
+
+// FromNative converts a decoded Go value into its Monkey object. Maps with string keys become hashes,
+// slices become arrays and strings, integers, floats and booleans become their respective objects.
+// Any other value is converted to NULL.
+func FromNative(value interface{}) Object {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		pairs := make(map[HashKey]HashPair)
+		for k, v := range value {
+			key := &String{Value: k}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: FromNative(v)}
+		}
+		return &Hash{Pairs: pairs}
+	case []interface{}:
+		elements := make([]Object, len(value))
+		for i, v := range value {
+			elements[i] = FromNative(v)
+		}
+		return &Array{Elements: elements}
+	case string:
+		return &String{Value: value}
+	case int64:
+		return &Integer{Value: value}
+	case int:
+		return &Integer{Value: int64(value)}
+	case float64:
+		return &Float{Value: value}
+	case bool:
+		if value {
+			return TRUE
+		}
+		return FALSE
+	case Object:
+		return value
+	default:
+		return NULL
+	}
+}
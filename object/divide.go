@@ -0,0 +1,41 @@
+package object
+
+// Monkey offers two kinds of integer division. `/` truncates towards zero like Go does, so `-7 / 2` is -3.
+// `//` floors towards negative infinity, so `-7 // 2` is -4, and `divmod` returns the floored quotient
+// together with a remainder that always has the sign of the divisor, so `divmod(-7, 2)` is [-4, 1].
+
+// FloorDivide divides a by b rounding the quotient towards negative infinity.
+// The remainder has the sign of b, and a == q*b + r always holds. b must not be 0.
+func FloorDivide(a, b int64) (q, r int64) {
+	q, r = a/b, a%b
+	// truncation rounded up whenever the remainder and the divisor have opposite signs
+	if r != 0 && (r < 0) != (b < 0) {
+		q--
+		r += b
+	}
+	return q, r
+}
+
+// divmodBuiltin returns the floored quotient and the remainder of two integers, `divmod(7, 2)` is [3, 1]
+var divmodBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		a, ok := args[0].(*Integer)
+		if !ok {
+			return newError("first argument to `divmod` must be INTEGER, got %s", args[0].Type())
+		}
+		b, ok := args[1].(*Integer)
+		if !ok {
+			return newError("second argument to `divmod` must be INTEGER, got %s", args[1].Type())
+		}
+		if b.Value == 0 {
+			return newError("division by zero")
+		}
+
+		q, r := FloorDivide(a.Value, b.Value)
+		return &Array{Elements: []Object{&Integer{Value: q}, &Integer{Value: r}}}
+	},
+	Pure: true,
+}
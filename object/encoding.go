@@ -0,0 +1,679 @@
+package object
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+)
+
+// EncodingVersion is the version of the serialization format written by EncodeJSON and EncodeBinary.
+// It must be incremented whenever the meaning of an encoded value changes, decoding refuses any other version.
+// The instructions of compiled functions are versioned separately by code.InstructionSet, which every encoded
+// document records next to the version since version 2.
+const EncodingVersion = 3
+
+// binaryMagic starts every binary encoded value, it is followed by a single byte holding the EncodingVersion
+// and the code.InstructionSet the value was encoded with
+const binaryMagic = "MKY"
+
+// CheckCompatible returns an error when a document encoded with the given version and instruction set
+// cannot be decoded: the format changed, or its compiled functions were compiled for other opcodes.
+func CheckCompatible(version int, instructionSet string) error {
+	if version != EncodingVersion {
+		return fmt.Errorf("unsupported encoding version %d, expected %d", version, EncodingVersion)
+	}
+	if instructionSet != code.InstructionSet {
+		return fmt.Errorf("bytecode compiled for instruction set %q cannot run on instruction set %q, compile it again",
+			instructionSet, code.InstructionSet)
+	}
+	return nil
+}
+
+// EncodedObject is the serializable representation of an Object, only the fields of its Type are set.
+// Arrays keep their values in Elements, hashes keep their keys in Keys and the matching values in Elements.
+// Records keep the names of their fields in Fields and the matching values in Elements.
+// A closure refers to its compiled function by its index in the constant pool with Constant,
+// or carries the compiled function itself in Function when the function is not part of the pool.
+type EncodedObject struct {
+	Type          ObjectType       `json:"type"`
+	Integer       int64            `json:"integer,omitempty"`
+	String        string           `json:"string,omitempty"`
+	Boolean       bool             `json:"boolean,omitempty"`
+	Elements      []*EncodedObject `json:"elements,omitempty"`
+	Keys          []*EncodedObject `json:"keys,omitempty"`
+	Name          string           `json:"name,omitempty"`
+	Constant      *int             `json:"constant,omitempty"`
+	Function      *EncodedObject   `json:"function,omitempty"`
+	Instructions  []byte           `json:"instructions,omitempty"`
+	NumLocals     int              `json:"numLocals,omitempty"`
+	NumParameters int              `json:"numParameters,omitempty"`
+	NumDefaults   int              `json:"numDefaults,omitempty"`
+	Variadic      bool             `json:"variadic,omitempty"`
+	MaxStack      int              `json:"maxStack,omitempty"`
+	Parameters    []string         `json:"parameters,omitempty"`
+	Fields        []string         `json:"fields,omitempty"`
+	Source        string           `json:"source,omitempty"`
+	Doc           string           `json:"doc,omitempty"`
+}
+
+// encodedDocument is the versioned JSON document written by EncodeJSON
+type encodedDocument struct {
+	Version        int            `json:"version"`
+	InstructionSet string         `json:"instructionSet"`
+	Value          *EncodedObject `json:"value"`
+}
+
+// Encoder converts objects into their EncodedObject representation.
+// Closures whose function is one of the Constants are encoded as a reference into the constant pool,
+// so decoding them with the same constants restores the shared function instead of a copy.
+type Encoder struct {
+	Constants []Object
+}
+
+// Decoder converts EncodedObjects back into objects.
+// Closures referencing the constant pool are resolved against its Constants.
+type Decoder struct {
+	Constants []Object
+}
+
+// Encode converts the object into its serializable representation.
+// Only values without hidden state can be encoded, evaluator functions and iterators return an error.
+func (e *Encoder) Encode(obj Object) (*EncodedObject, error) {
+	if obj == nil {
+		return nil, fmt.Errorf("cannot encode a missing value")
+	}
+	encoded := &EncodedObject{Type: obj.Type()}
+
+	switch obj := obj.(type) {
+	case *Integer:
+		encoded.Integer = obj.Value
+	case *Float:
+		// the bits of the float are stored as an integer, JSON has no infinities or NaN
+		encoded.Integer = int64(math.Float64bits(obj.Value))
+	case *String:
+		encoded.String = obj.Value
+	case *Boolean:
+		encoded.Boolean = obj.Value
+	case *Null:
+	case *Error:
+		encoded.String = obj.Message
+	case *StringKey:
+		encoded.String = obj.String.Value
+	case *Symbol:
+		encoded.String = obj.Name
+	case *ReturnValue:
+		value, err := e.Encode(obj.Value)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Elements = []*EncodedObject{value}
+	case *Array:
+		elements, err := e.encodeAll(obj.Elements)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Elements = elements
+	case *Hash:
+		for _, pair := range obj.Pairs {
+			key, err := e.Encode(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := e.Encode(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			encoded.Keys = append(encoded.Keys, key)
+			encoded.Elements = append(encoded.Elements, value)
+		}
+	case *Record:
+		values, err := e.encodeAll(obj.Values)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Fields = obj.Shape.Fields
+		encoded.Elements = values
+	case *RecordShape:
+		encoded.Fields = obj.Fields
+	case *CompiledFunction:
+		encoded.Instructions = obj.Instructions
+		encoded.NumLocals = obj.NumLocals
+		encoded.NumParameters = obj.NumParameters
+		encoded.NumDefaults = obj.NumDefaults
+		encoded.Variadic = obj.Variadic
+		encoded.MaxStack = obj.MaxStack
+		encoded.Name = obj.Name
+		encoded.Parameters = obj.Parameters
+		encoded.Source = obj.Source
+		encoded.Doc = obj.Doc
+	case *Closure:
+		if index := e.constantIndex(obj.Fn); index != -1 {
+			encoded.Constant = &index
+		} else {
+			fn, err := e.Encode(obj.Fn)
+			if err != nil {
+				return nil, err
+			}
+			encoded.Function = fn
+		}
+		free, err := e.encodeAll(obj.Free)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Elements = free
+	case *Builtin:
+		encoded.Name = builtinName(obj)
+		if encoded.Name == "" {
+			return nil, fmt.Errorf("cannot encode a built-in function that is not registered")
+		}
+	case *Module:
+		encoded.Name = obj.Name
+	default:
+		return nil, fmt.Errorf("values of type %s cannot be encoded", obj.Type())
+	}
+
+	return encoded, nil
+}
+
+// encodeAll encodes a list of objects
+func (e *Encoder) encodeAll(objects []Object) ([]*EncodedObject, error) {
+	encoded := make([]*EncodedObject, len(objects))
+	for i, obj := range objects {
+		el, err := e.Encode(obj)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = el
+	}
+	return encoded, nil
+}
+
+// constantIndex returns the index of the function in the constant pool, or -1 when it is not part of it
+func (e *Encoder) constantIndex(fn *CompiledFunction) int {
+	for i, constant := range e.Constants {
+		if constant == fn {
+			return i
+		}
+	}
+	return -1
+}
+
+// builtinName returns the name a built-in function is registered with, or an empty string.
+// Built-in functions created at runtime (memoize, partial) are not registered.
+func builtinName(builtin *Builtin) string {
+	for _, def := range Builtins {
+		if def.Builtin == builtin {
+			return def.Name
+		}
+	}
+	return ""
+}
+
+// Decode converts the encoded representation back into an object.
+// Booleans and null decode to the TRUE, FALSE and NULL singletons, symbols are interned again and built-in
+// functions and modules decode to the registered ones, so they keep comparing equal by identity.
+func (d *Decoder) Decode(encoded *EncodedObject) (Object, error) {
+	if encoded == nil {
+		return nil, fmt.Errorf("invalid encoding: missing value")
+	}
+
+	switch encoded.Type {
+	case INTEGER_OBJ:
+		return &Integer{Value: encoded.Integer}, nil
+	case FLOAT_OBJ:
+		return &Float{Value: math.Float64frombits(uint64(encoded.Integer))}, nil
+	case STRING_OBJ:
+		return &String{Value: encoded.String}, nil
+	case BOOLEAN_OBJ:
+		if encoded.Boolean {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case NULL_OBJ:
+		return NULL, nil
+	case ERROR_OBJ:
+		return &Error{Message: encoded.String}, nil
+	case HASH_KEY_OBJ:
+		return NewStringKey(encoded.String), nil
+	case SYMBOL_OBJ:
+		return Intern(encoded.String), nil
+	case RETURN_VALUE_OBJ:
+		if len(encoded.Elements) != 1 {
+			return nil, fmt.Errorf("invalid encoding: return value has %d values", len(encoded.Elements))
+		}
+		value, err := d.Decode(encoded.Elements[0])
+		if err != nil {
+			return nil, err
+		}
+		return &ReturnValue{Value: value}, nil
+	case ARRAY_OBJ:
+		elements, err := d.decodeAll(encoded.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return &Array{Elements: elements}, nil
+	case HASH_OBJ:
+		if len(encoded.Keys) != len(encoded.Elements) {
+			return nil, fmt.Errorf("invalid encoding: hash has %d keys and %d values", len(encoded.Keys), len(encoded.Elements))
+		}
+		keys, err := d.decodeAll(encoded.Keys)
+		if err != nil {
+			return nil, err
+		}
+		values, err := d.decodeAll(encoded.Elements)
+		if err != nil {
+			return nil, err
+		}
+		hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+		for i, key := range keys {
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("invalid encoding: unusable as hash key: %s", key.Type())
+			}
+			hash.Pairs[hashable.HashKey()] = HashPair{Key: key, Value: values[i]}
+		}
+		return hash, nil
+	case RECORD_OBJ:
+		if len(encoded.Fields) != len(encoded.Elements) {
+			return nil, fmt.Errorf("invalid encoding: record has %d fields and %d values", len(encoded.Fields), len(encoded.Elements))
+		}
+		values, err := d.decodeAll(encoded.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return &Record{Shape: &RecordShape{Fields: encoded.Fields}, Values: values}, nil
+	case RECORD_SHAPE_OBJ:
+		return &RecordShape{Fields: encoded.Fields}, nil
+	case COMPILED_FUNCTION_OBJ:
+		return &CompiledFunction{
+			Instructions:  encoded.Instructions,
+			NumLocals:     encoded.NumLocals,
+			NumParameters: encoded.NumParameters,
+			NumDefaults:   encoded.NumDefaults,
+			Variadic:      encoded.Variadic,
+			MaxStack:      encoded.MaxStack,
+			Name:          encoded.Name,
+			Parameters:    encoded.Parameters,
+			Source:        encoded.Source,
+			Doc:           encoded.Doc,
+		}, nil
+	case CLOSURE_OBJ:
+		fn, err := d.closureFunction(encoded)
+		if err != nil {
+			return nil, err
+		}
+		free, err := d.decodeAll(encoded.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return &Closure{Fn: fn, Free: free}, nil
+	case BUILTIN_OBJ:
+		builtin := GetBuiltInByName(encoded.Name)
+		if builtin == nil {
+			return nil, fmt.Errorf("invalid encoding: unknown built-in function %s", encoded.Name)
+		}
+		return builtin, nil
+	case MODULE_OBJ:
+		module := GetModuleByName(encoded.Name)
+		if module == nil {
+			return nil, fmt.Errorf("invalid encoding: unknown module %s", encoded.Name)
+		}
+		return module, nil
+	default:
+		return nil, fmt.Errorf("invalid encoding: unknown type %s", encoded.Type)
+	}
+}
+
+// closureFunction returns the compiled function of an encoded closure,
+// either from the constant pool or decoded from the closure itself
+func (d *Decoder) closureFunction(encoded *EncodedObject) (*CompiledFunction, error) {
+	var fn Object
+	switch {
+	case encoded.Constant != nil:
+		index := *encoded.Constant
+		if index < 0 || index >= len(d.Constants) {
+			return nil, fmt.Errorf("invalid encoding: closure refers to missing constant %d", index)
+		}
+		fn = d.Constants[index]
+	case encoded.Function != nil:
+		decoded, err := d.Decode(encoded.Function)
+		if err != nil {
+			return nil, err
+		}
+		fn = decoded
+	default:
+		return nil, fmt.Errorf("invalid encoding: closure without a function")
+	}
+
+	compiled, ok := fn.(*CompiledFunction)
+	if !ok {
+		return nil, fmt.Errorf("invalid encoding: closure function has type %s", fn.Type())
+	}
+	return compiled, nil
+}
+
+// decodeAll decodes a list of encoded objects
+func (d *Decoder) decodeAll(encoded []*EncodedObject) ([]Object, error) {
+	objects := make([]Object, len(encoded))
+	for i, el := range encoded {
+		decoded, err := d.Decode(el)
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = decoded
+	}
+	return objects, nil
+}
+
+// EncodeJSON writes the object as a versioned JSON document,
+// closures referencing one of the constants are written as a reference into the pool
+func EncodeJSON(w io.Writer, obj Object, constants []Object) error {
+	encoder := &Encoder{Constants: constants}
+	encoded, err := encoder.Encode(obj)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(encodedDocument{Version: EncodingVersion, InstructionSet: code.InstructionSet, Value: encoded})
+}
+
+// DecodeJSON reads an object written by EncodeJSON, resolving closures against the constants
+func DecodeJSON(r io.Reader, constants []Object) (Object, error) {
+	var doc encodedDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid encoding: %s", err)
+	}
+	if err := CheckCompatible(doc.Version, doc.InstructionSet); err != nil {
+		return nil, err
+	}
+	decoder := &Decoder{Constants: constants}
+	return decoder.Decode(doc.Value)
+}
+
+// EncodeBinary returns the compact binary encoding of the object.
+// It holds the same information as the JSON encoding.
+func EncodeBinary(obj Object, constants []Object) ([]byte, error) {
+	encoder := &Encoder{Constants: constants}
+	encoded, err := encoder.Encode(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(binaryMagic)
+	buf.WriteByte(EncodingVersion)
+	writeString(&buf, code.InstructionSet)
+	writeBinary(&buf, encoded)
+	return buf.Bytes(), nil
+}
+
+// DecodeBinary reads an object written by EncodeBinary, resolving closures against the constants
+func DecodeBinary(data []byte, constants []Object) (Object, error) {
+	header := len(binaryMagic) + 1
+	if len(data) < header || string(data[:len(binaryMagic)]) != binaryMagic {
+		return nil, fmt.Errorf("invalid encoding: missing header")
+	}
+	// the instruction set follows the version since version 2, the header of another version is not read further
+	if version := int(data[len(binaryMagic)]); version != EncodingVersion {
+		return nil, fmt.Errorf("unsupported encoding version %d, expected %d", version, EncodingVersion)
+	}
+
+	r := bytes.NewReader(data[header:])
+	instructionSet, err := readString(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encoding: %s", err)
+	}
+	if err := CheckCompatible(EncodingVersion, instructionSet); err != nil {
+		return nil, err
+	}
+	encoded, err := readBinary(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encoding: %s", err)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("invalid encoding: unexpected data after value")
+	}
+
+	decoder := &Decoder{Constants: constants}
+	return decoder.Decode(encoded)
+}
+
+// writeBinary appends the encoded object to the buffer.
+// Every value starts with its type, the fields of the type follow in a fixed order.
+func writeBinary(buf *bytes.Buffer, encoded *EncodedObject) {
+	writeString(buf, string(encoded.Type))
+
+	switch encoded.Type {
+	case INTEGER_OBJ, FLOAT_OBJ:
+		writeInt(buf, encoded.Integer)
+	case STRING_OBJ, ERROR_OBJ, HASH_KEY_OBJ, SYMBOL_OBJ:
+		writeString(buf, encoded.String)
+	case BOOLEAN_OBJ:
+		if encoded.Boolean {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case ARRAY_OBJ, RETURN_VALUE_OBJ:
+		writeList(buf, encoded.Elements)
+	case HASH_OBJ:
+		writeList(buf, encoded.Keys)
+		writeList(buf, encoded.Elements)
+	case COMPILED_FUNCTION_OBJ:
+		writeString(buf, string(encoded.Instructions))
+		writeInt(buf, int64(encoded.NumLocals))
+		writeInt(buf, int64(encoded.NumParameters))
+		writeInt(buf, int64(encoded.NumDefaults))
+		writeInt(buf, int64(encoded.MaxStack))
+		if encoded.Variadic {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		writeString(buf, encoded.Name)
+		writeStrings(buf, encoded.Parameters)
+		writeString(buf, encoded.Source)
+		writeString(buf, encoded.Doc)
+	case RECORD_OBJ:
+		writeStrings(buf, encoded.Fields)
+		writeList(buf, encoded.Elements)
+	case RECORD_SHAPE_OBJ:
+		writeStrings(buf, encoded.Fields)
+	case CLOSURE_OBJ:
+		if encoded.Constant != nil {
+			writeInt(buf, int64(*encoded.Constant))
+		} else {
+			writeInt(buf, -1)
+			writeBinary(buf, encoded.Function)
+		}
+		writeList(buf, encoded.Elements)
+	case BUILTIN_OBJ, MODULE_OBJ:
+		writeString(buf, encoded.Name)
+	}
+}
+
+// writeInt appends a variable length signed integer
+func writeInt(buf *bytes.Buffer, value int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(scratch[:], value)
+	buf.Write(scratch[:n])
+}
+
+// writeString appends the length of the string followed by its bytes
+func writeString(buf *bytes.Buffer, value string) {
+	writeInt(buf, int64(len(value)))
+	buf.WriteString(value)
+}
+
+// writeStrings appends the number of strings followed by every string
+func writeStrings(buf *bytes.Buffer, list []string) {
+	writeInt(buf, int64(len(list)))
+	for _, s := range list {
+		writeString(buf, s)
+	}
+}
+
+// writeList appends the number of objects followed by every object
+func writeList(buf *bytes.Buffer, list []*EncodedObject) {
+	writeInt(buf, int64(len(list)))
+	for _, el := range list {
+		writeBinary(buf, el)
+	}
+}
+
+// readBinary reads an object written by writeBinary
+func readBinary(r *bytes.Reader) (*EncodedObject, error) {
+	typ, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	encoded := &EncodedObject{Type: ObjectType(typ)}
+
+	switch encoded.Type {
+	case INTEGER_OBJ, FLOAT_OBJ:
+		encoded.Integer, err = binary.ReadVarint(r)
+	case STRING_OBJ, ERROR_OBJ, HASH_KEY_OBJ, SYMBOL_OBJ:
+		encoded.String, err = readString(r)
+	case BOOLEAN_OBJ:
+		var b byte
+		b, err = r.ReadByte()
+		encoded.Boolean = b == 1
+	case NULL_OBJ:
+	case ARRAY_OBJ, RETURN_VALUE_OBJ:
+		encoded.Elements, err = readList(r)
+	case HASH_OBJ:
+		if encoded.Keys, err = readList(r); err == nil {
+			encoded.Elements, err = readList(r)
+		}
+	case RECORD_OBJ:
+		if encoded.Fields, err = readStrings(r); err == nil {
+			encoded.Elements, err = readList(r)
+		}
+	case RECORD_SHAPE_OBJ:
+		encoded.Fields, err = readStrings(r)
+	case COMPILED_FUNCTION_OBJ:
+		err = readFunction(r, encoded)
+	case CLOSURE_OBJ:
+		var constant int
+		if constant, err = readLength(r, -1); err != nil {
+			break
+		}
+		if constant == -1 {
+			encoded.Function, err = readBinary(r)
+		} else {
+			encoded.Constant = &constant
+		}
+		if err == nil {
+			encoded.Elements, err = readList(r)
+		}
+	case BUILTIN_OBJ, MODULE_OBJ:
+		encoded.Name, err = readString(r)
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return encoded, nil
+}
+
+// readFunction reads the fields of a compiled function written by writeBinary
+func readFunction(r *bytes.Reader, encoded *EncodedObject) error {
+	instructions, err := readString(r)
+	if err != nil {
+		return err
+	}
+	encoded.Instructions = []byte(instructions)
+
+	for _, field := range []*int{&encoded.NumLocals, &encoded.NumParameters, &encoded.NumDefaults, &encoded.MaxStack} {
+		if *field, err = readLength(r, 0); err != nil {
+			return err
+		}
+	}
+	variadic, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	encoded.Variadic = variadic == 1
+	if encoded.Name, err = readString(r); err != nil {
+		return err
+	}
+
+	if encoded.Parameters, err = readStrings(r); err != nil {
+		return err
+	}
+
+	if encoded.Source, err = readString(r); err != nil {
+		return err
+	}
+	encoded.Doc, err = readString(r)
+	return err
+}
+
+// readStrings reads a list of strings written by writeStrings
+func readStrings(r *bytes.Reader) ([]string, error) {
+	count, err := readLength(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for i := 0; i < count; i++ {
+		s, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// readLength reads a variable length integer that must not be lower than min
+func readLength(r *bytes.Reader, min int) (int, error) {
+	value, err := binary.ReadVarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if value < int64(min) || value > int64(^uint32(0)>>1) {
+		return 0, errors.New("length out of range")
+	}
+	return int(value), nil
+}
+
+// readString reads a string written by writeString
+func readString(r *bytes.Reader) (string, error) {
+	length, err := readLength(r, 0)
+	if err != nil {
+		return "", err
+	}
+	if length > r.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+// readList reads a list written by writeList
+func readList(r *bytes.Reader) ([]*EncodedObject, error) {
+	count, err := readLength(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	var list []*EncodedObject
+	for i := 0; i < count; i++ {
+		el, err := readBinary(r)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, el)
+	}
+	return list, nil
+}
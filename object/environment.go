@@ -5,8 +5,12 @@ package object
 // it was original bound too.
 type Environment struct {
 	store map[string]Object
+	// constants holds the names of the store bound by SetConstant, it is nil until a constant is bound
+	constants map[string]bool
 	// The environment that encloses this one. Outer will be set to "nil" if no enclosing environment.
 	outer *Environment
+	// block is set for the environments of blocks, they belong to the function or program enclosing them
+	block bool
 }
 
 // Get uses the given name to find an associated Object in the Environment store.
@@ -22,13 +26,66 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Names returns the names bound in the environment and in the environments enclosing it
+func (e *Environment) Names() []string {
+	names := []string{}
+	for ; e != nil; e = e.outer {
+		for name := range e.store {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // Set will use the given name to update the associated entry in the
-// Environment store with the new value
+// Environment store with the new value. A constant binding of the name is replaced with a binding that is not.
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
+	delete(e.constants, name)
 	return val
 }
 
+// SetConstant binds the name like Set, the binding is marked constant so the evaluator refuses to assign to it
+func (e *Environment) SetConstant(name string, val Object) Object {
+	e.store[name] = val
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name] = true
+	return val
+}
+
+// IsConstant reports whether the binding of the name in this environment, not the outer ones, was made by SetConstant
+func (e *Environment) IsConstant(name string) bool {
+	return e.constants[name]
+}
+
+// Owner returns the environment holding the binding of the name, searching the outer environments like Get.
+// It returns nil when the name is not bound in any of them.
+func (e *Environment) Owner(name string) *Environment {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return env
+		}
+	}
+	return nil
+}
+
+// Global reports whether the environment holds bindings made at the top of the program, outside of any function:
+// it is the outermost one or the environment of a block enclosed by it
+func (e *Environment) Global() bool {
+	return e.Function().outer == nil
+}
+
+// Function returns the environment of the function call the environment belongs to, or the outermost environment
+// for the top of the program. It is the environment itself unless it was created by NewBlockEnvironment.
+func (e *Environment) Function() *Environment {
+	for e.block {
+		e = e.outer
+	}
+	return e
+}
+
 // NewEnvironment creates a new instance of an Environment
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
@@ -58,3 +115,12 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env.outer = outer
 	return env
 }
+
+// NewBlockEnvironment extends the given Environment (outer) for the statements of a block, like the body of an if
+// expression or a loop. The names bound in the block are only visible inside of it, like with NewEnclosedEnvironment,
+// but the block stays part of the function it is in: Function and Global see through it.
+func NewBlockEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.block = true
+	return env
+}
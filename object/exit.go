@@ -0,0 +1,27 @@
+package object
+
+import "fmt"
+
+// MaxExitCode is the highest exit status a program can end with, like in shells
+const MaxExitCode = 255
+
+// exitBuiltin ends the program with the given exit status, 0 when it is called without arguments.
+// It returns an exit error that stops both engines, the runner of the program turns it into the exit status of the process.
+var exitBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) > 1 {
+			return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+		}
+
+		code := int64(0)
+		if len(args) == 1 {
+			integer, ok := args[0].(*Integer)
+			if !ok || integer.Value < 0 || integer.Value > MaxExitCode {
+				return newError("argument to `exit` must be an INTEGER between 0 and %d, got %s", MaxExitCode, args[0].Inspect())
+			}
+			code = integer.Value
+		}
+
+		return &Error{Message: fmt.Sprintf("exit status %d", code), Exit: true, Code: int(code)}
+	},
+}
@@ -0,0 +1,92 @@
+package object
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Floats follow IEEE 754 like Go's float64: `1.0 / 0` is an infinity instead of an error.
+// An operation mixing an integer and a float promotes the integer, so `1 + 0.5` is 1.5 and `1 == 1.0` is true.
+// Floor division floors the float quotient and reports a division by zero like it does for integers.
+
+const FLOAT_OBJ = "FLOAT"
+
+// Float is the referenced struct for floating-point literals in our object system
+type Float struct {
+	Value float64
+}
+
+// Type returns the ObjectType (FLOAT_OBJ) associated with the referenced Float struct
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect returns the shortest representation of the float that reads back to the same value,
+// whole numbers keep a ".0" so they are not mistaken for integers
+func (f *Float) Inspect() string {
+	formatted := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(formatted, ".eIN") {
+		formatted += ".0"
+	}
+	return formatted
+}
+
+// HashKey uses the bits of the float as the hash-key value, so equal floats are the same key
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+// ToFloat returns the value of an integer or a float as a float64, it reports false for other objects.
+// Both engines use it to promote the integer operand of an operation mixing integers and floats.
+func ToFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Float:
+		return obj.Value, true
+	case *Integer:
+		return float64(obj.Value), true
+	default:
+		return 0, false
+	}
+}
+
+// IsNumber reports whether the object is an integer or a float
+func IsNumber(obj Object) bool {
+	_, ok := ToFloat(obj)
+	return ok
+}
+
+// floatBuiltin converts an integer to a float, `float(1)` is 1.0. Floats are returned unchanged.
+var floatBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		value, ok := ToFloat(args[0])
+		if !ok {
+			return newError("argument to `float` must be a number, got %s", args[0].Type())
+		}
+		return &Float{Value: value}
+	},
+	Pure: true,
+}
+
+// intBuiltin converts a float to an integer by truncating it towards zero, `int(-1.5)` is -1.
+// Integers are returned unchanged, infinities and NaN cannot be converted.
+var intBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		switch n := args[0].(type) {
+		case *Integer:
+			return n
+		case *Float:
+			if math.IsInf(n.Value, 0) || math.IsNaN(n.Value) {
+				return newError("cannot convert %s to an integer", n.Inspect())
+			}
+			return &Integer{Value: int64(n.Value)}
+		default:
+			return newError("argument to `int` must be a number, got %s", args[0].Type())
+		}
+	},
+	Pure: true,
+}
@@ -0,0 +1,93 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Number formatting never depends on the locale of the machine running the script:
+// the decimal point is always "." and digits are grouped only when asked for.
+
+// toFixedBuiltin formats a number with a fixed number of digits after the decimal point, `to_fixed(5, 2)` is "5.00"
+var toFixedBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		digits, ok := args[1].(*Integer)
+		if !ok || digits.Value < 0 {
+			return newError("second argument to `to_fixed` must be a non-negative INTEGER, got %s", args[1].Inspect())
+		}
+
+		switch n := args[0].(type) {
+		case *Integer:
+			formatted := strconv.FormatInt(n.Value, 10)
+			if digits.Value > 0 {
+				formatted += "." + strings.Repeat("0", int(digits.Value))
+			}
+			return &String{Value: formatted}
+		case *Float:
+			return &String{Value: strconv.FormatFloat(n.Value, 'f', int(digits.Value), 64)}
+		default:
+			return newError("first argument to `to_fixed` must be a number, got %s", args[0].Type())
+		}
+	},
+	Pure: true,
+}
+
+// formatNumberBuiltin formats a number with its digits grouped by thousands,
+// `format_number(1234567)` is "1,234,567". An optional second argument replaces the "," separator.
+var formatNumberBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+		}
+		separator := ","
+		if len(args) == 2 {
+			str, ok := args[1].(*String)
+			if !ok {
+				return newError("second argument to `format_number` must be STRING, got %s", args[1].Type())
+			}
+			separator = str.Value
+		}
+
+		switch n := args[0].(type) {
+		case *Integer:
+			return &String{Value: groupThousands(strconv.FormatInt(n.Value, 10), separator)}
+		case *Float:
+			return &String{Value: groupThousands(strconv.FormatFloat(n.Value, 'f', -1, 64), separator)}
+		case *String:
+			// formatting the result of to_fixed keeps its fraction intact
+			if _, err := strconv.ParseFloat(n.Value, 64); err != nil {
+				return newError("first argument to `format_number` must be a number, got %q", n.Value)
+			}
+			return &String{Value: groupThousands(n.Value, separator)}
+		default:
+			return newError("first argument to `format_number` must be a number, got %s", args[0].Type())
+		}
+	},
+	Pure: true,
+}
+
+// groupThousands inserts the separator between every group of three digits of the integer part of a formatted number
+func groupThousands(number, separator string) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") || strings.HasPrefix(number, "+") {
+		sign, number = number[:1], number[1:]
+	}
+	fraction := ""
+	if dot := strings.IndexByte(number, '.'); dot != -1 {
+		number, fraction = number[:dot], number[dot:]
+	}
+
+	var out strings.Builder
+	out.WriteString(sign)
+	for i, digit := range number {
+		if i > 0 && (len(number)-i)%3 == 0 {
+			out.WriteString(separator)
+		}
+		out.WriteRune(digit)
+	}
+	out.WriteString(fraction)
+	return out.String()
+}
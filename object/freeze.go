@@ -0,0 +1,91 @@
+package object
+
+// Constants are shared: the compiler puts every constant of a program in a single pool, the bytecode can be
+// run by any number of VMs, one after another or at the same time, and the REPL reuses the bytecode of lines
+// it compiled before. A constant must therefore never change once it is in the pool. Scalars and strings are
+// immutable anyway, the compiler freezes the collections it puts in the pool with Freeze. Code that changes
+// a collection in place, like a built-in function registered by an embedder, calls Mutable first: a frozen
+// collection is copied on write, the copy shares the elements of the original, which stay frozen themselves.
+
+// Freeze marks the collection and every collection it contains as frozen and returns it.
+// Values that are immutable anyway are returned as they are.
+func Freeze(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		// a frozen collection is not visited again, which also stops at a collection containing itself
+		if obj.frozen {
+			return obj
+		}
+		obj.frozen = true
+		for _, el := range obj.Elements {
+			Freeze(el)
+		}
+	case *Hash:
+		if obj.frozen {
+			return obj
+		}
+		obj.frozen = true
+		for _, pair := range obj.Pairs {
+			Freeze(pair.Key)
+			Freeze(pair.Value)
+		}
+	case *Record:
+		if obj.frozen {
+			return obj
+		}
+		obj.frozen = true
+		for _, value := range obj.Values {
+			Freeze(value)
+		}
+	}
+	return obj
+}
+
+// IsFrozen reports whether the value can never change: it is a frozen collection or an immutable value.
+// Iterators, closures and the other objects holding state are never frozen.
+func IsFrozen(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.frozen
+	case *Hash:
+		return obj.frozen
+	case *Record:
+		return obj.frozen
+	case *Integer, *Float, *Boolean, *Null, *String, *StringKey, *Symbol, *RecordShape, *CompiledFunction, *Builtin:
+		return true
+	default:
+		return false
+	}
+}
+
+// Mutable returns the collection when it can be changed in place, or a copy of it when it is frozen.
+// The copy is shallow, its elements are shared with the frozen collection until they are copied in turn.
+func Mutable(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		if !obj.frozen {
+			return obj
+		}
+		elements := make([]Object, len(obj.Elements))
+		copy(elements, obj.Elements)
+		return &Array{Elements: elements}
+	case *Hash:
+		if !obj.frozen {
+			return obj
+		}
+		pairs := make(map[HashKey]HashPair, len(obj.Pairs))
+		for key, pair := range obj.Pairs {
+			pairs[key] = pair
+		}
+		return &Hash{Pairs: pairs}
+	case *Record:
+		if !obj.frozen {
+			return obj
+		}
+		values := make([]Object, len(obj.Values))
+		copy(values, obj.Values)
+		return &Record{Shape: obj.Shape, Values: values}
+	default:
+		return obj
+	}
+}
@@ -0,0 +1,136 @@
+package object
+
+// applyBuiltin calls the function with the elements of the array as its arguments
+var applyBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+
+		if !isCallable(args[0]) {
+			return newError("first argument to `apply` must be a function, got %s", args[0].Type())
+		}
+
+		arr, ok := args[1].(*Array)
+		if !ok {
+			return newError("second argument to `apply` must be ARRAY, got %s", args[1].Type())
+		}
+
+		return call(args[0], arr.Elements...)
+	},
+}
+
+// partialBuiltin binds the leading arguments of a function. It returns a built-in function
+// which calls the function with the bound arguments followed by the arguments it is called with.
+var partialBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) < 1 {
+			return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+		}
+
+		fn := args[0]
+		if !isCallable(fn) {
+			return newError("first argument to `partial` must be a function, got %s", fn.Type())
+		}
+
+		bound := make([]Object, len(args)-1)
+		copy(bound, args[1:])
+		return &Builtin{
+			HigherOrderFn: func(call CallFunction, args ...Object) Object {
+				allArgs := make([]Object, 0, len(bound)+len(args))
+				allArgs = append(allArgs, bound...)
+				allArgs = append(allArgs, args...)
+				return call(fn, allArgs...)
+			},
+		}
+	},
+}
+
+// composeBuiltin returns a built-in function that calls the given functions from right to left,
+// compose(f, g)(x) is the same as f(g(x)). The last function receives all arguments of the call,
+// every other function receives the result of the function after it.
+var composeBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) < 1 {
+			return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+		}
+
+		fns := make([]Object, len(args))
+		for i, arg := range args {
+			if !isCallable(arg) {
+				return newError("arguments to `compose` must be functions, got %s", arg.Type())
+			}
+			fns[i] = arg
+		}
+
+		return &Builtin{
+			HigherOrderFn: func(call CallFunction, args ...Object) Object {
+				result := call(fns[len(fns)-1], args...)
+				for i := len(fns) - 2; i >= 0; i-- {
+					if isError(result) {
+						return result
+					}
+					result = call(fns[i], result)
+				}
+				return result
+			},
+		}
+	},
+}
+
+// identityBuiltin returns its argument unchanged
+var identityBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		return args[0]
+	},
+}
+
+// constFnBuiltin returns a built-in function that ignores its arguments and always returns the given value
+var constFnBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		value := args[0]
+		return &Builtin{
+			Fn: func(args ...Object) Object {
+				return value
+			},
+		}
+	},
+}
+
+// tryBuiltin calls a function without arguments and captures its outcome instead of letting
+// a runtime error abort the program. It returns the result {"ok": value} when the call succeeds
+// and {"error": message} when it fails, see Ok and Err.
+var tryBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		if !isCallable(args[0]) {
+			return newError("argument to `try` must be a function, got %s", args[0].Type())
+		}
+
+		result := call(args[0])
+		if errObj, ok := result.(*Error); ok && errObj.Exit {
+			// ending the program is not a failure to recover from
+			return errObj
+		}
+		if errObj, ok := result.(*Error); ok {
+			return Err(errObj.Message)
+		}
+		return Ok(result)
+	},
+}
+
+// isCallable reports whether the object can be called like a function by either engine
+func isCallable(obj Object) bool {
+	_, ok := obj.(Callable)
+	return ok
+}
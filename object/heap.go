@@ -0,0 +1,109 @@
+package object
+
+import "unsafe"
+
+// interfaceSize is the size of an Object held in a slice or a hash pair
+const interfaceSize = int(unsafe.Sizeof(Object(nil)))
+
+// HeapSummary describes the objects reachable from a set of roots. Every object is counted once,
+// no matter how many references to it there are. Sizes are estimates in bytes of the memory held
+// by the objects themselves, excluding the overhead of the Go runtime.
+type HeapSummary struct {
+	Objects int
+	Bytes   int
+	// Counts and Sizes hold the number of objects and their total size by type
+	Counts map[ObjectType]int
+	Sizes  map[ObjectType]int
+}
+
+// SummarizeHeap walks the objects reachable from the roots and summarizes them by type
+func SummarizeHeap(roots []Object) HeapSummary {
+	summary := HeapSummary{Counts: make(map[ObjectType]int), Sizes: make(map[ObjectType]int)}
+	seen := make(map[Object]bool)
+	for _, root := range roots {
+		walkHeap(root, seen, func(obj Object) {
+			size := Size(obj)
+			summary.Objects++
+			summary.Bytes += size
+			summary.Counts[obj.Type()]++
+			summary.Sizes[obj.Type()] += size
+		})
+	}
+	return summary
+}
+
+// RetainedSize returns the estimated size of the object and every object reachable from it
+func RetainedSize(obj Object) int {
+	total := 0
+	walkHeap(obj, make(map[Object]bool), func(obj Object) {
+		total += Size(obj)
+	})
+	return total
+}
+
+// Size returns the estimated size in bytes of the object itself, without the objects it references
+func Size(obj Object) int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return int(unsafe.Sizeof(*obj))
+	case *Boolean:
+		return int(unsafe.Sizeof(*obj))
+	case *Float:
+		return int(unsafe.Sizeof(*obj))
+	case *String:
+		return int(unsafe.Sizeof(*obj)) + len(obj.Value)
+	case *StringKey:
+		return int(unsafe.Sizeof(*obj))
+	case *Array:
+		return int(unsafe.Sizeof(*obj)) + cap(obj.Elements)*interfaceSize
+	case *Hash:
+		return int(unsafe.Sizeof(*obj)) + len(obj.Pairs)*int(unsafe.Sizeof(HashKey{})+unsafe.Sizeof(HashPair{}))
+	case *Record:
+		return int(unsafe.Sizeof(*obj)) + cap(obj.Values)*interfaceSize
+	case *Closure:
+		return int(unsafe.Sizeof(*obj)) + cap(obj.Free)*interfaceSize
+	case *CompiledFunction:
+		return int(unsafe.Sizeof(*obj)) + len(obj.Instructions) + len(obj.Source) + len(obj.Doc)
+	case *Error:
+		return int(unsafe.Sizeof(*obj)) + len(obj.Message)
+	case *ReturnValue:
+		return int(unsafe.Sizeof(*obj))
+	default:
+		// built-in functions, modules and other runtime objects are only referenced, they are not allocated by programs
+		return 0
+	}
+}
+
+// walkHeap calls visit for obj and every object reachable from it that was not seen before
+func walkHeap(obj Object, seen map[Object]bool, visit func(Object)) {
+	if obj == nil || seen[obj] {
+		return
+	}
+	seen[obj] = true
+	visit(obj)
+
+	switch obj := obj.(type) {
+	case *Array:
+		for _, element := range obj.Elements {
+			walkHeap(element, seen, visit)
+		}
+	case *Hash:
+		for _, pair := range obj.Pairs {
+			walkHeap(pair.Key, seen, visit)
+			walkHeap(pair.Value, seen, visit)
+		}
+	case *Record:
+		for _, value := range obj.Values {
+			walkHeap(value, seen, visit)
+		}
+	case *StringKey:
+		walkHeap(obj.String, seen, visit)
+	case *Closure:
+		walkHeap(obj.Fn, seen, visit)
+		for _, free := range obj.Free {
+			walkHeap(free, seen, visit)
+		}
+	case *ReturnValue:
+		walkHeap(obj.Value, seen, visit)
+	}
+}
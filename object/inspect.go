@@ -0,0 +1,124 @@
+package object
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// InspectOptions limits how much of nested arrays and hashes Inspect prints.
+// A zero limit means unlimited. Collections deeper than MaxDepth are printed as `[...]` or `{...}`
+// and collections with more than MaxWidth elements print their first MaxWidth elements followed by `...`.
+// With QuoteStrings, strings are printed as quoted string literals with escapes instead of their raw contents.
+type InspectOptions struct {
+	MaxDepth     int
+	MaxWidth     int
+	QuoteStrings bool
+}
+
+// DefaultInspectOptions are the limits used by the Inspect methods of arrays and hashes.
+// The REPL printer passes its own limits to InspectWith.
+var DefaultInspectOptions = InspectOptions{}
+
+// InspectWith returns the printed form of the object within the given limits.
+// An array or hash that contains itself is printed as `[...]` or `{...}` where it repeats,
+// instead of recursing forever.
+func InspectWith(obj Object, options InspectOptions) string {
+	var out bytes.Buffer
+	p := &printer{out: &out, options: options, visiting: make(map[Object]bool)}
+	p.print(obj, 0)
+	return out.String()
+}
+
+// printer writes the printed form of nested collections, keeping track of the collections being printed
+type printer struct {
+	out      *bytes.Buffer
+	options  InspectOptions
+	visiting map[Object]bool
+}
+
+// print writes the object at the given nesting depth
+func (p *printer) print(obj Object, depth int) {
+	switch obj := obj.(type) {
+	case *Array:
+		if p.visiting[obj] || p.tooDeep(depth) {
+			p.out.WriteString("[...]")
+			return
+		}
+		p.visiting[obj] = true
+		defer delete(p.visiting, obj)
+
+		p.out.WriteString("[")
+		for i, el := range obj.Elements {
+			if p.separate(i) {
+				break
+			}
+			p.print(el, depth+1)
+		}
+		p.out.WriteString("]")
+	case *Hash:
+		if p.visiting[obj] || p.tooDeep(depth) {
+			p.out.WriteString("{...}")
+			return
+		}
+		p.visiting[obj] = true
+		defer delete(p.visiting, obj)
+
+		p.out.WriteString("{")
+		i := 0
+		for _, pair := range obj.Pairs {
+			if p.separate(i) {
+				break
+			}
+			p.print(pair.Key, depth+1)
+			p.out.WriteString(": ")
+			p.print(pair.Value, depth+1)
+			i++
+		}
+		p.out.WriteString("}")
+	case *Record:
+		if p.visiting[obj] || p.tooDeep(depth) {
+			p.out.WriteString("record{...}")
+			return
+		}
+		p.visiting[obj] = true
+		defer delete(p.visiting, obj)
+
+		p.out.WriteString("record{")
+		for i, field := range obj.Shape.Fields {
+			if p.separate(i) {
+				break
+			}
+			p.out.WriteString(field + ": ")
+			p.print(obj.Values[i], depth+1)
+		}
+		p.out.WriteString("}")
+	case *String:
+		if p.options.QuoteStrings {
+			p.out.WriteString(strconv.Quote(obj.Value))
+			return
+		}
+		p.out.WriteString(obj.Inspect())
+	case *StringKey:
+		p.print(obj.String, depth)
+	default:
+		p.out.WriteString(obj.Inspect())
+	}
+}
+
+// tooDeep reports whether a collection at the given depth exceeds the maximum depth
+func (p *printer) tooDeep(depth int) bool {
+	return p.options.MaxDepth > 0 && depth >= p.options.MaxDepth
+}
+
+// separate writes the separator before the element at index i of a collection.
+// It writes `...` instead and returns true when the element exceeds the maximum width.
+func (p *printer) separate(i int) bool {
+	if i > 0 {
+		p.out.WriteString(", ")
+	}
+	if p.options.MaxWidth > 0 && i >= p.options.MaxWidth {
+		p.out.WriteString("...")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package object
+
+import "sort"
+
+// for-in loops walk a collection through a Cursor, so the evaluator and the VM iterate the same way.
+// Every step produces a key and a value: the index and the element of an array or an iterator,
+// the key and the value of a hash. Hashes are walked in the order of their keys, so a loop over
+// a hash does the same thing every time the program runs.
+
+const CURSOR_OBJ = "CURSOR"
+
+// Cursor is the position of a for-in loop in the collection it walks.
+// It only lives on the stack of the VM while the loop runs and is never bound to a name.
+type Cursor struct {
+	next func(call CallFunction) (key, value Object, ok bool)
+}
+
+// Type returns the ObjectType (CURSOR_OBJ) associated with the referenced Cursor struct
+func (c *Cursor) Type() ObjectType { return CURSOR_OBJ }
+
+// Inspect returns a static string for the Cursor struct
+func (c *Cursor) Inspect() string { return "cursor" }
+
+// Next returns the key and the value of the next element, the boolean is false once the collection is exhausted.
+// The elements of an iterator are produced with call, an Error yielded by a failed callback is returned as the value.
+func (c *Cursor) Next(call CallFunction) (Object, Object, bool) {
+	return c.next(call)
+}
+
+// Iterate returns a cursor over the elements of an array, a hash or an iterator,
+// or an Error when the object is not a collection
+func Iterate(obj Object) (*Cursor, *Error) {
+	switch obj := obj.(type) {
+	case *Array:
+		i := 0
+		return &Cursor{next: func(call CallFunction) (Object, Object, bool) {
+			// the array can grow while it is walked, its length is checked on every step
+			if i >= len(obj.Elements) {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Value: int64(i - 1)}, obj.Elements[i-1], true
+		}}, nil
+	case *Hash:
+		pairs := sortedPairs(obj)
+		i := 0
+		return &Cursor{next: func(call CallFunction) (Object, Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			return pairs[i-1].Key, pairs[i-1].Value, true
+		}}, nil
+	case *Iterator:
+		i := 0
+		return &Cursor{next: func(call CallFunction) (Object, Object, bool) {
+			el, ok := obj.Next(call)
+			if !ok {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Value: int64(i - 1)}, el, true
+		}}, nil
+	default:
+		return nil, newError("cannot iterate over %s, want ARRAY, HASH or ITERATOR", obj.Type())
+	}
+}
+
+// sortedPairs returns the pairs of the hash ordered by their keys. Keys of different types are grouped by type,
+// numbers, strings and symbols are in ascending order and false comes before true.
+func sortedPairs(h *Hash) []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i].Key, pairs[j].Key
+		if a.Type() != b.Type() {
+			return a.Type() < b.Type()
+		}
+		switch a := a.(type) {
+		case *Integer:
+			return a.Value < b.(*Integer).Value
+		case *Float:
+			return a.Value < b.(*Float).Value
+		case *String:
+			return a.Value < b.(*String).Value
+		case *Symbol:
+			return a.Name < b.(*Symbol).Name
+		case *Boolean:
+			return !a.Value && b.(*Boolean).Value
+		default:
+			return a.Inspect() < b.Inspect()
+		}
+	})
+	return pairs
+}
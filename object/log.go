@@ -0,0 +1,184 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// LogLevel is the severity of a line written by the logging built-in functions
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+// logLevelNames maps every LogLevel to the name used in log lines and by the `log_level` built-in function
+var logLevelNames = map[LogLevel]string{
+	LogDebug: "debug",
+	LogInfo:  "info",
+	LogWarn:  "warn",
+	LogError: "error",
+}
+
+// String returns the name of the LogLevel
+func (l LogLevel) String() string { return logLevelNames[l] }
+
+// Logger holds the configuration used by the logging built-in functions.
+// Writer is where log lines are written to, embedders can replace it to integrate
+// script logs with their host's logging. Lines below Level are discarded.
+// When JSON is set, lines are written as JSON objects instead of key=value pairs.
+// Every VM and evaluation has a Logger of its own, so `log_level` only changes the level of the program
+// calling it. The fields are set before the Logger is given to an engine, with vm.SetLogger or the
+// Logger of the evaluator's Options, the workers of pmap share the Logger of their program.
+type Logger struct {
+	Writer io.Writer
+	Level  LogLevel
+	JSON   bool
+}
+
+// Log is the default Logger, the VMs and evaluations without a Logger of their own start from a copy of it
+var Log = &Logger{Writer: os.Stderr, Level: LogInfo}
+
+// logMu guards the loggers changed by `log_level` while other programs write with them, and keeps the lines
+// of different loggers sharing a Writer, like the copies of Log writing to os.Stderr, from interleaving
+var logMu sync.Mutex
+
+// Copy returns a new Logger with the configuration of the Logger, changing its level leaves the Logger unchanged
+func (l *Logger) Copy() *Logger {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return &Logger{Writer: l.Writer, Level: l.Level, JSON: l.JSON}
+}
+
+// Write formats a log line with the given level, message and fields (sorted by key)
+// and writes it to the Logger's Writer, unless the level is below the Logger's Level.
+func (l *Logger) Write(level LogLevel, msg string, fields map[string]Object) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if level < l.Level {
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out bytes.Buffer
+	if l.JSON {
+		out.WriteString(`{"level":` + strconv.Quote(level.String()))
+		out.WriteString(`,"msg":` + strconv.Quote(msg))
+		for _, k := range keys {
+			out.WriteString("," + strconv.Quote(k) + ":" + jsonLogValue(fields[k]))
+		}
+		out.WriteString("}")
+	} else {
+		out.WriteString("level=" + level.String())
+		out.WriteString(" msg=" + strconv.Quote(msg))
+		for _, k := range keys {
+			out.WriteString(" " + k + "=" + textLogValue(fields[k]))
+		}
+	}
+	out.WriteString("\n")
+
+	l.Writer.Write(out.Bytes())
+}
+
+// textLogValue formats a field value for a key=value log line, quoting strings
+func textLogValue(obj Object) string {
+	if str, ok := obj.(*String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return obj.Inspect()
+}
+
+// jsonLogValue formats a field value for a JSON log line. Integers, booleans and null
+// keep their JSON type, every other object is written as the string of its Inspect value.
+func jsonLogValue(obj Object) string {
+	switch obj := obj.(type) {
+	case *Integer, *Boolean, *Null:
+		return obj.Inspect()
+	case *String:
+		return strconv.Quote(obj.Value)
+	default:
+		encoded, _ := json.Marshal(obj.Inspect())
+		return string(encoded)
+	}
+}
+
+// logBuiltin creates the built-in function that logs at the given level. It expects a message
+// and optionally a hash of fields, whose keys and values are added to the log line.
+func logBuiltin(level LogLevel) *Builtin {
+	name := "log_" + level.String()
+	return &Builtin{
+		LogFn: func(log *Logger, args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			msg := args[0].Inspect()
+
+			fields := make(map[string]Object)
+			if len(args) == 2 {
+				hash, ok := args[1].(*Hash)
+				if !ok {
+					return newError("second argument to `%s` must be HASH, got %s", name, args[1].Type())
+				}
+				for _, pair := range hash.Pairs {
+					fields[pair.Key.Inspect()] = pair.Value
+				}
+			}
+
+			if log == nil {
+				log = Log
+			}
+			log.Write(level, msg, fields)
+			return nil
+		},
+	}
+}
+
+// logLevelBuiltin returns the name of the current log level of the program, when
+// given the name of a level it first changes the log level to it.
+var logLevelBuiltin = &Builtin{
+	LogFn: func(log *Logger, args ...Object) Object {
+		if len(args) > 1 {
+			return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+		}
+		if log == nil {
+			log = Log
+		}
+
+		logMu.Lock()
+		defer logMu.Unlock()
+		if len(args) == 1 {
+			name, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `log_level` must be STRING, got %s", args[0].Type())
+			}
+
+			found := false
+			for level, levelName := range logLevelNames {
+				if levelName == name.Value {
+					log.Level = level
+					found = true
+				}
+			}
+			if !found {
+				return newError("unknown log level: %s", name.Value)
+			}
+		}
+
+		return &String{Value: fmt.Sprint(log.Level)}
+	},
+}
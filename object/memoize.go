@@ -0,0 +1,57 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// memoizeBuiltin wraps a function in a built-in function that caches its results.
+// The cache is keyed by the arguments of a call, so all arguments must be hashable.
+// Failed calls are not cached. Recursive functions benefit when they call the wrapper
+// instead of themselves, e.g. `let fib = memoize(fn(n) { ... fib(n - 1) ... })`.
+var memoizeBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		fn := args[0]
+		if !isCallable(fn) {
+			return newError("argument to `memoize` must be a function, got %s", fn.Type())
+		}
+
+		cache := make(map[string]Object)
+		return &Builtin{
+			HigherOrderFn: func(call CallFunction, args ...Object) Object {
+				key, errObj := memoizeKey(args)
+				if errObj != nil {
+					return errObj
+				}
+
+				if result, ok := cache[key]; ok {
+					return result
+				}
+
+				result := call(fn, args...)
+				if !isError(result) {
+					cache[key] = result
+				}
+				return result
+			},
+		}
+	},
+}
+
+// memoizeKey combines the hash-keys of the arguments into a single cache key
+func memoizeKey(args []Object) (string, Object) {
+	var key strings.Builder
+	for _, arg := range args {
+		hashable, ok := arg.(Hashable)
+		if !ok {
+			return "", newError("unusable as memoize key: %s", arg.Type())
+		}
+		hashKey := hashable.HashKey()
+		fmt.Fprintf(&key, "%s:%d;", hashKey.Type, hashKey.Value)
+	}
+	return key.String(), nil
+}
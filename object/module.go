@@ -0,0 +1,85 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxBuiltins is the maximum number of built-in functions, including the members of modules.
+// The VM addresses built-in functions with a one-byte operand.
+const MaxBuiltins = 256
+
+// Module is the referenced struct for namespaced built-in modules (strings, math) in our object system.
+// Members maps the name of each member to the index of its built-in function in Builtins,
+// which lets the compiler resolve `strings.upper` to a built-in function at compile time.
+type Module struct {
+	Name    string
+	Members map[string]int
+}
+
+// Type returns the ObjectType (MODULE_OBJ) associated with the referenced Module struct
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+
+// Inspect returns the name of the module
+func (m *Module) Inspect() string { return fmt.Sprintf("module %s", m.Name) }
+
+// Member returns the built-in function bound to the given name in the module
+func (m *Module) Member(name string) (*Builtin, bool) {
+	index, ok := m.Members[name]
+	if !ok {
+		return nil, false
+	}
+	return Builtins[index].Builtin, true
+}
+
+// Modules contains the registered built-in modules, the index of a module
+// is used by the VM to load it with an OpGetModule instruction.
+var Modules = []*Module{}
+
+// RegisterModule registers a module of built-in functions under the given name.
+// Each member is added to Builtins under its qualified name (strings.upper), so modules
+// must be registered before compiling programs that use them. The members of the modules
+// of the standard library are listed in Builtins already, they keep their indices.
+func RegisterModule(name string, members map[string]*Builtin) error {
+	if GetModuleByName(name) != nil {
+		return fmt.Errorf("module %s is already registered", name)
+	}
+
+	if len(Builtins)+len(members) > MaxBuiltins {
+		return fmt.Errorf("too many built-in functions: module %s exceeds the limit of %d", name, MaxBuiltins)
+	}
+
+	// register members in a stable order so built-in indices do not depend on map iteration
+	names := make([]string, 0, len(members))
+	for member := range members {
+		names = append(names, member)
+	}
+	sort.Strings(names)
+
+	module := &Module{Name: name, Members: make(map[string]int, len(members))}
+	for _, member := range names {
+		qualified := name + "." + member
+		if index, ok := LookupBuiltin(qualified); ok && Builtins[index].Builtin == members[member] {
+			module.Members[member] = index
+			continue
+		}
+		index, err := RegisterBuiltin(qualified, members[member])
+		if err != nil {
+			return err
+		}
+		module.Members[member] = index
+	}
+
+	Modules = append(Modules, module)
+	return nil
+}
+
+// GetModuleByName returns the registered module with the given name, or nil when there is none
+func GetModuleByName(name string) *Module {
+	for _, module := range Modules {
+		if module.Name == name {
+			return module
+		}
+	}
+	return nil
+}
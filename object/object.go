@@ -23,6 +23,18 @@ const (
 	HASH_OBJ              = "HASH"
 	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION_OBJ"
 	CLOSURE_OBJ           = "CLOSURE"
+	ITERATOR_OBJ          = "ITERATOR"
+	MODULE_OBJ            = "MODULE"
+	HASH_KEY_OBJ          = "HASH_KEY"
+	LOOP_CONTROL_OBJ      = "LOOP_CONTROL"
+)
+
+var (
+	// TRUE, FALSE and NULL are the only instances of their objects, both the evaluator and the VM
+	// compare booleans and null by their pointer, so built-in functions must return these instances.
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
 )
 
 // ObjectType is the type that represents an evaluated value as a string
@@ -107,10 +119,39 @@ func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 // underlying struct which implemeneted the Object interface.
 func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
+// LoopControl is the value of a break or continue statement in the evaluator. Like a ReturnValue it stops
+// the evaluation of the blocks it is in, until the innermost loop stops or starts its next iteration.
+type LoopControl struct {
+	// Break is true for a break statement and false for a continue statement
+	Break bool
+}
+
+// Type returns the ObjectType (LOOP_CONTROL_OBJ) associated with the referenced LoopControl struct
+func (lc *LoopControl) Type() ObjectType { return LOOP_CONTROL_OBJ }
+
+// Inspect returns the statement the LoopControl is the value of
+func (lc *LoopControl) Inspect() string {
+	if lc.Break {
+		return "break"
+	}
+	return "continue"
+}
+
 // Error contains the Message corresponding to an error that
-// was encountered while evaluating the AST
+// was encountered while evaluating the AST.
+// Exit is set when the error is the request of the exit built-in function to end the program
+// with the exit status Code. It stops the program like any error, but `try` does not capture it.
+// Line and Column locate the expression that raised the error and Trace holds the names of the functions being
+// called at the time, from the outermost call. Suggestion is the name meant by a misspelled one. The evaluator
+// sets them to report the error, Line is 0 for an error that was not located.
 type Error struct {
-	Message string
+	Message    string
+	Exit       bool
+	Code       int
+	Line       int
+	Column     int
+	Trace      []string
+	Suggestion string
 }
 
 // Type returns the ObjectType (ERROR_OBJ) associated with the referenced Error struct
@@ -122,11 +163,18 @@ func (e *Error) Inspect() string { return "ERROR: " + e.Message }
 
 // Function is the referenced struct for Function Literals in our object system.
 // The struct holds the function's parameters and body to be later evaluated
-// when referenced in its respective environment in a function call
+// when referenced in its respective environment in a function call.
+// Defaults holds the default values of the last len(Defaults) parameters before the variadic one, they are evaluated
+// in the function's environment when a call leaves out their arguments. The last parameter of a Variadic function
+// is bound to an array of the arguments following the other parameters.
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Variadic   bool
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string
+	Doc        string
 }
 
 // Type returns the ObjectType (FUNCTION_OBJ) associated with the referenced Function struct
@@ -139,8 +187,19 @@ func (f *Function) Inspect() string {
 
 	params := []string{}
 	// build params, convert ast.Identifiers to strings
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	fixed := len(f.Parameters)
+	if f.Variadic {
+		fixed--
+	}
+	for i, p := range f.Parameters {
+		param := p.String()
+		switch {
+		case i == fixed:
+			param = ast.VariadicString(p)
+		case i >= fixed-len(f.Defaults):
+			param += " = " + f.Defaults[i-fixed+len(f.Defaults)].String()
+		}
+		params = append(params, param)
 	}
 
 	// construct function literal as string
@@ -154,6 +213,65 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// ArgumentsMessage describes the number of arguments a function accepts and the number it was called with,
+// like "want=2, got=1". A function with default values accepts from required to total arguments,
+// which is described as "want=1 to 2, got=3". A variadic function accepts any number of arguments
+// from required on, it is described as "want=1 or more, got=0" when total is negative.
+func ArgumentsMessage(required, total, got int) string {
+	if total < 0 {
+		return fmt.Sprintf("want=%d or more, got=%d", required, got)
+	}
+	if required == total {
+		return fmt.Sprintf("want=%d, got=%d", total, got)
+	}
+	return fmt.Sprintf("want=%d to %d, got=%d", required, total, got)
+}
+
+// ArrangeArguments places the arguments of a call with named arguments in the order of the parameters, as both
+// engines do before calling a function. The positional arguments come first in args and the named arguments follow
+// them, names holds their names. A variadic function collects the positional arguments after its fixed parameters in
+// the last of params, which cannot be named. The parameters from required on have default values: one the call left
+// out is nil in the result, so the function takes its default value. It returns an error for a name that is not
+// a parameter, a parameter given twice and a required parameter left out.
+func ArrangeArguments(params []string, required int, variadic bool, args []Object, names []string) ([]Object, error) {
+	fixed := len(params)
+	if variadic {
+		fixed--
+	}
+	positional := len(args) - len(names)
+	arranged := make([]Object, fixed)
+	if positional > fixed {
+		arranged = make([]Object, positional)
+	}
+	copy(arranged, args[:positional])
+
+	for i, name := range names {
+		index := -1
+		for j, param := range params[:fixed] {
+			if param == name {
+				index = j
+				break
+			}
+		}
+		switch {
+		case index < 0 && variadic && name == params[fixed]:
+			return nil, fmt.Errorf("variadic parameter %s cannot be passed by name", name)
+		case index < 0:
+			return nil, fmt.Errorf("unknown argument name %s, the parameters are (%s)", name, strings.Join(params, ", "))
+		case arranged[index] != nil:
+			return nil, fmt.Errorf("argument %s is given twice", name)
+		}
+		arranged[index] = args[positional+i]
+	}
+
+	for i := 0; i < required; i++ {
+		if arranged[i] == nil {
+			return nil, fmt.Errorf("missing argument for parameter %s", params[i])
+		}
+	}
+	return arranged, nil
+}
+
 // String is the referenced struct for String Literals in our object system.
 // The struct holds the evaluated value of the String Literal.
 type String struct {
@@ -166,6 +284,9 @@ func (s *String) Type() ObjectType { return STRING_OBJ }
 // Inspect returns the String struct's Value which is of type string
 func (s *String) Inspect() string { return s.Value }
 
+// Len returns the number of bytes of the String, which implements Sized
+func (s *String) Len() int { return len(s.Value) }
+
 // HashKey constructs a string hash-key for a Hash. The hash-key uses the String's Value
 // to construct a new 64-bit hash and converts it to a primitive uint64 for the hash-key.
 // This helps resolve the issue where &Object.Strings have the same Value, but have different
@@ -182,15 +303,111 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// StringKey is a constant string hash key whose HashKey the compiler computed ahead of time.
+// The compiler stores it in the constant pool for the string keys of hash literals, index expressions
+// with string literals and member accesses, so the VM does not hash the same key again on every access.
+// It never reaches Monkey code, the VM unwraps it into its String wherever the key becomes a value.
+type StringKey struct {
+	String *String
+	Key    HashKey
+}
+
+// NewStringKey creates a StringKey for the given value, computing its HashKey
+func NewStringKey(value string) *StringKey {
+	str := &String{Value: value}
+	return &StringKey{String: str, Key: str.HashKey()}
+}
+
+// Type returns the ObjectType (HASH_KEY_OBJ) associated with the referenced StringKey struct
+func (sk *StringKey) Type() ObjectType { return HASH_KEY_OBJ }
+
+// Inspect returns the value of the key
+func (sk *StringKey) Inspect() string { return sk.String.Value }
+
+// HashKey returns the precomputed HashKey of the string
+func (sk *StringKey) HashKey() HashKey { return sk.Key }
+
 // BuiltinFunction is used to create built-in functions that can be called in the interpretor.
 // The functions are defined by us and can be called by the user. A built-in function can be
 // constructed with any number of arguments of the type Object, but it must return an Object.
 type BuiltinFunction func(args ...Object) Object
 
+// CallFunction is provided by the engine (evaluator or VM) executing a higher-order built-in function.
+// It calls fn, which can be any callable object (function, closure or built-in), with the given arguments
+// and returns the result. When the call fails, the failure is returned as an *Error.
+type CallFunction func(fn Object, args ...Object) Object
+
+// HigherOrderBuiltinFunction is used to create built-in functions that call back into
+// Monkey functions, it receives the engine's CallFunction to do so.
+type HigherOrderBuiltinFunction func(call CallFunction, args ...Object) Object
+
+// ForkFunction is provided by an engine able to run Monkey functions on several goroutines at the same time.
+// It returns a CallFunction calling functions on a new worker, calls on different workers can run concurrently
+// but the calls on a single worker must not. It fails when fn cannot run on a worker.
+type ForkFunction func(fn Object) (CallFunction, *Error)
+
+// ParallelBuiltinFunction is used to create built-in functions that call Monkey functions concurrently.
+// fork is nil when the engine cannot run functions concurrently, the calls are then made one after another with call.
+type ParallelBuiltinFunction func(call CallFunction, fork ForkFunction, args ...Object) Object
+
+// SignalBuiltinFunction is used to create built-in functions registering the handlers of signals, it receives
+// the Signals of the running program. signals is nil when the engine does not handle signals.
+type SignalBuiltinFunction func(signals *Signals, args ...Object) Object
+
+// LogBuiltinFunction is used to create the logging built-in functions, it receives the Logger of the running
+// program. log is nil when the engine has none, the built-in functions then use the default Logger, Log.
+type LogBuiltinFunction func(log *Logger, args ...Object) Object
+
 // Builtin is the referenced struct for built-in functions in our object system.
-// The struct holds the defined built-in function.
+// The struct holds the defined built-in function. Built-in functions that need
+// to call other functions set HigherOrderFn instead of Fn, or ParallelFn to call them concurrently.
+// SignalFn is set by the built-in functions registering handlers with the signals of the program,
+// and LogFn by the ones writing to or configuring its Logger.
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn            BuiltinFunction
+	HigherOrderFn HigherOrderBuiltinFunction
+	ParallelFn    ParallelBuiltinFunction
+	SignalFn      SignalBuiltinFunction
+	LogFn         LogBuiltinFunction
+	// Pure marks a built-in function whose result depends only on its arguments and that has no effects,
+	// the compiler calls it at compile time when all the arguments are constants.
+	Pure bool
+}
+
+// Call executes the built-in function with the given arguments. The engine's call
+// function is only passed along to higher-order built-in functions.
+func (b *Builtin) Call(call CallFunction, args ...Object) Object {
+	return b.CallParallel(call, nil, args...)
+}
+
+// CallParallel executes the built-in function like Call, engines able to run functions concurrently
+// pass their ForkFunction along to the parallel built-in functions.
+func (b *Builtin) CallParallel(call CallFunction, fork ForkFunction, args ...Object) Object {
+	switch {
+	case b.ParallelFn != nil:
+		return b.ParallelFn(call, fork, args...)
+	case b.HigherOrderFn != nil:
+		return b.HigherOrderFn(call, args...)
+	case b.SignalFn != nil:
+		return b.SignalFn(nil, args...)
+	case b.LogFn != nil:
+		return b.LogFn(nil, args...)
+	default:
+		return b.Fn(args...)
+	}
+}
+
+// CallProgram executes the built-in function like Call, engines pass the Signals and the Logger of the running
+// program along to the built-in functions registering handlers of signals and to the logging ones.
+func (b *Builtin) CallProgram(call CallFunction, signals *Signals, log *Logger, args ...Object) Object {
+	switch {
+	case b.SignalFn != nil:
+		return b.SignalFn(signals, args...)
+	case b.LogFn != nil:
+		return b.LogFn(log, args...)
+	default:
+		return b.Call(call, args...)
+	}
 }
 
 // Type returns the ObjectType (BUILTIN_OBJ) associated with the referenced Builtin struct
@@ -203,6 +420,8 @@ func (b *Builtin) Inspect() string { return "builtin function" }
 // The struct holds the evaluated elements of the array literal
 type Array struct {
 	Elements []Object
+	// frozen marks an array shared as a constant, see Freeze
+	frozen bool
 }
 
 // Type returns the ObjectType (ARRAY_OBJ) associated with the referenced Array struct
@@ -210,22 +429,14 @@ func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
 
 // Inspect will construct the Array as a string by stringifying its elements,
 // and concatenating them into the expected array format.
+// An array containing itself is printed as [...] where it repeats (see InspectWith).
 func (ao *Array) Inspect() string {
-	var out bytes.Buffer
-
-	elements := []string{}
-
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
-	}
-
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-
-	return out.String()
+	return InspectWith(ao, DefaultInspectOptions)
 }
 
+// Len returns the number of elements of the Array, which implements Sized
+func (ao *Array) Len() int { return len(ao.Elements) }
+
 // HashPair is the referenced struct used as the designated value to HashKeys.
 // It helps us print the values of the map in a more practial manner by
 // containing both the objects that generated the keys and values of the map.
@@ -247,6 +458,8 @@ type HashKey struct {
 // The Pairs field holds the evaluated map of the hash literal.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	// frozen marks a hash shared as a constant, see Freeze
+	frozen bool
 }
 
 // Type returns the ObjectType (HASH_OBJ) associated with the referenced Hash struct
@@ -254,36 +467,96 @@ func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
 // Inspect will construct the Hash as a string by stringifying its key-value pairs,
 // and concatenating them into the expected hash format.
+// A hash containing itself is printed as {...} where it repeats (see InspectWith).
 func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
-	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-
-	return out.String()
+	return InspectWith(h, DefaultInspectOptions)
 }
 
+// Len returns the number of pairs of the Hash, which implements Sized
+func (h *Hash) Len() int { return len(h.Pairs) }
+
 // Hashable is the interface used in our evaluator to check if the given object is
 // usable as a hash key when we evaluate hash literals or index expressions for hashes.
 type Hashable interface {
 	HashKey() HashKey
 }
 
+// Sized is the interface implemented by the objects that have a length, the len built-in function
+// accepts any Sized object. New collection types implement it to work with len.
+type Sized interface {
+	Len() int
+}
+
+// Callable is the interface implemented by the objects that can be called with arguments:
+// evaluator functions, VM closures and built-in functions. Higher-order built-in functions
+// accept any Callable and call it through the CallFunction of the engine running them.
+type Callable interface {
+	Object
+	callable()
+}
+
+func (f *Function) callable() {}
+func (c *Closure) callable()  {}
+func (b *Builtin) callable()  {}
+
+// IteratorNext produces the next element of an iterator. The boolean is false once the iterator is exhausted.
+// Callbacks of lazy combinators are called with call, so the engine driving the iterator runs them.
+// A failed callback yields its Error as the next element.
+type IteratorNext func(call CallFunction) (Object, bool)
+
+// Iterator is the referenced struct for lazy sequences in our object system.
+// Elements are produced one at a time by Next, an iterator can only be traversed once.
+// Length returns the number of elements left when the iterator knows it without producing them,
+// like the iterators of range. It is nil for the lazy combinators, whose length is unknown.
+type Iterator struct {
+	Next   IteratorNext
+	Length func() int
+}
+
+// Len returns the number of elements left in the Iterator, which implements Sized.
+// It is 0 when the length is unknown, the len built-in function refuses those iterators.
+func (it *Iterator) Len() int {
+	if it.Length == nil {
+		return 0
+	}
+	return it.Length()
+}
+
+// Type returns the ObjectType (ITERATOR_OBJ) associated with the referenced Iterator struct
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+
+// Inspect returns a static string for the Iterator struct, printing it must not consume its elements
+func (it *Iterator) Inspect() string { return "iterator" }
+
 // CompiledFunction is the referenced struct for compiled functions in our object system.
 // The Instructions field holds the bytecode instructions from compiling a function literal.
 // NumLocals is the number of local bindings in the function.
+// MaxStack is the maximum number of elements the function's instructions hold on the stack at once,
+// it lets the VM verify that a call fits on the stack before executing it.
+// Name is the name the function literal is bound to, it is empty for anonymous functions.
+// NumDefaults is the number of trailing parameters with a default value, a call can leave out their arguments.
+// The last parameter of a Variadic function is bound to an array of the arguments following the other parameters,
+// it is counted in NumParameters but has no default value.
+// Parameters holds the names of the parameters. Source is the source code of the function literal,
+// it is only retained when the compiler was asked to keep debug information, just like Locals,
+// the names of the local bindings indexed by their slot.
+// Doc is the text of the ### doc comment preceding the definition of the function.
+// SourceMap maps the instructions back to the source of the function, it is not kept when the function is encoded.
 // CompiledFunction is intended to be a bytecode constant, it will be loaded on to
 // to the stack and eventually used by the VM when it executes the function as a call expression instruction (OpCall).
 type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	NumDefaults   int
+	Variadic      bool
+	MaxStack      int
+	Name          string
+	Parameters    []string
+	Source        string
+	Locals        []string
+	Doc           string
+	SourceMap     code.SourceMap
 }
 
 // Type returns the ObjectType (COMPILED_FUNCTION_OBJ) associated with the referenced CompiledFunction struct
@@ -1,7 +1,14 @@
 package object
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
 )
 
 func TestStringHashKey(t *testing.T) {
@@ -60,3 +67,431 @@ func TestBooleanHashKey(t *testing.T) {
 		t.Errorf("boolean with different content but have same hash keys")
 	}
 }
+
+func TestSymbolHashKey(t *testing.T) {
+	if Intern("red") != Intern("red") {
+		t.Errorf("symbols with the same name are different instances")
+	}
+	if Intern("red").HashKey() == Intern("green").HashKey() {
+		t.Errorf("symbols with different names have the same hash keys")
+	}
+	if Intern("red").HashKey() == (&String{Value: "red"}).HashKey() {
+		t.Errorf("a symbol and a string with the same name have the same hash keys")
+	}
+}
+
+func TestIterateHash(t *testing.T) {
+	keys := []Object{&Integer{Value: 10}, &String{Value: "b"}, &Integer{Value: 2}, TRUE, &String{Value: "a"}, FALSE}
+	pairs := make(map[HashKey]HashPair)
+	for _, key := range keys {
+		pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: NULL}
+	}
+
+	cursor, errObj := Iterate(&Hash{Pairs: pairs})
+	if errObj != nil {
+		t.Fatalf("unexpected error: %s", errObj.Message)
+	}
+	walked := []string{}
+	for {
+		key, _, ok := cursor.Next(nil)
+		if !ok {
+			break
+		}
+		walked = append(walked, key.Inspect())
+	}
+
+	expected := "false true 2 10 a b"
+	if strings.Join(walked, " ") != expected {
+		t.Errorf("wrong order. want=%q, got=%q", expected, strings.Join(walked, " "))
+	}
+}
+
+func TestLogBuiltins(t *testing.T) {
+	var out bytes.Buffer
+	log := &Logger{Writer: &out, Level: LogInfo}
+
+	fields := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, pair := range []HashPair{
+		{Key: &String{Value: "user"}, Value: &String{Value: "monkey"}},
+		{Key: &String{Value: "attempts"}, Value: &Integer{Value: 3}},
+	} {
+		fields.Pairs[pair.Key.(Hashable).HashKey()] = pair
+	}
+
+	GetBuiltInByName("log_debug").LogFn(log, &String{Value: "hidden"})
+	GetBuiltInByName("log_info").LogFn(log, &String{Value: "logged in"}, fields)
+	GetBuiltInByName("log_level").LogFn(log, &String{Value: "debug"})
+	GetBuiltInByName("log_debug").LogFn(log, &String{Value: "shown"})
+	log.JSON = true
+	GetBuiltInByName("log_error").LogFn(log, &String{Value: "failed"}, fields)
+
+	expected := `level=info msg="logged in" attempts=3 user="monkey"
+level=debug msg="shown"
+{"level":"error","msg":"failed","attempts":3,"user":"monkey"}
+`
+	if out.String() != expected {
+		t.Errorf("wrong log output.\nwant=%q\ngot=%q", expected, out.String())
+	}
+
+	result := GetBuiltInByName("log_level").LogFn(log, &String{Value: "verbose"})
+	errObj, ok := result.(*Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", result, result)
+	}
+	if errObj.Message != "unknown log level: verbose" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	// the level is the one of the logger given to the built-in function, the default Logger is unchanged
+	if Log.Level != LogInfo {
+		t.Errorf("the default logger changed level: %s", Log.Level)
+	}
+	copied := log.Copy()
+	GetBuiltInByName("log_level").LogFn(copied, &String{Value: "warn"})
+	if log.Level != LogDebug || copied.Level != LogWarn {
+		t.Errorf("wrong levels after changing a copy. got=%s and %s", log.Level, copied.Level)
+	}
+}
+
+func TestPrintBuiltins(t *testing.T) {
+	var out bytes.Buffer
+	defer func(output *Printer) { Output = output }(Output)
+	Output = &Printer{Writer: &out, Separator: DefaultSeparator}
+
+	words := &Array{Elements: []Object{&String{Value: "a"}, &String{Value: "b"}}}
+	GetBuiltInByName("print").Fn(&String{Value: "progress:"}, &Integer{Value: 1})
+	GetBuiltInByName("print").Fn(&String{Value: "%"})
+	GetBuiltInByName("puts").Fn(&String{Value: " done"}, words)
+	Output.Inspect.QuoteStrings = true
+	GetBuiltInByName("puts").Fn(&String{Value: "done"}, words)
+	GetBuiltInByName("puts").Fn()
+	Output.Separator = ", "
+	GetBuiltInByName("puts").Fn(&Integer{Value: 1}, &Integer{Value: 2})
+
+	expected := "progress: 1% done [a, b]\n\"done\" [\"a\", \"b\"]\n\n1, 2\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", expected, out.String())
+	}
+
+	// a buffered writer is flushed after every call
+	out.Reset()
+	Output = &Printer{Writer: bufio.NewWriter(&out)}
+	GetBuiltInByName("print").Fn(&String{Value: "progress"})
+	if out.String() != "progress" {
+		t.Errorf("output of print was not flushed. got=%q", out.String())
+	}
+}
+
+func TestRegisterModule(t *testing.T) {
+	double := &Builtin{Fn: func(args ...Object) Object {
+		return &Integer{Value: args[0].(*Integer).Value * 2}
+	}}
+
+	err := RegisterModule("test_numbers", map[string]*Builtin{"double": double})
+	if err != nil {
+		t.Fatalf("module not registered: %s", err)
+	}
+
+	module := GetModuleByName("test_numbers")
+	if module == nil {
+		t.Fatalf("module not found")
+	}
+
+	member, ok := module.Member("double")
+	if !ok || member != double {
+		t.Fatalf("wrong member. got=%v", member)
+	}
+
+	if GetBuiltInByName("test_numbers.double") != double {
+		t.Errorf("member not registered as built-in function test_numbers.double")
+	}
+
+	err = RegisterModule("test_numbers", map[string]*Builtin{})
+	if err == nil || err.Error() != "module test_numbers is already registered" {
+		t.Errorf("wrong error for duplicate module. got=%v", err)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	triple := &Builtin{Fn: func(args ...Object) Object {
+		return &Integer{Value: args[0].(*Integer).Value * 3}
+	}}
+
+	index, err := RegisterBuiltin("test_triple", triple)
+	if err != nil {
+		t.Fatalf("built-in function not registered: %s", err)
+	}
+	if index != len(Builtins)-1 || Builtins[index].Builtin != triple {
+		t.Fatalf("built-in function not appended. got index=%d", index)
+	}
+
+	found, ok := LookupBuiltin("test_triple")
+	if !ok || found != index {
+		t.Errorf("wrong index. want=%d, got=%d (%t)", index, found, ok)
+	}
+	if GetBuiltInByName("test_triple") != triple {
+		t.Errorf("built-in function not found by name")
+	}
+
+	// the indices of existing built-in functions never change
+	if found, ok := LookupBuiltin("len"); !ok || found != 0 {
+		t.Errorf("wrong index of len. got=%d (%t)", found, ok)
+	}
+
+	_, err = RegisterBuiltin("test_triple", triple)
+	if err == nil || err.Error() != "built-in function test_triple is already registered" {
+		t.Errorf("wrong error for duplicate built-in function. got=%v", err)
+	}
+}
+
+func TestModuleMemberIndices(t *testing.T) {
+	// the compiled programs refer to built-in functions by index, a new built-in function must not move the members
+	tests := map[string]int{
+		"len":              0,
+		"runtime_stats":    52,
+		"strings.contains": 53,
+		"strings.upper":    59,
+		"math.abs":         60,
+		"math.pow":         63,
+	}
+
+	for name, expected := range tests {
+		if index, ok := LookupBuiltin(name); !ok || index != expected {
+			t.Errorf("wrong index of %s. want=%d, got=%d (%t)", name, expected, index, ok)
+		}
+	}
+
+	for _, name := range []string{"strings", "math"} {
+		module := GetModuleByName(name)
+		for member, index := range module.Members {
+			if Builtins[index].Name != name+"."+member {
+				t.Errorf("%s.%s refers to %s", name, member, Builtins[index].Name)
+			}
+		}
+	}
+}
+
+func TestSummarizeHeap(t *testing.T) {
+	shared := &String{Value: "shared"}
+	arr := &Array{Elements: []Object{shared, &Integer{Value: 1}, shared}}
+	// the array refers to itself, the walk must still terminate
+	arr.Elements = append(arr.Elements, arr)
+
+	summary := SummarizeHeap([]Object{arr, shared})
+	if summary.Objects != 3 {
+		t.Fatalf("wrong number of objects. want=3, got=%d", summary.Objects)
+	}
+	if summary.Counts[STRING_OBJ] != 1 || summary.Counts[ARRAY_OBJ] != 1 || summary.Counts[INTEGER_OBJ] != 1 {
+		t.Errorf("wrong counts. got=%v", summary.Counts)
+	}
+	if summary.Bytes != Size(arr)+Size(shared)+Size(&Integer{}) {
+		t.Errorf("wrong total size. got=%d", summary.Bytes)
+	}
+	if RetainedSize(arr) != summary.Bytes {
+		t.Errorf("wrong retained size. want=%d, got=%d", summary.Bytes, RetainedSize(arr))
+	}
+	if RetainedSize(shared) != Size(shared) {
+		t.Errorf("wrong retained size of string. got=%d", RetainedSize(shared))
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	fn := &CompiledFunction{Instructions: []byte{1, 0, 0}, NumLocals: 1, NumParameters: 1, Name: "add", Parameters: []string{"a"}}
+	constants := []Object{&Integer{Value: 1}, fn}
+
+	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+	key := &String{Value: "k"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: &Array{Elements: []Object{TRUE, NULL}}}
+
+	values := []Object{
+		&Integer{Value: -42},
+		&String{Value: "monkey"},
+		FALSE,
+		&Error{Message: "boom"},
+		&Array{Elements: []Object{&Integer{Value: 1}, hash, GetBuiltInByName("len")}},
+		&Closure{Fn: fn, Free: []Object{&Integer{Value: 2}}},
+		&Closure{Fn: &CompiledFunction{Instructions: []byte{2}, Name: "inline"}},
+		&Closure{Fn: &CompiledFunction{Instructions: []byte{2}, NumParameters: 2, NumDefaults: 1, Variadic: true, Name: "rest"}},
+		GetModuleByName("strings"),
+		&Record{Shape: &RecordShape{Fields: []string{"x", "y"}}, Values: []Object{&Integer{Value: 1}, hash}},
+		&RecordShape{Fields: []string{"x"}},
+		Intern("red"),
+		&Float{Value: -1.5},
+	}
+
+	for _, value := range values {
+		var buf bytes.Buffer
+		if err := EncodeJSON(&buf, value, constants); err != nil {
+			t.Fatalf("EncodeJSON failed for %s: %s", value.Inspect(), err)
+		}
+		fromJSON, err := DecodeJSON(&buf, constants)
+		if err != nil {
+			t.Fatalf("DecodeJSON failed for %s: %s", value.Inspect(), err)
+		}
+
+		data, err := EncodeBinary(value, constants)
+		if err != nil {
+			t.Fatalf("EncodeBinary failed for %s: %s", value.Inspect(), err)
+		}
+		fromBinary, err := DecodeBinary(data, constants)
+		if err != nil {
+			t.Fatalf("DecodeBinary failed for %s: %s", value.Inspect(), err)
+		}
+
+		// closures are compared by their encoding, their Inspect output holds their address
+		encoder := &Encoder{Constants: constants}
+		want, _ := encoder.Encode(value)
+		for _, decoded := range []Object{fromJSON, fromBinary} {
+			got, err := encoder.Encode(decoded)
+			if err != nil {
+				t.Fatalf("encoding the decoded value failed: %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("wrong decoded value. want=%s (%s), got=%s (%s)", value.Inspect(), value.Type(), decoded.Inspect(), decoded.Type())
+			}
+		}
+	}
+
+	data, err := EncodeBinary(&Closure{Fn: fn}, constants)
+	if err != nil {
+		t.Fatalf("EncodeBinary failed: %s", err)
+	}
+	closure, err := DecodeBinary(data, constants)
+	if err != nil {
+		t.Fatalf("DecodeBinary failed: %s", err)
+	}
+	if closure.(*Closure).Fn != fn {
+		t.Errorf("closure does not share the compiled function of the constant pool")
+	}
+}
+
+func TestEncodingErrors(t *testing.T) {
+	if _, err := EncodeBinary(&Iterator{}, nil); err == nil || err.Error() != "values of type ITERATOR cannot be encoded" {
+		t.Errorf("wrong error for iterator: %v", err)
+	}
+
+	data, err := EncodeBinary(&Array{Elements: []Object{&String{Value: "abc"}}}, nil)
+	if err != nil {
+		t.Fatalf("EncodeBinary failed: %s", err)
+	}
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{data[:len(data)-1], "invalid encoding: unexpected EOF"},
+		{append(append([]byte{}, data...), 0), "invalid encoding: unexpected data after value"},
+		{append([]byte("MKY\x01"), data[4:]...), "unsupported encoding version 1, expected 3"},
+		// the instruction set is written after the version as a string of 8 bytes, its length is the varint 0x10
+		{append([]byte("MKY\x03\x10zzzzzzzz"), data[13:]...),
+			fmt.Sprintf(`bytecode compiled for instruction set "zzzzzzzz" cannot run on instruction set %q, compile it again`, code.InstructionSet)},
+		{[]byte("{}"), "invalid encoding: missing header"},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeBinary(tt.data, nil)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+
+	_, err = DecodeJSON(bytes.NewBufferString(`{"version": 0, "value": {"type": "NULL"}}`), nil)
+	if err == nil || err.Error() != "unsupported encoding version 0, expected 3" {
+		t.Errorf("wrong error for JSON version: %v", err)
+	}
+	_, err = DecodeJSON(bytes.NewBufferString(`{"version": 3, "value": {"type": "NULL"}}`), nil)
+	expected := fmt.Sprintf(`bytecode compiled for instruction set "" cannot run on instruction set %q, compile it again`, code.InstructionSet)
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error for JSON instruction set: %v", err)
+	}
+}
+
+func TestInspectRecursiveStructures(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+	key := &String{Value: "self"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: hash}
+
+	// the same array twice next to each other is not a cycle
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	siblings := &Array{Elements: []Object{shared, shared}}
+
+	nested := &Array{Elements: []Object{
+		&Integer{Value: 1},
+		&Array{Elements: []Object{&Integer{Value: 2}, &Array{Elements: []Object{&Integer{Value: 3}}}}},
+		&Integer{Value: 4},
+		&Integer{Value: 5},
+	}}
+
+	tests := []struct {
+		obj      Object
+		options  InspectOptions
+		expected string
+	}{
+		{array, InspectOptions{}, "[1, [...]]"},
+		{hash, InspectOptions{}, "{self: {...}}"},
+		{&Array{Elements: []Object{hash}}, InspectOptions{}, "[{self: {...}}]"},
+		{siblings, InspectOptions{}, "[[2], [2]]"},
+		{nested, InspectOptions{}, "[1, [2, [3]], 4, 5]"},
+		{nested, InspectOptions{MaxDepth: 2}, "[1, [2, [...]], 4, 5]"},
+		{nested, InspectOptions{MaxWidth: 2}, "[1, [2, [3]], ...]"},
+		{nested, InspectOptions{MaxDepth: 1, MaxWidth: 3}, "[1, [...], 4, ...]"},
+		{hash, InspectOptions{QuoteStrings: true}, `{"self": {...}}`},
+		{&String{Value: "a \"b\"\n"}, InspectOptions{QuoteStrings: true}, `"a \"b\"\n"`},
+	}
+
+	for _, tt := range tests {
+		if got := InspectWith(tt.obj, tt.options); got != tt.expected {
+			t.Errorf("wrong output with %+v. want=%q, got=%q", tt.options, tt.expected, got)
+		}
+	}
+
+	if array.Inspect() != "[1, [...]]" {
+		t.Errorf("wrong Inspect output: %q", array.Inspect())
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 1}}}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	key := &String{Value: "inner"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: inner}
+	outer := &Array{Elements: []Object{hash}}
+	// a collection containing itself is frozen once
+	outer.Elements = append(outer.Elements, outer)
+
+	if IsFrozen(outer) || IsFrozen(hash) || IsFrozen(inner) {
+		t.Fatalf("collections must not start frozen")
+	}
+	if Freeze(outer) != outer {
+		t.Fatalf("Freeze must return the collection it froze")
+	}
+	for _, obj := range []Object{outer, hash, inner, key, &Integer{Value: 1}, NULL} {
+		if !IsFrozen(obj) {
+			t.Errorf("%s is not frozen", obj.Inspect())
+		}
+	}
+	if IsFrozen(&Closure{}) {
+		t.Errorf("a closure must never be frozen")
+	}
+
+	// a frozen collection is copied on write, the copy shares the elements of the original
+	copied, ok := Mutable(outer).(*Array)
+	if !ok || copied == outer || IsFrozen(copied) {
+		t.Fatalf("Mutable must copy a frozen array. got=%v", copied)
+	}
+	copied.Elements[0] = NULL
+	if outer.Elements[0] != hash || copied.Elements[1] != outer {
+		t.Errorf("changing the copy changed the original")
+	}
+	copiedHash := Mutable(hash).(*Hash)
+	delete(copiedHash.Pairs, key.HashKey())
+	if len(hash.Pairs) != 1 || IsFrozen(copiedHash) {
+		t.Errorf("changing the copy of the hash changed the original")
+	}
+	if Mutable(copied) != copied {
+		t.Errorf("Mutable must return a collection that is not frozen as it is")
+	}
+}
@@ -0,0 +1,105 @@
+package object
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// pmapBuiltin maps an array like `map`, but calls the function for several elements at the same time,
+// `pmap(fn, array, workers)`. The elements are split into one contiguous shard per worker and the results
+// are merged back in the order of the elements, workers defaults to the number of CPUs. A failed call stops
+// the workers and pmap fails with the error of the first element that failed. An engine that cannot run
+// functions concurrently maps the elements one after another.
+var pmapBuiltin = &Builtin{
+	ParallelFn: func(call CallFunction, fork ForkFunction, args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+		}
+		fn := args[0]
+		if !isCallable(fn) {
+			return newError("first argument to `pmap` must be a function, got %s", fn.Type())
+		}
+		array, ok := args[1].(*Array)
+		if !ok {
+			return newError("second argument to `pmap` must be ARRAY, got %s", args[1].Type())
+		}
+		workers := runtime.GOMAXPROCS(0)
+		if len(args) == 3 {
+			n, ok := args[2].(*Integer)
+			if !ok || n.Value < 1 {
+				return newError("third argument to `pmap` must be a positive INTEGER, got %s", args[2].Inspect())
+			}
+			workers = int(n.Value)
+		}
+
+		if fork == nil {
+			elements, errObj := callForEach(call, fn, array.Elements)
+			if errObj != nil {
+				return errObj
+			}
+			return &Array{Elements: elements}
+		}
+
+		// a worker is forked even for an empty array, so a function that cannot run on workers always fails
+		if workers > len(array.Elements) {
+			workers = len(array.Elements)
+		}
+		if workers < 1 {
+			workers = 1
+		}
+		calls := make([]CallFunction, workers)
+		for i := range calls {
+			workerCall, errObj := fork(fn)
+			if errObj != nil {
+				return errObj
+			}
+			calls[i] = workerCall
+		}
+
+		// every worker writes the results of its own shard, so the results need no lock
+		results := make([]Object, len(array.Elements))
+		shard := (len(array.Elements) + workers - 1) / workers
+		var failed int32
+		var wg sync.WaitGroup
+		for i, workerCall := range calls {
+			low, high := i*shard, (i+1)*shard
+			if high > len(array.Elements) {
+				high = len(array.Elements)
+			}
+			wg.Add(1)
+			go func(call CallFunction, low, high int) {
+				defer wg.Done()
+				for j := low; j < high && atomic.LoadInt32(&failed) == 0; j++ {
+					results[j] = call(fn, array.Elements[j])
+					if isError(results[j]) {
+						atomic.StoreInt32(&failed, 1)
+					}
+				}
+			}(workerCall, low, high)
+		}
+		wg.Wait()
+
+		for _, result := range results {
+			if isError(result) {
+				return result
+			}
+		}
+		return &Array{Elements: results}
+	},
+}
+
+// KeepsState reports whether the built-in function keeps state between calls, like a memoized function,
+// which keeps it from running on several workers at the same time. The built-in functions of the registry
+// and the pure ones do not.
+func (b *Builtin) KeepsState() bool {
+	if b.Pure {
+		return false
+	}
+	for _, def := range Builtins {
+		if def.Builtin == b {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package object
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Printer holds the configuration used by the printing built-in functions `puts` and `print`.
+// Writer is where their output is written to, embedders can replace it to capture the output of scripts.
+// Inspect controls how values are printed, the REPL uses the same options for the results it echoes,
+// so a value looks the same whether it is printed by a script or by the REPL.
+// Separator is written between the arguments of a single call, `puts(1, 2)` prints "1 2" with the default " ".
+type Printer struct {
+	Writer    io.Writer
+	Inspect   InspectOptions
+	Separator string
+}
+
+// DefaultSeparator is the Separator of Output unless it is configured, like the space between the arguments of print functions elsewhere
+const DefaultSeparator = " "
+
+// Output is the Printer used by the printing built-in functions
+var Output = &Printer{Writer: os.Stdout, Separator: DefaultSeparator}
+
+// Flusher is implemented by writers buffering their output, like a *bufio.Writer
+type Flusher interface {
+	Flush() error
+}
+
+// printing serializes the calls of Print, the workers of pmap can print at the same time
+var printing sync.Mutex
+
+// Print writes the printed form of the objects to the Printer's Writer, joined by the Separator and followed by end.
+// A Writer buffering its output is flushed after every call, so the output of a long-running
+// script shows up while it runs instead of once it is done.
+func (p *Printer) Print(objects []Object, end string) {
+	printing.Lock()
+	defer printing.Unlock()
+
+	var out strings.Builder
+	for i, obj := range objects {
+		if i > 0 {
+			out.WriteString(p.Separator)
+		}
+		out.WriteString(InspectWith(obj, p.Inspect))
+	}
+	out.WriteString(end)
+	io.WriteString(p.Writer, out.String())
+	if f, ok := p.Writer.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+// printBuiltin prints its arguments like `puts` does, but without the newline at the end.
+// It lets scripts build a line from several calls, for example to report progress.
+var printBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		Output.Print(args, "")
+		return nil
+	},
+}
@@ -0,0 +1,96 @@
+package object
+
+import "strings"
+
+const (
+	RECORD_OBJ       = "RECORD"
+	RECORD_SHAPE_OBJ = "RECORD_SHAPE"
+)
+
+// RecordShape is the ordered list of field names of a record. The compiler adds the shape of every
+// record literal to the constant pool once, records created by the same literal share it,
+// so the offset of a field is known at compile time whenever the shape of a record is.
+type RecordShape struct {
+	Fields []string
+}
+
+// Type returns the ObjectType (RECORD_SHAPE_OBJ) associated with the referenced RecordShape
+func (s *RecordShape) Type() ObjectType { return RECORD_SHAPE_OBJ }
+
+// Inspect returns the field names of the RecordShape
+func (s *RecordShape) Inspect() string { return "record{" + strings.Join(s.Fields, ", ") + "}" }
+
+// Offset returns the position of the named field in the records of the shape
+func (s *RecordShape) Offset(name string) (int, bool) {
+	for i, field := range s.Fields {
+		if field == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Record holds a fixed set of named fields, Values holds the value of every field of the Shape at its offset.
+// Unlike hashes, records are compared by their fields: two records are equal when they have the same fields
+// in the same order with equal values.
+type Record struct {
+	Shape  *RecordShape
+	Values []Object
+	// frozen marks a record shared as a constant, see Freeze
+	frozen bool
+}
+
+// Type returns the ObjectType (RECORD_OBJ) associated with the referenced Record
+func (r *Record) Type() ObjectType { return RECORD_OBJ }
+
+// Inspect returns the record as a record literal
+func (r *Record) Inspect() string {
+	return InspectWith(r, DefaultInspectOptions)
+}
+
+// Len returns the number of fields of the Record, which implements Sized
+func (r *Record) Len() int { return len(r.Values) }
+
+// Field returns the value of the named field
+func (r *Record) Field(name string) (Object, bool) {
+	offset, ok := r.Shape.Offset(name)
+	if !ok {
+		return nil, false
+	}
+	return r.Values[offset], true
+}
+
+// Equal reports whether both records have the same fields in the same order with equal values.
+// Numbers and strings are compared by value, nested records by their fields and other values by identity.
+func (r *Record) Equal(other *Record) bool {
+	if r == other {
+		return true
+	}
+	if r.Shape != other.Shape && strings.Join(r.Shape.Fields, ",") != strings.Join(other.Shape.Fields, ",") {
+		return false
+	}
+	for i, value := range r.Values {
+		if !fieldsEqual(value, other.Values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldsEqual compares the values of two record fields
+func fieldsEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer, *Float:
+		x, _ := ToFloat(a)
+		y, ok := ToFloat(b)
+		return ok && x == y
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Record:
+		b, ok := b.(*Record)
+		return ok && a.Equal(b)
+	default:
+		return a == b
+	}
+}
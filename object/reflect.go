@@ -0,0 +1,112 @@
+package object
+
+// arityBuiltin returns the number of parameters of a function.
+// Built-in functions accept a varying number of arguments, their arity is NULL.
+var arityBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		params, errObj := functionParameters("arity", args)
+		if errObj != nil {
+			return errObj
+		}
+		if params == nil {
+			return NULL
+		}
+		return &Integer{Value: int64(len(params))}
+	},
+}
+
+// paramsBuiltin returns the names of the parameters of a function as an array of strings.
+// The parameters of built-in functions are unknown, they result in NULL.
+var paramsBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		params, errObj := functionParameters("params", args)
+		if errObj != nil {
+			return errObj
+		}
+		if params == nil {
+			return NULL
+		}
+
+		elements := make([]Object, len(params))
+		for i, param := range params {
+			elements[i] = &String{Value: param}
+		}
+		return &Array{Elements: elements}
+	},
+}
+
+// sourceBuiltin returns the source code of a function. Compiled functions only retain their
+// source code when the compiler keeps debug information, otherwise the result is NULL like for built-in functions.
+var sourceBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		switch fn := args[0].(type) {
+		case *Function:
+			return &String{Value: fn.Inspect()}
+		case *Closure:
+			if fn.Fn.Source == "" {
+				return NULL
+			}
+			return &String{Value: fn.Fn.Source}
+		case *Builtin:
+			return NULL
+		default:
+			return newError("argument to `source` must be a function, got %s", args[0].Type())
+		}
+	},
+}
+
+// docBuiltin returns the text of the ### doc comment preceding the definition of a function,
+// or NULL when the function is not documented.
+var docBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		var doc string
+		switch fn := args[0].(type) {
+		case *Function:
+			doc = fn.Doc
+		case *Closure:
+			doc = fn.Fn.Doc
+		case *Builtin:
+		default:
+			return newError("argument to `doc` must be a function, got %s", args[0].Type())
+		}
+
+		if doc == "" {
+			return NULL
+		}
+		return &String{Value: doc}
+	},
+}
+
+// functionParameters returns the parameter names of the function given to the reflection built-in function name.
+// The names are nil for built-in functions.
+func functionParameters(name string, args []Object) ([]string, Object) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch fn := args[0].(type) {
+	case *Function:
+		params := make([]string, len(fn.Parameters))
+		for i, param := range fn.Parameters {
+			params[i] = param.Value
+		}
+		return params, nil
+	case *Closure:
+		if fn.Fn.Parameters == nil {
+			return make([]string, fn.Fn.NumParameters), nil
+		}
+		return fn.Fn.Parameters, nil
+	case *Builtin:
+		return nil, nil
+	default:
+		return nil, newError("argument to `%s` must be a function, got %s", name, args[0].Type())
+	}
+}
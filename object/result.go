@@ -0,0 +1,115 @@
+package object
+
+// Results are the hashes built-in functions return for operations that can fail without aborting the program:
+// {"ok": value} when the operation succeeds and {"error": message} when it fails. The `ok` and `err`
+// built-in functions build them in programs, `is_ok`, `unwrap` and `unwrap_or` take them apart.
+
+// resultOk and resultError are the keys of the single pair of a result
+var (
+	resultOk    = &String{Value: "ok"}
+	resultError = &String{Value: "error"}
+)
+
+// Ok returns the result of a successful operation holding its value
+func Ok(value Object) *Hash {
+	if value == nil {
+		value = NULL
+	}
+	return &Hash{Pairs: map[HashKey]HashPair{resultOk.HashKey(): {Key: resultOk, Value: value}}}
+}
+
+// Err returns the result of a failed operation holding its error message
+func Err(message string) *Hash {
+	msg := &String{Value: message}
+	return &Hash{Pairs: map[HashKey]HashPair{resultError.HashKey(): {Key: resultError, Value: msg}}}
+}
+
+// unpackResult returns the value of a successful result or the message of a failed one
+// along with which it is. It returns an error when the object is not a result.
+func unpackResult(name string, obj Object) (value Object, ok bool, errObj *Error) {
+	hash, isHash := obj.(*Hash)
+	if isHash && len(hash.Pairs) == 1 {
+		if pair, found := hash.Pairs[resultOk.HashKey()]; found {
+			return pair.Value, true, nil
+		}
+		if pair, found := hash.Pairs[resultError.HashKey()]; found {
+			return pair.Value, false, nil
+		}
+	}
+	return nil, false, newError("argument to `%s` must be a result, {\"ok\": value} or {\"error\": message}, got %s", name, obj.Inspect())
+}
+
+// okBuiltin returns the result of a successful operation holding the given value
+var okBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		return Ok(args[0])
+	},
+}
+
+// errBuiltin returns the result of a failed operation holding the given error message
+var errBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		msg, ok := args[0].(*String)
+		if !ok {
+			return newError("argument to `err` must be STRING, got %s", args[0].Type())
+		}
+		return Err(msg.Value)
+	},
+}
+
+// isOkBuiltin reports whether a result is successful
+var isOkBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		_, ok, errObj := unpackResult("is_ok", args[0])
+		if errObj != nil {
+			return errObj
+		}
+		if ok {
+			return TRUE
+		}
+		return FALSE
+	},
+}
+
+// unwrapBuiltin returns the value of a successful result, a failed result aborts the program with its message
+var unwrapBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		value, ok, errObj := unpackResult("unwrap", args[0])
+		if errObj != nil {
+			return errObj
+		}
+		if !ok {
+			return newError("unwrap of an error result: %s", value.Inspect())
+		}
+		return value
+	},
+}
+
+// unwrapOrBuiltin returns the value of a successful result, or the given default for a failed one
+var unwrapOrBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		value, ok, errObj := unpackResult("unwrap_or", args[0])
+		if errObj != nil {
+			return errObj
+		}
+		if !ok {
+			return args[1]
+		}
+		return value
+	},
+}
@@ -0,0 +1,244 @@
+package object
+
+// rangeBuiltin returns a lazy iterator over the integers from start (inclusive) to end (exclusive).
+// It accepts range(end), range(start, end) and range(start, end, step).
+var rangeBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) < 1 || len(args) > 3 {
+			return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+		}
+
+		bounds := []int64{0, 0, 1}
+		for i, arg := range args {
+			integer, ok := arg.(*Integer)
+			if !ok {
+				return newError("arguments to `range` must be INTEGER, got %s", arg.Type())
+			}
+			bounds[i] = integer.Value
+		}
+		if len(args) == 1 {
+			bounds[0], bounds[1] = 0, bounds[0]
+		}
+
+		current, end, step := bounds[0], bounds[1], bounds[2]
+		if step == 0 {
+			return newError("step of `range` must not be 0")
+		}
+
+		return &Iterator{
+			Next: func(call CallFunction) (Object, bool) {
+				if (step > 0 && current >= end) || (step < 0 && current <= end) {
+					return nil, false
+				}
+				value := current
+				current += step
+				return &Integer{Value: value}, true
+			},
+			// the number of steps left between the current integer and the end
+			Length: func() int {
+				switch {
+				case step > 0 && current < end:
+					return int((end - current + step - 1) / step)
+				case step < 0 && current > end:
+					return int((current - end - step - 1) / -step)
+				default:
+					return 0
+				}
+			},
+		}
+	},
+}
+
+// mapBuiltin calls the function with every element of the sequence. Arrays are mapped to a new array,
+// iterators are mapped lazily to a new iterator that calls the function as elements are requested.
+var mapBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+
+		fn := args[0]
+		switch seq := args[1].(type) {
+		case *Array:
+			elements, errObj := callForEach(call, fn, seq.Elements)
+			if errObj != nil {
+				return errObj
+			}
+			return &Array{Elements: elements}
+		case *Iterator:
+			return &Iterator{Next: func(call CallFunction) (Object, bool) {
+				el, ok := seq.Next(call)
+				if !ok || isError(el) {
+					return el, ok
+				}
+				return call(fn, el), true
+			}}
+		default:
+			return newError("second argument to `map` must be ARRAY or ITERATOR, got %s", args[1].Type())
+		}
+	},
+}
+
+// filterBuiltin keeps the elements of the sequence for which the function returns a truthy value.
+// Arrays are filtered into a new array, iterators are filtered lazily into a new iterator.
+var filterBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+
+		fn := args[0]
+		switch seq := args[1].(type) {
+		case *Array:
+			elements := []Object{}
+			for _, el := range seq.Elements {
+				keep := call(fn, el)
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					elements = append(elements, el)
+				}
+			}
+			return &Array{Elements: elements}
+		case *Iterator:
+			return &Iterator{Next: func(call CallFunction) (Object, bool) {
+				for {
+					el, ok := seq.Next(call)
+					if !ok || isError(el) {
+						return el, ok
+					}
+					keep := call(fn, el)
+					if isError(keep) {
+						return keep, true
+					}
+					if isTruthy(keep) {
+						return el, true
+					}
+				}
+			}}
+		default:
+			return newError("second argument to `filter` must be ARRAY or ITERATOR, got %s", args[1].Type())
+		}
+	},
+}
+
+// takeBuiltin returns the first n elements of the sequence. For an iterator the result is
+// a new iterator which stops after n elements without requesting any more.
+var takeBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		n, errObj := countAndSequence("take", args)
+		if errObj != nil {
+			return errObj
+		}
+
+		switch seq := args[1].(type) {
+		case *Array:
+			if n > len(seq.Elements) {
+				n = len(seq.Elements)
+			}
+			elements := make([]Object, n)
+			copy(elements, seq.Elements[:n])
+			return &Array{Elements: elements}
+		default:
+			it := seq.(*Iterator)
+			taken := 0
+			return &Iterator{Next: func(call CallFunction) (Object, bool) {
+				if taken >= n {
+					return nil, false
+				}
+				taken++
+				return it.Next(call)
+			}}
+		}
+	},
+}
+
+// dropBuiltin skips the first n elements of the sequence. For an iterator the result is
+// a new iterator which skips the elements once its first element is requested.
+var dropBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		n, errObj := countAndSequence("drop", args)
+		if errObj != nil {
+			return errObj
+		}
+
+		switch seq := args[1].(type) {
+		case *Array:
+			if n > len(seq.Elements) {
+				n = len(seq.Elements)
+			}
+			elements := make([]Object, len(seq.Elements)-n)
+			copy(elements, seq.Elements[n:])
+			return &Array{Elements: elements}
+		default:
+			it := seq.(*Iterator)
+			dropped := false
+			return &Iterator{Next: func(call CallFunction) (Object, bool) {
+				for ; !dropped && n > 0; n-- {
+					el, ok := it.Next(call)
+					if !ok || isError(el) {
+						return el, ok
+					}
+				}
+				dropped = true
+				return it.Next(call)
+			}}
+		}
+	},
+}
+
+// collectBuiltin is the terminal operation of lazy sequences, it consumes an iterator into an array.
+// Collecting an array returns a copy of it.
+var collectBuiltin = &Builtin{
+	HigherOrderFn: func(call CallFunction, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		switch seq := args[0].(type) {
+		case *Array:
+			elements := make([]Object, len(seq.Elements))
+			copy(elements, seq.Elements)
+			return &Array{Elements: elements}
+		case *Iterator:
+			elements := []Object{}
+			for {
+				el, ok := seq.Next(call)
+				if !ok {
+					return &Array{Elements: elements}
+				}
+				if isError(el) {
+					return el
+				}
+				elements = append(elements, el)
+			}
+		default:
+			return newError("argument to `collect` must be ARRAY or ITERATOR, got %s", args[0].Type())
+		}
+	},
+}
+
+// countAndSequence validates the arguments of take and drop, a non-negative integer and a sequence
+func countAndSequence(name string, args []Object) (int, Object) {
+	if len(args) != 2 {
+		return 0, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	n, ok := args[0].(*Integer)
+	if !ok || n.Value < 0 {
+		return 0, newError("first argument to `%s` must be a non-negative INTEGER, got %s", name, args[0].Inspect())
+	}
+
+	switch args[1].(type) {
+	case *Array, *Iterator:
+		return int(n.Value), nil
+	default:
+		return 0, newError("second argument to `%s` must be ARRAY or ITERATOR, got %s", name, args[1].Type())
+	}
+}
+
+// isTruthy reports whether the object counts as true in a condition, only false and null do not
+func isTruthy(obj Object) bool {
+	return obj != nil && obj != FALSE && obj != NULL
+}
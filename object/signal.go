@@ -0,0 +1,164 @@
+package object
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+)
+
+// Signals holds the handlers a program registered with `on_signal` and the signals received but not handled yet.
+// Every run has handlers of its own, as they are functions of its program: the engines create them when a run
+// starts and stop them when it returns, a REPL shares them between its inputs. A signal arrives on a goroutine
+// of its own while the program runs, so its handler is not called right away: the engine running the program
+// calls Next between two instructions, where calling a function is safe.
+type Signals struct {
+	mu       sync.Mutex
+	handlers map[os.Signal]Object
+	pending  []os.Signal
+	// count is the length of pending, the engines read it without taking the lock
+	count int32
+	// relay receives the signals of the process that have a handler
+	relay chan os.Signal
+}
+
+// running holds the signals of the runs that registered a handler, RaiseSignal forwards a signal to all of them
+var running = struct {
+	sync.Mutex
+	signals map[*Signals]bool
+}{signals: make(map[*Signals]bool)}
+
+// NewSignals returns the signals of a run, without any handler
+func NewSignals() *Signals {
+	return &Signals{handlers: make(map[os.Signal]Object)}
+}
+
+// onSignalBuiltin registers a function called without arguments when the process receives the signal,
+// `on_signal("SIGTERM", fn() { cleanup(); exit(1) })`. The handler replaces the default behavior of the
+// signal, so the program keeps running once the handler returns, and a later call replaces the handler.
+var onSignalBuiltin = &Builtin{
+	SignalFn: func(signals *Signals, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		name, ok := args[0].(*String)
+		if !ok {
+			return newError("first argument to `on_signal` must be STRING, got %s", args[0].Type())
+		}
+		sig, ok := signalNames[name.Value]
+		if !ok {
+			return newError("unsupported signal %q, want %s", name.Value, supportedSignals)
+		}
+		if !isCallable(args[1]) {
+			return newError("second argument to `on_signal` must be a function, got %s", args[1].Type())
+		}
+		if signals == nil {
+			return newError("`on_signal` must be called by a program run with a context")
+		}
+
+		signals.handle(sig, args[1])
+		return NULL
+	},
+}
+
+// handle registers the handler of the signal and starts relaying the signal from the process
+func (s *Signals) handle(sig os.Signal, handler Object) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.relay == nil {
+		s.relay = make(chan os.Signal, 1)
+		go func(relay <-chan os.Signal) {
+			for sig := range relay {
+				s.raise(sig)
+			}
+		}(s.relay)
+
+		running.Lock()
+		running.signals[s] = true
+		running.Unlock()
+	}
+	s.handlers[sig] = handler
+	signal.Notify(s.relay, sig)
+}
+
+// raise queues the signal when the run has a handler for it
+func (s *Signals) raise(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.handlers[sig]; !ok {
+		return
+	}
+	s.pending = append(s.pending, sig)
+	atomic.StoreInt32(&s.count, int32(len(s.pending)))
+}
+
+// Next removes the oldest signal received since the last call and returns its handler.
+// It reports false when no signal is waiting, which is cheap enough to check between instructions.
+func (s *Signals) Next() (Object, bool) {
+	if atomic.LoadInt32(&s.count) == 0 {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return nil, false
+	}
+	sig := s.pending[0]
+	s.pending = s.pending[1:]
+	atomic.StoreInt32(&s.count, int32(len(s.pending)))
+	return s.handlers[sig], true
+}
+
+// Stop removes every handler of the run and the signals waiting for them, the signals get their
+// default behavior back unless another run handles them.
+func (s *Signals) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.relay != nil {
+		signal.Stop(s.relay)
+		close(s.relay)
+		s.relay = nil
+
+		running.Lock()
+		delete(running.signals, s)
+		running.Unlock()
+	}
+	s.handlers = make(map[os.Signal]Object)
+	s.pending = nil
+	atomic.StoreInt32(&s.count, 0)
+}
+
+// RaiseSignal queues the signal as if the process had received it, its handler is called by the engine
+// running the program at the next instruction. Embedders use it to forward the signals they receive
+// themselves. The signal reaches every running program with a handler for it, the others ignore it.
+func RaiseSignal(sig os.Signal) {
+	for _, signals := range runningSignals() {
+		signals.raise(sig)
+	}
+}
+
+// HandlesSignal reports whether a running program registered a handler for the signal with `on_signal`
+func HandlesSignal(sig os.Signal) bool {
+	for _, signals := range runningSignals() {
+		signals.mu.Lock()
+		_, ok := signals.handlers[sig]
+		signals.mu.Unlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runningSignals returns the signals of the runs that registered a handler. The runs take the lock of
+// running while holding their own, so it is released before the lock of a run is taken.
+func runningSignals() []*Signals {
+	running.Lock()
+	defer running.Unlock()
+	list := make([]*Signals, 0, len(running.signals))
+	for signals := range running.signals {
+		list = append(list, signals)
+	}
+	return list
+}
@@ -0,0 +1,19 @@
+//go:build !js && !wasip1
+// +build !js,!wasip1
+
+package object
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalNames maps the names accepted by `on_signal` to the signals
+var signalNames = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  os.Interrupt,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// supportedSignals lists the names of signalNames for the error of `on_signal`
+const supportedSignals = "SIGHUP, SIGINT or SIGTERM"
@@ -0,0 +1,15 @@
+//go:build js || wasip1
+// +build js wasip1
+
+package object
+
+import "os"
+
+// signalNames maps the names accepted by `on_signal` to the signals, a program running in a WebAssembly host
+// only receives the interrupts its embedder raises with RaiseSignal
+var signalNames = map[string]os.Signal{
+	"SIGINT": os.Interrupt,
+}
+
+// supportedSignals lists the names of signalNames for the error of `on_signal`
+const supportedSignals = "SIGINT"
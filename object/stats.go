@@ -0,0 +1,60 @@
+package object
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Counts is the work done by an engine. Instructions is the number of instructions the VM executed,
+// or the number of nodes the evaluator evaluated, and Frames the number of function calls.
+type Counts struct {
+	Instructions int64
+	Frames       int64
+}
+
+// totals holds the counts reported by every engine of the process, the workers of pmap report theirs concurrently
+var totals Counts
+
+// AddCounts adds the counts of an engine to the totals read by `runtime_stats`. The engines keep their counts
+// on their own and report them before calling a built-in function, so the totals are up to date when it runs.
+func AddCounts(counts Counts) {
+	if counts.Instructions != 0 {
+		atomic.AddInt64(&totals.Instructions, counts.Instructions)
+	}
+	if counts.Frames != 0 {
+		atomic.AddInt64(&totals.Frames, counts.Frames)
+	}
+}
+
+// runtimeStatsBuiltin returns a hash describing the cost of the program so far, `runtime_stats()["instructions"]`.
+// instructions and frames are the work of the engines since the process started, objects_allocated is the number
+// of heap objects the Go runtime allocated and heap_objects the number of those still live. memory holds a snapshot
+// of the memory statistics of the Go runtime, in bytes, along with the number and the total pause of the garbage
+// collections. Reading them stops the program for a moment, so the calls belong outside of hot loops.
+var runtimeStatsBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+
+		var memory runtime.MemStats
+		runtime.ReadMemStats(&memory)
+		return FromNative(map[string]interface{}{
+			"instructions":      atomic.LoadInt64(&totals.Instructions),
+			"frames":            atomic.LoadInt64(&totals.Frames),
+			"objects_allocated": int64(memory.Mallocs),
+			"heap_objects":      int64(memory.HeapObjects),
+			"memory": map[string]interface{}{
+				"heap_alloc":     int64(memory.HeapAlloc),
+				"heap_sys":       int64(memory.HeapSys),
+				"heap_objects":   int64(memory.HeapObjects),
+				"total_alloc":    int64(memory.TotalAlloc),
+				"sys":            int64(memory.Sys),
+				"mallocs":        int64(memory.Mallocs),
+				"frees":          int64(memory.Frees),
+				"num_gc":         int64(memory.NumGC),
+				"pause_total_ns": int64(memory.PauseTotalNs),
+			},
+		})
+	},
+}
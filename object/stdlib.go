@@ -0,0 +1,197 @@
+package object
+
+import "strings"
+
+// init registers the standard library modules that ship with the interpreter
+func init() {
+	for _, module := range []struct {
+		name    string
+		members map[string]*Builtin
+	}{
+		{"strings", stringsModule},
+		{"math", mathModule},
+	} {
+		if err := RegisterModule(module.name, module.members); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// stringsModule contains the built-in functions for working with strings
+var stringsModule = map[string]*Builtin{
+	"upper": stringFunction("strings.upper", strings.ToUpper),
+	"lower": stringFunction("strings.lower", strings.ToLower),
+	"trim":  stringFunction("strings.trim", strings.TrimSpace),
+	"split": {
+		Fn: func(args ...Object) Object {
+			values, errObj := stringArguments("strings.split", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			parts := strings.Split(values[0], values[1])
+			elements := make([]Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &String{Value: part}
+			}
+			return &Array{Elements: elements}
+		},
+	},
+	"join": {
+		Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return newError("first argument to `strings.join` must be ARRAY, got %s", args[0].Type())
+			}
+			separator, ok := args[1].(*String)
+			if !ok {
+				return newError("second argument to `strings.join` must be STRING, got %s", args[1].Type())
+			}
+
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				str, ok := el.(*String)
+				if !ok {
+					return newError("elements joined by `strings.join` must be STRING, got %s", el.Type())
+				}
+				parts[i] = str.Value
+			}
+			return &String{Value: strings.Join(parts, separator.Value)}
+		},
+	},
+	"contains": {
+		Fn: func(args ...Object) Object {
+			values, errObj := stringArguments("strings.contains", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			if strings.Contains(values[0], values[1]) {
+				return TRUE
+			}
+			return FALSE
+		},
+	},
+	"replace": {
+		Fn: func(args ...Object) Object {
+			values, errObj := stringArguments("strings.replace", 3, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			return &String{Value: strings.ReplaceAll(values[0], values[1], values[2])}
+		},
+	},
+}
+
+// mathModule contains the built-in functions for integer arithmetic
+var mathModule = map[string]*Builtin{
+	"abs": {
+		Fn: func(args ...Object) Object {
+			values, errObj := integerArguments("math.abs", 1, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			if values[0] < 0 {
+				return &Integer{Value: -values[0]}
+			}
+			return &Integer{Value: values[0]}
+		},
+	},
+	"min": {
+		Fn: func(args ...Object) Object {
+			values, errObj := integerArguments("math.min", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			if values[1] < values[0] {
+				return &Integer{Value: values[1]}
+			}
+			return &Integer{Value: values[0]}
+		},
+	},
+	"max": {
+		Fn: func(args ...Object) Object {
+			values, errObj := integerArguments("math.max", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			if values[1] > values[0] {
+				return &Integer{Value: values[1]}
+			}
+			return &Integer{Value: values[0]}
+		},
+	},
+	"pow": {
+		Fn: func(args ...Object) Object {
+			values, errObj := integerArguments("math.pow", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+
+			if values[1] < 0 {
+				return newError("exponent of `math.pow` must not be negative, got %d", values[1])
+			}
+
+			result := int64(1)
+			for i := int64(0); i < values[1]; i++ {
+				result *= values[0]
+			}
+			return &Integer{Value: result}
+		},
+	},
+}
+
+// stringFunction creates a built-in function that transforms its single string argument with fn
+func stringFunction(name string, fn func(string) string) *Builtin {
+	return &Builtin{
+		Fn: func(args ...Object) Object {
+			values, errObj := stringArguments(name, 1, args)
+			if errObj != nil {
+				return errObj
+			}
+			return &String{Value: fn(values[0])}
+		},
+	}
+}
+
+// stringArguments validates that exactly n string arguments were given and returns their values
+func stringArguments(name string, n int, args []Object) ([]string, Object) {
+	if len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+
+	values := make([]string, n)
+	for i, arg := range args {
+		str, ok := arg.(*String)
+		if !ok {
+			return nil, newError("arguments to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		values[i] = str.Value
+	}
+	return values, nil
+}
+
+// integerArguments validates that exactly n integer arguments were given and returns their values
+func integerArguments(name string, n int, args []Object) ([]int64, Object) {
+	if len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+
+	values := make([]int64, n)
+	for i, arg := range args {
+		integer, ok := arg.(*Integer)
+		if !ok {
+			return nil, newError("arguments to `%s` must be INTEGER, got %s", name, arg.Type())
+		}
+		values[i] = integer.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,41 @@
+package object
+
+import "sync"
+
+const SYMBOL_OBJ = "SYMBOL"
+
+// Symbol is an interned name, created by a `:name` literal. Every symbol with the same name is the
+// same instance, so symbols compare by identity in both engines and hash by a number assigned when
+// the name is first interned, without hashing the name again on every use as a hash key.
+type Symbol struct {
+	Name string
+	id   uint64
+}
+
+// symbols holds every interned symbol by name, shared by the evaluator, the VM and decoded bytecode
+var symbols = struct {
+	sync.Mutex
+	byName map[string]*Symbol
+}{byName: make(map[string]*Symbol)}
+
+// Intern returns the symbol with the given name, creating it the first time the name is interned
+func Intern(name string) *Symbol {
+	symbols.Lock()
+	defer symbols.Unlock()
+
+	if symbol, ok := symbols.byName[name]; ok {
+		return symbol
+	}
+	symbol := &Symbol{Name: name, id: uint64(len(symbols.byName))}
+	symbols.byName[name] = symbol
+	return symbol
+}
+
+// Type returns the ObjectType (SYMBOL_OBJ) associated with the referenced Symbol
+func (s *Symbol) Type() ObjectType { return SYMBOL_OBJ }
+
+// Inspect returns the symbol as a symbol literal
+func (s *Symbol) Inspect() string { return ":" + s.Name }
+
+// HashKey returns the number the symbol was interned with, symbols never collide with each other
+func (s *Symbol) HashKey() HashKey { return HashKey{Type: s.Type(), Value: s.id} }
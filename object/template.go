@@ -0,0 +1,78 @@
+package object
+
+import (
+	"strings"
+)
+
+// renderBuiltin substitutes every {{key}} placeholder in a template string with the value
+// of that key in the given hash. Dotted keys, {{user.name}}, look up nested hashes.
+// Strings are substituted as they are, any other value by its Inspect representation.
+var renderBuiltin = &Builtin{
+	Fn: func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+
+		template, ok := args[0].(*String)
+		if !ok {
+			return newError("first argument to `render` must be STRING, got %s", args[0].Type())
+		}
+
+		data, ok := args[1].(*Hash)
+		if !ok {
+			return newError("second argument to `render` must be HASH, got %s", args[1].Type())
+		}
+
+		var out strings.Builder
+		rest := template.Value
+		for {
+			start := strings.Index(rest, "{{")
+			if start == -1 {
+				out.WriteString(rest)
+				break
+			}
+
+			end := strings.Index(rest[start:], "}}")
+			if end == -1 {
+				return newError("unclosed placeholder in template: %s", rest[start:])
+			}
+
+			out.WriteString(rest[:start])
+			key := strings.TrimSpace(rest[start+2 : start+end])
+			value, ok := lookupTemplateKey(data, key)
+			if !ok {
+				return newError("undefined template key: %s", key)
+			}
+
+			if str, ok := value.(*String); ok {
+				out.WriteString(str.Value)
+			} else {
+				out.WriteString(value.Inspect())
+			}
+
+			rest = rest[start+end+2:]
+		}
+
+		return &String{Value: out.String()}
+	},
+}
+
+// lookupTemplateKey finds the value of a (dotted) template key in the hash,
+// descending into nested hashes for every part of the key.
+func lookupTemplateKey(hash *Hash, key string) (Object, bool) {
+	var value Object = hash
+	for _, part := range strings.Split(key, ".") {
+		current, ok := value.(*Hash)
+		if !ok {
+			return nil, false
+		}
+
+		pair, ok := current.Pairs[(&String{Value: part}).HashKey()]
+		if !ok {
+			return nil, false
+		}
+		value = pair.Value
+	}
+
+	return value, true
+}
@@ -0,0 +1,39 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TraceLimit is the number of most recent calls included in a call trace
+const TraceLimit = 5
+
+// AnonymousFunctionName is used in call traces for functions that are not bound to a name
+const AnonymousFunctionName = "<anonymous>"
+
+// RecursionDepthMessage describes a call that exceeded the maximum recursion depth.
+// The trace holds the names of the active calls from the outermost to the innermost call,
+// only the most recent TraceLimit calls are listed.
+func RecursionDepthMessage(maxDepth int, trace []string) string {
+	var out strings.Builder
+
+	name := AnonymousFunctionName
+	if len(trace) > 0 && trace[len(trace)-1] != "" {
+		name = trace[len(trace)-1]
+	}
+	fmt.Fprintf(&out, "maximum recursion depth (%d) exceeded in function %s", maxDepth, name)
+
+	out.WriteString("\ncall trace (most recent call last):")
+	if len(trace) > TraceLimit {
+		fmt.Fprintf(&out, "\n  ... %d earlier calls", len(trace)-TraceLimit)
+		trace = trace[len(trace)-TraceLimit:]
+	}
+	for _, name := range trace {
+		if name == "" {
+			name = AnonymousFunctionName
+		}
+		fmt.Fprintf(&out, "\n  %s", name)
+	}
+
+	return out.String()
+}
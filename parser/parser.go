@@ -3,8 +3,11 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/feature"
 	"github.com/yourfavoritedev/golang-interpreter/lexer"
 	"github.com/yourfavoritedev/golang-interpreter/token"
 )
@@ -14,27 +17,53 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = 5
+	CONDITIONAL // a ? b : c
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BITWISE_OR  // |
+	BITWISE_XOR // ^
+	BITWISE_AND // &
+	SHIFT       // << or >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
 	INDEX       // array[index]
+	POSTFIX     // X++ or X--
 )
 
 // a map of the token infix operators and their precedences
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:      ASSIGN,
+	token.QUESTION:    CONDITIONAL,
+	token.EQ:          EQUALS,
+	token.NOT_EQ:      EQUALS,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.AND:         AND,
+	token.OR:          OR,
+	token.LT_EQ:       LESSGREATER,
+	token.GT_EQ:       LESSGREATER,
+	token.PIPE:        BITWISE_OR,
+	token.CARET:       BITWISE_XOR,
+	token.AMPERSAND:   BITWISE_AND,
+	token.SHIFT_LEFT:  SHIFT,
+	token.SHIFT_RIGHT: SHIFT,
+	token.PLUS:        SUM,
+	token.MINUS:       SUM,
+	token.SLASH:       PRODUCT,
+	token.FLOOR_SLASH: PRODUCT,
+	token.ASTERISK:    PRODUCT,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
+	token.DOT:         INDEX,
+	// safe navigation binds like the index operator it guards
+	token.OPTIONAL_LBRACKET: INDEX,
+	token.INCREMENT:         POSTFIX,
+	token.DECREMENT:         POSTFIX,
 }
 
 // Parser constructs the abstract syntax-tree for a program by analyzing the tokens
@@ -50,6 +79,23 @@ type Parser struct {
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// doc holds the lines of the most recent doc comment, docStart its first line
+	// and docToken the token that follows it
+	doc      string
+	docStart token.Token
+	docToken token.Token
+
+	// diagnostics holds the errors (also found in errors) and warnings encountered during parsing
+	diagnostics diagnostic.Diagnostics
+
+	// features are the explicitly enabled language features, syntax of experimental features
+	// that are not enabled results in an error.
+	features feature.Set
+
+	// loops counts the loops around the statement being parsed within the innermost function,
+	// break and continue are errors outside of a loop
+	loops int
 }
 
 type (
@@ -78,18 +124,30 @@ func New(l *lexer.Lexer) *Parser {
 	// we can call its parsing function
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
 	// register infixParseFns as well
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.FLOOR_SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	// register boolean parsing functions
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
@@ -97,6 +155,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	// register ifExpression parsing function
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	// register while loop parsing function
+	p.registerPrefix(token.WHILE, p.parseWhileExpression)
+	// register switch expression parsing function
+	p.registerPrefix(token.SWITCH, p.parseSwitchExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
 	// register function-literal parsing function
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	// register infixParseFn to parse call-expressions
@@ -107,8 +170,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	// register index operator parsing function
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	// register assignment parsing function
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	// register safe navigation index parsing function, it builds the same node as a plain index operation
+	p.registerInfix(token.OPTIONAL_LBRACKET, p.parseIndexExpression)
+	// register postfix increment and decrement parsing functions, they build assignments
+	p.registerInfix(token.INCREMENT, p.parsePostfixExpression)
+	p.registerInfix(token.DECREMENT, p.parsePostfixExpression)
+	// register conditional expression parsing function
+	p.registerInfix(token.QUESTION, p.parseConditionalExpression)
+	// register member access parsing function
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 	// register hash literal parsing function
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	// register record literal parsing function
+	p.registerPrefix(token.RECORD, p.parseRecordLiteral)
+	// register symbol literal parsing function
+	p.registerPrefix(token.COLON, p.parseSymbolLiteral)
 
 	return p
 }
@@ -122,26 +200,70 @@ func (p *Parser) parseIdentifier() ast.Expression {
 // When called, the lexer will examine the next character, produce a new token
 // and advance its position.
 func (p *Parser) nextToken() {
+	// a doc comment that was not taken by the token following it does not document anything
+	if p.doc != "" && p.curToken == p.docToken {
+		p.unattachedDocWarning()
+	}
+
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	// doc comments are not part of the grammar, collect them for the token that follows them
+	if p.peekToken.Type == token.DOC {
+		p.docStart = p.peekToken
+		lines := []string{}
+		for p.peekToken.Type == token.DOC {
+			lines = append(lines, p.peekToken.Literal)
+			p.peekToken = p.l.NextToken()
+		}
+		p.doc = strings.Join(lines, "\n")
+		p.docToken = p.peekToken
+	}
+}
+
+// unattachedDocWarning warns about the current doc comment, which does not precede a definition, and discards it
+func (p *Parser) unattachedDocWarning() {
+	p.diagnostics = append(p.diagnostics, diagnostic.New(diagnostic.Warning, p.docStart, "W001",
+		"doc comment is not attached to a definition"))
+	p.doc = ""
+}
+
+// takeDoc returns the doc comment directly preceding the current token, or an empty string
+func (p *Parser) takeDoc() string {
+	if p.doc == "" || p.docToken != p.curToken {
+		return ""
+	}
+	doc := p.doc
+	p.doc = ""
+	return doc
 }
 
 // parseStatement checks the parser's current token type to determine what statement operation to run and return
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.IDENT:
+		// a name followed by a comma can only start an assignment to several names
+		if p.peekTokenIs(token.COMMA) {
+			return p.parseMultiAssignStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-// parseLetStatement constructs a Statement with the attributes of a LetStatement
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	// construct initial LetStatement node with the starting token (token.LET)
-	stmt := &ast.LetStatement{Token: p.curToken}
+// parseLetStatement constructs a Statement with the attributes of a LetStatement.
+// A comma after the name makes it a MultiLetStatement, see parseMultiLetStatement.
+// A const statement is parsed the same way, only its Const field is set.
+func (p *Parser) parseLetStatement() ast.Statement {
+	// construct initial LetStatement node with the starting token (token.LET or token.CONST)
+	stmt := &ast.LetStatement{Token: p.curToken, Doc: p.takeDoc(), Const: p.curTokenIs(token.CONST)}
 	// should expect next token type to be token.IDENT `x in let x = 5`
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -149,6 +271,20 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	// construct the Identifier node with the attributes of the initial token.LET
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	// the name may be followed by a type annotation `let x: int = 5`
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if stmt.Name.Type = p.parseTypeAnnotation(); stmt.Name.Type == nil {
+			return nil
+		}
+	}
+	if p.peekTokenIs(token.COMMA) {
+		// a doc comment documents a single definition
+		if stmt.Doc != "" {
+			p.unattachedDocWarning()
+		}
+		return p.parseMultiLetStatement(stmt.Token, stmt.Name)
+	}
 
 	// should expect LetStatement to use an assignment `=`
 	if !p.expectPeek(token.ASSIGN) {
@@ -165,6 +301,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	// using the LetStatement's Name
 	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
 		fl.Name = stmt.Name.Value
+		fl.Doc = stmt.Doc
 	}
 
 	// advance tokens if peekToken is a semicolon.
@@ -177,6 +314,89 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parseMultiLetStatement parses the rest of a let statement binding several names, `let a, b = 1, 2;`,
+// after its first name. It reports an error when the number of names and values differ.
+func (p *Parser) parseMultiLetStatement(tok token.Token, first *ast.Identifier) ast.Statement {
+	stmt := &ast.MultiLetStatement{Token: tok, Names: []*ast.Identifier{first}, Const: tok.Type == token.CONST}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			if name.Type = p.parseTypeAnnotation(); name.Type == nil {
+				return nil
+			}
+		}
+		stmt.Names = append(stmt.Names, name)
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Values = []ast.Expression{p.parseExpression(LOWEST)}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Values = append(stmt.Values, p.parseExpression(LOWEST))
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if len(stmt.Names) != len(stmt.Values) {
+		p.addError(tok, "E007", fmt.Sprintf("%s binds %d names to %d values", tok.Literal, len(stmt.Names), len(stmt.Values)))
+		return nil
+	}
+	for i, value := range stmt.Values {
+		if fl, ok := value.(*ast.FunctionLiteral); ok {
+			fl.Name = stmt.Names[i].Value
+		}
+	}
+
+	return stmt
+}
+
+// parseMultiAssignStatement parses an assignment to several names, `a, b = b, a;`, from its first name.
+// Like a let statement binding several names, it reports an error when the number of names and values differ.
+func (p *Parser) parseMultiAssignStatement() ast.Statement {
+	stmt := &ast.MultiAssignStatement{Names: []*ast.Identifier{{Token: p.curToken, Value: p.curToken.Literal}}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+	stmt.Token = p.curToken
+	p.nextToken()
+	stmt.Values = []ast.Expression{p.parseExpression(LOWEST)}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Values = append(stmt.Values, p.parseExpression(LOWEST))
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if len(stmt.Names) != len(stmt.Values) {
+		p.addError(stmt.Token, "E007", fmt.Sprintf("assignment binds %d names to %d values", len(stmt.Names), len(stmt.Values)))
+		return nil
+	}
+
+	return stmt
+}
+
 // parseReturnStatement constructs a Statement with the attributes of a ReturnStatement
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	// construct initial returnStatement node with the starting token (token.RETURn)
@@ -197,6 +417,31 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// parseLoopControlStatement constructs a BreakStatement or a ContinueStatement, which must be inside
+// the body of a loop. A function body starts outside of any loop, even when the function is defined in one.
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if p.loops == 0 {
+		p.addError(tok, "E011", fmt.Sprintf("%s outside of a loop", tok.Literal))
+		return nil
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
+// parseLoopBody constructs the body of a loop, where break and continue can be used
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+	return p.parseBlockStatement()
+}
+
 // curTokenIs verifies whether t and the parser's current token type are the same
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
@@ -246,7 +491,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 // that does not have a prefix parse function
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, "E001", msg)
 }
 
 // parseExpression checks whether a parsing function is
@@ -291,11 +536,45 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Diagnostics returns the errors and warnings in the parser, in the order they were encountered
+func (p *Parser) Diagnostics() diagnostic.Diagnostics {
+	return p.diagnostics
+}
+
+// SetFeatures enables the given experimental language features for the rest of the parsing
+func (p *Parser) SetFeatures(features feature.Set) {
+	p.features = features
+}
+
+// requireFeature is called by the parsing functions of gated syntax at the current token.
+// It reports an error when the feature is experimental and not enabled, and warns
+// when the feature is deprecated. It returns whether the syntax may be used.
+func (p *Parser) requireFeature(name string) bool {
+	f, ok := feature.Lookup(name)
+	if !ok || !p.features.Enabled(name) {
+		p.addError(p.curToken, "E004", fmt.Sprintf("%s is an experimental feature, enable it with --feature=%s",
+			p.curToken.Literal, name))
+		return false
+	}
+
+	if f.Status == feature.Deprecated {
+		p.diagnostics = append(p.diagnostics, diagnostic.New(diagnostic.Warning, p.curToken, "W002",
+			"%s is deprecated: %s", p.curToken.Literal, f.Description))
+	}
+	return true
+}
+
+// addError records an error about the given token in the parser's errors and diagnostics
+func (p *Parser) addError(tok token.Token, code, msg string) {
+	p.errors = append(p.errors, msg)
+	p.diagnostics = append(p.diagnostics, diagnostic.New(diagnostic.Error, tok, code, "%s", msg))
+}
+
 // peekError adds an error message (string) to the parser's errors ([]string)
 // when the peekToken does not match the expected token.
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, "E002", msg)
 }
 
 // ParseProgram constructs the root node of a AST an *ast.Program.
@@ -313,6 +592,11 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	// a doc comment at the end of the program does not precede any definition
+	if p.doc != "" {
+		p.unattachedDocWarning()
+	}
+
 	return program
 }
 
@@ -334,7 +618,22 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q a integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, "E003", msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+// parseFloatLiteral constructs an ast.FloatLiteral from the current FLOAT token
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken, "E003", msg)
 		return nil
 	}
 
@@ -403,6 +702,76 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression constructs an AssignExpression from `name = value`. The value is parsed with
+// the lowest precedence, so assignments are right-associative: `a = b = 1` assigns 1 to b, then to a.
+// Only names can be assigned to, anything else on the left is reported and the value is still parsed.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: p.curToken}
+
+	// advance token past "=" to the value
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	// the value is parsed first, so a bad target is reported once and the value is not parsed as a new statement
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		p.addError(expression.Token, "E008", fmt.Sprintf("cannot assign to %s", left.String()))
+		return nil
+	}
+	expression.Name = name
+
+	return expression
+}
+
+// parsePostfixExpression desugars `i++` and `i--` to the assignments `i = i + 1` and `i = i - 1`, the left
+// expression is the name. Like any assignment the expression has the new value of the name, and the name
+// must already be bound, so the engines, the type checker and the transpiler need nothing of their own.
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	operator := tok.Literal[:1]
+
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		p.addError(tok, "E008", fmt.Sprintf("cannot assign to %s", left.String()))
+		return nil
+	}
+
+	// the operator and the one share the position of the ++ or --, errors of the addition point there
+	opToken := token.Token{Type: token.TokenType(operator), Literal: operator, Line: tok.Line, Column: tok.Column}
+	oneToken := token.Token{Type: token.INT, Literal: "1", Line: tok.Line, Column: tok.Column}
+	return &ast.AssignExpression{
+		Token: token.Token{Type: token.ASSIGN, Literal: "=", Line: tok.Line, Column: tok.Column},
+		Name:  name,
+		Value: &ast.InfixExpression{
+			Token:    opToken,
+			Left:     &ast.Identifier{Token: name.Token, Value: name.Value},
+			Operator: operator,
+			Right:    &ast.IntegerLiteral{Token: oneToken, Value: 1},
+		},
+	}
+}
+
+// parseConditionalExpression constructs a ConditionalExpression from `cond ? a : b`, the condition is the left
+// expression. Like the brackets of an index, the "?" and ":" delimit the consequence, which can be any expression.
+// The alternative is parsed with the precedence of an assignment, so conditionals are right-associative,
+// `a ? b : c ? d : e` is `a ? b : (c ? d : e)`, and bind tighter than an assignment, `x = a ? b : c`.
+func (p *Parser) parseConditionalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.ConditionalExpression{Token: p.curToken, Condition: left}
+
+	// advance past "?" to the consequence
+	p.nextToken()
+	expression.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+	// advance past ":" to the alternative
+	p.nextToken()
+	expression.Alternative = p.parseExpression(ASSIGN)
+
+	return expression
+}
+
 // parseBoolean uses the parser's current token to construct a Boolean expression
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
@@ -474,6 +843,122 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseWhileExpression constructs a WhileExpression from `while (condition) { body }`
+func (p *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseLoopBody()
+
+	return expression
+}
+
+// parseSwitchExpression constructs a SwitchExpression from
+// `switch (subject) { case 1, 2 { ... } case "a" { ... } default { ... } }`.
+// A case lists one or more values separated by commas, and a switch has at most one default.
+func (p *Parser) parseSwitchExpression() ast.Expression {
+	expression := &ast.SwitchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// advance past "{" to the first case, the default or the closing "}"
+	p.nextToken()
+	for !p.curTokenIs(token.RBRACE) {
+		switch p.curToken.Type {
+		case token.CASE:
+			c := &ast.SwitchCase{Token: p.curToken}
+			p.nextToken()
+			c.Values = append(c.Values, p.parseExpression(LOWEST))
+			for p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+				p.nextToken()
+				c.Values = append(c.Values, p.parseExpression(LOWEST))
+			}
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			c.Body = p.parseBlockStatement()
+			expression.Cases = append(expression.Cases, c)
+		case token.DEFAULT:
+			if expression.Default != nil {
+				p.addError(p.curToken, "E010", "duplicate default in switch expression")
+				return nil
+			}
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			expression.Default = p.parseBlockStatement()
+		default:
+			p.addError(p.curToken, "E009", fmt.Sprintf("expected case, default or } in switch expression, got %s instead",
+				p.curToken.Type))
+			return nil
+		}
+		// advance past the "}" of the body
+		p.nextToken()
+	}
+
+	return expression
+}
+
+// parseForExpression constructs a ForExpression from `for (value in iterable) { body }`
+// or `for (key, value in iterable) { body }`
+func (p *Parser) parseForExpression() ast.Expression {
+	expression := &ast.ForExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.Key = expression.Value
+		expression.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseLoopBody()
+
+	return expression
+}
+
 // parseBlockStatement constructs a BlockStatement. It calls parseStatement
 // until it encounters either a }, which signifies the end of the block statement
 // or a token.EOF, which tells us there are no more tokens left to parse
@@ -508,67 +993,179 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	// parse function parameters, should leave current token as ")"
-	lit.Parameters = p.parseFunctionParameters()
+	// parse function parameters and their default values, should leave current token as ")"
+	lit.Parameters, lit.Defaults, lit.Variadic = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
 
-	// current token should be ")", verify next token is "{"
+	// the parameters may be followed by the type annotation of the returned value `fn() -> int`
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		if lit.ReturnType = p.parseTypeAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
+
+	// current token should be ")" or the return type, verify next token is "{"
 	// then advane to that token
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
 	// construct Block Statement of function-literal
+	// the body of a function is not part of the loop the function is defined in
+	loops := p.loops
+	p.loops = 0
 	lit.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return lit
 }
 
 // parseFunctionParameters constructs the function-literal's
-// parameters as identifiers
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parameters as identifiers, and the default values of the parameters following `=`.
+// Only the last parameters can have a default value, so the defaults belong to the last len(defaults) parameters
+// before the variadic one. variadic reports whether the last parameter, followed by "...", collects the remaining arguments.
+func (p *Parser) parseFunctionParameters() (identifiers []*ast.Identifier, defaults []ast.Expression, variadic bool) {
+	identifiers = []*ast.Identifier{}
+	misplaced := false
 
 	// early exit if the next token is ")",
 	// advance to ")" to move past parameters
 	// this would mean the function has no parameters, fn()
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, defaults, false
 	}
 
-	// advance past current token "("
-	p.nextToken()
+	for {
+		// advance past current token "(" or ","
+		p.nextToken()
 
-	// construct first parameter as identifier
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		// construct the parameter as identifier
+		ident, rest := p.parseFunctionParameter()
+		if ident == nil {
+			return nil, nil, false
+		}
+		identifiers = append(identifiers, ident)
+		// only the last parameter can be variadic, the others are reported below
+		variadic = rest
+
+		if p.peekTokenIs(token.ASSIGN) {
+			if rest {
+				p.addError(ident.Token, "E014", fmt.Sprintf("variadic parameter %s cannot have a default value", ident.Value))
+				misplaced = true
+			}
+			p.nextToken()
+			p.nextToken()
+			value := p.parseExpression(LOWEST)
+			if value == nil {
+				return nil, nil, false
+			}
+			defaults = append(defaults, value)
+		} else if len(defaults) != 0 && !rest {
+			// the rest of the function is still parsed, without the default values, so the error does not cascade
+			p.addError(ident.Token, "E012", fmt.Sprintf("parameter %s without a default value follows a parameter with one", ident.Value))
+			misplaced = true
+		}
 
-	// keep building identifiers if the next token is a ","
-	for p.peekTokenIs(token.COMMA) {
+		// keep building identifiers if the next token is a ","
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		if rest {
+			// the following parameters are still parsed, so the error does not cascade
+			p.addError(ident.Token, "E013", fmt.Sprintf("variadic parameter %s must be the last parameter", ident.Value))
+			misplaced = true
+		}
 		// advance current token to ","
 		p.nextToken()
-		// advance current token to next parameter
-		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
 	}
 
 	// after parsing all parameters, the next token should be ")",
 	// advance to that next token. otherwise, we've encountered an error
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil, false
+	}
+	if misplaced {
+		return identifiers, nil, false
+	}
+
+	return identifiers, defaults, variadic
+}
+
+// parseFunctionParameter constructs the parameter at the current token as an identifier,
+// along with its type annotation when it is followed by one `a: int`.
+// variadic reports whether the name is followed by "...", the annotation of such a parameter types every argument it collects `rest...: int`.
+func (p *Parser) parseFunctionParameter() (ident *ast.Identifier, variadic bool) {
+	ident = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.ELLIPSIS) {
+		p.nextToken()
+		variadic = true
 	}
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if ident.Type = p.parseTypeAnnotation(); ident.Type == nil {
+			return nil, false
+		}
+	}
+	return ident, variadic
+}
 
-	return identifiers
+// parseTypeAnnotation constructs the type annotation following the current ":" or "->" token.
+// Type names are identifiers, except fn which is a keyword. Whether the name is a known type
+// is left to the typechecker, so annotations never prevent a program from parsing.
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	if p.peekTokenIs(token.FUNCTION) {
+		p.nextToken()
+		return &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	return &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
 }
 
 // parseCallExpression constructs a CallExpression, it expects
 // the current token to be "(" amd expects function to be passed as an argument
 // (can be Identifier or function-literal)
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	// construct argument expressions
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp := &ast.CallExpression{Token: p.curToken, Function: function, Arguments: []ast.Expression{}}
+	// early exit if the call has no arguments
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return exp
+	}
+
+	for {
+		// advance past "(" or the "," before the argument
+		p.nextToken()
+		// a name followed by a colon starts a named argument, f(x: 1), they must follow the positional arguments
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			for _, other := range exp.Names {
+				if other.Value == name.Value {
+					p.addError(name.Token, "E016", fmt.Sprintf("argument %s is given twice", name.Value))
+				}
+			}
+			exp.Names = append(exp.Names, name)
+			p.nextToken()
+			p.nextToken()
+		} else if len(exp.Names) > 0 {
+			p.addError(p.curToken, "E015", "positional argument follows a named argument")
+		}
+		exp.Arguments = append(exp.Arguments, p.parseExpression(LOWEST))
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	// after parsing all arguments, the next token should be the closing ")"
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
 	return exp
 }
 
@@ -578,6 +1175,19 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseSymbolLiteral constructs an ast.SymbolLiteral from a COLON token followed by an identifier.
+// The name must follow the colon without any space, so `{a: b}` stays a hash literal.
+// A colon only reaches this prefix position where no hash literal, record literal or annotation expects it.
+func (p *Parser) parseSymbolLiteral() ast.Expression {
+	colon := p.curToken
+	if !p.symbolFollows() {
+		p.addError(colon, "E006", "expected a name right after : in symbol literal")
+		return nil
+	}
+	p.nextToken()
+	return &ast.SymbolLiteral{Token: colon, Value: p.curToken.Literal}
+}
+
 // parseArrayLiteral will construct an ast.Arrayliteral node using the current token.
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
@@ -588,7 +1198,8 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 }
 
 // parseExpressionList parses the elements of a comma separated list, returning a list of expressions.
-// parseExpressionList is used for both parsing array literal elements and parsing call expression arguments.
+// parseExpressionList is used for parsing array literal elements, parseCallExpression parses the arguments of calls,
+// which may be named.
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -628,11 +1239,21 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 // The ast.IndexExpression implements the Expression interface.
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	// a "?[" token makes the index operation safe to apply to null
+	exp.Optional = p.curTokenIs(token.OPTIONAL_LBRACKET)
 
 	// advance past "[" token for index operator
 	p.nextToken()
+	// a colon right after "[" starts a slice without a low bound, unless it is the colon of a symbol, xs[:name] indexes with :name
+	if p.curTokenIs(token.COLON) && !p.symbolFollows() {
+		return p.parseSliceExpression(exp, nil)
+	}
 	// parse the index used to surface the array literal
 	exp.Index = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(exp, exp.Index)
+	}
 
 	// after successful parsing, the next token should be closing "]" of the index operation,
 	// advance to that next token, otherwise, we've encountered an error
@@ -643,6 +1264,41 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseSliceExpression will construct an ast.SliceExpression node for the index expression whose
+// low bound was parsed, the current token is the colon followed by the optional high bound.
+func (p *Parser) parseSliceExpression(index *ast.IndexExpression, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: index.Token, Left: index.Left, Low: low, Optional: index.Optional}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+// symbolFollows reports whether the current colon token starts a symbol literal, a name right after the colon
+func (p *Parser) symbolFollows() bool {
+	return p.peekTokenIs(token.IDENT) && p.peekToken.Line == p.curToken.Line && p.peekToken.Column == p.curToken.Column+1
+}
+
+// parseMemberExpression will construct an ast.MemberExpression node using the current token, the ".".
+// The object of the member access is the left expression and the property must be an identifier.
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	// the property after the "." must be an identifier
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 // parseHashLiteral will construct an ast.HashLiteral node using the current token.
 // The ast.HashLiteral implements the Expression interface.
 func (p *Parser) parseHashLiteral() ast.Expression {
@@ -690,3 +1346,41 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 
 	return hash
 }
+
+// parseRecordLiteral constructs a RecordLiteral, the current token is "record".
+// Its fields are written like the pairs of a hash literal, but every key must be a distinct name.
+func (p *Parser) parseRecordLiteral() ast.Expression {
+	record := &ast.RecordLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			p.addError(p.curToken, "E005", fmt.Sprintf("duplicate field %s in record literal", field.Value))
+			return nil
+		}
+		seen[field.Value] = true
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		record.Fields = append(record.Fields, field)
+		record.Values = append(record.Values, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return record
+}
@@ -5,7 +5,10 @@ import (
 	"testing"
 
 	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/feature"
 	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -42,6 +45,269 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		constant bool
+	}{
+		{"const x = 5;", "const x = 5;", true},
+		{"const a, b = 1, 2;", "const a, b = 1, 2;", true},
+		{"let y = 1;", "let y = 1;", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expected, program.String())
+		}
+		switch stmt := program.Statements[0].(type) {
+		case *ast.LetStatement:
+			if stmt.Const != tt.constant {
+				t.Errorf("wrong Const for %q. want=%t", tt.input, tt.constant)
+			}
+		case *ast.MultiLetStatement:
+			if stmt.Const != tt.constant {
+				t.Errorf("wrong Const for %q. want=%t", tt.input, tt.constant)
+			}
+		}
+	}
+
+	p := New(lexer.New(`const a, b = 1;`))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "const binds 2 names to 1 values" {
+		t.Errorf("wrong errors for a missing value. got=%v", p.Errors())
+	}
+}
+
+func TestWhileExpression(t *testing.T) {
+	p := New(lexer.New(`while (x < y) { x }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.WhileExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d", len(exp.Body.Statements))
+	}
+	testIdentifier(t, exp.Body.Statements[0].(*ast.ExpressionStatement).Expression, "x")
+}
+
+func TestForExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		key      string
+		value    string
+		expected string
+	}{
+		{`for (x in list) { x }`, "", "x", "for (x in list) x"},
+		{`for (k, v in h) { v }`, "k", "v", "for (k, v in h) v"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ForExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.ForExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+		}
+		if (exp.Key == nil && tt.key != "") || (exp.Key != nil && exp.Key.Value != tt.key) {
+			t.Errorf("wrong key. want=%q, got=%v", tt.key, exp.Key)
+		}
+		testIdentifier(t, exp.Value, tt.value)
+		if exp.String() != tt.expected {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expected, exp.String())
+		}
+	}
+
+	p := New(lexer.New(`for (x, in list) { x }`))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected parser errors for a missing name")
+	}
+}
+
+func TestSwitchExpression(t *testing.T) {
+	p := New(lexer.New(`switch (x) { case 1, y + 1 { a } case "b" { } default { c } }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SwitchExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.SwitchExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	testIdentifier(t, exp.Subject, "x")
+	if len(exp.Cases) != 2 {
+		t.Fatalf("switch does not have 2 cases. got=%d", len(exp.Cases))
+	}
+	if len(exp.Cases[0].Values) != 2 || len(exp.Cases[1].Values) != 1 {
+		t.Fatalf("wrong number of case values. got=%d, %d", len(exp.Cases[0].Values), len(exp.Cases[1].Values))
+	}
+	testIntegerLiteral(t, exp.Cases[0].Values[0], 1)
+	testInfixExpression(t, exp.Cases[0].Values[1], "y", "+", 1)
+	if exp.Default == nil {
+		t.Fatalf("switch has no default")
+	}
+	expected := `switch (x) { case 1, (y + 1) a case b  default c }`
+	if exp.String() != expected {
+		t.Errorf("wrong string. want=%q, got=%q", expected, exp.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`switch (x) { x }`, "expected case, default or } in switch expression, got IDENT instead"},
+		{`switch (x) { default { 1 } default { 2 } }`, "duplicate default in switch expression"},
+		{`switch (x) { case 1 { 1 }`, "expected case, default or } in switch expression, got EOF instead"},
+		{`switch (x) { case 1 2 }`, "expected next token to be {, got INT instead"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %s. want first=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestLoopControlStatements(t *testing.T) {
+	p := New(lexer.New(`while (x) { if (y) { break; } continue }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "whilex ify break;continue;"
+	if program.String() != expected {
+		t.Errorf("wrong string. want=%q, got=%q", expected, program.String())
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`break;`, "break outside of a loop"},
+		{`if (x) { continue }`, "continue outside of a loop"},
+		// a function in the body of a loop starts outside of any loop
+		{`for (x in y) { fn() { break; } }`, "break outside of a loop"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong errors for %s. want first=%q, got=%v", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "x = 5"},
+		{"x = x + 1 * 2", "x = (x + (1 * 2))"},
+		// assignments are right-associative, the value of an assignment is the assigned value
+		{"x = y = 1", "x = y = 1"},
+		{"f(x = 1)", "f(x = 1)"},
+		{"x = a || b", "x = (a || b)"},
+		// postfix increments and decrements are assignments, they bind tighter than prefix operators
+		{"i++", "i = (i + 1)"},
+		{"-i--", "(-i = (i - 1))"},
+		{"a[i++]", "(a[i = (i + 1)])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong string for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	exp, ok := New(lexer.New("x = y = 1")).ParseProgram().Statements[0].(*ast.ExpressionStatement).Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("exp is not ast.AssignExpression")
+	}
+	testIdentifier(t, exp.Name, "x")
+	if inner, ok := exp.Value.(*ast.AssignExpression); !ok || inner.Name.Value != "y" {
+		t.Errorf("value is not an assignment to y. got=%s", exp.Value)
+	}
+
+	p := New(lexer.New("1 = 2"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "cannot assign to 1" {
+		t.Errorf("wrong errors for an assignment to a literal. got=%v", p.Errors())
+	}
+
+	p = New(lexer.New("a[0]++"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "cannot assign to (a[0])" {
+		t.Errorf("wrong errors for an increment of an index. got=%v", p.Errors())
+	}
+}
+
+func TestMultiLetStatements(t *testing.T) {
+	p := New(lexer.New(`let a, b: int = 1, x + 2;`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.MultiLetStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.MultiLetStatement. got=%T", program.Statements[0])
+	}
+	if len(stmt.Names) != 2 || stmt.Names[0].Value != "a" || stmt.Names[1].Value != "b" || stmt.Names[1].Type.Name != "int" {
+		t.Fatalf("wrong names. got=%v", stmt.Names)
+	}
+	testIntegerLiteral(t, stmt.Values[0], 1)
+	testInfixExpression(t, stmt.Values[1], "x", "+", 2)
+	if stmt.String() != "let a, b: int = 1, (x + 2);" {
+		t.Errorf("wrong statement. got=%q", stmt.String())
+	}
+
+	p = New(lexer.New(`let a, b = 1;`))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "let binds 2 names to 1 values" {
+		t.Errorf("wrong errors for a missing value. got=%v", p.Errors())
+	}
+}
+
+func TestMultiAssignStatements(t *testing.T) {
+	p := New(lexer.New(`a, b = b, a + 1;`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.MultiAssignStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.MultiAssignStatement. got=%T", program.Statements[0])
+	}
+	if len(stmt.Names) != 2 || stmt.Names[0].Value != "a" || stmt.Names[1].Value != "b" {
+		t.Fatalf("wrong names. got=%v", stmt.Names)
+	}
+	testIdentifier(t, stmt.Values[0], "b")
+	testInfixExpression(t, stmt.Values[1], "a", "+", 1)
+	if stmt.String() != "a, b = b, (a + 1);" {
+		t.Errorf("wrong statement. got=%q", stmt.String())
+	}
+
+	p = New(lexer.New(`a, b = 1, 2, 3;`))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "assignment binds 2 names to 3 values" {
+		t.Errorf("wrong errors for an extra value. got=%v", p.Errors())
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -178,6 +444,30 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	p := New(lexer.New("2.75;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != 2.75 {
+		t.Errorf("literal.Value not %g. got=%g", 2.75, literal.Value)
+	}
+
+	if literal.TokenLiteral() != "2.75" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "2.75", literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -186,6 +476,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5", "!", 5},
 		{"-15", "-", 15},
+		{"~15", "~", 15},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
 	}
@@ -262,6 +553,7 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5", 5, "-", 5},
 		{"5 * 5", 5, "*", 5},
 		{"5 / 5", 5, "/", 5},
+		{"5 // 5", 5, "//", 5},
 		{"5 > 5", 5, ">", 5},
 		{"5 < 5", 5, "<", 5},
 		{"5 == 5", 5, "==", 5},
@@ -383,6 +675,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a + b / c",
 			"(a + (b / c))",
 		},
+		{
+			"a + b // c * d",
+			"(a + ((b // c) * d))",
+		},
 		{
 			"a + b * c + d / e - f",
 			"(((a + (b * c)) + (d / e)) - f)",
@@ -399,6 +695,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"5 < 4 != 3 > 4",
 			"((5 < 4) != (3 > 4))",
 		},
+		{
+			"a + 1 <= b == c >= d * 2",
+			"(((a + 1) <= b) == (c >= (d * 2)))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || !c",
+			"((a && b) || (!c))",
+		},
+		{
+			"a & 1 == 0",
+			"((a & 1) == 0)",
+		},
+		{
+			"a | b ^ c & d << 1 + 1",
+			"(a | (b ^ (c & (d << (1 + 1)))))",
+		},
+		{
+			"~a >> 2 & b < c",
+			"((((~a) >> 2) & b) < c)",
+		},
 		{
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
@@ -459,6 +779,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a?[1] * b?[c][2]",
+			"((a?[1]) * ((b?[c])[2]))",
+		},
+		{
+			"a * strings.upper(b).c",
+			"(a * ((strings.upper)(b).c))",
+		},
+		{
+			"a || b ? c + 1 : d",
+			"((a || b) ? (c + 1) : d)",
+		},
+		{
+			"a ? b : c ? d : e",
+			"(a ? b : (c ? d : e))",
+		},
+		{
+			"x = a ? b ? 1 : 2 : 3",
+			"x = (a ? (b ? 1 : 2) : 3)",
+		},
+		{
+			"xs[a ? 0 : 1] ? [1] : :none",
+			"((xs[(a ? 0 : 1)]) ? [1] : :none)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -767,6 +1111,233 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultParameterParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedRequired int
+		expectedString   string
+	}{
+		{"fn(x, y = 10) { x + y };", 1, "fn(x, y = 10) (x + y)"},
+		{"fn(x = 1, y = x * 2) { y };", 0, "fn(x = 1, y = (x * 2)) y"},
+		{"fn(x, y: int = 1) { y };", 1, "fn(x, y: int = 1) y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if function.Required() != tt.expectedRequired {
+			t.Errorf("wrong number of required parameters. want=%d, got=%d", tt.expectedRequired, function.Required())
+		}
+		if function.Default(0) != nil && tt.expectedRequired > 0 {
+			t.Errorf("expected no default value for the first parameter, got=%s", function.Default(0))
+		}
+		if function.String() != tt.expectedString {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expectedString, function.String())
+		}
+	}
+
+	l := lexer.New("fn(x = 1, y) { y };")
+	p := New(l)
+	p.ParseProgram()
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].Code != "E012" {
+		t.Fatalf("expected a single E012 diagnostic, got=%v", diagnostics)
+	}
+	expected := "parameter y without a default value follows a parameter with one"
+	if diagnostics[0].Message != expected {
+		t.Errorf("wrong message. want=%q, got=%q", expected, diagnostics[0].Message)
+	}
+}
+
+func TestVariadicParameterParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedRequired int
+		expectedString   string
+	}{
+		{"fn(rest...) { rest };", 0, "fn(rest...) rest"},
+		{"fn(x, y = 1, rest...) { rest };", 1, "fn(x, y = 1, rest...) rest"},
+		{"fn(x, rest...: int) { rest };", 1, "fn(x, rest...: int) rest"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if !function.Variadic {
+			t.Errorf("expected a variadic function: %s", tt.input)
+		}
+		if function.Required() != tt.expectedRequired {
+			t.Errorf("wrong number of required parameters. want=%d, got=%d", tt.expectedRequired, function.Required())
+		}
+		if function.Default(function.Fixed()) != nil {
+			t.Errorf("expected no default value for the variadic parameter, got=%s", function.Default(function.Fixed()))
+		}
+		if function.String() != tt.expectedString {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expectedString, function.String())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		code    string
+		message string
+	}{
+		{"fn(rest..., x) { x };", "E013", "variadic parameter rest must be the last parameter"},
+		{"fn(x, rest... = 1) { x };", "E014", "variadic parameter rest cannot have a default value"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 || diagnostics[0].Code != tt.code {
+			t.Fatalf("expected a single %s diagnostic, got=%v", tt.code, diagnostics)
+		}
+		if diagnostics[0].Message != tt.message {
+			t.Errorf("wrong message. want=%q, got=%q", tt.message, diagnostics[0].Message)
+		}
+	}
+}
+
+func TestNamedArgumentParsing(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedPositional int
+		expectedString     string
+	}{
+		{"makePoint(x: 1, y: 2)", 0, "makePoint(x: 1, y: 2)"},
+		{"f(1, y: a + 2)", 1, "f(1, y: (a + 2))"},
+		{"f(a, b)", 2, "f(a, b)"},
+		{"f(x: {a: 1})", 0, "f(x: {a:1})"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		call := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+		if call.Positional() != tt.expectedPositional {
+			t.Errorf("wrong number of positional arguments. want=%d, got=%d", tt.expectedPositional, call.Positional())
+		}
+		if call.String() != tt.expectedString {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expectedString, call.String())
+		}
+	}
+
+	errors := []struct {
+		input   string
+		code    string
+		message string
+	}{
+		{"f(x: 1, 2)", "E015", "positional argument follows a named argument"},
+		{"f(x: 1, x: 2)", "E016", "argument x is given twice"},
+	}
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 || diagnostics[0].Code != tt.code {
+			t.Fatalf("expected a single %s diagnostic, got=%v", tt.code, diagnostics)
+		}
+		if diagnostics[0].Message != tt.message {
+			t.Errorf("wrong message. want=%q, got=%q", tt.message, diagnostics[0].Message)
+		}
+	}
+}
+
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let add = fn(a: int, b) -> int { a + b };", "let add = fnadd(a: int, b) -> int (a + b);"},
+		{"let apply = fn(f: fn, x) { f(x) };", "let apply = fnapply(f: fn, x) f(x);"},
+		{"fn() -> string { \"a\" }", "fn() -> string a"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("let x: 5 = 5;"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "expected next token to be IDENT, got INT instead" {
+		t.Errorf("wrong errors for an invalid annotation. got=%v", p.Errors())
+	}
+}
+
+func TestRecordLiteralParsing(t *testing.T) {
+	p := New(lexer.New(`record{x: 1, y: 2 + 3}; record{}`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	record, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.RecordLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.RecordLiteral. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if len(record.Fields) != 2 || record.Fields[0].Value != "x" || record.Fields[1].Value != "y" {
+		t.Fatalf("wrong fields. got=%v", record.Fields)
+	}
+	testIntegerLiteral(t, record.Values[0], 1)
+	testInfixExpression(t, record.Values[1], 2, "+", 3)
+	if program.String() != "record{x: 1, y: (2 + 3)}record{}" {
+		t.Errorf("wrong program. got=%q", program.String())
+	}
+
+	p = New(lexer.New(`record{x: 1, x: 2}`))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "duplicate field x in record literal" {
+		t.Errorf("wrong errors for a duplicate field. got=%v", p.Errors())
+	}
+}
+
+func TestSymbolLiteralParsing(t *testing.T) {
+	p := New(lexer.New(`:red; {:a: 1, "b":c}`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	symbol, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SymbolLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.SymbolLiteral. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if symbol.Value != "red" {
+		t.Errorf("symbol.Value not %q. got=%q", "red", symbol.Value)
+	}
+
+	hash, ok := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	if !ok || len(hash.Pairs) != 2 {
+		t.Fatalf("exp is not a hash literal with 2 pairs. got=%s", program.Statements[1])
+	}
+	for key, value := range hash.Pairs {
+		if _, ok := key.(*ast.SymbolLiteral); ok {
+			testIntegerLiteral(t, value, 1)
+		} else {
+			testIdentifier(t, value, "c")
+		}
+	}
+
+	p = New(lexer.New(`: red`))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "expected a name right after : in symbol literal" {
+		t.Errorf("wrong errors for a symbol without name. got=%v", p.Errors())
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -867,6 +1438,53 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1:3]", "(xs[1:3])"},
+		{"xs[:2]", "(xs[:2])"},
+		{"xs[2:]", "(xs[2:])"},
+		{"xs[:]", "(xs[:])"},
+		{"xs[i + 1:len(xs) - 1]", "(xs[(i + 1):(len(xs) - 1)])"},
+		{"xs?[1:]", "(xs?[1:])"},
+		{"xs[1:n]", "(xs[1:n])"},
+		// a name right after the colon is a symbol, which indexes a hash
+		{"h[:red]", "(h[:red])"},
+		{"xs[: n]", "(xs[:n])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	p := New(lexer.New("xs[1:3]"))
+	stmt := p.ParseProgram().Statements[0].(*ast.ExpressionStatement)
+	slice, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, slice.Left, "xs") || !testIntegerLiteral(t, slice.Low, 1) || !testIntegerLiteral(t, slice.High, 3) {
+		return
+	}
+
+	p = New(lexer.New("h[:red]"))
+	stmt = p.ParseProgram().Statements[0].(*ast.ExpressionStatement)
+	if index, ok := stmt.Expression.(*ast.IndexExpression); !ok {
+		t.Errorf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	} else if _, ok := index.Index.(*ast.SymbolLiteral); !ok {
+		t.Errorf("index not *ast.SymbolLiteral. got=%T", index.Index)
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -1070,3 +1688,139 @@ func TestFunctionLiteralWithName(t *testing.T) {
 			function.Name)
 	}
 }
+
+func TestDocComments(t *testing.T) {
+	input := `
+	### Adds two numbers.
+	### Both must be integers.
+	let add = fn(a, b) { a + b };
+	let undocumented = 1;
+	### A stray comment
+	add(1, ### inside an expression
+	2);
+	let x = 5;
+	`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			4, len(program.Statements))
+	}
+
+	tests := []struct {
+		index       int
+		expectedDoc string
+	}{
+		{0, "Adds two numbers.\nBoth must be integers."},
+		{1, ""},
+		{3, ""},
+	}
+
+	for _, tt := range tests {
+		stmt, ok := program.Statements[tt.index].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not ast.LetStatement. got=%T",
+				tt.index, program.Statements[tt.index])
+		}
+
+		if stmt.Doc != tt.expectedDoc {
+			t.Errorf("statement %d has wrong doc. want=%q, got=%q", tt.index, tt.expectedDoc, stmt.Doc)
+		}
+	}
+
+	function := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if function.Doc != tests[0].expectedDoc {
+		t.Errorf("function literal has wrong doc. want=%q, got=%q", tests[0].expectedDoc, function.Doc)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := `### documents nothing
+	5;
+	let = 1;`
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	tests := []struct {
+		expectedSeverity diagnostic.Severity
+		expectedCode     string
+		expectedLine     int
+		expectedColumn   int
+	}{
+		{diagnostic.Warning, "W001", 1, 1},
+		{diagnostic.Error, "E002", 3, 6},
+		{diagnostic.Error, "E001", 3, 6},
+	}
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != len(tests) {
+		t.Fatalf("wrong number of diagnostics. want=%d, got=%d (%v)", len(tests), len(diagnostics), diagnostics)
+	}
+
+	for i, tt := range tests {
+		d := diagnostics[i]
+		if d.Severity != tt.expectedSeverity || d.Code != tt.expectedCode ||
+			d.Line != tt.expectedLine || d.Column != tt.expectedColumn {
+			t.Errorf("diagnostics[%d] wrong. want=%s[%s] at %d:%d, got=%s",
+				i, tt.expectedSeverity, tt.expectedCode, tt.expectedLine, tt.expectedColumn, d)
+		}
+	}
+
+	if len(p.Errors()) != len(diagnostics.Errors()) {
+		t.Errorf("errors and error diagnostics differ. errors=%v, diagnostics=%v", p.Errors(), diagnostics.Errors())
+	}
+}
+
+func TestFeatureGates(t *testing.T) {
+	feature.Register("test_gated", "use identifiers instead", feature.Experimental)
+	feature.Register("test_old", "use identifiers instead", feature.Deprecated)
+
+	tests := []struct {
+		name             string
+		features         feature.Set
+		expectedErrors   []string
+		expectedWarnings []string
+	}{
+		{"test_gated", nil, []string{"@ is an experimental feature, enable it with --feature=test_gated"}, nil},
+		{"test_gated", feature.Set{"test_gated": true}, nil, nil},
+		{"test_old", nil, nil, []string{"1:1: warning[W002]: @ is deprecated: use identifiers instead"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(`@`))
+		p.SetFeatures(tt.features)
+		// gate a prefix parse function like the parsing function of new syntax would
+		p.registerPrefix(token.ILLEGAL, func() ast.Expression {
+			if !p.requireFeature(tt.name) {
+				return nil
+			}
+			return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		})
+		p.ParseProgram()
+
+		if len(p.Errors()) != len(tt.expectedErrors) {
+			t.Fatalf("wrong errors for %s. want=%v, got=%v", tt.name, tt.expectedErrors, p.Errors())
+		}
+		for i, msg := range tt.expectedErrors {
+			if p.Errors()[i] != msg {
+				t.Errorf("wrong error for %s. want=%q, got=%q", tt.name, msg, p.Errors()[i])
+			}
+		}
+
+		warnings := p.Diagnostics().Warnings()
+		if len(warnings) != len(tt.expectedWarnings) {
+			t.Fatalf("wrong warnings for %s. want=%v, got=%v", tt.name, tt.expectedWarnings, warnings)
+		}
+		for i, msg := range tt.expectedWarnings {
+			if warnings[i].String() != msg {
+				t.Errorf("wrong warning for %s. want=%q, got=%q", tt.name, msg, warnings[i])
+			}
+		}
+	}
+}
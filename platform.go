@@ -0,0 +1,27 @@
+//go:build !js && !wasip1
+// +build !js,!wasip1
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"os/user"
+)
+
+// username returns the name of the user running the REPL for its greeting, or an empty string when the
+// system cannot tell, like in a container without an entry for the user in /etc/passwd
+func username() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// notifyInterrupts returns a channel receiving the interrupts of the process, Ctrl-C in a terminal
+func notifyInterrupts() chan os.Signal {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	return interrupts
+}
@@ -0,0 +1,17 @@
+//go:build js || wasip1
+// +build js wasip1
+
+package main
+
+import "os"
+
+// username returns an empty string, a WebAssembly host has no user accounts to look up
+func username() string {
+	return ""
+}
+
+// notifyInterrupts returns nil, the process never receives signals from a WebAssembly host, so nothing
+// interrupts the REPL or a script and a receive from the channel blocks forever
+func notifyInterrupts() chan os.Signal {
+	return nil
+}
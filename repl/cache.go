@@ -0,0 +1,55 @@
+package repl
+
+import (
+	"crypto/sha256"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+)
+
+// maxCachedLines bounds the compilation cache, the cache is emptied when a new line does not fit
+const maxCachedLines = 512
+
+// cachedLine is the compiled bytecode of an input. The constants it refers to stay in the constant pool
+// of the session, which only grows, so only the instructions are kept.
+type cachedLine struct {
+	instructions code.Instructions
+	// warnings are the diagnostics shown when the line was compiled, they are shown again on every run
+	warnings diagnostic.Diagnostics
+	// definitions is the number of symbols the session defined after compiling the line. The bytecode reads and
+	// writes the global slots the names had then, so it is only reused while nothing else was defined.
+	definitions int
+}
+
+// compileCache keeps the bytecode of the inputs compiled by the session keyed by a hash of their source,
+// so running an input again, like one recalled from the history, skips lexing, parsing and compiling it
+type compileCache struct {
+	lines  map[[sha256.Size]byte]*cachedLine
+	hits   int
+	misses int
+}
+
+// newCompileCache creates an empty compilation cache
+func newCompileCache() *compileCache {
+	return &compileCache{lines: make(map[[sha256.Size]byte]*cachedLine)}
+}
+
+// lookup returns the bytecode of the line when it was compiled before and is still valid for the symbol table
+func (c *compileCache) lookup(line string, symbolTable *compiler.SymbolTable) (*cachedLine, bool) {
+	cached, ok := c.lines[sha256.Sum256([]byte(line))]
+	if !ok || cached.definitions != symbolTable.NumDefinitions() {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return cached, true
+}
+
+// store adds the bytecode of a line that compiled without errors
+func (c *compileCache) store(line string, cached *cachedLine) {
+	if len(c.lines) >= maxCachedLines {
+		c.lines = make(map[[sha256.Size]byte]*cachedLine)
+	}
+	c.lines[sha256.Sum256([]byte(line))] = cached
+}
@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// heapGlobal is a live global binding and the estimated size of everything it retains
+type heapGlobal struct {
+	name  string
+	value object.Object
+	size  int
+}
+
+// printHeap writes every live global binding of the session with the size it retains, largest first,
+// followed by the objects reachable from all of them by type. Bindings hidden by a later let statement
+// of the same name still hold their value, they are listed as shadowed since they can no longer be released.
+func printHeap(out io.Writer, session *Session) {
+	names := make(map[int]string)
+	slots := 0
+	for _, symbol := range session.SymbolTable.Globals() {
+		names[symbol.Index] = symbol.Name
+		if symbol.Index >= slots {
+			slots = symbol.Index + 1
+		}
+	}
+
+	globals := []heapGlobal{}
+	roots := []object.Object{}
+	for i := 0; i < slots; i++ {
+		value := session.Globals[i]
+		if value == nil {
+			continue
+		}
+		name, ok := names[i]
+		if !ok {
+			name = fmt.Sprintf("(shadowed #%d)", i)
+		}
+		globals = append(globals, heapGlobal{name: name, value: value, size: object.RetainedSize(value)})
+		roots = append(roots, value)
+	}
+	sort.SliceStable(globals, func(i, j int) bool { return globals[i].size > globals[j].size })
+
+	fmt.Fprintf(out, "globals: %d\n", len(globals))
+	for _, global := range globals {
+		fmt.Fprintf(out, "\t%s: %s%s, %s\n", global.name, global.value.Type(), describeLength(global.value), formatBytes(global.size))
+	}
+
+	summary := object.SummarizeHeap(roots)
+	types := []string{}
+	for t := range summary.Counts {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+
+	fmt.Fprintf(out, "reachable objects: %d, %s\n", summary.Objects, formatBytes(summary.Bytes))
+	for _, t := range types {
+		fmt.Fprintf(out, "\t%s: %d, %s\n", t, summary.Counts[object.ObjectType(t)], formatBytes(summary.Sizes[object.ObjectType(t)]))
+	}
+}
+
+// describeLength returns the number of elements of arrays, hashes and strings for the heap dump
+func describeLength(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return fmt.Sprintf(" (%d elements)", len(obj.Elements))
+	case *object.Hash:
+		return fmt.Sprintf(" (%d pairs)", len(obj.Pairs))
+	case *object.String:
+		return fmt.Sprintf(" (%d bytes)", len(obj.Value))
+	default:
+		return ""
+	}
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KiB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1024*1024))
+	}
+}
@@ -0,0 +1,70 @@
+package repl
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// InterruptExitCode is the exit status of the process when an interrupt ends the REPL, like shells report SIGINT
+const InterruptExitCode = 130
+
+// exit ends the process, tests replace it to observe the REPL exiting
+var exit = os.Exit
+
+// interrupter cancels the input that is running when an interrupt arrives. An interrupt arriving while
+// no input runs, or while the cancelled input has not stopped yet, exits the process instead.
+type interrupter struct {
+	mu sync.Mutex
+	// cancel cancels the running input, it is nil at the prompt
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// watch handles the interrupts until the channel is closed
+func (it *interrupter) watch(interrupts <-chan os.Signal) {
+	for range interrupts {
+		if !it.interrupt() {
+			exit(InterruptExitCode)
+		}
+	}
+}
+
+// Interrupt stops the input that is running, like an interrupt signal does, without ever exiting the process.
+// It reports whether an input was running. Frontends receiving interrupts as requests, like notebooks, call it.
+func (r *REPL) Interrupt() bool {
+	return r.interrupts.interrupt()
+}
+
+// interrupt cancels the running input and reports whether there was one to cancel
+func (it *interrupter) interrupt() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.cancel == nil || it.cancelled {
+		return false
+	}
+	it.cancel()
+	it.cancelled = true
+	return true
+}
+
+// start returns the context an input runs with, until finish is called it is cancelled by an interrupt
+func (it *interrupter) start() context.Context {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it.cancel, it.cancelled = cancel, false
+	return ctx
+}
+
+// finish releases the context of the input that stopped running and reports whether an interrupt cancelled it
+func (it *interrupter) finish() bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.cancel()
+	it.cancel = nil
+	return it.cancelled
+}
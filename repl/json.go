@@ -0,0 +1,129 @@
+package repl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// Response is the outcome of a single input in JSON mode, written as one line of JSON so editors, notebooks and
+// the playground can drive a session without reading the output meant for people. Value is the inspected result
+// and Type its object type, both are empty when the input has no value, like a let statement or a command.
+// Stdout holds what puts, print and the commands wrote. Errors holds the errors that stopped the input and Warnings
+// the warnings found while running it. Durations holds the milliseconds spent in each stage the input went through:
+// parse, check, compile and run, and the total. Exit is the status passed to exit when the input called it.
+type Response struct {
+	Value     string             `json:"value"`
+	Type      string             `json:"type"`
+	Stdout    string             `json:"stdout"`
+	Errors    []ResponseError    `json:"errors"`
+	Warnings  []ResponseError    `json:"warnings"`
+	Durations map[string]float64 `json:"durations"`
+	Exit      *int               `json:"exit,omitempty"`
+}
+
+// ResponseError is an error or a warning of a Response. Kind names the stage that reported it: "parse error",
+// "type error", "compile error", "runtime error", or "warning" for warnings. Code identifies the diagnostics of
+// the parser and the type checker. Line and Column are left out when the position is unknown, Trace lists the
+// calls active when a runtime error happened and Suggestion is the name that was probably meant.
+type ResponseError struct {
+	Kind       string         `json:"kind"`
+	Code       string         `json:"code,omitempty"`
+	Message    string         `json:"message"`
+	Line       int            `json:"line,omitempty"`
+	Column     int            `json:"column,omitempty"`
+	Suggestion string         `json:"suggestion,omitempty"`
+	Trace      []report.Frame `json:"trace,omitempty"`
+}
+
+// EvalJSON runs a single input like EvalLine, but writes its Response as a line of JSON instead of the result,
+// the errors and the warnings meant for people. It returns the response and the error the input failed with.
+func (r *REPL) EvalJSON(line string) (*Response, error) {
+	out := r.out
+	response := &Response{Errors: []ResponseError{}, Warnings: []ResponseError{}, Durations: map[string]float64{}}
+	var stdout bytes.Buffer
+	r.out, r.stdout, r.response = io.Discard, &stdout, response
+
+	start := time.Now()
+	result, err := r.EvalLine(line)
+	r.out, r.stdout, r.response = out, nil, nil
+	response.Durations["total"] = milliseconds(time.Since(start))
+
+	response.Stdout = stdout.String()
+	if result != nil {
+		response.Value = object.InspectWith(result, r.options.Inspect)
+		response.Type = string(result.Type())
+	}
+	var exit *vm.ExitError
+	if errors.As(err, &exit) {
+		response.Exit = &exit.Code
+	} else if err != nil && len(response.Errors) == 0 {
+		// parse and type errors are recorded with their codes before they stop the input
+		response.Errors = append(response.Errors, responseError(err))
+	}
+
+	if data, err := json.Marshal(response); err == nil {
+		out.Write(append(data, '\n'))
+	}
+	return response, err
+}
+
+// output returns the writer receiving the output of puts, print and the commands.
+// It is kept apart from the results and the errors in JSON mode.
+func (r *REPL) output() io.Writer {
+	if r.stdout != nil {
+		return r.stdout
+	}
+	return r.out
+}
+
+// record adds the diagnostics to the response of the input run in JSON mode, kind names the stage reporting the errors
+func (r *REPL) record(kind string, diagnostics diagnostic.Diagnostics) {
+	if r.response == nil {
+		return
+	}
+	for _, d := range diagnostics {
+		e := ResponseError{Kind: kind, Code: d.Code, Message: d.Message, Line: d.Line, Column: d.Column}
+		if d.Severity == diagnostic.Warning {
+			e.Kind = "warning"
+			r.response.Warnings = append(r.response.Warnings, e)
+		} else {
+			r.response.Errors = append(r.response.Errors, e)
+		}
+	}
+}
+
+// timed records the time since start as the duration of the stage in the response of the input run in JSON mode
+func (r *REPL) timed(stage string, start time.Time) {
+	if r.response != nil {
+		r.response.Durations[stage] = milliseconds(time.Since(start))
+	}
+}
+
+// milliseconds returns the duration in milliseconds, with the fraction kept for the stages faster than that
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// responseError describes the error that stopped an input, located by the compiler or an engine when it can be
+func responseError(err error) ResponseError {
+	var located *report.Error
+	if !errors.As(err, &located) {
+		return ResponseError{Kind: "error", Message: err.Error()}
+	}
+	return ResponseError{
+		Kind:       located.Kind.String(),
+		Message:    located.Message,
+		Line:       located.Line,
+		Column:     located.Column,
+		Suggestion: located.Suggestion,
+		Trace:      located.Trace,
+	}
+}
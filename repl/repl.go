@@ -2,80 +2,471 @@ package repl
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/yourfavoritedev/golang-interpreter/ast"
 	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/evaluator"
+	"github.com/yourfavoritedev/golang-interpreter/feature"
 	"github.com/yourfavoritedev/golang-interpreter/lexer"
 	"github.com/yourfavoritedev/golang-interpreter/object"
 	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+	"github.com/yourfavoritedev/golang-interpreter/runner"
+	"github.com/yourfavoritedev/golang-interpreter/stdlib"
+	"github.com/yourfavoritedev/golang-interpreter/typecheck"
 	"github.com/yourfavoritedev/golang-interpreter/vm"
 )
 
 const PROMPT = ">> "
 const MONKEY_FACE = "@(^_^)@\n"
 
+// Options configures a REPL session
+type Options struct {
+	// Features are the experimental language features enabled for every input
+	Features feature.Set
+	// Transcript receives every input and output of the session with timestamps, it is disabled when nil
+	Transcript io.Writer
+	// Inspect controls how each result is printed, the output of puts and print uses the same options
+	Inspect object.InspectOptions
+	// Interrupts receives the interrupt signals of the process. An interrupt stops the running input and returns
+	// to the prompt, an interrupt at the prompt or a second one before the input stopped exits the process.
+	Interrupts <-chan os.Signal
+	// Strict makes indexing an array out of range and reading a missing hash key errors instead of null.
+	// Results are printed by the REPL, so unused results are not reported like they are for files.
+	Strict bool
+	// MaxCallDepth is the maximum number of nested function calls, 0 for the default of the engine
+	MaxCallDepth int
+	// NoStdlib starts the session without the standard library, its names are free for the inputs
+	NoStdlib bool
+	// Prompt is written before every input read by Run, PROMPT when empty
+	Prompt string
+	// Writer receives the results, the errors and the output of puts and print, standard output when nil
+	Writer io.Writer
+	// Engine runs the inputs, the VM when empty. The :stats, :heap and session commands need the VM.
+	Engine runner.Engine
+	// Banner is written once when Run starts, before the first prompt
+	Banner string
+	// Quiet leaves out the banner and the prompts, so only the results and the output of the inputs are
+	// written when the input is piped from another program instead of typed in a terminal
+	Quiet bool
+	// HistoryPath is a file every input is appended to, one per line, so frontends can recall the inputs
+	// of previous sessions. The history is not written when it is empty.
+	HistoryPath string
+	// JSON makes Run answer every input with a single line of JSON describing its outcome, see Response,
+	// for editors, notebooks and other programs driving the REPL. Like Quiet, it leaves out the banner and the prompts.
+	JSON bool
+}
+
+// Start runs the REPL with only the stable language features
 func Start(in io.Reader, out io.Writer) {
-	// scanner helps intake standard input (from user) as a data stream
-	scanner := bufio.NewScanner(in)
+	StartWithOptions(in, out, Options{})
+}
+
+// StartWithFeatures runs the REPL, reading input from in and writing results to out.
+// The given experimental language features are enabled for every input.
+func StartWithFeatures(in io.Reader, out io.Writer, features feature.Set) {
+	StartWithOptions(in, out, Options{Features: features})
+}
+
+// StartWithOptions runs the REPL configured by the options, reading input from in and writing results to out
+func StartWithOptions(in io.Reader, out io.Writer, options Options) {
+	options.Writer = out
+	r, err := New(options)
+	if err != nil {
+		fmt.Fprintf(out, "Woops! Starting the REPL failed:\n %s\n", err)
+		return
+	}
+	r.Run(in)
+}
+
+// REPL is a session of the read-eval-print loop. Run reads the inputs from a reader and prompts for each of them
+// like the interactive REPL, EvalLine runs a single input, which lets the playground, tests and other frontends
+// drive a session programmatically. The bindings of every input are visible to the inputs after it until Reset.
+type REPL struct {
+	options Options
+	// out receives the results and the errors of the inputs, and the transcript when there is one
+	out io.Writer
 
 	// helps us preserve the work when running multiple compilations
-	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalsSize)
-	symbolTable := compiler.NewSymbolTable()
-	for i, v := range object.Builtins {
-		symbolTable.DefineBuiltin(i, v.Name)
+	session *Session
+	// env holds the bindings of the inputs run by the evaluator
+	env *object.Environment
+	// checks the type annotations of every input with the bindings of the previous inputs
+	checker *typecheck.Checker
+	// the inputs that ran successfully, in order, written as a script by :save
+	history []string
+	// running total of string literals that reused an existing constant across all compilations
+	internedStrings int
+	// the bytecode of the inputs compiled for the VM, reused when an input is run again
+	cache *compileCache
+	// the handlers registered with `on_signal` by the inputs, they are kept until the session is reset
+	signals *object.Signals
+	// the logger of the inputs, the level set with `log_level` is kept until the session is reset
+	logger *object.Logger
+
+	interrupts *interrupter
+	// color highlights the diagnostics, only a terminal without a transcript shows them in color
+	color bool
+
+	// the response of the input run by EvalJSON and the output of puts, print and the commands
+	// captured for it, both are nil outside of EvalJSON
+	response *Response
+	stdout   *bytes.Buffer
+}
+
+// New creates a REPL session configured by the options
+func New(options Options) (*REPL, error) {
+	switch options.Engine {
+	case "":
+		options.Engine = runner.VM
+	case runner.VM, runner.Eval:
+	default:
+		return nil, fmt.Errorf("unknown engine %q, want %q or %q", options.Engine, runner.VM, runner.Eval)
+	}
+	if options.Prompt == "" {
+		options.Prompt = PROMPT
+	}
+	if options.Writer == nil {
+		options.Writer = os.Stdout
 	}
 
+	r := &REPL{options: options, out: options.Writer, interrupts: &interrupter{}}
+	r.color = diagnostic.IsTerminal(options.Writer) && options.Transcript == nil
+	// the prompt is not part of the transcript, everything written to out is
+	if options.Transcript != nil {
+		r.out = io.MultiWriter(options.Writer, &transcriptWriter{w: options.Transcript, prefix: "   "})
+	}
+	// the output of the inputs is flushed as it is written, so a long-running input shows its progress
+	if f, ok := options.Writer.(object.Flusher); ok {
+		r.out = &flushWriter{w: r.out, flusher: f}
+	}
+	if options.Interrupts != nil {
+		go r.interrupts.watch(options.Interrupts)
+	}
+	r.Reset()
+	return r, nil
+}
+
+// Reset forgets every binding, the constants and the history of the session, as if the REPL had just started
+func (r *REPL) Reset() {
+	r.session = NewSession()
+	r.env = object.NewEnvironment()
+	if !r.options.NoStdlib {
+		library := stdlib.Load()
+		library.Define(r.session.SymbolTable, r.session.Globals)
+		r.session.Constants = library.Constants()
+		stdlib.Eval(r.env)
+	}
+	r.checker = typecheck.New()
+	r.history = []string{}
+	r.internedStrings = 0
+	r.cache = newCompileCache()
+	if r.signals != nil {
+		r.signals.Stop()
+	}
+	r.signals = object.NewSignals()
+	r.logger = object.Log.Copy()
+}
+
+// Run writes the banner, then reads the inputs line by line and evaluates each of them after writing the prompt.
+// Quiet and JSON sessions write neither, JSON sessions answer every input with EvalJSON.
+// It returns at the end of the input or when an input calls exit.
+func (r *REPL) Run(in io.Reader) {
+	quiet := r.options.Quiet || r.options.JSON
+	if !quiet {
+		io.WriteString(r.options.Writer, r.options.Banner)
+	}
+	// scanner helps intake standard input (from user) as a data stream
+	scanner := bufio.NewScanner(in)
+
 	// keep accepting standard input until the user forcefully stops the program
 	for {
-		// Display prompt to signal start of input after ">> "
-		fmt.Fprintf(out, PROMPT)
+		// Display prompt to signal start of input
+		if !quiet {
+			io.WriteString(r.options.Writer, r.options.Prompt)
+		}
+		if f, ok := r.options.Writer.(object.Flusher); ok {
+			f.Flush()
+		}
 		// Scan loops until it receives input (from user), then makes the input available to its other methods
-		scanned := scanner.Scan()
-
 		// Exit program when no active data-stream left to scan
-		if !scanned {
+		if !scanner.Scan() {
 			return
 		}
 
-		// get the entire newly scanned input
-		line := scanner.Text()
-		// create mew lexer using input
-		l := lexer.New(line)
-		// create new parser using lexer
-		p := parser.New(l)
-
-		// initialize program
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
+		eval := r.EvalLine
+		if r.options.JSON {
+			eval = func(line string) (object.Object, error) {
+				_, err := r.EvalJSON(line)
+				return nil, err
+			}
 		}
+		// exit ends the session like the end of the input does
+		if _, err := eval(scanner.Text()); isExit(err) {
+			return
+		}
+	}
+}
 
-		// compile the program
-		comp := compiler.NewWithState(symbolTable, constants)
-		err := comp.Compile(program)
-		if err != nil {
-			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
-			continue
+// EvalLine runs a single input or REPL command and writes its result to the writer of the session, the way the
+// interactive REPL shows it. It returns the result, which is nil for commands and inputs without an expression.
+// When the input fails the error is written as well as returned, a *vm.ExitError is returned when the input called exit.
+func (r *REPL) EvalLine(line string) (object.Object, error) {
+	out := r.out
+	if r.options.Transcript != nil {
+		(&transcriptWriter{w: r.options.Transcript, prefix: r.options.Prompt}).Write([]byte(line + "\n"))
+	}
+	if r.options.HistoryPath != "" && strings.TrimSpace(line) != "" {
+		if err := appendHistory(r.options.HistoryPath, line); err != nil {
+			fmt.Fprintf(out, "Woops! Writing the history failed:\n %s\n", err)
+		}
+	}
+
+	// REPL commands are handled before the input reaches the lexer
+	if handled, err := r.command(line); handled {
+		return nil, err
+	}
+
+	// puts and print write next to the results, so their output reaches the transcript too
+	output := object.Output
+	object.Output = &object.Printer{Writer: r.output(), Inspect: r.options.Inspect, Separator: output.Separator}
+	defer func() { object.Output = output }()
+
+	var result object.Object
+	var err error
+	if cached, ok := r.cachedLine(line); ok {
+		// the warnings are shown like they would be if the line was compiled again
+		diagnostic.RenderColor(out, cached.warnings, r.color)
+		r.record("", cached.warnings)
+		result, err = r.run(&compiler.Bytecode{Instructions: cached.instructions, Constants: r.session.Constants})
+	} else {
+		result, err = r.parseAndRun(line)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(line) != "" {
+		r.history = append(r.history, line)
+	}
+
+	// input without any expression (blank or only comments) has nothing to print
+	if result == nil {
+		return nil, nil
+	}
+	// write program string to output
+	io.WriteString(out, object.InspectWith(result, r.options.Inspect))
+	io.WriteString(out, "\n")
+	return result, nil
+}
+
+// cachedLine returns the bytecode of the line when the VM runs the inputs and the line was compiled before
+func (r *REPL) cachedLine(line string) (*cachedLine, bool) {
+	if r.options.Engine != runner.VM {
+		return nil, false
+	}
+	return r.cache.lookup(line, r.session.SymbolTable)
+}
+
+// parseAndRun parses and checks the line, then runs it with the engine of the session
+func (r *REPL) parseAndRun(line string) (object.Object, error) {
+	out := r.out
+	// create mew lexer using input
+	l := lexer.New(line)
+	// create new parser using lexer
+	p := parser.New(l)
+	p.SetFeatures(r.options.Features)
+
+	// initialize program
+	start := time.Now()
+	program := p.ParseProgram()
+	r.timed("parse", start)
+	r.record("parse error", p.Diagnostics())
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, p.Errors())
+		return nil, fmt.Errorf("parser errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+	// warnings are shown without stopping the input from running
+	warnings := p.Diagnostics().Warnings()
+	diagnostic.RenderColor(out, warnings, r.color)
+
+	// type errors stop the input before it runs, they are rendered with the type warnings
+	start = time.Now()
+	types := r.checker.Check(program)
+	r.timed("check", start)
+	r.record("type error", types)
+	diagnostic.RenderColor(out, types, r.color)
+	if errors := types.Errors(); len(errors) != 0 {
+		messages := make([]string, len(errors))
+		for i, e := range errors {
+			messages[i] = e.Message
+		}
+		return nil, fmt.Errorf("type errors:\n\t%s", strings.Join(messages, "\n\t"))
+	}
+	warnings = append(warnings, types...)
+
+	if r.options.Engine == runner.Eval {
+		return r.evaluate(program)
+	}
+	return r.execute(line, program, warnings)
+}
+
+// command runs the input when it is a REPL command and reports whether it was one
+// The output of a command is written like the output of puts, its errors like the errors of the inputs.
+func (r *REPL) command(line string) (bool, error) {
+	out := r.output()
+	name := line
+	if space := strings.IndexByte(line, ' '); space != -1 {
+		name = line[:space]
+	}
+	switch name {
+	case ":stats", ":heap", ":save-session", ":load-session":
+		// the commands inspecting the session look at the state of the VM
+		if r.options.Engine != runner.VM {
+			err := fmt.Errorf("%s is only available with the %s engine", name, runner.VM)
+			fmt.Fprintf(r.out, "Woops! %s\n", err)
+			return true, err
 		}
+	}
 
-		// execute the program
-		code := comp.Bytecode()
-		constants = code.Constants
-		machine := vm.NewWithGlobalStore(code, globals)
-		err = machine.Run()
+	if line == ":stats" {
+		printStats(out, r.session.Constants, r.internedStrings, r.cache)
+		return true, nil
+	}
+	if line == ":heap" {
+		printHeap(out, r.session)
+		return true, nil
+	}
+	if path := strings.TrimPrefix(line, ":save "); path != line {
+		err := saveScript(r.history, strings.TrimSpace(path))
+		if err != nil {
+			fmt.Fprintf(r.out, "Woops! Saving the script failed:\n %s\n", err)
+		}
+		return true, err
+	}
+	if path := strings.TrimPrefix(line, ":save-session "); path != line {
+		err := saveSession(r.session, strings.TrimSpace(path))
+		if err != nil {
+			fmt.Fprintf(r.out, "Woops! Saving the session failed:\n %s\n", err)
+		}
+		return true, err
+	}
+	if path := strings.TrimPrefix(line, ":load-session "); path != line {
+		loaded, err := loadSession(strings.TrimSpace(path))
 		if err != nil {
-			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
-			continue
+			fmt.Fprintf(r.out, "Woops! Loading the session failed:\n %s\n", err)
+			return true, err
 		}
+		r.session = loaded
+		// the cached bytecode refers to the constants and the globals of the previous session
+		r.cache = newCompileCache()
+		return true, nil
+	}
+	return false, nil
+}
 
-		lastPopped := machine.LastPoppedStackElem()
-		// write program string to output
-		io.WriteString(out, lastPopped.Inspect())
-		io.WriteString(out, "\n")
+// execute compiles the program with the state of the session and runs it on the VM.
+// The bytecode is cached with the warnings shown for the line, so running the line again skips compiling it.
+func (r *REPL) execute(line string, program *ast.Program, warnings diagnostic.Diagnostics) (object.Object, error) {
+	out := r.out
+	// compile the program
+	comp := compiler.NewWithState(r.session.SymbolTable, r.session.Constants)
+	// keep the source of functions around for the `source` built-in function
+	comp.SetDebugInfo(true)
+	start := time.Now()
+	err := comp.Compile(program)
+	r.timed("compile", start)
+	if err != nil {
+		woops(out, "Compilation failed", err)
+		return nil, err
 	}
+	diagnostic.RenderColor(out, comp.Diagnostics(), r.color)
+	r.record("compile error", comp.Diagnostics())
+
+	r.internedStrings += comp.InternedStrings()
+
+	code := comp.Bytecode()
+	r.session.Constants = code.Constants
+	r.cache.store(line, &cachedLine{
+		instructions: code.Instructions,
+		warnings:     append(warnings, comp.Diagnostics()...),
+		definitions:  r.session.SymbolTable.NumDefinitions(),
+	})
+	return r.run(code)
+}
+
+// run executes the bytecode on a VM sharing the globals of the session
+func (r *REPL) run(code *compiler.Bytecode) (object.Object, error) {
+	out := r.out
+	machine := vm.NewWithGlobalStore(code, r.session.Globals)
+	machine.SetStrict(r.options.Strict)
+	machine.SetSignals(r.signals)
+	machine.SetLogger(r.logger)
+	if r.options.MaxCallDepth > 0 {
+		machine.SetMaxFrames(r.options.MaxCallDepth)
+	}
+	start := time.Now()
+	err := machine.Report(machine.RunContext(r.interrupts.start()))
+	interrupted := r.interrupts.finish()
+	r.timed("run", start)
+	if isExit(err) {
+		return nil, err
+	}
+	if err != nil && interrupted {
+		fmt.Fprintln(out, "Interrupted")
+		return nil, err
+	}
+	if err != nil {
+		woops(out, "Executing bytecode failed", err)
+		return nil, err
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
+
+// evaluate runs the program with the evaluator in the environment of the session
+func (r *REPL) evaluate(program *ast.Program) (object.Object, error) {
+	out := r.out
+	start := time.Now()
+	options := evaluator.Options{Strict: r.options.Strict, MaxCallDepth: r.options.MaxCallDepth, Signals: r.signals, Logger: r.logger}
+	result := evaluator.EvalWith(r.interrupts.start(), program, r.env, options)
+	interrupted := r.interrupts.finish()
+	r.timed("run", start)
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		return result, nil
+	}
+	if errObj.Exit {
+		return nil, &vm.ExitError{Code: errObj.Code}
+	}
+	err := evaluator.Report(errObj)
+	if interrupted {
+		fmt.Fprintln(out, "Interrupted")
+	} else {
+		woops(out, "Evaluating failed", err)
+	}
+	return nil, err
+}
+
+// woops writes the error that stopped the input below the stage that failed, formatted by report.Format
+func woops(out io.Writer, stage string, err error) {
+	fmt.Fprintf(out, "Woops! %s:\n", stage)
+	for _, line := range strings.Split(report.Format("", err), "\n") {
+		fmt.Fprintf(out, " %s\n", line)
+	}
+}
+
+// isExit reports whether the error is the request of the exit built-in function to end the session
+func isExit(err error) bool {
+	_, ok := err.(*vm.ExitError)
+	return ok
 }
 
 // printParserErrors writes the parser errors to the output
@@ -87,3 +478,40 @@ func printParserErrors(out io.Writer, errors []string) {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
 }
+
+// printStats writes statistics about the constant pool shared by all compilations in the REPL session
+// and about the compilation cache
+func printStats(out io.Writer, constants []object.Object, internedStrings int, cache *compileCache) {
+	counts := make(map[object.ObjectType]int)
+	types := []string{}
+	for _, c := range constants {
+		if counts[c.Type()] == 0 {
+			types = append(types, string(c.Type()))
+		}
+		counts[c.Type()]++
+	}
+	sort.Strings(types)
+
+	fmt.Fprintf(out, "constants: %d\n", len(constants))
+	for _, t := range types {
+		fmt.Fprintf(out, "\t%s: %d\n", t, counts[object.ObjectType(t)])
+	}
+	fmt.Fprintf(out, "interned strings: %d\n", internedStrings)
+	fmt.Fprintf(out, "compile cache: %d lines, %d hits, %d misses\n", len(cache.lines), cache.hits, cache.misses)
+}
+
+// flushWriter passes every write on to w and flushes the writer of the session right after it,
+// so the output of puts and print reaches a buffered writer while the input is still running
+type flushWriter struct {
+	w       io.Writer
+	flusher object.Flusher
+}
+
+// Write writes p to w and flushes the buffered writer
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, fw.flusher.Flush()
+}
@@ -0,0 +1,385 @@
+package repl
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/runner"
+)
+
+func TestTranscriptAndSave(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	script := filepath.Join(t.TempDir(), "script.monkey")
+	input := strings.Join([]string{
+		`let x = 1;`,
+		`x + "a"`,
+		``,
+		`x + 2`,
+		`:save ` + script,
+	}, "\n")
+
+	var out, transcript bytes.Buffer
+	StartWithOptions(strings.NewReader(input), &out, Options{Transcript: &transcript})
+
+	expectedTranscript := `[2026-01-02T03:04:05Z] >> let x = 1;
+[2026-01-02T03:04:05Z]    1
+[2026-01-02T03:04:05Z] >> x + "a"
+` + "[2026-01-02T03:04:05Z]    1:3: warning[W301]: operator + is not supported for int and string\n" +
+		`[2026-01-02T03:04:05Z]    Woops! Executing bytecode failed:
+[2026-01-02T03:04:05Z]     1:3: runtime error: unsupported types for binary operation: INTEGER, STRING
+[2026-01-02T03:04:05Z] >> 
+[2026-01-02T03:04:05Z] >> x + 2
+[2026-01-02T03:04:05Z]    3
+[2026-01-02T03:04:05Z] >> :save ` + script + "\n"
+	if transcript.String() != expectedTranscript {
+		t.Errorf("wrong transcript. want=\n%s\ngot=\n%s", expectedTranscript, transcript.String())
+	}
+
+	saved, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatalf("reading script failed: %s", err)
+	}
+	expectedScript := "let x = 1;\nx + 2\n"
+	if string(saved) != expectedScript {
+		t.Errorf("wrong script. want=%q, got=%q", expectedScript, string(saved))
+	}
+}
+
+func TestHeapCommand(t *testing.T) {
+	input := strings.Join([]string{
+		`let small = 1;`,
+		`let big = ["a", "b", "c", "d"];`,
+		`let small = 2;`,
+		`:heap`,
+	}, "\n")
+
+	// the functions of the standard library are globals too, the dump only lists the ones of the inputs without it
+	var out bytes.Buffer
+	StartWithOptions(strings.NewReader(input), &out, Options{NoStdlib: true})
+
+	dump := out.String()[strings.Index(out.String(), "globals:"):]
+	lines := strings.Split(dump, "\n")
+	expected := []string{
+		"globals: 3",
+		"\tbig: ARRAY (4 elements), ",
+		"\t(shadowed #0): INTEGER, ",
+		"\tsmall: INTEGER, ",
+		"reachable objects: 7, ",
+		"\tARRAY: 1, ",
+		"\tINTEGER: 2, ",
+		"\tSTRING: 4, ",
+	}
+	for i, prefix := range expected {
+		if i >= len(lines) || !strings.HasPrefix(lines[i], prefix) {
+			t.Fatalf("wrong heap dump line %d. want prefix %q, got=\n%s", i, prefix, dump)
+		}
+	}
+}
+
+func TestPrintOutput(t *testing.T) {
+	input := strings.Join([]string{
+		`puts("hello", [1, [2, [3]]])`,
+		`print("a", 1); print("b")`,
+		`"hello"`,
+	}, "\n")
+
+	var out, transcript bytes.Buffer
+	options := Options{Transcript: &transcript, Inspect: object.InspectOptions{MaxDepth: 2, QuoteStrings: true}}
+	StartWithOptions(strings.NewReader(input), &out, options)
+
+	// puts and print share the writer and the options the REPL prints results with
+	expected := ">> \"hello\" [1, [2, [...]]]\nnull\n>> \"a\" 1\"b\"null\n>> \"hello\"\n>> "
+	if out.String() != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", expected, out.String())
+	}
+	if !strings.Contains(transcript.String(), "[1, [2, [...]]]") {
+		t.Errorf("output of puts is missing from the transcript. got=%q", transcript.String())
+	}
+	if object.Output.Writer != os.Stdout {
+		t.Errorf("the output of puts was not restored after the session")
+	}
+}
+
+func TestExitEndsSession(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("1 + 1\nexit(2)\n3 + 3\n"), &out)
+
+	if strings.Contains(out.String(), "6") {
+		t.Errorf("input after exit was run. got=%q", out.String())
+	}
+	if !strings.Contains(out.String(), "2") {
+		t.Errorf("input before exit was not run. got=%q", out.String())
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	input := strings.Join([]string{
+		`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };`,
+		`fib(50)`,
+		`1 + 1`,
+	}, "\n")
+
+	interrupts := make(chan os.Signal)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		interrupts <- os.Interrupt
+	}()
+
+	var out bytes.Buffer
+	StartWithOptions(strings.NewReader(input), &out, Options{Interrupts: interrupts})
+
+	expected := ">> Interrupted\n>> 2\n"
+	if !strings.HasSuffix(out.String(), expected+">> ") {
+		t.Errorf("wrong output. want suffix %q, got=%q", expected, out.String())
+	}
+}
+
+func TestInterrupter(t *testing.T) {
+	it := &interrupter{}
+	// at the prompt an interrupt exits
+	if it.interrupt() {
+		t.Errorf("interrupt at the prompt must not cancel")
+	}
+
+	ctx := it.start()
+	if !it.interrupt() || ctx.Err() == nil {
+		t.Fatalf("interrupt did not cancel the running input")
+	}
+	// a second interrupt before the input stopped exits
+	if it.interrupt() {
+		t.Errorf("second interrupt must not cancel again")
+	}
+	if !it.finish() {
+		t.Errorf("input not reported as interrupted")
+	}
+
+	it.start()
+	if it.finish() {
+		t.Errorf("input reported as interrupted")
+	}
+}
+
+func TestEvalLine(t *testing.T) {
+	for _, engine := range []runner.Engine{runner.VM, runner.Eval} {
+		var out bytes.Buffer
+		history := filepath.Join(t.TempDir(), "history")
+		r, err := New(Options{Writer: &out, Engine: engine, HistoryPath: history})
+		if err != nil {
+			t.Fatalf("%s: creating the REPL failed: %s", engine, err)
+		}
+
+		if _, err := r.EvalLine(`let x = 20;`); err != nil {
+			t.Fatalf("%s: unexpected error: %s", engine, err)
+		}
+		result, err := r.EvalLine(`x + 1`)
+		if err != nil || result.Inspect() != "21" {
+			t.Errorf("%s: wrong result. got=%v, %v", engine, result, err)
+		}
+		if !strings.HasSuffix(out.String(), "21\n") {
+			t.Errorf("%s: result not written. got=%q", engine, out.String())
+		}
+		if _, err := r.EvalLine(`x + "a"`); err == nil {
+			t.Errorf("%s: expected an error", engine)
+		}
+		if _, err := r.EvalLine(`exit(3)`); !isExit(err) {
+			t.Errorf("%s: expected an exit. got=%v", engine, err)
+		}
+
+		r.Reset()
+		if _, err := r.EvalLine(`x`); err == nil {
+			t.Errorf("%s: binding survived the reset", engine)
+		}
+
+		saved, err := os.ReadFile(history)
+		if err != nil {
+			t.Fatalf("%s: reading the history failed: %s", engine, err)
+		}
+		expected := "let x = 20;\nx + 1\nx + \"a\"\nexit(3)\nx\n"
+		if string(saved) != expected {
+			t.Errorf("%s: wrong history. want=%q, got=%q", engine, expected, string(saved))
+		}
+	}
+
+	// the commands inspecting the session need the VM
+	r, _ := New(Options{Writer: &bytes.Buffer{}, Engine: runner.Eval})
+	if _, err := r.EvalLine(":stats"); err == nil || err.Error() != ":stats is only available with the vm engine" {
+		t.Errorf("wrong error for :stats. got=%v", err)
+	}
+
+	if _, err := New(Options{Engine: "jit"}); err == nil {
+		t.Errorf("expected an error for an unknown engine")
+	}
+}
+
+func TestRunBannerAndPrompt(t *testing.T) {
+	var out bytes.Buffer
+	r, err := New(Options{Writer: &out, Banner: "welcome\n", Prompt: "monkey> "})
+	if err != nil {
+		t.Fatalf("creating the REPL failed: %s", err)
+	}
+	r.Run(strings.NewReader("1 + 1\n"))
+
+	expected := "welcome\nmonkey> 2\nmonkey> "
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRunQuiet(t *testing.T) {
+	var out bytes.Buffer
+	r, err := New(Options{Writer: &out, Banner: "welcome\n", Quiet: true})
+	if err != nil {
+		t.Fatalf("creating the REPL failed: %s", err)
+	}
+	r.Run(strings.NewReader("1 + 1\nputs(3)\n"))
+
+	expected := "2\n3\nnull\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	for _, engine := range []runner.Engine{runner.VM, runner.Eval} {
+		var out bytes.Buffer
+		r, err := New(Options{Writer: &out, Banner: "welcome\n", Engine: engine, JSON: true})
+		if err != nil {
+			t.Fatalf("%s: creating the REPL failed: %s", engine, err)
+		}
+		input := []string{
+			`let x = 1; puts(x); x + 1`,
+			`# nothing to print`,
+			`x + "a"`,
+			`let`,
+			`exit(3)`,
+			`x`,
+		}
+		r.Run(strings.NewReader(strings.Join(input, "\n")))
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		// the session ended at exit, without a banner or prompts
+		if len(lines) != 5 {
+			t.Fatalf("%s: wrong number of responses. want=5, got=%d:\n%s", engine, len(lines), out.String())
+		}
+		responses := make([]Response, len(lines))
+		for i, line := range lines {
+			if err := json.Unmarshal([]byte(line), &responses[i]); err != nil {
+				t.Fatalf("%s: response %d is not JSON: %s\n%s", engine, i, err, line)
+			}
+		}
+
+		if responses[0].Value != "2" || responses[0].Type != string(object.INTEGER_OBJ) || responses[0].Stdout != "1\n" {
+			t.Errorf("%s: wrong value, type or output: %+v", engine, responses[0])
+		}
+		for _, stage := range []string{"parse", "check", "run", "total"} {
+			if _, ok := responses[0].Durations[stage]; !ok {
+				t.Errorf("%s: missing the duration of %s: %v", engine, stage, responses[0].Durations)
+			}
+		}
+		if responses[1].Value != "" || responses[1].Type != "" || len(responses[1].Errors) != 0 {
+			t.Errorf("%s: expected no value and no errors: %+v", engine, responses[1])
+		}
+
+		failed := responses[2]
+		if len(failed.Warnings) != 1 || failed.Warnings[0].Code != "W301" || failed.Warnings[0].Column != 3 {
+			t.Errorf("%s: wrong warnings: %+v", engine, failed.Warnings)
+		}
+		if len(failed.Errors) != 1 || failed.Errors[0].Kind != "runtime error" || failed.Errors[0].Line != 1 || failed.Errors[0].Column != 3 {
+			t.Errorf("%s: wrong errors: %+v", engine, failed.Errors)
+		}
+
+		parsed := responses[3]
+		if len(parsed.Errors) != 1 || parsed.Errors[0].Kind != "parse error" || parsed.Errors[0].Code != "E002" {
+			t.Errorf("%s: wrong parse errors: %+v", engine, parsed.Errors)
+		}
+		if responses[4].Exit == nil || *responses[4].Exit != 3 {
+			t.Errorf("%s: wrong exit status: %+v", engine, responses[4])
+		}
+	}
+}
+
+// chunkWriter buffers what is written to it, every flush records the buffered output as a chunk
+type chunkWriter struct {
+	pending bytes.Buffer
+	chunks  []string
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) { return cw.pending.Write(p) }
+
+func (cw *chunkWriter) Flush() error {
+	if cw.pending.Len() > 0 {
+		cw.chunks = append(cw.chunks, cw.pending.String())
+		cw.pending.Reset()
+	}
+	return nil
+}
+
+func TestStreamingOutput(t *testing.T) {
+	for _, engine := range []runner.Engine{runner.VM, runner.Eval} {
+		out := &chunkWriter{}
+		r, err := New(Options{Writer: out, Engine: engine})
+		if err != nil {
+			t.Fatalf("%s: creating the REPL failed: %s", engine, err)
+		}
+		r.EvalLine(`for (i in range(3)) { print(i) }; puts("done")`)
+
+		// every call of print reached the writer on its own, while the input was running
+		expected := []string{"0", "1", "2", "done\n", "null", "\n"}
+		if strings.Join(out.chunks, "|") != strings.Join(expected, "|") {
+			t.Errorf("%s: wrong chunks. want=%q, got=%q", engine, expected, out.chunks)
+		}
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+	var out bytes.Buffer
+	r, err := New(Options{Writer: &out})
+	if err != nil {
+		t.Fatalf("creating the REPL failed: %s", err)
+	}
+
+	inputs := []struct {
+		line     string
+		expected string
+	}{
+		{"let x = 1;", ""},
+		{"x = x + 1", "2"},
+		// the same line runs the cached bytecode
+		{"x = x + 1", "3"},
+		// defining a name invalidates the cached lines, x could now be a new binding
+		{"let x = 10;", ""},
+		{"x = x + 1", "11"},
+		{"x = x + 1", "12"},
+	}
+	for _, tt := range inputs {
+		result, err := r.EvalLine(tt.line)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tt.line, err)
+		}
+		if tt.expected != "" && (result == nil || result.Inspect() != tt.expected) {
+			t.Errorf("wrong result for %q. want=%q, got=%v", tt.line, tt.expected, result)
+		}
+	}
+	if r.cache.hits != 2 || r.cache.misses != 4 {
+		t.Errorf("wrong cache counts. want=2 hits and 4 misses, got=%d and %d", r.cache.hits, r.cache.misses)
+	}
+
+	out.Reset()
+	r.EvalLine(":stats")
+	if !strings.Contains(out.String(), "compile cache: 3 lines, 2 hits, 4 misses\n") {
+		t.Errorf("cache stats not written. got=%q", out.String())
+	}
+
+	r.Reset()
+	if _, err := r.EvalLine("x = x + 1"); err == nil {
+		t.Errorf("cached line ran after the reset")
+	}
+}
@@ -0,0 +1,151 @@
+package repl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// Session is the state the REPL preserves between inputs: the constants of every compilation,
+// the global bindings and the symbol table resolving their names.
+// A session can be saved to disk and restored later with LoadSession.
+type Session struct {
+	Constants   []object.Object
+	Globals     []object.Object
+	SymbolTable *compiler.SymbolTable
+}
+
+// NewSession creates an empty session with the built-in functions and modules defined
+func NewSession() *Session {
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	for i, m := range object.Modules {
+		symbolTable.DefineModule(i, m.Name)
+	}
+
+	return &Session{
+		Constants:   []object.Object{},
+		Globals:     make([]object.Object, vm.GlobalsSize),
+		SymbolTable: symbolTable,
+	}
+}
+
+// savedSession is the JSON document a session is saved as, its values use the object encoding.
+// Closures refer to their compiled function by its index in the constants.
+type savedSession struct {
+	Version        int                     `json:"version"`
+	InstructionSet string                  `json:"instructionSet"`
+	Constants      []*object.EncodedObject `json:"constants"`
+	Globals        []savedGlobal           `json:"globals"`
+}
+
+// savedGlobal is a named global binding and the value in its slot, Constant is set for a binding made by const
+type savedGlobal struct {
+	Name     string                `json:"name"`
+	Index    int                   `json:"index"`
+	Constant bool                  `json:"constant,omitempty"`
+	Value    *object.EncodedObject `json:"value"`
+}
+
+// Save writes the session as JSON. Only the global bindings that are reachable by name are saved.
+func (s *Session) Save(w io.Writer) error {
+	saved := savedSession{
+		Version:        object.EncodingVersion,
+		InstructionSet: code.InstructionSet,
+		Constants:      []*object.EncodedObject{},
+		Globals:        []savedGlobal{},
+	}
+	encoder := &object.Encoder{Constants: s.Constants}
+
+	for _, constant := range s.Constants {
+		encoded, err := encoder.Encode(constant)
+		if err != nil {
+			return err
+		}
+		saved.Constants = append(saved.Constants, encoded)
+	}
+
+	for _, symbol := range s.SymbolTable.Globals() {
+		value := s.Globals[symbol.Index]
+		if value == nil {
+			continue
+		}
+		encoded, err := encoder.Encode(value)
+		if err != nil {
+			return fmt.Errorf("cannot save %s: %s", symbol.Name, err)
+		}
+		saved.Globals = append(saved.Globals, savedGlobal{Name: symbol.Name, Index: symbol.Index, Constant: symbol.Constant, Value: encoded})
+	}
+
+	return json.NewEncoder(w).Encode(saved)
+}
+
+// LoadSession reads a session previously written by Save
+func LoadSession(r io.Reader) (*Session, error) {
+	var saved savedSession
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("invalid session: %s", err)
+	}
+	if err := object.CheckCompatible(saved.Version, saved.InstructionSet); err != nil {
+		return nil, fmt.Errorf("invalid session: %s", err)
+	}
+
+	s := NewSession()
+	// constants are decoded in order, so closures can only refer to the functions before them
+	decoder := &object.Decoder{}
+	for _, constant := range saved.Constants {
+		decoded, err := decoder.Decode(constant)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session: %s", err)
+		}
+		s.Constants = append(s.Constants, decoded)
+		decoder.Constants = s.Constants
+	}
+
+	symbols := []compiler.Symbol{}
+	for _, global := range saved.Globals {
+		if global.Index < 0 || global.Index >= len(s.Globals) {
+			return nil, fmt.Errorf("invalid session: global %s has index %d out of range", global.Name, global.Index)
+		}
+		decoded, err := decoder.Decode(global.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session: %s", err)
+		}
+		s.Globals[global.Index] = decoded
+		symbols = append(symbols, compiler.Symbol{Name: global.Name, Index: global.Index, Constant: global.Constant})
+	}
+	s.SymbolTable.RestoreGlobals(symbols)
+
+	return s, nil
+}
+
+// saveSession writes the session to the file at path, replacing the file if it exists
+func saveSession(session *Session, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := session.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadSession restores a session from the file at path
+func loadSession(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSession(f)
+}
@@ -0,0 +1,120 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// run compiles and executes the input in the session and returns the last popped element
+func run(t *testing.T, session *Session, input string) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.NewWithState(session.SymbolTable, session.Constants)
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	code := comp.Bytecode()
+	session.Constants = code.Constants
+	machine := vm.NewWithGlobalStore(code, session.Globals)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	return machine.LastPoppedStackElem()
+}
+
+func TestSessionSaveLoad(t *testing.T) {
+	session := NewSession()
+	run(t, session, `
+	let x = 1;
+	let x = 2;
+	const limit = 3;
+	let adder = fn(a) { fn(b) { a + b } };
+	let addTen = adder(10);
+	let data = {"list": [1, "two", true], "len": len, "strings": strings};
+	`)
+
+	var saved bytes.Buffer
+	if err := session.Save(&saved); err != nil {
+		t.Fatalf("save failed: %s", err)
+	}
+
+	restored, err := LoadSession(&saved)
+	if err != nil {
+		t.Fatalf("load failed: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`x`, "2"},
+		{`addTen(5)`, "15"},
+		{`data["list"][1]`, "two"},
+		{`data["len"](data["list"])`, "3"},
+		{`data["strings"].upper("a")`, "A"},
+		{`let y = x + 1; y`, "3"},
+		{`x`, "2"},
+		{`limit`, "3"},
+	}
+
+	for _, tt := range tests {
+		result := run(t, restored, tt.input)
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	// a constant stays constant in the restored session
+	comp := compiler.NewWithState(restored.SymbolTable, restored.Constants)
+	err = comp.Compile(parser.New(lexer.New("limit = 4")).ParseProgram())
+	if err == nil || err.Error() != "cannot assign to constant limit" {
+		t.Errorf("wrong error for an assignment to a restored constant. got=%v", err)
+	}
+}
+
+func TestSessionSaveUnsupported(t *testing.T) {
+	session := NewSession()
+	run(t, session, `let numbers = range(3);`)
+
+	var saved bytes.Buffer
+	err := session.Save(&saved)
+	expected := "cannot save numbers: values of type ITERATOR cannot be encoded"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%v", expected, err)
+	}
+}
+
+func TestLoadSessionIncompatible(t *testing.T) {
+	session := NewSession()
+	run(t, session, `let add = fn(a, b) { a + b };`)
+
+	var saved bytes.Buffer
+	if err := session.Save(&saved); err != nil {
+		t.Fatalf("save failed: %s", err)
+	}
+	// a session saved by a version with other opcodes must not run its functions
+	foreign := strings.Replace(saved.String(), code.InstructionSet, "zzzzzzzz", 1)
+
+	_, err := LoadSession(strings.NewReader(foreign))
+	expected := fmt.Sprintf(`invalid session: bytecode compiled for instruction set "zzzzzzzz" cannot run on instruction set %q, compile it again`,
+		code.InstructionSet)
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%v", expected, err)
+	}
+}
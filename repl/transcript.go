@@ -0,0 +1,61 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// now returns the time transcript lines are stamped with, tests replace it with a fixed clock
+var now = time.Now
+
+// transcriptWriter writes every line it receives to w, starting with a timestamp and the prefix.
+// Inputs are prefixed with the prompt and outputs with spaces, so they line up in the transcript.
+type transcriptWriter struct {
+	w      io.Writer
+	prefix string
+	// partial holds the start of a line that has not been terminated yet
+	partial []byte
+}
+
+// Write stamps every complete line of p, a partial line is kept until its end is written
+func (t *transcriptWriter) Write(p []byte) (int, error) {
+	t.partial = append(t.partial, p...)
+	for {
+		end := bytes.IndexByte(t.partial, '\n')
+		if end == -1 {
+			return len(p), nil
+		}
+
+		line := t.partial[:end+1]
+		stamp := now().UTC().Format(time.RFC3339)
+		if _, err := io.WriteString(t.w, "["+stamp+"] "+t.prefix+string(line)); err != nil {
+			return 0, err
+		}
+		t.partial = t.partial[end+1:]
+	}
+}
+
+// saveScript writes the inputs as a script that can be run again, one input per line
+func saveScript(history []string, path string) error {
+	script := strings.Join(history, "\n")
+	if script != "" {
+		script += "\n"
+	}
+	return os.WriteFile(path, []byte(script), 0644)
+}
+
+// appendHistory appends the input to the history file at path, creating the file if it does not exist
+func appendHistory(path, line string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, line+"\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,179 @@
+// Package report renders the errors that stop a program, the same way for the REPL and the run command.
+// The compiler and both engines describe such an error with an Error: its kind, its message, where it happened,
+// the calls active at the time and, for a misspelled name, a suggestion picked from the names in scope.
+// Format turns it into the text shown to the user.
+package report
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// Kind tells which stage of running a program an error stopped
+type Kind int
+
+const (
+	Runtime Kind = iota
+	Compile
+)
+
+// String returns the name of the kind as it appears in formatted errors
+func (k Kind) String() string {
+	if k == Compile {
+		return "compile error"
+	}
+	return "runtime error"
+}
+
+// MainFunctionName names the main program in call traces
+const MainFunctionName = "<main>"
+
+// traceHeader starts the call trace of a formatted error, like the trace of object.RecursionDepthMessage
+const traceHeader = "call trace (most recent call last):"
+
+// Frame is a call that was active when the error happened. Line and Column locate the instruction the call
+// was at, they are 0 when it is unknown. Function is empty for the main program.
+type Frame struct {
+	Function string `json:"function,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// Error is an error that stopped a program. Line and Column locate the expression that failed, both count
+// from 1 and are 0 when the position is unknown. Trace holds the active calls from the main program to the
+// innermost call, it is empty when they are unknown. Suggestion is the name that was probably meant
+// when the error is about a name that is not defined.
+type Error struct {
+	Kind       Kind
+	Message    string
+	Line       int
+	Column     int
+	Trace      []Frame
+	Suggestion string
+}
+
+// Error returns the message alone, so wrapping an Error keeps the text the error had before it was located
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Format returns the text of the error for the user. An Error found in the chain of err is formatted as
+// "file:line:column: kind: message", followed by the suggestion and, when it happened inside a function,
+// the call trace listing the most recent object.TraceLimit calls. The file and the position are left out
+// when they are unknown. Any other error is formatted as "file: message".
+func Format(file string, err error) string {
+	var out strings.Builder
+
+	var located *Error
+	if !errors.As(err, &located) {
+		if file != "" {
+			out.WriteString(file + ": ")
+		}
+		out.WriteString(err.Error())
+		return out.String()
+	}
+
+	location := []string{}
+	if file != "" {
+		location = append(location, file)
+	}
+	if located.Line > 0 {
+		location = append(location, fmt.Sprintf("%d:%d", located.Line, located.Column))
+	}
+	if len(location) > 0 {
+		out.WriteString(strings.Join(location, ":") + ": ")
+	}
+	fmt.Fprintf(&out, "%s: %s", located.Kind, located.Message)
+	if located.Suggestion != "" {
+		fmt.Fprintf(&out, " — did you mean %s?", located.Suggestion)
+	}
+
+	// the message of a recursion error already lists the calls that led to it
+	trace := located.Trace
+	if len(trace) < 2 || strings.Contains(located.Message, traceHeader) {
+		return out.String()
+	}
+	out.WriteString("\n" + traceHeader)
+	if len(trace) > object.TraceLimit {
+		fmt.Fprintf(&out, "\n  ... %d earlier calls", len(trace)-object.TraceLimit)
+		trace = trace[len(trace)-object.TraceLimit:]
+	}
+	for i, frame := range trace {
+		name := frame.Function
+		if name == "" {
+			name = object.AnonymousFunctionName
+			if i == 0 && len(trace) == len(located.Trace) {
+				name = MainFunctionName
+			}
+		}
+		if frame.Line > 0 {
+			fmt.Fprintf(&out, "\n  %s at %d:%d", name, frame.Line, frame.Column)
+		} else {
+			fmt.Fprintf(&out, "\n  %s", name)
+		}
+	}
+	return out.String()
+}
+
+// Suggest returns the candidate closest to the misspelled name, by the number of characters to insert, delete
+// or replace to turn one into the other. It returns "" when no candidate is close enough to be what was meant:
+// a third of the name, rounded up, can be edited and never the whole name. Candidates at the same distance
+// are picked in alphabetical order.
+func Suggest(name string, candidates []string) string {
+	sorted := append([]string{}, candidates...)
+	sort.Strings(sorted)
+
+	limit := (len(name) + 2) / 3
+	if limit >= len(name) {
+		limit = len(name) - 1
+	}
+	best, bestDistance := "", limit+1
+	for _, candidate := range sorted {
+		if candidate == name {
+			continue
+		}
+		if d := distance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance of a and b, the number of single character
+// insertions, deletions and replacements turning a into b
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// previous holds the distances of the prefixes of s to the prefix of t one character shorter than current
+	previous := make([]int, len(s)+1)
+	current := make([]int, len(s)+1)
+	for i := range previous {
+		previous[i] = i
+	}
+	for j := 1; j <= len(t); j++ {
+		current[0] = j
+		for i := 1; i <= len(s); i++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[i] = min(previous[i]+1, current[i-1]+1, previous[i-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(s)]
+}
+
+// min returns the smallest of the values
+func min(values ...int) int {
+	smallest := values[0]
+	for _, v := range values[1:] {
+		if v < smallest {
+			smallest = v
+		}
+	}
+	return smallest
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"len", "puts", "print", "first", "rest", "counter", "x"}
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"lenn", "len"},
+		{"pritn", "print"},
+		{"counte", "counter"},
+		{"frist", "first"},
+		// a single character name is never a misspelling of another one
+		{"y", ""},
+		{"len", ""},
+		{"totally", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Suggest(tt.name, candidates); got != tt.expected {
+			t.Errorf("wrong suggestion for %q. want=%q, got=%q", tt.name, tt.expected, got)
+		}
+	}
+
+	// both candidates are one edit away, the first in alphabetical order is picked
+	if got := Suggest("cat", []string{"cut", "bat"}); got != "bat" {
+		t.Errorf("wrong suggestion for a tie. want=%q, got=%q", "bat", got)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"len", "lenn", 1},
+		{"größe", "grösse", 2},
+	}
+
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("wrong distance of %q and %q. want=%d, got=%d", tt.a, tt.b, tt.expected, got)
+		}
+		if got := distance(tt.b, tt.a); got != tt.expected {
+			t.Errorf("wrong distance of %q and %q. want=%d, got=%d", tt.b, tt.a, tt.expected, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	undefined := &Error{Kind: Compile, Message: "undefined variable: lenn", Line: 5, Column: 6, Suggestion: "len"}
+	inner := &Error{
+		Kind:    Runtime,
+		Message: "unknown operator: BOOLEAN + BOOLEAN",
+		Line:    2,
+		Column:  5,
+		Trace:   []Frame{{Line: 6, Column: 6}, {Function: "twice", Line: 4, Column: 25}, {Function: "add", Line: 2, Column: 5}},
+	}
+	deep := &Error{Kind: Runtime, Message: "stop", Trace: make([]Frame, 8)}
+	recursion := &Error{Kind: Runtime, Message: "maximum recursion depth\ncall trace (most recent call last):\n  f", Trace: make([]Frame, 3)}
+
+	tests := []struct {
+		file     string
+		err      error
+		expected string
+	}{
+		{"main.mk", undefined, "main.mk:5:6: compile error: undefined variable: lenn — did you mean len?"},
+		{"", undefined, "5:6: compile error: undefined variable: lenn — did you mean len?"},
+		{"main.mk", fmt.Errorf("compilation failed: %w", undefined), "main.mk:5:6: compile error: undefined variable: lenn — did you mean len?"},
+		{"", &Error{Kind: Runtime, Message: "stop"}, "runtime error: stop"},
+		{"main.mk", errors.New("interrupted"), "main.mk: interrupted"},
+		{"", inner, "2:5: runtime error: unknown operator: BOOLEAN + BOOLEAN\n" +
+			"call trace (most recent call last):\n  <main> at 6:6\n  twice at 4:25\n  add at 2:5"},
+		{"", deep, "runtime error: stop\ncall trace (most recent call last):\n  ... 3 earlier calls\n" +
+			"  <anonymous>\n  <anonymous>\n  <anonymous>\n  <anonymous>\n  <anonymous>"},
+		{"", recursion, "runtime error: " + recursion.Message},
+	}
+
+	for _, tt := range tests {
+		if got := Format(tt.file, tt.err); got != tt.expected {
+			t.Errorf("wrong format. want=\n%s\ngot=\n%s", tt.expected, got)
+		}
+	}
+}
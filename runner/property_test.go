@@ -0,0 +1,409 @@
+package runner
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The property tests generate random well-typed expressions of integers, booleans and strings together with
+// the value they must have, computed with math/big and Go semantics independently of the engines, and check
+// that both engines agree with it. The source of an expression only has the parentheses the precedences
+// below require, plus some redundant ones, so the parser's precedences and associativity are tested too.
+// A failing expression is shrunk to a smaller one that still fails before it is reported with the seed. The seed
+// is fixed so every run checks the same expressions, -property.seed=0 explores new ones.
+
+var (
+	propertySeed  = flag.Int64("property.seed", 1, "seed of the expressions generated by the property tests, 0 for the current time")
+	propertyCases = flag.Int("property.cases", 300, "number of expressions generated by each property test")
+)
+
+// kind is the type of a generated expression
+type kind int
+
+const (
+	intKind kind = iota
+	boolKind
+	stringKind
+)
+
+// the precedences of the operators, from the language reference rather than from the parser
+const (
+	precOr = iota + 1
+	precAnd
+	precEquals
+	precCompare
+	precBitOr
+	precBitXor
+	precBitAnd
+	precShift
+	precSum
+	precProduct
+	precPrefix
+	precAtom
+)
+
+var binaryPrecedences = map[string]int{
+	"||": precOr, "&&": precAnd,
+	"==": precEquals, "!=": precEquals,
+	"<": precCompare, ">": precCompare, "<=": precCompare, ">=": precCompare,
+	"|": precBitOr, "^": precBitXor, "&": precBitAnd,
+	"<<": precShift, ">>": precShift,
+	"+": precSum, "-": precSum,
+	"*": precProduct, "/": precProduct, "//": precProduct,
+}
+
+// the operators generated for the kinds of their operands and result
+var (
+	intOperators     = []string{"+", "-", "*", "/", "//", "&", "|", "^", "<<", ">>"}
+	compareOperators = []string{"<", ">", "<=", ">=", "==", "!="}
+	boolOperators    = []string{"==", "!=", "&&", "||"}
+)
+
+// interesting integer literals, the extremes make the arithmetic wrap around
+var intLiterals = []int64{0, 1, 2, 3, 7, 10, 255, 1 << 31, 1<<62 + 1, math.MaxInt64}
+
+var stringLiterals = []string{"", "a", "b", "ab", "monkey", "a b"}
+
+var (
+	two64     = new(big.Int).Lsh(big.NewInt(1), 64)
+	maxInt64  = big.NewInt(math.MaxInt64)
+	maxShifts = 70
+)
+
+// expr is a generated expression, its operator applied to its operands or a literal when op is empty
+type expr struct {
+	kind kind
+	op   string
+	args []*expr
+
+	src  string
+	prec int
+
+	integer *big.Int
+	boolean bool
+	str     string
+}
+
+// String returns the expected value the way the engines inspect it
+func (e *expr) String() string {
+	switch e.kind {
+	case intKind:
+		return e.integer.String()
+	case boolKind:
+		return strconv.FormatBool(e.boolean)
+	default:
+		return e.str
+	}
+}
+
+// wrap reduces x to an int64 the way Go arithmetic overflows, in two's complement
+func wrap(x *big.Int) *big.Int {
+	x = new(big.Int).Mod(x, two64)
+	if x.Cmp(maxInt64) > 0 {
+		x.Sub(x, two64)
+	}
+	return x
+}
+
+func intLiteral(v int64) *expr {
+	return &expr{kind: intKind, src: strconv.FormatInt(v, 10), prec: precAtom, integer: big.NewInt(v)}
+}
+
+func boolLiteral(v bool) *expr {
+	return &expr{kind: boolKind, src: strconv.FormatBool(v), prec: precAtom, boolean: v}
+}
+
+func stringLiteral(v string) *expr {
+	return &expr{kind: stringKind, src: strconv.Quote(v), prec: precAtom, str: v}
+}
+
+// parenthesize returns the source of the operand, in parentheses when it binds looser than needed
+func parenthesize(operand *expr, needed int) string {
+	if operand.prec < needed {
+		return "(" + operand.src + ")"
+	}
+	return operand.src
+}
+
+// build applies the operator to the operands and computes the expected value. It returns nil
+// when the result is not defined, like a division by zero or a shift by a negative count.
+func build(op string, args ...*expr) *expr {
+	e := &expr{op: op, args: args}
+
+	switch {
+	case op == "()":
+		e.kind, e.integer, e.boolean, e.str = args[0].kind, args[0].integer, args[0].boolean, args[0].str
+		e.src, e.prec = "("+args[0].src+")", precAtom
+		return e
+	case op == "len":
+		e.kind, e.integer = intKind, big.NewInt(int64(len(args[0].str)))
+		e.src, e.prec = "len("+args[0].src+")", precAtom
+		return e
+	case len(args) == 1:
+		return buildPrefix(e, args[0])
+	}
+
+	left, right := args[0], args[1]
+	prec := binaryPrecedences[op]
+	// every binary operator is left-associative
+	e.src = parenthesize(left, prec) + " " + op + " " + parenthesize(right, prec+1)
+	e.prec = prec
+
+	switch {
+	case left.kind == intKind && prec == precCompare, left.kind == intKind && prec == precEquals:
+		e.kind = boolKind
+		cmp := left.integer.Cmp(right.integer)
+		e.boolean = map[string]bool{"<": cmp < 0, ">": cmp > 0, "<=": cmp <= 0, ">=": cmp >= 0, "==": cmp == 0, "!=": cmp != 0}[op]
+	case left.kind == intKind:
+		e.kind = intKind
+		x, y := left.integer, right.integer
+		result := new(big.Int)
+		switch op {
+		case "+":
+			result.Add(x, y)
+		case "-":
+			result.Sub(x, y)
+		case "*":
+			result.Mul(x, y)
+		case "/", "//":
+			if y.Sign() == 0 {
+				return nil
+			}
+			// Quo truncates towards zero, floor division rounds down when the remainder is negative
+			var remainder big.Int
+			result.QuoRem(x, y, &remainder)
+			if op == "//" && remainder.Sign() != 0 && remainder.Sign() != y.Sign() {
+				result.Sub(result, big.NewInt(1))
+			}
+		case "&":
+			result.And(x, y)
+		case "|":
+			result.Or(x, y)
+		case "^":
+			result.Xor(x, y)
+		case "<<", ">>":
+			if y.Sign() < 0 || y.Cmp(big.NewInt(int64(maxShifts))) > 0 {
+				return nil
+			}
+			if op == "<<" {
+				result.Lsh(x, uint(y.Int64()))
+			} else {
+				result.Rsh(x, uint(y.Int64()))
+			}
+		}
+		e.integer = wrap(result)
+	case left.kind == boolKind:
+		e.kind = boolKind
+		a, b := left.boolean, right.boolean
+		e.boolean = map[string]bool{"==": a == b, "!=": a != b, "&&": a && b, "||": a || b}[op]
+	case op == "+":
+		e.kind, e.str = stringKind, left.str+right.str
+	default:
+		e.kind = boolKind
+		e.boolean = (left.str == right.str) == (op == "==")
+	}
+	return e
+}
+
+// buildPrefix applies a prefix operator to the operand of e
+func buildPrefix(e *expr, operand *expr) *expr {
+	e.kind, e.prec = operand.kind, precPrefix
+	e.src = e.op + parenthesize(operand, precPrefix)
+	if e.op == "-" && e.src[1] == '-' {
+		// keep the two minus signs apart
+		e.src = "- " + e.src[1:]
+	}
+
+	switch e.op {
+	case "-":
+		e.integer = wrap(new(big.Int).Neg(operand.integer))
+	case "~":
+		e.integer = new(big.Int).Not(operand.integer)
+	case "!":
+		e.boolean = !operand.boolean
+	}
+	return e
+}
+
+// generator makes random expressions
+type generator struct {
+	rand *rand.Rand
+}
+
+// generate returns an expression of the kind, at most depth operators deep
+func (g *generator) generate(k kind, depth int) *expr {
+	for {
+		if e := g.try(k, depth); e != nil {
+			return e
+		}
+	}
+}
+
+// try returns a random expression of the kind, or nil when it picked an undefined operation
+func (g *generator) try(k kind, depth int) *expr {
+	if depth == 0 || g.rand.Intn(4) == 0 {
+		switch k {
+		case intKind:
+			// small shift counts are more interesting than large ones
+			if g.rand.Intn(2) == 0 {
+				return intLiteral(int64(g.rand.Intn(maxShifts)))
+			}
+			return intLiteral(intLiterals[g.rand.Intn(len(intLiterals))])
+		case boolKind:
+			return boolLiteral(g.rand.Intn(2) == 0)
+		default:
+			return stringLiteral(stringLiterals[g.rand.Intn(len(stringLiterals))])
+		}
+	}
+	depth--
+
+	if g.rand.Intn(10) == 0 {
+		return build("()", g.generate(k, depth))
+	}
+	switch k {
+	case intKind:
+		switch g.rand.Intn(8) {
+		case 0:
+			return build([]string{"-", "~"}[g.rand.Intn(2)], g.generate(intKind, depth))
+		case 1:
+			return build("len", g.generate(stringKind, depth))
+		default:
+			op := intOperators[g.rand.Intn(len(intOperators))]
+			return build(op, g.generate(intKind, depth), g.generate(intKind, depth))
+		}
+	case boolKind:
+		switch g.rand.Intn(4) {
+		case 0:
+			return build("!", g.generate(boolKind, depth))
+		case 1:
+			op := compareOperators[g.rand.Intn(len(compareOperators))]
+			return build(op, g.generate(intKind, depth), g.generate(intKind, depth))
+		case 2:
+			op := []string{"==", "!="}[g.rand.Intn(2)]
+			return build(op, g.generate(stringKind, depth), g.generate(stringKind, depth))
+		default:
+			op := boolOperators[g.rand.Intn(len(boolOperators))]
+			return build(op, g.generate(boolKind, depth), g.generate(boolKind, depth))
+		}
+	default:
+		return build("+", g.generate(stringKind, depth), g.generate(stringKind, depth))
+	}
+}
+
+// shrink returns smaller expressions of the same kind: the operands of the expression,
+// and the expression with one of its operands shrunk
+func shrink(e *expr) []*expr {
+	var smaller []*expr
+	for _, arg := range e.args {
+		if arg.kind == e.kind {
+			smaller = append(smaller, arg)
+		}
+	}
+	for i, arg := range e.args {
+		for _, candidate := range shrink(arg) {
+			args := append([]*expr{}, e.args...)
+			args[i] = candidate
+			if shrunk := build(e.op, args...); shrunk != nil {
+				smaller = append(smaller, shrunk)
+			}
+		}
+	}
+	return smaller
+}
+
+// disagreement runs the expression with both engines and describes the first result that is not the expected value
+func disagreement(e *expr) string {
+	for _, engine := range []Engine{VM, Eval} {
+		result, err := Run(e.src, Options{Engine: engine})
+		if err != nil {
+			return fmt.Sprintf("%s: %s", engine, err)
+		}
+		if result == nil {
+			return fmt.Sprintf("%s: got no result", engine)
+		}
+		if result.Inspect() != e.String() {
+			return fmt.Sprintf("%s: got=%s", engine, result.Inspect())
+		}
+	}
+	return ""
+}
+
+// checkProperty generates expressions of the kind and checks that both engines compute their expected value
+func checkProperty(t *testing.T, k kind) {
+	seed := *propertySeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	g := &generator{rand: rand.New(rand.NewSource(seed))}
+
+	for i := 0; i < *propertyCases; i++ {
+		e := g.generate(k, 4)
+		problem := disagreement(e)
+		if problem == "" {
+			continue
+		}
+
+		// keep the smallest expression that still fails
+		for shrunk := true; shrunk; {
+			shrunk = false
+			for _, candidate := range shrink(e) {
+				if p := disagreement(candidate); p != "" {
+					e, problem, shrunk = candidate, p, true
+					break
+				}
+			}
+		}
+		t.Fatalf("%s\nwant=%s, %s\n(run with -property.seed=%d to reproduce)", e.src, e, problem, seed)
+	}
+}
+
+func TestIntegerProperties(t *testing.T) {
+	checkProperty(t, intKind)
+}
+
+func TestBooleanProperties(t *testing.T) {
+	checkProperty(t, boolKind)
+}
+
+func TestStringProperties(t *testing.T) {
+	checkProperty(t, stringKind)
+}
+
+func TestPropertyReference(t *testing.T) {
+	// the reference itself follows Go: overflow wraps around, / truncates and // floors
+	tests := []struct {
+		e        *expr
+		expected string
+		src      string
+	}{
+		{build("+", intLiteral(math.MaxInt64), intLiteral(1)), "-9223372036854775808", "9223372036854775807 + 1"},
+		{build("//", build("-", intLiteral(7)), intLiteral(2)), "-4", "-7 // 2"},
+		{build("/", build("-", intLiteral(7)), intLiteral(2)), "-3", "-7 / 2"},
+		{build("-", build("-", intLiteral(1)), build("-", intLiteral(2))), "1", "-1 - -2"},
+		{build("-", build("-", intLiteral(1))), "1", "- -1"},
+		{build("*", build("+", intLiteral(1), intLiteral(2)), intLiteral(3)), "9", "(1 + 2) * 3"},
+		{build("-", intLiteral(1), build("-", intLiteral(2), intLiteral(3))), "2", "1 - (2 - 3)"},
+		{build("&", intLiteral(6), build("<<", intLiteral(1), intLiteral(2))), "4", "6 & 1 << 2"},
+		{build(">>", build("-", intLiteral(16)), intLiteral(70)), "-1", "-16 >> 70"},
+		{build("~", intLiteral(0)), "-1", "~0"},
+		{build("==", stringLiteral("ab"), build("+", stringLiteral("a"), stringLiteral("b"))), "true", `"ab" == "a" + "b"`},
+	}
+
+	for _, tt := range tests {
+		if tt.e.String() != tt.expected || tt.e.src != tt.src {
+			t.Errorf("wrong reference. want=%s %s, got=%s %s", tt.src, tt.expected, tt.e.src, tt.e)
+		}
+		if problem := disagreement(tt.e); problem != "" {
+			t.Errorf("%s: %s", tt.src, problem)
+		}
+	}
+	if build("/", intLiteral(1), intLiteral(0)) != nil || build("<<", intLiteral(1), build("-", intLiteral(1))) != nil {
+		t.Errorf("undefined operations were built")
+	}
+}
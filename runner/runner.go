@@ -0,0 +1,281 @@
+// Package runner runs Monkey programs from files, with either the VM or the evaluator.
+// Before a program runs, its arguments and the environment are bound to the globals ARGV and ENV,
+// so scripts can read them the same way in both engines.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/cache"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/evaluator"
+	"github.com/yourfavoritedev/golang-interpreter/feature"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/stdlib"
+	"github.com/yourfavoritedev/golang-interpreter/typecheck"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// Engine selects how a program is executed
+type Engine string
+
+const (
+	// VM compiles the program to bytecode and runs it on the virtual machine, it is the default
+	VM Engine = "vm"
+	// Eval walks the AST of the program with the evaluator
+	Eval Engine = "eval"
+)
+
+// Options configures how a program is run
+type Options struct {
+	Engine   Engine
+	Features feature.Set
+	// Args are the program arguments bound to ARGV
+	Args []string
+	// Env holds the environment variables bound to ENV, as "key=value" strings like os.Environ returns them
+	Env []string
+	// Diagnostics receives the warnings of the parser and the compiler, they are dropped when nil
+	Diagnostics io.Writer
+	// Context stops the program when it is cancelled, the program runs to completion when it is nil
+	Context context.Context
+	// Strict turns behaviors silently producing null into errors: indexing an array out of range,
+	// reading a missing hash key and expression statements at file scope whose result is unused
+	Strict bool
+	// MaxCallDepth is the maximum number of nested function calls, 0 for the default of the engine,
+	// vm.MaxFrames or evaluator.MaxCallDepth. Exceeding it stops the program with an error.
+	MaxCallDepth int
+	// NoStdlib runs the program without the standard library, its names are free for the program
+	NoStdlib bool
+	// Cache keeps the compiled programs of the VM, so running the same source again skips parsing and compiling it.
+	// Programs are compiled every time when it is nil.
+	Cache *cache.Cache
+}
+
+// Global is a value bound to a name before the program runs
+type Global struct {
+	Name  string
+	Value object.Object
+}
+
+// Globals returns the globals bound before a program runs, in the order they are defined:
+// ARGV, an array of the argument strings, and ENV, a hash of the environment variables.
+func Globals(args []string, env []string) []Global {
+	argv := &object.Array{Elements: make([]object.Object, len(args))}
+	for i, arg := range args {
+		argv.Elements[i] = &object.String{Value: arg}
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	for _, variable := range env {
+		name, value, ok := cut(variable, "=")
+		if !ok {
+			continue
+		}
+		key := &object.String{Value: name}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: &object.String{Value: value}}
+	}
+
+	return []Global{
+		{Name: "ARGV", Value: argv},
+		{Name: "ENV", Value: &object.Hash{Pairs: pairs}},
+	}
+}
+
+// NewSymbolTable returns a symbol table defining the built-in functions and modules, the standard library
+// when it is not nil and the globals, and the global store of the VM holding the values of the globals.
+// A program compiled with the symbol table starts from the Constants of the library.
+func NewSymbolTable(library *stdlib.Library, globals []Global) (*compiler.SymbolTable, []object.Object) {
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	for i, m := range object.Modules {
+		symbolTable.DefineModule(i, m.Name)
+	}
+	store := make([]object.Object, vm.GlobalsSize)
+	// the functions of the library read its globals at the indices they were compiled with, the first ones
+	library.Define(symbolTable, store)
+	for _, global := range globals {
+		symbol := symbolTable.Define(global.Name)
+		store[symbol.Index] = global.Value
+	}
+	return symbolTable, store
+}
+
+// RunFile reads the program in the file and runs it, see Run
+func RunFile(path string, options Options) (object.Object, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Run(string(source), options)
+}
+
+// Run parses the program and runs it with the engine of the options, the VM takes the compiled program from
+// the cache of the options when it has one.
+// It returns the value of the last expression statement, or the error that stopped the program. The errors of
+// the compiler and of either engine wrap a *report.Error, report.Format renders them with their position.
+// A program ended by the exit built-in function returns a *vm.ExitError with its exit status, whatever the engine.
+func Run(source string, options Options) (object.Object, error) {
+	globals := Globals(options.Args, options.Env)
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	switch options.Engine {
+	case Eval:
+		program, diagnostics, err := Parse(source, options)
+		if err != nil {
+			return nil, err
+		}
+		if options.Diagnostics != nil {
+			diagnostic.Render(options.Diagnostics, diagnostics)
+		}
+
+		env := object.NewEnvironment()
+		if !options.NoStdlib {
+			stdlib.Eval(env)
+		}
+		for _, global := range globals {
+			env.Set(global.Name, global.Value)
+		}
+		result := evaluator.EvalWith(ctx, program, env, evaluator.Options{Strict: options.Strict, MaxCallDepth: options.MaxCallDepth})
+		if errObj, ok := result.(*object.Error); ok {
+			if errObj.Exit {
+				return nil, &vm.ExitError{Code: errObj.Code}
+			}
+			return nil, evaluator.Report(errObj)
+		}
+		return result, nil
+
+	case VM, "":
+		library := Stdlib(options)
+		symbolTable, store := NewSymbolTable(library, globals)
+		compiled, err := compile(source, options, library, symbolTable)
+		if err != nil {
+			return nil, err
+		}
+		if options.Diagnostics != nil {
+			diagnostic.Render(options.Diagnostics, compiled.Diagnostics)
+		}
+
+		machine := vm.NewWithGlobalStore(compiled.Bytecode, store)
+		machine.SetStrict(options.Strict)
+		if options.MaxCallDepth > 0 {
+			machine.SetMaxFrames(options.MaxCallDepth)
+		}
+		if err := machine.RunContext(ctx); err != nil {
+			return nil, machine.Report(err)
+		}
+		return machine.LastPoppedStackElem(), nil
+
+	default:
+		return nil, fmt.Errorf("unknown engine %q, want %q or %q", options.Engine, VM, Eval)
+	}
+}
+
+// compile parses and compiles the program for the VM with the symbol table, or loads it from the cache of the options.
+// A program compiled without errors is stored in the cache, failing to store it does not keep the program from running.
+func compile(source string, options Options, library *stdlib.Library, symbolTable *compiler.SymbolTable) (*cache.Program, error) {
+	var key string
+	if options.Cache != nil {
+		key = CacheKey(options.Cache, source, options)
+		if compiled, ok := options.Cache.Load(key, library.Constants()); ok {
+			return compiled, nil
+		}
+	}
+
+	program, diagnostics, err := Parse(source, options)
+	if err != nil {
+		return nil, err
+	}
+	comp := compiler.NewWithState(symbolTable, library.Constants())
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("compilation failed: %w", err)
+	}
+	compiled := &cache.Program{Bytecode: comp.Bytecode(), Diagnostics: append(diagnostics, comp.Diagnostics()...)}
+
+	if options.Cache != nil {
+		options.Cache.Store(key, compiled, library.Constants())
+	}
+	return compiled, nil
+}
+
+// CacheKey returns the key of the source in the cache, the options changing the compiled program are part of it:
+// the features, strict mode and the standard library. The arguments and the environment are not, since the
+// compiled program reads them from the globals.
+func CacheKey(c *cache.Cache, source string, options Options) string {
+	features := []string{}
+	for name, enabled := range options.Features {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	return c.Key(source,
+		"features="+strings.Join(features, ","),
+		fmt.Sprintf("strict=%t", options.Strict),
+		fmt.Sprintf("stdlib=%t", !options.NoStdlib),
+	)
+}
+
+// Stdlib returns the standard library the options run programs with, nil when it is disabled
+func Stdlib(options Options) *stdlib.Library {
+	if options.NoStdlib {
+		return nil
+	}
+	return stdlib.Load()
+}
+
+// Parse parses the program with the features of the options and checks it before it runs, with either engine:
+// its type annotations and, in strict mode, its unused results. It returns the warnings of the parser and the
+// type checker, or an error listing the errors that keep the program from running.
+func Parse(source string, options Options) (*ast.Program, diagnostic.Diagnostics, error) {
+	p := parser.New(lexer.New(source))
+	p.SetFeatures(options.Features)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, nil, fmt.Errorf("parser errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+	diagnostics := p.Diagnostics().Warnings()
+
+	types := typecheck.Check(program)
+	if errors := types.Errors(); len(errors) != 0 {
+		return nil, nil, fmt.Errorf("type errors:\n\t%s", strings.Join(messages(errors), "\n\t"))
+	}
+	diagnostics = append(diagnostics, types.Warnings()...)
+
+	if options.Strict {
+		if errors := UnusedResults(program); len(errors) != 0 {
+			return nil, nil, fmt.Errorf("strict mode errors:\n\t%s", strings.Join(messages(errors), "\n\t"))
+		}
+	}
+	return program, diagnostics, nil
+}
+
+// messages returns the located messages of the diagnostics
+func messages(diagnostics diagnostic.Diagnostics) []string {
+	messages := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		messages[i] = d.String()
+	}
+	return messages
+}
+
+// cut slices s around the first instance of sep, it is strings.Cut which needs a newer Go than this module targets
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
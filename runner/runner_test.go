@@ -0,0 +1,277 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/cache"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+func TestProgramArguments(t *testing.T) {
+	input := `ARGV[1] + ":" + ENV["GREETING"] + ":" + ENV["EMPTY"]`
+
+	for _, engine := range []Engine{VM, Eval} {
+		options := Options{
+			Engine: engine,
+			Args:   []string{"first", "second"},
+			Env:    []string{"GREETING=hello=world", "EMPTY=", "MALFORMED"},
+		}
+		result, err := Run(input, options)
+		if err != nil {
+			t.Fatalf("%s: run failed: %s", engine, err)
+		}
+		str, ok := result.(*object.String)
+		if !ok || str.Value != "second:hello=world:" {
+			t.Errorf("%s: wrong result. got=%v", engine, result)
+		}
+
+		result, err = Run(`len(ARGV)`, Options{Engine: engine})
+		if err != nil {
+			t.Fatalf("%s: run failed: %s", engine, err)
+		}
+		if integer, ok := result.(*object.Integer); !ok || integer.Value != 0 {
+			t.Errorf("%s: wrong number of arguments. got=%v", engine, result)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		engine   Engine
+		input    string
+		expected string
+	}{
+		{VM, `1 + "a"`, "unsupported types for binary operation: INTEGER, STRING"},
+		{Eval, `1 + "a"`, "type mismatch: INTEGER + STRING"},
+		{VM, `let = 1`, "parser errors:\n\texpected next token to be IDENT, got = instead\n\tno prefix parse function for = found"},
+		{"jit", `1`, `unknown engine "jit", want "vm" or "eval"`},
+		{Eval, `let x: int = "a"; puts("unreachable")`, "type errors:\n\t1:5: error[E302]: cannot use string value as int in let x"},
+	}
+
+	for _, tt := range tests {
+		_, err := Run(tt.input, Options{Engine: tt.engine})
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. want=%q, got=%v", tt.engine, tt.expected, err)
+		}
+	}
+}
+
+func TestBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len(1); puts("after")`, "argument to `len` not supported, got=INTEGER"},
+		{`puts(first(1))`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`let f = fn(x) { len(x) }; f(1); puts("after")`, "argument to `len` not supported, got=INTEGER"},
+	}
+
+	defer func(writer io.Writer) { object.Output.Writer = writer }(object.Output.Writer)
+	for _, engine := range []Engine{VM, Eval} {
+		for _, tt := range tests {
+			var out bytes.Buffer
+			object.Output.Writer = &out
+			_, err := Run(tt.input, Options{Engine: engine})
+			// the error of a built-in function stops the program before anything else is printed
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("%s: wrong error for %q. want=%q, got=%v", engine, tt.input, tt.expected, err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("%s: %q printed %q", engine, tt.input, out.String())
+			}
+		}
+	}
+}
+
+func TestRunErrorReports(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nlet twice = fn(f, x) { f(x, x) };\ntwice(add, true);"
+	for _, engine := range []Engine{VM, Eval} {
+		// both engines locate the failing addition inside the two calls
+		_, err := Run(input, Options{Engine: engine})
+		var located *report.Error
+		if !errors.As(err, &located) {
+			t.Fatalf("%s: error is not a *report.Error. got=%T (%v)", engine, err, err)
+		}
+		if located.Kind != report.Runtime || located.Line != 2 || located.Column != 5 || len(located.Trace) != 3 {
+			t.Errorf("%s: wrong report. got=%+v", engine, located)
+		}
+
+		// the VM finds the misspelled name while compiling, the evaluator when it is evaluated
+		_, err = Run(strings.Replace(input, "twice(add, true);", "puts(lenn(1));", 1), Options{Engine: engine})
+		expected := map[Engine]string{
+			VM:   "5:6: compile error: undefined variable: lenn — did you mean len?",
+			Eval: "5:6: runtime error: identifier not found: lenn — did you mean len?",
+		}[engine]
+		if got := report.Format("", err); got != expected {
+			t.Errorf("%s: wrong formatted error. want=%q, got=%q", engine, expected, got)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1, 2]; puts(a[2])`, "index 2 out of range for array of length 2"},
+		{`let a = [1, 2]; puts(a[-3])`, "index -3 out of range for array of length 2"},
+		{`let h = {"a": 1}; puts(h["b"])`, `missing hash key "b"`},
+		{`let a = [1, 2]; puts(a[1:3])`, "slice bounds [1:3] out of range for array of length 2"},
+		{`puts("ab"[-3:])`, "slice bounds [-3:2] out of range for string of length 2"},
+		{`let h = {"a": 1}; puts(h.b)`, `missing hash key "b"`},
+		{`let a = 1; a + 1; puts(a)`, "strict mode errors:\n\t1:12: error[E401]: result of expression statement is unused"},
+	}
+
+	for _, engine := range []Engine{VM, Eval} {
+		for _, tt := range tests {
+			_, err := Run(tt.input, Options{Engine: engine, Strict: true})
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("%s: wrong error for %q. want=%q, got=%v", engine, tt.input, tt.expected, err)
+			}
+			// without strict mode the same programs run
+			if _, err := Run(tt.input, Options{Engine: engine}); err != nil {
+				t.Errorf("%s: %q failed without strict mode: %s", engine, tt.input, err)
+			}
+		}
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	input := `let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(3000)`
+	for _, engine := range []Engine{VM, Eval} {
+		_, err := Run(input, Options{Engine: engine})
+		if err == nil || !strings.HasPrefix(err.Error(), "maximum recursion depth (1024) exceeded in function sum") {
+			t.Errorf("%s: expected the recursion depth error. got=%v", engine, err)
+		}
+
+		result, err := Run(input, Options{Engine: engine, MaxCallDepth: 5000})
+		if err != nil {
+			t.Fatalf("%s: run failed: %s", engine, err)
+		}
+		if integer, ok := result.(*object.Integer); !ok || integer.Value != 4501500 {
+			t.Errorf("%s: wrong result. got=%v", engine, result)
+		}
+	}
+}
+
+func TestStdlib(t *testing.T) {
+	for _, engine := range []Engine{VM, Eval} {
+		result, err := Run(`let xs = reverse([1, 2, 3]); sum(xs) * xs[0]`, Options{Engine: engine})
+		if err != nil {
+			t.Fatalf("%s: run failed: %s", engine, err)
+		}
+		if integer, ok := result.(*object.Integer); !ok || integer.Value != 18 {
+			t.Errorf("%s: wrong result. got=%v", engine, result)
+		}
+
+		// without the standard library its names are free
+		if _, err := Run(`sum([1, 2])`, Options{Engine: engine, NoStdlib: true}); err == nil {
+			t.Errorf("%s: sum is defined without the standard library", engine)
+		}
+		result, err = Run(`let sum = fn(a, b) { a + b }; sum(1, 2)`, Options{Engine: engine, NoStdlib: true})
+		if err != nil {
+			t.Fatalf("%s: run failed: %s", engine, err)
+		}
+		if integer, ok := result.(*object.Integer); !ok || integer.Value != 3 {
+			t.Errorf("%s: wrong result without the standard library. got=%v", engine, result)
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.monkey")
+	if err := os.WriteFile(path, []byte(`ARGV[0]`), 0644); err != nil {
+		t.Fatalf("writing script failed: %s", err)
+	}
+
+	result, err := RunFile(path, Options{Args: []string{"arg"}})
+	if err != nil {
+		t.Fatalf("run failed: %s", err)
+	}
+	if result.Inspect() != "arg" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	c := &cache.Cache{Dir: t.TempDir()}
+	input := `let len = fn(xs) { reduce(fn(total, x) { total + 1 }, 0, xs) }; len(ARGV)`
+
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		var diagnostics bytes.Buffer
+		result, err := Run(input, Options{Args: []string{"a", "b"}, Diagnostics: &diagnostics, Cache: c})
+		if err != nil {
+			t.Fatalf("run %d failed: %s", i, err)
+		}
+		if integer, ok := result.(*object.Integer); !ok || integer.Value != 2 {
+			t.Errorf("run %d: wrong result. got=%v", i, result)
+		}
+		outputs = append(outputs, diagnostics.String())
+	}
+	// the warnings of the compiler are reported again when the program is loaded from the cache
+	if outputs[0] == "" || outputs[1] != outputs[0] {
+		t.Errorf("wrong diagnostics of the cached program. want=%q, got=%q", outputs[0], outputs[1])
+	}
+
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		t.Fatalf("reading the cache failed: %s", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("wrong number of cached programs. want=1, got=%d", len(entries))
+	}
+
+	// a program compiled with other options is cached separately, one that fails to compile is not cached
+	for _, options := range []Options{{Cache: c}, {Strict: true, Cache: c}, {NoStdlib: true, Cache: c}} {
+		if _, err := Run(`let answer = 1;`, options); err != nil {
+			t.Fatalf("run failed: %s", err)
+		}
+	}
+	if _, err := Run(input, Options{NoStdlib: true, Cache: c}); err == nil {
+		t.Errorf("expected an error without the standard library")
+	}
+	if entries, _ := os.ReadDir(c.Dir); len(entries) != 4 {
+		t.Errorf("wrong number of cached programs. want=4, got=%d", len(entries))
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{`exit(); 1`, 0},
+		{`let f = fn() { exit(3); 1 }; f(); 2`, 3},
+		{`map(fn(x) { if (x == 2) { exit(4) } else { x } }, [1, 2, 3]); 1`, 4},
+		// exiting is not an error that try captures
+		{`try(fn() { exit(5) }); 1`, 5},
+	}
+
+	for _, engine := range []Engine{VM, Eval} {
+		for _, tt := range tests {
+			_, err := Run(tt.input, Options{Engine: engine})
+			exit, ok := err.(*vm.ExitError)
+			if !ok {
+				t.Errorf("%s: %s: expected exit. got=%v", engine, tt.input, err)
+				continue
+			}
+			if exit.Code != tt.expected {
+				t.Errorf("%s: %s: wrong exit status. want=%d, got=%d", engine, tt.input, tt.expected, exit.Code)
+			}
+		}
+
+		_, err := Run(`exit(256)`, Options{Engine: engine})
+		if _, ok := err.(*vm.ExitError); ok {
+			t.Errorf("%s: exit with an invalid status must not exit", engine)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package runner
+
+import (
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+)
+
+// UnusedResult is reported in strict mode for an expression statement at file scope whose value is discarded
+const UnusedResult = "E401"
+
+// UnusedResults returns an error for every expression statement at file scope whose value is never used.
+// Calls, assignments, if expressions and loops are run for their effects, any other expression statement computes a value
+// that is thrown away, which is usually a mistake like a forgotten let or puts.
+func UnusedResults(program *ast.Program) diagnostic.Diagnostics {
+	var diagnostics diagnostic.Diagnostics
+	for _, stmt := range program.Statements {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok || es.Expression == nil {
+			continue
+		}
+		switch es.Expression.(type) {
+		case *ast.CallExpression, *ast.AssignExpression, *ast.IfExpression, *ast.ConditionalExpression,
+			*ast.SwitchExpression, *ast.WhileExpression, *ast.ForExpression:
+			continue
+		}
+		diagnostics = append(diagnostics, diagnostic.New(diagnostic.Error, es.Token, UnusedResult,
+			"result of expression statement is unused"))
+	}
+	return diagnostics
+}
@@ -0,0 +1,31 @@
+//go:build ignore
+// +build ignore
+
+// generate compiles the standard library and writes it to bundle.json, it is run by `go generate ./stdlib`
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yourfavoritedev/golang-interpreter/stdlib"
+)
+
+func main() {
+	library, err := stdlib.Compile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	f, err := os.Create("bundle.json")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	if err := library.Encode(f); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
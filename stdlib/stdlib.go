@@ -0,0 +1,234 @@
+// Package stdlib is the standard library written in Monkey: helpers for arrays, strings and numbers that every
+// program can use without defining them first. The library ships inside the binary compiled to bytecode,
+// bundle.json is generated from stdlib.monkey by `go generate ./stdlib`, so loading it at startup decodes the
+// compiled functions instead of parsing and compiling the source. The evaluator walks the AST, it evaluates
+// the source instead.
+package stdlib
+
+//go:generate go run generate.go
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/evaluator"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+// Source is the Monkey source of the standard library
+//
+//go:embed stdlib.monkey
+var Source string
+
+// bundle is the standard library compiled by Compile and written by Encode
+//
+//go:embed bundle.json
+var bundle []byte
+
+// Library is the compiled standard library: the constants its functions refer to and its global bindings.
+// The functions read the globals of the library by their index, so the library is defined in a symbol table
+// before any other global, which gives its globals the indices they were compiled with.
+type Library struct {
+	constants []object.Object
+	globals   []Global
+}
+
+// Global is a binding of the standard library and the index of its slot in the global store
+type Global struct {
+	Name  string
+	Index int
+	Value object.Object
+}
+
+// encodedLibrary is the JSON document a library is encoded as, its values use the object encoding.
+// Closures refer to their compiled function by its index in the constants.
+type encodedLibrary struct {
+	Version        int                     `json:"version"`
+	InstructionSet string                  `json:"instructionSet"`
+	Constants      []*object.EncodedObject `json:"constants"`
+	Globals        []encodedGlobal         `json:"globals"`
+}
+
+// encodedGlobal is a named global binding and the value in its slot
+type encodedGlobal struct {
+	Name  string                `json:"name"`
+	Index int                   `json:"index"`
+	Value *object.EncodedObject `json:"value"`
+}
+
+// Compile compiles the source of the standard library and runs it on a VM, which binds its globals.
+// The symbol table only defines the built-in functions and modules, the globals of the library start at index 0.
+func Compile() (*Library, error) {
+	p := parser.New(lexer.New(Source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser errors: %s", strings.Join(p.Errors(), ", "))
+	}
+
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	for i, m := range object.Modules {
+		symbolTable.DefineModule(i, m.Name)
+	}
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("compilation failed: %s", err)
+	}
+
+	bytecode := comp.Bytecode()
+	store := make([]object.Object, vm.GlobalsSize)
+	if err := vm.NewWithGlobalStore(bytecode, store).Run(); err != nil {
+		return nil, fmt.Errorf("executing bytecode failed: %s", err)
+	}
+
+	library := &Library{constants: bytecode.Constants}
+	for _, symbol := range symbolTable.Globals() {
+		library.globals = append(library.globals, Global{Name: symbol.Name, Index: symbol.Index, Value: store[symbol.Index]})
+	}
+	return library, nil
+}
+
+// Encode writes the library as JSON, the format of bundle.json
+func (l *Library) Encode(w io.Writer) error {
+	encoded := encodedLibrary{
+		Version:        object.EncodingVersion,
+		InstructionSet: code.InstructionSet,
+		Constants:      []*object.EncodedObject{},
+		Globals:        []encodedGlobal{},
+	}
+	encoder := &object.Encoder{Constants: l.constants}
+
+	for _, constant := range l.constants {
+		value, err := encoder.Encode(constant)
+		if err != nil {
+			return err
+		}
+		encoded.Constants = append(encoded.Constants, value)
+	}
+	for _, global := range l.globals {
+		value, err := encoder.Encode(global.Value)
+		if err != nil {
+			return fmt.Errorf("cannot encode %s: %s", global.Name, err)
+		}
+		encoded.Globals = append(encoded.Globals, encodedGlobal{Name: global.Name, Index: global.Index, Value: value})
+	}
+
+	out, err := json.MarshalIndent(encoded, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+// Decode reads a library written by Encode
+func Decode(r io.Reader) (*Library, error) {
+	var encoded encodedLibrary
+	if err := json.NewDecoder(r).Decode(&encoded); err != nil {
+		return nil, fmt.Errorf("invalid library: %s", err)
+	}
+	if err := object.CheckCompatible(encoded.Version, encoded.InstructionSet); err != nil {
+		return nil, fmt.Errorf("invalid library: %s", err)
+	}
+
+	library := &Library{}
+	// constants are decoded in order, so closures can only refer to the functions before them
+	decoder := &object.Decoder{}
+	for _, constant := range encoded.Constants {
+		decoded, err := decoder.Decode(constant)
+		if err != nil {
+			return nil, fmt.Errorf("invalid library: %s", err)
+		}
+		// like the constants of a compiler, the decoded ones are shared by every program and must not change
+		library.constants = append(library.constants, object.Freeze(decoded))
+		decoder.Constants = library.constants
+	}
+	for _, global := range encoded.Globals {
+		if global.Index < 0 || global.Index >= vm.GlobalsSize {
+			return nil, fmt.Errorf("invalid library: global %s has index %d out of range", global.Name, global.Index)
+		}
+		decoded, err := decoder.Decode(global.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid library: %s", err)
+		}
+		library.globals = append(library.globals, Global{Name: global.Name, Index: global.Index, Value: decoded})
+	}
+	return library, nil
+}
+
+var (
+	loadOnce sync.Once
+	loaded   *Library
+)
+
+// Load returns the standard library decoded from the bundle embedded in the binary, it is decoded once.
+// The bundle is generated and checked by the tests, it panics when the bundle does not decode.
+func Load() *Library {
+	loadOnce.Do(func() {
+		library, err := Decode(strings.NewReader(string(bundle)))
+		if err != nil {
+			panic(fmt.Sprintf("stdlib: bundle.json: %s", err))
+		}
+		loaded = library
+	})
+	return loaded
+}
+
+// Constants returns a copy of the constants of the library, a compiler appends the constants of the program to it.
+// A nil library has no constants, so programs compiled without the standard library start from an empty pool.
+func (l *Library) Constants() []object.Object {
+	if l == nil {
+		return []object.Object{}
+	}
+	return append([]object.Object{}, l.constants...)
+}
+
+// Globals returns the bindings of the library
+func (l *Library) Globals() []Global {
+	return l.globals
+}
+
+// Define binds the globals of the library in the symbol table and in the global store of a VM.
+// It must be called before the symbol table defines any global, and the program must be compiled
+// with the Constants of the library. A nil library defines nothing.
+func (l *Library) Define(symbolTable *compiler.SymbolTable, store []object.Object) {
+	if l == nil {
+		return
+	}
+	symbols := make([]compiler.Symbol, len(l.globals))
+	for i, global := range l.globals {
+		symbols[i] = compiler.Symbol{Name: global.Name, Index: global.Index}
+		store[global.Index] = global.Value
+	}
+	symbolTable.RestoreGlobals(symbols)
+}
+
+// Eval binds the functions of the standard library in the environment by evaluating its source.
+// The source is evaluated in an environment of its own, like the compiled functions reading their own
+// globals, so a program binding one of the names again does not change the functions using it.
+// The source is checked by the tests, it panics when the evaluation fails.
+func Eval(env *object.Environment) {
+	program := parser.New(lexer.New(Source)).ParseProgram()
+	library := object.NewEnvironment()
+	if errObj, ok := evaluator.Eval(program, library).(*object.Error); ok {
+		panic(fmt.Sprintf("stdlib: stdlib.monkey: %s", errObj.Message))
+	}
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok {
+			value, _ := library.Get(let.Name.Value)
+			env.Set(let.Name.Value, value)
+		}
+	}
+}
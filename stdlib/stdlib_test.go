@@ -0,0 +1,120 @@
+package stdlib
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/evaluator"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/vm"
+)
+
+func TestBundleUpToDate(t *testing.T) {
+	library, err := Compile()
+	if err != nil {
+		t.Fatalf("compiling the standard library failed: %s", err)
+	}
+	var out bytes.Buffer
+	if err := library.Encode(&out); err != nil {
+		t.Fatalf("encoding the standard library failed: %s", err)
+	}
+	if out.String() != string(bundle) {
+		t.Fatalf("bundle.json is out of date with stdlib.monkey, run go generate ./stdlib")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"version": 0}`, "invalid library: unsupported encoding version 0, expected 3"},
+		{`{"version": 3, "instructionSet": "zzzzzzzz"}`, fmt.Sprintf(
+			`invalid library: bytecode compiled for instruction set "zzzzzzzz" cannot run on instruction set %q, compile it again`, code.InstructionSet)},
+		{fmt.Sprintf(`{"version": 3, "instructionSet": %q, "globals": [{"name": "x", "index": -1}]}`, code.InstructionSet),
+			"invalid library: global x has index -1 out of range"},
+		{`[`, "invalid library: unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(strings.NewReader(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`reduce(fn(acc, x) { acc + x }, 10, [1, 2, 3])`, "16"},
+		{`sum([1, 2, 3])`, "6"},
+		{`sum([])`, "0"},
+		{`product([2, 3, 4])`, "24"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`any(fn(x) { x > 2 }, [1, 2, 3])`, "true"},
+		{`all(fn(x) { x > 2 }, [1, 2, 3])`, "false"},
+		{`all(fn(x) { x > 2 }, [])`, "true"},
+		{`count(fn(x) { x > 1 }, [1, 2, 3])`, "2"},
+		{`index_of([1, 2, 3], 3)`, "2"},
+		{`index_of([1, 2, 3], 4)`, "-1"},
+		{`includes(["a", "b"], "b")`, "true"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`flatten([[1], [], [2, 3]])`, "[1, 2, 3]"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`pad_left("7", 3, "0")`, "007"},
+		{`clamp(15, 0, 10)`, "10"},
+		{`sign(-4)`, "-1"},
+		// a program binding a name of the library again does not change the functions using it
+		{`let reduce = 1; sum([1, 2]) + reduce`, "4"},
+	}
+
+	for _, tt := range tests {
+		if got := runVM(t, tt.input).Inspect(); got != tt.expected {
+			t.Errorf("vm: wrong result for %s. want=%s, got=%s", tt.input, tt.expected, got)
+		}
+		env := object.NewEnvironment()
+		Eval(env)
+		if got := evaluator.Eval(parse(t, tt.input), env).Inspect(); got != tt.expected {
+			t.Errorf("eval: wrong result for %s. want=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// runVM compiles the program with the library loaded from the bundle and runs it
+func runVM(t *testing.T, input string) object.Object {
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	store := make([]object.Object, vm.GlobalsSize)
+	Load().Define(symbolTable, store)
+
+	comp := compiler.NewWithState(symbolTable, Load().Constants())
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := vm.NewWithGlobalStore(comp.Bytecode(), store)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	return machine.LastPoppedStackElem()
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
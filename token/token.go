@@ -2,9 +2,13 @@ package token
 
 type TokenType string
 
+// Token is a single lexical unit produced by the lexer. Line and Column
+// record where the token starts in the input, both counting from 1.
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
 }
 
 const (
@@ -14,6 +18,7 @@ const (
 	// Identifiers + literals
 	IDENT = "IDENT" // add, foobar, x, y, ...
 	INT   = "INT"   // 123456
+	FLOAT = "FLOAT" // 1.5
 
 	// Operators
 	ASSIGN   = "="
@@ -22,14 +27,37 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
-	LT       = "<"
-	GT       = ">"
-	EQ       = "=="
-	NOT_EQ   = "!="
+	// FLOOR_SLASH is integer division rounding towards negative infinity
+	FLOOR_SLASH = "//"
+	LT          = "<"
+	GT          = ">"
+	LT_EQ       = "<="
+	GT_EQ       = ">="
+	EQ          = "=="
+	NOT_EQ      = "!="
+	AND         = "&&"
+	OR          = "||"
+	// the bitwise operators work on the bits of integers
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	TILDE       = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+	// ARROW precedes the return type annotation of a function, fn(a: int) -> int
+	ARROW = "->"
+	// QUESTION starts the branches of a conditional expression, cond ? a : b
+	QUESTION = "?"
+	// INCREMENT and DECREMENT follow a name to add or subtract one from it, i++
+	INCREMENT = "++"
+	DECREMENT = "--"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	DOT       = "."
+	// ELLIPSIS follows the last parameter of a function collecting the remaining arguments, fn(x, rest...)
+	ELLIPSIS = "..."
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -39,27 +67,52 @@ const (
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
 	RETURN   = "RETURN"
+	RECORD   = "RECORD"
+	SWITCH   = "SWITCH"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+
+	// Documentation
+	DOC = "DOC" // ### adds two numbers
 
 	// Data-types
 	STRING   = "STRING"
 	LBRACKET = "["
 	RBRACKET = "]"
 	COLON    = ":"
+	// OPTIONAL_LBRACKET opens a safe navigation index, list?[0] is null when list is null
+	OPTIONAL_LBRACKET = "?["
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"return":   RETURN,
+	"record":   RECORD,
+	"switch":   SWITCH,
+	"case":     CASE,
+	"default":  DEFAULT,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 // LookupIdent checks the keywords table to see whether
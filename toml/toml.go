@@ -0,0 +1,629 @@
+// Package toml implements a decoder for the subset of TOML used to configure Monkey programs.
+// It supports tables, arrays of tables, dotted keys, basic, literal and multi-line strings,
+// integers, floats, booleans, arrays and inline tables. Date-times are not supported.
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parse decodes the given TOML document. Tables are decoded as map[string]interface{},
+// arrays as []interface{}, strings as string, integers as int64, floats as float64 and booleans as bool.
+func Parse(input string) (map[string]interface{}, error) {
+	p := &parser{input: input, line: 1, kinds: make(map[uintptr]tableKind)}
+	root := make(map[string]interface{})
+	current := root
+
+	for {
+		p.skipBlankLines()
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseTableHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectLineEnd(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parser keeps track of the position in the TOML document being decoded
+type parser struct {
+	input string
+	pos   int
+	line  int
+	// kinds records how the tables of the document were defined, a table missing from it is implicit
+	kinds map[uintptr]tableKind
+}
+
+// tableKind records how a table was defined, which decides whether a later header or dotted key can extend it
+type tableKind int
+
+const (
+	// implicitTable is created by the header of one of its sub-tables, a header of its own may still define it
+	implicitTable tableKind = iota
+	// headerTable is defined by a [header] or an element of an [[array of tables]]
+	headerTable
+	// dottedTable is created by a dotted key, a.b = 1, only other dotted keys add to it
+	dottedTable
+	// inlineTable is defined by an inline table, nothing can be added to it afterwards
+	inlineTable
+)
+
+// errorf constructs an error that includes the line the parser is on
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("toml: line %d: %s", p.line, fmt.Sprintf(format, a...))
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.input) }
+
+// peek returns the current character without advancing, or 0 at the end of the input
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// advance moves past the current character, counting lines as it goes
+func (p *parser) advance() {
+	if p.peek() == '\n' {
+		p.line++
+	}
+	p.pos++
+}
+
+// skipSpaces moves past spaces and tabs on the current line
+func (p *parser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.advance()
+	}
+}
+
+// skipComment moves past a comment up until the end of the line
+func (p *parser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.advance()
+		}
+	}
+}
+
+// skipBlankLines moves past whitespace, newlines and comments
+func (p *parser) skipBlankLines() {
+	for {
+		p.skipSpaces()
+		p.skipComment()
+		if p.peek() == '\n' || p.peek() == '\r' {
+			p.advance()
+			continue
+		}
+		return
+	}
+}
+
+// expectLineEnd verifies nothing but a comment follows on the current line
+func (p *parser) expectLineEnd() error {
+	p.skipSpaces()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.advance()
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.peek())
+	}
+	return nil
+}
+
+// parseTableHeader parses a [table] or [[array of tables]] header and returns the table
+// that the key-value pairs following the header belong to.
+func (p *parser) parseTableHeader(root map[string]interface{}) (map[string]interface{}, error) {
+	p.advance()
+	isArray := p.peek() == '['
+	if isArray {
+		p.advance()
+	}
+
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	closing := "]"
+	if isArray {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.input[p.pos:], closing) {
+		return nil, p.errorf("expected %q to close table header", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.descend(root, keys[:len(keys)-1], implicitTable)
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+
+	if isArray {
+		table := make(map[string]interface{})
+		p.kinds[tableID(table)] = headerTable
+		switch existing := parent[last].(type) {
+		case nil:
+			parent[last] = []interface{}{table}
+		case []interface{}:
+			parent[last] = append(existing, table)
+		default:
+			return nil, p.errorf("key %q is already defined", last)
+		}
+		return table, nil
+	}
+
+	table, err := p.descend(parent, []string{last}, implicitTable)
+	if err != nil {
+		return nil, err
+	}
+	if p.kinds[tableID(table)] != implicitTable {
+		return nil, p.errorf("table %q is already defined", strings.Join(keys, "."))
+	}
+	p.kinds[tableID(table)] = headerTable
+	return table, nil
+}
+
+// tableID identifies a table by its map, two headers naming the same table of different
+// elements of an array of tables define different tables
+func tableID(table map[string]interface{}) uintptr {
+	return reflect.ValueOf(table).Pointer()
+}
+
+// descend walks the given keys from table, creating the tables that do not exist yet with the given kind,
+// implicitTable for the keys of a header and dottedTable for the ones of a dotted key. When a key refers to
+// an array of tables, the last table in the array is used. Inline tables cannot be extended, and a dotted key
+// only walks through the tables other dotted keys created.
+func (p *parser) descend(table map[string]interface{}, keys []string, kind tableKind) (map[string]interface{}, error) {
+	for _, key := range keys {
+		switch existing := table[key].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			if kind != implicitTable {
+				p.kinds[tableID(next)] = kind
+			}
+			table[key] = next
+			table = next
+		case map[string]interface{}:
+			switch existingKind := p.kinds[tableID(existing)]; {
+			case existingKind == inlineTable:
+				return nil, p.errorf("inline table %q cannot be extended", key)
+			case kind == dottedTable && existingKind != dottedTable:
+				return nil, p.errorf("table %q is already defined", key)
+			}
+			table = existing
+		case []interface{}:
+			if kind == dottedTable {
+				return nil, p.errorf("table %q is already defined", key)
+			}
+			if len(existing) == 0 {
+				return nil, p.errorf("key %q is not a table", key)
+			}
+			last, ok := existing[len(existing)-1].(map[string]interface{})
+			if !ok {
+				return nil, p.errorf("key %q is not a table", key)
+			}
+			table = last
+		default:
+			return nil, p.errorf("key %q is not a table", key)
+		}
+	}
+	return table, nil
+}
+
+// parseKeyValue parses a `key = value` pair and sets it in the given table
+func (p *parser) parseKeyValue(table map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+
+	if p.peek() != '=' {
+		return p.errorf("expected '=' after key %q", strings.Join(keys, "."))
+	}
+	p.advance()
+	p.skipSpaces()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := p.descend(table, keys[:len(keys)-1], dottedTable)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := parent[last]; ok {
+		return p.errorf("key %q is already defined", last)
+	}
+	parent[last] = value
+	return nil
+}
+
+// parseKey parses a (dotted) key made of bare or quoted parts and returns its parts
+func (p *parser) parseKey() ([]string, error) {
+	keys := []string{}
+	for {
+		p.skipSpaces()
+
+		var key string
+		var err error
+		switch c := p.peek(); {
+		case c == '"':
+			key, err = p.parseBasicString()
+		case c == '\'':
+			key, err = p.parseLiteralString()
+		case isBareKeyChar(c):
+			start := p.pos
+			for isBareKeyChar(p.peek()) {
+				p.advance()
+			}
+			key = p.input[start:p.pos]
+		default:
+			return nil, p.errorf("expected a key, got %q", c)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.advance()
+	}
+}
+
+// isBareKeyChar checks whether the character is allowed in an unquoted key
+func isBareKeyChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue parses the value of a key-value pair, an array element or an inline table entry
+func (p *parser) parseValue() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case strings.HasPrefix(p.input[p.pos:], "true"):
+		p.pos += len("true")
+		return true, nil
+	case strings.HasPrefix(p.input[p.pos:], "false"):
+		p.pos += len("false")
+		return false, nil
+	case c == '+' || c == '-' || '0' <= c && c <= '9' || strings.HasPrefix(p.input[p.pos:], "inf") || strings.HasPrefix(p.input[p.pos:], "nan"):
+		return p.parseNumber()
+	case c == 0 || c == '\n' || c == '\r':
+		return nil, p.errorf("expected a value")
+	default:
+		return nil, p.errorf("unexpected %q at the start of a value", c)
+	}
+}
+
+// parseNumber parses decimal, hexadecimal, octal and binary integers and decimal floats,
+// including inf and nan. It follows the syntax of TOML rather than the one of Go: decimal integers have
+// no leading zeros, the prefixes of the other bases are lowercase and unsigned, and an underscore must
+// sit between two digits.
+func (p *parser) parseNumber() (interface{}, error) {
+	start := p.pos
+	for c := p.peek(); c != 0 && strings.IndexByte(" \t\r\n,]}#", c) == -1; c = p.peek() {
+		p.advance()
+	}
+	literal := p.input[start:p.pos]
+	if isDateTime(literal) {
+		return nil, p.errorf("date-times are not supported: %s", literal)
+	}
+
+	unsigned := literal
+	if literal[0] == '+' || literal[0] == '-' {
+		unsigned = literal[1:]
+	}
+	switch {
+	case unsigned == "inf" || unsigned == "nan":
+		value, _ := strconv.ParseFloat(literal, 64)
+		return value, nil
+	case strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0o") || strings.HasPrefix(unsigned, "0b"):
+		base, digit := 16, isHexDigit
+		switch unsigned[1] {
+		case 'o':
+			base, digit = 8, func(c byte) bool { return '0' <= c && c <= '7' }
+		case 'b':
+			base, digit = 2, func(c byte) bool { return c == '0' || c == '1' }
+		}
+		if unsigned != literal || !validDigits(unsigned[2:], digit) {
+			return nil, p.errorf("invalid integer %s", literal)
+		}
+		value, err := strconv.ParseInt(strings.ReplaceAll(unsigned[2:], "_", ""), base, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", literal)
+		}
+		return value, nil
+	case strings.ContainsAny(unsigned, ".eE"):
+		if !validFloat(unsigned) {
+			return nil, p.errorf("invalid float %s", literal)
+		}
+		value, err := strconv.ParseFloat(strings.ReplaceAll(literal, "_", ""), 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", literal)
+		}
+		return value, nil
+	default:
+		if !validDecimal(unsigned) {
+			return nil, p.errorf("invalid integer %s", literal)
+		}
+		value, err := strconv.ParseInt(strings.ReplaceAll(literal, "_", ""), 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", literal)
+		}
+		return value, nil
+	}
+}
+
+// isDateTime checks whether the literal looks like a date, 1979-05-27, or a time, 07:32:00
+func isDateTime(literal string) bool {
+	return strings.Contains(literal, ":") || len(literal) > 4 && literal[4] == '-' && validDigits(literal[:4], isDigit)
+}
+
+// validDigits checks that the digits are not empty and that every underscore sits between two digits
+func validDigits(digits string, digit func(byte) bool) bool {
+	if digits == "" {
+		return false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] == '_' {
+			if i == 0 || i == len(digits)-1 || !digit(digits[i-1]) || !digit(digits[i+1]) {
+				return false
+			}
+		} else if !digit(digits[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// validDecimal checks an unsigned decimal integer, which only starts with a zero when it is zero
+func validDecimal(digits string) bool {
+	return validDigits(digits, isDigit) && (digits[0] != '0' || len(digits) == 1)
+}
+
+// validFloat checks an unsigned decimal float: an integer part, then a fractional part, an exponent or both.
+// TOML requires digits on both sides of the decimal point, which strconv.ParseFloat does not.
+func validFloat(number string) bool {
+	mantissa, exponent := number, ""
+	if i := strings.IndexAny(number, "eE"); i >= 0 {
+		mantissa, exponent = number[:i], number[i+1:]
+		if exponent == "" {
+			return false
+		}
+		if exponent[0] == '+' || exponent[0] == '-' {
+			exponent = exponent[1:]
+		}
+		if !validDigits(exponent, isDigit) {
+			return false
+		}
+	}
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		if !validDigits(mantissa[i+1:], isDigit) {
+			return false
+		}
+		mantissa = mantissa[:i]
+	}
+	return validDecimal(mantissa)
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isHexDigit(c byte) bool { return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' }
+
+// parseBasicString parses a double quoted string, processing its escape sequences.
+// Multi-line basic strings are delimited by three double quotes, a backslash ending one of their lines
+// trims the newline and the whitespace up to the next character.
+func (p *parser) parseBasicString() (string, error) {
+	delimiter := `"`
+	if strings.HasPrefix(p.input[p.pos:], `"""`) {
+		delimiter = `"""`
+		p.pos += 3
+		p.skipLeadingNewline()
+	} else {
+		p.advance()
+	}
+
+	var out strings.Builder
+	for {
+		if p.eof() || delimiter == `"` && p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		if strings.HasPrefix(p.input[p.pos:], delimiter) {
+			p.pos += len(delimiter)
+			return out.String(), nil
+		}
+
+		c := p.peek()
+		p.advance()
+		if c != '\\' {
+			out.WriteByte(c)
+			continue
+		}
+
+		if delimiter == `"""` && p.skipLineEndingBackslash() {
+			continue
+		}
+
+		escaped := p.peek()
+		if escaped == 0 || escaped == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.advance()
+		switch escaped {
+		case 'b':
+			out.WriteByte('\b')
+		case 't':
+			out.WriteByte('\t')
+		case 'n':
+			out.WriteByte('\n')
+		case 'f':
+			out.WriteByte('\f')
+		case 'r':
+			out.WriteByte('\r')
+		case '"', '\\':
+			out.WriteByte(escaped)
+		case 'u', 'U':
+			digits := 4
+			if escaped == 'U' {
+				digits = 8
+			}
+			r, err := p.parseUnicodeEscape(digits)
+			if err != nil {
+				return "", err
+			}
+			out.WriteRune(r)
+		default:
+			return "", p.errorf("invalid escape sequence \\%c", escaped)
+		}
+	}
+}
+
+// parseUnicodeEscape parses the hexadecimal digits of a \uXXXX or \UXXXXXXXX escape sequence,
+// which must encode a Unicode scalar value
+func (p *parser) parseUnicodeEscape(digits int) (rune, error) {
+	if p.pos+digits > len(p.input) || strings.Trim(p.input[p.pos:p.pos+digits], "0123456789abcdefABCDEF") != "" {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	code, err := strconv.ParseUint(p.input[p.pos:p.pos+digits], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	p.pos += digits
+	return rune(code), nil
+}
+
+// skipLineEndingBackslash moves past the whitespace and newlines following a backslash ending a line,
+// it reports false and leaves the position unchanged when the backslash is followed by anything else
+func (p *parser) skipLineEndingBackslash() bool {
+	rest := strings.TrimLeft(p.input[p.pos:], " \t")
+	if !strings.HasPrefix(rest, "\n") && !strings.HasPrefix(rest, "\r\n") {
+		return false
+	}
+	for c := p.peek(); c == ' ' || c == '\t' || c == '\r' || c == '\n'; c = p.peek() {
+		p.advance()
+	}
+	return true
+}
+
+// parseLiteralString parses a single quoted string, no escape sequences are processed.
+// Multi-line literal strings are delimited by three single quotes.
+func (p *parser) parseLiteralString() (string, error) {
+	delimiter := "'"
+	if strings.HasPrefix(p.input[p.pos:], "'''") {
+		delimiter = "'''"
+		p.pos += 3
+		p.skipLeadingNewline()
+	} else {
+		p.advance()
+	}
+
+	start := p.pos
+	for !strings.HasPrefix(p.input[p.pos:], delimiter) {
+		if p.eof() || delimiter == "'" && p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.advance()
+	}
+	value := p.input[start:p.pos]
+	p.pos += len(delimiter)
+	return value, nil
+}
+
+// skipLeadingNewline trims the newline directly following the opening delimiter of a multi-line string
+func (p *parser) skipLeadingNewline() {
+	if strings.HasPrefix(p.input[p.pos:], "\r\n") {
+		p.pos++
+	}
+	if p.peek() == '\n' {
+		p.advance()
+	}
+}
+
+// parseArray parses an array, which can span multiple lines and have a trailing comma
+func (p *parser) parseArray() ([]interface{}, error) {
+	p.advance()
+	array := []interface{}{}
+
+	for {
+		p.skipBlankLines()
+		if p.peek() == ']' {
+			p.advance()
+			return array, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		p.skipBlankLines()
+		switch p.peek() {
+		case ',':
+			p.advance()
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// parseInlineTable parses an inline table, { key = value, ... }, on a single line
+func (p *parser) parseInlineTable() (map[string]interface{}, error) {
+	p.advance()
+	table := make(map[string]interface{})
+	p.kinds[tableID(table)] = inlineTable
+
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.advance()
+		return table, nil
+	}
+
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.advance()
+		case '}':
+			p.advance()
+			return table, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package toml
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `# server configuration
+title = "Monkey \"config\""
+debug = false
+workers = 1_000
+ratio = 0.75
+
+[server]
+host = 'localhost'
+port = 0x1F90
+tags = [
+	"web", # the public api
+	"api",
+]
+limits = { requests = 100, burst = -5, backoff = 1.5e-3 }
+
+[server.tls]
+enabled = true
+
+[[users]]
+name = "ape"
+
+[users.score]
+best = -inf
+
+[[users]]
+name = """
+gorilla"""
+roles.admin = true
+
+[users.score]
+best = 9_000.5
+`
+
+	expected := map[string]interface{}{
+		"title":   `Monkey "config"`,
+		"debug":   false,
+		"workers": int64(1000),
+		"ratio":   0.75,
+		"server": map[string]interface{}{
+			"host": "localhost",
+			"port": int64(8080),
+			"tags": []interface{}{"web", "api"},
+			"limits": map[string]interface{}{
+				"requests": int64(100),
+				"burst":    int64(-5),
+				"backoff":  0.0015,
+			},
+			"tls": map[string]interface{}{"enabled": true},
+		},
+		"users": []interface{}{
+			map[string]interface{}{
+				"name":  "ape",
+				"score": map[string]interface{}{"best": math.Inf(-1)},
+			},
+			map[string]interface{}{
+				"name":  "gorilla",
+				"roles": map[string]interface{}{"admin": true},
+				"score": map[string]interface{}{"best": 9000.5},
+			},
+		},
+	}
+
+	result, err := Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result.\nwant=%#v\ngot=%#v", expected, result)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a = 1\na = 2", `toml: line 2: key "a" is already defined`},
+		{"a 1", `toml: line 1: expected '=' after key "a"`},
+		{"day = 1979-05-27", "toml: line 1: date-times are not supported: 1979-05-27"},
+		{"pi = 3.", "toml: line 1: invalid float 3."},
+		{"pi = 03.14", "toml: line 1: invalid float 03.14"},
+		{"pi = 1e", "toml: line 1: invalid float 1e"},
+		{"pi = 1_.5", "toml: line 1: invalid float 1_.5"},
+		{"a = 012", "toml: line 1: invalid integer 012"},
+		{"a = 0X1F", "toml: line 1: invalid integer 0X1F"},
+		{"a = +0x1F", "toml: line 1: invalid integer +0x1F"},
+		{"a = 0x_1F", "toml: line 1: invalid integer 0x_1F"},
+		{"a = 0b102", "toml: line 1: invalid integer 0b102"},
+		{"a = 1__2", "toml: line 1: invalid integer 1__2"},
+		{"a = _1", "toml: line 1: unexpected '_' at the start of a value"},
+		{"a = 1_", "toml: line 1: invalid integer 1_"},
+		{"pi = .5", "toml: line 1: unexpected '.' at the start of a value"},
+		{"pi = 1.2.3", "toml: line 1: invalid float 1.2.3"},
+		{"[a]\nx = 1\n[a]\ny = 2", `toml: line 3: table "a" is already defined`},
+		{"[a.b]\n[b]\n[a.b]", `toml: line 3: table "a.b" is already defined`},
+		{"a = { x = 1 }\n[a]", `toml: line 2: inline table "a" cannot be extended`},
+		{"a = { x = 1 }\n[a.b]", `toml: line 2: inline table "a" cannot be extended`},
+		{"a = { x = 1 }\na.y = 2", `toml: line 2: inline table "a" cannot be extended`},
+		{"a = { b = { x = 1 } }\n[a.b.c]", `toml: line 2: inline table "a" cannot be extended`},
+		{"a.b = 1\n[a]", `toml: line 2: table "a" is already defined`},
+		{"[a]\nb.c = 1\n[a.b]", `toml: line 3: table "a.b" is already defined`},
+		{"[a.b]\nx = 1\n[a]\nb.y = 2", `toml: line 4: table "b" is already defined`},
+		{"[[t.a]]\n[t]\na.x = 1", `toml: line 3: table "a" is already defined`},
+		{"a = []\n[a.b]", `toml: line 2: key "a" is not a table`},
+		{`name = "monkey`, "toml: line 1: unterminated string"},
+		{`name = "\q"`, `toml: line 1: invalid escape sequence \q`},
+		{`name = "\u00e"`, "toml: line 1: invalid unicode escape"},
+		{`name = "\U0000_0e9"`, "toml: line 1: invalid unicode escape"},
+		{`name = "\uD800"`, "toml: line 1: invalid unicode escape"},
+		{`name = "\U00110000"`, "toml: line 1: invalid unicode escape"},
+		{"name = \"a \\\nb\"", "toml: line 1: unterminated string"},
+		{"[table\na = 1", `toml: line 1: expected "]" to close table header`},
+		{"a = 1 2", `toml: line 1: unexpected '2' after value`},
+		{"a = [1 2]", "toml: line 1: expected ',' or ']' in array"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		if err == nil {
+			t.Errorf("expected error for %q but got none", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestParseImplicitTable(t *testing.T) {
+	result, err := Parse("[a.b]\nx = 1\n[a]\ny = 2\nc.d = 3\nc.e = 4\n[a.c.f]\ng = 5")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{"x": int64(1)},
+			"y": int64(2),
+			"c": map[string]interface{}{
+				"d": int64(3),
+				"e": int64(4),
+				"f": map[string]interface{}{"g": int64(5)},
+			},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result.\nwant=%#v\ngot=%#v", expected, result)
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0", int64(0)},
+		{"+0", int64(0)},
+		{"-17", int64(-17)},
+		{"1_000_000", int64(1000000)},
+		{"0xdead_BEEF", int64(0xdeadbeef)},
+		{"0o755", int64(0755)},
+		{"0b1101", int64(13)},
+		{"0.5", 0.5},
+		{"-1_000.000_1", -1000.0001},
+		{"6e+2", 600.0},
+		{"1E-2", 0.01},
+		{"+inf", math.Inf(1)},
+	}
+
+	for _, tt := range tests {
+		result, err := Parse("a = " + tt.input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if result["a"] != tt.expected {
+			t.Errorf("wrong value for %q. want=%#v, got=%#v", tt.input, tt.expected, result["a"])
+		}
+	}
+
+	result, err := Parse("a = nan")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if value, ok := result["a"].(float64); !ok || !math.IsNaN(value) {
+		t.Errorf("wrong value for nan: %#v", result["a"])
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"tab\tquote\"backslash\\"`, "tab\tquote\"backslash\\"},
+		{`"\b\f\n\r"`, "\b\f\n\r"},
+		{`"caf\u00e9 \U0001F412"`, "café 🐒"},
+		{"\"\"\"\nThe quick \\\n\n    brown \\   \r\n  fox\"\"\"", "The quick brown fox"},
+		{"\"\"\"\nkeeps\n  lines\"\"\"", "keeps\n  lines"},
+		{`'C:\raw\n'`, `C:\raw\n`},
+	}
+
+	for _, tt := range tests {
+		result, err := Parse("s = " + tt.input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if result["s"] != tt.expected {
+			t.Errorf("wrong value for %q. want=%q, got=%q", tt.input, tt.expected, result["s"])
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package transform runs AST transformation passes between parsing and compilation.
+// DSL authors use passes to rewrite their custom constructs, usually calls of reserved functions,
+// into core nodes the evaluator and the compiler already understand.
+package transform
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/token"
+)
+
+// PassFailed is the diagnostic code of the errors returned by passes
+const PassFailed = "E201"
+
+// Pass is a single rewrite of the program. Rewrite is called for every node of the program,
+// children before their parents, and returns the node that replaces it (see ast.Modify).
+// Passes run by ascending Order, passes with the same Order run in the order they were added.
+type Pass struct {
+	Name    string
+	Order   int
+	Rewrite ast.ModifierFunc
+}
+
+// Error is an error located at a token of the program, passes return it to point at the offending node
+type Error struct {
+	Token   token.Token
+	Message string
+}
+
+// Error returns the message of the error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf creates an Error located at the given token
+func Errorf(tok token.Token, format string, a ...interface{}) *Error {
+	return &Error{Token: tok, Message: fmt.Sprintf(format, a...)}
+}
+
+// Pipeline is an ordered list of passes applied to programs
+type Pipeline struct {
+	passes []Pass
+}
+
+// New creates an empty pipeline
+func New() *Pipeline {
+	return &Pipeline{}
+}
+
+// Add adds a pass to the pipeline, pass names must be unique
+func (p *Pipeline) Add(pass Pass) error {
+	if pass.Rewrite == nil {
+		return fmt.Errorf("pass %s has no rewrite function", pass.Name)
+	}
+	for _, existing := range p.passes {
+		if existing.Name == pass.Name {
+			return fmt.Errorf("pass %s is already added", pass.Name)
+		}
+	}
+
+	p.passes = append(p.passes, pass)
+	// a stable sort keeps passes with the same order in the order they were added
+	sort.SliceStable(p.passes, func(i, j int) bool { return p.passes[i].Order < p.passes[j].Order })
+	return nil
+}
+
+// Names returns the names of the passes in the order they run
+func (p *Pipeline) Names() []string {
+	names := []string{}
+	for _, pass := range p.passes {
+		names = append(names, pass.Name)
+	}
+	return names
+}
+
+// Apply runs every pass over the program, which is rewritten in place.
+// The first failing pass stops the pipeline, its error is reported as a diagnostic naming the pass.
+func (p *Pipeline) Apply(program *ast.Program) diagnostic.Diagnostics {
+	for _, pass := range p.passes {
+		_, err := ast.Modify(program, pass.Rewrite)
+		if err == nil {
+			continue
+		}
+
+		var tok token.Token
+		if located, ok := err.(*Error); ok {
+			tok = located.Token
+		}
+		return diagnostic.Diagnostics{
+			diagnostic.New(diagnostic.Error, tok, PassFailed, "pass %s: %s", pass.Name, err),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+)
+
+// unless rewrites `unless(condition, consequence, alternative)` into an if expression
+func unless(node ast.Node) (ast.Node, error) {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return node, nil
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || ident.Value != "unless" {
+		return node, nil
+	}
+	if len(call.Arguments) != 3 {
+		return nil, Errorf(call.Token, "unless expects 3 arguments, got %d", len(call.Arguments))
+	}
+
+	block := func(e ast.Expression) *ast.BlockStatement {
+		return &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: e}}}
+	}
+	return &ast.IfExpression{
+		Token:       call.Token,
+		Condition:   call.Arguments[0],
+		Consequence: block(call.Arguments[2]),
+		Alternative: block(call.Arguments[1]),
+	}, nil
+}
+
+// rename returns a pass rewriting identifiers called from into to
+func rename(from, to string) ast.ModifierFunc {
+	return func(node ast.Node) (ast.Node, error) {
+		if ident, ok := node.(*ast.Identifier); ok && ident.Value == from {
+			ident.Value = to
+		}
+		return node, nil
+	}
+}
+
+func parse(input string) *ast.Program {
+	return parser.New(lexer.New(input)).ParseProgram()
+}
+
+func TestPipeline(t *testing.T) {
+	pipeline := New()
+	passes := []Pass{
+		{Name: "unless", Order: 10, Rewrite: unless},
+		{Name: "b-to-c", Order: 0, Rewrite: rename("b", "c")},
+		{Name: "a-to-b", Order: 0, Rewrite: rename("a", "b")},
+	}
+	for _, pass := range passes {
+		if err := pipeline.Add(pass); err != nil {
+			t.Fatalf("add failed: %s", err)
+		}
+	}
+
+	names := pipeline.Names()
+	expectedNames := []string{"b-to-c", "a-to-b", "unless"}
+	for i, name := range expectedNames {
+		if names[i] != name {
+			t.Errorf("wrong pass order. want=%v, got=%v", expectedNames, names)
+			break
+		}
+	}
+
+	program := parse(`unless(a, 1, b);`)
+	if ds := pipeline.Apply(program); len(ds) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", ds)
+	}
+	// b is renamed to c before a is renamed to b, so only a ends up as b
+	expected := "ifb celse 1"
+	if program.String() != expected {
+		t.Errorf("wrong program. want=%q, got=%q", expected, program.String())
+	}
+
+	if err := pipeline.Add(Pass{Name: "unless", Rewrite: unless}); err == nil || err.Error() != "pass unless is already added" {
+		t.Errorf("wrong error for duplicate pass: %v", err)
+	}
+	if err := pipeline.Add(Pass{Name: "empty"}); err == nil || err.Error() != "pass empty has no rewrite function" {
+		t.Errorf("wrong error for pass without rewrite: %v", err)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	pipeline := New()
+	pipeline.Add(Pass{Name: "unless", Rewrite: unless})
+
+	ds := pipeline.Apply(parse("let x = 1;\nlet y = unless(x, 2);"))
+	expected := "2:15: error[E201]: pass unless: unless expects 3 arguments, got 2"
+	if len(ds) != 1 || ds[0].String() != expected {
+		t.Errorf("wrong diagnostics. want=%q, got=%v", expected, ds)
+	}
+}
@@ -0,0 +1,740 @@
+// Package transpile translates Monkey programs into standalone Go programs.
+// The generated program embeds the object runtime and uses the evaluator's operations,
+// so it behaves exactly like evaluating the Monkey program, without shipping the source or bytecode.
+//
+// Every expression is lowered into Go statements that store intermediate results in temporaries,
+// which keeps the evaluation order of Monkey and lets errors and return statements leave the
+// enclosing Go function directly, just like they stop the evaluation of the enclosing Monkey function.
+package transpile
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/evaluator"
+)
+
+// header is the start of every generated program, the translated program is the body of run
+const header = `// Code generated by monkey transpile. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yourfavoritedev/golang-interpreter/evaluator"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+func main() {
+	if errObj, ok := run().(*object.Error); ok {
+		if errObj.Exit {
+			os.Exit(errObj.Code)
+		}
+		fmt.Fprintln(os.Stderr, errObj.Inspect())
+		os.Exit(1)
+	}
+}
+
+`
+
+// Transpile translates the program into the source of a Go program of package main
+func Transpile(program *ast.Program) ([]byte, error) {
+	g := &generator{}
+	run, err := g.function(nil, nil, false, program.Statements)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(header)
+	fmt.Fprintf(&out, "var run = %s\n", run)
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid Go code: %s", err)
+	}
+	return source, nil
+}
+
+// scope holds the bindings of a single Monkey function, nested functions get their own scope
+type scope struct {
+	// blocks holds the bindings of the body of the function and of the blocks the current statement is nested in,
+	// from the body to the innermost block. Every binding is a Go variable declared in the matching Go block,
+	// it maps the name to whether it was bound by a const statement, assigning to a constant is an error.
+	blocks []map[string]bool
+	outer  *scope
+}
+
+// resolve finds the scope binding the name, the scope itself or an enclosing one, and whether the binding is constant
+func (s *scope) resolve(name string) (owner *scope, constant bool) {
+	for current := s; current != nil; current = current.outer {
+		for i := len(current.blocks) - 1; i >= 0; i-- {
+			if constant, ok := current.blocks[i][name]; ok {
+				return current, constant
+			}
+		}
+	}
+	return nil, false
+}
+
+// generator writes the Go statements of the function that is being translated
+type generator struct {
+	body  *bytes.Buffer
+	scope *scope
+	temps int
+}
+
+// variable returns the Go identifier of a Monkey binding, the prefix keeps it apart from Go keywords and temporaries
+func variable(name string) string {
+	return "m_" + name
+}
+
+// temp returns a new unique temporary
+func (g *generator) temp() string {
+	g.temps++
+	return fmt.Sprintf("t%d", g.temps)
+}
+
+// emit writes a line of Go to the body of the current function
+func (g *generator) emit(format string, a ...interface{}) {
+	fmt.Fprintf(g.body, format+"\n", a...)
+}
+
+// emitErrorCheck returns from the current function when the value is an error, as the evaluator stops at errors
+func (g *generator) emitErrorCheck(value string) {
+	g.emit("if evaluator.IsError(%s) {\nreturn %s\n}", value, value)
+}
+
+// function translates a function with the given parameters and body into a Go function literal,
+// defaults holds the default values of the last len(defaults) parameters before the variadic one.
+// The last parameter of a variadic function is bound to an array of the arguments following the other parameters.
+func (g *generator) function(params []*ast.Identifier, defaults []ast.Expression, variadic bool, statements []ast.Statement) (string, error) {
+	outerBody, outerScope := g.body, g.scope
+	g.body = &bytes.Buffer{}
+	g.scope = &scope{blocks: []map[string]bool{{}}, outer: outerScope}
+	defer func() { g.body, g.scope = outerBody, outerScope }()
+
+	fixed, total := len(params), len(params)
+	if variadic {
+		fixed, total = fixed-1, -1
+	}
+	required := fixed - len(defaults)
+	var out bytes.Buffer
+	out.WriteString("func(args ...object.Object) object.Object {\n")
+	if variadic {
+		fmt.Fprintf(&out, "if len(args) < %d {\n", required)
+	} else {
+		fmt.Fprintf(&out, "if len(args) < %d || len(args) > %d {\n", required, fixed)
+	}
+	fmt.Fprintf(&out, "return &object.Error{Message: \"wrong number of arguments: \" + object.ArgumentsMessage(%d, %d, len(args))}\n}\n",
+		required, total)
+	for i, param := range params {
+		g.scope.blocks[0][param.Value] = false
+		if i == fixed {
+			// like the evaluator, the variadic parameter is bound to a new array, empty when there are no remaining arguments
+			fmt.Fprintf(&out, "var %s object.Object = &object.Array{Elements: []object.Object{}}\n", variable(param.Value))
+			fmt.Fprintf(&out, "if len(args) > %d {\n%s = &object.Array{Elements: append([]object.Object{}, args[%d:]...)}\n}\n_ = %s\n",
+				fixed, variable(param.Value), fixed, variable(param.Value))
+		} else if i < required {
+			fmt.Fprintf(&out, "%s := args[%d]\n_ = %s\n", variable(param.Value), i, variable(param.Value))
+		} else {
+			fmt.Fprintf(&out, "var %s object.Object = object.NULL\n_ = %s\n", variable(param.Value), variable(param.Value))
+		}
+	}
+	// like the evaluator, the default values of the missing arguments are evaluated from left to right
+	// with every parameter in scope, the ones after them are still null
+	for i := required; i < fixed; i++ {
+		g.emit("if len(args) > %d {\n%s = args[%d]\n} else {", i, variable(params[i].Value), i)
+		value, err := g.expression(defaults[i-required])
+		if err != nil {
+			return "", err
+		}
+		g.emit("%s = %s\n}", variable(params[i].Value), value)
+	}
+
+	result := g.temp()
+	if err := g.statements(statements, result); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(&out, "var %s object.Object = object.NULL\n", result)
+	out.Write(g.body.Bytes())
+	fmt.Fprintf(&out, "return %s\n}", result)
+
+	return out.String(), nil
+}
+
+// statements translates a list of statements, storing the value of expression statements in result
+func (g *generator) statements(statements []ast.Statement, result string) error {
+	for _, statement := range statements {
+		if err := g.statement(statement, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statement translates a single statement
+func (g *generator) statement(statement ast.Statement, result string) error {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		value, err := g.expression(statement.Expression)
+		if err != nil {
+			return err
+		}
+		g.emit("%s = %s", result, value)
+
+	case *ast.LetStatement:
+		name := statement.Name.Value
+		// a function may call itself through the name it is bound to
+		_, isFunction := statement.Value.(*ast.FunctionLiteral)
+		if isFunction {
+			g.bind(name, statement.Const, "nil")
+		}
+		value, err := g.expression(statement.Value)
+		if err != nil {
+			return err
+		}
+		if isFunction {
+			g.emit("%s = %s", variable(name), value)
+		} else {
+			g.bind(name, statement.Const, value)
+		}
+
+	case *ast.MultiLetStatement:
+		// every value is evaluated and copied before any name is bound, like the let statement,
+		// so `let a, b = b, a` swaps the bindings even when it declares new variables
+		values := make([]string, len(statement.Values))
+		for i, expression := range statement.Values {
+			value, err := g.expression(expression)
+			if err != nil {
+				return err
+			}
+			values[i] = value
+		}
+		temps := make([]string, len(values))
+		for i := range values {
+			temps[i] = g.temp()
+		}
+		g.emit("%s := %s", strings.Join(temps, ", "), strings.Join(values, ", "))
+		for i, name := range statement.Names {
+			g.bind(name.Value, statement.Const, temps[i])
+		}
+
+	case *ast.MultiAssignStatement:
+		// like the let statement binding several names, every value is copied before any name is assigned
+		for _, name := range statement.Names {
+			if err := g.assignable(name); err != nil {
+				return err
+			}
+		}
+		values := make([]string, len(statement.Values))
+		for i, expression := range statement.Values {
+			value, err := g.expression(expression)
+			if err != nil {
+				return err
+			}
+			values[i] = value
+		}
+		temps := make([]string, len(values))
+		for i := range values {
+			temps[i] = g.temp()
+		}
+		g.emit("%s := %s", strings.Join(temps, ", "), strings.Join(values, ", "))
+		for i, name := range statement.Names {
+			g.emit("%s = %s", variable(name.Value), temps[i])
+		}
+
+	case *ast.ReturnStatement:
+		value, err := g.expression(statement.ReturnValue)
+		if err != nil {
+			return err
+		}
+		g.emit("return %s", value)
+
+	// a loop translates to a Go for loop, the ifs translating the expressions around the statement are inside it
+	case *ast.BreakStatement:
+		g.emit("break")
+
+	case *ast.ContinueStatement:
+		g.emit("continue")
+
+	default:
+		return fmt.Errorf("cannot transpile statement %s", statement.String())
+	}
+
+	return nil
+}
+
+// bind adds a let binding of the value to the innermost block, its Go variable is declared the first time
+// the block binds the name. The declaration shadows the variable of an enclosing block the same way the binding
+// shadows the enclosing one, and the value is read before the declaration, so `let x = x` reads the outer x.
+func (g *generator) bind(name string, constant bool, value string) {
+	block := g.scope.blocks[len(g.scope.blocks)-1]
+	if _, ok := block[name]; ok {
+		g.emit("%s = %s", variable(name), value)
+	} else {
+		g.emit("var %s object.Object = %s\n_ = %s", variable(name), value, variable(name))
+	}
+	block[name] = constant
+}
+
+// enterBlock starts the bindings of a block, the Go block translating it must already be open
+func (g *generator) enterBlock() {
+	g.scope.blocks = append(g.scope.blocks, map[string]bool{})
+}
+
+// leaveBlock ends the bindings of the innermost block, before the Go block translating it is closed
+func (g *generator) leaveBlock() {
+	g.scope.blocks = g.scope.blocks[:len(g.scope.blocks)-1]
+}
+
+// block translates the statements of a block, storing its value in result. The names it binds are only
+// visible inside of it, like the variables of the Go block the caller opened around it.
+func (g *generator) block(block *ast.BlockStatement, result string) error {
+	g.enterBlock()
+	defer g.leaveBlock()
+	return g.statements(block.Statements, result)
+}
+
+// expression translates an expression and returns the Go expression holding its value
+func (g *generator) expression(expression ast.Expression) (string, error) {
+	switch node := expression.(type) {
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("&object.Integer{Value: %d}", node.Value), nil
+
+	case *ast.FloatLiteral:
+		return fmt.Sprintf("&object.Float{Value: %s}", strconv.FormatFloat(node.Value, 'g', -1, 64)), nil
+
+	case *ast.StringLiteral:
+		return fmt.Sprintf("&object.String{Value: %s}", strconv.Quote(node.Value)), nil
+
+	case *ast.SymbolLiteral:
+		return fmt.Sprintf("object.Intern(%s)", strconv.Quote(node.Value)), nil
+
+	case *ast.Boolean:
+		if node.Value {
+			return "object.TRUE", nil
+		}
+		return "object.FALSE", nil
+
+	case *ast.Identifier:
+		return g.identifier(node)
+
+	case *ast.PrefixExpression:
+		right, err := g.expression(node.Right)
+		if err != nil {
+			return "", err
+		}
+		return g.operation("evaluator.Prefix(%q, %s)", node.Operator, right), nil
+
+	case *ast.InfixExpression:
+		left, err := g.expression(node.Left)
+		if err != nil {
+			return "", err
+		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return g.logical(node, left)
+		}
+		right, err := g.expression(node.Right)
+		if err != nil {
+			return "", err
+		}
+		return g.operation("evaluator.Infix(%q, %s, %s)", node.Operator, left, right), nil
+
+	case *ast.IfExpression:
+		condition, err := g.expression(node.Condition)
+		if err != nil {
+			return "", err
+		}
+		result := g.temp()
+		g.emit("var %s object.Object = object.NULL", result)
+		g.emit("if evaluator.IsTruthy(%s) {", condition)
+		if err := g.block(node.Consequence, result); err != nil {
+			return "", err
+		}
+		if node.Alternative != nil {
+			g.emit("} else {")
+			if err := g.block(node.Alternative, result); err != nil {
+				return "", err
+			}
+		}
+		g.emit("}")
+		return result, nil
+
+	case *ast.ConditionalExpression:
+		condition, err := g.expression(node.Condition)
+		if err != nil {
+			return "", err
+		}
+		return g.conditional(node, condition)
+
+	case *ast.SwitchExpression:
+		subject, err := g.expression(node.Subject)
+		if err != nil {
+			return "", err
+		}
+		return g.switchExpression(node, subject)
+
+	case *ast.WhileExpression:
+		// the condition is translated inside the loop, so it is evaluated again before every iteration
+		g.emit("for {")
+		condition, err := g.expression(node.Condition)
+		if err != nil {
+			return "", err
+		}
+		g.emit("if !evaluator.IsTruthy(%s) {", condition)
+		g.emit("break")
+		g.emit("}")
+		body := g.temp()
+		g.emit("var %s object.Object", body)
+		if err := g.block(node.Body, body); err != nil {
+			return "", err
+		}
+		g.emit("_ = %s", body)
+		g.emit("}")
+		return "object.NULL", nil
+
+	case *ast.AssignExpression:
+		return g.assignment(node)
+
+	case *ast.ForExpression:
+		iterable, err := g.expression(node.Iterable)
+		if err != nil {
+			return "", err
+		}
+		cursor, failure := g.temp(), g.temp()
+		g.emit("%s, %s := evaluator.Iterate(%s)", cursor, failure, iterable)
+		g.emitErrorCheck(failure)
+		// the names of the loop are declared in a Go block around the Go loop, like the evaluator binds them
+		// once for the whole loop, so they are only visible in the body
+		g.emit("{")
+		g.enterBlock()
+		if node.Key != nil {
+			g.bind(node.Key.Value, false, "nil")
+		}
+		g.bind(node.Value.Value, false, "nil")
+		g.emit("for {")
+		key, element, ok := "_", g.temp(), g.temp()
+		if node.Key != nil {
+			key = g.temp()
+		}
+		g.emit("%s, %s, %s := evaluator.Next(%s)", key, element, ok, cursor)
+		g.emit("if !%s {", ok)
+		g.emit("break")
+		g.emit("}")
+		g.emitErrorCheck(element)
+		if node.Key != nil {
+			g.emit("%s = %s", variable(node.Key.Value), key)
+		}
+		g.emit("%s = %s", variable(node.Value.Value), element)
+		body := g.temp()
+		g.emit("var %s object.Object", body)
+		err = g.block(node.Body, body)
+		g.leaveBlock()
+		if err != nil {
+			return "", err
+		}
+		g.emit("_ = %s", body)
+		g.emit("}")
+		g.emit("}")
+		return "object.NULL", nil
+
+	case *ast.FunctionLiteral:
+		fn, err := g.function(node.Parameters, node.Defaults, node.Variadic, node.Body.Statements)
+		if err != nil {
+			return "", err
+		}
+		result := g.temp()
+		g.emit("%s := &object.Builtin{Fn: %s}", result, fn)
+		return result, nil
+
+	case *ast.CallExpression:
+		// the transpiled functions do not keep the names of their parameters to match named arguments with
+		if len(node.Names) > 0 {
+			return "", fmt.Errorf("%d:%d: cannot transpile the named arguments of call %s", node.Token.Line, node.Token.Column, node.String())
+		}
+		fn, err := g.expression(node.Function)
+		if err != nil {
+			return "", err
+		}
+		args, err := g.expressions(node.Arguments)
+		if err != nil {
+			return "", err
+		}
+		return g.operation("evaluator.Apply(%s, %s)", fn, args), nil
+
+	case *ast.ArrayLiteral:
+		elements, err := g.expressions(node.Elements)
+		if err != nil {
+			return "", err
+		}
+		result := g.temp()
+		g.emit("%s := &object.Array{Elements: %s}", result, elements)
+		return result, nil
+
+	case *ast.RecordLiteral:
+		values, err := g.expressions(node.Values)
+		if err != nil {
+			return "", err
+		}
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = strconv.Quote(field.Value)
+		}
+		result := g.temp()
+		g.emit("%s := &object.Record{Shape: &object.RecordShape{Fields: []string{%s}}, Values: %s}",
+			result, strings.Join(fields, ", "), values)
+		return result, nil
+
+	case *ast.HashLiteral:
+		// pairs are translated in a fixed order, so transpiling a program always gives the same code
+		keys := []ast.Expression{}
+		for key := range node.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		values := []ast.Expression{}
+		for _, key := range keys {
+			values = append(values, node.Pairs[key])
+		}
+
+		translatedKeys, err := g.expressions(keys)
+		if err != nil {
+			return "", err
+		}
+		translatedValues, err := g.expressions(values)
+		if err != nil {
+			return "", err
+		}
+		return g.operation("evaluator.Hash(%s, %s)", translatedKeys, translatedValues), nil
+
+	case *ast.IndexExpression:
+		left, err := g.expression(node.Left)
+		if err != nil {
+			return "", err
+		}
+		if node.Optional {
+			return g.optional(left, func() (string, error) {
+				index, err := g.expression(node.Index)
+				return fmt.Sprintf("evaluator.Index(%s, %s)", left, index), err
+			})
+		}
+		index, err := g.expression(node.Index)
+		if err != nil {
+			return "", err
+		}
+		return g.operation("evaluator.Index(%s, %s)", left, index), nil
+
+	case *ast.SliceExpression:
+		left, err := g.expression(node.Left)
+		if err != nil {
+			return "", err
+		}
+		slice := func() (string, error) {
+			low, err := g.bound(node.Low)
+			if err != nil {
+				return "", err
+			}
+			high, err := g.bound(node.High)
+			return fmt.Sprintf("evaluator.Slice(%s, %s, %s)", left, low, high), err
+		}
+		if node.Optional {
+			return g.optional(left, slice)
+		}
+		operation, err := slice()
+		if err != nil {
+			return "", err
+		}
+		return g.operation("%s", operation), nil
+
+	case *ast.MemberExpression:
+		obj, err := g.expression(node.Object)
+		if err != nil {
+			return "", err
+		}
+		return g.operation("evaluator.Member(%s, %q)", obj, node.Property.Value), nil
+
+	default:
+		return "", fmt.Errorf("cannot transpile expression %s", expression.String())
+	}
+}
+
+// operation stores the result of an operation that may fail in a temporary, returning when it is an error
+func (g *generator) operation(format string, a ...interface{}) string {
+	result := g.temp()
+	g.emit("%s := %s", result, fmt.Sprintf(format, a...))
+	g.emitErrorCheck(result)
+	return result
+}
+
+// logical translates a short-circuiting && or || on the already translated left value,
+// the right operand is translated inside the check so it is only evaluated when left does not decide the result
+func (g *generator) logical(node *ast.InfixExpression, left string) (string, error) {
+	result := g.temp()
+	g.emit("var %s object.Object = %s", result, left)
+	if node.Operator == "&&" {
+		g.emit("if evaluator.IsTruthy(%s) {", result)
+	} else {
+		g.emit("if !evaluator.IsTruthy(%s) {", result)
+	}
+	right, err := g.expression(node.Right)
+	if err != nil {
+		return "", err
+	}
+	g.emit("%s = %s", result, right)
+	g.emit("}")
+	return result, nil
+}
+
+// conditional translates the branches of a conditional expression on the already translated condition,
+// each branch is translated inside its side of the check so only one of them is evaluated
+func (g *generator) conditional(node *ast.ConditionalExpression, condition string) (string, error) {
+	result := g.temp()
+	g.emit("var %s object.Object", result)
+	g.emit("if evaluator.IsTruthy(%s) {", condition)
+	consequence, err := g.expression(node.Consequence)
+	if err != nil {
+		return "", err
+	}
+	g.emit("%s = %s", result, consequence)
+	g.emit("} else {")
+	alternative, err := g.expression(node.Alternative)
+	if err != nil {
+		return "", err
+	}
+	g.emit("%s = %s", result, alternative)
+	g.emit("}")
+	return result, nil
+}
+
+// switchExpression translates the cases of a switch expression on the already translated subject. Every value
+// is translated inside a check that no value matched yet, so the values after the matching one are not evaluated.
+func (g *generator) switchExpression(node *ast.SwitchExpression, subject string) (string, error) {
+	result := g.temp()
+	matched := g.temp()
+	g.emit("var %s object.Object = object.NULL", result)
+	g.emit("%s := false", matched)
+	for _, c := range node.Cases {
+		g.emit("if !%s {", matched)
+		for _, v := range c.Values {
+			g.emit("if !%s {", matched)
+			value, err := g.expression(v)
+			if err != nil {
+				return "", err
+			}
+			equal := g.operation("evaluator.Infix(\"==\", %s, %s)", subject, value)
+			g.emit("%s = evaluator.IsTruthy(%s)", matched, equal)
+			g.emit("}")
+		}
+		g.emit("if %s {", matched)
+		if err := g.block(c.Body, result); err != nil {
+			return "", err
+		}
+		g.emit("}")
+		g.emit("}")
+	}
+	if node.Default != nil {
+		g.emit("if !%s {", matched)
+		if err := g.block(node.Default, result); err != nil {
+			return "", err
+		}
+		g.emit("}")
+	}
+	return result, nil
+}
+
+// optional translates a safe navigation on the already translated left value, the operation is translated
+// inside the check so the index or the bounds are only evaluated when left is not null
+func (g *generator) optional(left string, operation func() (string, error)) (string, error) {
+	result := g.temp()
+	g.emit("var %s object.Object = object.NULL", result)
+	g.emit("if %s != object.NULL {", left)
+	translated, err := operation()
+	if err != nil {
+		return "", err
+	}
+	g.emit("%s = %s", result, translated)
+	g.emitErrorCheck(result)
+	g.emit("}")
+	return result, nil
+}
+
+// bound translates a bound of a slice, an omitted bound is null
+func (g *generator) bound(bound ast.Expression) (string, error) {
+	if bound == nil {
+		return "object.NULL", nil
+	}
+	return g.expression(bound)
+}
+
+// expressions translates a list of expressions in order and returns a Go slice literal of their values
+func (g *generator) expressions(expressions []ast.Expression) (string, error) {
+	var out bytes.Buffer
+	out.WriteString("[]object.Object{")
+	for i, expression := range expressions {
+		value, err := g.expression(expression)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(value)
+	}
+	out.WriteString("}")
+	return out.String(), nil
+}
+
+// assignment translates an assignment to a binding of the current function or of the program.
+// Go closures share the variables of the functions enclosing them, but the evaluator does not let
+// a function assign to them, so the transpiled program does not either.
+func (g *generator) assignment(node *ast.AssignExpression) (string, error) {
+	if err := g.assignable(node.Name); err != nil {
+		return "", err
+	}
+
+	value, err := g.expression(node.Value)
+	if err != nil {
+		return "", err
+	}
+	g.emit("%s = %s", variable(node.Name.Value), value)
+	return variable(node.Name.Value), nil
+}
+
+// assignable returns the error of an assignment to the name, or nil when the current function can assign to it
+func (g *generator) assignable(name *ast.Identifier) error {
+	owner, constant := g.scope.resolve(name.Value)
+	position := fmt.Sprintf("%d:%d", name.Token.Line, name.Token.Column)
+	switch {
+	case owner == nil && evaluator.Global(name.Value) != nil:
+		return fmt.Errorf("%s: cannot assign to built-in %s", position, name.Value)
+	case owner == nil:
+		return fmt.Errorf("%s: undefined variable: %s", position, name.Value)
+	case constant:
+		return fmt.Errorf("%s: cannot assign to constant %s", position, name.Value)
+	case owner != g.scope && owner.outer != nil:
+		return fmt.Errorf("%s: cannot assign to %s, it is bound by an enclosing function", position, name.Value)
+	}
+	return nil
+}
+
+// identifier translates a reference to a binding, a built-in function or a module
+func (g *generator) identifier(node *ast.Identifier) (string, error) {
+	if owner, _ := g.scope.resolve(node.Value); owner != nil {
+		return variable(node.Value), nil
+	}
+
+	if evaluator.Global(node.Value) != nil {
+		return fmt.Sprintf("evaluator.Global(%q)", node.Value), nil
+	}
+
+	return "", fmt.Errorf("%d:%d: identifier not found: %s", node.Token.Line, node.Token.Column, node.Value)
+}
@@ -0,0 +1,127 @@
+package transpile
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestTranspileRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the transpiled program is slow")
+	}
+
+	input := `
+	let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+	puts(fib(15));
+	let data = {"list": [1, 2, 3], "name": "monkey"};
+	puts(data["list"][1] + len(data.name));
+	let adder = fn(a) { fn(b) { a + b } };
+	puts(collect(map(adder(10), [1, 2])));
+	puts(strings.upper("hi"));
+	let y = 1;
+	let x = if (true) { let y = y + 4; y * 2 } else { 0 };
+	puts(-x, !true, y);
+	puts(if (false) { 1 });
+	let early = fn() { if (true) { return "early"; } "late" };
+	puts(early());
+	let point = record{x: 1, y: 2};
+	puts(point.x + point.y, point == record{x: 1, y: 2});
+	let loop = fn() { while (true) { return "looped"; } };
+	puts(loop(), while (false) { 1 });
+	let none = if (false) { 1 };
+	puts(data?["name"], none?[len(1)]);
+	for (k, v in {"b": 2, "a": 1}) { puts(k, v); }
+	let first, second = "a", "b";
+	let first, second = second, first;
+	puts(first + second);
+	first, second = second, first;
+	puts(first + second);
+	let count = 0;
+	let bump = fn() { count = count + 1; };
+	while (count < 3) { bump(); }
+	puts(count);
+	puts(none || "default", none && len(1), 1 < 2 && "both");
+	puts(data["list"][1:], data["list"][:1], none?[len(1):]);
+	puts(count > 2 ? "many" : 1 + "a");
+	puts(switch (count) { case 1, 3 { "odd" } case 1 + "a" { "unreachable" } default { "even" } });
+	let odd = [];
+	for (x in range(10)) { if (x > 5) { break; } if (x == x / 2 * 2) { continue; } odd = push(odd, x); }
+	puts(odd);
+	let scale = fn(x, by = x * 2, plus = by + 1) { x * by + plus };
+	puts(scale(2), scale(2, 1));
+	let rest = fn(x, xs...) { push(xs, x) };
+	puts(rest(1), rest(1, 2, 3));
+	puts(1 + "a");
+	puts("unreachable");
+	`
+	source, err := Transpile(parse(t, input))
+	if err != nil {
+		t.Fatalf("transpile failed: %s", err)
+	}
+
+	// the program is built inside the module, so it can import the object runtime without a go.mod of its own
+	dir, err := os.MkdirTemp(".", "_transpiled")
+	if err != nil {
+		t.Fatalf("creating directory failed: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), source, 0644); err != nil {
+		t.Fatalf("writing program failed: %s", err)
+	}
+
+	cmd := exec.Command("go", "run", "./"+filepath.Base(dir))
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected the program to exit with status 1, got %v\n%s", err, stderr.String())
+	}
+
+	expected := "610\n8\n[11, 12]\nHI\n-10 false 1\nnull\nearly\n3 true\nlooped null\nmonkey null\na 1\nb 2\nba\nab\n3\ndefault null both\n[2, 3] [1] null\nmany\nodd\n[1, 3, 5]\n13 4\n[1] [2, 3, 1]\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, stdout.String())
+	}
+	if !strings.HasPrefix(stderr.String(), "ERROR: type mismatch: INTEGER + STRING\n") {
+		t.Errorf("wrong error output: %q", stderr.String())
+	}
+}
+
+func TestTranspileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\nputs(y);", "2:6: identifier not found: y"},
+		{"let x = x + 1;", "1:9: identifier not found: x"},
+		{"x = 1;", "1:1: undefined variable: x"},
+		{"len = 1;", "1:1: cannot assign to built-in len"},
+		{"const x = 1;\nx = 2;", "2:1: cannot assign to constant x"},
+		{"let f = fn() { let y = 1; fn() { y = 2 } };", "1:34: cannot assign to y, it is bound by an enclosing function"},
+		{"if (true) { let y = 1; };\ny;", "2:1: identifier not found: y"},
+		{"for (x in [1]) { x };\nx;", "2:1: identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		_, err := Transpile(parse(t, tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
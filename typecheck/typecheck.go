@@ -0,0 +1,609 @@
+// Package typecheck checks the optional type annotations of a program before it is compiled or evaluated.
+// Typing is gradual: bindings without an annotation get the type of their value when it is known,
+// and values whose type cannot be known statically (parameters without annotation, indexing, calls of
+// built-in functions) have the type any, which is compatible with every type. Values contradicting an
+// annotation are errors, operations that always fail at runtime are warnings.
+package typecheck
+
+import (
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/token"
+)
+
+// Diagnostic codes reported by the typechecker
+const (
+	// UnknownType is reported for an annotation naming a type that does not exist
+	UnknownType = "E301"
+	// Mismatch is reported for a value whose type contradicts an annotation
+	Mismatch = "E302"
+	// WrongArgumentCount is reported for a call of an annotated function with the wrong number of arguments
+	WrongArgumentCount = "E303"
+	// InvalidNamedArgument is reported for a call of an annotated function with a named argument that is not one
+	// of its parameters or a parameter given twice, and for a call with named arguments leaving out a required parameter
+	InvalidNamedArgument = "E304"
+	// InvalidOperation is reported for an operator applied to operands it does not support
+	InvalidOperation = "W301"
+)
+
+// Type is a static type, named like in annotations
+type Type string
+
+const (
+	Any    Type = "any"
+	Int    Type = "int"
+	Float  Type = "float"
+	String Type = "string"
+	Bool   Type = "bool"
+	Array  Type = "array"
+	Hash   Type = "hash"
+	Fn     Type = "fn"
+	Record Type = "record"
+	Symbol Type = "symbol"
+	Null   Type = "null"
+)
+
+// types holds every type that can be named in an annotation
+var types = map[string]Type{
+	"any":    Any,
+	"int":    Int,
+	"float":  Float,
+	"string": String,
+	"bool":   Bool,
+	"array":  Array,
+	"hash":   Hash,
+	"fn":     Fn,
+	"record": Record,
+	"symbol": Symbol,
+	"null":   Null,
+}
+
+// signature holds the parameter and result types of a function literal, unannotated ones are Any.
+// required is the number of parameters without a default value, a call passes between required and len(params) arguments.
+type signature struct {
+	params   []Type
+	names    []string
+	required int
+	result   Type
+	// variadic is set when the last of params is the type of every argument collected by the variadic parameter
+	variadic bool
+}
+
+// value is what the typechecker knows about a value: its type and, for function literals, their signature.
+// annotated is set for the value of a name bound with a type annotation, every value assigned to the name must have that type.
+type value struct {
+	typ       Type
+	sig       *signature
+	annotated bool
+}
+
+// unknown is a value whose type is not known statically
+var unknown = value{typ: Any}
+
+// scope holds the values bound by the let statements and the parameters of a function
+type scope struct {
+	values map[string]value
+	outer  *scope
+}
+
+// lookup returns the value bound to the name in the scope or its outer scopes
+func (s *scope) lookup(name string) (value, bool) {
+	for ; s != nil; s = s.outer {
+		if v, ok := s.values[name]; ok {
+			return v, true
+		}
+	}
+	return unknown, false
+}
+
+// assign replaces the value bound to the name in the closest scope binding it
+func (s *scope) assign(name string, v value) {
+	for ; s != nil; s = s.outer {
+		if _, ok := s.values[name]; ok {
+			s.values[name] = v
+			return
+		}
+	}
+}
+
+// Checker checks programs one after another, remembering the bindings of the programs it checked,
+// so the REPL can check every input with the bindings of the previous ones.
+type Checker struct {
+	global *scope
+	scope  *scope
+	// results holds the declared result types of the enclosing function literals, the innermost last
+	results     []Type
+	diagnostics diagnostic.Diagnostics
+}
+
+// New creates a Checker without any bindings
+func New() *Checker {
+	global := &scope{values: make(map[string]value)}
+	return &Checker{global: global, scope: global}
+}
+
+// Check checks a single program with a new Checker and returns the errors and warnings found
+func Check(program *ast.Program) diagnostic.Diagnostics {
+	return New().Check(program)
+}
+
+// Check checks the program and returns the errors and warnings found, in the order they were found
+func (c *Checker) Check(program *ast.Program) diagnostic.Diagnostics {
+	c.diagnostics = nil
+	c.scope = c.global
+	for _, stmt := range program.Statements {
+		c.statement(stmt)
+	}
+	return c.diagnostics
+}
+
+// statement checks a statement and returns the value it produces, which is only known for expression statements
+func (c *Checker) statement(stmt ast.Statement) value {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt)
+	case *ast.MultiLetStatement:
+		c.multiLet(stmt)
+	case *ast.MultiAssignStatement:
+		c.multiAssign(stmt)
+	case *ast.ReturnStatement:
+		v := c.expression(stmt.ReturnValue)
+		if len(c.results) > 0 {
+			c.expect(v, c.results[len(c.results)-1], stmt.Token, "return value")
+		}
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		return c.block(stmt)
+	}
+	return unknown
+}
+
+// let checks the value of a let statement against its annotation and binds the name
+func (c *Checker) let(stmt *ast.LetStatement) {
+	// a function literal can call itself, so its name is bound before its body is checked
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		c.scope.values[stmt.Name.Value] = value{typ: Fn, sig: c.signature(fl)}
+	}
+
+	c.bind(stmt.Name, c.expression(stmt.Value))
+}
+
+// multiLet checks every value of a let statement binding several names before binding the names
+func (c *Checker) multiLet(stmt *ast.MultiLetStatement) {
+	values := make([]value, len(stmt.Values))
+	for i, val := range stmt.Values {
+		values[i] = c.expression(val)
+	}
+	for i, name := range stmt.Names {
+		c.bind(name, values[i])
+	}
+}
+
+// bind checks the value against the annotation of the name and binds the name,
+// to the declared type when it is annotated, otherwise to what is known about the value
+func (c *Checker) bind(name *ast.Identifier, v value) {
+	if name.Type == nil {
+		c.scope.values[name.Value] = v
+		return
+	}
+	declared := value{typ: c.resolve(name.Type), annotated: true}
+	c.expect(v, declared.typ, name.Token, "let "+name.Value)
+	if declared.typ == Fn && v.sig != nil {
+		declared.sig = v.sig
+	}
+	c.scope.values[name.Value] = declared
+}
+
+// assign checks the value assigned to a name against its annotation. Without an annotation the name keeps its type
+// when the new value has the same one, otherwise its type is no longer known.
+func (c *Checker) assign(exp *ast.AssignExpression) value {
+	v := c.expression(exp.Value)
+	c.assignValue(exp.Name, v)
+	return v
+}
+
+// multiAssign checks every value of an assignment to several names before assigning them
+func (c *Checker) multiAssign(stmt *ast.MultiAssignStatement) {
+	values := make([]value, len(stmt.Values))
+	for i, val := range stmt.Values {
+		values[i] = c.expression(val)
+	}
+	for i, name := range stmt.Names {
+		c.assignValue(name, values[i])
+	}
+}
+
+// assignValue checks a value assigned to the name and updates what is known about the name, see assign
+func (c *Checker) assignValue(name *ast.Identifier, v value) {
+	bound, ok := c.scope.lookup(name.Value)
+	switch {
+	case !ok:
+	case bound.annotated:
+		c.expect(v, bound.typ, name.Token, "assignment to "+name.Value)
+	case bound.typ != v.typ:
+		c.scope.assign(name.Value, unknown)
+	default:
+		c.scope.assign(name.Value, v)
+	}
+}
+
+// block checks the statements of a block in a new scope and returns the value of its last statement,
+// like in the engines the names it binds are only visible inside of it
+func (c *Checker) block(block *ast.BlockStatement) value {
+	c.scope = &scope{values: make(map[string]value), outer: c.scope}
+	defer func() { c.scope = c.scope.outer }()
+	v := unknown
+	for _, stmt := range block.Statements {
+		v = c.statement(stmt)
+	}
+	return v
+}
+
+// expression checks an expression and returns what is known about its value
+func (c *Checker) expression(exp ast.Expression) value {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return value{typ: Int}
+	case *ast.FloatLiteral:
+		return value{typ: Float}
+	case *ast.StringLiteral:
+		return value{typ: String}
+	case *ast.Boolean:
+		return value{typ: Bool}
+	case *ast.SymbolLiteral:
+		return value{typ: Symbol}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+		return value{typ: Array}
+	case *ast.HashLiteral:
+		for key, val := range exp.Pairs {
+			c.expression(key)
+			c.expression(val)
+		}
+		return value{typ: Hash}
+	case *ast.Identifier:
+		v, _ := c.scope.lookup(exp.Value)
+		return v
+	case *ast.PrefixExpression:
+		return c.prefix(exp)
+	case *ast.InfixExpression:
+		return c.infix(exp)
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		consequence := c.block(exp.Consequence)
+		if exp.Alternative == nil {
+			// the value is null when the condition does not hold
+			return unknown
+		}
+		if alternative := c.block(exp.Alternative); alternative.typ == consequence.typ {
+			return value{typ: consequence.typ}
+		}
+		return unknown
+	case *ast.ConditionalExpression:
+		c.expression(exp.Condition)
+		consequence := c.expression(exp.Consequence)
+		if alternative := c.expression(exp.Alternative); alternative.typ == consequence.typ {
+			return value{typ: consequence.typ}
+		}
+		return unknown
+	case *ast.SwitchExpression:
+		c.expression(exp.Subject)
+		bodies := []value{}
+		for _, sc := range exp.Cases {
+			for _, v := range sc.Values {
+				c.expression(v)
+			}
+			bodies = append(bodies, c.block(sc.Body))
+		}
+		if exp.Default == nil {
+			// the value is null when no case matches
+			return unknown
+		}
+		result := c.block(exp.Default)
+		for _, body := range bodies {
+			if body.typ != result.typ {
+				return unknown
+			}
+		}
+		return value{typ: result.typ}
+	case *ast.WhileExpression:
+		c.expression(exp.Condition)
+		c.block(exp.Body)
+		return value{typ: Null}
+	case *ast.AssignExpression:
+		return c.assign(exp)
+	case *ast.ForExpression:
+		// the keys of an array are its indexes, the elements of a collection can have any type
+		key := unknown
+		if c.expression(exp.Iterable).typ == Array {
+			key = value{typ: Int}
+		}
+		// the names of the loop are only visible in its body
+		c.scope = &scope{values: make(map[string]value), outer: c.scope}
+		defer func() { c.scope = c.scope.outer }()
+		if exp.Key != nil {
+			c.bind(exp.Key, key)
+		}
+		c.bind(exp.Value, unknown)
+		c.block(exp.Body)
+		return value{typ: Null}
+	case *ast.FunctionLiteral:
+		return c.function(exp)
+	case *ast.CallExpression:
+		return c.call(exp)
+	case *ast.RecordLiteral:
+		for _, val := range exp.Values {
+			c.expression(val)
+		}
+		return value{typ: Record}
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+		return unknown
+	case *ast.SliceExpression:
+		left := c.expression(exp.Left)
+		for _, bound := range []ast.Expression{exp.Low, exp.High} {
+			if bound != nil {
+				c.expression(bound)
+			}
+		}
+		// the slice of an array is an array and the slice of a string a string, a safe navigation can be null
+		if (left.typ == Array || left.typ == String) && !exp.Optional {
+			return value{typ: left.typ}
+		}
+		return unknown
+	case *ast.MemberExpression:
+		c.expression(exp.Object)
+		return unknown
+	default:
+		return unknown
+	}
+}
+
+// prefix checks the operand of a prefix expression
+func (c *Checker) prefix(exp *ast.PrefixExpression) value {
+	right := c.expression(exp.Right)
+	switch exp.Operator {
+	case "!":
+		return value{typ: Bool}
+	case "-":
+		if right.typ != Any && !numeric(right.typ) {
+			c.report(diagnostic.Warning, exp.Token, InvalidOperation, "operator - is not supported for %s", right.typ)
+			return unknown
+		}
+		return value{typ: right.typ}
+	case "~":
+		if right.typ != Any && right.typ != Int {
+			c.report(diagnostic.Warning, exp.Token, InvalidOperation, "operator ~ is not supported for %s", right.typ)
+			return unknown
+		}
+		return value{typ: Int}
+	}
+	return unknown
+}
+
+// infix checks the operands of an infix expression, following the rules of the evaluator and the VM
+func (c *Checker) infix(exp *ast.InfixExpression) value {
+	left := c.expression(exp.Left)
+	right := c.expression(exp.Right)
+
+	switch exp.Operator {
+	case "==", "!=":
+		return value{typ: Bool}
+	case "&&", "||":
+		// the value is one of the operands, whichever decided the result
+		if left.typ == right.typ {
+			return value{typ: left.typ}
+		}
+		return unknown
+	}
+	if bitwise(exp.Operator) {
+		// bitwise operators only accept integers, floats are not promoted
+		if (left.typ != Any && left.typ != Int) || (right.typ != Any && right.typ != Int) {
+			c.report(diagnostic.Warning, exp.Token, InvalidOperation, "operator %s is not supported for %s and %s", exp.Operator, left.typ, right.typ)
+			return unknown
+		}
+		return value{typ: Int}
+	}
+	if left.typ == Any || right.typ == Any {
+		if comparison(exp.Operator) {
+			return value{typ: Bool}
+		}
+		return unknown
+	}
+
+	switch {
+	case numeric(left.typ) && numeric(right.typ):
+		switch {
+		case comparison(exp.Operator):
+			return value{typ: Bool}
+		case left.typ == Float || right.typ == Float:
+			// an integer operand is promoted to a float
+			return value{typ: Float}
+		default:
+			return value{typ: Int}
+		}
+	case left.typ == String && right.typ == String && exp.Operator == "+":
+		return value{typ: String}
+	}
+	c.report(diagnostic.Warning, exp.Token, InvalidOperation, "operator %s is not supported for %s and %s", exp.Operator, left.typ, right.typ)
+	return unknown
+}
+
+// numeric reports whether the type is a number, operators accept integers and floats mixed
+func numeric(t Type) bool {
+	return t == Int || t == Float
+}
+
+// function checks the body of a function literal against its annotations in a new scope
+func (c *Checker) function(fl *ast.FunctionLiteral) value {
+	sig := c.signature(fl)
+
+	c.scope = &scope{values: make(map[string]value), outer: c.scope}
+	c.results = append(c.results, sig.result)
+	defer func() {
+		c.scope = c.scope.outer
+		c.results = c.results[:len(c.results)-1]
+	}()
+
+	for i, param := range fl.Parameters {
+		c.scope.values[param.Value] = value{typ: sig.params[i]}
+	}
+	// the variadic parameter holds the array of the arguments it collected
+	if fl.Variadic {
+		c.scope.values[fl.Parameters[fl.Fixed()].Value] = value{typ: Array}
+	}
+	// the default values are evaluated in the scope of the function, with every parameter in scope
+	for i := fl.Required(); i < fl.Fixed(); i++ {
+		param := fl.Parameters[i]
+		c.expect(c.expression(fl.Default(i)), sig.params[i], param.Token, fmt.Sprintf("default value of %s", param.Value))
+	}
+
+	result := c.block(fl.Body)
+	// the implicit result is the value of the last statement, return statements were checked on their own
+	if n := len(fl.Body.Statements); n > 0 {
+		if last, ok := fl.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+			c.expect(result, sig.result, last.Token, "return value")
+		}
+	}
+	return value{typ: Fn, sig: sig}
+}
+
+// signature returns the types of the parameters and the result of a function literal
+func (c *Checker) signature(fl *ast.FunctionLiteral) *signature {
+	sig := &signature{params: make([]Type, len(fl.Parameters)), names: make([]string, len(fl.Parameters)),
+		required: fl.Required(), result: Any, variadic: fl.Variadic}
+	for i, param := range fl.Parameters {
+		sig.params[i], sig.names[i] = Any, param.Value
+		if param.Type != nil {
+			sig.params[i] = c.resolve(param.Type)
+		}
+	}
+	if fl.ReturnType != nil {
+		sig.result = c.resolve(fl.ReturnType)
+	}
+	return sig
+}
+
+// call checks the arguments of a call against the signature of the called function, when it is known
+func (c *Checker) call(exp *ast.CallExpression) value {
+	fn := c.expression(exp.Function)
+	args := make([]value, len(exp.Arguments))
+	for i, arg := range exp.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	if fn.typ != Any && fn.typ != Fn {
+		c.report(diagnostic.Warning, exp.Token, InvalidOperation, "cannot call a value of type %s", fn.typ)
+		return unknown
+	}
+	if fn.sig == nil {
+		return unknown
+	}
+
+	// the index of the parameter receiving each argument, named arguments are matched with the parameters by their names
+	indexes := make([]int, len(args))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	total := len(fn.sig.params)
+	if fn.sig.variadic {
+		total = -1
+	}
+	if len(exp.Names) > 0 {
+		var ok bool
+		if indexes, ok = c.arrange(exp, fn.sig); !ok {
+			return value{typ: fn.sig.result}
+		}
+	} else if len(args) < fn.sig.required || (!fn.sig.variadic && len(args) > total) {
+		c.report(diagnostic.Error, exp.Token, WrongArgumentCount, "wrong number of arguments in call of %s: %s",
+			exp.Function, object.ArgumentsMessage(fn.sig.required, total, len(args)))
+		return value{typ: fn.sig.result}
+	}
+	for i, arg := range args {
+		// every argument collected by the variadic parameter has the type of its annotation
+		param := fn.sig.params[len(fn.sig.params)-1]
+		if indexes[i] < len(fn.sig.params) {
+			param = fn.sig.params[indexes[i]]
+		}
+		what := fmt.Sprintf("argument %d of %s", i+1, exp.Function)
+		if named := i - exp.Positional(); named >= 0 {
+			what = fmt.Sprintf("argument %s of %s", exp.Names[named].Value, exp.Function)
+		}
+		c.expect(arg, param, exp.Token, what)
+	}
+	return value{typ: fn.sig.result}
+}
+
+// arrange matches the arguments of a call with named arguments with the parameters of the signature, the way both
+// engines do, and returns the index of the parameter receiving each argument. It reports the names that do not match.
+func (c *Checker) arrange(exp *ast.CallExpression, sig *signature) ([]int, bool) {
+	// the arguments are stood in for by their positions, which are found back in the arranged arguments
+	positions := make([]object.Object, len(exp.Arguments))
+	for i := range positions {
+		positions[i] = &object.Integer{Value: int64(i)}
+	}
+	names := make([]string, len(exp.Names))
+	for i, name := range exp.Names {
+		names[i] = name.Value
+	}
+	arranged, err := object.ArrangeArguments(sig.names, sig.required, sig.variadic, positions, names)
+	if err != nil {
+		c.report(diagnostic.Error, exp.Token, InvalidNamedArgument, "%s in call of %s", err, exp.Function)
+		return nil, false
+	}
+	indexes := make([]int, len(positions))
+	for index, position := range arranged {
+		if position != nil {
+			indexes[position.(*object.Integer).Value] = index
+		}
+	}
+	return indexes, true
+}
+
+// resolve returns the type named by an annotation, reporting unknown type names
+func (c *Checker) resolve(annotation *ast.TypeAnnotation) Type {
+	t, ok := types[annotation.Name]
+	if !ok {
+		c.report(diagnostic.Error, annotation.Token, UnknownType, "unknown type %s", annotation.Name)
+		return Any
+	}
+	return t
+}
+
+// expect reports a mismatch when the value cannot have the wanted type
+func (c *Checker) expect(v value, want Type, tok token.Token, what string) {
+	if v.typ == Any || want == Any || v.typ == want {
+		return
+	}
+	c.report(diagnostic.Error, tok, Mismatch, "cannot use %s value as %s in %s", v.typ, want, what)
+}
+
+// report records an error or a warning located at the token
+func (c *Checker) report(severity diagnostic.Severity, tok token.Token, code, format string, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, diagnostic.New(severity, tok, code, format, a...))
+}
+
+// comparison reports whether the operator compares two numbers
+func comparison(operator string) bool {
+	switch operator {
+	case "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+// bitwise reports whether the operator works on the bits of two integers
+func bitwise(operator string) bool {
+	switch operator {
+	case "&", "|", "^", "<<", ">>":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package typecheck
+
+import (
+	"testing"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x: int = 5; let y: string = "a"; let z: any = x;`, nil},
+		{`let x = fn(a, b) { a + b }; x(1, "a");`, nil},
+		{`let x: int = "five";`, []string{`1:5: error[E302]: cannot use string value as int in let x`}},
+		{`let x = 1; let y: bool = x;`, []string{`1:16: error[E302]: cannot use int value as bool in let y`}},
+		{`let x: integer = 1;`, []string{`1:8: error[E301]: unknown type integer`}},
+		{
+			`let add = fn(a: int, b: int) -> int { a + b }; add(1, "2"); add(1);`,
+			[]string{
+				`1:51: error[E302]: cannot use string value as int in argument 2 of add`,
+				`1:64: error[E303]: wrong number of arguments in call of add: want=2, got=1`,
+			},
+		},
+		{`let name = fn() -> string { 1 };`, []string{`1:29: error[E302]: cannot use int value as string in return value`}},
+		{`let f = fn(n: int) -> int { if (n < 1) { return "done"; } f(n - 1) };`,
+			[]string{`1:42: error[E302]: cannot use string value as int in return value`}},
+		{`let n: int = fn() -> int { 1 }();`, nil},
+		{`let f: fn = fn(a: int) { a }; f("a");`, []string{`1:32: error[E302]: cannot use string value as int in argument 1 of f`}},
+		{`let s = "a"; -s; s - 1; 1(2);`, []string{
+			`1:14: warning[W301]: operator - is not supported for string`,
+			`1:20: warning[W301]: operator - is not supported for string and int`,
+			`1:26: warning[W301]: cannot call a value of type int`,
+		}},
+		{`let b: bool = if (true) { 1 } else { 2 };`, []string{`1:5: error[E302]: cannot use int value as bool in let b`}},
+		{`let b: bool = if (true) { 1 };`, nil},
+		{`let b: bool = true ? 1 : 2;`, []string{`1:5: error[E302]: cannot use int value as bool in let b`}},
+		{`let b: bool = true ? 1 : "2";`, nil},
+		{`let b: bool = switch (1) { case 1 { 1 } default { 2 } };`, []string{`1:5: error[E302]: cannot use int value as bool in let b`}},
+		{`let b: bool = switch (1) { case 1 { 1 } };`, nil},
+		{`let b: bool = switch (1) { case 1 { true } default { 2 } };`, nil},
+		{`let f: float = 1 + 0.5; let n: int = 2 * 1.5;`, []string{`1:29: error[E302]: cannot use float value as int in let n`}},
+		{`let a: string, b = 1, "b"; let c: int = b;`, []string{
+			`1:5: error[E302]: cannot use int value as string in let a`,
+			`1:32: error[E302]: cannot use string value as int in let c`,
+		}},
+		{`let n: int = while (false) { 1 };`, []string{`1:5: error[E302]: cannot use null value as int in let n`}},
+		{`for (i, x in [1]) { let s: string = i; let t: string = x; }`, []string{`1:25: error[E302]: cannot use int value as string in let s`}},
+		{`let s: symbol = :red; let t: symbol = "red";`, []string{`1:27: error[E302]: cannot use string value as symbol in let t`}},
+		{`let b: bool = 1 <= 2.5; let n: int = 2 >= 1;`, []string{`1:29: error[E302]: cannot use bool value as int in let n`}},
+		{`let a: bool = true && 1 < 2; let b: int = 1 || "a"; let c: int = 1 || 2;`, nil},
+		{`let m: int = 1 << 3 & ~7; let f = 1.5 | 1; let g = ~"a";`, []string{
+			`1:39: warning[W301]: operator | is not supported for float and int`,
+			`1:52: warning[W301]: operator ~ is not supported for string`,
+		}},
+		{`let xs: array = [1, 2][1:]; let s: string = xs[:1];`, []string{`1:33: error[E302]: cannot use array value as string in let s`}},
+		{`let s: string = "ab"[1:]; let n: int = s[:1];`, []string{`1:31: error[E302]: cannot use string value as int in let n`}},
+		{`let s: string = 1 && 2;`, []string{`1:5: error[E302]: cannot use int value as string in let s`}},
+		{`let x: int = 1; x = "a"; x = 2;`, []string{`1:17: error[E302]: cannot use string value as int in assignment to x`}},
+		{`let x: int, y = 1, "a"; x, y = y, x;`, []string{`1:25: error[E302]: cannot use string value as int in assignment to x`}},
+		// without an annotation an assignment of another type makes the binding unknown
+		{`let x = 1; x = "a"; let y: string = x;`, nil},
+		{`let f = fn(a, b: int = "1") { a }; f(1); f(1, 2); f(); f(1, 2, 3);`, []string{
+			`1:15: error[E302]: cannot use string value as int in default value of b`,
+			`1:52: error[E303]: wrong number of arguments in call of f: want=1 to 2, got=0`,
+			`1:57: error[E303]: wrong number of arguments in call of f: want=1 to 2, got=3`,
+		}},
+		{`let f = fn(a, rest...: int) { let s: string = rest; a }; f(1, 2, "x"); f();`, []string{
+			`1:35: error[E302]: cannot use array value as string in let s`,
+			`1:59: error[E302]: cannot use string value as int in argument 3 of f`,
+			`1:73: error[E303]: wrong number of arguments in call of f: want=1 or more, got=0`,
+		}},
+		{`let f = fn(a: int, b: string = "") { a }; f(b: 1, a: 2); f(1, c: 2); f(b: "x");`, []string{
+			`1:44: error[E302]: cannot use int value as string in argument b of f`,
+			`1:59: error[E304]: unknown argument name c, the parameters are (a, b) in call of f`,
+			`1:71: error[E304]: missing argument for parameter a in call of f`,
+		}},
+		// the annotation of a binding made in a block does not apply to the binding it shadows
+		{`let x = "a"; if (true) { let x: int = 1; }; x = "b"; let y: int = x;`, []string{`1:58: error[E302]: cannot use string value as int in let y`}},
+	}
+
+	for _, tt := range tests {
+		diagnostics := Check(parse(t, tt.input))
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("wrong number of diagnostics for %q. want=%v, got=%v", tt.input, tt.expected, diagnostics)
+			continue
+		}
+		for i, d := range diagnostics {
+			if d.String() != tt.expected[i] {
+				t.Errorf("wrong diagnostic for %q. want=%q, got=%q", tt.input, tt.expected[i], d.String())
+			}
+		}
+	}
+}
+
+func TestCheckerKeepsBindings(t *testing.T) {
+	checker := New()
+	if diagnostics := checker.Check(parse(t, `let double = fn(x: int) -> int { x * 2 };`)); len(diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+
+	diagnostics := checker.Check(parse(t, `double("a")`))
+	if len(diagnostics) != 1 || diagnostics[0].Code != Mismatch {
+		t.Errorf("the binding of the previous program was not used. got=%v", diagnostics)
+	}
+}
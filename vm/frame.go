@@ -18,10 +18,14 @@ import (
 // where it will push and pop values, if sp is 3 it should use the indices that are greater than 3+n.
 // When the function exits, we can restore the stack, removing all values after the initial basePointer, thus giving us
 // the stack before the function was called.
+// numArgs is the number of arguments the function was called with, the parameters after them take their default values.
+// skipped marks the parameters before them a call with named arguments left out, which take their default values too.
 type Frame struct {
 	cl          *object.Closure
 	ip          int
 	basePointer int
+	numArgs     int
+	skipped     []bool
 }
 
 // NewFrame creates a new frame for the given compiled function
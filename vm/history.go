@@ -0,0 +1,286 @@
+package vm
+
+import (
+	"errors"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+	"github.com/yourfavoritedev/golang-interpreter/report"
+)
+
+// ErrBreakpoint is returned by Run and Continue when execution stops at a breakpoint.
+// The VM is left before the instruction of the breakpoint, Continue resumes from there.
+var ErrBreakpoint = errors.New("breakpoint")
+
+// ErrNoHistory is returned by StepBack when there is no recorded instruction left to undo
+var ErrNoHistory = errors.New("no instruction left to step back over")
+
+// SlotChange records the value a stack slot or global binding held before an instruction overwrote it
+type SlotChange struct {
+	Index int
+	Old   object.Object
+}
+
+// HistoryEntry records a single executed instruction along with everything needed to undo it.
+// Function is the compiled function the instruction belongs to, Ip its offset in the instructions of that function
+// and StackDelta the change of the stack pointer caused by executing it.
+// Instructions run by the callbacks of higher-order built-in functions are part of the entry of the calling instruction.
+type HistoryEntry struct {
+	Function   *object.CompiledFunction
+	Ip         int
+	Op         code.Opcode
+	StackDelta int
+	// Stack holds the previous values of the stack slots overwritten by the instruction, in the order they were written
+	Stack []SlotChange
+	// Globals holds the previous values of the global bindings overwritten by the instruction
+	Globals []SlotChange
+
+	frame       *Frame
+	sp          int
+	framesIndex int
+	// frames holds the frames replaced in the frames of the VM by the calls the instruction made
+	frames []frameChange
+}
+
+// frameChange records the frame a call replaced at an index of the frames of the VM
+type frameChange struct {
+	index int
+	old   *Frame
+}
+
+// history is a ring buffer of the most recently executed instructions
+type history struct {
+	entries []*HistoryEntry
+	// next is the position the next entry is written to, count the number of entries recorded
+	next  int
+	count int
+}
+
+// add appends an entry to the ring buffer, replacing the oldest entry when it is full
+func (h *history) add(entry *HistoryEntry) {
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.count < len(h.entries) {
+		h.count++
+	}
+}
+
+// removeLast removes the most recent entry from the ring buffer and returns it, or nil when it is empty
+func (h *history) removeLast() *HistoryEntry {
+	if h.count == 0 {
+		return nil
+	}
+	h.next = (h.next - 1 + len(h.entries)) % len(h.entries)
+	h.count--
+	entry := h.entries[h.next]
+	h.entries[h.next] = nil
+	return entry
+}
+
+// breakpoint is an instruction of a compiled function at which execution stops
+type breakpoint struct {
+	fn *object.CompiledFunction
+	ip int
+}
+
+// SetHistory records the last size executed instructions, so StepBack can undo them.
+// A size of 0 disables recording, which is the default since recording slows down execution.
+func (vm *VM) SetHistory(size int) {
+	if size <= 0 {
+		vm.history = nil
+		return
+	}
+	vm.history = &history{entries: make([]*HistoryEntry, size)}
+}
+
+// History returns the recorded instructions, from the oldest to the most recent one
+func (vm *VM) History() []HistoryEntry {
+	if vm.history == nil {
+		return nil
+	}
+
+	h := vm.history
+	entries := make([]HistoryEntry, 0, h.count)
+	for i := h.count; i > 0; i-- {
+		entries = append(entries, *h.entries[(h.next-i+len(h.entries))%len(h.entries)])
+	}
+	return entries
+}
+
+// SetBreakpoint stops execution before the instruction at offset ip of the given compiled function,
+// a nil function refers to the main program. Breakpoints are not hit by the callbacks of higher-order built-in functions.
+func (vm *VM) SetBreakpoint(fn *object.CompiledFunction, ip int) {
+	if fn == nil {
+		fn = vm.frames[0].cl.Fn
+	}
+	if vm.breakpoints == nil {
+		vm.breakpoints = make(map[breakpoint]bool)
+	}
+	vm.breakpoints[breakpoint{fn: fn, ip: ip}] = true
+}
+
+// ClearBreakpoint removes the breakpoint at offset ip of the given compiled function, nil refers to the main program
+func (vm *VM) ClearBreakpoint(fn *object.CompiledFunction, ip int) {
+	if fn == nil {
+		fn = vm.frames[0].cl.Fn
+	}
+	delete(vm.breakpoints, breakpoint{fn: fn, ip: ip})
+}
+
+// Position returns the compiled function the VM is executing and the offset of the next instruction it executes
+func (vm *VM) Position() (*object.CompiledFunction, int) {
+	frame := vm.currentFrame()
+	return frame.cl.Fn, frame.ip + 1
+}
+
+// SourcePosition returns the line and the column of the source of the instruction at offset ip of the function,
+// nil refers to the main program. It reports false when the instruction was compiled without a position.
+func (vm *VM) SourcePosition(fn *object.CompiledFunction, ip int) (int, int, bool) {
+	if fn == nil {
+		fn = vm.frames[0].cl.Fn
+	}
+	return fn.SourceMap.Lookup(ip)
+}
+
+// StackFrame is a call the VM is executing. Line and Column locate the instruction the call is at,
+// they are 0 when it was compiled without a position. Function is empty for the main program.
+type StackFrame struct {
+	Function string
+	Line     int
+	Column   int
+}
+
+// StackTrace returns the calls the VM is executing, from the main program to the innermost call.
+// After a runtime error, the innermost call is at the instruction that failed and every other call
+// at the call instruction it is waiting on.
+func (vm *VM) StackTrace() []StackFrame {
+	trace := make([]StackFrame, 0, vm.framesIndex)
+	for i, frame := range vm.frames[:vm.framesIndex] {
+		stackFrame := StackFrame{Function: frame.cl.Fn.Name}
+		if i > 0 && stackFrame.Function == "" {
+			stackFrame.Function = object.AnonymousFunctionName
+		}
+		// the instruction pointer is at the instruction or at one of its operands, which belong to the same position
+		if frame.ip >= 0 {
+			stackFrame.Line, stackFrame.Column, _ = frame.cl.Fn.SourceMap.Lookup(frame.ip)
+		}
+		trace = append(trace, stackFrame)
+	}
+	return trace
+}
+
+// Report describes the error Run stopped with as a report.Error, located at the instruction that failed
+// and holding the calls that were active. The errors that do not come from a mistake in the program,
+// an ExitError and stopping at a breakpoint or a watchpoint, are returned unchanged, like nil.
+func (vm *VM) Report(err error) error {
+	if _, ok := err.(*ExitError); ok || err == nil || err == ErrBreakpoint || err == ErrWatchpoint {
+		return err
+	}
+	trace := vm.StackTrace()
+	frames := make([]report.Frame, len(trace))
+	for i, frame := range trace {
+		frames[i] = report.Frame(frame)
+	}
+	innermost := frames[len(frames)-1]
+	return &report.Error{
+		Kind:    report.Runtime,
+		Message: err.Error(),
+		Line:    innermost.Line,
+		Column:  innermost.Column,
+		Trace:   frames,
+	}
+}
+
+// Stack returns a copy of the elements currently on the stack, from the bottom to the top
+func (vm *VM) Stack() []object.Object {
+	stack := make([]object.Object, vm.sp)
+	copy(stack, vm.stack[:vm.sp])
+	return stack
+}
+
+// Continue resumes execution after it stopped at a breakpoint or a watchpoint, or after stepping back.
+// The instruction the VM stopped at is executed even when it has a breakpoint.
+func (vm *VM) Continue() error {
+	return vm.run(0)
+}
+
+// StepBack undoes the most recently executed instruction, restoring the stack, the globals and the frames
+// to the state before it ran. It returns ErrNoHistory once every recorded instruction has been undone.
+// Objects modified in place by built-in functions are not restored.
+func (vm *VM) StepBack() error {
+	if vm.history == nil {
+		return ErrNoHistory
+	}
+	entry := vm.history.removeLast()
+	if entry == nil {
+		return ErrNoHistory
+	}
+
+	// changes are undone in the reverse order they were made, so a slot written twice gets its oldest value back
+	for i := len(entry.Stack) - 1; i >= 0; i-- {
+		vm.stack[entry.Stack[i].Index] = entry.Stack[i].Old
+	}
+	for i := len(entry.Globals) - 1; i >= 0; i-- {
+		vm.globals[entry.Globals[i].Index] = entry.Globals[i].Old
+	}
+	for i := len(entry.frames) - 1; i >= 0; i-- {
+		vm.frames[entry.frames[i].index] = entry.frames[i].old
+	}
+
+	vm.sp = entry.sp
+	vm.framesIndex = entry.framesIndex
+	// the run loop increments ip before fetching, so this executes the instruction again on the next step
+	entry.frame.ip = entry.Ip - 1
+	vm.resuming = true
+	return nil
+}
+
+// beginInstruction starts recording the instruction at offset ip of the current frame.
+// It reports whether execution has to stop at a breakpoint first.
+func (vm *VM) beginInstruction(ip int) bool {
+	frame := vm.currentFrame()
+
+	if vm.resuming {
+		vm.resuming = false
+	} else if vm.breakpoints[breakpoint{fn: frame.cl.Fn, ip: ip}] {
+		// the breakpoint is not hit again when execution resumes
+		vm.resuming = true
+		return true
+	}
+
+	if vm.history != nil {
+		vm.recording = &HistoryEntry{
+			Function:    frame.cl.Fn,
+			Ip:          ip,
+			Op:          code.Opcode(frame.Instructions()[ip]),
+			frame:       frame,
+			sp:          vm.sp,
+			framesIndex: vm.framesIndex,
+		}
+		vm.history.add(vm.recording)
+	}
+	return false
+}
+
+// endInstruction finishes recording the current instruction
+func (vm *VM) endInstruction() {
+	if vm.recording != nil {
+		vm.recording.StackDelta = vm.sp - vm.recording.sp
+		vm.recording = nil
+	}
+}
+
+// recordStack remembers the value of a stack slot before the current instruction overwrites it
+func (vm *VM) recordStack(index int) {
+	vm.recording.Stack = append(vm.recording.Stack, SlotChange{Index: index, Old: vm.stack[index]})
+}
+
+// recordGlobal remembers the value of a global binding before the current instruction overwrites it
+func (vm *VM) recordGlobal(index int) {
+	vm.recording.Globals = append(vm.recording.Globals, SlotChange{Index: index, Old: vm.globals[index]})
+}
+
+// recordFrame remembers the frame at an index of the frames before the current instruction replaces it
+func (vm *VM) recordFrame(index int) {
+	vm.recording.frames = append(vm.recording.frames, frameChange{index: index, old: vm.frames[index]})
+}
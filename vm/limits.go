@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// memoryCheckInterval is the number of instructions executed between two checks of the memory limit,
+// measuring the memory walks every value the VM holds
+const memoryCheckInterval = 64 * cancelCheckInterval
+
+// SetMaxSteps limits the number of instructions executed by every RunContext and CallContext, 0 removes the limit.
+// Exceeding it stops the program with a runtime error, like a cancelled context.
+func (vm *VM) SetMaxSteps(steps int) {
+	vm.maxSteps = steps
+}
+
+// SetMaxMemory limits the estimated size in bytes of the values held by the globals and the stack of the VM,
+// 0 removes the limit. RunContext and CallContext measure it every memoryCheckInterval instructions and before
+// returning, exceeding it stops the program with a runtime error.
+func (vm *VM) SetMaxMemory(bytes int) {
+	vm.maxMemory = bytes
+}
+
+// CallContext calls fn like Call, but stops with the error of the context once it is cancelled and
+// checks the limits of SetMaxSteps and SetMaxMemory like RunContext.
+func (vm *VM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	vm.ctx, vm.ticks = ctx, 0
+	defer func() { vm.ctx = nil }()
+	result, err := vm.Call(fn, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := vm.checkMemory(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkMemory returns an error when the values held by the VM exceed the limit of SetMaxMemory
+func (vm *VM) checkMemory() error {
+	if vm.maxMemory <= 0 {
+		return nil
+	}
+
+	roots := make([]object.Object, 0, vm.sp)
+	for _, global := range vm.globals {
+		if global != nil {
+			roots = append(roots, global)
+		}
+	}
+	roots = append(roots, vm.stack[:vm.sp]...)
+	if used := object.SummarizeHeap(roots).Bytes; used > vm.maxMemory {
+		return fmt.Errorf("maximum memory (%d bytes) exceeded, the program holds %d bytes", vm.maxMemory, used)
+	}
+	return nil
+}
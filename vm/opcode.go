@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// OpcodeHandler executes a custom opcode. It receives the decoded operands of the instruction
+// and works on the stack of the VM with Push and Pop, leaving it as the stack effect the opcode was registered with.
+type OpcodeHandler func(vm *VM, operands []int) error
+
+// opcodeHandlers holds the handlers of the custom opcodes
+var opcodeHandlers = map[code.Opcode]OpcodeHandler{}
+
+// RegisterOpcode registers the handler that executes a custom opcode.
+// The opcode must already be defined with code.Register, so the compiler can emit it.
+func RegisterOpcode(op code.Opcode, handler OpcodeHandler) error {
+	if op < code.OpCustomStart {
+		return fmt.Errorf("opcode %d is reserved, custom opcodes start at %d", op, code.OpCustomStart)
+	}
+	if _, err := code.Lookup(byte(op)); err != nil {
+		return err
+	}
+	if _, ok := opcodeHandlers[op]; ok {
+		return fmt.Errorf("opcode %d already has a handler", op)
+	}
+
+	opcodeHandlers[op] = handler
+	return nil
+}
+
+// executeCustomOpcode decodes the operands of a custom opcode and runs its handler.
+// The instruction pointer is moved past the operands before the handler runs.
+func (vm *VM) executeCustomOpcode(op code.Opcode, operandBytes code.Instructions) error {
+	handler, ok := opcodeHandlers[op]
+	if !ok {
+		return fmt.Errorf("unknown opcode: %d", op)
+	}
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		return err
+	}
+
+	operands, read := code.ReadOperands(def, operandBytes)
+	vm.currentFrame().ip += read
+
+	return handler(vm, operands)
+}
+
+// Push pushes an element on the stack, it fails when the stack is full.
+// It is meant for the handlers of custom opcodes.
+func (vm *VM) Push(o object.Object) error {
+	return vm.push(o)
+}
+
+// Pop removes the element on top of the stack and returns it.
+// It is meant for the handlers of custom opcodes.
+func (vm *VM) Pop() object.Object {
+	return vm.pop()
+}
@@ -0,0 +1,104 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/code"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// workers forks the VM for a call of a parallel built-in function like pmap. Every worker is a VM of its own
+// running the same bytecode, which can be shared since its constants are frozen. The workers share the globals
+// of the VM, which is waiting for the built-in function to return, but the functions they run must not use them.
+type workers struct {
+	vm *VM
+}
+
+// fork creates a worker running fn, it fails when fn cannot run concurrently with other workers
+func (w *workers) fork(fn object.Object) (object.CallFunction, *object.Error) {
+	if err := checkParallel(fn, w.vm.constants, map[*object.CompiledFunction]bool{}); err != nil {
+		return nil, &object.Error{Message: fmt.Sprintf("cannot run %s on several workers: %s", callableName(fn), err)}
+	}
+
+	worker := NewWithGlobalStore(&compiler.Bytecode{Constants: w.vm.constants}, w.vm.globals)
+	worker.strict = w.vm.strict
+	worker.SetMaxFrames(len(w.vm.frames) - 1)
+	worker.ctx = w.vm.ctx
+	worker.logger = w.vm.logger
+	if w.vm.arena != nil {
+		worker.arena = &arena{}
+	}
+
+	return worker.callFunction, nil
+}
+
+// callParallel executes a parallel built-in function, which can fork workers running functions concurrently
+func (vm *VM) callParallel(builtin *object.Builtin, args []object.Object) object.Object {
+	w := &workers{vm: vm}
+	return builtin.CallParallel(vm.callFunction, w.fork, args...)
+}
+
+// checkParallel returns an error when fn cannot run on a worker: its instructions, the instructions of the
+// functions it defines or the functions it captured read or write globals, which another worker could be
+// writing, or fn is a built-in function keeping state between calls, like a memoized function.
+// The built-in functions of the registry and the pure ones can run anywhere.
+func checkParallel(fn object.Object, constants []object.Object, checked map[*object.CompiledFunction]bool) error {
+	switch fn := fn.(type) {
+	case *object.Closure:
+		if err := checkInstructions(fn.Fn, constants, checked); err != nil {
+			return err
+		}
+		for _, free := range fn.Free {
+			if err := checkParallel(free, constants, checked); err != nil {
+				return err
+			}
+		}
+	case *object.Builtin:
+		if fn.KeepsState() {
+			return fmt.Errorf("it keeps state between calls")
+		}
+	}
+	return nil
+}
+
+// checkInstructions returns an error when the instructions of the function, or of a function it defines, use a global
+func checkInstructions(fn *object.CompiledFunction, constants []object.Object, checked map[*object.CompiledFunction]bool) error {
+	if checked[fn] {
+		return nil
+	}
+	checked[fn] = true
+
+	ins := fn.Instructions
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return err
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		switch code.Opcode(ins[i]) {
+		case code.OpGetGlobal, code.OpSetGlobal:
+			return fmt.Errorf("it uses global variables")
+		case code.OpClosure:
+			if inner, ok := constants[operands[0]].(*object.CompiledFunction); ok {
+				if err := checkInstructions(inner, constants, checked); err != nil {
+					return err
+				}
+			}
+		}
+		i += 1 + read
+	}
+	return nil
+}
+
+// callableName returns the name of a function for error messages
+func callableName(fn object.Object) string {
+	if closure, ok := fn.(*object.Closure); ok && closure.Fn.Name != "" {
+		return closure.Fn.Name
+	}
+	if _, ok := fn.(*object.Builtin); ok {
+		return "a built-in function"
+	}
+	return object.AnonymousFunctionName
+}
@@ -1,20 +1,22 @@
 package vm
 
 import (
+	"context"
 	"fmt"
+	"math"
 
 	"github.com/yourfavoritedev/golang-interpreter/code"
 	"github.com/yourfavoritedev/golang-interpreter/compiler"
 	"github.com/yourfavoritedev/golang-interpreter/object"
 )
 
-const StackSize = 2048    // arbitrary number
+const StackSize = 2048    // the initial number of stack slots, the stack grows with the calls
 const GlobalsSize = 65536 // upper limit on the number of global bindings since operands are 16 bits wide.
-const MaxFrames = 1024    // arbitrary number
+const MaxFrames = 1024    // arbitrary number, the default maximum recursion depth
 
-var True = &object.Boolean{Value: true}
-var False = &object.Boolean{Value: false}
-var Null = &object.Null{}
+var True = object.TRUE
+var False = object.FALSE
+var Null = object.NULL
 
 // VM is the struct for our virtual-machine. It holds the bytecode instructions and constants-pool generated by the compiler.
 // A VM implements a stack, as it executes the bytecode, it organizes (push, pop, etc) the evaluated constants on the stack.
@@ -33,18 +35,50 @@ type VM struct {
 	frames []*Frame
 	// frameIndex refers to the position of the current frame the VM is working in
 	framesIndex int
+	// arena allocates the Integer and String results of operations, it is nil unless SetArena enabled it.
+	arena *arena
+	// ctx cancels the execution started by RunContext, it is checked every cancelCheckInterval instructions.
+	// ticks counts the instructions executed since, maxSteps and maxMemory are the limits of SetMaxSteps
+	// and SetMaxMemory, checked with the context.
+	ctx       context.Context
+	ticks     int
+	maxSteps  int
+	maxMemory int
+	// signals holds the handlers the program registered with `on_signal`, it is only set by RunContext while it runs
+	// and by SetSignals, never for the workers of pmap. handlesSignals tells the checks of the context to call the handlers, it is false while
+	// a handler runs and for the workers of pmap, which leave the signals to the VM they serve.
+	signals        *object.Signals
+	handlesSignals bool
+	// logger is the Logger of the logging built-in functions, a copy of object.Log unless SetLogger replaced it.
+	// The workers of pmap share the logger of the VM they serve.
+	logger *object.Logger
+	// history records the most recently executed instructions for stepping back, it is nil when disabled.
+	// recording is the entry of the instruction being executed while history is enabled.
+	history   *history
+	recording *HistoryEntry
+	// breakpoints holds the instructions at which execution stops, resuming skips the breakpoint of the next instruction.
+	breakpoints map[breakpoint]bool
+	resuming    bool
+	// watchpoints holds the variables whose writes stop execution, watchHit describes the last write that did.
+	watchpoints map[watchpoint]string
+	watchHit    *WatchHit
+	// strict makes indexing an array out of range and reading a missing hash key errors instead of null
+	strict bool
+	// counts is the work done since it was last reported with object.AddCounts
+	counts object.Counts
 }
 
 // New initializes a new VM using the bytecode generated by the compiler.
 // VMs are initialized with an sp of 0 (the initial top). The stack
-// will have a preallocated number of elements (StackSize).
+// will have a preallocated number of elements (StackSize), it grows as the calls need it.
 func New(bytecode *compiler.Bytecode) *VM {
 	// constuct a "main frame" with the bytecode instructions
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
-	frames := make([]*Frame, MaxFrames)
+	// the main frame occupies the first frame, MaxFrames are left for function calls
+	frames := make([]*Frame, MaxFrames+1)
 	frames[0] = mainFrame
 
 	return &VM{
@@ -54,6 +88,7 @@ func New(bytecode *compiler.Bytecode) *VM {
 		globals:     make([]object.Object, GlobalsSize),
 		frames:      frames,
 		framesIndex: 1,
+		logger:      object.Log.Copy(),
 	}
 }
 
@@ -65,8 +100,12 @@ func (vm *VM) currentFrame() *Frame {
 
 // pushFrame adds a new frame to the VM's frames and preps the VM for a future frame to be added.
 func (vm *VM) pushFrame(f *Frame) {
+	if vm.recording != nil {
+		vm.recordFrame(vm.framesIndex)
+	}
 	vm.frames[vm.framesIndex] = f
 	vm.framesIndex++
+	vm.counts.Frames++
 }
 
 // popFrame returns the current frame and makes its position available for a future frame to be added.
@@ -79,16 +118,116 @@ func (vm *VM) popFrame() *Frame {
 // the specific instructions (opcode + operands) that it was provided
 // from the compiler. It executes the fetch-decode-execute cycle.
 func (vm *VM) Run() error {
+	return vm.run(0)
+}
+
+// cancelCheckInterval is the number of instructions executed between two checks for cancellation,
+// checking a context is much slower than executing an instruction
+const cancelCheckInterval = 1024
+
+// RunContext runs the VM like Run, but stops with the error of the context once it is cancelled.
+// The VM is left in the middle of the program, it must not be run again. The handlers the program
+// registers with `on_signal` are called while it runs, on the same checks as the cancellation,
+// and removed when it returns unless they were set with SetSignals.
+func (vm *VM) RunContext(ctx context.Context) error {
+	if vm.signals == nil {
+		vm.signals = object.NewSignals()
+		defer func() {
+			vm.signals.Stop()
+			vm.signals = nil
+		}()
+	}
+	vm.ctx, vm.ticks = ctx, 0
+	vm.handlesSignals = true
+	defer func() { vm.ctx, vm.handlesSignals = nil, false }()
+	if err := vm.run(0); err != nil {
+		return err
+	}
+	return vm.checkMemory()
+}
+
+// SetSignals makes RunContext register the handlers of `on_signal` with signals, which keeps them once it
+// returns. A REPL shares its signals between the VMs running its inputs, and stops them itself.
+func (vm *VM) SetSignals(signals *object.Signals) {
+	vm.signals = signals
+}
+
+// SetLogger makes the logging built-in functions of the program write to logger, and `log_level` change its level.
+// Embedders give every VM its own, the VMs of a pool for instance, to configure the logs of each of them.
+func (vm *VM) SetLogger(logger *object.Logger) {
+	vm.logger = logger
+}
+
+// handleSignals calls the handlers of the signals the process received, between two instructions.
+// A handler runs like the callback of a higher-order built-in function, its failure stops the program.
+// No other signal is handled until the handler returns.
+func (vm *VM) handleSignals() error {
+	vm.handlesSignals = false
+	defer func() { vm.handlesSignals = true }()
+
+	for {
+		handler, ok := vm.signals.Next()
+		if !ok {
+			return nil
+		}
+		if err := abortError(vm.callFunction(handler)); err != nil {
+			return err
+		}
+	}
+}
+
+// run executes the fetch-decode-execute cycle until the instructions of the main frame are exhausted
+// or until the number of frames drops to stopFrames. The latter allows running a single function call
+// to completion when a higher-order built-in function calls back into the VM.
+func (vm *VM) run(stopFrames int) error {
 	var ip int
-	var ins code.Instructions
 	var op code.Opcode
+	// frame and ins are the current frame and its instructions. They are cached across iterations and only
+	// refreshed by the instructions that change the current frame: calls and returns.
+	frame := vm.currentFrame()
+	ins := frame.Instructions()
+
+	// only the outermost run is debugged, the callbacks of higher-order built-in functions belong to their call instruction
+	debugging := stopFrames == 0 && (vm.history != nil || vm.breakpoints != nil)
+	if debugging {
+		defer vm.endInstruction()
+	}
+	// the work of a run is reported when it stops, as the workers of pmap may not call any built-in function
+	defer vm.reportCounts()
 
 	// iterate through all instructions in the current frame.
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
-		vm.currentFrame().ip++
+	for vm.framesIndex > stopFrames && frame.ip < len(ins)-1 {
+		if debugging {
+			if vm.beginInstruction(frame.ip + 1) {
+				return ErrBreakpoint
+			}
+		}
 
-		ip = vm.currentFrame().ip
-		ins = vm.currentFrame().Instructions()
+		if vm.ctx != nil {
+			vm.ticks++
+			if vm.maxSteps > 0 && vm.ticks > vm.maxSteps {
+				return fmt.Errorf("maximum number of steps (%d) exceeded", vm.maxSteps)
+			}
+			if vm.ticks%cancelCheckInterval == 0 {
+				if err := vm.ctx.Err(); err != nil {
+					return err
+				}
+				if vm.ticks%memoryCheckInterval == 0 {
+					if err := vm.checkMemory(); err != nil {
+						return err
+					}
+				}
+				if vm.handlesSignals {
+					if err := vm.handleSignals(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		frame.ip++
+		ip = frame.ip
+		vm.counts.Instructions++
 
 		// FETCH the instruction (opcode + operand) at the specific position (ip, the instruction pointer)
 		// then convert the instruction's first-byte into an Opcode (which is what we expect it to be)
@@ -102,7 +241,7 @@ func (vm *VM) Run() error {
 			// decode the operand, getting back the identifier for the constant's position in the constants pool
 			constIndex := code.ReadUint16(operand)
 			// increment the instruction-pointer by 2 because OpConstant has one two-byte wide operand
-			vm.currentFrame().ip += 2
+			frame.ip += 2
 			// EXECUTE, grab the constant from the pool and push it on to the stack
 			err := vm.push(vm.constants[constIndex])
 			if err != nil {
@@ -110,19 +249,33 @@ func (vm *VM) Run() error {
 			}
 
 		// Execute the binary operation for the Opcode arithmetic instruction.
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpFloorDiv:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
 			}
 
 		// Execute the comparison operation for the Opcode comparison instruction.
-		case code.OpGreaterThan, code.OpEqual, code.OpNotEqual:
+		case code.OpGreaterThan, code.OpGreaterOrEqual, code.OpEqual, code.OpNotEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
 			}
 
+		// Execute the bitwise operation for the Opcode bitwise instruction.
+		case code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			err := vm.executeBitwiseOperation(op)
+			if err != nil {
+				return err
+			}
+
+		// Execute the complement "~" operation for this Opcode instruction.
+		case code.OpBitNot:
+			err := vm.executeBitNotOperator()
+			if err != nil {
+				return err
+			}
+
 		// Execute the minus "-" operation for this Opcode instruction.
 		case code.OpMinus:
 			err := vm.executeMinusOperator()
@@ -157,7 +310,7 @@ func (vm *VM) Run() error {
 			// since we're in a loop that increments ip with each iteration, we need to set ip
 			// to the offset right before the one we want. That lets the loop do its work
 			// and ip gets set to the value we want in the next cycle to process that instruction
-			vm.currentFrame().ip = pos - 1
+			frame.ip = pos - 1
 
 		// Execute OpJumpNotTruthy instruction to jump to the next instruction byte after compiing a falsey condition.
 		case code.OpJumpNotTruthy:
@@ -166,31 +319,95 @@ func (vm *VM) Run() error {
 			pos := int(code.ReadUint16(operand))
 			// increment the instruction-pointer by 2 because OpJumpNotTruthy has one two-byte wide operand
 			// this would prepare us for the next iteration to evaluate the OpConstant - the result of a truthy condition
-			vm.currentFrame().ip += 2
+			frame.ip += 2
 
 			// pop the condition constant (True or False) and determine where we need to jump
 			condition := vm.pop()
 			if !isTruthy(condition) {
 				// jump pass the consequence when the condition is falsey to process the next instruction
-				vm.currentFrame().ip = pos - 1
+				frame.ip = pos - 1
+			}
+
+		// Execute OpJumpIfNull instruction to jump over the index operation of a safe navigation when the object is null.
+		case code.OpJumpIfNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			// the object is only peeked, a null stays on the stack as the value of the safe navigation
+			if vm.stack[vm.sp-1] == Null {
+				frame.ip = pos - 1
+			}
+
+		// Execute OpJumpIfFalsy and OpJumpIfTruthy instructions to jump over the right operand of && and ||
+		case code.OpJumpIfFalsy, code.OpJumpIfTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			// the left operand is only peeked, it stays on the stack as the value of the expression when jumping
+			if isTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpIfTruthy) {
+				frame.ip = pos - 1
+			}
+
+		// Execute OpJumpIfArgument instruction to jump over the default value of a parameter the call passed an argument for.
+		case code.OpJumpIfArgument:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			index := int(ins[ip+3])
+			frame.ip += 3
+
+			if index < frame.numArgs && (frame.skipped == nil || !frame.skipped[index]) {
+				frame.ip = pos - 1
+			}
+
+		// Execute OpIterate instruction, it replaces the collection on top of the stack with a cursor walking its elements
+		case code.OpIterate:
+			cursor, errObj := object.Iterate(vm.pop())
+			if errObj != nil {
+				return fmt.Errorf("%s", errObj.Message)
+			}
+			if err := vm.push(cursor); err != nil {
+				return err
+			}
+
+		// Execute OpIterNext instruction to push the next element of the cursor on top of the stack,
+		// or to jump past the loop once the cursor is exhausted
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			count := int(ins[ip+3])
+			frame.ip += 3
+
+			cursor := vm.stack[vm.sp-1].(*object.Cursor)
+			ok, err := vm.iterNext(cursor, count)
+			if err != nil {
+				return err
+			}
+			// jump past the loop when the cursor is exhausted
+			if !ok {
+				frame.ip = pos - 1
 			}
 
 		// Execute OpSetGlobal instruction
 		case code.OpSetGlobal:
 			// decode the operand to get back the global index associated with that identifier
 			globalIndex := code.ReadUint16(ins[ip+1:])
-			vm.currentFrame().ip += 2
+			frame.ip += 2
 
 			// pop the top element off the stack, which should be the value bound to an identifier
 			// and save that value in the vm's globals store under the specified index. Making it easy
 			// to retrieve when we need to push that value on to the stack again.
+			if vm.recording != nil {
+				vm.recordGlobal(int(globalIndex))
+			}
+			old := vm.globals[globalIndex]
 			vm.globals[globalIndex] = vm.pop()
+			if stopFrames == 0 && vm.watchpoints != nil && vm.hitWatchpoint(true, int(globalIndex), ip, old, vm.globals[globalIndex]) {
+				return ErrWatchpoint
+			}
 
 		// Execute OpGetGlobal instruction
 		case code.OpGetGlobal:
 			// decode the operand to get back the global index associated with that identifier
 			globalIndex := code.ReadUint16(ins[ip+1:])
-			vm.currentFrame().ip += 2
+			frame.ip += 2
 
 			// with an OpGetGlobal instruction, we can assume that vm.globals has already
 			// recorded the value associated with this identifier in its store at the
@@ -204,20 +421,24 @@ func (vm *VM) Run() error {
 		case code.OpSetLocal:
 			operand := ins[ip+1]
 			localIndex := int(operand)
-			vm.currentFrame().ip += 1
-
-			frame := vm.currentFrame()
+			frame.ip += 1
 
 			// set element in stack "hole" reserved for local binding value
+			if vm.recording != nil {
+				vm.recordStack(frame.basePointer + localIndex)
+			}
+			old := vm.stack[frame.basePointer+localIndex]
 			vm.stack[frame.basePointer+localIndex] = vm.pop()
+			if stopFrames == 0 && vm.watchpoints != nil && vm.hitWatchpoint(false, localIndex, ip, old, vm.stack[frame.basePointer+localIndex]) {
+				return ErrWatchpoint
+			}
 
 		// Execute OpGetLocal instruction
 		case code.OpGetLocal:
 			operand := ins[ip+1]
 			localIndex := int(operand)
-			vm.currentFrame().ip += 1
+			frame.ip += 1
 
-			frame := vm.currentFrame()
 			// push the value in the "hole" to the stack
 			err := vm.push(vm.stack[frame.basePointer+localIndex])
 			if err != nil {
@@ -228,7 +449,7 @@ func (vm *VM) Run() error {
 		case code.OpGetBuiltin:
 			operand := ins[ip+1]
 			builtinIndex := int(operand)
-			vm.currentFrame().ip += 1
+			frame.ip += 1
 			// use index to grab the built-in function from the object.Builtins slice
 			definition := object.Builtins[builtinIndex]
 			// push the built-in function to the stack
@@ -237,13 +458,22 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpGetModule:
+			operand := ins[ip+1]
+			frame.ip += 1
+			// use index to grab the module from the object.Modules slice and push it to the stack
+			err := vm.push(object.Modules[int(operand)])
+			if err != nil {
+				return err
+			}
+
 		// Execute OpArray instruction, it should construct an array and push it on to the stack,
 		// using the values (if any) that were previously loaded.
 		case code.OpArray:
 			// derive the number of elements to pull from the operand
 			operand := ins[ip+1:]
 			numElements := int(code.ReadUint16(operand))
-			vm.currentFrame().ip += 2
+			frame.ip += 2
 
 			// construct a new array using elements on the stack, buildArray needs a starting index and non-inclusive ending index
 			array := vm.buildArray(vm.sp-numElements, vm.sp)
@@ -262,7 +492,7 @@ func (vm *VM) Run() error {
 			// derive the number of elements to pull from the operand
 			operand := ins[ip+1:]
 			numElements := int(code.ReadUint16(operand))
-			vm.currentFrame().ip += 2
+			frame.ip += 2
 
 			// construct a new map using elements on the stack, buildHash needs a starting index and non-inclusive ending index
 			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
@@ -289,6 +519,70 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		// Execute OpSlice instruction, it pops the high bound, the low bound and the sequence being sliced
+		// and pushes the slice onto the stack.
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSliceExpression(left, low, high)
+			if err != nil {
+				return err
+			}
+
+		// Execute the OpIndexKey instruction. It indexes the object on top of the stack with a string key
+		// from the constants pool whose HashKey was computed by the compiler.
+		case code.OpIndexKey:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			key := vm.constants[constIndex].(*object.StringKey)
+			left := vm.pop()
+
+			err := vm.executeKeyIndex(left, key)
+			if err != nil {
+				return err
+			}
+
+		// Execute the OpRecord instruction. It builds a record with the shape from the constants pool
+		// out of the values of its fields on the stack.
+		case code.OpRecord:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFields := int(code.ReadUint16(ins[ip+3:]))
+			frame.ip += 4
+
+			values := make([]object.Object, numFields)
+			copy(values, vm.stack[vm.sp-numFields:vm.sp])
+			vm.sp = vm.sp - numFields
+
+			shape := vm.constants[constIndex].(*object.RecordShape)
+			err := vm.push(&object.Record{Shape: shape, Values: values})
+			if err != nil {
+				return err
+			}
+
+		// Execute the OpGetField instruction. It replaces the record on top of the stack with the value
+		// of its field at the offset the compiler computed from the shape of the record.
+		// Any other value is accessed by the name of the field at that offset, like OpIndexKey does.
+		case code.OpGetField:
+			shape := vm.constants[code.ReadUint16(ins[ip+1:])].(*object.RecordShape)
+			offset := int(code.ReadUint16(ins[ip+3:]))
+			frame.ip += 4
+
+			obj := vm.pop()
+			// the name may have been assigned another value since the field access was compiled,
+			// the field is then looked up by its name like any other member access
+			var err error
+			if record, ok := obj.(*object.Record); ok && record.Shape == shape {
+				err = vm.push(record.Values[offset])
+			} else {
+				err = vm.executeKeyIndex(obj, object.NewStringKey(shape.Fields[offset]))
+			}
+			if err != nil {
+				return err
+			}
+
 		// Execute OpClosure instruction. This is the designated instruction that will grab the existing object.CompiledFunction
 		// from the constants pool, enclose it in a Closure and push it on to the stack.
 		case code.OpClosure:
@@ -296,7 +590,7 @@ func (vm *VM) Run() error {
 			constIndex := code.ReadUint16(ins[ip+1:])
 			// grab the number of free variables used by this closure
 			numFree := ins[ip+3]
-			vm.currentFrame().ip += 3
+			frame.ip += 3
 			// push closure to stack
 			err := vm.pushClosure(int(constIndex), int(numFree))
 			if err != nil {
@@ -307,10 +601,10 @@ func (vm *VM) Run() error {
 		case code.OpGetFree:
 			operand := ins[ip+1]
 			freeIndex := int(operand)
-			vm.currentFrame().ip += 1
+			frame.ip += 1
 
 			// grab free-variable from currentClosure and push it to the stack
-			currentClosure := vm.currentFrame().cl
+			currentClosure := frame.cl
 			err := vm.push(currentClosure.Free[freeIndex])
 			if err != nil {
 				return err
@@ -319,7 +613,7 @@ func (vm *VM) Run() error {
 		// Execute OpCurrentClosure instruction
 		case code.OpCurrentClosure:
 			// grab the current closure being executed and push it to the stack
-			currentClosure := vm.currentFrame().cl
+			currentClosure := frame.cl
 			err := vm.push(currentClosure)
 			if err != nil {
 				return err
@@ -331,12 +625,29 @@ func (vm *VM) Run() error {
 			// get the number of arguments expected by the function. we need them to effectively find the function constant on the stack
 			operand := ins[ip+1]
 			numArgs := int(operand)
-			vm.currentFrame().ip += 1
+			frame.ip += 1
 			// execute the function
 			err := vm.executeCall(int(numArgs))
 			if err != nil {
 				return err
 			}
+			// calling a closure entered its frame
+			frame = vm.currentFrame()
+			ins = frame.Instructions()
+
+		// Execute OpCallNamed instruction, it calls the function like OpCall once the named arguments,
+		// the last ones on the stack, are placed at the positions of their parameters
+		case code.OpCallNamed:
+			numArgs := int(ins[ip+1])
+			constIndex := code.ReadUint16(ins[ip+2:])
+			frame.ip += 3
+			names, _ := vm.constants[constIndex].(*object.Array)
+			err := vm.executeCallNamed(numArgs, names)
+			if err != nil {
+				return err
+			}
+			frame = vm.currentFrame()
+			ins = frame.Instructions()
 
 		// Execute OpReturnValue instruction. It should pop the returnValue sitting before the stack pointer and exit
 		// the inner-execution context accordingly.
@@ -344,7 +655,7 @@ func (vm *VM) Run() error {
 			// pop the return value object sitting before sp and adjust sp
 			returnValue := vm.pop()
 			// pop the frame so the loop can leave this inner execution context
-			frame := vm.popFrame()
+			vm.popFrame()
 			// the frame.basePointer is the index where the compiledFunctions work(the "hole" and all values produced in the function) starts.
 			// that means frame.basePointer - 1 should be where the compiledFunction constant is on the stack. Upon successful execution of the call-expression,
 			// we need to replace the function constant with the actual returnValue. Thus the stack-pointer (sp) needs to be updated to
@@ -354,16 +665,21 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+			// the loop resumes in the frame of the caller
+			frame = vm.currentFrame()
+			ins = frame.Instructions()
 
 		// Execute OpReturn instruction. It should just push a Null value to the stack for the function.
 		case code.OpReturn:
-			frame := vm.popFrame()
+			vm.popFrame()
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(Null)
 			if err != nil {
 				return err
 			}
+			frame = vm.currentFrame()
+			ins = frame.Instructions()
 
 		// Execute the OpNull instructin. Simply push the Null constant on to the stack
 		case code.OpNull:
@@ -376,6 +692,24 @@ func (vm *VM) Run() error {
 		case code.OpPop:
 			// EXECUTE: pop the element before the stack pointer
 			vm.pop()
+
+		// Execute OpDup instruction, it pushes the element on top of the stack again
+		case code.OpDup:
+			err := vm.push(vm.stack[vm.sp-1])
+			if err != nil {
+				return err
+			}
+
+		// any other opcode must be a custom opcode with a registered handler
+		default:
+			err := vm.executeCustomOpcode(op, ins[ip+1:])
+			if err != nil {
+				return err
+			}
+		}
+
+		if debugging {
+			vm.endInstruction()
 		}
 	}
 
@@ -399,10 +733,13 @@ func isTruthy(obj object.Object) bool {
 // next available slot in the stack, finally it preps the stackpointer (sp),
 // incrementing it to designate the next slot to be allocated
 func (vm *VM) push(o object.Object) error {
-	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if vm.sp >= len(vm.stack) {
+		vm.growStack(vm.sp + 1)
 	}
 
+	if vm.recording != nil {
+		vm.recordStack(vm.sp)
+	}
 	vm.stack[vm.sp] = o
 	vm.sp++
 
@@ -439,6 +776,9 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	switch {
 	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	// a float operand promotes an integer operand to a float
+	case leftType == object.FLOAT_OBJ && object.IsNumber(right), rightType == object.FLOAT_OBJ && object.IsNumber(left):
+		return vm.executeBinaryFloatOperation(op, left, right)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
 	default:
@@ -468,13 +808,52 @@ func (vm *VM) executeBinaryIntegerOperation(
 	case code.OpMul:
 		result = leftValue * rightValue
 	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
 		result = leftValue / rightValue
+	case code.OpFloorDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result, _ = object.FloorDivide(leftValue, rightValue)
 	default:
 		return fmt.Errorf("unknown integer operation: %d", op)
 	}
 
 	// push the Object to the stack
-	return vm.push(&object.Integer{Value: result})
+	return vm.push(vm.newInteger(result))
+}
+
+// executeBinaryFloatOperation performs an arithmetic operation between two numbers of which at least one
+// is a float, the integer operand is promoted to a float. The float result is pushed to the stack.
+func (vm *VM) executeBinaryFloatOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue, _ := object.ToFloat(left)
+	rightValue, _ := object.ToFloat(right)
+
+	var result float64
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	case code.OpFloorDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		result = math.Floor(leftValue / rightValue)
+	default:
+		return fmt.Errorf("unknown float operation: %d", op)
+	}
+
+	return vm.push(&object.Float{Value: result})
 }
 
 // executeBinaryStringOperation will assert that the provided Objects are
@@ -493,7 +872,7 @@ func (vm *VM) executeBinaryStringOperation(
 	rightValue := right.(*object.String).Value
 
 	// push the Object to the stack
-	return vm.push(&object.String{Value: fmt.Sprint(leftValue, rightValue)})
+	return vm.push(vm.newString(leftValue + rightValue))
 }
 
 // executeComparison will compare the two constants directly before the stack-pointer
@@ -509,6 +888,34 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	if leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ {
 		return vm.executeIntegerComparison(op, left, right)
 	}
+	if (leftType == object.FLOAT_OBJ && object.IsNumber(right)) || (rightType == object.FLOAT_OBJ && object.IsNumber(left)) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
+	// strings are equal when their values are, not only when they are the same object
+	if leftString, ok := left.(*object.String); ok {
+		if rightString, ok := right.(*object.String); ok {
+			switch op {
+			case code.OpEqual:
+				return vm.push(nativeBoolToBooleanObject(leftString.Value == rightString.Value))
+			case code.OpNotEqual:
+				return vm.push(nativeBoolToBooleanObject(leftString.Value != rightString.Value))
+			}
+		}
+	}
+
+	// records are equal when their fields are
+	if leftRecord, ok := left.(*object.Record); ok {
+		if rightRecord, ok := right.(*object.Record); ok {
+			equal := leftRecord.Equal(rightRecord)
+			switch op {
+			case code.OpEqual:
+				return vm.push(nativeBoolToBooleanObject(equal))
+			case code.OpNotEqual:
+				return vm.push(nativeBoolToBooleanObject(!equal))
+			}
+		}
+	}
 
 	// compare of pointer-addresses. For boolean objects,
 	// right and left are holding the constants TRUE and FALSE listed, and we
@@ -538,6 +945,8 @@ func (vm *VM) executeIntegerComparison(
 	switch op {
 	case code.OpGreaterThan:
 		result = nativeBoolToBooleanObject(leftValue > rightValue)
+	case code.OpGreaterOrEqual:
+		result = nativeBoolToBooleanObject(leftValue >= rightValue)
 	case code.OpEqual:
 		result = nativeBoolToBooleanObject(leftValue == rightValue)
 	case code.OpNotEqual:
@@ -550,6 +959,28 @@ func (vm *VM) executeIntegerComparison(
 
 }
 
+// executeFloatComparison compares two numbers of which at least one is a float, promoting the integer operand
+func (vm *VM) executeFloatComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue, _ := object.ToFloat(left)
+	rightValue, _ := object.ToFloat(right)
+
+	switch op {
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
 // nativeBoolToBooleanObject simply converts a traditional boolean
 // to an *object.Boolean
 func nativeBoolToBooleanObject(b bool) *object.Boolean {
@@ -584,13 +1015,63 @@ func (vm *VM) executeBangOperator() error {
 func (vm *VM) executeMinusOperator() error {
 	right := vm.pop()
 
+	if float, ok := right.(*object.Float); ok {
+		return vm.push(&object.Float{Value: -float.Value})
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return fmt.Errorf("unsupported type for negation: %s", right.Type())
 	}
 
 	rightValue := right.(*object.Integer).Value
 
-	return vm.push(&object.Integer{Value: -rightValue})
+	return vm.push(vm.newInteger(-rightValue))
+}
+
+// executeBitwiseOperation pops two integers and pushes the result of the bitwise operation or the shift
+// between them. Unlike arithmetic, bitwise operations do not accept floats.
+func (vm *VM) executeBitwiseOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftValue, ok := left.(*object.Integer)
+	rightValue, ok2 := right.(*object.Integer)
+	if !ok || !ok2 {
+		return fmt.Errorf("unsupported types for bitwise operation: %s, %s", left.Type(), right.Type())
+	}
+
+	var result int64
+	switch op {
+	case code.OpBitAnd:
+		result = leftValue.Value & rightValue.Value
+	case code.OpBitOr:
+		result = leftValue.Value | rightValue.Value
+	case code.OpBitXor:
+		result = leftValue.Value ^ rightValue.Value
+	case code.OpShiftLeft, code.OpShiftRight:
+		if rightValue.Value < 0 {
+			return fmt.Errorf("negative shift count: %d", rightValue.Value)
+		}
+		if op == code.OpShiftLeft {
+			result = leftValue.Value << rightValue.Value
+		} else {
+			result = leftValue.Value >> rightValue.Value
+		}
+	default:
+		return fmt.Errorf("unknown bitwise operation: %d", op)
+	}
+	return vm.push(vm.newInteger(result))
+}
+
+// executeBitNotOperator handles the execution of an instruction for an OpBitNot Opcode.
+// It pops an integer and pushes its complement, every bit of it flipped.
+func (vm *VM) executeBitNotOperator() error {
+	right := vm.pop()
+
+	integer, ok := right.(*object.Integer)
+	if !ok {
+		return fmt.Errorf("unsupported type for complement: %s", right.Type())
+	}
+	return vm.push(vm.newInteger(^integer.Value))
 }
 
 // buildArray constructs a new Object.Array using existing elements
@@ -619,6 +1100,12 @@ func (vm *VM) buildHash(
 		value := vm.stack[i+1]
 		pair := object.HashPair{Key: key, Value: value}
 
+		// string literal keys come with their hash key, the hash stores the string itself
+		if stringKey, ok := key.(*object.StringKey); ok {
+			hashedPairs[stringKey.Key] = object.HashPair{Key: stringKey.String, Value: value}
+			continue
+		}
+
 		// build hashKey
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
@@ -632,6 +1119,32 @@ func (vm *VM) buildHash(
 	return &object.Hash{Pairs: hashedPairs}, nil
 }
 
+// executeKeyIndex indexes left with a precomputed string key. Hashes are looked up without hashing
+// the key again, any other object is indexed with the key's string like a regular index operation.
+func (vm *VM) executeKeyIndex(left object.Object, key *object.StringKey) error {
+	if record, ok := left.(*object.Record); ok {
+		value, ok := record.Field(key.String.Value)
+		if !ok {
+			return fmt.Errorf("record has no field %s", key.String.Value)
+		}
+		return vm.push(value)
+	}
+
+	hash, ok := left.(*object.Hash)
+	if !ok {
+		return vm.executeIndexExpression(left, key.String)
+	}
+
+	pair, ok := hash.Pairs[key.Key]
+	if !ok {
+		if vm.strict {
+			return fmt.Errorf("%s", object.MissingKeyMessage(key.String))
+		}
+		return vm.push(Null)
+	}
+	return vm.push(pair.Value)
+}
+
 // executeIndexExpression performs an index operation with the provided arguments.
 // Depending on the type of the arguments, it will delegate execute to the
 // matching helper method.
@@ -641,23 +1154,58 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 		return vm.executeArrayIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		module := left.(*object.Module)
+		member, ok := module.Member(index.(*object.String).Value)
+		if !ok {
+			return fmt.Errorf("undefined member %s in module %s", index.Inspect(), module.Name)
+		}
+		return vm.push(member)
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
 	}
 }
 
+// executeSliceExpression pushes the elements of the array from the low bound up until the high bound
+// (not inclusive) as a new array, a null bound is the start or the end of the array.
+// The slice of a string is the substring between the bounds, which count bytes like len does.
+func (vm *VM) executeSliceExpression(left, low, high object.Object) error {
+	switch left := left.(type) {
+	case *object.Array:
+		lo, hi, err := object.SliceBounds("array", low, high, len(left.Elements), vm.strict)
+		if err != nil {
+			return err
+		}
+		elements := make([]object.Object, hi-lo)
+		copy(elements, left.Elements[lo:hi])
+		return vm.push(&object.Array{Elements: elements})
+	case *object.String:
+		lo, hi, err := object.SliceBounds("string", low, high, len(left.Value), vm.strict)
+		if err != nil {
+			return err
+		}
+		return vm.push(&object.String{Value: left.Value[lo:hi]})
+	default:
+		return fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
+}
+
 // executeArrayIndex is the helper method that performs an index operation
 // on an array object and pushes the result to the stack
 func (vm *VM) executeArrayIndex(left, index object.Object) error {
 	arrayObject := left.(*object.Array)
 	i := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
 
-	if i < 0 || i > max {
+	// a negative index counts from the end of the array
+	element, ok := object.ArrayIndex(i, len(arrayObject.Elements))
+	if !ok {
+		if vm.strict {
+			return fmt.Errorf("%s", object.OutOfRangeMessage(i, len(arrayObject.Elements)))
+		}
 		return vm.push(Null)
 	}
 
-	return vm.push(arrayObject.Elements[i])
+	return vm.push(arrayObject.Elements[element])
 }
 
 // executeHashIndex is the helper method that performs an index operation
@@ -672,6 +1220,9 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
+		if vm.strict {
+			return fmt.Errorf("%s", object.MissingKeyMessage(index))
+		}
 		return vm.push(Null)
 	}
 
@@ -693,18 +1244,97 @@ func (vm *VM) executeCall(numArgs int) error {
 	}
 }
 
+// executeCallNamed is invoked when the VM executes the OpCallNamed expression. The arguments of the closure on the
+// stack are rearranged in the order of its parameters, the slots of the parameters left out hold null and are
+// marked as skipped in the frame of the call, so the function evaluates their default values.
+func (vm *VM) executeCallNamed(numArgs int, names *object.Array) error {
+	if names == nil || len(names.Elements) == 0 || len(names.Elements) > numArgs {
+		return fmt.Errorf("invalid names of named arguments")
+	}
+	cl, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok {
+		if _, ok := vm.stack[vm.sp-1-numArgs].(*object.Builtin); ok {
+			return fmt.Errorf("built-in functions do not accept named arguments, got %s", names.Elements[0].Inspect())
+		}
+		return fmt.Errorf("calling non-function and non-built-in")
+	}
+
+	named := make([]string, len(names.Elements))
+	for i, name := range names.Elements {
+		named[i] = name.Inspect()
+	}
+	required := cl.Fn.NumParameters - cl.Fn.NumDefaults
+	if cl.Fn.Variadic {
+		required--
+	}
+	if len(cl.Fn.Parameters) != cl.Fn.NumParameters {
+		return fmt.Errorf("the parameter names of the function are unknown, it cannot be called with named arguments")
+	}
+	basePointer := vm.sp - numArgs
+	arranged, err := object.ArrangeArguments(cl.Fn.Parameters, required, cl.Fn.Variadic, vm.stack[basePointer:vm.sp], named)
+	if err != nil {
+		return err
+	}
+	if basePointer+len(arranged) > len(vm.stack) {
+		vm.growStack(basePointer + len(arranged))
+	}
+
+	skipped := make([]bool, len(arranged))
+	for i, arg := range arranged {
+		if arg == nil {
+			arg, skipped[i] = Null, true
+		}
+		vm.stack[basePointer+i] = arg
+	}
+	vm.sp = basePointer + len(arranged)
+	if err := vm.callClosure(cl, len(arranged)); err != nil {
+		return err
+	}
+	vm.currentFrame().skipped = skipped
+	return nil
+}
+
 // callClosure creates a new frame for the calling function and updates the stack-pointer accordingly
 // so the VM can execute the function.
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
-			cl.Fn.NumParameters, numArgs)
+	// a variadic function accepts any number of arguments after its fixed parameters
+	fixed, total := cl.Fn.NumParameters, cl.Fn.NumParameters
+	if cl.Fn.Variadic {
+		fixed, total = fixed-1, -1
+	}
+	required := fixed - cl.Fn.NumDefaults
+	if numArgs < required || (!cl.Fn.Variadic && numArgs > fixed) {
+		return fmt.Errorf("wrong number of arguments: %s", object.ArgumentsMessage(required, total, numArgs))
+	}
+
+	if vm.framesIndex >= len(vm.frames) {
+		return fmt.Errorf("%s", object.RecursionDepthMessage(len(vm.frames)-1, vm.callTrace(cl)))
 	}
 
 	basePointer := vm.sp - numArgs
+	// make room for the function's locals and its deepest use of the stack before the call is made,
+	// so the stack is only limited by the number of frames and deep recursion fails with the depth error
+	if size := basePointer + cl.Fn.NumLocals + cl.Fn.MaxStack; size > len(vm.stack) {
+		vm.growStack(size)
+	}
+
 	// create a new frame for this function, we need to initialize the basePointer so
 	// it starts directly after the index of the function - being the start of its local-bindings.
 	frame := NewFrame(cl, basePointer)
+	frame.numArgs = numArgs
+	// the slots of the parameters without an argument hold null until their defaults are evaluated,
+	// so a default reading a later parameter never sees a value left on the stack
+	for i := numArgs; i < fixed; i++ {
+		vm.stack[basePointer+i] = Null
+	}
+	// the arguments following the fixed parameters are collected in a new array, bound to the variadic parameter
+	if cl.Fn.Variadic {
+		rest := []object.Object{}
+		if numArgs > fixed {
+			rest = append(rest, vm.stack[basePointer+fixed:basePointer+numArgs]...)
+		}
+		vm.stack[basePointer+fixed] = &object.Array{Elements: rest}
+	}
 	vm.pushFrame(frame)
 	// the stack pointer is `increased` to allocate space ("the hole") for the local-bindings and any new values
 	// generated in the function will start at the updated stack pointer (above the "hole").
@@ -712,6 +1342,49 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	return nil
 }
 
+// growStack replaces the stack with one holding at least size slots, doubling it to keep the copies rare
+func (vm *VM) growStack(size int) {
+	grown := 2 * len(vm.stack)
+	for grown < size {
+		grown *= 2
+	}
+	stack := make([]object.Object, grown)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+// reportCounts adds the work done since the last report to the totals of object.AddCounts
+func (vm *VM) reportCounts() {
+	object.AddCounts(vm.counts)
+	vm.counts = object.Counts{}
+}
+
+// callTrace returns the names of the functions of all active frames, excluding the main frame,
+// followed by the name of the function about to be called.
+func (vm *VM) callTrace(cl *object.Closure) []string {
+	trace := make([]string, 0, vm.framesIndex)
+	for _, frame := range vm.frames[1:vm.framesIndex] {
+		trace = append(trace, frame.cl.Fn.Name)
+	}
+	return append(trace, cl.Fn.Name)
+}
+
+// SetMaxFrames changes the maximum number of nested function calls, the default is MaxFrames.
+// Exceeding it results in a runtime error reporting the recursion depth. It also bounds the size
+// of the stack, which grows with the calls.
+func (vm *VM) SetMaxFrames(maxFrames int) {
+	// the main frame occupies the first frame
+	frames := make([]*Frame, maxFrames+1)
+	copy(frames, vm.frames[:vm.framesIndex])
+	vm.frames = frames
+}
+
+// SetStrict switches strict mode on or off. In strict mode, indexing an array out of range and reading
+// a key missing from a hash are runtime errors instead of producing null.
+func (vm *VM) SetStrict(strict bool) {
+	vm.strict = strict
+}
+
 // pushClosure grabs a compiledFunction at the given constIndex in the constants pool,
 // wraps it in a Closure and pushes it onto the stack
 func (vm *VM) pushClosure(constIndex, numFree int) error {
@@ -738,8 +1411,19 @@ func (vm *VM) pushClosure(constIndex, numFree int) error {
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	// grab the arguments for this function on the stack
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	// execute the builtin function
-	result := builtin.Fn(args...)
+	// `runtime_stats` reports the work done up to its call
+	vm.reportCounts()
+	// execute the builtin function, higher-order built-in functions call back into the VM through callFunction
+	var result object.Object
+	if builtin.ParallelFn != nil {
+		result = vm.callParallel(builtin, args)
+	} else {
+		result = builtin.CallProgram(vm.callFunction, vm.signals, vm.logger, args...)
+	}
+	// a failed built-in function aborts execution, like the failed calls it made unless it recovered from them
+	if err := abortError(result); err != nil {
+		return err
+	}
 	// set sp to the position of the built-in function on the stack
 	vm.sp = vm.sp - numArgs - 1
 	// replace function with return value
@@ -752,6 +1436,96 @@ func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	return nil
 }
 
+// iterNext pushes the key and the value of the next element of the cursor when count is 2, only the value otherwise.
+// It reports false when the cursor has no elements left. A failed callback of a lazy iterator aborts the loop with its error.
+func (vm *VM) iterNext(cursor *object.Cursor, count int) (bool, error) {
+	key, value, ok := cursor.Next(vm.callFunction)
+	if !ok {
+		return false, nil
+	}
+	if err := abortError(value); err != nil {
+		return false, err
+	}
+	if count == 2 {
+		if err := vm.push(key); err != nil {
+			return false, err
+		}
+	}
+	return true, vm.push(value)
+}
+
+// callFunction lets higher-order built-in functions call fn with the given arguments.
+// It pushes the function and its arguments on top of the stack, as an OpCall instruction expects them,
+// and runs the VM until the function returns. The stack and frames are restored when the call fails,
+// the failure is returned as an *object.Error.
+func (vm *VM) callFunction(fn object.Object, args ...object.Object) object.Object {
+	sp := vm.sp
+	framesIndex := vm.framesIndex
+
+	err := vm.push(fn)
+	for _, arg := range args {
+		if err != nil {
+			break
+		}
+		err = vm.push(arg)
+	}
+
+	if err == nil {
+		err = vm.executeCall(len(args))
+	}
+	// a closure call pushed a new frame, run it until it returns
+	if err == nil && vm.framesIndex > framesIndex {
+		err = vm.run(framesIndex)
+	}
+
+	if err != nil {
+		vm.sp = sp
+		vm.framesIndex = framesIndex
+		errObj := &object.Error{Message: err.Error()}
+		if exit, ok := err.(*ExitError); ok {
+			errObj.Exit, errObj.Code = true, exit.Code
+		}
+		return errObj
+	}
+
+	return vm.pop()
+}
+
+// Call calls fn with the given arguments once the VM has run its program and returns the result.
+// It lets Go code call back into the functions defined by the program, a failed call is returned as an error.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	result := vm.callFunction(fn, args...)
+	if err := abortError(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExitError is returned by Run when the program called the exit built-in function, Code is the exit status it asked for
+type ExitError struct {
+	Code int
+}
+
+// Error returns the exit status as a message
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// abortError returns the error that aborts execution after a built-in function returned result:
+// an ExitError when the program asked to exit, or the runtime error of the failed built-in function,
+// which may be the failure of a call it made. It returns nil when execution continues.
+func abortError(result object.Object) error {
+	errObj, ok := result.(*object.Error)
+	switch {
+	case !ok:
+		return nil
+	case errObj.Exit:
+		return &ExitError{Code: errObj.Code}
+	default:
+		return fmt.Errorf("%s", errObj.Message)
+	}
+}
+
 // NewWithGlobalStore keeps global state in the REPL so the VM can execute
 // with the byteode and global store from a previous compilation.
 func NewWithGlobalStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
@@ -759,3 +1533,21 @@ func NewWithGlobalStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	vm.globals = s
 	return vm
 }
+
+// Reset prepares the VM to run its bytecode again from the start, reusing its stack and frames instead of
+// allocating new ones. It also recovers a VM stopped in the middle of the program by an error or a cancelled
+// context. The stack is cleared, so the result of the last run must be read before. The global store is
+// kept as it is, whoever owns it clears the globals the program defines.
+func (vm *VM) Reset() {
+	// the values and the frames left over by the last run are dropped, the garbage collector can release them
+	for i := range vm.stack {
+		vm.stack[i] = nil
+	}
+	for i := 1; i < len(vm.frames) && vm.frames[i] != nil; i++ {
+		vm.frames[i] = nil
+	}
+	vm.sp = 0
+	vm.frames[0].ip = -1
+	vm.framesIndex = 1
+	vm.ticks = 0
+}
@@ -1,14 +1,20 @@
 package vm
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/code"
 	"github.com/yourfavoritedev/golang-interpreter/compiler"
 	"github.com/yourfavoritedev/golang-interpreter/lexer"
 	"github.com/yourfavoritedev/golang-interpreter/object"
 	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/report"
 )
 
 type vmTestCase struct {
@@ -30,6 +36,13 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 
 		vm := New(comp.Bytecode())
 		err = vm.Run()
+		// a failed built-in function stops the VM with its error
+		if expected, ok := tt.expected.(*object.Error); ok {
+			if err == nil || err.Error() != expected.Message {
+				t.Errorf("wrong vm error for %q. want=%q, got=%v", tt.input, expected.Message, err)
+			}
+			continue
+		}
 		if err != nil {
 			t.Fatalf("vm error: %s", err)
 		}
@@ -52,6 +65,11 @@ func testExpectedObject(
 		if err != nil {
 			t.Errorf("testIntegerObject failed: %s", err)
 		}
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s", err)
+		}
 	case bool:
 		err := testBooleanObject(bool(expected), actual)
 		if err != nil {
@@ -79,6 +97,25 @@ func testExpectedObject(
 				t.Errorf("testIntegerObject failed: %s", err)
 			}
 		}
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d",
+				len(expected), len(array.Elements))
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testStringObject(expectedElem, array.Elements[i])
+			if err != nil {
+				t.Errorf("testStringObject failed: %s", err)
+			}
+		}
 	case map[object.HashKey]int64:
 		hash, ok := actual.(*object.Hash)
 		if !ok {
@@ -139,6 +176,19 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+	}
+
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(*object.Boolean)
 	if !ok {
@@ -184,11 +234,85 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"-7 / 2", -3},
+		{"7 // 2", 3},
+		{"-7 // 2", -4},
+		{"7 // -2", -4},
+		{"-7 // -2", 3},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"~5", -6},
+		{"1 | 2 ^ 7 & 12 << 1 >> 2", 5},
 	}
 
 	runVmTests(t, tests)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"1.5 + 1", 2.5},
+		{"2 * 0.25", 0.5},
+		{"1 / 4.0", 0.25},
+		{"7.5 // 2", 3.0},
+		{"-7.5 // 2", -4.0},
+		{"-2.5", -2.5},
+		{"1.0 == 1", true},
+		{"0.5 != 0.5", false},
+		{"0.5 < 1", true},
+		{"2 > 2.5", false},
+		{"int(2.9) + 1", 3},
+		{"float(2)", 2.0},
+		{"to_fixed(2.345, 1)", "2.3"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDivisionByZero(t *testing.T) {
+	for _, input := range []string{`let zero = 0; 7 / zero`, `let zero = 0; 7 // zero`, `let zero = 0; 1.5 // zero`} {
+		program := parse(input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil || err.Error() != "division by zero" {
+			t.Errorf("wrong VM error for %q: want=%q, got=%v", input, "division by zero", err)
+		}
+	}
+}
+
+func TestBitwiseOperationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let n = -1; 1 << n", "negative shift count: -1"},
+		{"1.5 & 1", "unsupported types for bitwise operation: FLOAT, INTEGER"},
+		{"~true", "unsupported type for complement: BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong VM error for %q: want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
 func TestBooleanExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{"true", true},
@@ -197,6 +321,12 @@ func TestBooleanExpressions(t *testing.T) {
 		{"1 > 2", false},
 		{"1 < 1", false},
 		{"1 > 1", false},
+		{"1 <= 1", true},
+		{"1 >= 1", true},
+		{"1 <= 0", false},
+		{"0 >= 1", false},
+		{"1.5 >= 1", true},
+		{"2 <= 1.5", false},
 		{"1 == 1", true},
 		{"1 != 1", false},
 		{"1 == 2", false},
@@ -211,6 +341,8 @@ func TestBooleanExpressions(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"ab" == "a" + "b"`, true},
+		{`"a" != "b"`, true},
 		{"!true", false},
 		{"!false", true},
 		{"!5", false},
@@ -235,6 +367,25 @@ func TestConditionals(t *testing.T) {
 		{"if (1 > 2) { 10 }", Null},
 		{"if (false) { 10 }", Null},
 		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { }", Null},
+		{"let x = if (true) { let a = 1; }; x", Null},
+		{"let f = fn(n) { if (n > 0) { return n; } else { let m = 0; } }; [f(1), f(0)]", []interface{}{1, Null}},
+		{"1 < 2 ? 10 : 20", 10},
+		{"1 > 2 ? 10 : 20", 20},
+		{"let sign = fn(n) { n > 0 ? 1 : n < 0 ? -1 : 0 }; [sign(5), sign(-5), sign(0)]", []int{1, -1, 0}},
+		{"let x = 0; x = x == 0 ? 3 : 4; x", 3},
+		{"let f = fn(n) { n > 0 ? n : 0; 9 }; f(1)", 9},
+		{`let f = fn(x) { switch (x) { case 1, 2 { "small" } case "a" { "letter" } default { "other" } } }; [f(1), f(2), f("a"), f(3), f([1])]`,
+			[]interface{}{"small", "small", "letter", "other", "other"}},
+		{"switch (5) { case 1 { 1 } }", Null},
+		{"switch (1) { case 1 { let a = 1; } default { 2 } }", Null},
+		{"let f = fn(x) { switch (x) { case 1 { return 10; } default { 20 } } + 1 }; [f(1), f(2)]", []int{10, 21}},
+		{"let n = 0; switch (n) { case 0 { n = 5; } }; n", 5},
+		{"let calls = 0; let f = fn() { calls = calls + 1; 2 }; switch (f()) { case 1, f() { 7 } }; calls", 2},
+		// a switch whose value is thrown away leaves the stack as it found it
+		{"let f = fn(x) { switch (x) { case 1, 2 { 1 } case 3 { 3 } }; switch (x) { default { } }; x }; f(1) + f(3) + f(4)", 8},
+		// loops whose value is thrown away leave the stack as they found it
+		{"let f = fn() { let i = 0; while (i < 3) { for (x in [1, 2]) { i = i + x; }; while (false) { } }; i }; f() + f()", 6},
 	}
 
 	runVmTests(t, tests)
@@ -245,6 +396,14 @@ func TestGlobalLetStatements(t *testing.T) {
 		{"let one = 1; one;", 1},
 		{"let one = 1; let two = 2; one + two", 3},
 		{"let one = 1; let two = one + one; one + two", 3},
+		{"let one, two = 1, 2; one * 10 + two", 12},
+		{"let one, two = 1, 2; let one, two = two, one; one * 10 + two", 21},
+		{"let f = fn(a, b) { let a, b = b, a; a - b }; f(1, 5)", 4},
+		// a value reading the name it is bound to reads its previous binding, its functions read the new one
+		{"let x = 1; let x = x + 1; x", 2},
+		{"let x = 1; let x = [x, fn() { 1 }]; x[0]", 1},
+		{"let f = fn() { let x = 1; let x = [x, fn() { 1 }]; x[0] }; f()", 1},
+		{"let count = 1; let count = memoize(fn(n) { if (n == 0) { 0 } else { count(n - 1) } }); count(3)", 0},
 	}
 
 	runVmTests(t, tests)
@@ -255,6 +414,7 @@ func TestStringExpressions(t *testing.T) {
 		{`"monkey"`, "monkey"},
 		{`"mon" + "key"`, "monkey"},
 		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{"`say \"hi\"` + \"!\"", "say \"hi\"!"},
 	}
 
 	runVmTests(t, tests)
@@ -294,6 +454,44 @@ func TestHashLiterals(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestRecords(t *testing.T) {
+	tests := []vmTestCase{
+		{"let p = record{x: 1, y: 2}; p.x + p.y", 3},
+		{"let getY = fn(r) { r.y }; getY(record{y: 5, x: 1})", 5},
+		{"let p = record{name: \"a\", inner: record{v: [1]}}; p.inner.v", []int{1}},
+		{"record{x: 1, y: \"a\"} == record{x: 1, y: \"a\"}", true},
+		{"record{x: 1, y: 2} == record{y: 2, x: 1}", false},
+		{"record{x: 1} != record{x: 2}", true},
+		{"record{x: record{y: 1}} == record{x: record{y: 1}}", true},
+		{"let p = record{x: 1}; let f = fn() { p.x }; f()", 1},
+	}
+
+	runVmTests(t, tests)
+
+	program := parse(`let getZ = fn(r) { r.z }; getZ(record{x: 1})`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err == nil || err.Error() != "record has no field z" {
+		t.Errorf("wrong VM error: want=%q, got=%v", "record has no field z", err)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	tests := []vmTestCase{
+		{":a == :a", true},
+		{":a == :b", false},
+		{":a != :b", true},
+		{`let colors = {:red: 1, :green: 2}; colors[:green]`, 2},
+		{`let tag = fn() { :done }; tag() == :done`, true},
+		{`{:a: 1}["a"]`, Null},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestIndexExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{"[1, 2, 3][1]", 2},
@@ -301,11 +499,87 @@ func TestIndexExpressions(t *testing.T) {
 		{"[[1, 1, 1]][0][0]", 1},
 		{"[][0]", Null},
 		{"[1, 2, 3][99]", Null},
-		{"[1][-1]", Null},
+		{"[1][-1]", 1},
+		{"[1, 2, 3][-1]", 3},
+		{"let xs = [1, 2, 3]; xs[-len(xs)]", 1},
+		{"[1, 2, 3][-4]", Null},
+		{"[][-1]", Null},
 		{"{1: 1, 2: 3}[1]", 1},
 		{"{1: 1, 2: 2}[2]", 2},
 		{"{1: 1}[0]", Null},
 		{"{}[0]", Null},
+		{`{"a": 1, "b": 2}["b"]`, 2},
+		{`{"a": 1}["b"]`, Null},
+		{`let h = {"a": {"b": 3}}; h.a.b`, 3},
+		{`let h = {"a": 1}; let k = "a"; h[k] + h["a"] + h.a`, 3},
+		{`let h = {"a": 1}; h["" + "a"]`, 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3][:]", []int{1, 2, 3}},
+		{"let xs = [1, 2, 3]; let n = 1; xs[n:n + 1]", []int{2}},
+		// bounds out of range are clamped like an index out of range is null
+		{"[1, 2, 3][1:99]", []int{2, 3}},
+		{"[1, 2, 3][2:1]", []int{}},
+		{"[1, 2, 3][-5:1]", []int{1}},
+		// negative bounds count from the end like negative indices
+		{"[1, 2, 3][-2:]", []int{2, 3}},
+		{"[1, 2, 3][:-1]", []int{1, 2}},
+		{"[1, 2, 3][-2:-1]", []int{2}},
+		{`"monkey"[-3:]`, "key"},
+		{"let n = if (false) { 1 }; n?[1:]", Null},
+		{"[1, 2]?[1:]", []int{2}},
+		// the slice is a new array
+		{"let xs = [1, 2]; let ys = xs[:]; push(ys, 3); len(xs)", 2},
+		{`"monkey"[1:4]`, "onk"},
+		{`"monkey"[3:]`, "key"},
+		{`"monkey"[:0]`, ""},
+		{`"monkey"[4:99]`, "ey"},
+		{`let s = "monkey"; s[0:len(s) - 3] + s[len(s) - 3:]`, "monkey"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSliceErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1[1:]`, "slice operator not supported: INTEGER"},
+		{`[1, 2]["a":]`, "slice bounds must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong VM error for %q: want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestSafeNavigation(t *testing.T) {
+	tests := []vmTestCase{
+		{"let n = if (false) { 1 }; n?[0]", Null},
+		{"[1, 2]?[1]", 2},
+		{`{"a": 1}?["a"]`, 1},
+		{`let h = {"a": {"b": 2}}; h?["a"]?["b"]`, 2},
+		{`let h = {"a": {"b": 2}}; h?["c"]?["b"]`, Null},
+		// the index is not evaluated when the object is null
+		{"let n = if (false) { 1 }; n?[len(1)]", Null},
 	}
 
 	runVmTests(t, tests)
@@ -537,6 +811,50 @@ func TestCallingFunctionsWithArgumentsAndBindings(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestDefaultParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{input: `let add = fn(x, y = 10) { x + y }; add(5);`, expected: 15},
+		{input: `let add = fn(x, y = 10) { x + y }; add(5, 1);`, expected: 6},
+		{input: `let f = fn(x, y = x * 2, z = y + 1) { z }; f(1);`, expected: 3},
+		{input: `let f = fn(x, y = x * 2, z = y + 1) { z }; f(1, 5);`, expected: 6},
+		{input: `let n = 1; let f = fn(x = n) { x }; n = 2; f();`, expected: 2},
+		{input: `let f = fn(x, y = fn() { x }) { let z = 3; y() + z }; f(4);`, expected: 7},
+		// the slot of a missing parameter does not keep a value left on the stack by an earlier call
+		{input: `let g = fn(a, b, c) { c }; g(1, 2, 3); let f = fn(x = y, y = 1) { x }; f();`, expected: Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestVariadicParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{input: `let f = fn(x, rest...) { rest }; f(1, 2, 3);`, expected: []int{2, 3}},
+		{input: `let f = fn(x, rest...) { rest }; f(1);`, expected: []int{}},
+		{input: `let sum = fn(xs...) { let total = 0; for (x in xs) { total = total + x }; total }; sum(1, 2, 3);`, expected: 6},
+		{input: `let f = fn(x, y = 2, rest...) { x + y + len(rest) }; f(1);`, expected: 3},
+		{input: `let f = fn(x, y = 2, rest...) { x + y + len(rest) }; f(1, 5, 7, 7);`, expected: 8},
+		// the locals after the variadic parameter do not overwrite the collected arguments
+		{input: `let f = fn(rest...) { let a = 10; let b = 20; rest[2] + a + b }; f(1, 2, 3, 4);`, expected: 33},
+		{input: `let outer = fn(x) { fn(ys...) { x + len(ys) } }; outer(1)(5, 6);`, expected: 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []vmTestCase{
+		{input: `let f = fn(x, y) { x - y }; f(y: 1, x: 10);`, expected: 9},
+		{input: `let f = fn(x, y) { x - y }; f(10, y: 1);`, expected: 9},
+		// the defaults of the parameters left out are evaluated, the ones given by name are not
+		{input: `let f = fn(x, y = 2, z = x + y) { x * 100 + y * 10 + z }; f(1, z: 5);`, expected: 125},
+		{input: `let f = fn(x, y = 2, z = x + y) { x * 100 + y * 10 + z }; f(z: 5, x: 3);`, expected: 325},
+		{input: `let f = fn(x, rest...) { rest }; f(x: 1);`, expected: []int{}},
+		{input: `let f = fn(x, y) { fn(z) { x + y + z } }; f(y: 1, x: 2)(z: 3);`, expected: 6},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -551,6 +869,30 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			input:    `fn(a, b) { a + b; }(1)`,
 			expected: `wrong number of arguments: want=2, got=1`,
 		},
+		{
+			input:    `fn(a, b = 1) { a + b; }()`,
+			expected: `wrong number of arguments: want=1 to 2, got=0`,
+		},
+		{
+			input:    `fn(a, b = 1) { a + b; }(1, 2, 3)`,
+			expected: `wrong number of arguments: want=1 to 2, got=3`,
+		},
+		{
+			input:    `fn(a, rest...) { a; }()`,
+			expected: `wrong number of arguments: want=1 or more, got=0`,
+		},
+		{
+			input:    `fn(a, b) { a; }(1, c: 2)`,
+			expected: `unknown argument name c, the parameters are (a, b)`,
+		},
+		{
+			input:    `fn(a, b = 1) { a; }(b: 2)`,
+			expected: `missing argument for parameter a`,
+		},
+		{
+			input:    `len(a: 1)`,
+			expected: `built-in functions do not accept named arguments, got a`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -574,11 +916,513 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	}
 }
 
+func TestDeepRecursion(t *testing.T) {
+	program := parse(`let f = fn(x) { 1 + f(x) }; f(1)`)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// the stack grows with the calls, so the recursion stops at the maximum depth
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error but resulted in none.")
+	}
+
+	expected := `maximum recursion depth (1024) exceeded in function f
+call trace (most recent call last):
+  ... 1020 earlier calls
+  f
+  f
+  f
+  f
+  f`
+	if err.Error() != expected {
+		t.Fatalf("wrong VM error: want=%q, got=%q", expected, err)
+	}
+
+	program = parse(`let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(4000)`)
+	comp = compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm = New(comp.Bytecode())
+	vm.SetMaxFrames(5000)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if err := testIntegerObject(8002000, vm.LastPoppedStackElem()); err != nil {
+		t.Fatalf("testIntegerObject failed: %s", err)
+	}
+}
+
+func TestMaxFrames(t *testing.T) {
+	program := parse(`
+	let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } };
+	let wrapper = fn(n) { countdown(n) };
+	wrapper(9);
+	wrapper(10);
+	`)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	vm.SetMaxFrames(10)
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error but resulted in none.")
+	}
+
+	expected := `maximum recursion depth (10) exceeded in function countdown
+call trace (most recent call last):
+  ... 6 earlier calls
+  countdown
+  countdown
+  countdown
+  countdown
+  countdown`
+	if err.Error() != expected {
+		t.Fatalf("wrong VM error: want=%q, got=%q", expected, err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	program := parse(`
+	let add = fn(a, b) { a + b };
+	let fail = fn() { 1 + "a" };
+	`)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	add, fail := vm.globals[0], vm.globals[1]
+	result, err := vm.Call(add, &object.Integer{Value: 1}, &object.Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("call failed: %s", err)
+	}
+	testIntegerObject(3, result)
+
+	_, err = vm.Call(fail)
+	if err == nil || err.Error() != "unsupported types for binary operation: INTEGER, STRING" {
+		t.Fatalf("wrong call error: %v", err)
+	}
+
+	// the VM can keep calling functions after a failed call
+	result, err = vm.Call(object.GetBuiltInByName("len"), &object.String{Value: "abc"})
+	if err != nil {
+		t.Fatalf("call failed: %s", err)
+	}
+	testIntegerObject(3, result)
+}
+
+func TestRunContext(t *testing.T) {
+	program := parse(`
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	fib(30);
+	`)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vm := New(comp.Bytecode())
+	if err := vm.RunContext(ctx); err != context.Canceled {
+		t.Fatalf("expected cancellation. got=%v", err)
+	}
+}
+
+func TestSignalHandlers(t *testing.T) {
+	_, err := object.RegisterBuiltin("vm_test_interrupt", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		object.RaiseSignal(os.Interrupt)
+		return object.NULL
+	}})
+	if err != nil {
+		t.Fatalf("built-in function not registered: %s", err)
+	}
+
+	// the handlers are called between two instructions, the loops give the VM enough instructions to get there
+	tests := []struct {
+		input    string
+		expected int
+		exit     int
+	}{
+		{`let interrupts = 0;
+		on_signal("SIGINT", fn() { interrupts = interrupts + 1 });
+		vm_test_interrupt();
+		vm_test_interrupt();
+		let i = 0; while (i < 1000) { i = i + 1; }
+		interrupts`, 2, -1},
+		{`on_signal("SIGINT", fn() { exit(3) }); vm_test_interrupt(); let i = 0; while (i < 1000) { i = i + 1; } i`, 0, 3},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		machine := New(comp.Bytecode())
+		err := machine.RunContext(context.Background())
+		// the handlers of a run are removed when it returns, another program never calls them
+		if object.HandlesSignal(os.Interrupt) {
+			t.Errorf("the handler outlived its run")
+		}
+		if tt.exit >= 0 {
+			exit, ok := err.(*ExitError)
+			if !ok || exit.Code != tt.exit {
+				t.Fatalf("expected the handler to exit with status %d. got=%v", tt.exit, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		testExpectedObject(t, tt.expected, machine.LastPoppedStackElem())
+	}
+
+	// outside of RunContext no handler can be registered
+	comp := compiler.New()
+	if err := comp.Compile(parse(`on_signal("SIGINT", fn() { 1 })`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	err = New(comp.Bytecode()).Run()
+	if err == nil || err.Error() != "`on_signal` must be called by a program run with a context" {
+		t.Errorf("wrong error outside of RunContext. got=%v", err)
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"while (false) { 1 }", Null},
+		{"let f = fn() { while (true) { return 42; } }; f()", 42},
+		{"let f = fn(n) { while (n > 0) { return n * 2; } 0 }; [f(3), f(0)]", []int{6, 0}},
+		{"let i = 0; while (true) { i = i + 1; if (i == 3) { break; } }; i", 3},
+		{"let i = 0; let n = 0; while (i < 5) { i = i + 1; if (i == 2) { continue; } n = n + i; }; n", 13},
+		// the operands already pushed by the expression around a break or continue are popped
+		{"let i = 0; while (true) { i = i + 1; 1 + if (i == 3) { break; } else { 0 }; }; i", 3},
+	}
+
+	runVmTests(t, tests)
+
+	// an endless loop without calls is stopped by the cancellation checks
+	comp := compiler.New()
+	if err := comp.Compile(parse(`while (true) { }`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vm := New(comp.Bytecode())
+	if err := vm.RunContext(ctx); err != context.Canceled {
+		t.Fatalf("expected cancellation. got=%v", err)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"true && false", false},
+		{"true && 2", 2},
+		{"1 && 2", 2},
+		{"false || 2", 2},
+		{"1 || 2", 1},
+		{"let n = if (false) { 1 }; n || 3", 3},
+		{"let n = if (false) { 1 }; n && 3", Null},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		// the right operand is not evaluated when the left one decides the result
+		{"false && len(1)", false},
+		{"true || len(1)", true},
+		{"let x = 0; false && (x = 1); true || (x = 2); true && (x = 3); x", 3},
+		{"let f = fn(n) { n > 0 && f(n - 1) || n == 0 }; f(5)", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = x + 1; x", 2},
+		{"let x = 1; x = 5", 5},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let i = 0; let total = 0; while (i < 4) { total = total + i; i = i + 1; } total", 6},
+		{"let x = 1; let f = fn() { x = x + 1; }; f(); f(); x", 3},
+		{"let f = fn(n) { let m = n; m = m * 2; m }; f(4)", 8},
+		{"let i = 0; while (i < 3) { i++; } i", 3},
+		{"let i = 5; let j = i--; i + j", 8},
+		{"let f = fn() { let n = 0; for (x in [1, 2]) { n++; }; n-- }; f()", 1},
+		// a function compiled before the reassignment still reads the field of the new record
+		{"let p = record{x: 1}; let f = fn() { p.x }; p = record{y: 2, x: 3}; f()", 3},
+		{"let a, b = 1, 2; a, b = b, a; a * 10 + b", 21},
+		{"let f = fn(n) { let a, b = 0, 1; while (n > 0) { a, b = b, a + b; n--; } a }; f(10)", 55},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"for (x in []) { 1 }", Null},
+		{"let last = 0; for (x in [1, 2]) { last = x; }; last", 2},
+		{"let f = fn(arr) { for (x in arr) { if (x > 1) { return x; } } 0 }; [f([1, 2, 3]), f([1])]", []int{2, 0}},
+		{`let f = fn(arr) { for (i, x in arr) { if (x == "b") { return i; } } }; f(["a", "b"])`, 1},
+		// hashes are walked in the order of their keys
+		{`let f = fn(h) { for (k, v in h) { return k; } }; f({"b": 1, "a": 2})`, "a"},
+		{`let f = fn(h) { for (k, v in h) { return v; } }; f({"b": 1, "a": 2})`, 2},
+		{"let f = fn() { for (x in range(5, 10)) { return x; } }; f()", 5},
+		{"let last = 0; for (x in map(fn(x) { x * 2 }, range(3))) { last = x; }; last", 4},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break; } n = n + x; }; n", 3},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { continue; } n = n + x; }; n", 7},
+		// break and continue only leave the innermost loop, the cursor of the outer one stays on the stack
+		{`let f = fn(arr) {
+			let n = 0;
+			for (x in arr) {
+				for (y in range(1, 4)) {
+					if (y > x) { break; }
+					if (y == 2) { continue; }
+					n = n + [1, 1 + if (y == 3) { continue; } else { 0 }][1];
+				};
+			};
+			n
+		}; f([1, 2, 3])`, 3},
+	}
+
+	runVmTests(t, tests)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in 1) { x }", "cannot iterate over INTEGER, want ARRAY, HASH or ITERATOR"},
+		{"for (x in map(fn(x) { x + true }, range(3))) { x }", "unsupported types for binary operation: INTEGER, BOOLEAN"},
+	}
+	for _, tt := range errors {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		if err := New(comp.Bytecode()).Run(); err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestStepBack(t *testing.T) {
+	program := parse(`
+	let double = fn(x) { x * 2 };
+	let a = double(3);
+	let b = map(double, [1, 2]);
+	a + len(b);
+	`)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+	// stop before the final OpPop, with the result of the program on the stack
+	last := len(bytecode.Instructions) - 1
+
+	vm := New(bytecode)
+	vm.SetHistory(1000)
+	vm.SetBreakpoint(nil, last)
+
+	if err := vm.Run(); err != ErrBreakpoint {
+		t.Fatalf("expected breakpoint. got=%v", err)
+	}
+	fn, ip := vm.Position()
+	if fn != vm.frames[0].cl.Fn || ip != last {
+		t.Fatalf("wrong position. got=%d, want=%d", ip, last)
+	}
+	stack := vm.Stack()
+	if len(stack) != 1 {
+		t.Fatalf("wrong stack at breakpoint. got=%v", stack)
+	}
+	if err := testIntegerObject(8, stack[0]); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+
+	history := vm.History()
+	if history[len(history)-1].Op != code.OpAdd || history[len(history)-1].StackDelta != -1 {
+		t.Errorf("wrong last entry. got=%+v", history[len(history)-1])
+	}
+
+	// step back over every instruction, including the calls and the callbacks of map
+	for range history {
+		if err := vm.StepBack(); err != nil {
+			t.Fatalf("step back failed: %s", err)
+		}
+	}
+	if err := vm.StepBack(); err != ErrNoHistory {
+		t.Fatalf("expected ErrNoHistory. got=%v", err)
+	}
+	if _, ip := vm.Position(); ip != 0 || vm.sp != 0 || vm.framesIndex != 1 {
+		t.Fatalf("not back at the start. ip=%d, sp=%d, frames=%d", ip, vm.sp, vm.framesIndex)
+	}
+	for i := 0; i < 3; i++ {
+		if vm.globals[i] != nil {
+			t.Errorf("global %d not restored. got=%s", i, vm.globals[i].Inspect())
+		}
+	}
+
+	// executing again reaches the breakpoint in the same state
+	if err := vm.Continue(); err != ErrBreakpoint {
+		t.Fatalf("expected breakpoint. got=%v", err)
+	}
+	if err := testIntegerObject(8, vm.Stack()[0]); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+
+	if err := vm.Continue(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if err := testIntegerObject(8, vm.LastPoppedStackElem()); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+}
+
+func TestStepBackBoundedHistory(t *testing.T) {
+	program := parse(`let a = 1; let b = a + 2; b * 10;`)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	vm := New(bytecode)
+	vm.SetHistory(3)
+	vm.SetBreakpoint(nil, len(bytecode.Instructions)-1)
+	if err := vm.Run(); err != ErrBreakpoint {
+		t.Fatalf("expected breakpoint. got=%v", err)
+	}
+
+	// only OpGetGlobal b, OpConstant 10 and OpMul are kept
+	history := vm.History()
+	expected := []code.Opcode{code.OpGetGlobal, code.OpConstant, code.OpMul}
+	if len(history) != len(expected) {
+		t.Fatalf("wrong history length. want=%d, got=%d", len(expected), len(history))
+	}
+	for i, op := range expected {
+		if history[i].Op != op {
+			t.Errorf("wrong opcode at %d. want=%d, got=%d", i, op, history[i].Op)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := vm.StepBack(); err != nil {
+			t.Fatalf("step back failed: %s", err)
+		}
+	}
+	if err := vm.StepBack(); err != ErrNoHistory {
+		t.Fatalf("expected ErrNoHistory. got=%v", err)
+	}
+	if vm.sp != 0 {
+		t.Fatalf("wrong sp. got=%d", vm.sp)
+	}
+	if err := testIntegerObject(3, vm.globals[1]); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+
+	vm.ClearBreakpoint(nil, len(bytecode.Instructions)-1)
+	if err := vm.Continue(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if err := testIntegerObject(30, vm.LastPoppedStackElem()); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+}
+
+func TestWatchpoints(t *testing.T) {
+	program := parse(`
+	let total = 5;
+	let f = fn(a) { let b = a * 2; b + 1 };
+	f(total);
+	`)
+
+	symbols := compiler.NewSymbolTable()
+	comp := compiler.NewWithState(symbols, []object.Object{})
+	comp.SetDebugInfo(true)
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	var fn *object.CompiledFunction
+	for _, constant := range bytecode.Constants {
+		if compiled, ok := constant.(*object.CompiledFunction); ok {
+			fn = compiled
+		}
+	}
+
+	vm := New(bytecode)
+	if err := vm.WatchVariable(symbols, "total"); err != nil {
+		t.Fatalf("watch failed: %s", err)
+	}
+	if err := vm.WatchLocalVariable(fn, "b"); err != nil {
+		t.Fatalf("watch failed: %s", err)
+	}
+	if err := vm.WatchVariable(symbols, "missing"); err == nil {
+		t.Errorf("expected error for unknown variable")
+	}
+
+	if err := vm.Run(); err != ErrWatchpoint {
+		t.Fatalf("expected watchpoint. got=%v", err)
+	}
+	hit := vm.LastWatchHit()
+	if !hit.Global || hit.Name != "total" || hit.Index != 0 || hit.Old != nil || hit.Function != vm.frames[0].cl.Fn {
+		t.Fatalf("wrong watch hit. got=%+v", hit)
+	}
+	if err := testIntegerObject(5, hit.New); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+	if code.Opcode(bytecode.Instructions[hit.Ip]) != code.OpSetGlobal {
+		t.Errorf("hit does not point at OpSetGlobal. got=%d", hit.Ip)
+	}
+
+	if err := vm.Continue(); err != ErrWatchpoint {
+		t.Fatalf("expected watchpoint. got=%v", err)
+	}
+	hit = vm.LastWatchHit()
+	if hit.Global || hit.Name != "b" || hit.Index != 1 || hit.Function != fn {
+		t.Fatalf("wrong watch hit. got=%+v", hit)
+	}
+	if err := testIntegerObject(10, hit.New); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+	if code.Opcode(fn.Instructions[hit.Ip]) != code.OpSetLocal {
+		t.Errorf("hit does not point at OpSetLocal. got=%d", hit.Ip)
+	}
+
+	if err := vm.Continue(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if err := testIntegerObject(11, vm.LastPoppedStackElem()); err != nil {
+		t.Errorf("testIntegerObject failed: %s", err)
+	}
+}
+
 func TestBuiltInFunctons(t *testing.T) {
 	tests := []vmTestCase{
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len({})`, 0},
 		{
 			`len(1)`,
 			&object.Error{
@@ -593,6 +1437,17 @@ func TestBuiltInFunctons(t *testing.T) {
 		},
 		{`len([1, 2, 3])`, 3},
 		{`len([])`, 0},
+		{`len(record{x: 1, y: 2})`, 2},
+		{`len(range(5))`, 5},
+		{`len(range(1, 10, 3))`, 3},
+		{`len(range(10, 0, -3))`, 4},
+		{`len(range(3, 3))`, 0},
+		{`let it = range(4); first(collect(take(1, it))); len(it)`, 3},
+		{`len(map(fn(x) { x }, range(3)))`,
+			&object.Error{
+				Message: "length of a lazy iterator is unknown, collect it first",
+			},
+		},
 		{`puts("hello", "world!")`, Null},
 		{`first([1, 2, 3])`, 1},
 		{`first([])`, Null},
@@ -616,11 +1471,243 @@ func TestBuiltInFunctons(t *testing.T) {
 				Message: "argument to `push` must be ARRAY, got INTEGER",
 			},
 		},
+		{`parse_toml("name = 'monkey'
+		[server]
+		port = 8080")["server"]["port"]`, 8080},
+		{`parse_toml("[server]
+		tls = true")["server"]["tls"] == true`, true},
+		{`parse_toml("ratio = 0.5")["ratio"] == 0.5`, true},
+		{`parse_toml("[a]
+		[a]")`,
+			&object.Error{
+				Message: "toml: line 2: table \"a\" is already defined",
+			},
+		},
+		{`parse_toml("port = ")`,
+			&object.Error{
+				Message: "toml: line 1: expected a value",
+			},
+		},
+		{`render("Hello {{ name }}, you have {{count}} {{items.kind}}!", {"name": "monkey", "count": 3, "items": {"kind": "bananas"}})`,
+			"Hello monkey, you have 3 bananas!"},
+		{`render("{{name}", {})`,
+			&object.Error{
+				Message: "unclosed placeholder in template: {{name}",
+			},
+		},
+		{`render("{{user.name}}", {"user": {}})`,
+			&object.Error{
+				Message: "undefined template key: user.name",
+			},
+		},
+		{`get({"a": 1}, "a")`, 1},
+		{`get({"a": 1}, "b")`, Null},
+		{`get({"a": 1}, "b", 5)`, 5},
+		{`get([1, 2], 1, 5)`, 2},
+		{`get([1, 2], -1, 5)`, 2},
+		{`get([1, 2], -3, 5)`, 5},
+		{`get(1, "b", 5)`, 5},
+		{`dig({"a": {"b": [1, {"c": 3}]}}, ["a", "b", 1, "c"])`, 3},
+		{`dig({"a": {"b": 2}}, ["a", "x", "c"])`, Null},
+		{`dig({"a": {"b": 2}}, ["a", "b", "c"], "none")`, "none"},
+		{`dig({}, "a")`,
+			&object.Error{
+				Message: "second argument to `dig` must be ARRAY, got STRING",
+			},
+		},
+		{`group_by([1, 2, 3, 4, 5], fn(x) { x - (x / 2) * 2 })[1]`, []int{1, 3, 5}},
+		{`group_by([], fn(x) { x })`, map[object.HashKey]int64{}},
+		{`sort_by([3, 1, 2], fn(x) { -x })`, []int{3, 2, 1}},
+		{`let s = sort_by([[2, 1], [1, 2], [2, 3], [1, 4]], fn(p) { p[0] }); [s[0][1], s[1][1], s[2][1], s[3][1]]`, []int{2, 4, 1, 3}},
+		{`unique([1, 2, 1, 3, 2])`, []int{1, 2, 3}},
+		{`unique([1, 2, 3, 4], fn(x) { x > 2 })`, []int{1, 3}},
+		{`min_by([3, 1, 2], fn(x) { x })`, 1},
+		{`max_by(["a", "ccc", "bb"], fn(s) { len(s) })`, "ccc"},
+		{`max_by([], fn(s) { len(s) })`, Null},
+		{`sort_by([1, "a"], fn(x) { x })`,
+			&object.Error{
+				Message: "keys returned to `sort_by` must both be INTEGER or STRING, got STRING and INTEGER",
+			},
+		},
+		{`collect(take(5, map(fn(x) { x * x }, range(1000000))))`, []int{0, 1, 4, 9, 16}},
+		{`to_array(take(3, filter(fn(x) { x > 10 }, range(1, 9223372036854775807))))`, []int{11, 12, 13}},
+		{`collect(drop(2, range(10, 0, -3)))`, []int{4, 1}},
+		{`collect(range(3, 3))`, []int{}},
+		{`map(fn(x) { x + 1 }, [1, 2])`, []int{2, 3}},
+		{`filter(fn(x) { x != 2 }, [1, 2, 3])`, []int{1, 3}},
+		{`take(5, [1, 2])`, []int{1, 2}},
+		{`drop(1, [1, 2])`, []int{2}},
+		{`let it = range(4); let first = collect(take(2, it)); collect(it)`, []int{2, 3}},
+		{`range(1, 2, 0)`,
+			&object.Error{
+				Message: "step of `range` must not be 0",
+			},
+		},
+		{`take(-1, range(3))`,
+			&object.Error{
+				Message: "first argument to `take` must be a non-negative INTEGER, got -1",
+			},
+		},
+		{`let fib = memoize(fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }); fib(80)`, 23416728348467685},
+		{`let square = memoize(fn(x, y) { x * y }); square(3, 4) + square(3, 4)`, 24},
+		{`memoize(1)`,
+			&object.Error{
+				Message: "argument to `memoize` must be a function, got INTEGER",
+			},
+		},
+		{`memoize(fn(x) { x })([1])`,
+			&object.Error{
+				Message: "unusable as memoize key: ARRAY",
+			},
+		},
+		{`apply(fn(a, b) { a - b }, [5, 3])`, 2},
+		{`apply(len, ["four"])`, 4},
+		{`let add = fn(a, b, c) { a + b + c }; partial(add, 1, 2)(3)`, 6},
+		{`let add = fn(a, b) { a + b }; map(partial(add, 10), [1, 2])`, []int{11, 12}},
+		{`partial(1, 2)`,
+			&object.Error{
+				Message: "first argument to `partial` must be a function, got INTEGER",
+			},
+		},
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(inc, double)(5)`, 11},
+		{`let add = fn(a, b) { a + b }; compose(fn(x) { -x }, add)(2, 3)`, -5},
+		{`compose(len, identity)("four")`, 4},
+		{`map(const_fn(7), [1, 2])`, []int{7, 7}},
+		{`const_fn("x")()`, "x"},
+		{`compose(identity, 1)`,
+			&object.Error{
+				Message: "arguments to `compose` must be functions, got INTEGER",
+			},
+		},
+		{`try(fn() { 1 + 2 })["ok"]`, 3},
+		{`try(fn() { 1 + "a" })["error"]`, "unsupported types for binary operation: INTEGER, STRING"},
+		{`try(fn() { len(1) })["error"]`, "argument to `len` not supported, got=INTEGER"},
+		{`try(fn() { 1 / 0 })["error"]`, "division by zero"},
+		{`let r = try(fn() { let f = fn(x) { x(1) }; f(2) }); if (!r["ok"]) { r["error"] }`,
+			"calling non-function and non-built-in"},
+		{`let inner = try(fn() { [1][true] }); try(fn() { inner["error"] })["ok"]`, "index operator not supported: ARRAY"},
+		{`try(fn() { puts() })["ok"]`, Null},
+		{`strings.upper("monkey")`, "MONKEY"},
+		{`strings.join(strings.split("a,b,c", ","), "-")`, "a-b-c"},
+		{`strings.contains("monkey", "key")`, true},
+		{`math.max(math.abs(-7), math.pow(2, 2))`, 7},
+		{`let m = math; let f = fn() { m.min(3, 2) }; f()`, 2},
+		{`map(strings.trim, [" a ", "b "])[0]`, "a"},
+		{`{"name": "monkey"}.name`, "monkey"},
+		{`math.abs("a")`,
+			&object.Error{
+				Message: "arguments to `math.abs` must be INTEGER, got STRING",
+			},
+		},
+		{`try(fn() { let m = math; m.sqrt })["error"]`, "undefined member sqrt in module math"},
+		{`arity(fn(a, b) { a })`, 2},
+		{`params(fn(first, second) { first })`, []string{"first", "second"}},
+		{`source(fn(a) { a })`, Null},
+		{`arity(len)`, Null},
+		{"### Doubles x.\nlet double = fn(x) { x * 2 }; doc(double)", "Doubles x."},
+		{`let plain = fn(x) { x }; doc(plain)`, Null},
+		{`arity(1)`,
+			&object.Error{
+				Message: "argument to `arity` must be a function, got INTEGER",
+			},
+		},
+		{`to_fixed(5, 2)`, "5.00"},
+		{`to_fixed(-3, 0)`, "-3"},
+		{`format_number(1234567)`, "1,234,567"},
+		{`format_number(-1234567, " ")`, "-1 234 567"},
+		{`format_number(123)`, "123"},
+		{`divmod(7, 2)`, []int{3, 1}},
+		{`divmod(-7, 2)`, []int{-4, 1}},
+		{`divmod(7, -2)`, []int{-4, -1}},
+		{`divmod(-7, -2)`, []int{3, -1}},
+		{`divmod(1, 0)`, &object.Error{Message: "division by zero"}},
+		{`format_number(to_fixed(12345, 2))`, "12,345.00"},
+		{`to_fixed(5, -1)`,
+			&object.Error{
+				Message: "second argument to `to_fixed` must be a non-negative INTEGER, got -1",
+			},
+		},
+		{`format_number("abc")`,
+			&object.Error{
+				Message: "first argument to `format_number` must be a number, got \"abc\"",
+			},
+		},
+		{`unwrap(ok(5))`, 5},
+		{`err("boom")["error"]`, "boom"},
+		{`is_ok(ok(1))`, true},
+		{`is_ok(err("boom"))`, false},
+		{`is_ok(try(fn() { 1 + "a" }))`, false},
+		{`unwrap_or(err("boom"), 7)`, 7},
+		{`unwrap_or(try(fn() { 1 + 2 }), 7)`, 3},
+		{`unwrap(err("boom"))`, &object.Error{Message: "unwrap of an error result: boom"}},
+		{`unwrap({"value": 1})`,
+			&object.Error{
+				Message: "argument to `unwrap` must be a result, {\"ok\": value} or {\"error\": message}, got {value: 1}",
+			},
+		},
+		{`err(1)`, &object.Error{Message: "argument to `err` must be STRING, got INTEGER"}},
 	}
 
 	runVmTests(t, tests)
 }
 
+func TestRuntimeStats(t *testing.T) {
+	stats := `let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; let before = runtime_stats(); f(9); let after = runtime_stats();`
+	runVmTests(t, []vmTestCase{
+		{stats + `after["frames"] - before["frames"]`, 10},
+		// the instructions of the calls, the ones between the two calls of runtime_stats included
+		{stats + `after["instructions"] - before["instructions"] > 10 * 8`, true},
+		{stats + `after["heap_objects"] > 0 && after["memory"]["total_alloc"] >= before["memory"]["total_alloc"]`, true},
+		{`runtime_stats(1)`, &object.Error{Message: "wrong number of arguments. got=1, want=0"}},
+	})
+}
+
+func TestRegisteredBuiltin(t *testing.T) {
+	_, err := object.RegisterBuiltin("vm_test_square", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		value := args[0].(*object.Integer).Value
+		return &object.Integer{Value: value * value}
+	}})
+	if err != nil {
+		t.Fatalf("built-in function not registered: %s", err)
+	}
+
+	runVmTests(t, []vmTestCase{
+		{"vm_test_square(7)", 49},
+		{"let f = fn(x) { vm_test_square(x) + 1 }; f(3)", 10},
+	})
+}
+
+func TestHigherOrderBuiltinCallbackError(t *testing.T) {
+	for _, input := range []string{
+		`let total = sort_by([1, 2], fn(x) { x + "a" }); total`,
+		`let lazy = map(fn(x) { x + "a" }, range(5)); collect(lazy)`,
+	} {
+		testCallbackError(t, input)
+	}
+}
+
+// testCallbackError runs the input and expects its callback to abort the VM with a runtime error
+func testCallbackError(t *testing.T, input string) {
+	program := parse(input)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error but resulted in none.")
+	}
+
+	expected := "unsupported types for binary operation: INTEGER, STRING"
+	if err.Error() != expected {
+		t.Fatalf("wrong VM error: want=%q, got=%q", expected, err)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -752,6 +1839,26 @@ func TestRecursiveFunctions(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestCallReturnBoundaries(t *testing.T) {
+	tests := []vmTestCase{
+		// execution resumes right after the call in the caller, at any depth
+		{`let a = fn() { 1 }; let b = fn() { a() + 10 }; let c = fn() { b() + a() + 100 }; c() + b() + a()`, 124},
+		// a function without a body returns null through OpReturn, the caller continues with its next instruction
+		{`let none = fn() { }; let f = fn() { none(); none(); 3 }; [f(), none()]`, []interface{}{3, Null}},
+		// returning from inside a loop leaves the loop and the frame
+		{`let find = fn(xs) { for (x in xs) { if (x > 2) { return x; } }; -1 }; find([1, 2, 3, 4]) + find([])`, 2},
+		{`let count = fn(n) { let i = 0; while (true) { if (i == n) { return i; } i = i + 1; } }; count(5) + count(0)`, 5},
+		// a higher-order built-in function runs the callback in its own frame and returns to the caller's
+		{`let double = fn(x) { x * 2 }; let f = fn(xs) { let ys = map(double, xs); len(ys) + ys[2] }; f([1, 2, 3])`, 9},
+		{`let f = fn(xs) { map(fn(x) { map(fn(y) { y + 1 }, [x])[0] }, xs) }; f([1, 2])`, []int{2, 3}},
+		// a closure returned from a call keeps running with its own instructions
+		{`let adder = fn(a) { fn(b) { a + b } }; let addTwo = adder(2); addTwo(adder(1)(1)) + addTwo(0)`, 6},
+		{`let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } }; countdown(50); countdown(3) + 1`, 1},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestRecursiveFibonacci(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -775,3 +1882,328 @@ func TestRecursiveFibonacci(t *testing.T) {
 
 	runVmTests(t, tests)
 }
+
+func TestArena(t *testing.T) {
+	program := parse(`let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(100)`)
+
+	if New(&compiler.Bytecode{}).arena != nil {
+		t.Fatalf("the arena is enabled by default")
+	}
+
+	for _, enabled := range []bool{true, false} {
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetArena(enabled)
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		testExpectedObject(t, 5050, vm.LastPoppedStackElem())
+	}
+}
+
+func TestLoggerPerVM(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`log_level("debug"); log_debug("shown"); pmap(fn(x) { log_debug("worker") }, [1, 2])`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	var out bytes.Buffer
+	logger := &object.Logger{Writer: &out, Level: object.LogInfo}
+	vm := New(comp.Bytecode())
+	vm.SetLogger(logger)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	expected := "level=debug msg=\"shown\"\nlevel=debug msg=\"worker\"\nlevel=debug msg=\"worker\"\n"
+	if out.String() != expected {
+		t.Errorf("wrong log output.\nwant=%q\ngot=%q", expected, out.String())
+	}
+	// the level set by the program is the one of its logger, another VM still has the default level
+	if logger.Level != object.LogDebug || object.Log.Level != object.LogInfo {
+		t.Errorf("wrong levels. got=%s for the VM and %s for the default logger", logger.Level, object.Log.Level)
+	}
+	other := New(comp.Bytecode())
+	if other.logger.Level != object.LogInfo {
+		t.Errorf("a new VM starts with level %s", other.logger.Level)
+	}
+}
+
+func benchmarkFibonacci(b *testing.B, arena bool) {
+	program := parse(`
+	let fibonacci = fn(x) {
+		if (x == 0) { return 0; }
+		if (x == 1) { return 1; }
+		fibonacci(x - 1) + fibonacci(x - 2);
+	};
+	fibonacci(20);`)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		b.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm := New(bytecode)
+		vm.SetArena(arena)
+		err := vm.Run()
+		if err != nil {
+			b.Fatalf("vm error: %s", err)
+		}
+	}
+}
+
+func BenchmarkFibonacciArena(b *testing.B) {
+	benchmarkFibonacci(b, true)
+}
+
+func BenchmarkFibonacciNoArena(b *testing.B) {
+	benchmarkFibonacci(b, false)
+}
+
+// BenchmarkLoop measures the dispatch loop itself, the loop runs in a single frame without calls
+func BenchmarkLoop(b *testing.B) {
+	program := parse(`let i = 0; let sum = 0; while (i < 10000) { sum = sum + i; i = i + 1; } sum;`)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		b.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm := New(bytecode)
+		if err := vm.Run(); err != nil {
+			b.Fatalf("vm error: %s", err)
+		}
+	}
+}
+
+// opVecAdd is the custom opcode used by TestCustomOpcode, it adds two arrays of integers element by element
+const opVecAdd = code.OpCustomStart
+
+// vecExtension compiles calls of `vadd(a, b)` into the custom opVecAdd instruction
+type vecExtension struct{}
+
+func (vecExtension) Compile(c *compiler.Compiler, node ast.Node) (bool, error) {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false, nil
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || ident.Value != "vadd" {
+		return false, nil
+	}
+	if len(call.Arguments) != 2 {
+		return true, fmt.Errorf("vadd expects 2 arguments, got %d", len(call.Arguments))
+	}
+	for _, arg := range call.Arguments {
+		if err := c.Compile(arg); err != nil {
+			return true, err
+		}
+	}
+	_, err := c.Emit(opVecAdd)
+	return true, err
+}
+
+func TestCustomOpcode(t *testing.T) {
+	if err := code.Register(opVecAdd, &code.Definition{Name: "OpVecAdd", OperandWidths: []int{}}, -1); err != nil {
+		t.Fatalf("register failed: %s", err)
+	}
+	err := RegisterOpcode(opVecAdd, func(vm *VM, operands []int) error {
+		right, left := vm.Pop(), vm.Pop()
+		a, aOk := left.(*object.Array)
+		b, bOk := right.(*object.Array)
+		if !aOk || !bOk || len(a.Elements) != len(b.Elements) {
+			return fmt.Errorf("vadd expects two arrays of the same length")
+		}
+		sum := &object.Array{}
+		for i := range a.Elements {
+			sum.Elements = append(sum.Elements, &object.Integer{
+				Value: a.Elements[i].(*object.Integer).Value + b.Elements[i].(*object.Integer).Value,
+			})
+		}
+		return vm.Push(sum)
+	})
+	if err != nil {
+		t.Fatalf("register handler failed: %s", err)
+	}
+	if err := RegisterOpcode(code.OpAdd, nil); err == nil {
+		t.Errorf("expected an error registering a handler for a core opcode")
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`vadd([1, 2], [3, 4])`, "[4, 6]"},
+		{`let f = fn(a) { vadd(a, a) }; f([1, 2, 3])`, "[2, 4, 6]"},
+		{`vadd([1], [1, 2])`, "vadd expects two arrays of the same length"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		comp.Use(vecExtension{})
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			if err.Error() != tt.expected {
+				t.Errorf("wrong vm error. want=%q, got=%q", tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("wrong result. want=%q, got=%q", tt.expected, result)
+		}
+	}
+}
+
+func BenchmarkStringKeyIndex(b *testing.B) {
+	program := parse(`
+	let point = {"x": 1, "y": 2};
+	let sum = fn(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + point.x + point["y"]) };
+	sum(500, 0);`)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		b.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm := New(bytecode)
+		err := vm.Run()
+		if err != nil {
+			b.Fatalf("vm error: %s", err)
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	input := `let f = fn(a) {
+  a + true
+};
+f(1);`
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err == nil {
+		t.Fatalf("expected a runtime error")
+	}
+
+	// the main program waits on the call, the function is at the failing addition
+	expected := []StackFrame{{Function: "", Line: 4, Column: 2}, {Function: "f", Line: 2, Column: 5}}
+	trace := vm.StackTrace()
+	if len(trace) != len(expected) {
+		t.Fatalf("wrong stack trace. want=%v, got=%v", expected, trace)
+	}
+	for i := range expected {
+		if trace[i] != expected[i] {
+			t.Errorf("wrong frame %d. want=%v, got=%v", i, expected[i], trace[i])
+		}
+	}
+
+	if line, column, ok := vm.SourcePosition(nil, 0); !ok || line != 1 || column != 1 {
+		t.Errorf("wrong position of the first instruction. got=%d:%d %t", line, column, ok)
+	}
+
+	// the report of the error is located at the innermost call and holds the same calls
+	located, ok := vm.Report(errors.New("failed")).(*report.Error)
+	if !ok {
+		t.Fatalf("report is not *report.Error")
+	}
+	if located.Kind != report.Runtime || located.Message != "failed" || located.Line != 2 || located.Column != 5 {
+		t.Errorf("wrong report. got=%+v", located)
+	}
+	for i := range expected {
+		if located.Trace[i] != report.Frame(expected[i]) {
+			t.Errorf("wrong reported frame %d. want=%v, got=%v", i, expected[i], located.Trace[i])
+		}
+	}
+	if exit := (&ExitError{Code: 3}); vm.Report(exit) != exit {
+		t.Errorf("expected an exit error to be reported unchanged")
+	}
+}
+
+func TestSharedBytecode(t *testing.T) {
+	program := parse(`
+	let names = fn(n) { let xs = []; while (n > 0) { xs = push(xs, "name"); n = n - 1; } xs };
+	let total = fn(xs) { let sum = 0; for (x in xs) { sum = sum + len(x); } sum };
+	total(names(50)) + total(["a", "bc"][0:]);`)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+	for _, constant := range bytecode.Constants {
+		if !object.IsFrozen(constant) {
+			t.Errorf("constant %s is not frozen", constant.Inspect())
+		}
+	}
+
+	// the constants are frozen, any number of VMs can run the same bytecode at the same time
+	errs := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			machine := New(bytecode)
+			if err := machine.Run(); err != nil {
+				errs <- err
+				return
+			}
+			if result, ok := machine.LastPoppedStackElem().(*object.Integer); !ok || result.Value != 203 {
+				errs <- fmt.Errorf("wrong result: %s", machine.LastPoppedStackElem().Inspect())
+				return
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestParallelMap(t *testing.T) {
+	runVmTests(t, []vmTestCase{
+		{`pmap(fn(x) { x * 2 }, [1, 2, 3, 4, 5], 2)`, []int{2, 4, 6, 8, 10}},
+		{`pmap(fn(x) { x * x }, [1, 2, 3], 8)`, []int{1, 4, 9}},
+		{`pmap(fn(x) { x + 1 }, [])`, []int{}},
+		{`pmap(len, ["a", "bc", "def"], 3)`, []int{1, 2, 3}},
+		{`let offset = 10; let f = fn(n) { pmap(fn(x) { x + n }, [1, 2, 3], 3) }; f(offset)`, []int{11, 12, 13}},
+		{`pmap(fn(x) { let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(x) }, [10, 15, 20], 3)`,
+			[]int{55, 610, 6765}},
+		{`let limit = 2; pmap(fn(x) { x > limit }, [1, 2, 3])`,
+			&object.Error{Message: "cannot run <anonymous> on several workers: it uses global variables"}},
+		{`let limit = 2; let above = fn(x) { x > limit }; pmap(fn(x) { above(x) }, [1, 2, 3])`,
+			&object.Error{Message: "cannot run <anonymous> on several workers: it uses global variables"}},
+		{`let count = 0; let f = fn(x) { count = count + x }; pmap(f, [1, 2])`,
+			&object.Error{Message: "cannot run f on several workers: it uses global variables"}},
+		{`pmap(memoize(fn(x) { x }), [1, 2, 3])`,
+			&object.Error{Message: "cannot run a built-in function on several workers: it keeps state between calls"}},
+		{`pmap(fn(x) { x }, [1], 0)`,
+			&object.Error{Message: "third argument to `pmap` must be a positive INTEGER, got 0"}},
+		{`pmap(1, [1])`, &object.Error{Message: "first argument to `pmap` must be a function, got INTEGER"}},
+	})
+
+	testCallbackError(t, `pmap(fn(x) { x + "a" }, [1, 2, 3, 4], 2)`)
+}
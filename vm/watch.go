@@ -0,0 +1,106 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/object"
+)
+
+// ErrWatchpoint is returned by Run and Continue when an instruction wrote a watched variable.
+// The VM stops right after the write, LastWatchHit describes it and Continue resumes execution.
+var ErrWatchpoint = errors.New("watchpoint")
+
+// WatchHit describes the write of a watched variable. Function and Ip locate the OpSetGlobal or OpSetLocal
+// instruction that wrote it, Index is the global index or the local slot of the variable.
+// Name is the name of the variable when it is known, Old and New are its values before and after the write.
+type WatchHit struct {
+	Function *object.CompiledFunction
+	Ip       int
+	Global   bool
+	Index    int
+	Name     string
+	Old      object.Object
+	New      object.Object
+}
+
+// watchpoint is a watched variable, the local slot of a compiled function or a global index when fn is nil
+type watchpoint struct {
+	fn    *object.CompiledFunction
+	index int
+}
+
+// WatchGlobal stops execution whenever the global binding with the given index is written
+func (vm *VM) WatchGlobal(index int) {
+	vm.watch(watchpoint{index: index}, "")
+}
+
+// WatchLocal stops execution whenever the local binding in the given slot of a compiled function is written,
+// in any call of the function. The names of the slots are known when the function was compiled with debug information.
+func (vm *VM) WatchLocal(fn *object.CompiledFunction, index int) {
+	name := ""
+	if index < len(fn.Locals) {
+		name = fn.Locals[index]
+	}
+	vm.watch(watchpoint{fn: fn, index: index}, name)
+}
+
+// WatchVariable stops execution whenever the global binding with the given name is written.
+// The name is resolved with the symbol table the program was compiled with.
+func (vm *VM) WatchVariable(symbols *compiler.SymbolTable, name string) error {
+	symbol, ok := symbols.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		return fmt.Errorf("no global binding named %s", name)
+	}
+	vm.watch(watchpoint{index: symbol.Index}, name)
+	return nil
+}
+
+// WatchLocalVariable stops execution whenever the local binding with the given name of a compiled function is written.
+// The function must have been compiled with debug information, which keeps the names of its local bindings.
+func (vm *VM) WatchLocalVariable(fn *object.CompiledFunction, name string) error {
+	for index, local := range fn.Locals {
+		if local == name {
+			vm.watch(watchpoint{fn: fn, index: index}, name)
+			return nil
+		}
+	}
+	return fmt.Errorf("no local binding named %s, was the function compiled with debug information?", name)
+}
+
+// ClearWatchpoints removes every watchpoint
+func (vm *VM) ClearWatchpoints() {
+	vm.watchpoints = nil
+}
+
+// LastWatchHit returns the write that last stopped execution at a watchpoint, or nil when none did
+func (vm *VM) LastWatchHit() *WatchHit {
+	return vm.watchHit
+}
+
+// watch adds a watchpoint, name is empty when the name of the variable is not known
+func (vm *VM) watch(w watchpoint, name string) {
+	if vm.watchpoints == nil {
+		vm.watchpoints = make(map[watchpoint]string)
+	}
+	vm.watchpoints[w] = name
+}
+
+// hitWatchpoint reports whether the write of a variable by the instruction at offset ip of the current frame
+// stops execution, and records the write as the last watch hit when it does.
+func (vm *VM) hitWatchpoint(global bool, index, ip int, old, new object.Object) bool {
+	fn := vm.currentFrame().cl.Fn
+	w := watchpoint{index: index}
+	if !global {
+		w.fn = fn
+	}
+
+	name, ok := vm.watchpoints[w]
+	if !ok {
+		return false
+	}
+
+	vm.watchHit = &WatchHit{Function: fn, Ip: ip, Global: global, Index: index, Name: name, Old: old, New: new}
+	return true
+}
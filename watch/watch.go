@@ -0,0 +1,220 @@
+// Package watch checks Monkey files every time they change, it backs the `monkey watch` command.
+// A file is parsed, type checked and compiled only when its content is new: the results are cached
+// by the hash of the content, so saving a file without changes or undoing an edit reports the
+// diagnostics again without doing the work again, and files that did not change are not read at all.
+// A Checker with a disk cache also shares the files it compiled with `monkey run` and later watch sessions.
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/ast"
+	"github.com/yourfavoritedev/golang-interpreter/cache"
+	"github.com/yourfavoritedev/golang-interpreter/compiler"
+	"github.com/yourfavoritedev/golang-interpreter/diagnostic"
+	"github.com/yourfavoritedev/golang-interpreter/feature"
+	"github.com/yourfavoritedev/golang-interpreter/lexer"
+	"github.com/yourfavoritedev/golang-interpreter/parser"
+	"github.com/yourfavoritedev/golang-interpreter/runner"
+	"github.com/yourfavoritedev/golang-interpreter/typecheck"
+)
+
+// maxCachedResults bounds the results kept by a Checker, the cache is emptied when a new result does not fit
+const maxCachedResults = 256
+
+// Result is what checking the content of a file found
+type Result struct {
+	// Program is the parsed file, it is nil when the file has parser errors or was compiled from the disk cache
+	Program *ast.Program
+	// Bytecode is the compiled file, it is nil when the file has errors
+	Bytecode *compiler.Bytecode
+	// Diagnostics are the errors and the warnings of the parser, the type checker and the compiler, in that order
+	Diagnostics diagnostic.Diagnostics
+	// Err is the error that stopped the compilation, compiler errors have no position so they are not diagnostics
+	Err error
+}
+
+// OK reports whether the file is free of errors, it can still have warnings
+func (r *Result) OK() bool {
+	return r.Err == nil && len(r.Diagnostics.Errors()) == 0
+}
+
+// file is the last seen state of a watched file
+type file struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// Checker checks files and remembers what it found, see the package documentation
+type Checker struct {
+	Features feature.Set
+	// Strict reports expression statements at file scope whose result is unused, like `monkey run --strict`
+	Strict bool
+	// NoStdlib checks the files without the standard library, like `monkey run --no-stdlib`
+	NoStdlib bool
+	// Checks counts the contents that were actually parsed and compiled, results taken from a cache are not counted
+	Checks int
+	// Cache keeps the files compiled without errors on disk, it is not used when it is nil
+	Cache *cache.Cache
+
+	files   map[string]*file
+	results map[[sha256.Size]byte]*Result
+}
+
+// NewChecker creates a Checker with an empty cache
+func NewChecker(features feature.Set) *Checker {
+	return &Checker{
+		Features: features,
+		files:    make(map[string]*file),
+		results:  make(map[[sha256.Size]byte]*Result),
+	}
+}
+
+// Check returns the result of checking the source, taken from the cache when the same source was checked before
+func (c *Checker) Check(source []byte) *Result {
+	hash := sha256.Sum256(source)
+	if result, ok := c.results[hash]; ok {
+		return result
+	}
+
+	result, ok := c.load(string(source))
+	if !ok {
+		c.Checks++
+		result = c.check(string(source))
+	}
+	if len(c.results) >= maxCachedResults {
+		c.results = make(map[[sha256.Size]byte]*Result)
+	}
+	c.results[hash] = result
+	return result
+}
+
+// load returns the result of the source compiled without errors from the disk cache
+func (c *Checker) load(source string) (*Result, bool) {
+	if c.Cache == nil {
+		return nil, false
+	}
+	library := runner.Stdlib(c.options())
+	compiled, ok := c.Cache.Load(runner.CacheKey(c.Cache, source, c.options()), library.Constants())
+	if !ok {
+		return nil, false
+	}
+	return &Result{Bytecode: compiled.Bytecode, Diagnostics: compiled.Diagnostics}, true
+}
+
+// options returns the options `monkey run` compiles the files with, given the settings of the Checker
+func (c *Checker) options() runner.Options {
+	return runner.Options{Features: c.Features, Strict: c.Strict, NoStdlib: c.NoStdlib}
+}
+
+// check parses, type checks and compiles the source the way `monkey run` does before running it,
+// a file free of errors is stored in the disk cache
+func (c *Checker) check(source string) *Result {
+	result := &Result{}
+	p := parser.New(lexer.New(source))
+	p.SetFeatures(c.Features)
+	program := p.ParseProgram()
+	result.Diagnostics = append(result.Diagnostics, p.Diagnostics()...)
+	if len(p.Errors()) != 0 {
+		return result
+	}
+	result.Program = program
+
+	result.Diagnostics = append(result.Diagnostics, typecheck.Check(program)...)
+	if c.Strict {
+		result.Diagnostics = append(result.Diagnostics, runner.UnusedResults(program)...)
+	}
+	if !result.OK() {
+		return result
+	}
+
+	// the globals of a script are defined, so references to ARGV, ENV and the standard library compile
+	library := runner.Stdlib(c.options())
+	symbolTable, _ := runner.NewSymbolTable(library, runner.Globals(nil, nil))
+	comp := compiler.NewWithState(symbolTable, library.Constants())
+	if err := comp.Compile(program); err != nil {
+		result.Err = err
+		return result
+	}
+	result.Diagnostics = append(result.Diagnostics, comp.Diagnostics()...)
+	result.Bytecode = comp.Bytecode()
+	if c.Cache != nil {
+		compiled := &cache.Program{Bytecode: result.Bytecode, Diagnostics: result.Diagnostics}
+		c.Cache.Store(runner.CacheKey(c.Cache, source, c.options()), compiled, library.Constants())
+	}
+	return result
+}
+
+// Changed checks every file whose content changed since the last call, or since the Checker was created,
+// and returns their results by path. A file whose modification time and size did not change is not read.
+func (c *Checker) Changed(paths []string) (map[string]*Result, error) {
+	changed := make(map[string]*Result)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		seen, ok := c.files[path]
+		if ok && info.ModTime().Equal(seen.modTime) && info.Size() == seen.size {
+			continue
+		}
+
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(source)
+		c.files[path] = &file{modTime: info.ModTime(), size: info.Size(), hash: hash}
+		// touching a file without changing it does not report it again
+		if ok && hash == seen.hash {
+			continue
+		}
+		changed[path] = c.Check(source)
+	}
+	return changed, nil
+}
+
+// Watch checks the files every interval until the context is cancelled and writes the result of every file
+// whose content changed to out: its diagnostics, or "ok" when it has none.
+// It returns the error of a file that cannot be read.
+func (c *Checker) Watch(ctx context.Context, paths []string, interval time.Duration, out io.Writer) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		changed, err := c.Changed(paths)
+		if err != nil {
+			return err
+		}
+		// the files are reported in the order they were given
+		for _, path := range paths {
+			if result, ok := changed[path]; ok {
+				Report(out, path, result)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report writes the result of checking the file at path
+func Report(out io.Writer, path string, result *Result) {
+	if result.OK() && len(result.Diagnostics) == 0 {
+		fmt.Fprintf(out, "%s: ok\n", path)
+		return
+	}
+	fmt.Fprintf(out, "%s:\n", path)
+	diagnostic.Render(out, result.Diagnostics)
+	if result.Err != nil {
+		fmt.Fprintf(out, "compilation failed: %s\n", result.Err)
+	}
+}
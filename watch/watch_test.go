@@ -0,0 +1,149 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourfavoritedev/golang-interpreter/cache"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		source   string
+		ok       bool
+		expected string
+	}{
+		{"let x = 1; puts(x + len(ARGV));", true, ""},
+		{"let x = ;", false, "1:9: error[E001]: no prefix parse function for ; found"},
+		{`let x: int = "a";`, false, "1:5: error[E302]: cannot use string value as int in let x"},
+		{"let len = 1;", true, "1:5: warning[W101]: let len shadows the built-in function len"},
+	}
+
+	for _, tt := range tests {
+		c := NewChecker(nil)
+		result := c.Check([]byte(tt.source))
+		if result.OK() != tt.ok {
+			t.Errorf("wrong result for %q. want ok=%t, got=%v %v", tt.source, tt.ok, result.Diagnostics, result.Err)
+		}
+		if tt.ok && result.Bytecode == nil {
+			t.Errorf("no bytecode for %q", tt.source)
+		}
+		if tt.expected != "" && (len(result.Diagnostics) == 0 || result.Diagnostics[0].String() != tt.expected) {
+			t.Errorf("wrong diagnostics for %q. want=%q, got=%v", tt.source, tt.expected, result.Diagnostics)
+		}
+	}
+
+	c := NewChecker(nil)
+	if result := c.Check([]byte("x")); result.Err == nil || result.Err.Error() != "undefined variable: x" {
+		t.Errorf("wrong compiler error. got=%v", result.Err)
+	}
+
+	// checking the same source again takes the result from the cache
+	c.Strict = true
+	c.Check([]byte("1 + 1;"))
+	c.Check([]byte("1 + 1;"))
+	if c.Checks != 2 {
+		t.Errorf("wrong number of checks. want=2, got=%d", c.Checks)
+	}
+}
+
+func TestCheckDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	first := NewChecker(nil)
+	first.Cache = &cache.Cache{Dir: dir}
+	first.Check([]byte("let len = 1;"))
+	first.Check([]byte("let x = ;"))
+
+	// another Checker sharing the directory takes the file compiled without errors from the disk
+	second := NewChecker(nil)
+	second.Cache = &cache.Cache{Dir: dir}
+	result := second.Check([]byte("let len = 1;"))
+	if second.Checks != 0 || !result.OK() || result.Bytecode == nil || result.Program != nil {
+		t.Errorf("expected the result from the disk cache. got checks=%d, %v", second.Checks, result)
+	}
+	if len(result.Diagnostics) != 1 || result.Diagnostics[0].Code != "W101" {
+		t.Errorf("wrong diagnostics from the disk cache. got=%v", result.Diagnostics)
+	}
+	if result := second.Check([]byte("let x = ;")); second.Checks != 1 || result.OK() {
+		t.Errorf("files with errors must be checked again. got checks=%d", second.Checks)
+	}
+
+	// the settings are part of the key
+	third := NewChecker(nil)
+	third.Cache = &cache.Cache{Dir: dir}
+	third.NoStdlib = true
+	third.Check([]byte("let len = 1;"))
+	if third.Checks != 1 {
+		t.Errorf("wrong number of checks without the standard library. want=1, got=%d", third.Checks)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.mky"), filepath.Join(dir, "b.mky")
+	write := func(path, source string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatalf("writing %s failed: %s", path, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("setting the time of %s failed: %s", path, err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write(a, "let a = 1;", start)
+	write(b, "let b = ;", start)
+
+	c := NewChecker(nil)
+	changed, err := c.Changed([]string{a, b})
+	if err != nil || len(changed) != 2 || !changed[a].OK() || changed[b].OK() {
+		t.Fatalf("wrong first check. got=%v, %v", changed, err)
+	}
+
+	// only the file that changed is checked again, touching a file does not report it
+	write(b, "let b = 2;", start.Add(time.Minute))
+	write(a, "let a = 1;", start.Add(time.Minute))
+	changed, err = c.Changed([]string{a, b})
+	if err != nil || len(changed) != 1 || !changed[b].OK() {
+		t.Fatalf("wrong second check. got=%v, %v", changed, err)
+	}
+
+	// undoing an edit reports the file again from the cache
+	write(b, "let b = ;", start.Add(2*time.Minute))
+	changed, _ = c.Changed([]string{a, b})
+	if len(changed) != 1 || changed[b].OK() || c.Checks != 3 {
+		t.Errorf("wrong check after undoing. got=%v, checks=%d", changed, c.Checks)
+	}
+
+	if _, err := c.Changed([]string{filepath.Join(dir, "missing.mky")}); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.mky")
+	if err := os.WriteFile(path, []byte("let x: int = true;"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out bytes.Buffer
+	if err := NewChecker(nil).Watch(ctx, []string{path}, time.Millisecond, &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := path + ":\n1:5: error[E302]: cannot use bool value as int in let x\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+
+	out.Reset()
+	Report(&out, path, NewChecker(nil).Check([]byte("1")))
+	if !strings.HasSuffix(out.String(), ": ok\n") {
+		t.Errorf("wrong report. got=%q", out.String())
+	}
+}